@@ -0,0 +1,87 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"expvar"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// expvarMeter is a changelog.Meter exporting its metrics through expvar,
+// which serves them as JSON at /debug/vars. Latencies are the total number of
+// milliseconds spent, so the mean latency is the total divided by the count.
+type expvarMeter struct {
+	requests         *expvar.Map
+	requestLatencyMs *expvar.Int
+	fetches          *expvar.Map
+	fetchErrors      *expvar.Map
+	fetchLatencyMs   *expvar.Map
+	cacheHits        *expvar.Map
+	cacheMisses      *expvar.Map
+}
+
+// newExpvarMeter creates an expvarMeter whose metrics are grouped under name.
+// It panics if name is already published.
+func newExpvarMeter(name string) *expvarMeter {
+	m := &expvarMeter{
+		requests:         new(expvar.Map).Init(),
+		requestLatencyMs: new(expvar.Int),
+		fetches:          new(expvar.Map).Init(),
+		fetchErrors:      new(expvar.Map).Init(),
+		fetchLatencyMs:   new(expvar.Map).Init(),
+		cacheHits:        new(expvar.Map).Init(),
+		cacheMisses:      new(expvar.Map).Init(),
+	}
+	vars := expvar.NewMap(name)
+	// Requests are counted by HTTP code, "200" for successful requests
+	vars.Set("requests", m.requests)
+	vars.Set("request_latency_ms", m.requestLatencyMs)
+	// Fetches are counted by repository, ex. "cos.googlesource.com/cos/repo"
+	vars.Set("fetches", m.fetches)
+	vars.Set("fetch_errors", m.fetchErrors)
+	vars.Set("fetch_latency_ms", m.fetchLatencyMs)
+	// Cache lookups are counted by kind, ex. "manifest" or "commits"
+	vars.Set("cache_hits", m.cacheHits)
+	vars.Set("cache_misses", m.cacheMisses)
+	return m
+}
+
+func (m *expvarMeter) ObserveChangelog(latency time.Duration, err utils.ChangelogError) {
+	code := "200"
+	if err != nil {
+		code = err.HTTPCode()
+	}
+	m.requests.Add(code, 1)
+	m.requestLatencyMs.Add(latency.Milliseconds())
+}
+
+func (m *expvarMeter) ObserveFetch(instanceURL, repo string, latency time.Duration, err utils.ChangelogError) {
+	key := instanceURL + "/" + repo
+	m.fetches.Add(key, 1)
+	if err != nil {
+		m.fetchErrors.Add(key, 1)
+	}
+	m.fetchLatencyMs.Add(key, latency.Milliseconds())
+}
+
+func (m *expvarMeter) ObserveCacheLookup(kind string, hit bool) {
+	if hit {
+		m.cacheHits.Add(kind, 1)
+	} else {
+		m.cacheMisses.Add(kind, 1)
+	}
+}
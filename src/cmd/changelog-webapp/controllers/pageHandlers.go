@@ -17,15 +17,19 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
-	"strings"
 	"text/template"
+	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/storage"
 	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	changelogstore "cos.googlesource.com/cos/tools.git/src/pkg/changelog/store"
 	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
 	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
 
@@ -34,6 +38,22 @@ import (
 
 const (
 	subjectLen int = 100
+
+	// Maximum time spent retrieving a changelog before the request is abandoned
+	changelogTimeout = 5 * time.Minute
+
+	// Maximum number of Gitiles responses kept in the changelog cache
+	changelogCacheEntries = 10000
+
+	// Time manifest tags and files are kept in the findbuild cache
+	findBuildCacheTTL = time.Hour
+
+	// Maximum number of commit log requests in flight across all changelogs
+	changelogWorkers = 128
+
+	// Maximum number of commits retrieved for each repository of a changelog,
+	// whatever the requested query size
+	changelogMaxCommitsPerRepo = 5000
 )
 
 var (
@@ -49,6 +69,8 @@ var (
 	envQuerySize                   string
 	envBoard                       string
 	artifactsBucket                string
+	changelogStoreBucket           string
+	storageClient                  *storage.Client
 
 	staticBasePath            string
 	indexTemplate             *template.Template
@@ -59,6 +81,23 @@ var (
 	findReleasedBuildTemplate *template.Template
 	statusForbiddenTemplate   *template.Template
 	basicTextTemplate         *template.Template
+
+	// Cache of Gitiles responses from the external GoB instance. Internal
+	// responses are never cached so every request is checked against the
+	// caller's own permissions.
+	externalChangelogCache = changelog.NewMemoryCache(changelogCacheEntries)
+
+	// Cache of the manifest tags and files used to find builds on the
+	// external GoB instance. Like externalChangelogCache, it is never used
+	// for internal requests.
+	externalFindBuildCache = findbuild.NewMemoryCache(findBuildCacheTTL, nil)
+
+	// Operational metrics of changelog requests, served at /debug/vars
+	changelogMeter = newExpvarMeter("changelog")
+
+	// Workers shared by all changelog requests, so concurrent requests cannot
+	// exhaust sockets or get throttled by GoB
+	changelogPool = changelog.NewWorkerPool(changelogWorkers, 0, 0)
 )
 
 func init() {
@@ -92,6 +131,14 @@ func init() {
 		log.Fatalf("Failed to retrieve secret for COS_CHANGELOG_ARTIFACTS_BUCKET_NAME with key name %s\n%v", os.Getenv("COS_CHANGELOG_ARTIFACTS_BUCKET_NAME"), err)
 	}
 
+	changelogStoreBucket = os.Getenv("COS_CHANGELOG_STORE_BUCKET")
+	if changelogStoreBucket != "" {
+		storageClient, err = storage.NewClient(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to setup storage client: %v", err)
+		}
+	}
+
 	externalGerritInstance = os.Getenv("COS_EXTERNAL_GERRIT_INSTANCE")
 	externalFallbackGerritInstance = os.Getenv("COS_EXTERNAL_FALLBACK_GERRIT_INSTANCE")
 	externalGoBInstance = os.Getenv("COS_EXTERNAL_GOB_INSTANCE")
@@ -113,7 +160,12 @@ type changelogData struct {
 	Target    string
 	Additions map[string]*changelog.RepoLog
 	Removals  map[string]*changelog.RepoLog
-	Internal  bool
+	// Repositories added to or removed from the manifest file
+	AddedRepos   []*changelog.RepoChange
+	RemovedRepos []*changelog.RepoChange
+	// Settings of the manifest file that changed
+	ManifestChanges []*changelog.ManifestChange
+	Internal        bool
 }
 
 type changelogPage struct {
@@ -125,10 +177,31 @@ type changelogPage struct {
 	TargetMilestone string
 	QuerySize       string
 	RepoTables      []*repoTable
+	SecurityFixes   []*securityFix
+	RepoChanges     []*repoChange
+	ManifestChanges []*changelog.ManifestChange
 	Internal        bool
 	Sysctl          sysctlChanges
 }
 
+// securityFix is a commit added to the target build that references a CVE
+type securityFix struct {
+	CVE     string
+	URL     string
+	Repo    string
+	SHA     *shaAttr
+	Subject string
+}
+
+// repoChange is a repository recorded in the manifest file of only one of the
+// two builds
+type repoChange struct {
+	IsAddition bool
+	Path       string
+	Repo       string
+	Revision   *shaAttr
+}
+
 type sysctlChanges struct {
 	Changes  [][]string
 	NotFound string
@@ -199,6 +272,15 @@ func gobCommitLink(instance, repo, SHA string) string {
 	return fmt.Sprintf("https://%s/%s/+/%s", instance, repo, SHA)
 }
 
+// commitLink links to the CL a commit was reviewed in if it is known, and to
+// the commit itself otherwise.
+func commitLink(instance, repo string, commit *changelog.Commit) string {
+	if commit.ReviewURL != "" {
+		return commit.ReviewURL
+	}
+	return gobCommitLink(instance, repo, commit.SHA)
+}
+
 func gobDiffLink(instance, repo, sourceSHA, targetSHA string, diffLink bool) string {
 	if !diffLink {
 		return fmt.Sprintf("https://%s/%s/+log/%s?n=10000", instance, repo, targetSHA)
@@ -206,18 +288,47 @@ func gobDiffLink(instance, repo, sourceSHA, targetSHA string, diffLink bool) str
 	return fmt.Sprintf("https://%s/%s/+log/%s..%s?n=10000", instance, repo, sourceSHA, targetSHA)
 }
 
+func cveLink(cve string) string {
+	return fmt.Sprintf("https://nvd.nist.gov/vuln/detail/%s", cve)
+}
+
+// createSecurityFixes lists the commits of a changelog that reference CVEs,
+// sorted by CVE and then by repository path.
+func createSecurityFixes(changes map[string]*changelog.RepoLog) []*securityFix {
+	var fixes []*securityFix
+	for repoPath, repoLog := range changelog.SecurityFixes(changes) {
+		for _, commit := range repoLog.Commits {
+			for _, cve := range commit.CVEs {
+				fixes = append(fixes, &securityFix{
+					CVE:     cve,
+					URL:     cveLink(cve),
+					Repo:    repoPath,
+					SHA:     &shaAttr{Name: commit.SHA[:8], URL: commitLink(repoLog.InstanceURL, repoLog.Repo, commit)},
+					Subject: commit.Subject,
+				})
+			}
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		if fixes[i].CVE != fixes[j].CVE {
+			return fixes[i].CVE < fixes[j].CVE
+		}
+		return fixes[i].Repo < fixes[j].Repo
+	})
+	return fixes
+}
+
 func createRepoTableEntry(instance, repo string, commit *changelog.Commit, isAddition bool) *repoTableEntry {
 	entry := new(repoTableEntry)
 	entry.IsAddition = isAddition
-	entry.SHA = &shaAttr{Name: commit.SHA[:8], URL: gobCommitLink(instance, repo, commit.SHA)}
+	entry.SHA = &shaAttr{Name: commit.SHA[:8], URL: commitLink(instance, repo, commit)}
 	entry.Subject = commit.Subject
 	if len(entry.Subject) > subjectLen {
 		entry.Subject = entry.Subject[:subjectLen]
 	}
-	entry.Bugs = make([]*bugAttr, len(commit.Bugs))
-	for i, bugURL := range commit.Bugs {
-		name := bugURL[strings.Index(bugURL, "/")+1:]
-		entry.Bugs[i] = &bugAttr{Name: name, URL: "http://" + bugURL}
+	entry.Bugs = make([]*bugAttr, len(commit.BugLinks))
+	for i, bug := range commit.BugLinks {
+		entry.Bugs[i] = &bugAttr{Name: bug.ID, URL: bug.URL}
 	}
 	entry.AuthorName = commit.AuthorName
 	entry.CommitterName = commit.CommitterName
@@ -226,8 +337,36 @@ func createRepoTableEntry(instance, repo string, commit *changelog.Commit, isAdd
 	return entry
 }
 
+// createRepoChanges lists the repositories added to the target build followed
+// by the repositories removed from it.
+func createRepoChanges(added, removed []*changelog.RepoChange) []*repoChange {
+	var output []*repoChange
+	for _, isAddition := range []bool{true, false} {
+		changes := removed
+		if isAddition {
+			changes = added
+		}
+		for _, change := range changes {
+			name := change.Revision
+			if len(name) == 40 {
+				name = name[:8]
+			}
+			output = append(output, &repoChange{
+				IsAddition: isAddition,
+				Path:       change.Path,
+				Repo:       change.Repo,
+				Revision:   &shaAttr{Name: name, URL: gobCommitLink(change.InstanceURL, change.Repo, change.Revision)},
+			})
+		}
+	}
+	return output
+}
+
 func createChangelogPage(data changelogData) *changelogPage {
 	page := &changelogPage{Source: data.Source, Target: data.Target, QuerySize: envQuerySize, Internal: data.Internal}
+	page.SecurityFixes = createSecurityFixes(data.Additions)
+	page.RepoChanges = createRepoChanges(data.AddedRepos, data.RemovedRepos)
+	page.ManifestChanges = data.ManifestChanges
 	for repoPath, addLog := range data.Additions {
 		diffLink := false
 		table := &repoTable{Name: repoPath}
@@ -268,7 +407,7 @@ func createChangelogPage(data changelogData) *changelogPage {
 	return page
 }
 
-func findBuildWithFallback(httpClient *http.Client, gerrit, fallbackGerrit, gob, repo, cl string, internal bool) (*findbuild.BuildResponse, bool, utils.ChangelogError) {
+func findBuildWithFallback(ctx context.Context, httpClient *http.Client, gerrit, fallbackGerrit, gob, repo, cl string, internal bool) (*findbuild.BuildResponse, bool, utils.ChangelogError) {
 	didFallback := false
 	request := &findbuild.BuildRequest{
 		HTTPClient:   httpClient,
@@ -277,7 +416,10 @@ func findBuildWithFallback(httpClient *http.Client, gerrit, fallbackGerrit, gob,
 		ManifestRepo: repo,
 		CL:           cl,
 	}
-	buildData, err := findbuild.FindBuild(request)
+	if !internal {
+		request.Cache = externalFindBuildCache
+	}
+	buildData, err := findbuild.FindBuild(ctx, request)
 	if err != nil && err.HTTPCode() == "404" {
 		log.Debugf("Cl %s not found in Gerrit instance, using fallback", cl)
 		fallbackRequest := &findbuild.BuildRequest{
@@ -286,8 +428,9 @@ func findBuildWithFallback(httpClient *http.Client, gerrit, fallbackGerrit, gob,
 			GitilesHost:  gob,
 			ManifestRepo: repo,
 			CL:           cl,
+			Cache:        request.Cache,
 		}
-		buildData, err = findbuild.FindBuild(fallbackRequest)
+		buildData, err = findbuild.FindBuild(ctx, fallbackRequest)
 		didFallback = true
 	}
 	return buildData, didFallback, err
@@ -308,17 +451,34 @@ func findReleaseBuild(httpClient *http.Client, gerrit, gob, repo, cl string, int
 	return buildData, err
 }
 
+// errorStatus returns the HTTP status code of the error page for a given error.
+// Failures retrieving data from Gitiles are reported as bad gateway errors,
+// since they are not caused by the changelog service itself.
+func errorStatus(displayErr utils.ChangelogError) int {
+	switch {
+	case errors.Is(displayErr, utils.ErrBuildNotFound):
+		return http.StatusNotFound
+	case errors.Is(displayErr, utils.ErrManifestMalformed), errors.Is(displayErr, utils.ErrRepoLogUnavailable):
+		return http.StatusBadGateway
+	}
+	if code, err := strconv.Atoi(displayErr.HTTPCode()); err == nil && code >= 400 && code < 600 {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
 // handleError creates the error page for a given error
 func handleError(w http.ResponseWriter, r *http.Request, displayErr utils.ChangelogError, currPage string) {
+	w.WriteHeader(errorStatus(displayErr))
 	err := basicTextTemplate.Execute(w, &basicTextPage{
 		Header:     displayErr.Header(),
 		Body:       displayErr.HTMLError(),
 		ActivePage: currPage,
 		SignedIn:   SignedIn(r),
 	})
+	// The status code has already been written, so only log the failure
 	if err != nil {
 		log.Error(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -342,7 +502,9 @@ func HandleReadme(w http.ResponseWriter, r *http.Request) {
 
 // HandleChangelog serves the changelog page
 func HandleChangelog(w http.ResponseWriter, r *http.Request) {
-	if RequireToken(w, r, "/changelog/") {
+	// External changelogs only read public instances, so users who are not
+	// signed in can generate them anonymously.
+	if r.FormValue("internal") == "true" && RequireToken(w, r, "/changelog/") {
 		return
 	}
 	var err error
@@ -380,16 +542,39 @@ func HandleChangelog(w http.ResponseWriter, r *http.Request) {
 		querySize, _ = strconv.Atoi(envQuerySize)
 	}
 	internal, instance, manifestRepo := false, externalGoBInstance, externalManifestRepo
+	opts := &changelog.Options{Cache: externalChangelogCache, Meter: changelogMeter, Pool: changelogPool, MaxCommitsPerRepo: changelogMaxCommitsPerRepo, ResolveCLs: true}
 	if r.FormValue("internal") == "true" {
 		internal, instance, manifestRepo = true, internalGoBInstance, internalManifestRepo
+		opts.Cache = nil
+	}
+	var httpClient *http.Client
+	if SignedIn(r) {
+		if httpClient, err = HTTPClient(w, r); err != nil {
+			if internal {
+				loginURL := GetLoginURL("/changelog/", false)
+				http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
+				return
+			}
+			log.Warnf("failed to create authorized client, generating changelog anonymously: %v", err)
+			httpClient = nil
+		}
 	}
-	httpClient, err := HTTPClient(w, r)
-	if err != nil {
-		loginURL := GetLoginURL("/changelog/", false)
-		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
-		return
+	ctx, cancel := context.WithTimeout(r.Context(), changelogTimeout)
+	defer cancel()
+	generate := func(ctx context.Context) (*changelog.Document, utils.ChangelogError) {
+		return changelog.ChangelogWithBuildInfo(ctx, httpClient, source, target, instance, manifestRepo, croslandURL, querySize, opts)
+	}
+	var doc *changelog.Document
+	var utilErr utils.ChangelogError
+	// Internal changelogs are never stored so every request is checked
+	// against the caller's own permissions. Changelogs of branches are not
+	// stored either since branches move.
+	if changelogStoreBucket != "" && !internal && changelog.StableBuild(source) && changelog.StableBuild(target) {
+		prefix := fmt.Sprintf("%s/%s/n%d", instance, manifestRepo, querySize)
+		doc, utilErr = changelogstore.New(storageClient, changelogStoreBucket, prefix).Load(ctx, source, target, generate)
+	} else {
+		doc, utilErr = generate(ctx)
 	}
-	added, removed, utilErr := changelog.Changelog(httpClient, source, target, instance, manifestRepo, croslandURL, querySize)
 	if utilErr != nil {
 		log.Errorf("error retrieving changelog between builds %s and %s on GoB instance: %s with manifest repository: %s\n%v\n",
 			source, target, externalGoBInstance, externalManifestRepo, utilErr)
@@ -397,19 +582,29 @@ func HandleChangelog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	page := createChangelogPage(changelogData{
-		Source:    source,
-		Target:    target,
-		Additions: added,
-		Removals:  removed,
-		Internal:  internal,
+		Source:          source,
+		Target:          target,
+		Additions:       doc.Additions,
+		Removals:        doc.Removals,
+		AddedRepos:      doc.AddedRepos,
+		RemovedRepos:    doc.RemovedRepos,
+		ManifestChanges: doc.ManifestChanges,
+		Internal:        internal,
 	})
+	// Milestones default to the ones recorded in the manifest files
+	if sourceMilestone == "" && doc.SourceBuild != nil && doc.SourceBuild.Milestone != 0 {
+		sourceMilestone = strconv.Itoa(doc.SourceBuild.Milestone)
+	}
+	if targetMilestone == "" && doc.TargetBuild != nil && doc.TargetBuild.Milestone != 0 {
+		targetMilestone = strconv.Itoa(doc.TargetBuild.Milestone)
+	}
 	page.SourceMilestone = sourceMilestone
 	page.SourceBoard = sourceBoard
 	page.TargetMilestone = targetMilestone
 	page.TargetBoard = targetBoard
 
 	var foundSource, foundTarget bool
-	page.Sysctl.Changes, foundSource, foundTarget = changelog.GetSysctlDiff(artifactsBucket, sourceBoard,
+	page.Sysctl.Changes, foundSource, foundTarget = changelog.GetSysctlDiff(ctx, artifactsBucket, sourceBoard,
 		sourceMilestone, source, targetBoard, targetMilestone, target)
 	page.Sysctl.NotEmpty = false
 	if !foundSource {
@@ -464,7 +659,7 @@ func HandleFindBuild(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
 		return
 	}
-	buildData, didFallback, utilErr := findBuildWithFallback(httpClient, gerrit, fallbackGerrit, gob, repo, cl, internal)
+	buildData, didFallback, utilErr := findBuildWithFallback(r.Context(), httpClient, gerrit, fallbackGerrit, gob, repo, cl, internal)
 	if utilErr != nil {
 		log.Errorf("error retrieving build for CL %s with internal set to %t\n%v", cl, internal, utilErr)
 		handleError(w, r, utilErr, "/findbuild/")
@@ -24,6 +24,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -31,15 +32,15 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog/render"
 	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
 
 	"github.com/urfave/cli/v2"
-	"go.chromium.org/luci/common/api/gerrit"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -51,18 +52,28 @@ const (
 	externalManifestRepo = "cos/manifest-snapshots"
 )
 
-func getHTTPClient() (*http.Client, error) {
+// getHTTPClient creates a client authenticating as the service account of the
+// JSON key at credentials, or with Application Default Credentials if empty.
+func getHTTPClient(credentials string) (*http.Client, error) {
 	log.Debug("Creating HTTP client")
-	creds, err := google.FindDefaultCredentials(context.Background(), gerrit.OAuthScope)
-	if err != nil || len(creds.JSON) == 0 {
+	if credentials != "" {
+		return utils.ServiceAccountFileHTTPClient(context.Background(), credentials)
+	}
+	httpClient, err := utils.DefaultCredentialsHTTPClient(context.Background())
+	if err != nil {
 		return nil, fmt.Errorf("no application default credentials found - run `gcloud auth application-default login` and try again")
 	}
-	return oauth2.NewClient(oauth2.NoContext, creds.TokenSource), nil
+	return httpClient, nil
 }
 
-func writeChangelogAsJSON(source string, target string, changes map[string]*changelog.RepoLog) error {
-	fileName := fmt.Sprintf("%s -> %s.json", source, target)
-	log.Infof("Writing changelog to %s\n", fileName)
+// changelogFormats maps each supported output format to the extension of the
+// files it is written to.
+var changelogFormats = map[string]string{
+	"json":     "json",
+	"markdown": "md",
+}
+
+func writeChangelogAsJSON(fileName, source, target string, changes map[string]*changelog.RepoLog) error {
 	jsonData, err := json.MarshalIndent(changes, "", "    ")
 	if err != nil {
 		return fmt.Errorf("writeChangelogAsJSON: error marshalling changelog from: %s to: %s\n%v", source, target, err)
@@ -73,22 +84,101 @@ func writeChangelogAsJSON(source string, target string, changes map[string]*chan
 	return nil
 }
 
-func generateChangelog(source, target, instance, manifestRepo string) error {
+func writeChangelogAsMarkdown(fileName, source, target string, changes map[string]*changelog.RepoLog) error {
+	var buf bytes.Buffer
+	if err := render.Markdown(&buf, fmt.Sprintf("%s -> %s", source, target), changes); err != nil {
+		return fmt.Errorf("writeChangelogAsMarkdown: error rendering changelog from: %s to: %s\n%v", source, target, err)
+	}
+	if err := ioutil.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writeChangelogAsMarkdown: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+// writeChangelog writes a changelog to "<source> -> <target>.<ext>" in the
+// requested format. Slashes of refs used as source or target are replaced by
+// underscores in the file name.
+func writeChangelog(format, source, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.%s", strings.ReplaceAll(source, "/", "_"), strings.ReplaceAll(target, "/", "_"), changelogFormats[format])
+	log.Infof("Writing changelog to %s\n", fileName)
+	if format == "markdown" {
+		return writeChangelogAsMarkdown(fileName, source, target, changes)
+	}
+	return writeChangelogAsJSON(fileName, source, target, changes)
+}
+
+// parseRepoMapping converts FROM=TO arguments into a repository mapping.
+func parseRepoMapping(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	mapping := make(map[string]string)
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("parseRepoMapping: invalid repository mapping %q, expected FROM=TO", arg)
+		}
+		mapping[parts[0]] = parts[1]
+	}
+	return mapping, nil
+}
+
+// changelogRequest holds the arguments and flags of the changelog mode
+type changelogRequest struct {
+	Source       string
+	Target       string
+	Instance     string
+	ManifestRepo string
+	// Output format, one of the keys of changelogFormats
+	Format   string
+	CacheDir string
+	// Service account key file to authenticate with, if not empty
+	Credentials string
+	// Only keep commits touching one of these directories, if not empty
+	Paths []string
+	Opts  *changelog.Options
+}
+
+func generateChangelog(req *changelogRequest) error {
 	start := time.Now()
-	httpClient, err := getHTTPClient()
+	if _, ok := changelogFormats[req.Format]; !ok {
+		return fmt.Errorf("generateChangelog: unsupported output format %q, expected json or markdown", req.Format)
+	}
+	httpClient, err := getHTTPClient(req.Credentials)
 	if err != nil {
-		return fmt.Errorf("generateChangelog: failed to create http client: \n%v", err)
+		// Public instances are readable anonymously, so only a key file
+		// passed explicitly must be usable.
+		if req.Credentials != "" {
+			return fmt.Errorf("generateChangelog: failed to create http client: \n%v", err)
+		}
+		log.Warnf("generateChangelog: %v\nQuerying public instances anonymously", err)
+		httpClient = nil
+	}
+	if req.CacheDir != "" {
+		cache, err := changelog.NewDiskCache(req.CacheDir)
+		if err != nil {
+			return fmt.Errorf("generateChangelog: failed to create cache: \n%v", err)
+		}
+		req.Opts.Cache = cache
 	}
-	sourceToTargetChanges, targetToSourceChanges, err := changelog.Changelog(httpClient, source, target, instance, manifestRepo, "", -1)
+	if len(req.Paths) > 0 {
+		req.Opts.FileChanges = true
+	}
+	source, target := req.Source, req.Target
+	sourceToTargetChanges, targetToSourceChanges, err := changelog.Changelog(context.Background(), httpClient, source, target, req.Instance, req.ManifestRepo, "", -1, req.Opts)
 	if err != nil {
 		return fmt.Errorf("generateChangelog: error retrieving changelog between builds %s and %s on GoB instance: %s with manifest repository: %s\n%v",
-			source, target, instance, manifestRepo, err)
+			source, target, req.Instance, req.ManifestRepo, err)
+	}
+	if len(req.Paths) > 0 {
+		sourceToTargetChanges = changelog.FilterByPath(sourceToTargetChanges, req.Paths...)
+		targetToSourceChanges = changelog.FilterByPath(targetToSourceChanges, req.Paths...)
 	}
-	if err := writeChangelogAsJSON(source, target, sourceToTargetChanges); err != nil {
+	if err := writeChangelog(req.Format, source, target, sourceToTargetChanges); err != nil {
 		log.Errorf("generateChangelog: error writing first changelog with source: %s and target: %s\n%v\n",
 			source, target, err)
 	}
-	if err := writeChangelogAsJSON(target, source, targetToSourceChanges); err != nil {
+	if err := writeChangelog(req.Format, target, source, targetToSourceChanges); err != nil {
 		log.Errorf("generateChangelog: Error writing second changelog with source: %s and target: %s\n%v\n",
 			target, source, err)
 	}
@@ -96,45 +186,63 @@ func generateChangelog(source, target, instance, manifestRepo string) error {
 	return nil
 }
 
-func getBuildForCL(gerrit, fallback, gob, manifestRepo, targetCL string) error {
-	httpClient, err := getHTTPClient()
+func getBuildForCL(credentials, gerrit, fallback, gob, manifestRepo, cacheDir, targetCL string, allMilestones, upstreamKernel bool) error {
+	httpClient, err := getHTTPClient(credentials)
 	if err != nil {
 		return fmt.Errorf("error creating http client: %v", err)
 	}
+	var cache findbuild.Cache
+	if cacheDir != "" {
+		diskCache, err := changelog.NewDiskCache(cacheDir)
+		if err != nil {
+			return fmt.Errorf("error creating cache: %v", err)
+		}
+		cache = findbuild.NewMemoryCache(0, diskCache)
+	}
 	req := &findbuild.BuildRequest{
-		HTTPClient:   httpClient,
-		GerritHost:   gerrit,
-		GitilesHost:  gob,
-		ManifestRepo: manifestRepo,
-		CL:           targetCL,
+		HTTPClient:     httpClient,
+		GerritHost:     gerrit,
+		GitilesHost:    gob,
+		ManifestRepo:   manifestRepo,
+		CL:             targetCL,
+		AllMilestones:  allMilestones,
+		UpstreamKernel: upstreamKernel,
+		Cache:          cache,
 	}
-	buildData, clErr := findbuild.FindBuild(req)
+	buildData, clErr := findbuild.FindBuild(context.Background(), req)
 	if clErr != nil && clErr.HTTPCode() == "404" {
 		log.Debugf("Query failed on Gerrit url %s and Gitiles url %s, retrying with fallback urls", externalGerritURL, externalGoBURL)
 		fallbackReq := &findbuild.BuildRequest{
-			HTTPClient:   httpClient,
-			GerritHost:   fallback,
-			GitilesHost:  gob,
-			ManifestRepo: manifestRepo,
-			CL:           targetCL,
+			HTTPClient:     httpClient,
+			GerritHost:     fallback,
+			GitilesHost:    gob,
+			ManifestRepo:   manifestRepo,
+			CL:             targetCL,
+			AllMilestones:  allMilestones,
+			UpstreamKernel: upstreamKernel,
+			Cache:          cache,
 		}
-		buildData, clErr = findbuild.FindBuild(fallbackReq)
+		buildData, clErr = findbuild.FindBuild(context.Background(), fallbackReq)
 	}
 	if clErr != nil {
 		return clErr
 	}
 	fmt.Printf("Build: %s\n", buildData.BuildNum)
+	for _, milestone := range buildData.Milestones {
+		fmt.Printf("%s: %s (CL %s)\n", milestone.Release, milestone.BuildNum, milestone.CLNum)
+	}
 	return nil
 }
 
 func main() {
-	var mode, gobURL, gerritURL, fallbackURL, manifestRepo string
-	var debug bool
+	var mode, gobURL, gerritURL, fallbackURL, manifestRepo, cacheDir, format, credentials string
+	var debug, allMilestones, upstreamKernel bool
+	opts := &changelog.Options{}
 	app := &cli.App{
 		Name:  "changelogctl",
 		Usage: "get commits between builds or first build containing CL",
 		Description: fmt.Sprintf("%s\n   %s",
-			"changelog usage: ./changelogctl -m changelog [build-number || image-name] [build-number || image-name]",
+			"changelog usage: ./changelogctl -m changelog [build-number || image-name || manifest-SHA] [build-number || image-name || manifest-SHA]",
 			"findbuild usage: ./changelogctl -m findbuild [CL-number || commit-SHA]",
 		),
 		Flags: []cli.Flag{
@@ -171,6 +279,89 @@ func main() {
 				Usage:       "`REPO` containing Manifest file",
 				Destination: &manifestRepo,
 			},
+			&cli.StringFlag{
+				Name:        "cache-dir",
+				Value:       "",
+				Usage:       "`DIR` to cache Gitiles responses and manifest tags in between runs. Caching is disabled if empty",
+				Destination: &cacheDir,
+			},
+			&cli.StringFlag{
+				Name:        "credentials",
+				Value:       "",
+				Usage:       "Service account key `FILE` to authenticate with. Application Default Credentials are used if empty",
+				Destination: &credentials,
+			},
+			&cli.BoolFlag{
+				Name:        "best-effort",
+				Value:       false,
+				Usage:       "Keep generating the changelog if some repositories cannot be queried",
+				Destination: &opts.BestEffort,
+			},
+			&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "Only include repositories whose name or path matches the glob `PATTERN`. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Exclude repositories whose name or path matches the glob `PATTERN`. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "map-repo",
+				Usage: "Query the commits of a manifest repository from another repository, given as `FROM=TO`, ex. chromiumos/=mirrors/chromiumos/. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "path",
+				Usage: "Only include commits touching a file under `DIR`, relative to the repository root. Can be repeated",
+			},
+			&cli.BoolFlag{
+				Name:        "files",
+				Value:       false,
+				Usage:       "List the files changed by each commit",
+				Destination: &opts.FileChanges,
+			},
+			&cli.BoolFlag{
+				Name:        "cls",
+				Value:       false,
+				Usage:       "Resolve the Gerrit CL of each commit",
+				Destination: &opts.ResolveCLs,
+			},
+			&cli.IntFlag{
+				Name:        "max-commits",
+				Value:       0,
+				Usage:       "Retrieve at most `N` commits for each repository. No limit by default",
+				Destination: &opts.MaxCommitsPerRepo,
+			},
+			&cli.StringFlag{
+				Name:        "manifest-file",
+				Value:       "",
+				Usage:       "`PATH` of the manifest file in the manifest repository. Defaults to snapshot.xml",
+				Destination: &opts.ManifestFileName,
+			},
+			&cli.StringFlag{
+				Name:        "tag-prefix",
+				Value:       "",
+				Usage:       "`PREFIX` prepended to build numbers to form manifest refs. Defaults to refs/tags/",
+				Destination: &opts.TagPrefix,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       "json",
+				Aliases:     []string{"f"},
+				Usage:       "Changelog output `FORMAT`. Acceptable values: json | markdown",
+				Destination: &format,
+			},
+			&cli.BoolFlag{
+				Name:        "all-milestones",
+				Value:       false,
+				Usage:       "Find the first build containing the CL on every release branch",
+				Destination: &allMilestones,
+			},
+			&cli.BoolFlag{
+				Name:        "upstream-kernel",
+				Value:       false,
+				Usage:       "Treat the commit SHA as an upstream Linux kernel commit backported to COS",
+				Destination: &upstreamKernel,
+			},
 			&cli.BoolFlag{
 				Name:        "debug",
 				Value:       false,
@@ -189,14 +380,31 @@ func main() {
 					return errors.New("must specify CL number (ex. 3280) or commit SHA (ex. 18d4ce48c1dc2f530120f85973fec348367f78a0)")
 				}
 				targetCL := c.Args().Get(0)
-				return getBuildForCL(gerritURL, fallbackURL, gobURL, manifestRepo, targetCL)
+				return getBuildForCL(credentials, gerritURL, fallbackURL, gobURL, manifestRepo, cacheDir, targetCL, allMilestones, upstreamKernel)
 			case "changelog":
 				if c.NArg() != 2 {
 					return errors.New("must specify two build numbers (ex. 13310.1034.0) or image names (ex. cos-rc-85-13310-1034-0) to retrieve changelog")
 				}
 				source := c.Args().Get(0)
 				target := c.Args().Get(1)
-				return generateChangelog(source, target, gobURL, manifestRepo)
+				opts.IncludeRepos = c.StringSlice("include")
+				opts.ExcludeRepos = c.StringSlice("exclude")
+				mapping, err := parseRepoMapping(c.StringSlice("map-repo"))
+				if err != nil {
+					return err
+				}
+				opts.RepoMapping = mapping
+				return generateChangelog(&changelogRequest{
+					Source:       source,
+					Target:       target,
+					Instance:     gobURL,
+					ManifestRepo: manifestRepo,
+					Format:       format,
+					CacheDir:     cacheDir,
+					Credentials:  credentials,
+					Paths:        c.StringSlice("path"),
+					Opts:         opts,
+				})
 			default:
 				return fmt.Errorf("please specify either \"findbuild\" or \"changelog\" mode")
 			}
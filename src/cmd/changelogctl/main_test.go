@@ -21,7 +21,11 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
 )
 
 const (
@@ -326,3 +330,110 @@ func TestFindBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteChangelog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changelogctl")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change working directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	changes := map[string]*changelog.RepoLog{
+		"src/platform/dev": {
+			InstanceURL: gitilesURL,
+			Repo:        "cos/platform/dev",
+			Commits:     []*changelog.Commit{{SHA: "4444444444444444444444444444444444444444", Subject: "Update README"}},
+		},
+	}
+	tests := map[string]struct {
+		format   string
+		source   string
+		target   string
+		fileName string
+		prefix   string
+	}{
+		"JSON": {
+			format:   "json",
+			source:   "15000.0.0",
+			target:   "15001.0.0",
+			fileName: "15000.0.0 -> 15001.0.0.json",
+			prefix:   "{",
+		},
+		"Markdown": {
+			format:   "markdown",
+			source:   "15000.0.0",
+			target:   "15001.0.0",
+			fileName: "15000.0.0 -> 15001.0.0.md",
+			prefix:   "# 15000.0.0 -> 15001.0.0\n",
+		},
+		"Refs": {
+			format:   "json",
+			source:   "refs/tags/dev/1",
+			target:   "refs/tags/dev/2",
+			fileName: "refs_tags_dev_1 -> refs_tags_dev_2.json",
+			prefix:   "{",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := writeChangelog(test.format, test.source, test.target, changes); err != nil {
+				t.Fatalf("writeChangelog failed: %v", err)
+			}
+			contents, err := ioutil.ReadFile(test.fileName)
+			if err != nil {
+				t.Fatalf("expected file %s to be created, got %v", test.fileName, err)
+			}
+			if !strings.HasPrefix(string(contents), test.prefix) {
+				t.Errorf("expected file to start with %q, got %q", test.prefix, contents)
+			}
+		})
+	}
+}
+
+func TestGenerateChangelogInvalidFormat(t *testing.T) {
+	req := &changelogRequest{Source: "15000.0.0", Target: "15001.0.0", Format: "pdf", Opts: &changelog.Options{}}
+	if err := generateChangelog(req); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestParseRepoMapping(t *testing.T) {
+	tests := map[string]struct {
+		args        []string
+		expected    map[string]string
+		expectedErr bool
+	}{
+		"No Mapping": {},
+		"Mappings": {
+			args:     []string{"chromiumos/=mirrors/chromiumos/", "a=cos.googlesource.com/b=c"},
+			expected: map[string]string{"chromiumos/": "mirrors/chromiumos/", "a": "cos.googlesource.com/b=c"},
+		},
+		"Missing Target": {
+			args:        []string{"chromiumos/="},
+			expectedErr: true,
+		},
+		"Missing Separator": {
+			args:        []string{"chromiumos/"},
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseRepoMapping(test.args)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected error: %v, got %v", test.expectedErr, err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected mapping %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -76,6 +76,11 @@ type InstallCommand struct {
 	debug              bool
 	test               bool
 	prepareBuildTools  bool
+	precompiled        bool
+	// staged is set when the drivers are installed to a staging directory by
+	// upgrade, which updates the host ld cache once the installation is
+	// switched to.
+	staged bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -121,6 +126,10 @@ func (c *InstallCommand) SetFlags(f *flag.FlagSet) {
 		"Enable test mode. "+
 			"In test mode, `-nvidia-installer-url` can be used without `-allow-unsigned-driver`.")
 	f.BoolVar(&c.prepareBuildTools, "prepare-build-tools", false, "Whether to populate the build tools cache, i.e. to download and install the toolchain and the kernel headers. Drivers are NOT installed when this flag is set and running with this flag does not require GPU attached to the instance.")
+	f.BoolVar(&c.precompiled, "precompiled", false,
+		"Whether to install prebuilt, pre-signed kernel modules matching the COS kernel instead of linking them on the instance, "+
+			"which skips downloading the toolchain and the kernel headers. "+
+			"The modules are linked on the instance as usual if none are published for the driver version and the COS kernel.")
 
 }
 
@@ -134,6 +143,12 @@ func (c *InstallCommand) validateFlags() error {
 	if c.signatureURL != "" && (c.nvidiaInstallerURL == "" || c.test == false) {
 		return stderrors.New("-signature-url must be used with -nvidia-installer-url and -test")
 	}
+	if c.precompiled && c.nvidiaInstallerURL != "" {
+		return stderrors.New("-precompiled and -nvidia-installer-url are both set; these flags are mutually exclusive")
+	}
+	if c.precompiled && c.prepareBuildTools {
+		return stderrors.New("-precompiled and -prepare-build-tools are both set; these flags are mutually exclusive")
+	}
 	return nil
 }
 
@@ -143,12 +158,52 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 		c.logError(err)
 		return subcommands.ExitFailure
 	}
-	envReader, err := cos.NewEnvReader(hostRootPath)
+	envReader, downloader, err := c.prepare()
 	if err != nil {
-		c.logError(errors.Wrapf(err, "failed to create envReader with host root path %s", hostRootPath))
+		c.logError(err)
 		return subcommands.ExitFailure
 	}
 
+	hostInstallDir := filepath.Join(hostRootPath, c.hostInstallDir)
+	var cacher *installer.Cacher
+	// We only want to cache drivers installed from official sources.
+	if c.nvidiaInstallerURL == "" {
+		cacher = installer.NewCacher(hostInstallDir, envReader.BuildNumber(), c.driverVersion)
+		if isCached, err := cacher.IsCached(); isCached && err == nil {
+			log.V(2).Info("Found cached version, NOT building the drivers.")
+			if err := installer.ConfigureCachedInstalltion(hostInstallDir, !c.unsignedDriver, c.test); err != nil {
+				c.logError(errors.Wrap(err, "failed to configure cached installation"))
+				return subcommands.ExitFailure
+			}
+			if err := installer.VerifyDriverInstallation(); err != nil {
+				c.logError(errors.Wrap(err, "failed to verify GPU driver installation"))
+				return subcommands.ExitFailure
+			}
+			if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
+				c.logError(errors.Wrap(err, "failed to update host ld cache"))
+				return subcommands.ExitFailure
+			}
+			return subcommands.ExitSuccess
+		}
+	}
+
+	log.V(2).Info("Did not find cached version, installing the drivers...")
+	if err := installDriver(c, cacher, envReader, downloader); err != nil {
+		c.logError(err)
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// prepare checks that the drivers can be installed on this instance, and
+// resolves the driver version to install and the host installation directory.
+func (c *InstallCommand) prepare() (*cos.EnvReader, *cos.GCSDownloader, error) {
+	envReader, err := cos.NewEnvReader(hostRootPath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create envReader with host root path %s", hostRootPath)
+	}
+
 	if c.debug {
 		if err := flag.Set("v", "2"); err != nil {
 			log.Errorf("Unable to set debug logging: %v", err)
@@ -159,8 +214,7 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 
 	// All prerelease builds are in dev-channel. For testing we don't need to check release track.
 	if releaseTrack := envReader.ReleaseTrack(); !c.test && releaseTrack == "dev-channel" {
-		c.logError(fmt.Errorf("GPU installation is not supported on dev images for now; Please use LTS image."))
-		return subcommands.ExitFailure
+		return nil, nil, fmt.Errorf("GPU installation is not supported on dev images for now; Please use LTS image.")
 	}
 
 	var gpuType GPUType
@@ -168,13 +222,11 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 	if !c.prepareBuildTools {
 		var isGpuConfigured bool
 		if isGpuConfigured, gpuType, err = c.getGPUTypeInfo(); err != nil {
-			c.logError(errors.Wrapf(err, "failed to get GPU type information"))
-			return subcommands.ExitFailure
+			return nil, nil, errors.Wrapf(err, "failed to get GPU type information")
 		}
 
 		if !isGpuConfigured {
-			c.logError(fmt.Errorf("Please have GPU device configured"))
-			return subcommands.ExitFailure
+			return nil, nil, fmt.Errorf("Please have GPU device configured")
 		}
 	}
 
@@ -183,22 +235,18 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 		versionInput := c.driverVersion
 		milestone, err := strconv.Atoi(envReader.Milestone())
 		if err != nil {
-			c.logError(errors.Wrap(err, "failed to parse milestone number"))
-			return subcommands.ExitFailure
+			return nil, nil, errors.Wrap(err, "failed to parse milestone number")
 		}
 		c.driverVersion, err = getDriverVersion(downloader, c.driverVersion)
 		if err != nil {
 			if versionInput == "latest" && milestone < 93 {
-				c.logError(errors.Wrap(err, "'--version=latest' is only supported on COS M93 and onwards, please unset this flag"))
-				return subcommands.ExitFailure
+				return nil, nil, errors.Wrap(err, "'--version=latest' is only supported on COS M93 and onwards, please unset this flag")
 			} else {
-				c.logError(errors.Wrap(err, "failed to get default driver version"))
-				return subcommands.ExitFailure
+				return nil, nil, errors.Wrap(err, "failed to get default driver version")
 			}
 		}
 		if err := c.checkDriverCompatibility(downloader, gpuType); err != nil {
-			c.logError(errors.Wrap(err, "failed to check driver compatibility"))
-			return subcommands.ExitFailure
+			return nil, nil, errors.Wrap(err, "failed to check driver compatibility")
 		}
 		log.Infof("Installing GPU driver version %s", c.driverVersion)
 	} else {
@@ -219,36 +267,7 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 	if c.hostInstallDir == "" {
 		c.hostInstallDir = os.Getenv("NVIDIA_INSTALL_DIR_HOST")
 	}
-	hostInstallDir := filepath.Join(hostRootPath, c.hostInstallDir)
-	var cacher *installer.Cacher
-	// We only want to cache drivers installed from official sources.
-	if c.nvidiaInstallerURL == "" {
-		cacher = installer.NewCacher(hostInstallDir, envReader.BuildNumber(), c.driverVersion)
-		if isCached, err := cacher.IsCached(); isCached && err == nil {
-			log.V(2).Info("Found cached version, NOT building the drivers.")
-			if err := installer.ConfigureCachedInstalltion(hostInstallDir, !c.unsignedDriver, c.test); err != nil {
-				c.logError(errors.Wrap(err, "failed to configure cached installation"))
-				return subcommands.ExitFailure
-			}
-			if err := installer.VerifyDriverInstallation(); err != nil {
-				c.logError(errors.Wrap(err, "failed to verify GPU driver installation"))
-				return subcommands.ExitFailure
-			}
-			if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
-				c.logError(errors.Wrap(err, "failed to update host ld cache"))
-				return subcommands.ExitFailure
-			}
-			return subcommands.ExitSuccess
-		}
-	}
-
-	log.V(2).Info("Did not find cached version, installing the drivers...")
-	if err := installDriver(c, cacher, envReader, downloader); err != nil {
-		c.logError(err)
-		return subcommands.ExitFailure
-	}
-
-	return subcommands.ExitSuccess
+	return envReader, downloader, nil
 }
 
 func getDriverVersion(downloader *cos.GCSDownloader, argVersion string) (string, error) {
@@ -275,11 +294,21 @@ func remountExecutable(dir string) error {
 }
 
 func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.EnvReader, downloader *cos.GCSDownloader) error {
-	callback, err := installer.ConfigureDriverInstallationDirs(filepath.Join(hostRootPath, c.hostInstallDir), envReader.KernelRelease())
+	cleanup, err := installer.ConfigureDriverInstallationDirs(filepath.Join(hostRootPath, c.hostInstallDir), envReader.KernelRelease())
 	if err != nil {
 		return errors.Wrap(err, "failed to configure GPU driver installation dirs")
 	}
-	defer func() { callback <- 0 }()
+	defer cleanup()
+
+	if c.precompiled {
+		installed, err := installPrecompiledDriver(c, envReader, downloader)
+		if err != nil {
+			return err
+		}
+		if installed {
+			return finishInstallation(c, cacher)
+		}
+	}
 
 	if err := cos.SetCompilationEnv(downloader); err != nil {
 		return errors.Wrap(err, "failed to set compilation environment variables")
@@ -334,6 +363,43 @@ func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.E
 			return errors.Wrap(err, "failed to run GPU driver installer")
 		}
 	}
+	return finishInstallation(c, cacher)
+}
+
+// installPrecompiledDriver installs the prebuilt kernel modules of the driver
+// version if they are published for the COS kernel. Returns false without
+// installing anything if they are not, so the drivers are linked on the
+// instance instead.
+func installPrecompiledDriver(c *InstallCommand, envReader *cos.EnvReader, downloader *cos.GCSDownloader) (bool, error) {
+	modulesDir, err := installer.DownloadPrecompiledModules(downloader, c.driverVersion, envReader.KernelRelease())
+	if errors.Is(err, installer.ErrNoPrecompiledModules) {
+		log.Infof("%v; linking the drivers on this instance instead", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to download precompiled GPU driver modules")
+	}
+	installerFile, err := installer.DownloadDriverInstaller(
+		c.driverVersion, envReader.Milestone(), envReader.BuildNumber())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to download GPU driver installer")
+	}
+	// The signatures hold the public key of the modules and the signature of
+	// the GSP firmware.
+	if !c.unsignedDriver {
+		if err := signing.DownloadDriverSignatures(downloader, c.driverVersion); err != nil {
+			return false, errors.Wrap(err, "failed to download driver signature")
+		}
+	}
+	if err := installer.InstallPrecompiledModules(modulesDir, installerFile, c.driverVersion, !c.unsignedDriver, c.test); err != nil {
+		return false, errors.Wrap(err, "failed to install precompiled GPU driver modules")
+	}
+	return true, nil
+}
+
+// finishInstallation caches and verifies an installation, and makes its
+// libraries available to the host.
+func finishInstallation(c *InstallCommand, cacher *installer.Cacher) error {
 	if cacher != nil {
 		if err := cacher.Cache(); err != nil {
 			return errors.Wrap(err, "failed to cache installation")
@@ -342,8 +408,10 @@ func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.E
 	if err := installer.VerifyDriverInstallation(); err != nil {
 		return errors.Wrap(err, "failed to verify installation")
 	}
-	if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
-		return errors.Wrap(err, "failed to update host ld cache")
+	if !c.staged {
+		if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
+			return errors.Wrap(err, "failed to update host ld cache")
+		}
 	}
 	log.Info("Finished installing the drivers.")
 	return nil
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/installer"
+	"cos.googlesource.com/cos/tools.git/src/pkg/cos"
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"github.com/pkg/errors"
+)
+
+// StatusCommand is the subcommand to report the status of installed GPU
+// drivers.
+type StatusCommand struct {
+	hostInstallDir string
+	format         string
+	debug          bool
+}
+
+// driverStatus is the status reported by the status subcommand.
+type driverStatus struct {
+	*installer.DriverStatus
+	// RunningKernelRelease is the release of the kernel running.
+	RunningKernelRelease string `json:"running_kernel_release"`
+	// LoadedModules are the GPU driver kernel modules loaded.
+	LoadedModules []string `json:"loaded_modules"`
+}
+
+// Name implements subcommands.Command.Name.
+func (*StatusCommand) Name() string { return "status" }
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*StatusCommand) Synopsis() string { return "Report the status of installed GPU drivers." }
+
+// Usage implements subcommands.Command.Usage.
+func (*StatusCommand) Usage() string { return "status [-host-dir <filepath>] [-format text|json]\n" }
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *StatusCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.hostInstallDir, "host-dir", "",
+		"Host directory that GPU drivers were installed to. "+
+			"It tries to read from the env NVIDIA_INSTALL_DIR_HOST if the flag is not set explicitly.")
+	f.StringVar(&c.format, "format", "text",
+		"Output format, either text or json.")
+	f.BoolVar(&c.debug, "debug", false,
+		"Enable debug mode.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *StatusCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.debug {
+		if err := flag.Set("v", "2"); err != nil {
+			log.Errorf("Unable to set debug logging: %v", err)
+		}
+	}
+	if c.format != "text" && c.format != "json" {
+		c.logError(fmt.Errorf("invalid -format %q; please set it to text or json", c.format))
+		return subcommands.ExitUsageError
+	}
+
+	// Read value from env NVIDIA_INSTALL_DIR_HOST if the flag is not set. This is to be compatible with old interface.
+	if c.hostInstallDir == "" {
+		c.hostInstallDir = os.Getenv("NVIDIA_INSTALL_DIR_HOST")
+	}
+	hostInstallDir := filepath.Join(hostRootPath, c.hostInstallDir)
+
+	envReader, err := cos.NewEnvReader(hostRootPath)
+	if err != nil {
+		c.logError(errors.Wrap(err, "failed to create envReader"))
+		return subcommands.ExitFailure
+	}
+	installed, err := installer.GetDriverStatus(hostInstallDir, envReader.BuildNumber())
+	if err != nil {
+		c.logError(errors.Wrap(err, "failed to get GPU driver status"))
+		return subcommands.ExitFailure
+	}
+	loaded, err := modules.LoadedModules()
+	if err != nil {
+		c.logError(errors.Wrap(err, "failed to list loaded kernel modules"))
+		return subcommands.ExitFailure
+	}
+	status := driverStatus{
+		DriverStatus:         installed,
+		RunningKernelRelease: envReader.KernelRelease(),
+		LoadedModules:        []string{},
+	}
+	for _, name := range loaded {
+		if strings.HasPrefix(name, "nvidia") {
+			status.LoadedModules = append(status.LoadedModules, name)
+		}
+	}
+
+	if c.format == "json" {
+		out, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			c.logError(errors.Wrap(err, "failed to encode GPU driver status"))
+			return subcommands.ExitFailure
+		}
+		fmt.Println(string(out))
+		return subcommands.ExitSuccess
+	}
+	fmt.Printf("Installed: %t\n", status.Installed)
+	if status.Installed {
+		fmt.Printf("Driver version: %s\n", status.DriverVersion)
+		fmt.Printf("Installed on build: %s\n", status.BuildNumber)
+		fmt.Printf("Built for kernel: %s\n", status.KernelRelease)
+		fmt.Printf("Signed: %t\n", status.Signed)
+		fmt.Printf("Cache valid: %t\n", status.CacheValid)
+	}
+	fmt.Printf("Running kernel: %s\n", status.RunningKernelRelease)
+	fmt.Printf("Loaded modules: %s\n", strings.Join(status.LoadedModules, " "))
+	return subcommands.ExitSuccess
+}
+
+func (c *StatusCommand) logError(err error) {
+	if c.debug {
+		log.Errorf("%+v", err)
+	} else {
+		log.Errorf("%v", err)
+	}
+}
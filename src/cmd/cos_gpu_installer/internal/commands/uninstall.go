@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flag"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/installer"
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"github.com/pkg/errors"
+)
+
+// UninstallCommand is the subcommand to uninstall GPU drivers.
+type UninstallCommand struct {
+	hostInstallDir string
+	debug          bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*UninstallCommand) Name() string { return "uninstall" }
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*UninstallCommand) Synopsis() string { return "Uninstall GPU drivers." }
+
+// Usage implements subcommands.Command.Usage.
+func (*UninstallCommand) Usage() string { return "uninstall [-host-dir <filepath>]\n" }
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *UninstallCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.hostInstallDir, "host-dir", "",
+		"Host directory that GPU drivers were installed to. "+
+			"It tries to read from the env NVIDIA_INSTALL_DIR_HOST if the flag is not set explicitly.")
+	f.BoolVar(&c.debug, "debug", false,
+		"Enable debug mode.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *UninstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.debug {
+		if err := flag.Set("v", "2"); err != nil {
+			log.Errorf("Unable to set debug logging: %v", err)
+		}
+	}
+
+	// Read value from env NVIDIA_INSTALL_DIR_HOST if the flag is not set. This is to be compatible with old interface.
+	if c.hostInstallDir == "" {
+		c.hostInstallDir = os.Getenv("NVIDIA_INSTALL_DIR_HOST")
+	}
+	// The contents of the directory are removed, so it must not default to the
+	// host root directory.
+	if filepath.Clean("/"+c.hostInstallDir) == "/" {
+		c.logError(stderrors.New("-host-dir is not set; please set it to the directory GPU drivers were installed to"))
+		return subcommands.ExitFailure
+	}
+	hostInstallDir := filepath.Join(hostRootPath, c.hostInstallDir)
+
+	// Modules still in use cannot be unloaded, in which case the installation
+	// is left untouched.
+	clients, err := installer.GPUClients()
+	if err != nil {
+		c.logError(errors.Wrap(err, "failed to check for GPU clients"))
+		return subcommands.ExitFailure
+	}
+	if len(clients) > 0 {
+		c.logError(fmt.Errorf("GPUs are in use by processes %v; please drain GPU workloads before uninstalling", clients))
+		return subcommands.ExitFailure
+	}
+	if err := installer.UnloadGPUDrivers(); err != nil {
+		c.logError(errors.Wrap(err, "failed to unload GPU drivers"))
+		c.reloadInstalled(hostInstallDir)
+		return subcommands.ExitFailure
+	}
+	if err := modules.RevertHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
+		c.logError(errors.Wrap(err, "failed to revert host ld cache"))
+		return subcommands.ExitFailure
+	}
+	if err := installer.NewCacher(hostInstallDir, "", "").Clear(); err != nil {
+		c.logError(errors.Wrap(err, "failed to clear cached installation"))
+		return subcommands.ExitFailure
+	}
+	if err := installer.RemoveInstallation(hostInstallDir); err != nil {
+		c.logError(errors.Wrap(err, "failed to remove GPU driver installation"))
+		return subcommands.ExitFailure
+	}
+	log.Info("Finished uninstalling the drivers.")
+	return subcommands.ExitSuccess
+}
+
+// reloadInstalled loads the drivers of the installation in hostInstallDir
+// again if they were unloaded only partially, ex. because a module came into
+// use after it was checked. The public key of the installation is still in
+// the kernel keyring, so it is not loaded again.
+func (c *UninstallCommand) reloadInstalled(hostInstallDir string) {
+	log.Info("Loading the installed GPU drivers again")
+	if err := installer.ConfigureCachedInstalltion(hostInstallDir, false, false); err != nil {
+		c.logError(errors.Wrap(err, "failed to configure cached installation"))
+	}
+}
+
+func (c *UninstallCommand) logError(err error) {
+	if c.debug {
+		log.Errorf("%+v", err)
+	} else {
+		log.Errorf("%v", err)
+	}
+}
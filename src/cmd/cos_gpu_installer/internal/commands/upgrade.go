@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flag"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/installer"
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"github.com/pkg/errors"
+)
+
+// stagingDirSuffix is appended to the host installation directory to stage
+// the installation of the new GPU driver version.
+const stagingDirSuffix = ".staging"
+
+// UpgradeCommand is the subcommand to upgrade installed GPU drivers.
+type UpgradeCommand struct {
+	install InstallCommand
+}
+
+// Name implements subcommands.Command.Name.
+func (*UpgradeCommand) Name() string { return "upgrade" }
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*UpgradeCommand) Synopsis() string { return "Upgrade installed GPU drivers." }
+
+// Usage implements subcommands.Command.Usage.
+func (*UpgradeCommand) Usage() string { return "upgrade -version <version> [-host-dir <filepath>]\n" }
+
+// SetFlags implements subcommands.Command.SetFlags. It accepts the flags of
+// the install subcommand.
+func (c *UpgradeCommand) SetFlags(f *flag.FlagSet) {
+	c.install.SetFlags(f)
+}
+
+func (c *UpgradeCommand) validateFlags() error {
+	if c.install.driverVersion == "" {
+		return stderrors.New("-version is not set; please set it to the GPU driver version to upgrade to")
+	}
+	if c.install.nvidiaInstallerURL != "" {
+		return stderrors.New("-nvidia-installer-url is not supported by upgrade")
+	}
+	if c.install.prepareBuildTools {
+		return stderrors.New("-prepare-build-tools is not supported by upgrade")
+	}
+	return c.install.validateFlags()
+}
+
+// Execute implements subcommands.Command.Execute.
+//
+// The new driver version is installed to a staging directory next to the
+// host installation directory, which are then atomically exchanged. The
+// installation directory is left untouched if the upgrade fails, and the
+// previous driver version is loaded again.
+func (c *UpgradeCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := c.validateFlags(); err != nil {
+		c.install.logError(err)
+		return subcommands.ExitFailure
+	}
+	envReader, downloader, err := c.install.prepare()
+	if err != nil {
+		c.install.logError(err)
+		return subcommands.ExitFailure
+	}
+	if filepath.Clean("/"+c.install.hostInstallDir) == "/" {
+		c.install.logError(stderrors.New("-host-dir is not set; please set it to the directory GPU drivers were installed to"))
+		return subcommands.ExitFailure
+	}
+	hostInstallDir := filepath.Join(hostRootPath, c.install.hostInstallDir)
+
+	buildNumber, installedVersion, err := installer.NewCacher(hostInstallDir, "", "").Cached()
+	if err != nil || installedVersion == "" {
+		c.install.logError(fmt.Errorf("no GPU driver installation found in %s; please use the install subcommand", c.install.hostInstallDir))
+		return subcommands.ExitFailure
+	}
+	if installedVersion == c.install.driverVersion && buildNumber == envReader.BuildNumber() {
+		log.Infof("GPU driver version %s is already installed.", installedVersion)
+		return subcommands.ExitSuccess
+	}
+	log.Infof("Upgrading GPU driver version %s to %s", installedVersion, c.install.driverVersion)
+
+	clients, err := installer.GPUClients()
+	if err != nil {
+		c.install.logError(errors.Wrap(err, "failed to check for GPU clients"))
+		return subcommands.ExitFailure
+	}
+	if len(clients) > 0 {
+		c.install.logError(fmt.Errorf("GPUs are in use by processes %v; please drain GPU workloads before upgrading", clients))
+		return subcommands.ExitFailure
+	}
+	if err := installer.UnloadGPUDrivers(); err != nil {
+		c.install.logError(errors.Wrap(err, "failed to unload GPU drivers"))
+		return subcommands.ExitFailure
+	}
+
+	staged := c.install
+	staged.hostInstallDir = c.install.hostInstallDir + stagingDirSuffix
+	staged.staged = true
+	stagingDir := filepath.Join(hostRootPath, staged.hostInstallDir)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		c.install.logError(errors.Wrapf(err, "failed to clean staging dir %s", stagingDir))
+		c.reloadInstalled(hostInstallDir)
+		return subcommands.ExitFailure
+	}
+	// installDriver returns once the mounts of the staging directory are
+	// removed, so that it can be switched with the installation directory.
+	cacher := installer.NewCacher(stagingDir, envReader.BuildNumber(), c.install.driverVersion)
+	if err := installDriver(&staged, cacher, envReader, downloader); err != nil {
+		c.install.logError(errors.Wrapf(err, "failed to install GPU driver version %s", c.install.driverVersion))
+		c.reloadInstalled(hostInstallDir)
+		c.removeStaging(stagingDir)
+		return subcommands.ExitFailure
+	}
+	if err := installer.SwitchInstallation(stagingDir, hostInstallDir); err != nil {
+		// The drivers of the staged installation are loaded already.
+		c.install.logError(errors.Wrap(err, "failed to switch to the new installation"))
+		c.reloadInstalled(hostInstallDir)
+		c.removeStaging(stagingDir)
+		return subcommands.ExitFailure
+	}
+	// The ld cache is rebuilt from the new libraries, without listing the
+	// installation directory twice.
+	libDir := filepath.Join(c.install.hostInstallDir, "lib64")
+	if err := modules.RevertHostLdCache(hostRootPath, libDir); err != nil {
+		c.install.logError(errors.Wrap(err, "failed to revert host ld cache"))
+		return subcommands.ExitFailure
+	}
+	if err := modules.UpdateHostLdCache(hostRootPath, libDir); err != nil {
+		c.install.logError(errors.Wrap(err, "failed to update host ld cache"))
+		return subcommands.ExitFailure
+	}
+	log.Infof("Finished upgrading the drivers to version %s.", c.install.driverVersion)
+	return subcommands.ExitSuccess
+}
+
+// reloadInstalled loads the drivers of the installation in hostInstallDir
+// again after a failed upgrade.
+func (c *UpgradeCommand) reloadInstalled(hostInstallDir string) {
+	log.Info("Loading the previously installed GPU drivers again")
+	if err := installer.UnloadGPUDrivers(); err != nil {
+		c.install.logError(errors.Wrap(err, "failed to unload GPU drivers"))
+		return
+	}
+	if err := installer.ConfigureCachedInstalltion(hostInstallDir, !c.install.unsignedDriver, c.install.test); err != nil {
+		c.install.logError(errors.Wrap(err, "failed to configure cached installation"))
+	}
+}
+
+// removeStaging removes the staging directory of a failed upgrade.
+func (c *UpgradeCommand) removeStaging(stagingDir string) {
+	if err := os.RemoveAll(stagingDir); err != nil {
+		c.install.logError(errors.Wrapf(err, "failed to remove staging dir %s", stagingDir))
+	}
+}
@@ -74,3 +74,23 @@ func (c *Cacher) IsCached() (bool, error) {
 	return (c.buildNumber == cacheMap[buildNumberKey] &&
 		c.driverVersion == cacheMap[driverVersionKey]), nil
 }
+
+// Clear removes the information that a GPU driver has been installed, if any.
+func (c *Cacher) Clear() error {
+	cachePath := filepath.Join(c.gpuInstallDir, cacheFile)
+	if err := os.Remove(cachePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Failed to remove file %s", cachePath)
+	}
+	log.Info("Cleared cached version")
+	return nil
+}
+
+// Cached returns the COS build number and the GPU driver version of the
+// installation cached in the GPU driver installation directory.
+func (c *Cacher) Cached() (string, string, error) {
+	cacheMap, err := utils.LoadEnvFromFile(c.gpuInstallDir, cacheFile)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "Failed to read file %s", filepath.Join(c.gpuInstallDir, cacheFile))
+	}
+	return cacheMap[buildNumberKey], cacheMap[driverVersionKey], nil
+}
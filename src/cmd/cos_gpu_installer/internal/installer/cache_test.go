@@ -40,3 +40,25 @@ func TestIsCached(t *testing.T) {
 		})
 	}
 }
+
+func TestClear(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	cacher := NewCacher(testDir, "12688.0.0", "418.67")
+	if err := cacher.Cache(); err != nil {
+		t.Fatalf("Failed to cache: %v", err)
+	}
+	// Clearing twice succeeds, since nothing is cached the second time.
+	for i := 0; i < 2; i++ {
+		if err := cacher.Clear(); err != nil {
+			t.Fatalf("Failed to clear cache: %v", err)
+		}
+	}
+	if out, _ := cacher.IsCached(); out {
+		t.Errorf("Unexpected cache result after clearing: want: false, got: %v", out)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -42,6 +43,9 @@ var (
 	ErrDriverLoad = stderrors.New("failed to load GPU drivers")
 
 	errInstallerFailed = stderrors.New("failed to run GPU driver installer")
+
+	sysModuleDir = "/sys/module"
+	unloadModule = modules.UnloadModule
 )
 
 // VerifyDriverInstallation runs some commands to verify the driver installation.
@@ -102,7 +106,8 @@ func DownloadDriverInstaller(driverVersion, cosMilestone, cosBuildNumber string)
 }
 
 // ConfigureDriverInstallationDirs configures GPU driver installation directories by creating mounts.
-func ConfigureDriverInstallationDirs(gpuInstallDirHost string, kernelRelease string) (chan<- int, error) {
+// The returned function removes the mounts, and returns once they are removed.
+func ConfigureDriverInstallationDirs(gpuInstallDirHost string, kernelRelease string) (func(), error) {
 	log.Info("Configuring driver installation directories")
 
 	if err := createHostDirBindMount(gpuInstallDirHost, gpuInstallDirContainer); err != nil {
@@ -127,16 +132,13 @@ func ConfigureDriverInstallationDirs(gpuInstallDirHost string, kernelRelease str
 		return nil, errors.Wrap(err, "failed to update container ld cache")
 	}
 
-	ch := make(chan int, 1)
-	go func() {
+	return func() {
 		// cleans up mounts created above.
-		<-ch
 		syscall.Unmount("/usr/bin", 0)
 		syscall.Unmount("/usr/lib/x86_64-linux-gnu", 0)
 		syscall.Unmount(modulePath, 0)
 		syscall.Unmount(gpuInstallDirContainer, 0)
-	}()
-	return ch, nil
+	}, nil
 }
 
 func extractPrecompiled(nvidiaDir string) error {
@@ -297,20 +299,30 @@ func installUserLibs(nvidiaDir string) error {
 	return nil
 }
 
-// RunDriverInstaller runs GPU driver installer. Only works if the provided
-// installer includes precompiled drivers.
-func RunDriverInstaller(toolchainDir, installerFilename, driverVersion string, needSigned, test, legacyLink bool) error {
-	log.Info("Running GPU driver installer")
-
+// extractInstaller extracts the files of the GPU driver installer, and returns
+// the directory they were extracted to.
+func extractInstaller(installerFilename string) (string, error) {
 	// Extract files to a fixed path first to make sure md5sum of generated gpu drivers are consistent.
 	extractDir := "/tmp/extract"
 	if err := os.RemoveAll(extractDir); err != nil {
-		return fmt.Errorf("failed to clean %q: %v", extractDir, err)
+		return "", fmt.Errorf("failed to clean %q: %v", extractDir, err)
 	}
 	cmd := exec.Command("sh", installerFilename, "-x", "--target", extractDir)
 	cmd.Dir = gpuInstallDirContainer
 	if err := cmd.Run(); err != nil {
-		return errors.Wrap(err, "failed to extract installer files")
+		return "", errors.Wrap(err, "failed to extract installer files")
+	}
+	return extractDir, nil
+}
+
+// RunDriverInstaller runs GPU driver installer. Only works if the provided
+// installer includes precompiled drivers.
+func RunDriverInstaller(toolchainDir, installerFilename, driverVersion string, needSigned, test, legacyLink bool) error {
+	log.Info("Running GPU driver installer")
+
+	extractDir, err := extractInstaller(installerFilename)
+	if err != nil {
+		return err
 	}
 
 	// Extract precompiled artifacts.
@@ -551,6 +563,90 @@ func loadGPUDrivers(needSigned, test bool) error {
 	return nil
 }
 
+// gpuModulesToUnload lists the GPU driver kernel modules in the order they
+// need to be unloaded due to module dependency.
+var gpuModulesToUnload = []string{"nvidia_drm", "nvidia_modeset", "nvidia_uvm", "nvidia"}
+
+// UnloadGPUDrivers unloads the GPU driver kernel modules that are loaded.
+// Unloading fails without unloading any module if a module is still in use,
+// ex. by a process holding a GPU open or by another kernel module.
+func UnloadGPUDrivers() error {
+	log.Info("Unloading GPU drivers")
+	if err := checkGPUModulesUnused(); err != nil {
+		return err
+	}
+	for _, moduleName := range gpuModulesToUnload {
+		if err := unloadModule(moduleName); err != nil {
+			return errors.Wrapf(err, "failed to unload module %s", moduleName)
+		}
+	}
+	return nil
+}
+
+// checkGPUModulesUnused returns an error if a loaded GPU driver kernel module
+// is referenced by anything but the other GPU driver kernel modules, which are
+// unloaded before it.
+func checkGPUModulesUnused() error {
+	gpuModules := make(map[string]bool)
+	for _, moduleName := range gpuModulesToUnload {
+		gpuModules[moduleName] = true
+	}
+	for _, moduleName := range gpuModulesToUnload {
+		moduleDir := filepath.Join(sysModuleDir, moduleName)
+		content, err := ioutil.ReadFile(filepath.Join(moduleDir, "refcnt"))
+		if os.IsNotExist(err) {
+			// The module is not loaded.
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read reference count of module %s", moduleName)
+		}
+		refcnt, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse reference count of module %s", moduleName)
+		}
+		holders, err := ioutil.ReadDir(filepath.Join(moduleDir, "holders"))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to list holders of module %s", moduleName)
+		}
+		var others []string
+		for _, holder := range holders {
+			if gpuModules[holder.Name()] {
+				refcnt--
+			} else {
+				others = append(others, holder.Name())
+			}
+		}
+		if len(others) > 0 {
+			return fmt.Errorf("module %s is in use by modules %v", moduleName, others)
+		}
+		if refcnt > 0 {
+			return fmt.Errorf("module %s is in use", moduleName)
+		}
+	}
+	return nil
+}
+
+// RemoveInstallation removes the contents of the GPU driver installation
+// directory on host, keeping the directory itself.
+func RemoveInstallation(gpuInstallDirHost string) error {
+	log.Infof("Removing GPU driver installation from %s", gpuInstallDirHost)
+	files, err := ioutil.ReadDir(gpuInstallDirHost)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to list files in directory %s", gpuInstallDirHost)
+	}
+	for _, file := range files {
+		filePath := filepath.Join(gpuInstallDirHost, file.Name())
+		if err := os.RemoveAll(filePath); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", filePath)
+		}
+	}
+	return nil
+}
+
 func prepareGSPFirmware(extractDir, driverVersion string, needSigned bool) error {
 	signaturePath := signing.GetModuleSignature(gspFileName)
 	installerGSPPath := filepath.Join(extractDir, "firmware", gspFileName)
@@ -1,7 +1,14 @@
 package installer
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 func TestGetInstallerDownloadLocation(t *testing.T) {
@@ -45,3 +52,106 @@ func TestGetPrecompiledInstallerURL(t *testing.T) {
 		t.Errorf("Unexpected return, want: %s, got: %s", expectedRet, ret)
 	}
 }
+
+func TestUnloadGPUDrivers(t *testing.T) {
+	// Reference counts and holders of the loaded modules, as in a system where
+	// nvidia_drm and nvidia_modeset hold nvidia, and nvidia_drm holds
+	// nvidia_modeset.
+	type module struct {
+		refcnt  int
+		holders []string
+	}
+	unused := map[string]module{
+		"nvidia_drm":     {},
+		"nvidia_modeset": {refcnt: 1, holders: []string{"nvidia_drm"}},
+		"nvidia_uvm":     {},
+		"nvidia":         {refcnt: 2, holders: []string{"nvidia_drm", "nvidia_modeset"}},
+	}
+	for _, tc := range []struct {
+		testName         string
+		modules          map[string]module
+		failingModule    string
+		expectedUnloaded []string
+		expectedErr      bool
+	}{
+		{
+			testName:         "Unused",
+			modules:          unused,
+			expectedUnloaded: []string{"nvidia_drm", "nvidia_modeset", "nvidia_uvm", "nvidia"},
+		},
+		{
+			testName:         "NotLoaded",
+			modules:          map[string]module{},
+			expectedUnloaded: []string{"nvidia_drm", "nvidia_modeset", "nvidia_uvm", "nvidia"},
+		},
+		{
+			testName: "NvidiaInUse",
+			modules: map[string]module{
+				"nvidia_drm":     {},
+				"nvidia_modeset": {refcnt: 1, holders: []string{"nvidia_drm"}},
+				"nvidia_uvm":     {},
+				"nvidia":         {refcnt: 3, holders: []string{"nvidia_drm", "nvidia_modeset"}},
+			},
+			expectedErr: true,
+		},
+		{
+			testName: "HeldByOtherModule",
+			modules: map[string]module{
+				"nvidia_drm":     {refcnt: 1, holders: []string{"nvidia_peermem"}},
+				"nvidia_modeset": {refcnt: 1, holders: []string{"nvidia_drm"}},
+				"nvidia_uvm":     {},
+				"nvidia":         {refcnt: 2, holders: []string{"nvidia_drm", "nvidia_modeset"}},
+			},
+			expectedErr: true,
+		},
+		{
+			testName:         "UnloadFails",
+			modules:          unused,
+			failingModule:    "nvidia_uvm",
+			expectedUnloaded: []string{"nvidia_drm", "nvidia_modeset"},
+			expectedErr:      true,
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			testDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(testDir)
+			origSysModuleDir, origUnloadModule := sysModuleDir, unloadModule
+			defer func() { sysModuleDir, unloadModule = origSysModuleDir, origUnloadModule }()
+			sysModuleDir = testDir
+			var unloaded []string
+			unloadModule = func(moduleName string) error {
+				if moduleName == tc.failingModule {
+					return errors.New("rmmod failed")
+				}
+				unloaded = append(unloaded, moduleName)
+				return nil
+			}
+
+			for name, m := range tc.modules {
+				holdersDir := filepath.Join(testDir, name, "holders")
+				if err := os.MkdirAll(holdersDir, 0755); err != nil {
+					t.Fatalf("Failed to create dir %s: %v", holdersDir, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(testDir, name, "refcnt"), []byte(strconv.Itoa(m.refcnt)+"\n"), 0644); err != nil {
+					t.Fatalf("Failed to write refcnt of %s: %v", name, err)
+				}
+				for _, holder := range m.holders {
+					if err := os.Symlink(filepath.Join("..", "..", holder), filepath.Join(holdersDir, holder)); err != nil {
+						t.Fatalf("Failed to create holder symlink: %v", err)
+					}
+				}
+			}
+
+			err = UnloadGPUDrivers()
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("Unexpected error, want error: %v, got: %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(unloaded, tc.expectedUnloaded) {
+				t.Errorf("Unexpected unloaded modules, want: %v, got: %v", tc.expectedUnloaded, unloaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package installer
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/signing"
+	"cos.googlesource.com/cos/tools.git/src/pkg/cos"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	precompiledModulesSuffix = ".modules.tar.gz"
+	kernelReleaseFile        = "kernel_release"
+)
+
+var (
+	// ErrNoPrecompiledModules indicates that no precompiled GPU driver kernel
+	// modules are published for a driver version and COS kernel.
+	ErrNoPrecompiledModules = stderrors.New("no precompiled GPU driver modules")
+
+	precompiledModulesDir = "/tmp/precompiled"
+)
+
+// DownloadPrecompiledModules downloads the prebuilt kernel modules of a GPU
+// driver version published as a GPU extension artifact of the COS build,
+// ex. "535.129.03.modules.tar.gz". The modules are linked and signed in
+// advance for a single kernel, recorded in the kernel_release file of the
+// archive, so they must match kernelRelease.
+//
+// Returns the directory the modules were extracted to, or an error wrapping
+// ErrNoPrecompiledModules if no modules were published for the driver version
+// and kernel.
+func DownloadPrecompiledModules(downloader cos.ExtensionsDownloader, driverVersion, kernelRelease string) (string, error) {
+	archive := driverVersion + precompiledModulesSuffix
+	artifacts, err := downloader.ListExtensionArtifacts(cos.GPUExtension)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list GPU extension artifacts")
+	}
+	published := false
+	for _, artifact := range artifacts {
+		if artifact == archive {
+			published = true
+			break
+		}
+	}
+	if !published {
+		return "", fmt.Errorf("%w: %s is not published", ErrNoPrecompiledModules, archive)
+	}
+
+	log.Infof("Downloading precompiled GPU driver modules for version %s", driverVersion)
+	if err := os.RemoveAll(precompiledModulesDir); err != nil {
+		return "", fmt.Errorf("failed to clean %q: %v", precompiledModulesDir, err)
+	}
+	if err := os.MkdirAll(precompiledModulesDir, defaultFilePermission); err != nil {
+		return "", errors.Wrapf(err, "failed to create dir %s", precompiledModulesDir)
+	}
+	if err := downloader.DownloadExtensionArtifact(precompiledModulesDir, cos.GPUExtension, archive); err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", archive)
+	}
+	archivePath := filepath.Join(precompiledModulesDir, archive)
+	if err := exec.Command("tar", "xf", archivePath, "-C", precompiledModulesDir).Run(); err != nil {
+		return "", errors.Wrapf(err, "failed to extract %s", archivePath)
+	}
+
+	builtFor, err := ioutil.ReadFile(filepath.Join(precompiledModulesDir, kernelReleaseFile))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the kernel release of %s", archive)
+	}
+	if release := strings.TrimSpace(string(builtFor)); release != kernelRelease {
+		return "", fmt.Errorf("%w: %s was built for kernel %s, not %s", ErrNoPrecompiledModules, archive, release, kernelRelease)
+	}
+	return precompiledModulesDir, nil
+}
+
+// InstallPrecompiledModules installs and loads the kernel modules downloaded
+// by DownloadPrecompiledModules to modulesDir, then installs the userspace
+// libraries and GSP firmware of the driver installer. The modules are already
+// signed, so only the public key of the driver signatures is installed if
+// needSigned is set.
+func InstallPrecompiledModules(modulesDir, installerFilename, driverVersion string, needSigned, test bool) error {
+	log.Info("Installing precompiled GPU driver modules")
+	moduleFiles, err := ioutil.ReadDir(modulesDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list files in directory %s", modulesDir)
+	}
+	for _, moduleFile := range moduleFiles {
+		if strings.HasSuffix(moduleFile.Name(), ".ko") {
+			module := moduleFile.Name()
+			src := filepath.Join(modulesDir, module)
+			dst := filepath.Join(gpuInstallDirContainer, "drivers", module)
+			if err := utils.CopyFile(src, dst); err != nil {
+				return fmt.Errorf("failed to copy kernel module %q: %v", module, err)
+			}
+		}
+	}
+	if needSigned {
+		if err := utils.CopyFile(signing.GetPublicKeyDer(), filepath.Join(gpuInstallDirContainer, "pubkey.der")); err != nil {
+			return errors.Wrapf(err, "failed to copy file %s", signing.GetPublicKeyDer())
+		}
+	}
+	if err := loadGPUDrivers(needSigned, test); err != nil {
+		return fmt.Errorf("%w: %v", ErrDriverLoad, err)
+	}
+
+	extractDir, err := extractInstaller(installerFilename)
+	if err != nil {
+		return err
+	}
+	if err := installUserLibs(extractDir); err != nil {
+		return fmt.Errorf("failed to install userspace libraries: %v", err)
+	}
+	if err := prepareGSPFirmware(extractDir, driverVersion, needSigned); err != nil {
+		return fmt.Errorf("failed to prepare GSP firmware, err: %v", err)
+	}
+	log.Info("Done installing precompiled GPU driver modules")
+	return nil
+}
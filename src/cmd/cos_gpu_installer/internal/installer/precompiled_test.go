@@ -0,0 +1,100 @@
+package installer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+func TestDownloadPrecompiledModules(t *testing.T) {
+	for _, tc := range []struct {
+		testName      string
+		artifacts     map[string]map[string][]byte
+		expectedError error
+	}{
+		{
+			"published",
+			map[string]map[string][]byte{
+				"535.129.03.modules.tar.gz": {
+					"kernel_release": []byte("5.15.133+\n"),
+					"nvidia.ko":      []byte("nvidia"),
+				},
+			},
+			nil,
+		},
+		{
+			"not published",
+			map[string]map[string][]byte{
+				"525.125.06.modules.tar.gz": {
+					"kernel_release": []byte("5.15.133+\n"),
+				},
+			},
+			ErrNoPrecompiledModules,
+		},
+		{
+			"other kernel",
+			map[string]map[string][]byte{
+				"535.129.03.modules.tar.gz": {
+					"kernel_release": []byte("5.15.120+\n"),
+				},
+			},
+			ErrNoPrecompiledModules,
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			origPrecompiledModulesDir := precompiledModulesDir
+			precompiledModulesDir = tmpDir
+			defer func() { precompiledModulesDir = origPrecompiledModulesDir }()
+
+			downloader := &fakeDownloader{artifacts: tc.artifacts}
+			modulesDir, err := DownloadPrecompiledModules(downloader, "535.129.03", "5.15.133+")
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Fatalf("Unexpected error, want: %v, got: %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to run DownloadPrecompiledModules: %v", err)
+			}
+			content, err := ioutil.ReadFile(filepath.Join(modulesDir, "nvidia.ko"))
+			if err != nil {
+				t.Fatalf("Failed to read nvidia.ko: %v", err)
+			}
+			if string(content) != "nvidia" {
+				t.Errorf("Unexpected content of nvidia.ko, want: nvidia, got: %s", content)
+			}
+		})
+	}
+}
+
+type fakeDownloader struct {
+	artifacts map[string]map[string][]byte
+}
+
+func (*fakeDownloader) ListExtensions() ([]string, error) { return nil, nil }
+
+func (f *fakeDownloader) ListExtensionArtifacts(extension string) ([]string, error) {
+	var artifacts []string
+	for artifact := range f.artifacts {
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+func (*fakeDownloader) GetExtensionArtifact(extension, artifact string) ([]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeDownloader) DownloadExtensionArtifact(destDir, extension, artifact string) error {
+	return utils.CreateTarFile(filepath.Join(destDir, artifact), f.artifacts[artifact])
+}
@@ -0,0 +1,70 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+
+	"github.com/pkg/errors"
+)
+
+// DriverStatus describes the GPU driver installed in a GPU driver
+// installation directory.
+type DriverStatus struct {
+	// Installed is set if a GPU driver installation is cached in the
+	// directory.
+	Installed bool `json:"installed"`
+	// DriverVersion is the installed GPU driver version, ex. "535.129.03".
+	DriverVersion string `json:"driver_version,omitempty"`
+	// BuildNumber is the COS build the driver was installed on, ex.
+	// "16919.29.16".
+	BuildNumber string `json:"build_number,omitempty"`
+	// KernelRelease is the release of the kernel the kernel modules were
+	// built for, ex. "5.15.133+".
+	KernelRelease string `json:"kernel_release,omitempty"`
+	// Signed is set if a signature is appended to the kernel modules.
+	Signed bool `json:"signed"`
+	// CacheValid is set if the cached installation is used as is by the
+	// install subcommand on this COS build, without installing the driver
+	// again.
+	CacheValid bool `json:"cache_valid"`
+}
+
+// GetDriverStatus returns the status of the GPU driver installed in
+// gpuInstallDirHost. The installation cache is valid if it was written on
+// buildNumber, the COS build running.
+func GetDriverStatus(gpuInstallDirHost, buildNumber string) (*DriverStatus, error) {
+	status := &DriverStatus{}
+	cacher := NewCacher(gpuInstallDirHost, buildNumber, "")
+	cachedBuild, driverVersion, err := cacher.Cached()
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrap(err, "failed to read the cached installation")
+	}
+	if err != nil || driverVersion == "" {
+		return status, nil
+	}
+	status.Installed = true
+	status.DriverVersion = driverVersion
+	status.BuildNumber = cachedBuild
+	status.CacheValid = cachedBuild == buildNumber
+
+	modulePath := filepath.Join(gpuInstallDirHost, "drivers", "nvidia.ko")
+	if _, err := os.Stat(modulePath); os.IsNotExist(err) {
+		// The cache is no longer valid if its kernel modules were removed.
+		status.CacheValid = false
+		return status, nil
+	}
+	vermagic, err := modules.ModuleVermagic(modulePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the kernel release of the GPU driver")
+	}
+	if fields := strings.Fields(vermagic); len(fields) > 0 {
+		status.KernelRelease = fields[0]
+	}
+	if status.Signed, err = modules.IsModuleSigned(modulePath); err != nil {
+		return nil, errors.Wrap(err, "failed to get the signing state of the GPU driver")
+	}
+	return status, nil
+}
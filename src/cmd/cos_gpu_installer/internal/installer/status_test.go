@@ -0,0 +1,83 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetDriverStatus(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	status, err := GetDriverStatus(testDir, "12688.0.0")
+	if err != nil {
+		t.Fatalf("Failed to get driver status: %v", err)
+	}
+	if !reflect.DeepEqual(status, &DriverStatus{}) {
+		t.Errorf("Unexpected status without installation: %+v", status)
+	}
+
+	if err := NewCacher(testDir, "12688.0.0", "418.67").Cache(); err != nil {
+		t.Fatalf("Failed to cache: %v", err)
+	}
+	driversDir := filepath.Join(testDir, "drivers")
+	if err := os.MkdirAll(driversDir, 0755); err != nil {
+		t.Fatalf("Failed to create dir %s: %v", driversDir, err)
+	}
+	module := "\x7fELF\x00vermagic=5.4.129+ SMP mod_unload \x00"
+	if err := ioutil.WriteFile(filepath.Join(driversDir, "nvidia.ko"), []byte(module), 0644); err != nil {
+		t.Fatalf("Failed to write nvidia.ko: %v", err)
+	}
+
+	for _, tc := range []struct {
+		testName    string
+		buildNumber string
+		expectOut   *DriverStatus
+	}{
+		{"TestStatusCacheValid", "12688.0.0", &DriverStatus{
+			Installed:     true,
+			DriverVersion: "418.67",
+			BuildNumber:   "12688.0.0",
+			KernelRelease: "5.4.129+",
+			CacheValid:    true,
+		}},
+		{"TestStatusWrongBuild", "12670.0.0", &DriverStatus{
+			Installed:     true,
+			DriverVersion: "418.67",
+			BuildNumber:   "12688.0.0",
+			KernelRelease: "5.4.129+",
+		}},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			status, err := GetDriverStatus(testDir, tc.buildNumber)
+			if err != nil {
+				t.Fatalf("Failed to get driver status: %v", err)
+			}
+			if !reflect.DeepEqual(status, tc.expectOut) {
+				t.Errorf("Unexpected status: want: %+v, got: %+v", tc.expectOut, status)
+			}
+		})
+	}
+}
+
+func TestGetDriverStatusReadError(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	// The cache cannot be read if it is a directory.
+	if err := os.Mkdir(filepath.Join(testDir, cacheFile), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if status, err := GetDriverStatus(testDir, "12688.0.0"); err == nil {
+		t.Errorf("Unexpected status with an unreadable cache: %+v", status)
+	}
+}
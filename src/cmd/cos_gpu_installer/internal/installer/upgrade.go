@@ -0,0 +1,64 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+var procDir = "/proc"
+
+// GPUClients returns the IDs of the processes holding a GPU device file, ex.
+// /dev/nvidia0 or /dev/nvidiactl, open, in increasing order. The installer
+// must run in the host PID namespace to see the processes of the host.
+func GPUClients() ([]int, error) {
+	fdDirs, err := filepath.Glob(filepath.Join(procDir, "[0-9]*", "fd"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list processes in %s", procDir)
+	}
+	self := os.Getpid()
+	var pids []int
+	for _, fdDir := range fdDirs {
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(fdDir)))
+		if err != nil || pid == self {
+			continue
+		}
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// The process exited or cannot be inspected.
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && strings.HasPrefix(target, "/dev/nvidia") {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// SwitchInstallation atomically exchanges the GPU driver installation staged
+// in stagingDirHost with the installation in gpuInstallDirHost, then removes
+// the previous installation. Both directories must be on the same filesystem.
+func SwitchInstallation(stagingDirHost, gpuInstallDirHost string) error {
+	log.Infof("Switching GPU driver installation %s to %s", gpuInstallDirHost, stagingDirHost)
+	if err := os.MkdirAll(gpuInstallDirHost, defaultFilePermission); err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", gpuInstallDirHost)
+	}
+	if err := unix.Renameat2(unix.AT_FDCWD, stagingDirHost, unix.AT_FDCWD, gpuInstallDirHost, unix.RENAME_EXCHANGE); err != nil {
+		return errors.Wrapf(err, "failed to exchange %s with %s", stagingDirHost, gpuInstallDirHost)
+	}
+	if err := os.RemoveAll(stagingDirHost); err != nil {
+		return errors.Wrapf(err, "failed to remove previous installation %s", stagingDirHost)
+	}
+	return nil
+}
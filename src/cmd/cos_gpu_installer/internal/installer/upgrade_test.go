@@ -0,0 +1,78 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGPUClients(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	origProcDir := procDir
+	procDir = testDir
+	defer func() { procDir = origProcDir }()
+
+	for pid, fds := range map[string][]string{
+		"12":   {"/dev/null", "/dev/nvidia0"},
+		"345":  {"/dev/nvidiactl"},
+		"6789": {"/dev/null", "socket:[1234]"},
+		"self": {"/dev/nvidia0"},
+	} {
+		fdDir := filepath.Join(testDir, pid, "fd")
+		if err := os.MkdirAll(fdDir, 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", fdDir, err)
+		}
+		for i, target := range fds {
+			if err := os.Symlink(target, filepath.Join(fdDir, strconv.Itoa(i))); err != nil {
+				t.Fatalf("Failed to create fd symlink: %v", err)
+			}
+		}
+	}
+
+	clients, err := GPUClients()
+	if err != nil {
+		t.Fatalf("Failed to run GPUClients: %v", err)
+	}
+	if expected := []int{12, 345}; !reflect.DeepEqual(clients, expected) {
+		t.Errorf("Unexpected GPU clients, want: %v, got: %v", expected, clients)
+	}
+}
+
+func TestSwitchInstallation(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	installDir := filepath.Join(testDir, "nvidia")
+	stagingDir := filepath.Join(testDir, "nvidia.staging")
+	for dir, version := range map[string]string{installDir: "418.67", stagingDir: "450.51.06"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", dir, err)
+		}
+		if err := NewCacher(dir, "12688.0.0", version).Cache(); err != nil {
+			t.Fatalf("Failed to cache: %v", err)
+		}
+	}
+
+	if err := SwitchInstallation(stagingDir, installDir); err != nil {
+		t.Fatalf("Failed to run SwitchInstallation: %v", err)
+	}
+	_, version, err := NewCacher(installDir, "", "").Cached()
+	if err != nil {
+		t.Fatalf("Failed to read cache: %v", err)
+	}
+	if version != "450.51.06" {
+		t.Errorf("Unexpected installed version, want: 450.51.06, got: %s", version)
+	}
+	if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+		t.Errorf("Expected staging dir %s to be removed, got: %v", stagingDir, err)
+	}
+}
@@ -27,6 +27,9 @@ func main() {
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(&commands.InstallCommand{}, "")
 	subcommands.Register(&commands.ListCommand{}, "")
+	subcommands.Register(&commands.UninstallCommand{}, "")
+	subcommands.Register(&commands.UpgradeCommand{}, "")
+	subcommands.Register(&commands.StatusCommand{}, "")
 
 	ctx := context.Background()
 	os.Exit(int(subcommands.Execute(ctx)))
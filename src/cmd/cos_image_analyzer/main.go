@@ -9,112 +9,103 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/binary"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/output"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/packagediff"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/remote"
 )
 
-func cosImageAnalyzer(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
-	imageDiff := &output.ImageDiff{}
-
-	err := *new(error)
-	if err := binary.GetBinaryInfo(image1, flagInfo); err != nil {
-		return fmt.Errorf("failed to get GetBinaryInfo from image %v: %v", flagInfo.Image1, err)
-	}
-	if err := binary.GetBinaryInfo(image2, flagInfo); err != nil {
-		return fmt.Errorf("failed to GetBinaryInfo from image %v: %v", flagInfo.Image2, err)
-	}
-	if err := image1.Rename(flagInfo); err != nil {
-		return fmt.Errorf("failed to rename image %v: %v", flagInfo.Image1, err)
+// analyzeRemotely runs the analysis on a GCE VM and prints its output
+func analyzeRemotely(ctx context.Context, flagInfo *input.FlagInfo) error {
+	exitCode, err := remote.Run(ctx, &remote.Config{
+		ProjectID:   flagInfo.ProjectIDPtr,
+		Zone:        flagInfo.RemoteZone,
+		MachineType: flagInfo.RemoteMachineType,
+		VM:          flagInfo.RemoteVM,
+		Binary:      flagInfo.RemoteBinary,
+		Args:        flagInfo.RemoteArgs,
+	}, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("failed to run the analysis remotely: %v", err)
 	}
-	if err := image2.Rename(flagInfo); err != nil {
-		return fmt.Errorf("failed to rename image %v: %v", flagInfo.Image2, err)
+	if exitCode != 0 {
+		return fmt.Errorf("the analysis on the VM exited with code %d", exitCode)
 	}
+	return nil
+}
 
-	binaryDiff, err := binary.Diff(image1, image2, flagInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get Binary Difference: %v", err)
+// analyze analyzes the images with the imageanalyzer library, outputs the
+// differences based on the "-output" flag and exports them to BigQuery if requested
+func analyze(ctx context.Context, flagInfo *input.FlagInfo) error {
+	if flagInfo.Remote {
+		return analyzeRemotely(ctx, flagInfo)
+	}
+	var bigQueryTable *output.BigQueryTable
+	if flagInfo.BigQueryTablePtr != "" {
+		table, err := output.ParseBigQueryTable(flagInfo.BigQueryTablePtr)
+		if err != nil {
+			return err
+		}
+		bigQueryTable = table
 	}
-	imageDiff.BinaryDiff = binaryDiff
 
-	packageList1, err := packagediff.GetPackageInfo(image1, flagInfo)
+	diff, err := imageanalyzer.AnalyzeWithFlags(ctx, flagInfo)
 	if err != nil {
-		return fmt.Errorf("failed to get package info from image %v: %v", flagInfo.Image1, err)
-	}
-	packageList2, err := packagediff.GetPackageInfo(image2, flagInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get package info from image %v: %v", flagInfo.Image2, err)
-	}
-	packageDiff, err := packagediff.Diff(packageList1, packageList2, flagInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get package difference: %v", err)
+		return err
 	}
-	imageDiff.PackageDiff = packageDiff
 
-	output, err := imageDiff.Formater(image1.TempDir, image2.TempDir, flagInfo)
+	output, err := diff.Formater(diff.Image1.TempDir, diff.Image2.TempDir, flagInfo)
 	if err != nil {
 		return fmt.Errorf("failed to format image difference: %v", err)
 	}
 	if flagInfo.OutputSelected == "terminal" {
-		imageDiff.Print(output)
+		diff.Print(output)
 	} else {
 		fmt.Print(output)
 	}
-	return nil
-}
-
-// CallCosImageAnalyzer is wrapper that gets the images, calls cosImageAnalyzer, and cleans up
-func CallCosImageAnalyzer(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
-	if err := image1.MountImage(flagInfo.BinaryTypesSelected); err != nil {
-		return fmt.Errorf("failed to mount first image %v: %v", flagInfo.Image1, err)
-	}
-	if err := image2.MountImage(flagInfo.BinaryTypesSelected); err != nil {
-		return fmt.Errorf("failed to mount second image %v: %v", flagInfo.Image2, err)
-	}
-	if err := cosImageAnalyzer(image1, image2, flagInfo); err != nil {
-		return fmt.Errorf("failed to call cosImageAnalyzer: %v", err)
-	}
-	return nil
-}
 
-func analyze(flagInfo *input.FlagInfo) error {
-	var image1, image2 *input.ImageInfo
-	defer func() {
-		if err := image1.Cleanup(); err != nil {
-			log.Printf("failed to clean up image %v: %v", flagInfo.Image1, err)
+	if bigQueryTable != nil {
+		if err := diff.ExportToBigQuery(ctx, diff.Image1, diff.Image2, bigQueryTable); err != nil {
+			return fmt.Errorf("failed to export image difference to BigQuery: %v", err)
 		}
-		if err := image2.Cleanup(); err != nil {
-			log.Printf("failed to clean up image %v: %v", flagInfo.Image2, err)
-		}
-	}()
-	var err error
-	image1, image2, err = input.GetImages(flagInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get images: %v", err)
-	}
-	if err := CallCosImageAnalyzer(image1, image2, flagInfo); err != nil {
-		return err
 	}
 	return nil
 }
 
 func main() {
-	if runtime.GOOS != "linux" {
-		fmt.Printf("Error: This is a Linux tool, can not run on %s", runtime.GOOS)
-	}
 	flagInfo, err := input.ParseFlags()
 	if err != nil {
 		log.Printf("failed to parse flags: %v\n", err)
 		os.Exit(1)
 	}
-	if err := analyze(flagInfo); err != nil {
-		log.Printf("%v\n", err)
+	if runtime.GOOS != "linux" && !flagInfo.Remote {
+		fmt.Printf("Error: This is a Linux tool, can not run on %s. Use -remote to run it on a GCE VM", runtime.GOOS)
+	}
+	// Interrupts cancel the analysis instead of killing the process, so that
+	// mounted images are still cleaned up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := func() {}
+	if flagInfo.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, flagInfo.Timeout)
+	}
+	err = analyze(ctx, flagInfo)
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	cancel()
+	stop()
+	if err != nil {
+		if timedOut {
+			log.Printf("analysis timed out after %v: %v\n", flagInfo.Timeout, err)
+		} else {
+			log.Printf("%v\n", err)
+		}
 		os.Exit(1)
 	}
 	os.Exit(0)
@@ -0,0 +1,525 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains the CLI application locating the first COS build
+// containing a CL.
+//
+// This application is responsible for:
+// 1. Accepting user input and creating the authenticator object used for
+//    queries
+// 2. Calling the FindBuilds function of the findbuild package, and printing
+//    the result as plain text or JSON
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	externalGerritURL    = "https://cos-review.googlesource.com"
+	fallbackGerritURL    = "https://chromium-review.googlesource.com"
+	externalGoBURL       = "cos.googlesource.com"
+	externalManifestRepo = "cos/manifest-snapshots"
+)
+
+// result is the output for a single CL
+type result struct {
+	// CL is the CL identifier given on the command line, or the number of
+	// a CL referencing Bug
+	CL string
+	// Bug is the bug given with --bug that the CL references
+	Bug string `json:",omitempty"`
+	// BuildNum is the first build containing the CL, if it was found
+	BuildNum string `json:",omitempty"`
+	// CLNum is the number of the CL found in BuildNum
+	CLNum string `json:",omitempty"`
+	// Release is the branch of the manifest repository BuildNum is on
+	Release string `json:",omitempty"`
+	// ManifestRepo is the manifest repository BuildNum was found in
+	ManifestRepo string `json:",omitempty"`
+	// Released reports whether a public image of BuildNum exists, if
+	// --check-released is set
+	Released *bool `json:",omitempty"`
+	// Revert describes the revert of the CL, if it was reverted and
+	// --detect-reverts is set
+	Revert *findbuild.Revert `json:",omitempty"`
+	// CandidateBuilds lists the builds examined to find BuildNum
+	CandidateBuilds []*findbuild.Candidate `json:",omitempty"`
+	// Notes explain why BuildNum may not be the first build containing the
+	// CL, if the answer is ambiguous
+	Notes []string `json:",omitempty"`
+	// Milestones lists the first build containing the CL on each branch,
+	// if requested with --all-milestones
+	Milestones []*milestone `json:",omitempty"`
+	// LTS lists the first build containing the CL on each milestone
+	// requested with --lts, or that it has not landed on it yet
+	LTS []*findbuild.LTSBuild `json:",omitempty"`
+	// Error is the reason why the CL was not found
+	Error string `json:",omitempty"`
+	// Candidates lists the CLs matching the identifier if it is not unique
+	Candidates []utils.CLCandidate `json:",omitempty"`
+}
+
+type milestone struct {
+	Release  string
+	BuildNum string
+	CLNum    string
+	Revert   *findbuild.Revert `json:",omitempty"`
+}
+
+// newResult converts the output of FindBuilds for a CL. lts lists the
+// milestones requested with --lts, if any.
+func newResult(build *findbuild.CLBuild, lts []int) *result {
+	output := &result{CL: build.CL}
+	if build.Err != nil {
+		output.Error = build.Err.Error()
+		var notUnique *utils.CLNotUniqueError
+		if errors.As(build.Err, &notUnique) {
+			output.Candidates = notUnique.Candidates
+		}
+		return output
+	}
+	output.BuildNum = build.Build.BuildNum
+	output.CLNum = build.Build.CLNum
+	output.Release = build.Build.Release
+	output.ManifestRepo = build.Build.ManifestRepo
+	output.Released = build.Build.Released
+	output.Revert = build.Build.Revert
+	output.CandidateBuilds = build.Build.Candidates
+	output.Notes = build.Build.Notes
+	if len(lts) > 0 {
+		output.LTS = findbuild.LTSBuilds(build.Build, lts)
+		return output
+	}
+	for _, res := range build.Build.Milestones {
+		output.Milestones = append(output.Milestones, &milestone{Release: res.Release, BuildNum: res.BuildNum, CLNum: res.CLNum, Revert: res.Revert})
+	}
+	return output
+}
+
+// revertText describes a revert in the text output.
+func revertText(revert *findbuild.Revert) string {
+	if revert == nil {
+		return ""
+	}
+	if revert.BuildNum == "" {
+		return fmt.Sprintf(", reverted by CL %s in no build yet", revert.CLNum)
+	}
+	return fmt.Sprintf(", reverted by CL %s in %s", revert.CLNum, revert.BuildNum)
+}
+
+// releasedText describes whether a build was released in the text output.
+func releasedText(released *bool) string {
+	switch {
+	case released == nil:
+		return ""
+	case *released:
+		return ", released"
+	}
+	return ", not released"
+}
+
+// writeResults prints results to w as plain text or JSON.
+func writeResults(w io.Writer, format string, results []*result) error {
+	switch format {
+	case "json":
+		jsonData, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			return fmt.Errorf("writeResults: error marshalling results: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", jsonData)
+		return err
+	case "text":
+		for _, res := range results {
+			if res.Error != "" {
+				if _, err := fmt.Fprintf(w, "%s: error: %s\n", res.CL, res.Error); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s (%s)%s%s\n", res.CL, res.BuildNum, res.ManifestRepo, releasedText(res.Released), revertText(res.Revert)); err != nil {
+				return err
+			}
+			for _, note := range res.Notes {
+				if _, err := fmt.Fprintf(w, "    note: %s\n", note); err != nil {
+					return err
+				}
+			}
+			for _, milestone := range res.Milestones {
+				if _, err := fmt.Fprintf(w, "    %s: %s (CL %s)%s\n", milestone.Release, milestone.BuildNum, milestone.CLNum, revertText(milestone.Revert)); err != nil {
+					return err
+				}
+			}
+			for _, lts := range res.LTS {
+				line := fmt.Sprintf("    R%d: not yet landed\n", lts.Milestone)
+				if lts.Landed {
+					line = fmt.Sprintf("    R%d: %s (CL %s)\n", lts.Milestone, lts.BuildNum, lts.CLNum)
+				}
+				if _, err := io.WriteString(w, line); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q, expected json or text", format)
+}
+
+// getHTTPClient creates a client authenticating as the service account of the
+// JSON key at credentials, or with Application Default Credentials if empty.
+func getHTTPClient(credentials string) (*http.Client, error) {
+	log.Debug("Creating HTTP client")
+	if credentials != "" {
+		return utils.ServiceAccountFileHTTPClient(context.Background(), credentials)
+	}
+	httpClient, err := utils.DefaultCredentialsHTTPClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("no application default credentials found - run `gcloud auth application-default login` and try again")
+	}
+	return httpClient, nil
+}
+
+// hostHTTPClients creates the clients of the hosts of --host-credentials,
+// given as HOST=FILE, authenticating as the service account of each JSON key
+// file.
+func hostHTTPClients(specs []string) (map[string]*http.Client, error) {
+	clients := make(map[string]*http.Client)
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid host credentials %q, expected HOST=FILE", spec)
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(parts[0], "https://"), "/")
+		client, err := utils.ServiceAccountFileHTTPClient(context.Background(), parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("error creating http client for host %s: %v", host, err)
+		}
+		clients[host] = client
+	}
+	return clients, nil
+}
+
+// imageReleaseChecker creates the checker of --check-released, listing the
+// public images with the credentials of getHTTPClient.
+func imageReleaseChecker(credentials string) (findbuild.ReleaseChecker, error) {
+	opts := []option.ClientOption{option.WithScopes(compute.ComputeReadonlyScope)}
+	if credentials != "" {
+		opts = append(opts, option.WithCredentialsFile(credentials))
+	}
+	svc, err := compute.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating compute client: %v", err)
+	}
+	return findbuild.NewImageReleaseChecker(svc, findbuild.PublicImageProject), nil
+}
+
+// findBuilds finds the first build containing each CL of req. CLs that are
+// not found on req.GerritHost are searched on fallback, unless it is empty.
+// The results report the LTS milestones of req.Milestones, if any.
+func findBuilds(ctx context.Context, req *findbuild.BuildRequest, fallback string) ([]*result, error) {
+	builds, clErr := findbuild.FindBuilds(ctx, req)
+	if clErr != nil {
+		return nil, clErr
+	}
+	// missingIdx holds the index in builds of each CL of missing
+	var missing []string
+	var missingIdx []int
+	for i, build := range builds {
+		if build.Err != nil && build.Err.HTTPCode() == "404" {
+			missing = append(missing, build.CL)
+			missingIdx = append(missingIdx, i)
+		}
+	}
+	if fallback != "" && len(missing) > 0 {
+		log.Debugf("%d CLs not found on Gerrit url %s, retrying with fallback url %s", len(missing), req.GerritHost, fallback)
+		fallbackReq := *req
+		fallbackReq.GerritHost = fallback
+		fallbackReq.CLs = missing
+		fallbackBuilds, clErr := findbuild.FindBuilds(ctx, &fallbackReq)
+		if clErr != nil {
+			return nil, clErr
+		}
+		for i, build := range fallbackBuilds {
+			builds[missingIdx[i]] = build
+		}
+	}
+	output := make([]*result, len(builds))
+	for i, build := range builds {
+		output[i] = newResult(build, req.Milestones)
+	}
+	return output, nil
+}
+
+// findBugBuilds finds the first build containing each CL referencing bug on
+// req.GerritHost, or on fallback if no CL of req.GerritHost references it and
+// fallback is not empty. A bug referenced by no CL is reported as a failed
+// result.
+func findBugBuilds(ctx context.Context, req *findbuild.BuildRequest, bug, fallback string) []*result {
+	builds, clErr := findbuild.FindBugBuilds(ctx, req, bug)
+	if clErr != nil && clErr.HTTPCode() == "404" && fallback != "" {
+		log.Debugf("No CL referencing bug %s found on Gerrit url %s, retrying with fallback url %s", bug, req.GerritHost, fallback)
+		fallbackReq := *req
+		fallbackReq.GerritHost = fallback
+		builds, clErr = findbuild.FindBugBuilds(ctx, &fallbackReq, bug)
+	}
+	if clErr != nil {
+		return []*result{{CL: bug, Bug: bug, Error: clErr.Error()}}
+	}
+	output := make([]*result, len(builds))
+	for i, build := range builds {
+		output[i] = newResult(build, req.Milestones)
+		output[i].Bug = bug
+	}
+	return output
+}
+
+func main() {
+	var gerritURL, fallbackURL, gobURL, branch, cacheDir, credentials, mappingFile, format string
+	var allMilestones, upstreamKernel, detectReverts, checkReleased, debug bool
+	var retries int
+	var timeout time.Duration
+	app := &cli.App{
+		Name:      "findbuild",
+		Usage:     "find the first build containing CLs",
+		UsageText: "findbuild [options] [CL-number || commit-SHA || Change-Id || \"commit subject\"]...",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "cl",
+				Usage: "`CL` number, commit SHA, Change-Id or commit subject substring to find. Can be repeated, and CLs can also be given as arguments",
+			},
+			&cli.StringSliceFlag{
+				Name:  "bug",
+				Usage: "Find the first build containing each CL referencing `BUG`, ex. b/123456 or crbug/123456, in its Bug footer. Can be repeated",
+			},
+			&cli.StringFlag{
+				Name:        "branch",
+				Value:       "",
+				Usage:       "Select the CL on `BRANCH`, ex. release-R97-16919.B, when a CL identifier matches several cherry-picked CLs",
+				Destination: &branch,
+			},
+			&cli.StringFlag{
+				Name:        "gerrit",
+				Value:       externalGerritURL,
+				Usage:       "Gerrit instance `URL` to query from",
+				Destination: &gerritURL,
+			},
+			&cli.StringFlag{
+				Name:        "fallback",
+				Value:       fallbackGerritURL,
+				Usage:       "Fallback Gerrit instance `URL` for CLs not found on --gerrit. Disabled if empty",
+				Destination: &fallbackURL,
+			},
+			&cli.StringFlag{
+				Name:        "gob",
+				Value:       externalGoBURL,
+				Usage:       "GoB `HOST` containing the manifest repository",
+				Destination: &gobURL,
+			},
+			&cli.StringSliceFlag{
+				Name:  "repo",
+				Value: cli.NewStringSlice(externalManifestRepo),
+				Usage: "Manifest `REPOSITORY` within the GoB instance. Can be repeated to search several repositories in priority order",
+			},
+			&cli.StringFlag{
+				Name:        "credentials",
+				Value:       "",
+				Usage:       "Service account JSON key `FILE`. Application Default Credentials are used by default",
+				Destination: &credentials,
+			},
+			&cli.StringSliceFlag{
+				Name:  "host-credentials",
+				Usage: "Service account JSON key used for a Gerrit or GoB host instead of --credentials, as `HOST=FILE`, ex. chromium-review.googlesource.com=key.json. Can be repeated",
+			},
+			&cli.StringFlag{
+				Name:        "mapping",
+				Value:       "",
+				Usage:       "JSON `FILE` describing how CLs map to manifest repositories and release branches. Its rules extend the default COS and ChromiumOS kernel rules, unless it sets \"ReplaceDefaults\": true",
+				Destination: &mappingFile,
+			},
+			&cli.StringFlag{
+				Name:        "cache-dir",
+				Value:       "",
+				Usage:       "`DIR` to cache manifest tags and files in between runs. Caching is disabled if empty",
+				Destination: &cacheDir,
+			},
+			&cli.BoolFlag{
+				Name:        "all-milestones",
+				Value:       false,
+				Usage:       "Find the first build containing each CL on every release branch",
+				Destination: &allMilestones,
+			},
+			&cli.IntSliceFlag{
+				Name:  "lts",
+				Usage: "Report the first build containing each CL on the release branch of each LTS `MILESTONE`, ex. 93,97, or that it has not landed on it yet",
+			},
+			&cli.BoolFlag{
+				Name:        "upstream-kernel",
+				Value:       false,
+				Usage:       "Treat commit SHAs as upstream Linux kernel commits backported to COS",
+				Destination: &upstreamKernel,
+			},
+			&cli.BoolFlag{
+				Name:        "detect-reverts",
+				Value:       false,
+				Usage:       "Report whether each CL was reverted, and the first build containing the revert",
+				Destination: &detectReverts,
+			},
+			&cli.BoolFlag{
+				Name:        "check-released",
+				Value:       false,
+				Usage:       "Report whether a public image of each build found exists in the cos-cloud project, since some builds never ship",
+				Destination: &checkReleased,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Value:       0,
+				Usage:       "Abandon the search after `DURATION`, ex. 5m. No timeout by default",
+				Destination: &timeout,
+			},
+			&cli.IntFlag{
+				Name:        "retries",
+				Value:       utils.DefaultRetryPolicy().MaxAttempts - 1,
+				Usage:       "Maximum `NUMBER` of times a failed Gerrit or Gitiles request is retried",
+				Destination: &retries,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       "text",
+				Aliases:     []string{"f"},
+				Usage:       "Output `FORMAT`. Acceptable values: text | json",
+				Destination: &format,
+			},
+			&cli.BoolFlag{
+				Name:        "debug",
+				Value:       false,
+				Aliases:     []string{"d"},
+				Usage:       "Toggle debug messages",
+				Destination: &debug,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if debug {
+				log.SetLevel(log.DebugLevel)
+			}
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unsupported output format %q, expected json or text", format)
+			}
+			if retries < 0 {
+				return fmt.Errorf("invalid number of retries %d, expected a non-negative number", retries)
+			}
+			cls := append(c.StringSlice("cl"), c.Args().Slice()...)
+			bugs := c.StringSlice("bug")
+			if len(cls) == 0 && len(bugs) == 0 {
+				return errors.New("must specify at least one CL number (ex. 3280), commit SHA, Change-Id or bug")
+			}
+			httpClient, err := getHTTPClient(credentials)
+			if err != nil {
+				return fmt.Errorf("error creating http client: %v", err)
+			}
+			req := &findbuild.BuildRequest{
+				HTTPClient:     httpClient,
+				GerritHost:     gerritURL,
+				GitilesHost:    gobURL,
+				ManifestRepos:  c.StringSlice("repo"),
+				CLs:            cls,
+				Branch:         branch,
+				AllMilestones:  allMilestones,
+				UpstreamKernel: upstreamKernel,
+				DetectReverts:  detectReverts,
+			}
+			if specs := c.StringSlice("host-credentials"); len(specs) > 0 {
+				clients, err := hostHTTPClients(specs)
+				if err != nil {
+					return err
+				}
+				req.HostHTTPClient = findbuild.HostHTTPClients(clients)
+			}
+			if lts := c.IntSlice("lts"); len(lts) > 0 {
+				req.AllMilestones = true
+				req.Milestones = lts
+			}
+			if mappingFile != "" {
+				if req.Mapping, err = findbuild.LoadMappingConfig(mappingFile); err != nil {
+					return err
+				}
+			}
+			if checkReleased {
+				if req.ReleaseChecker, err = imageReleaseChecker(credentials); err != nil {
+					return err
+				}
+			}
+			retryPolicy := utils.DefaultRetryPolicy()
+			retryPolicy.MaxAttempts = retries + 1
+			req.RetryPolicy = &retryPolicy
+			if cacheDir != "" {
+				diskCache, err := changelog.NewDiskCache(cacheDir)
+				if err != nil {
+					return fmt.Errorf("error creating cache: %v", err)
+				}
+				req.Cache = findbuild.NewMemoryCache(0, diskCache)
+			}
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			var results []*result
+			if len(cls) > 0 {
+				if results, err = findBuilds(ctx, req, fallbackURL); err != nil {
+					return err
+				}
+			}
+			for _, bug := range bugs {
+				results = append(results, findBugBuilds(ctx, req, bug, fallbackURL)...)
+			}
+			if err := writeResults(os.Stdout, format, results); err != nil {
+				return err
+			}
+			failed := 0
+			for _, res := range results {
+				if res.Error != "" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d CLs not found", failed, len(results))
+			}
+			return nil
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		log.Errorf("main: error running app with arguments: %v:\n%v", os.Args, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeRequest returns a request served by fake services with builds 1.0.0
+// and 2.0.0. CL 7 is only known to the fallback Gerrit instance, references
+// bug b/42, and is included from build 2.0.0.
+func fakeRequest() *findbuild.BuildRequest {
+	baseTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := fakes.NewGitiles()
+	g.Commits[externalManifestRepo] = []*git.Commit{
+		{Id: "m1", Committer: &git.Commit_User{Time: timestamppb.New(baseTime)}},
+		{Id: "m2", Parents: []string{"m1"}, Committer: &git.Commit_User{Time: timestamppb.New(baseTime.AddDate(0, 0, 1))}},
+	}
+	g.Refs[externalManifestRepo] = map[string]string{"refs/heads/master": "m2"}
+	g.Commits["cos/overlays"] = []*git.Commit{{Id: "o1"}, {Id: "o2", Parents: []string{"o1"}}}
+	primary, fallback := fakes.NewGerrit(), fakes.NewGerrit()
+	for i, sha := range []string{"o1", "o2"} {
+		buildNum := fmt.Sprintf("%d.0.0", i+1)
+		g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays" path="src/overlays" revision="` + sha + `"/>
+</manifest>`
+		primary.Tags[externalManifestRepo] = append(primary.Tags[externalManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + buildNum, Revision: fmt.Sprintf("m%d", i+1)})
+	}
+	fallback.Changes = []gerrit.ChangeInfo{{
+		Number:          7,
+		Project:         "cos/overlays",
+		Branch:          "master",
+		Status:          "MERGED",
+		CurrentRevision: "o2",
+		Revisions: map[string]gerrit.RevisionInfo{
+			"o2": {Commit: gerrit.CommitInfo{Message: "Fix\n\nBug: b/42\n"}},
+		},
+		Submitted: &gerrit.Timestamp{Time: baseTime.Add(12 * time.Hour)},
+	}}
+	return &findbuild.BuildRequest{
+		GerritHost:    externalGerritURL,
+		GitilesHost:   externalGoBURL,
+		ManifestRepo:  externalManifestRepo,
+		GitilesClient: func(string) (utils.GitilesService, error) { return g, nil },
+		GerritClient: func(host string) (findbuild.GerritService, error) {
+			if host == fallbackGerritURL {
+				return fallback, nil
+			}
+			return primary, nil
+		},
+	}
+}
+
+func TestFindBuilds(t *testing.T) {
+	tests := map[string]struct {
+		fallback string
+		expected []*result
+	}{
+		"Fallback": {
+			fallback: fallbackGerritURL,
+			expected: []*result{
+				{CL: "7", BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo, CandidateBuilds: []*findbuild.Candidate{
+					{BuildNum: "1.0.0", Revision: "o1", Checked: true},
+					{BuildNum: "2.0.0", Revision: "o2", Checked: true, Contains: true},
+				}},
+				{CL: "8", Error: utils.CLNotFound("8").Error()},
+			},
+		},
+		"No Fallback": {
+			expected: []*result{
+				{CL: "7", Error: utils.CLNotFound("7").Error()},
+				{CL: "8", Error: utils.CLNotFound("8").Error()},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := fakeRequest()
+			req.CLs = []string{"7", "8"}
+			got, err := findBuilds(context.Background(), req, test.fallback)
+			if err != nil {
+				t.Fatalf("findBuilds failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected results, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindBugBuilds(t *testing.T) {
+	tests := map[string]struct {
+		fallback string
+		expected []*result
+	}{
+		"Fallback": {
+			fallback: fallbackGerritURL,
+			expected: []*result{
+				{CL: "7", Bug: "b/42", BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo, CandidateBuilds: []*findbuild.Candidate{
+					{BuildNum: "1.0.0", Revision: "o1", Checked: true},
+					{BuildNum: "2.0.0", Revision: "o2", Checked: true, Contains: true},
+				}},
+			},
+		},
+		"No Fallback": {
+			expected: []*result{
+				{CL: "b/42", Bug: "b/42", Error: utils.BugNotFound("b/42", externalGerritURL).Error()},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := findBugBuilds(context.Background(), fakeRequest(), "b/42", test.fallback)
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected results, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	candidates := []utils.CLCandidate{{Number: 7, Branch: "master"}, {Number: 9, Branch: "release-R2"}}
+	tests := map[string]struct {
+		build    *findbuild.CLBuild
+		lts      []int
+		expected *result
+	}{
+		"Found": {
+			build:    &findbuild.CLBuild{CL: "7", Build: &findbuild.BuildResponse{BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo}},
+			expected: &result{CL: "7", BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"LTS": {
+			build: &findbuild.CLBuild{CL: "7", Build: &findbuild.BuildResponse{BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo,
+				Milestones: []*findbuild.BuildResponse{{BuildNum: "2.1.0", CLNum: "9", Release: "release-R2"}, {BuildNum: "2.0.0", CLNum: "7", Release: "master"}}}},
+			lts: []int{1, 2},
+			expected: &result{CL: "7", BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo, LTS: []*findbuild.LTSBuild{
+				{Milestone: 1, Release: "release-R1"},
+				{Milestone: 2, Release: "release-R2", Landed: true, BuildNum: "2.1.0", CLNum: "9"},
+			}},
+		},
+		"Not Unique": {
+			build:    &findbuild.CLBuild{CL: "I7", Err: utils.CLNotUnique("I7", candidates, externalGerritURL)},
+			expected: &result{CL: "I7", Error: utils.CLNotUnique("I7", candidates, externalGerritURL).Error(), Candidates: candidates},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, newResult(test.build, test.lts)); diff != "" {
+				t.Errorf("unexpected result, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	released := true
+	results := []*result{
+		{CL: "7", BuildNum: "2.0.0", CLNum: "7", Release: "master", ManifestRepo: externalManifestRepo, Released: &released, Milestones: []*milestone{{Release: "release-R2", BuildNum: "2.1.0", CLNum: "9", Revert: &findbuild.Revert{CLNum: "12", BuildNum: "2.2.0"}}}},
+		{CL: "8", Error: "not found"},
+		{CL: "10", BuildNum: "1.0.0", CLNum: "10", Release: "master", ManifestRepo: externalManifestRepo, Revert: &findbuild.Revert{CLNum: "11"},
+			CandidateBuilds: []*findbuild.Candidate{{BuildNum: "0.9.0"}, {BuildNum: "1.0.0", Revision: "o1", Checked: true, Contains: true}},
+			Notes:           []string{"build 0.9.0 may contain the CL"}},
+		{CL: "9", BuildNum: "2.0.0", CLNum: "9", Release: "master", ManifestRepo: externalManifestRepo, LTS: []*findbuild.LTSBuild{
+			{Milestone: 1, Release: "release-R1"},
+			{Milestone: 2, Release: "release-R2", Landed: true, BuildNum: "2.1.0", CLNum: "10"},
+		}},
+	}
+	tests := map[string]struct {
+		format    string
+		expected  string
+		expectErr bool
+	}{
+		"Text": {
+			format:   "text",
+			expected: "7: 2.0.0 (cos/manifest-snapshots), released\n    release-R2: 2.1.0 (CL 9), reverted by CL 12 in 2.2.0\n8: error: not found\n10: 1.0.0 (cos/manifest-snapshots), reverted by CL 11 in no build yet\n    note: build 0.9.0 may contain the CL\n9: 2.0.0 (cos/manifest-snapshots)\n    R1: not yet landed\n    R2: 2.1.0 (CL 10)\n",
+		},
+		"JSON": {
+			format: "json",
+			expected: `[
+    {
+        "CL": "7",
+        "BuildNum": "2.0.0",
+        "CLNum": "7",
+        "Release": "master",
+        "ManifestRepo": "cos/manifest-snapshots",
+        "Released": true,
+        "Milestones": [
+            {
+                "Release": "release-R2",
+                "BuildNum": "2.1.0",
+                "CLNum": "9",
+                "Revert": {
+                    "CLNum": "12",
+                    "BuildNum": "2.2.0"
+                }
+            }
+        ]
+    },
+    {
+        "CL": "8",
+        "Error": "not found"
+    },
+    {
+        "CL": "10",
+        "BuildNum": "1.0.0",
+        "CLNum": "10",
+        "Release": "master",
+        "ManifestRepo": "cos/manifest-snapshots",
+        "Revert": {
+            "CLNum": "11"
+        },
+        "CandidateBuilds": [
+            {
+                "BuildNum": "0.9.0",
+                "Checked": false,
+                "Contains": false
+            },
+            {
+                "BuildNum": "1.0.0",
+                "Revision": "o1",
+                "Checked": true,
+                "Contains": true
+            }
+        ],
+        "Notes": [
+            "build 0.9.0 may contain the CL"
+        ]
+    },
+    {
+        "CL": "9",
+        "BuildNum": "2.0.0",
+        "CLNum": "9",
+        "Release": "master",
+        "ManifestRepo": "cos/manifest-snapshots",
+        "LTS": [
+            {
+                "Milestone": 1,
+                "Release": "release-R1",
+                "Landed": false
+            },
+            {
+                "Milestone": 2,
+                "Release": "release-R2",
+                "Landed": true,
+                "BuildNum": "2.1.0",
+                "CLNum": "10"
+            }
+        ]
+    }
+]
+`,
+		},
+		"Invalid Format": {
+			format:    "html",
+			expectErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeResults(&buf, test.format, results)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error %t, got %v", test.expectErr, err)
+			}
+			if diff := cmp.Diff(test.expected, buf.String()); diff != "" {
+				t.Errorf("unexpected output, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostHTTPClients(t *testing.T) {
+	tests := map[string]struct {
+		specs     []string
+		expectErr bool
+	}{
+		"Empty":        {},
+		"Missing File": {specs: []string{"cos.googlesource.com"}, expectErr: true},
+		"Missing Host": {specs: []string{"=key.json"}, expectErr: true},
+		"Invalid Key":  {specs: []string{"cos.googlesource.com=" + filepath.Join(t.TempDir(), "missing.json")}, expectErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, err := hostHTTPClients(test.specs)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error %t, got %v", test.expectErr, err)
+			}
+			if err == nil && len(clients) != len(test.specs) {
+				t.Errorf("expected %d clients, got %d", len(test.specs), len(clients))
+			}
+		})
+	}
+}
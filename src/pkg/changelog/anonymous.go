@@ -0,0 +1,124 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gitilesApi "go.chromium.org/luci/common/api/gitiles"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+)
+
+// defaultPublicInstances are the GoB instances readable without credentials
+// unless Options.PublicInstances is set.
+var defaultPublicInstances = []string{
+	"cos.googlesource.com",
+	"chromium.googlesource.com",
+	"android.googlesource.com",
+	"kernel.googlesource.com",
+}
+
+// authFailed reports whether a Gitiles request failed because its credentials
+// were rejected or could not be retrieved, ex. an expired refresh token.
+func authFailed(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return true
+	}
+	code := utils.GitilesErrCode(err)
+	return code == "401" || code == "403"
+}
+
+// anonymousFallbackClient queries a public instance with credentials, and
+// switches to anonymous requests for good once the credentials fail, so users
+// whose credentials do not work can still read public repositories.
+type anonymousFallbackClient struct {
+	remoteURL     string
+	authenticated utils.GitilesService
+	anonymous     utils.GitilesService
+	// Set once the credentials failed
+	fallback int32
+}
+
+// client returns the client to send the next request with.
+func (c *anonymousFallbackClient) client() utils.GitilesService {
+	if atomic.LoadInt32(&c.fallback) == 1 {
+		return c.anonymous
+	}
+	return c.authenticated
+}
+
+// failed reports whether a request sent with credentials should be retried
+// anonymously, and if so stops using the credentials.
+func (c *anonymousFallbackClient) failed(err error) bool {
+	if err == nil || !authFailed(err) {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&c.fallback, 0, 1) {
+		log.Warnf("Credentials failed for public remote url %s, falling back to anonymous access: %v", c.remoteURL, err)
+	}
+	return true
+}
+
+func (c *anonymousFallbackClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	client := c.client()
+	res, err := client.Log(ctx, in, opts...)
+	if client == c.authenticated && c.failed(err) {
+		return c.anonymous.Log(ctx, in, opts...)
+	}
+	return res, err
+}
+
+func (c *anonymousFallbackClient) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	client := c.client()
+	res, err := client.DownloadFile(ctx, in, opts...)
+	if client == c.authenticated && c.failed(err) {
+		return c.anonymous.DownloadFile(ctx, in, opts...)
+	}
+	return res, err
+}
+
+// restClient creates a Gitiles REST client for a remote. Public instances are
+// queried anonymously if httpClient is nil, and fall back to anonymous
+// requests if its credentials fail.
+func restClient(httpClient *http.Client, remoteURL string, opts *Options) (utils.GitilesService, error) {
+	public := opts.publicInstance(remoteURL)
+	if httpClient == nil {
+		if !public {
+			return nil, fmt.Errorf("no credentials to query non-public instance %s", remoteURL)
+		}
+		log.Debugf("Creating anonymous Gitiles client for remote url %s\n", remoteURL)
+		return gitilesApi.NewRESTClient(http.DefaultClient, remoteURL, false)
+	}
+	authenticated, err := gitilesApi.NewRESTClient(httpClient, remoteURL, true)
+	if err != nil {
+		return nil, err
+	}
+	if !public {
+		return authenticated, nil
+	}
+	anonymous, err := gitilesApi.NewRESTClient(http.DefaultClient, remoteURL, false)
+	if err != nil {
+		return nil, err
+	}
+	return &anonymousFallbackClient{remoteURL: remoteURL, authenticated: authenticated, anonymous: anonymous}, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"go.chromium.org/luci/common/proto/git"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rejectingGitiles fails every request with an error and counts them.
+type rejectingGitiles struct {
+	err      error
+	requests int
+}
+
+func (g *rejectingGitiles) Log(context.Context, *gitilesProto.LogRequest, ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	g.requests++
+	return nil, g.err
+}
+
+func (g *rejectingGitiles) DownloadFile(context.Context, *gitilesProto.DownloadFileRequest, ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	g.requests++
+	return nil, g.err
+}
+
+func TestAuthFailed(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"Unauthorized": {
+			err:      status.Error(codes.Internal, "unexpected HTTP 401 from Gitiles"),
+			expected: true,
+		},
+		"Forbidden": {
+			err:      status.Error(codes.Internal, "unexpected HTTP 403 from Gitiles"),
+			expected: true,
+		},
+		"Token Refresh": {
+			err:      &url.Error{Op: "Get", URL: "https://cos.googlesource.com/a/", Err: &oauth2.RetrieveError{}},
+			expected: true,
+		},
+		"Not Found": {
+			err:      status.Error(codes.NotFound, "not found"),
+			expected: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := authFailed(test.err); got != test.expected {
+				t.Errorf("expected authFailed %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestAnonymousFallbackClient(t *testing.T) {
+	tests := map[string]struct {
+		err              error
+		expectedRequests int
+		expectErr        bool
+	}{
+		"Credentials Rejected": {
+			err:              status.Error(codes.Internal, "unexpected HTTP 401 from Gitiles"),
+			expectedRequests: 1,
+		},
+		"Other Failure": {
+			err:              status.Error(codes.Unavailable, "unavailable"),
+			expectedRequests: 2,
+			expectErr:        true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			anonymous := fakes.NewGitiles()
+			anonymous.Commits["third_party/kernel"] = []*git.Commit{{Id: "k1"}}
+			authenticated := &rejectingGitiles{err: test.err}
+			client := &anonymousFallbackClient{remoteURL: cosInstance, authenticated: authenticated, anonymous: anonymous}
+			for i := 0; i < 2; i++ {
+				_, err := client.Log(context.Background(), &gitilesProto.LogRequest{Project: "third_party/kernel", Committish: "k1"})
+				if (err != nil) != test.expectErr {
+					t.Errorf("request %d: expected error %v, got %v", i, test.expectErr, err)
+				}
+			}
+			if authenticated.requests != test.expectedRequests {
+				t.Errorf("expected %d authenticated requests, got %d", test.expectedRequests, authenticated.requests)
+			}
+		})
+	}
+}
+
+func TestRestClient(t *testing.T) {
+	tests := map[string]struct {
+		httpClient       *http.Client
+		remoteURL        string
+		opts             *Options
+		expectErr        bool
+		expectedFallback bool
+	}{
+		"Anonymous Public": {
+			remoteURL: cosInstance,
+		},
+		"Anonymous Non-Public": {
+			remoteURL: "cos-internal.googlesource.com",
+			expectErr: true,
+		},
+		"Custom Public Instances": {
+			remoteURL: cosInstance,
+			opts:      &Options{PublicInstances: []string{}},
+			expectErr: true,
+		},
+		"Authenticated Public": {
+			httpClient:       http.DefaultClient,
+			remoteURL:        cosInstance,
+			expectedFallback: true,
+		},
+		"Authenticated Non-Public": {
+			httpClient: http.DefaultClient,
+			remoteURL:  "cos-internal.googlesource.com",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := restClient(test.httpClient, test.remoteURL, test.opts)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error %v, got %v", test.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if _, ok := client.(*anonymousFallbackClient); ok != test.expectedFallback {
+				t.Errorf("expected anonymous fallback %v, got %v", test.expectedFallback, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// Matches the milestone of a release branch, ex. "refs/heads/release-R93"
+var releaseBranchRe = regexp.MustCompile(`release-R(\d+)(?:-|$)`)
+
+// BuildInfo describes a build a changelog was generated for.
+type BuildInfo struct {
+	// Build number the manifest file was looked up with, ex. "15000.0.0"
+	BuildNumber string `json:"BuildNumber"`
+	// Milestone of the build, ex. 93. Zero if the branch is not a release
+	// branch.
+	Milestone int `json:"Milestone,omitempty"`
+	// Default branch of the manifest file, ex. "refs/heads/release-R93"
+	Branch string `json:"Branch,omitempty"`
+	// Time the manifest file of the build was committed. Zero if it could
+	// not be retrieved.
+	ManifestCommitTime time.Time `json:"ManifestCommitTime"`
+}
+
+// String returns a label for the build, ex. "15000.0.0 (M93)".
+func (b *BuildInfo) String() string {
+	if b.Milestone == 0 {
+		return b.BuildNumber
+	}
+	return fmt.Sprintf("%s (M%d)", b.BuildNumber, b.Milestone)
+}
+
+// milestone returns the milestone of a release branch, or 0 if branch is not
+// a release branch.
+func milestone(branch string) int {
+	match := releaseBranchRe.FindStringSubmatch(branch)
+	if match == nil {
+		return 0
+	}
+	m, _ := strconv.Atoi(match[1])
+	return m
+}
+
+func buildInfoCacheKey(instanceURL, repo, ref, fileName string) string {
+	return fmt.Sprintf("build:%s/%s@%s:%s", instanceURL, repo, ref, fileName)
+}
+
+// buildInfo retrieves the metadata of a build from its manifest file and the
+// commit that added it. The manifest file is usually already cached by
+// mappedManifest. Failing to retrieve the commit time is not an error, since
+// it is only used to label changelogs.
+func buildInfo(ctx context.Context, client utils.GitilesService, host, repo, buildNum string, opts *Options) (*BuildInfo, utils.ChangelogError) {
+	ref, fileName := opts.manifestRef(buildNum), opts.manifestFileName()
+	cacheKey := buildInfoCacheKey(host, repo, ref, fileName)
+	info := &BuildInfo{}
+	cacheable := immutableManifestRef(ref)
+	if cacheable && meteredCacheGet(opts.cache(), opts.meter(), ManifestLookup, cacheKey, info) {
+		return info, nil
+	}
+	contents, err := manifestFile(ctx, client, host, repo, ref, fileName, opts)
+	if err != nil {
+		log.Errorf("buildInfo: error downloading manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
+		return nil, utils.InternalServerError
+	}
+	root, err := parseManifestXML(contents)
+	if err != nil {
+		log.Errorf("buildInfo: error parsing manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
+		return nil, utils.ManifestMalformed(buildNum)
+	}
+	info.BuildNumber = buildNum
+	if def := root.SelectElement("default"); def != nil {
+		info.Branch = branchRef(def.SelectAttrValue("revision", ""))
+		info.Milestone = milestone(info.Branch)
+	}
+	commits, _, err := utils.Commits(ctx, client, repo, ref, "", 1)
+	if err != nil || len(commits) == 0 {
+		log.Errorf("buildInfo: failed to retrieve manifest commit of build %s: %v", buildNum, err)
+		return info, nil
+	}
+	if committer := commits[0].Committer; committer != nil && committer.Time != nil {
+		info.ManifestCommitTime = committer.Time.AsTime().UTC()
+	}
+	if cacheable {
+		cacheSet(opts.cache(), cacheKey, info)
+	}
+	return info, nil
+}
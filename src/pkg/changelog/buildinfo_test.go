@@ -0,0 +1,94 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMilestone(t *testing.T) {
+	tests := map[string]int{
+		"refs/heads/release-R93":       93,
+		"refs/heads/release-R101":      101,
+		"refs/heads/release-R89-16108": 89,
+		"refs/heads/master":            0,
+		"refs/heads/release-R93x":      0,
+		"":                             0,
+	}
+	for branch, expected := range tests {
+		if got := milestone(branch); got != expected {
+			t.Errorf("expected milestone %d for branch %q, got %d", expected, branch, got)
+		}
+	}
+}
+
+func TestBuildInfoString(t *testing.T) {
+	if got := (&BuildInfo{BuildNumber: "15000.0.0", Milestone: 93}).String(); got != "15000.0.0 (M93)" {
+		t.Errorf("unexpected label %q", got)
+	}
+	if got := (&BuildInfo{BuildNumber: "15000.0.0"}).String(); got != "15000.0.0" {
+		t.Errorf("unexpected label %q", got)
+	}
+}
+
+func TestChangelogWithBuildInfo(t *testing.T) {
+	sourceTime := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	targetTime := time.Date(2021, 7, 8, 12, 0, 0, 0, time.UTC)
+	g := fakes.NewGitiles()
+	g.Commits[defaultManifestRepo] = []*git.Commit{
+		{Id: "m1", Committer: &git.Commit_User{Time: timestamppb.New(sourceTime)}},
+		{Id: "m2", Parents: []string{"m1"}, Committer: &git.Commit_User{Time: timestamppb.New(targetTime)}},
+	}
+	g.Refs[defaultManifestRepo] = map[string]string{"refs/tags/1.0.0": "m1", "refs/tags/2.0.0": "m2"}
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1", Message: "Initial kernel"},
+		{Id: "k2", Parents: []string{"k1"}, Message: "Fix GPU reset"},
+	}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "m1", Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/release-R93"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="k1"/>
+</manifest>`
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "m2", Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="k2"/>
+</manifest>`
+	opts := &Options{GitilesClient: func(string) (utils.GitilesService, error) { return g, nil }}
+
+	doc, err := ChangelogWithBuildInfo(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("ChangelogWithBuildInfo failed: %v", err)
+	}
+	expectedSource := &BuildInfo{BuildNumber: "1.0.0", Milestone: 93, Branch: "refs/heads/release-R93", ManifestCommitTime: sourceTime}
+	expectedTarget := &BuildInfo{BuildNumber: "2.0.0", Branch: "refs/heads/master", ManifestCommitTime: targetTime}
+	if diff := cmp.Diff(expectedSource, doc.SourceBuild); diff != "" {
+		t.Errorf("unexpected source build info (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedTarget, doc.TargetBuild); diff != "" {
+		t.Errorf("unexpected target build info (-want +got):\n%s", diff)
+	}
+	if !commitsMatch(doc.Additions["src/third_party/kernel"].Commits, []string{"k2"}) {
+		t.Errorf("expected kernel addition k2, got %v", doc.Additions["src/third_party/kernel"].Commits)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Cache stores Gitiles responses so identical requests made by repeated
+// changelog generations are not sent again. Implementations must be safe for
+// concurrent use. Errors are not returned since a failing cache only costs
+// an extra Gitiles request; implementations should log them instead.
+//
+// Only responses for immutable inputs (build tags and commit SHAs) are
+// cached, so entries never need to be invalidated.
+type Cache interface {
+	// Get returns the value stored for key, and whether it was found.
+	Get(key string) ([]byte, bool)
+	// Set stores value for key.
+	Set(key string, value []byte)
+}
+
+// cachedCommits is the cache entry for a commit log request
+type cachedCommits struct {
+	Commits                []*Commit
+	HasMoreCommits         bool
+	NextPageToken          string
+	ContinuationCommittish string
+}
+
+func manifestCacheKey(instanceURL, repo, ref, fileName string) string {
+	return fmt.Sprintf("manifest:%s/%s@%s:%s", instanceURL, repo, ref, fileName)
+}
+
+func commitsCacheKey(instanceURL, repo, committish, ancestor string, querySize int, fileChanges bool) string {
+	key := fmt.Sprintf("log:%s/%s@%s..%s?n=%d", instanceURL, repo, ancestor, committish, querySize)
+	if fileChanges {
+		key += "&files"
+	}
+	return key
+}
+
+// immutableCommittish reports whether a committish always refers to the same
+// commit, which makes responses for it safe to cache.
+func immutableCommittish(committish string) bool {
+	return committish == "" || commitSHARe.MatchString(committish)
+}
+
+// immutableManifestRef reports whether the manifest file at a ref can be
+// cached. Tags are assumed to never move, unlike branches.
+func immutableManifestRef(ref string) bool {
+	return !strings.HasPrefix(ref, "refs/heads/")
+}
+
+// StableBuild reports whether a build passed to Changelog always refers to the
+// same manifest file, which makes changelogs involving it safe to store.
+// Builds given as a branch ref, ex. "refs/heads/main", are not stable.
+func StableBuild(build string) bool {
+	return immutableManifestRef(build)
+}
+
+// cacheGet decodes the JSON value stored for key into v. A nil cache never
+// returns a value.
+func cacheGet(cache Cache, key string, v interface{}) bool {
+	if cache == nil {
+		return false
+	}
+	data, ok := cache.Get(key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Errorf("cacheGet: ignoring malformed cache entry %s: %v", key, err)
+		return false
+	}
+	log.Debugf("Cache hit for %s", key)
+	return true
+}
+
+// cacheSet stores the JSON encoding of v for key. It does nothing if cache
+// is nil.
+func cacheSet(cache Cache, key string, v interface{}) {
+	if cache == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("cacheSet: failed to encode cache entry %s: %v", key, err)
+		return
+	}
+	cache.Set(key, data)
+}
+
+// MemoryCache is a Cache that keeps entries in memory for the lifetime of the
+// process. Once full, the oldest entries are evicted first.
+type MemoryCache struct {
+	mu         sync.RWMutex
+	maxEntries int
+	entries    map[string][]byte
+	order      []string
+}
+
+// NewMemoryCache returns an empty MemoryCache holding at most maxEntries
+// entries. The cache is unbounded if maxEntries is not positive.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{maxEntries: maxEntries, entries: make(map[string][]byte)}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.entries[key]
+	return value, ok
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = value
+	for c.maxEntries > 0 && len(c.order) > c.maxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// DiskCache is a Cache that stores each entry as a file in a directory, so
+// entries are shared between runs of a command line tool.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache storing entries in dir. The directory is
+// created if it does not exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %v", dir, err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Cache.
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("DiskCache: failed to read entry %s: %v", key, err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+// Set implements Cache. Entries are written to a temporary file first so
+// concurrent readers never observe a partial entry.
+func (c *DiskCache) Set(key string, value []byte) {
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		log.Errorf("DiskCache: failed to create entry %s: %v", key, err)
+		return
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		log.Errorf("DiskCache: failed to write entry %s: %v", key, err)
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const cachedSHA = "0123456789abcdef0123456789abcdef01234567"
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+	cache.Set("a", []byte("3"))
+	cache.Set("c", []byte("4"))
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected oldest entry a to be evicted")
+	}
+	for key, expected := range map[string]string{"b": "2", "c": "4"} {
+		if value, ok := cache.Get(key); !ok || string(value) != expected {
+			t.Errorf("expected value %q for key %s, got %q", expected, key, value)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+	if _, ok := cache.Get("log:repo"); ok {
+		t.Errorf("expected empty cache")
+	}
+	cache.Set("log:repo", []byte("commits"))
+	reopened, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+	if value, ok := reopened.Get("log:repo"); !ok || string(value) != "commits" {
+		t.Errorf("expected value %q, got %q", "commits", value)
+	}
+}
+
+func TestCommitsCache(t *testing.T) {
+	cache := NewMemoryCache(0)
+	expected := []*Commit{{SHA: cachedSHA, Subject: "cached", Bugs: []string{}, BugLinks: []*Bug{}}}
+	cacheSet(cache, commitsCacheKey("cos.googlesource.com", "cos/repo", cachedSHA, "", 10, false), cachedCommits{Commits: expected, HasMoreCommits: true})
+
+	// A nil client panics if commits tries to send a Gitiles request,
+	// so the result must come from the cache.
+	out := make(chan commitsResult, 1)
+	commits(context.Background(), commitsRequest{
+		InstanceURL: "cos.googlesource.com",
+		Repo:        "cos/repo",
+		Path:        "src/repo",
+		Committish:  cachedSHA,
+		QuerySize:   10,
+		Cache:       cache,
+		Meter:       noopMeter{},
+		OutputChan:  out,
+	})
+	res := <-out
+	if res.Err != nil {
+		t.Fatalf("expected no error, got %v", res.Err)
+	}
+	if diff := cmp.Diff(expected, res.Commits); diff != "" {
+		t.Errorf("commits returned unexpected commits (-want +got):\n%s", diff)
+	}
+	if !res.HasMoreCommits || res.Path != "src/repo" {
+		t.Errorf("commits returned unexpected metadata: %+v", res)
+	}
+}
+
+func TestImmutableManifestRef(t *testing.T) {
+	tests := map[string]bool{
+		cachedSHA:                         true,
+		"refs/tags/15000.0.0":             true,
+		"refs/tags/dev-weekly/2021-07-01": true,
+		"refs/heads/master":               false,
+	}
+	for ref, expected := range tests {
+		if got := immutableManifestRef(ref); got != expected {
+			t.Errorf("expected immutableManifestRef(%q) to be %v, got %v", ref, expected, got)
+		}
+	}
+}
+
+func TestStableBuild(t *testing.T) {
+	tests := map[string]bool{
+		"15000.0.0":                       true,
+		"cos-rc-85-13310-1034-0":          true,
+		"refs/tags/dev-weekly/2021-07-01": true,
+		"refs/heads/main":                 false,
+	}
+	for build, expected := range tests {
+		if got := StableBuild(build); got != expected {
+			t.Errorf("expected StableBuild(%q) to be %v, got %v", build, expected, got)
+		}
+	}
+}
+
+func TestImmutableCommittish(t *testing.T) {
+	tests := map[string]bool{
+		"":                    true,
+		cachedSHA:             true,
+		"refs/heads/master":   false,
+		"refs/tags/15000.0.0": false,
+	}
+	for committish, expected := range tests {
+		if got := immutableCommittish(committish); got != expected {
+			t.Errorf("expected immutableCommittish(%q) to be %v, got %v", committish, expected, got)
+		}
+	}
+}
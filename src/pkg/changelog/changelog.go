@@ -39,18 +39,22 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
 	"github.com/beevik/etree"
+	"golang.org/x/sync/singleflight"
 
-	log "github.com/sirupsen/logrus"
-	gitilesApi "go.chromium.org/luci/common/api/gitiles"
-	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+	"go.chromium.org/luci/common/proto/git"
 )
 
 var (
 	imageBuildRe = regexp.MustCompile("^cos-(dev-|beta-|stable-|rc-)?\\d+-([\\d-]+)$")
+	commitSHARe  = regexp.MustCompile("^[0-9a-f]{40}$")
+
+	// Messages are written to the Logger set by utils.SetLogger
+	log = utils.Log
 )
 
 type repo struct {
@@ -66,17 +70,33 @@ type repo struct {
 	//   ex. "master-2" or "deadbeef-1".
 	// Source: https://pkg.go.dev/go.chromium.org/luci/common/proto/gitiles?tab=doc#LogRequest
 	Committish string
+	// The branch the repository was tracking when the manifest was created,
+	// ex. "refs/heads/master". Empty if the manifest does not record it.
+	Branch string
 }
 
 type commitsRequest struct {
-	Client      gitilesProto.GitilesClient
+	Client      utils.GitilesService
 	InstanceURL string
 	Path        string
 	Repo        string
 	Committish  string
 	Ancestor    string
 	QuerySize   int
-	OutputChan  chan commitsResult
+	Cache       Cache
+	PageSize    utils.PageSizePolicy
+	Meter       Meter
+	FileChanges bool
+	Pool        *fetchPool
+	// Coalesces identical requests of a changelog, may be nil
+	Flight     *singleflight.Group
+	OutputChan chan commitsResult
+}
+
+// fetchedLog is a page of commits retrieved from Gitiles
+type fetchedLog struct {
+	Commits       []*git.Commit
+	NextPageToken string
 }
 
 type commitsResult struct {
@@ -85,7 +105,11 @@ type commitsResult struct {
 	Repo           string
 	Path           string
 	HasMoreCommits bool
-	Err            utils.ChangelogError
+	// Gitiles token of the page following Commits
+	NextPageToken string
+	// First parent of the last commit, if HasMoreCommits is set
+	ContinuationCommittish string
+	Err                    utils.ChangelogError
 }
 
 type additionsResult struct {
@@ -94,13 +118,37 @@ type additionsResult struct {
 }
 
 // RepoLog contains a changelist for a particular repository
+//
+// The JSON field names are part of the serialized changelog format described
+// by JSONVersion and must not change.
 type RepoLog struct {
-	Commits        []*Commit
-	InstanceURL    string
-	Repo           string
-	SourceSHA      string
-	TargetSHA      string
-	HasMoreCommits bool
+	Commits []*Commit `json:"Commits"`
+	// GoB instance hosting the repository, ex. "cos.googlesource.com"
+	InstanceURL string `json:"InstanceURL"`
+	Repo        string `json:"Repo"`
+	// Committish of the repository in the source and target builds
+	SourceSHA string `json:"SourceSHA"`
+	TargetSHA string `json:"TargetSHA"`
+	// Branch each build's manifest file records for the repository, ex.
+	// "refs/heads/release-R93". Empty if the manifest does not record it.
+	SourceBranch string `json:"SourceBranch,omitempty"`
+	TargetBranch string `json:"TargetBranch,omitempty"`
+	// Path of the repository in the source build, if it differs from the
+	// path the RepoLog is keyed by in the target build
+	SourcePath string `json:"SourcePath,omitempty"`
+	// Set if the changelog was truncated to the requested query size or to
+	// Options.MaxCommitsPerRepo
+	HasMoreCommits bool `json:"HasMoreCommits"`
+	// Committish following the last commit of Commits on its first-parent
+	// history. A changelog from it to SourceSHA continues this one. Only set
+	// if HasMoreCommits is set.
+	ContinuationCommittish string `json:"ContinuationCommittish,omitempty"`
+	// Token passed to ChangelogPage to retrieve the commits following Commits.
+	// Empty if there are no more commits.
+	NextPageToken string `json:"NextPageToken,omitempty"`
+	// Reason the commits of the repository could not be retrieved. Only set
+	// when Options.BestEffort is enabled, in which case Commits is empty.
+	Error string `json:"Error,omitempty"`
 }
 
 // resolveImageName returns the build number associated with an image name.
@@ -124,24 +172,46 @@ func limitPageSize(pageSize, requestedSize int) int {
 	return requestedSize
 }
 
-func gitilesClient(httpClient *http.Client, remoteURL string) (gitilesProto.GitilesClient, utils.ChangelogError) {
+// gitilesClient creates the client used to query a remote. Repositories hosted
+// on GitHub are queried through the GitHub API with opts.GitHubHTTPClient,
+// so the Gerrit credentials of httpClient are never sent to GitHub.
+func gitilesClient(httpClient *http.Client, remoteURL string, opts *Options) (utils.GitilesService, utils.ChangelogError) {
+	if opts != nil && opts.GitilesClient != nil {
+		log.Debugf("Creating custom client for remote url %s\n", remoteURL)
+		cl, err := opts.GitilesClient(remoteURL)
+		if err != nil {
+			log.Errorf("gitilesClient: failed to create client for remote url %s: %v", remoteURL, err)
+			return nil, utils.InternalServerError
+		}
+		if opts.fileChanges() {
+			return treeDiffClient{cl}, nil
+		}
+		return cl, nil
+	}
+	if isGitHubRemote(remoteURL) {
+		log.Debugf("Creating GitHub client for remote url %s\n", remoteURL)
+		return newGitHubClient(opts.githubHTTPClient(), remoteURL), nil
+	}
 	log.Debugf("Creating Gitiles client for remote url %s\n", remoteURL)
-	cl, err := gitilesApi.NewRESTClient(httpClient, remoteURL, true)
+	cl, err := restClient(httpClient, remoteURL, opts)
 	if err != nil {
-		log.Errorf("gitilesClient: failed to create client for remote url %s", remoteURL)
+		log.Errorf("gitilesClient: failed to create client for remote url %s: %v", remoteURL, err)
 		return nil, utils.InternalServerError
 	}
+	if opts.fileChanges() {
+		return treeDiffClient{cl}, nil
+	}
 	return cl, nil
 }
 
-func createGitilesClients(clients map[string]gitilesProto.GitilesClient, httpClient *http.Client, repoMap map[string]*repo) utils.ChangelogError {
+func createGitilesClients(clients map[string]utils.GitilesService, httpClient *http.Client, repoMap map[string]*repo, opts *Options) utils.ChangelogError {
 	log.Debug("Creating additional Gerrit clients for manifest file if not already created")
 	for _, repoData := range repoMap {
 		remoteURL := repoData.InstanceURL
 		if _, ok := clients[remoteURL]; ok {
 			continue
 		}
-		client, err := gitilesClient(httpClient, remoteURL)
+		client, err := gitilesClient(httpClient, remoteURL, opts)
 		if err != nil {
 			return err
 		}
@@ -150,57 +220,208 @@ func createGitilesClients(clients map[string]gitilesProto.GitilesClient, httpCli
 	return nil
 }
 
-// repoMap generates a mapping of repository ID to instance URL and committish.
-// This eliminates the need to track remote names and allows lookup
-// of source committish when generating changelog.
-func repoMap(manifest string) (map[string]*repo, error) {
-	log.Debug("Mapping repository to instance URL and committish")
+// Maximum depth of nested <include> elements in a manifest file
+const maxManifestIncludeDepth = 10
+
+// manifestLoader returns the contents of a manifest file included by another
+// one, where name is relative to the root of the manifest repository.
+type manifestLoader func(name string) (string, error)
+
+// includeDownloadError is returned when an included manifest file cannot be
+// downloaded, as opposed to a manifest file that cannot be parsed.
+type includeDownloadError struct {
+	name string
+	err  error
+}
+
+func (e *includeDownloadError) Error() string {
+	return fmt.Sprintf("failed to download included manifest file %s: %v", e.name, e.err)
+}
+
+func (e *includeDownloadError) Unwrap() error {
+	return e.err
+}
+
+// manifestElements are the elements of a manifest file and every file it
+// includes, as if the included files were inlined.
+type manifestElements struct {
+	remotes  []*etree.Element
+	defaults []*etree.Element
+	projects []*etree.Element
+}
+
+// parseManifestXML parses a manifest file into the root <manifest> element.
+func parseManifestXML(manifest string) (*etree.Element, error) {
 	if manifest == "" {
 		log.Error("repoMap: manifest file is empty")
 		return nil, errors.New("manifest file is empty")
 	}
 	doc := etree.NewDocument()
 	if err := doc.ReadFromString(manifest); err != nil {
-		log.Debug("repoMap: error parsing manifest xml:\n%w", err)
+		log.Debugf("repoMap: error parsing manifest xml:\n%v", err)
 		return nil, errors.New("could not parse XML for manifest file associated with build")
 	}
 	root := doc.SelectElement("manifest")
+	if root == nil {
+		return nil, errors.New("manifest file has no <manifest> element")
+	}
+	return root, nil
+}
+
+// collect adds the elements of root to m, resolving each <include name=X> tag
+// recursively with load. seen holds the files being included, so a file
+// including itself is reported instead of looping forever.
+func (m *manifestElements) collect(root *etree.Element, load manifestLoader, seen map[string]bool, depth int) error {
+	for _, elem := range root.ChildElements() {
+		switch elem.Tag {
+		case "remote":
+			m.remotes = append(m.remotes, elem)
+		case "default":
+			m.defaults = append(m.defaults, elem)
+		case "project":
+			m.projects = append(m.projects, elem)
+		case "include":
+			name := elem.SelectAttrValue("name", "")
+			switch {
+			case name == "":
+				return errors.New("manifest <include> element has no name")
+			case load == nil:
+				return fmt.Errorf("cannot resolve included manifest file %s", name)
+			case seen[name]:
+				return fmt.Errorf("manifest file %s includes itself", name)
+			case depth >= maxManifestIncludeDepth:
+				return fmt.Errorf("manifest file %s is nested more than %d includes deep", name, maxManifestIncludeDepth)
+			}
+			log.Debugf("Resolving included manifest file %s", name)
+			contents, err := load(name)
+			if err != nil {
+				return &includeDownloadError{name: name, err: err}
+			}
+			included, err := parseManifestXML(contents)
+			if err != nil {
+				return fmt.Errorf("failed to parse included manifest file %s: %v", name, err)
+			}
+			seen[name] = true
+			if err := m.collect(included, load, seen, depth+1); err != nil {
+				return err
+			}
+			delete(seen, name)
+		}
+	}
+	return nil
+}
 
+// loadManifestElements parses a manifest file and every file it includes.
+// Manifest files pulled in by <include> tags are retrieved with load;
+// manifests including other files cannot be parsed if load is nil.
+func loadManifestElements(manifest string, load manifestLoader) (*manifestElements, error) {
+	root, err := parseManifestXML(manifest)
+	if err != nil {
+		return nil, err
+	}
+	elements := &manifestElements{}
+	if err := elements.collect(root, load, make(map[string]bool), 0); err != nil {
+		log.Errorf("loadManifestElements: error resolving manifest includes: %v", err)
+		return nil, err
+	}
+	return elements, nil
+}
+
+// repoMap generates a mapping of repository ID to instance URL and committish.
+// This eliminates the need to track remote names and allows lookup
+// of source committish when generating changelog. Manifest files pulled in by
+// <include> tags are retrieved with load; manifests including other files
+// cannot be mapped if load is nil.
+func repoMap(manifest string, load manifestLoader) (map[string]*repo, error) {
+	log.Debug("Mapping repository to instance URL and committish")
+	elements, err := loadManifestElements(manifest, load)
+	if err != nil {
+		return nil, err
+	}
+	return elements.repos(), nil
+}
+
+// repos maps the path of each project of the manifest to its repository.
+func (m *manifestElements) repos() map[string]*repo {
 	// Parse each <remote fetch=X name=Y> tag in the manifest xml file.
 	// Extract the "fetch" and "name" attributes from each remote tag, and map the name to the fetch URL.
 	remoteMap := make(map[string]string)
-	for _, remote := range root.SelectElements("remote") {
-		url := strings.Replace(remote.SelectAttr("fetch").Value, "https://", "", 1)
-		remoteMap[remote.SelectAttr("name").Value] = url
+	for _, remote := range m.remotes {
+		url := strings.Replace(remote.SelectAttrValue("fetch", ""), "https://", "", 1)
+		remoteMap[remote.SelectAttrValue("name", "")] = url
 	}
 
 	// Parse each <project name=X remote=Y revision=Z> tag in the manifest xml file.
 	// Extract the "name", "remote", and "revision" attributes from each project tag.
 	// Some projects do not have a "remote" attribute.
 	// If this is the case, they should use the default remoteURL.
-	if root.SelectElement("default").SelectAttr("remote") != nil {
-		remoteMap[""] = remoteMap[root.SelectElement("default").SelectAttr("remote").Value]
+	// Only one <default> tag is expected across included files; the last one
+	// wins if there are several.
+	defaultBranch := ""
+	for _, def := range m.defaults {
+		if remote := def.SelectAttr("remote"); remote != nil {
+			remoteMap[""] = remoteMap[remote.Value]
+		}
+		defaultBranch = def.SelectAttrValue("revision", defaultBranch)
 	}
+	// Snapshot manifests pin every project to a commit SHA and record the
+	// tracked branch in the "upstream" attribute. Fall back to "dest-branch"
+	// and the default revision for projects that do not set it.
 	repos := make(map[string]*repo)
-	for _, project := range root.SelectElements("project") {
+	for _, project := range m.projects {
 		name, path := project.SelectAttr("name").Value, project.SelectAttrValue("path", "")
+		branch := project.SelectAttrValue("upstream", project.SelectAttrValue("dest-branch", defaultBranch))
 		repos[path] = &repo{
 			Repo:        name,
 			Path:        path,
 			InstanceURL: remoteMap[project.SelectAttrValue("remote", "")],
-			Committish:  project.SelectAttr("revision").Value,
+			Committish:  project.SelectAttrValue("revision", ""),
+			Branch:      branchRef(branch),
+		}
+	}
+	return repos
+}
+
+// branchRef converts a branch name from a manifest file into a fully
+// qualified ref. Returns an empty string if the name is a commit SHA.
+func branchRef(branch string) string {
+	switch {
+	case branch == "" || commitSHARe.MatchString(branch):
+		return ""
+	case strings.HasPrefix(branch, "refs/"):
+		return branch
+	}
+	return "refs/heads/" + branch
+}
+
+// headRepos returns a copy of repos where the committish of every repository
+// is replaced by the branch it tracks. Repositories without a known branch
+// are left out.
+func headRepos(repos map[string]*repo) map[string]*repo {
+	heads := make(map[string]*repo)
+	for path, repoData := range repos {
+		if repoData.Branch == "" {
+			log.Debugf("headRepos: no branch recorded for repo %s, skipping", repoData.Repo)
+			continue
 		}
+		head := *repoData
+		head.Committish = repoData.Branch
+		heads[path] = &head
 	}
-	return repos, nil
+	return heads
 }
 
 // mappedManifest retrieves a Manifest file from GoB and unmarshals XML.
-// Returns a mapping of repository ID to repository data.
-func mappedManifest(client gitilesProto.GitilesClient, repo string, buildInput, buildNum string) (map[string]*repo, utils.ChangelogError) {
+// Returns the elements of the manifest file and the files it includes.
+func mappedManifest(ctx context.Context, client utils.GitilesService, host, repo string, buildInput, buildNum string, opts *Options) (*manifestElements, utils.ChangelogError) {
 	log.Debugf("Retrieving manifest file for build %s\n", buildNum)
-	response, err := utils.DownloadManifest(client, repo, buildNum)
+	ref, fileName := opts.manifestRef(buildNum), opts.manifestFileName()
+	contents, err := manifestFile(ctx, client, host, repo, ref, fileName, opts)
 	if err != nil {
 		log.Errorf("mappedManifest: error downloading manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
+		if ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
 		httpCode := utils.GitilesErrCode(err)
 		if httpCode == "403" {
 			return nil, utils.ForbiddenError
@@ -209,24 +430,128 @@ func mappedManifest(client gitilesProto.GitilesClient, repo string, buildInput,
 		}
 		return nil, utils.InternalServerError
 	}
-	mappedManifest, err := repoMap(response.Contents)
+	// Included files are looked up at the same ref of the manifest repository
+	load := func(name string) (string, error) {
+		return manifestFile(ctx, client, host, repo, ref, name, opts)
+	}
+	return parseManifest(contents, load, repo, buildInput, buildNum)
+}
+
+// manifestFile returns the contents of a manifest file, from the cache if
+// possible. Downloaded files are added to the cache, unless ref is a branch.
+func manifestFile(ctx context.Context, client utils.GitilesService, host, repo, ref, fileName string, opts *Options) (string, error) {
+	var contents string
+	cacheKey := manifestCacheKey(host, repo, ref, fileName)
+	cacheable := immutableManifestRef(ref)
+	if cacheable && meteredCacheGet(opts.cache(), opts.meter(), ManifestLookup, cacheKey, &contents) {
+		return contents, nil
+	}
+	response, err := utils.DownloadManifestFile(ctx, client, repo, ref, fileName)
 	if err != nil {
-		log.Errorf("mappedManifest: error retrieving mapped manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
-		httpCode := utils.GitilesErrCode(err)
-		if httpCode == "404" {
+		return "", err
+	}
+	if cacheable {
+		cacheSet(opts.cache(), cacheKey, response.Contents)
+	}
+	return response.Contents, nil
+}
+
+// parseManifest converts the contents of a Manifest file into its elements,
+// resolving included files with load.
+func parseManifest(contents string, load manifestLoader, repo, buildInput, buildNum string) (*manifestElements, utils.ChangelogError) {
+	elements, err := loadManifestElements(contents, load)
+	if err != nil {
+		log.Errorf("parseManifest: error retrieving mapped manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
+		var downloadErr *includeDownloadError
+		if !errors.As(err, &downloadErr) {
+			return nil, utils.ManifestMalformed(buildInput)
+		}
+		if utils.GitilesErrCode(downloadErr.err) == "404" {
 			return nil, utils.BuildNotFound(buildInput)
 		}
 		return nil, utils.InternalServerError
 	}
-	return mappedManifest, nil
+	return elements, nil
+}
+
+// failed returns the result of a request that could not be completed.
+func (req commitsRequest) failed(err utils.ChangelogError) commitsResult {
+	return commitsResult{
+		InstanceURL: req.InstanceURL,
+		Path:        req.Path,
+		Repo:        req.Repo,
+		Err:         err,
+	}
+}
+
+// fetch retrieves the page of commits of the request from Gitiles. Requests
+// with the same key sharing a Flight wait for a single Gitiles request.
+func (req commitsRequest) fetch(ctx context.Context, key string) (*fetchedLog, error) {
+	fetch := func() (interface{}, error) {
+		start := time.Now()
+		commits, nextPageToken, err := req.PageSize.CommitsPage(ctx, req.Client, req.Repo, req.Committish, req.Ancestor, "", req.QuerySize)
+		switch {
+		case err == nil:
+			req.Meter.ObserveFetch(req.InstanceURL, req.Repo, time.Since(start), nil)
+		case ctx.Err() != nil:
+			req.Meter.ObserveFetch(req.InstanceURL, req.Repo, time.Since(start), utils.TimeoutError)
+		case utils.GitilesErrCode(err) == "404":
+			req.Meter.ObserveFetch(req.InstanceURL, req.Repo, time.Since(start), nil)
+		default:
+			req.Meter.ObserveFetch(req.InstanceURL, req.Repo, time.Since(start), utils.InternalServerError)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &fetchedLog{Commits: commits, NextPageToken: nextPageToken}, nil
+	}
+	if req.Flight == nil {
+		fetched, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		return fetched.(*fetchedLog), nil
+	}
+	fetched, err, shared := req.Flight.Do(key, fetch)
+	if shared {
+		log.Debugf("commits: shared request for repo %s on committish %s", req.Repo, req.Committish)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fetched.(*fetchedLog), nil
 }
 
 // commits get all commits that occur between committish and ancestor for a specific repo.
-func commits(req commitsRequest) {
+func commits(ctx context.Context, req commitsRequest) {
 	log.Debugf("Fetching changelog for repo: %s on committish %s\n", req.Repo, req.Committish)
-	commits, hasMoreCommits, err := utils.Commits(req.Client, req.Repo, req.Committish, req.Ancestor, req.QuerySize)
+	cacheKey := commitsCacheKey(req.InstanceURL, req.Repo, req.Committish, req.Ancestor, req.QuerySize, req.FileChanges)
+	cacheable := immutableCommittish(req.Committish) && immutableCommittish(req.Ancestor)
+	var cached cachedCommits
+	if cacheable && meteredCacheGet(req.Cache, req.Meter, CommitsLookup, cacheKey, &cached) {
+		req.OutputChan <- commitsResult{
+			Commits:                cached.Commits,
+			InstanceURL:            req.InstanceURL,
+			Path:                   req.Path,
+			Repo:                   req.Repo,
+			HasMoreCommits:         cached.HasMoreCommits,
+			NextPageToken:          cached.NextPageToken,
+			ContinuationCommittish: cached.ContinuationCommittish,
+		}
+		return
+	}
+	if req.Pool != nil {
+		ctx = req.Pool.scheduled(ctx)
+	}
+	fetched, err := req.fetch(ctx, cacheKey)
 	if err != nil {
-		if utils.GitilesErrCode(err) == "404" {
+		if errors.Is(err, utils.ErrNotScheduled) && ctx.Err() == nil {
+			log.Errorf("commits: request for repo %s was not sent before its deadline:\n%v", req.Repo, err)
+			req.OutputChan <- req.failed(utils.TimeoutError)
+		} else if ctx.Err() != nil {
+			log.Errorf("commits: request for repo %s was cancelled:\n%v", req.Repo, err)
+			req.OutputChan <- req.failed(utils.TimeoutError)
+		} else if utils.GitilesErrCode(err) == "404" {
 			req.OutputChan <- commitsResult{
 				InstanceURL: req.InstanceURL,
 				Path:        req.Path,
@@ -234,40 +559,116 @@ func commits(req commitsRequest) {
 			}
 		} else {
 			log.Errorf("commits: error retrieving commit changelog on repo %s from commit %s to commit %s:\n%v", req.Repo, req.Committish, req.Ancestor, err)
-			req.OutputChan <- commitsResult{Err: utils.InternalServerError}
+			req.OutputChan <- req.failed(utils.RepoLogUnavailable(req.Repo))
 		}
 		return
 	}
+	commits, nextPageToken := fetched.Commits, fetched.NextPageToken
 	if commits == nil {
 		log.Info(req.Repo, req.Committish, req.Ancestor)
 	}
+	// Parsing creates new Commit objects, so a log shared by coalesced
+	// requests can be modified by each of them
 	parsedCommits, err := ParseGitCommitLog(commits)
 	if err != nil {
 		log.Errorf("commits: error parsing Gitiles commits response\n%v", err)
-		req.OutputChan <- commitsResult{Err: utils.InternalServerError}
+		req.OutputChan <- req.failed(utils.RepoLogUnavailable(req.Repo))
 		return
 	}
+	continuation := ""
+	if nextPageToken != "" {
+		continuation = continuationCommittish(commits)
+	}
+	if cacheable {
+		cacheSet(req.Cache, cacheKey, cachedCommits{
+			Commits:                parsedCommits,
+			HasMoreCommits:         nextPageToken != "",
+			NextPageToken:          nextPageToken,
+			ContinuationCommittish: continuation,
+		})
+	}
 	req.OutputChan <- commitsResult{
-		Commits:        parsedCommits,
-		InstanceURL:    req.InstanceURL,
-		Path:           req.Path,
-		Repo:           req.Repo,
-		HasMoreCommits: hasMoreCommits,
+		Commits:                parsedCommits,
+		InstanceURL:            req.InstanceURL,
+		Path:                   req.Path,
+		Repo:                   req.Repo,
+		HasMoreCommits:         nextPageToken != "",
+		NextPageToken:          nextPageToken,
+		ContinuationCommittish: continuation,
+	}
+}
+
+// continuationCommittish returns the first parent of the last commit of a
+// truncated log, or an empty string if it is unknown.
+func continuationCommittish(commits []*git.Commit) string {
+	if len(commits) == 0 || len(commits[len(commits)-1].Parents) == 0 {
+		return ""
 	}
+	return commits[len(commits)-1].Parents[0]
+}
+
+// matchSourceRepos maps the path of each target repository to the same
+// repository in the source build. Repositories are matched by path, or by
+// name when a repository moved to a new path, ex. when the kernel checked out
+// at src/third_party/kernel/v5.10 in a milestone is checked out at
+// src/third_party/kernel/v5.15 in the next one. A moved repository is only
+// matched if a single candidate exists. Target repositories missing from the
+// source build are left out.
+func matchSourceRepos(sourceRepos, targetRepos map[string]*repo) map[string]*repo {
+	moved := make(map[string][]*repo)
+	for path, sourceRepo := range sourceRepos {
+		if _, ok := targetRepos[path]; !ok {
+			key := sourceRepo.InstanceURL + "/" + sourceRepo.Repo
+			moved[key] = append(moved[key], sourceRepo)
+		}
+	}
+	matched := make(map[string]*repo)
+	for path, targetRepo := range targetRepos {
+		if sourceRepo, ok := sourceRepos[path]; ok {
+			matched[path] = sourceRepo
+			continue
+		}
+		candidates := moved[targetRepo.InstanceURL+"/"+targetRepo.Repo]
+		if len(candidates) == 1 {
+			log.Debugf("matchSourceRepos: repo %s moved from %s to %s", targetRepo.Repo, candidates[0].Path, path)
+			matched[path] = candidates[0]
+		}
+	}
+	return matched
+}
+
+// newRepoLog creates the RepoLog of a commits request without its commits.
+// sourceRepo is nil if the repository is not in the source build.
+func newRepoLog(res commitsResult, sourceRepo, targetRepo *repo) *RepoLog {
+	repoLog := &RepoLog{
+		InstanceURL:  res.InstanceURL,
+		Repo:         res.Repo,
+		TargetSHA:    targetRepo.Committish,
+		TargetBranch: targetRepo.Branch,
+	}
+	if sourceRepo != nil {
+		repoLog.SourceSHA = sourceRepo.Committish
+		repoLog.SourceBranch = sourceRepo.Branch
+		if sourceRepo.Path != targetRepo.Path {
+			repoLog.SourcePath = sourceRepo.Path
+		}
+	}
+	return repoLog
 }
 
 // additions retrieves all commits that occured between 2 parsed manifest files for each repo.
 // Returns a map of repo name -> list of commits.
-func additions(clients map[string]gitilesProto.GitilesClient, sourceRepos map[string]*repo, targetRepos map[string]*repo, querySize int, outputChan chan additionsResult) {
+func additions(ctx context.Context, clients map[string]utils.GitilesService, sourceRepos map[string]*repo, targetRepos map[string]*repo, querySize int, opts *Options, pool *fetchPool, flight *singleflight.Group, outputChan chan additionsResult) {
 	log.Debug("Retrieving commit additions")
 	repoCommits := make(map[string]*RepoLog)
 	commitsChan := make(chan commitsResult, len(targetRepos))
+	matchedRepos := matchSourceRepos(sourceRepos, targetRepos)
 	for repoID, targetRepoInfo := range targetRepos {
 		cl := clients[targetRepoInfo.InstanceURL]
 		// If the source Manifest file does not contain a target repo,
 		// count every commit since target repo creation as an addition
 		ancestorCommittish := ""
-		if sourceRepoInfo, ok := sourceRepos[repoID]; ok {
+		if sourceRepoInfo, ok := matchedRepos[repoID]; ok {
 			ancestorCommittish = sourceRepoInfo.Committish
 		}
 		commitsReq := commitsRequest{
@@ -278,42 +679,47 @@ func additions(clients map[string]gitilesProto.GitilesClient, sourceRepos map[st
 			Committish:  targetRepoInfo.Committish,
 			Ancestor:    ancestorCommittish,
 			QuerySize:   querySize,
+			Cache:       opts.cache(),
+			PageSize:    opts.pageSizePolicy(),
+			Meter:       opts.meter(),
+			FileChanges: opts.fileChanges(),
+			Pool:        pool,
+			Flight:      flight,
 			OutputChan:  commitsChan,
 		}
-		go commits(commitsReq)
+		pool.submit(func() { commits(ctx, commitsReq) })
 	}
 	for i := 0; i < len(targetRepos); i++ {
 		res := <-commitsChan
-		if res.Err != nil {
+		if res.Err != nil && !opts.bestEffort() {
 			outputChan <- additionsResult{Err: res.Err}
 			return
 		}
-		var sourceSHA string
-		if sourceData, ok := sourceRepos[res.Path]; ok {
-			sourceSHA = sourceData.Committish
-		}
-		if len(res.Commits) > 0 {
-			repoCommits[res.Path] = &RepoLog{
-				Commits:        res.Commits,
-				HasMoreCommits: res.HasMoreCommits,
-				InstanceURL:    res.InstanceURL,
-				Repo:           res.Repo,
-				SourceSHA:      sourceSHA,
-				TargetSHA:      targetRepos[res.Path].Committish,
+		if res.Err != nil {
+			repoLog := newRepoLog(res, matchedRepos[res.Path], targetRepos[res.Path])
+			repoLog.Error = res.Err.Error()
+			repoCommits[res.Path] = repoLog
+		} else if len(res.Commits) > 0 {
+			repoLog := newRepoLog(res, matchedRepos[res.Path], targetRepos[res.Path])
+			repoLog.Commits = res.Commits
+			repoLog.HasMoreCommits = res.HasMoreCommits
+			repoLog.ContinuationCommittish = res.ContinuationCommittish
+			if res.NextPageToken != "" {
+				repoLog.NextPageToken = newPageToken(repoLog, res.NextPageToken).encode(opts.pageTokenKey())
 			}
+			repoCommits[res.Path] = repoLog
 		}
 	}
 	outputChan <- additionsResult{Additions: repoCommits}
 }
 
-// getSysctlDiff finds sysctl difference between the two builds.
+// GetSysctlDiff finds sysctl difference between the two builds.
 // Returns a list of change lists:[[name, old-value, new-value], ...]
-func GetSysctlDiff(bucket, sourceBoard, sourceMilestone, source, targetBoard, targetMilestone, target string) (
+func GetSysctlDiff(ctx context.Context, bucket, sourceBoard, sourceMilestone, source, targetBoard, targetMilestone, target string) (
 	[][]string, bool, bool) {
 	sourceBuildNum, targetBuildNum := resolveImageName(source), resolveImageName(target)
 	sourceChan := make(chan map[string]string)
 	targetChan := make(chan map[string]string)
-	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
 		log.Errorf("failed to create storage client (error: %s)", err)
@@ -415,11 +821,25 @@ func fetchSysctlToMap(path string, outputChan chan map[string]string, client *st
 
 // Changelog generates a changelog between 2 build numbers
 //
-// httpClient is a authorized http.Client object with Gerrit scope.
+// ctx bounds every Gitiles request made while generating the changelog. If
+// ctx is cancelled or its deadline passes, a TimeoutError is returned.
+//
+// httpClient is a authorized http.Client object with Gerrit scope. It may be
+// nil if opts.GitilesClient is set, or if every repository is hosted on a
+// public instance listed by opts.PublicInstances, which is then queried
+// anonymously.
 //
 // sourceBuildNum and targetBuildNum should be build numbers. It should match
 // a tag that links directly to snapshot.xml
 // Ex. For /refs/tags/15049.0.0, the argument should be 15049.0.0
+// The tag prefix and manifest file name can be changed through opts.
+// A full commit SHA of the manifest repository can be used instead of a
+// build number to refer to an untagged snapshot, such as an intermediate CI
+// build.
+// The builds can belong to different milestones. Repositories are compared by
+// commit, so they can track different release branches in each build, and a
+// repository checked out at a different path in each build is still compared
+// with itself. The branch of each build is recorded in the RepoLog.
 //
 // host should be the GoB instance that Manifest files are hosted in
 // ex. "cos.googlesource.com"
@@ -430,59 +850,190 @@ func fetchSysctlToMap(path string, outputChan chan map[string]string, client *st
 // querySize should be the number of commits that should be included in each
 // repository changelog. Specify as -1 to get all commits
 //
+// opts holds optional settings such as repository filters and may be nil
+//
 // Outputs two changelogs
 // The first changelog contains new commits that were added to the target
 // build starting from the source build number
 //
 // The second changelog contains all commits that are present in the source build
 // but not present in the target build
-func Changelog(httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int) (map[string]*RepoLog, map[string]*RepoLog, utils.ChangelogError) {
-	if httpClient == nil {
-		log.Error("httpClient is nil")
-		return nil, nil, utils.InternalServerError
+func Changelog(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int, opts *Options) (added map[string]*RepoLog, removed map[string]*RepoLog, err utils.ChangelogError) {
+	defer func(start time.Time) {
+		opts.meter().ObserveChangelog(time.Since(start), err)
+	}(time.Now())
+	doc, err := changelogDocument(ctx, httpClient, source, target, host, repo, croslandURL, querySize, opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc.Additions, doc.Removals, nil
+}
+
+// ChangelogWithBuildInfo generates a changelog like Changelog, and also
+// returns the BuildInfo of the source and target builds so the changelog can
+// be labelled without separate lookups. Retrieving build metadata costs an
+// extra Gitiles request per build. The returned Document also lists the
+// repositories added to or removed from the manifest file between the builds,
+// and the changes to its <default> and <remote> elements.
+func ChangelogWithBuildInfo(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int, opts *Options) (doc *Document, err utils.ChangelogError) {
+	defer func(start time.Time) {
+		opts.meter().ObserveChangelog(time.Since(start), err)
+	}(time.Now())
+	return changelogDocument(ctx, httpClient, source, target, host, repo, croslandURL, querySize, opts, true)
+}
+
+// changelogDocument generates the changelog returned by Changelog, the
+// repositories and manifest settings that changed between the builds, and the
+// BuildInfo of both builds if withBuildInfo is set.
+func changelogDocument(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int, opts *Options, withBuildInfo bool) (*Document, utils.ChangelogError) {
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
+	ctx = opts.retryContext(ctx)
+	querySize = opts.querySize(querySize)
 	sourceBuildNum, targetBuildNum := resolveImageName(source), resolveImageName(target)
 	log.Infof("Retrieving changelog between %s and %s\n", sourceBuildNum, targetBuildNum)
-	clients := make(map[string]gitilesProto.GitilesClient)
+	clients := make(map[string]utils.GitilesService)
 
 	// Since the manifest file is always in the cos instance, add cos client
 	// so that client knows what URL to use
-	manifestClient, err := gitilesClient(httpClient, host)
+	manifestClient, err := gitilesClient(httpClient, host, opts)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	sourceRepos, sourceErr := mappedManifest(manifestClient, repo, source, sourceBuildNum)
-	targetRepos, targetErr := mappedManifest(manifestClient, repo, target, targetBuildNum)
+	sourceManifest, sourceErr := mappedManifest(ctx, manifestClient, host, repo, source, sourceBuildNum, opts)
+	targetManifest, targetErr := mappedManifest(ctx, manifestClient, host, repo, target, targetBuildNum, opts)
 	if sourceErr != nil && sourceErr.HTTPCode() == "404" && targetErr != nil && targetErr.HTTPCode() == "404" {
-		return nil, nil, utils.BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuildNum)
+		return nil, utils.BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuildNum)
 	} else if sourceErr != nil {
-		return nil, nil, sourceErr
+		return nil, sourceErr
 	} else if targetErr != nil {
-		return nil, nil, targetErr
+		return nil, targetErr
+	}
+	doc := &Document{Version: JSONVersion, Source: source, Target: target}
+	if withBuildInfo {
+		if doc.SourceBuild, err = buildInfo(ctx, manifestClient, host, repo, sourceBuildNum, opts); err != nil {
+			return nil, err
+		}
+		if doc.TargetBuild, err = buildInfo(ctx, manifestClient, host, repo, targetBuildNum, opts); err != nil {
+			return nil, err
+		}
 	}
+	sourceRepos, targetRepos := sourceManifest.repos(), targetManifest.repos()
+	opts.filterRepos(sourceRepos)
+	opts.filterRepos(targetRepos)
+	// Remotes are compared before remapping, which would hide the
+	// instances recorded in the manifest files.
+	doc.ManifestChanges = manifestChanges(sourceManifest, targetManifest, sourceRepos, targetRepos)
+	opts.remapRepos(sourceRepos)
+	opts.remapRepos(targetRepos)
+	doc.AddedRepos, doc.RemovedRepos = repoChanges(sourceRepos, targetRepos)
 
 	clients[host] = manifestClient
-	err = createGitilesClients(clients, httpClient, sourceRepos)
+	err = createGitilesClients(clients, httpClient, sourceRepos, opts)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	err = createGitilesClients(clients, httpClient, targetRepos)
+	err = createGitilesClients(clients, httpClient, targetRepos, opts)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	// Requests still queued in the pool are cancelled once the changelog
+	// is returned, so an error does not wait for every other repository.
+	pool, release := acquirePool(opts)
+	defer release()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	addChan := make(chan additionsResult, 1)
 	missChan := make(chan additionsResult, 1)
-	go additions(clients, sourceRepos, targetRepos, querySize, addChan)
-	go additions(clients, targetRepos, sourceRepos, querySize, missChan)
-	missRes := <-missChan
+	// Both directions may request the same logs, ex. for a repository checked
+	// out at several paths, so identical requests are only sent once.
+	flight := &singleflight.Group{}
+	go additions(ctx, clients, sourceRepos, targetRepos, querySize, opts, pool, flight, addChan)
+	go additions(ctx, clients, targetRepos, sourceRepos, querySize, opts, pool, flight, missChan)
+	// Both results are received before returning since the pool must not be
+	// closed while additions is still submitting requests to it.
+	missRes, addRes := <-missChan, <-addChan
 	if missRes.Err != nil {
-		return nil, nil, missRes.Err
+		return nil, missRes.Err
 	}
-	addRes := <-addChan
 	if addRes.Err != nil {
-		return nil, nil, addRes.Err
+		return nil, addRes.Err
+	}
+	if opts.deduplicateChanges() {
+		dedupChanges(addRes.Additions)
+		dedupChanges(missRes.Additions)
+	}
+	markCherryPicks(addRes.Additions, missRes.Additions)
+	if opts.excludeCherryPicks() {
+		removeCherryPicks(addRes.Additions)
+		removeCherryPicks(missRes.Additions)
 	}
+	if opts.resolveCLs() {
+		resolveCLs(ctx, httpClient, opts, pool, addRes.Additions, missRes.Additions)
+	}
+
+	doc.Additions, doc.Removals = addRes.Additions, missRes.Additions
+	return doc, nil
+}
 
-	return addRes.Additions, missRes.Additions, nil
+// ChangelogToHead generates a changelog between a build and the current tip of
+// the branch each of its repositories tracks. It can be used to see what has
+// landed since a build before the next build is tagged.
+//
+// The arguments have the same meaning as for Changelog. Repositories whose
+// branch is not recorded in the build's manifest file are left out.
+//
+// Outputs a changelog containing the commits that were added to each branch
+// after the source build. The TargetSHA of each RepoLog is the branch ref.
+func ChangelogToHead(ctx context.Context, httpClient *http.Client, source, host, repo string, querySize int, opts *Options) (changes map[string]*RepoLog, err utils.ChangelogError) {
+	defer func(start time.Time) {
+		opts.meter().ObserveChangelog(time.Since(start), err)
+	}(time.Now())
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	ctx = opts.retryContext(ctx)
+	querySize = opts.querySize(querySize)
+	sourceBuildNum := resolveImageName(source)
+	log.Infof("Retrieving changelog between %s and HEAD\n", sourceBuildNum)
+	clients := make(map[string]utils.GitilesService)
+
+	manifestClient, err := gitilesClient(httpClient, host, opts)
+	if err != nil {
+		return nil, err
+	}
+	sourceManifest, err := mappedManifest(ctx, manifestClient, host, repo, source, sourceBuildNum, opts)
+	if err != nil {
+		return nil, err
+	}
+	sourceRepos := sourceManifest.repos()
+	opts.filterRepos(sourceRepos)
+	opts.remapRepos(sourceRepos)
+	targetRepos := headRepos(sourceRepos)
+
+	clients[host] = manifestClient
+	err = createGitilesClients(clients, httpClient, targetRepos, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, release := acquirePool(opts)
+	defer release()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	addChan := make(chan additionsResult, 1)
+	go additions(ctx, clients, sourceRepos, targetRepos, querySize, opts, pool, &singleflight.Group{}, addChan)
+	addRes := <-addChan
+	if addRes.Err != nil {
+		return nil, addRes.Err
+	}
+	if opts.deduplicateChanges() {
+		dedupChanges(addRes.Additions)
+	}
+	if opts.resolveCLs() {
+		resolveCLs(ctx, httpClient, opts, pool, addRes.Additions)
+	}
+	return addRes.Additions, nil
 }
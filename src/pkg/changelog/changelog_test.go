@@ -16,13 +16,25 @@ package changelog
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
 	"go.chromium.org/luci/common/api/gerrit"
+	"go.chromium.org/luci/common/proto/git"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const cosInstance = "cos.googlesource.com"
@@ -64,7 +76,7 @@ func TestChangelog(t *testing.T) {
 	httpClient, _ := getHTTPClient()
 
 	// Test invalid source
-	additions, removals, err := Changelog(httpClient, "15", "15043.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err := Changelog(context.Background(), httpClient, "15", "15043.0.0", cosInstance, defaultManifestRepo, "", -1, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -76,7 +88,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid target
-	additions, removals, err = Changelog(httpClient, "15043.0.0", "abx", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15043.0.0", "abx", cosInstance, defaultManifestRepo, "", -1, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -88,7 +100,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid instance
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "15041.0.0", "com", defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "15041.0.0", "com", defaultManifestRepo, "", -1, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -100,7 +112,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid manifest repo
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "15041.0.0", cosInstance, "cos/not-a-repo", "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "15041.0.0", cosInstance, "cos/not-a-repo", "", -1, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -112,7 +124,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test build number higher than latest release
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "99999.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "99999.0.0", cosInstance, defaultManifestRepo, "", -1, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -124,7 +136,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test manifest with remote urls specified and no default URL
-	additions, removals, err = Changelog(httpClient, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1, nil)
 	if additions == nil {
 		t.Errorf("changelog failed, expected additions, got nil")
 	} else if removals == nil {
@@ -248,7 +260,7 @@ func TestChangelog(t *testing.T) {
 		"9bc12bb411f357188d008864f80dfba43210b9d8",
 		"bf0dd3757826b9bc9d7082f5f749ff7615d4bcb3",
 	}
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", -1, nil)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if len(removals) != 0 {
@@ -292,7 +304,7 @@ func TestChangelog(t *testing.T) {
 		"src/platform2",
 		"src/third_party/chromiumos-overlay",
 	}
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", -1, nil)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	}
@@ -306,7 +318,7 @@ func TestChangelog(t *testing.T) {
 	source = "15030.0.0"
 	target = "15050.0.0"
 	querySize := 50
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize, nil)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if additions == nil {
@@ -332,7 +344,7 @@ func TestChangelog(t *testing.T) {
 	// Test changelog handles manifest with non-matching repositories
 	source = "12871.1177.0"
 	target = "12871.1179.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize, nil)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if len(removals) != 0 {
@@ -357,7 +369,7 @@ func TestChangelog(t *testing.T) {
 	// Test with different release branches
 	source = "13310.1035.0"
 	target = "15000.0.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize, nil)
 	if err != nil {
 		t.Errorf("Changelog failed, expected no error, got %v", err)
 	} else if len(additions) != 0 {
@@ -369,7 +381,7 @@ func TestChangelog(t *testing.T) {
 	// Test empty repository
 	source = "0.0.0"
 	target = "2.0.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize, nil)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -383,7 +395,7 @@ func TestChangelog(t *testing.T) {
 	// Test image name
 	source = "cos-rc-85-13310-1034-0"
 	target = "cos-rc-85-13310-1030-0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize, nil)
 	if err != nil {
 		t.Errorf("Changelog failed, expected no error, got %v", err)
 	} else if len(additions) != 0 {
@@ -392,3 +404,469 @@ func TestChangelog(t *testing.T) {
 		t.Errorf("Changelog failed, expected non-empty removals, got %v", removals)
 	}
 }
+
+// fakeManifest returns a manifest file pinning the kernel and overlays
+// repositories to the given commits.
+func fakeManifest(kernelSHA, overlaysSHA string) string {
+	return `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="` + kernelSHA + `"/>
+  <project name="cos/overlays/board-overlays" path="src/overlays" revision="` + overlaysSHA + `"/>
+</manifest>`
+}
+
+func TestChangelogFakeGitiles(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1", Message: "Initial kernel"},
+		{Id: "k2", Parents: []string{"k1"}, Message: "Fix GPU reset"},
+		{Id: "k3", Parents: []string{"k2"}, Message: "Bump version"},
+	}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{
+		{Id: "o1", Message: "Initial overlays"},
+		{Id: "o2", Parents: []string{"o1"}, Message: "Revert driver update"},
+	}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = fakeManifest("k1", "o2")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = fakeManifest("k3", "o1")
+	opts := &Options{GitilesClient: func(string) (utils.GitilesService, error) { return g, nil }}
+
+	additions, removals, err := Changelog(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	if !commitsMatch(additions["src/third_party/kernel"].Commits, []string{"k3", "k2"}) {
+		t.Errorf("expected kernel additions k3 and k2, got %v", additions["src/third_party/kernel"].Commits)
+	}
+	if _, ok := additions["src/overlays"]; ok {
+		t.Errorf("expected no overlays additions, got %v", additions["src/overlays"].Commits)
+	}
+	if !commitsMatch(removals["src/overlays"].Commits, []string{"o2"}) {
+		t.Errorf("expected overlays removal o2, got %v", removals["src/overlays"].Commits)
+	}
+
+	_, _, err = Changelog(context.Background(), nil, "1.0.0", "3.0.0", cosInstance, defaultManifestRepo, "", 10, opts)
+	if err == nil || err.HTTPCode() != "404" {
+		t.Errorf("expected a 404 error for a missing build, got %v", err)
+	}
+}
+
+func TestChangelogFullRefs(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1"},
+		{Id: "k2", Parents: []string{"k1"}},
+		{Id: "k3", Parents: []string{"k2"}},
+	}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{{Id: "o1"}}
+	source := fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/dev-weekly/2021-07-01", Path: "snapshot.xml"}
+	target := fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/heads/main", Path: "snapshot.xml"}
+	g.Files[source] = fakeManifest("k1", "o1")
+	g.Files[target] = fakeManifest("k2", "o1")
+	opts := &Options{
+		TagPrefix:     "refs/tags/lakitu-release/",
+		Cache:         NewMemoryCache(100),
+		GitilesClient: func(string) (utils.GitilesService, error) { return g, nil },
+	}
+
+	additions, _, err := Changelog(context.Background(), nil, source.Committish, target.Committish, cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	if !commitsMatch(additions["src/third_party/kernel"].Commits, []string{"k2"}) {
+		t.Errorf("expected kernel addition k2, got %v", additions["src/third_party/kernel"].Commits)
+	}
+	// Branches move, so their manifest file must not be served from the cache
+	g.Files[target] = fakeManifest("k3", "o1")
+	additions, _, err = Changelog(context.Background(), nil, source.Committish, target.Committish, cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	if !commitsMatch(additions["src/third_party/kernel"].Commits, []string{"k3", "k2"}) {
+		t.Errorf("expected kernel additions k3 and k2 after the branch moved, got %v", additions["src/third_party/kernel"].Commits)
+	}
+}
+
+// blockingLog is a Gitiles service whose log requests wait for release to be
+// closed. Each request is counted and signalled on started.
+type blockingLog struct {
+	*fakes.Gitiles
+	calls   *int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingLog) Log(ctx context.Context, req *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	atomic.AddInt32(b.calls, 1)
+	b.started <- struct{}{}
+	<-b.release
+	return b.Gitiles.Log(ctx, req, opts...)
+}
+
+func TestCommitsFlight(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{{Id: "k1"}, {Id: "k2", Parents: []string{"k1"}}}
+	var calls int32
+	client := blockingLog{Gitiles: g, calls: &calls, started: make(chan struct{}, 2), release: make(chan struct{})}
+	flight := &singleflight.Group{}
+	out := make(chan commitsResult, 2)
+	for _, path := range []string{"src/third_party/kernel", "src/kernel"} {
+		req := commitsRequest{
+			Client:      client,
+			InstanceURL: cosInstance,
+			Repo:        "third_party/kernel",
+			Path:        path,
+			Committish:  "k2",
+			Ancestor:    "k1",
+			QuerySize:   10,
+			PageSize:    utils.DefaultPageSizePolicy,
+			Meter:       noopMeter{},
+			Flight:      flight,
+			OutputChan:  out,
+		}
+		go commits(context.Background(), req)
+		if path == "src/third_party/kernel" {
+			// Wait for the first request to be sent so the second one
+			// is coalesced with it
+			<-client.started
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(client.release)
+	first, second := <-out, <-out
+	if calls != 1 {
+		t.Errorf("expected 1 Gitiles request, got %d", calls)
+	}
+	for _, res := range []commitsResult{first, second} {
+		if res.Err != nil || !commitsMatch(res.Commits, []string{"k2"}) {
+			t.Errorf("expected commit k2 for %s, got %v (error: %v)", res.Path, res.Commits, res.Err)
+		}
+	}
+	if first.Commits[0] == second.Commits[0] {
+		t.Errorf("expected coalesced requests to return distinct Commit objects")
+	}
+}
+
+func TestChangelogMaxCommitsPerRepo(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1"},
+		{Id: "k2", Parents: []string{"k1"}},
+		{Id: "k3", Parents: []string{"k2"}},
+		{Id: "k4", Parents: []string{"k3"}},
+		{Id: "k5", Parents: []string{"k4"}},
+	}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{{Id: "o1"}, {Id: "o2", Parents: []string{"o1"}}}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = fakeManifest("k1", "o1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = fakeManifest("k5", "o2")
+	opts := &Options{
+		MaxCommitsPerRepo: 2,
+		GitilesClient:     func(string) (utils.GitilesService, error) { return g, nil },
+	}
+
+	additions, _, err := Changelog(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1, opts)
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	kernel := additions["src/third_party/kernel"]
+	if !commitsMatch(kernel.Commits, []string{"k5", "k4"}) {
+		t.Errorf("expected kernel additions k5 and k4, got %v", kernel.Commits)
+	}
+	if !kernel.HasMoreCommits || kernel.ContinuationCommittish != "k3" {
+		t.Errorf("expected truncated kernel changelog continuing from k3, got HasMoreCommits %v and continuation %q", kernel.HasMoreCommits, kernel.ContinuationCommittish)
+	}
+	overlays := additions["src/overlays"]
+	if overlays.HasMoreCommits || overlays.ContinuationCommittish != "" {
+		t.Errorf("expected complete overlays changelog, got HasMoreCommits %v and continuation %q", overlays.HasMoreCommits, overlays.ContinuationCommittish)
+	}
+}
+
+// unavailableLog is a Gitiles service whose log requests always fail.
+type unavailableLog struct {
+	*fakes.Gitiles
+}
+
+func (unavailableLog) Log(context.Context, *gitilesProto.LogRequest, ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	return nil, status.Error(codes.Internal, "backend failure")
+}
+
+func TestChangelogSentinelErrors(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{{Id: "k1"}, {Id: "k2", Parents: []string{"k1"}}}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{{Id: "o1"}}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = fakeManifest("k1", "o1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = fakeManifest("k2", "o1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/3.0.0", Path: "snapshot.xml"}] = "<manifest><project"
+	tests := map[string]struct {
+		client       utils.GitilesService
+		target       string
+		expectedKind error
+	}{
+		"Build Not Found": {
+			client:       g,
+			target:       "4.0.0",
+			expectedKind: utils.ErrBuildNotFound,
+		},
+		"Manifest Malformed": {
+			client:       g,
+			target:       "3.0.0",
+			expectedKind: utils.ErrManifestMalformed,
+		},
+		"Repository Log Unavailable": {
+			client:       unavailableLog{g},
+			target:       "2.0.0",
+			expectedKind: utils.ErrRepoLogUnavailable,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{GitilesClient: func(string) (utils.GitilesService, error) { return test.client, nil }}
+			_, _, err := Changelog(context.Background(), nil, "1.0.0", test.target, cosInstance, defaultManifestRepo, "", 10, opts)
+			if err == nil {
+				t.Fatalf("expected error %v, got nil", test.expectedKind)
+			}
+			if !errors.Is(err, test.expectedKind) {
+				t.Errorf("expected error %v, got %v", test.expectedKind, err)
+			}
+		})
+	}
+}
+
+func TestRepoMapBranch(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays/board-overlays" path="src/overlays" revision="0123456789abcdef0123456789abcdef01234567" upstream="refs/heads/release-R85"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="0123456789abcdef0123456789abcdef01234567" dest-branch="cos-5.4"/>
+  <project name="cos/platform/dev" path="src/platform/dev" revision="0123456789abcdef0123456789abcdef01234567"/>
+  <project name="cos/pinned" path="src/pinned" revision="0123456789abcdef0123456789abcdef01234567" upstream="89abcdef0123456789abcdef0123456789abcdef"/>
+</manifest>`
+	repos, err := repoMap(manifest, nil)
+	if err != nil {
+		t.Fatalf("repoMap failed: %v", err)
+	}
+	tests := map[string]string{
+		"src/overlays":           "refs/heads/release-R85",
+		"src/third_party/kernel": "refs/heads/cos-5.4",
+		"src/platform/dev":       "refs/heads/master",
+		"src/pinned":             "",
+	}
+	for path, expectedBranch := range tests {
+		if repos[path].Branch != expectedBranch {
+			t.Errorf("repoMap failed, expected branch %q for %s, got %q", expectedBranch, path, repos[path].Branch)
+		}
+	}
+
+	heads := headRepos(repos)
+	if _, ok := heads["src/pinned"]; ok {
+		t.Errorf("headRepos failed, expected src/pinned to be skipped")
+	}
+	if heads["src/overlays"].Committish != "refs/heads/release-R85" {
+		t.Errorf("headRepos failed, expected committish refs/heads/release-R85, got %s", heads["src/overlays"].Committish)
+	}
+	if repos["src/overlays"].Committish != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("headRepos failed, source repos were modified")
+	}
+}
+
+func TestRepoMapInclude(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	files := map[string]string{
+		"remotes.xml": `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <remote fetch="https://chromium.googlesource.com" name="cros"/>
+  <default remote="cos" revision="refs/heads/master"/>
+</manifest>`,
+		"kernel.xml": `<manifest>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="` + sha + `"/>
+  <include name="nested.xml"/>
+</manifest>`,
+		"nested.xml": `<manifest>
+  <project name="chromiumos/platform/crosutils" path="src/scripts" remote="cros" revision="` + sha + `"/>
+</manifest>`,
+		"loop.xml": `<manifest>
+  <include name="loop.xml"/>
+</manifest>`,
+	}
+	load := func(name string) (string, error) {
+		contents, ok := files[name]
+		if !ok {
+			return "", fmt.Errorf("file %s not found", name)
+		}
+		return contents, nil
+	}
+	tests := map[string]struct {
+		manifest      string
+		load          manifestLoader
+		expectedRepos map[string]*repo
+		expectedErr   bool
+	}{
+		"Nested Includes": {
+			manifest: `<manifest>
+  <include name="remotes.xml"/>
+  <project name="cos/overlays/board-overlays" path="src/overlays" revision="` + sha + `"/>
+  <include name="kernel.xml"/>
+</manifest>`,
+			load: load,
+			expectedRepos: map[string]*repo{
+				"src/overlays":           {Repo: "cos/overlays/board-overlays", Path: "src/overlays", InstanceURL: "cos.googlesource.com", Committish: sha, Branch: "refs/heads/master"},
+				"src/third_party/kernel": {Repo: "third_party/kernel", Path: "src/third_party/kernel", InstanceURL: "cos.googlesource.com", Committish: sha, Branch: "refs/heads/master"},
+				"src/scripts":            {Repo: "chromiumos/platform/crosutils", Path: "src/scripts", InstanceURL: "chromium.googlesource.com", Committish: sha, Branch: "refs/heads/master"},
+			},
+		},
+		"Missing Include": {
+			manifest:    `<manifest><include name="missing.xml"/></manifest>`,
+			load:        load,
+			expectedErr: true,
+		},
+		"Include Loop": {
+			manifest:    `<manifest><include name="loop.xml"/></manifest>`,
+			load:        load,
+			expectedErr: true,
+		},
+		"No Loader": {
+			manifest:    `<manifest><include name="remotes.xml"/></manifest>`,
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			repos, err := repoMap(test.manifest, test.load)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected error: %v, got %v", test.expectedErr, err)
+			}
+			if diff := cmp.Diff(test.expectedRepos, repos); diff != "" {
+				t.Errorf("repoMap returned unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAdditionsBestEffort(t *testing.T) {
+	const cachedSHA, failedSHA = "1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"
+	targetRepos := map[string]*repo{
+		"src/cached": {Repo: "cos/cached", Path: "src/cached", InstanceURL: cosInstance, Committish: cachedSHA},
+		"src/failed": {Repo: "cos/failed", Path: "src/failed", InstanceURL: cosInstance, Committish: failedSHA},
+	}
+	tests := map[string]struct {
+		bestEffort  bool
+		expectedErr bool
+	}{
+		"Strict": {
+			expectedErr: true,
+		},
+		"Best Effort": {
+			bestEffort: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cache := NewMemoryCache(0)
+			cacheSet(cache, commitsCacheKey(cosInstance, "cos/cached", cachedSHA, "", 10, false), &cachedCommits{
+				Commits: []*Commit{{SHA: cachedSHA}},
+			})
+			opts := &Options{Cache: cache, BestEffort: test.bestEffort}
+			pool := newFetchPool(opts)
+			defer pool.close()
+			// The uncached repo fails before any request is sent since the
+			// context is already cancelled.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			outputChan := make(chan additionsResult, 1)
+			additions(ctx, nil, map[string]*repo{}, targetRepos, 10, opts, pool, nil, outputChan)
+			res := <-outputChan
+			if (res.Err != nil) != test.expectedErr {
+				t.Fatalf("expected error: %v, got %v", test.expectedErr, res.Err)
+			}
+			if test.expectedErr {
+				return
+			}
+			if !commitsMatch(res.Additions["src/cached"].Commits, []string{cachedSHA}) {
+				t.Errorf("expected cached commits for src/cached, got %v", res.Additions["src/cached"])
+			}
+			failed, ok := res.Additions["src/failed"]
+			if !ok || failed.Error == "" || len(failed.Commits) != 0 {
+				t.Errorf("expected error without commits for src/failed, got %+v", failed)
+			}
+		})
+	}
+}
+
+func TestMatchSourceRepos(t *testing.T) {
+	sourceRepos := map[string]*repo{
+		"src/overlays":                 {Repo: "cos/overlays/board-overlays", Path: "src/overlays", InstanceURL: cosInstance},
+		"src/third_party/kernel/v5.10": {Repo: "third_party/kernel", Path: "src/third_party/kernel/v5.10", InstanceURL: cosInstance},
+		"src/docker/a":                 {Repo: "third_party/docker", Path: "src/docker/a", InstanceURL: cosInstance},
+		"src/docker/b":                 {Repo: "third_party/docker", Path: "src/docker/b", InstanceURL: cosInstance},
+	}
+	targetRepos := map[string]*repo{
+		"src/overlays":                 {Repo: "cos/overlays/board-overlays", Path: "src/overlays", InstanceURL: cosInstance},
+		"src/third_party/kernel/v5.15": {Repo: "third_party/kernel", Path: "src/third_party/kernel/v5.15", InstanceURL: cosInstance},
+		"src/docker/c":                 {Repo: "third_party/docker", Path: "src/docker/c", InstanceURL: cosInstance},
+		"src/platform/dev":             {Repo: "cos/platform/dev", Path: "src/platform/dev", InstanceURL: cosInstance},
+	}
+	matched := matchSourceRepos(sourceRepos, targetRepos)
+	tests := map[string]struct {
+		targetPath   string
+		expectedPath string
+	}{
+		"Same Path":           {targetPath: "src/overlays", expectedPath: "src/overlays"},
+		"Moved Repo":          {targetPath: "src/third_party/kernel/v5.15", expectedPath: "src/third_party/kernel/v5.10"},
+		"Ambiguous Move":      {targetPath: "src/docker/c"},
+		"Missing From Source": {targetPath: "src/platform/dev"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var path string
+			if sourceRepo, ok := matched[test.targetPath]; ok {
+				path = sourceRepo.Path
+			}
+			if path != test.expectedPath {
+				t.Errorf("expected %s to match source path %q, got %q", test.targetPath, test.expectedPath, path)
+			}
+		})
+	}
+}
+
+func TestAdditionsAcrossMilestones(t *testing.T) {
+	const sourceSHA, targetSHA = "1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"
+	sourceRepos := map[string]*repo{
+		"src/third_party/kernel/v5.10": {Repo: "third_party/kernel", Path: "src/third_party/kernel/v5.10", InstanceURL: cosInstance,
+			Committish: sourceSHA, Branch: "refs/heads/cos-5.10"},
+	}
+	targetRepos := map[string]*repo{
+		"src/third_party/kernel/v5.15": {Repo: "third_party/kernel", Path: "src/third_party/kernel/v5.15", InstanceURL: cosInstance,
+			Committish: targetSHA, Branch: "refs/heads/cos-5.15"},
+	}
+	// Only the log between the two kernel commits is cached, so additions
+	// fails if the moved repository is not matched.
+	cache := NewMemoryCache(0)
+	cacheSet(cache, commitsCacheKey(cosInstance, "third_party/kernel", targetSHA, sourceSHA, 10, false), &cachedCommits{
+		Commits: []*Commit{{SHA: targetSHA}},
+	})
+	opts := &Options{Cache: cache}
+	pool := newFetchPool(opts)
+	defer pool.close()
+	outputChan := make(chan additionsResult, 1)
+	additions(context.Background(), nil, sourceRepos, targetRepos, 10, opts, pool, nil, outputChan)
+	res := <-outputChan
+	if res.Err != nil {
+		t.Fatalf("additions failed: %v", res.Err)
+	}
+	want := map[string]*RepoLog{
+		"src/third_party/kernel/v5.15": {
+			Commits:      []*Commit{{SHA: targetSHA}},
+			InstanceURL:  cosInstance,
+			Repo:         "third_party/kernel",
+			SourceSHA:    sourceSHA,
+			TargetSHA:    targetSHA,
+			SourceBranch: "refs/heads/cos-5.10",
+			TargetBranch: "refs/heads/cos-5.15",
+			SourcePath:   "src/third_party/kernel/v5.10",
+		},
+	}
+	if diff := cmp.Diff(want, res.Additions); diff != "" {
+		t.Errorf("additions returned unexpected changelog (-want +got):\n%s", diff)
+	}
+}
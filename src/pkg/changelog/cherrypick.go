@@ -0,0 +1,108 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+const changeIDFooter string = "Change-Id"
+
+// changeID returns the Gerrit Change-Id of a commit, or an empty string if it
+// does not have one.
+func changeID(commit *Commit) string {
+	ids := commit.Footer(changeIDFooter)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[len(ids)-1]
+}
+
+// repoKey identifies a repository independently of the path it is checked
+// out at, which can differ between the two builds of a changelog.
+func repoKey(repoLog *RepoLog) string {
+	return repoLog.InstanceURL + "/" + repoLog.Repo
+}
+
+// markCherryPicks pairs the commits of the two changelogs of Changelog that
+// have the same Change-Id in the same repository, even if the repository is
+// checked out at different paths in the two builds. When builds are on
+// different branches, such pairs are the same change cherry-picked onto each
+// branch. Both commits of a pair have CherryPickOf set to the SHA of the
+// other.
+func markCherryPicks(additions, removals map[string]*RepoLog) {
+	removedByID := make(map[string]map[string]*Commit)
+	for _, removed := range removals {
+		key := repoKey(removed)
+		if removedByID[key] == nil {
+			removedByID[key] = make(map[string]*Commit)
+		}
+		for _, commit := range removed.Commits {
+			if id := changeID(commit); id != "" {
+				removedByID[key][id] = commit
+			}
+		}
+	}
+	for repoPath, added := range additions {
+		byID, ok := removedByID[repoKey(added)]
+		if !ok {
+			continue
+		}
+		for _, commit := range added.Commits {
+			match, ok := byID[changeID(commit)]
+			if !ok || match.CherryPickOf != "" {
+				continue
+			}
+			log.Debugf("markCherryPicks: commit %s in repo %s is a cherry-pick of %s", commit.SHA, repoPath, match.SHA)
+			commit.CherryPickOf = match.SHA
+			match.CherryPickOf = commit.SHA
+		}
+	}
+}
+
+// dedupChanges keeps a single commit for each Change-Id of a repository in a
+// changelog. A change can be reachable through several commits once diverged
+// branches are merged back together, ex. a commit and its cherry-pick. The
+// newest commit is kept, and lists the SHAs of the others in Duplicates.
+func dedupChanges(changes map[string]*RepoLog) {
+	for repoPath, repoLog := range changes {
+		kept := make(map[string]*Commit)
+		commits := repoLog.Commits[:0]
+		for _, commit := range repoLog.Commits {
+			id := changeID(commit)
+			if original, ok := kept[id]; ok && id != "" {
+				log.Debugf("dedupChanges: commit %s in repo %s duplicates %s", commit.SHA, repoPath, original.SHA)
+				original.Duplicates = append(original.Duplicates, commit.SHA)
+				continue
+			}
+			kept[id] = commit
+			commits = append(commits, commit)
+		}
+		repoLog.Commits = commits
+	}
+}
+
+// removeCherryPicks removes the commits marked by markCherryPicks from a
+// changelog, and the repositories left without commits.
+func removeCherryPicks(changes map[string]*RepoLog) {
+	for repoPath, repoLog := range changes {
+		commits := repoLog.Commits[:0]
+		for _, commit := range repoLog.Commits {
+			if commit.CherryPickOf == "" {
+				commits = append(commits, commit)
+			}
+		}
+		repoLog.Commits = commits
+		if len(commits) == 0 && !repoLog.HasMoreCommits && repoLog.Error == "" {
+			delete(changes, repoPath)
+		}
+	}
+}
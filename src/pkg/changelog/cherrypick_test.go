@@ -0,0 +1,132 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func commitWithChangeID(sha, id string) *Commit {
+	commit := &Commit{SHA: sha}
+	if id != "" {
+		commit.Footers = map[string][]string{changeIDFooter: {id}}
+	}
+	return commit
+}
+
+func TestMarkCherryPicks(t *testing.T) {
+	additions := map[string]*RepoLog{
+		"src/kernel": {Repo: "third_party/kernel", Commits: []*Commit{
+			commitWithChangeID("a1", "I1"),
+			commitWithChangeID("a2", "I2"),
+			commitWithChangeID("a3", ""),
+		}},
+		"src/overlays": {Repo: "cos/overlays/board-overlays", Commits: []*Commit{
+			commitWithChangeID("a4", "I4"),
+		}},
+		"src/third_party/docker": {Repo: "third_party/docker", Commits: []*Commit{
+			commitWithChangeID("a5", "I5"),
+		}},
+	}
+	removals := map[string]*RepoLog{
+		"src/kernel": {Repo: "third_party/kernel", Commits: []*Commit{
+			commitWithChangeID("r1", "I1"),
+			commitWithChangeID("r3", ""),
+		}},
+		// Same Change-Id in a different repository is not a cherry-pick
+		"src/platform/dev": {Repo: "cos/platform/dev", Commits: []*Commit{
+			commitWithChangeID("r4", "I4"),
+		}},
+		// Same repository checked out at a different path in the source build
+		"src/docker": {Repo: "third_party/docker", Commits: []*Commit{
+			commitWithChangeID("r5", "I5"),
+		}},
+	}
+	markCherryPicks(additions, removals)
+	tests := map[string]struct {
+		commit   *Commit
+		expected string
+	}{
+		"Added Cherry-Pick":   {commit: additions["src/kernel"].Commits[0], expected: "r1"},
+		"Removed Cherry-Pick": {commit: removals["src/kernel"].Commits[0], expected: "a1"},
+		"Unmatched":           {commit: additions["src/kernel"].Commits[1]},
+		"No Change-Id":        {commit: additions["src/kernel"].Commits[2]},
+		"Other Repository":    {commit: additions["src/overlays"].Commits[0]},
+		"Moved Repository":    {commit: additions["src/third_party/docker"].Commits[0], expected: "r5"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.commit.CherryPickOf != test.expected {
+				t.Errorf("expected CherryPickOf %q, got %q", test.expected, test.commit.CherryPickOf)
+			}
+		})
+	}
+}
+
+func TestRemoveCherryPicks(t *testing.T) {
+	changes := map[string]*RepoLog{
+		"src/kernel": {Commits: []*Commit{
+			{SHA: "a1", CherryPickOf: "r1"},
+			{SHA: "a2"},
+		}},
+		"src/overlays": {Commits: []*Commit{
+			{SHA: "a3", CherryPickOf: "r3"},
+		}},
+		"src/platform/dev": {
+			Commits:        []*Commit{{SHA: "a4", CherryPickOf: "r4"}},
+			HasMoreCommits: true,
+		},
+	}
+	removeCherryPicks(changes)
+	expected := map[string]*RepoLog{
+		"src/kernel":       {Commits: []*Commit{{SHA: "a2"}}},
+		"src/platform/dev": {Commits: []*Commit{}, HasMoreCommits: true},
+	}
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("removeCherryPicks returned unexpected changelog (-want +got):\n%s", diff)
+	}
+}
+
+func TestDedupChanges(t *testing.T) {
+	changes := map[string]*RepoLog{
+		"src/kernel": {Commits: []*Commit{
+			commitWithChangeID("merge", ""),
+			commitWithChangeID("pick", "I1"),
+			commitWithChangeID("a2", "I2"),
+			commitWithChangeID("a3", ""),
+			commitWithChangeID("original", "I1"),
+		}},
+		"src/overlays": {Commits: []*Commit{
+			commitWithChangeID("a4", "I1"),
+		}},
+	}
+	dedupChanges(changes)
+	expected := map[string]*RepoLog{
+		"src/kernel": {Commits: []*Commit{
+			commitWithChangeID("merge", ""),
+			{SHA: "pick", Footers: map[string][]string{changeIDFooter: {"I1"}}, Duplicates: []string{"original"}},
+			commitWithChangeID("a2", "I2"),
+			commitWithChangeID("a3", ""),
+		}},
+		"src/overlays": {Commits: []*Commit{
+			commitWithChangeID("a4", "I1"),
+		}},
+	}
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("dedupChanges returned unexpected changelog (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/golang/protobuf/proto"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// Path Gitiles reports for the missing side of an added or deleted file
+const devNull = "/dev/null"
+
+// FileChange is a file added, deleted or modified by a commit. Paths are
+// relative to the root of the repository.
+//
+// Gitiles only reports which files changed, so line counts are not
+// available.
+type FileChange struct {
+	// How the file changed, one of "ADD", "COPY", "DELETE", "MODIFY" or
+	// "RENAME"
+	Type string `json:"Type"`
+	// Path of the file before the commit, empty if the file was added
+	OldPath string `json:"OldPath,omitempty"`
+	// Path of the file after the commit, empty if the file was deleted
+	NewPath string `json:"NewPath,omitempty"`
+}
+
+// treeDiffClient is a Gitiles client that requests the files changed by
+// each commit along with commit logs.
+type treeDiffClient struct {
+	utils.GitilesService
+}
+
+func (c treeDiffClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	req := proto.Clone(in).(*gitilesProto.LogRequest)
+	req.TreeDiff = true
+	return c.GitilesService.Log(ctx, req, opts...)
+}
+
+func filePath(p string) string {
+	if p == devNull {
+		return ""
+	}
+	return p
+}
+
+// fileChanges converts the tree diff of a commit into FileChanges. Returns nil
+// if the tree diff was not requested.
+func fileChanges(commit *git.Commit) []*FileChange {
+	if len(commit.TreeDiff) == 0 {
+		return nil
+	}
+	output := make([]*FileChange, len(commit.TreeDiff))
+	for i, diff := range commit.TreeDiff {
+		output[i] = &FileChange{
+			Type:    diff.Type.String(),
+			OldPath: filePath(diff.OldPath),
+			NewPath: filePath(diff.NewPath),
+		}
+	}
+	return output
+}
+
+// underPath reports whether file is dir or a file inside of it.
+func underPath(file, dir string) bool {
+	dir = strings.Trim(dir, "/")
+	if file == "" {
+		return false
+	}
+	return dir == "" || file == dir || strings.HasPrefix(file, dir+"/")
+}
+
+// TouchesPath reports whether the commit changed a file at or under dir,
+// ex. "drivers/gpu" matches "drivers/gpu/drm/i915/i915_drv.c" but not
+// "drivers/gpuvm.c". It is always false if Files was not retrieved.
+func (c *Commit) TouchesPath(dir string) bool {
+	for _, file := range c.Files {
+		if underPath(file.OldPath, dir) || underPath(file.NewPath, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByPath returns a copy of a changelog keeping only the commits that
+// touch at least one of dirs, as reported by Commit.TouchesPath. Repositories
+// left without commits are removed. The changelog must have been generated
+// with Options.FileChanges enabled.
+func FilterByPath(changes map[string]*RepoLog, dirs ...string) map[string]*RepoLog {
+	output := make(map[string]*RepoLog)
+	for repoPath, repoLog := range changes {
+		var commits []*Commit
+		for _, commit := range repoLog.Commits {
+			for _, dir := range dirs {
+				if commit.TouchesPath(dir) {
+					commits = append(commits, commit)
+					break
+				}
+			}
+		}
+		if len(commits) == 0 {
+			continue
+		}
+		filtered := *repoLog
+		filtered.Commits = commits
+		output[repoPath] = &filtered
+	}
+	return output
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// treeDiffLogClient returns a single commit whose tree diff is only set if
+// it was requested.
+type treeDiffLogClient struct {
+	utils.GitilesService
+}
+
+func (treeDiffLogClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	commit := &git.Commit{Id: "c1", Message: "Fix GPU reset"}
+	if in.TreeDiff {
+		commit.TreeDiff = []*git.Commit_TreeDiff{
+			{Type: git.Commit_TreeDiff_MODIFY, OldPath: "drivers/gpu/drm/drm_drv.c", NewPath: "drivers/gpu/drm/drm_drv.c"},
+			{Type: git.Commit_TreeDiff_ADD, OldPath: devNull, NewPath: "drivers/gpu/drm/reset.c"},
+			{Type: git.Commit_TreeDiff_DELETE, OldPath: "drivers/gpu/drm/old_reset.c", NewPath: devNull},
+		}
+	}
+	return &gitilesProto.LogResponse{Log: []*git.Commit{commit}}, nil
+}
+
+func TestTreeDiffClient(t *testing.T) {
+	expected := []*FileChange{
+		{Type: "MODIFY", OldPath: "drivers/gpu/drm/drm_drv.c", NewPath: "drivers/gpu/drm/drm_drv.c"},
+		{Type: "ADD", NewPath: "drivers/gpu/drm/reset.c"},
+		{Type: "DELETE", OldPath: "drivers/gpu/drm/old_reset.c"},
+	}
+	tests := map[string]struct {
+		client        utils.GitilesService
+		expectedFiles []*FileChange
+	}{
+		"Without Tree Diff": {
+			client: treeDiffLogClient{},
+		},
+		"With Tree Diff": {
+			client:        treeDiffClient{treeDiffLogClient{}},
+			expectedFiles: expected,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			in := &gitilesProto.LogRequest{Project: "third_party/kernel"}
+			resp, err := test.client.Log(context.Background(), in)
+			if err != nil {
+				t.Fatalf("Log failed: %v", err)
+			}
+			if in.TreeDiff {
+				t.Errorf("expected the request of the caller to be left unchanged")
+			}
+			commits, err := ParseGitCommitLog(resp.Log)
+			if err != nil {
+				t.Fatalf("ParseGitCommitLog failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expectedFiles, commits[0].Files); diff != "" {
+				t.Errorf("unexpected files (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTouchesPath(t *testing.T) {
+	commit := &Commit{Files: []*FileChange{
+		{Type: "RENAME", OldPath: "drivers/gpu/drm/i915/i915_drv.c", NewPath: "drivers/gpu/i915.c"},
+	}}
+	tests := map[string]bool{
+		"drivers/gpu":                     true,
+		"drivers/gpu/":                    true,
+		"drivers/gpu/drm/i915":            true,
+		"drivers/gpu/drm/i915/i915_drv.c": true,
+		"drivers/gpu/i915.c":              true,
+		"drivers/gp":                      false,
+		"drivers/net":                     false,
+		"":                                true,
+	}
+	for dir, expected := range tests {
+		if got := commit.TouchesPath(dir); got != expected {
+			t.Errorf("expected TouchesPath(%q) to be %v, got %v", dir, expected, got)
+		}
+	}
+	if (&Commit{}).TouchesPath("") {
+		t.Errorf("expected a commit without files to touch no path")
+	}
+}
+
+func TestFilterByPath(t *testing.T) {
+	gpu := &Commit{SHA: "gpu", Files: []*FileChange{{Type: "MODIFY", OldPath: "drivers/gpu/a.c", NewPath: "drivers/gpu/a.c"}}}
+	net := &Commit{SHA: "net", Files: []*FileChange{{Type: "MODIFY", OldPath: "drivers/net/b.c", NewPath: "drivers/net/b.c"}}}
+	docs := &Commit{SHA: "docs", Files: []*FileChange{{Type: "ADD", NewPath: "Documentation/gpu.rst"}}}
+	changes := map[string]*RepoLog{
+		"src/third_party/kernel": {Repo: "third_party/kernel", Commits: []*Commit{gpu, net, docs}, HasMoreCommits: true},
+		"src/overlays":           {Repo: "cos/overlays/board-overlays", Commits: []*Commit{docs}},
+	}
+	tests := map[string]struct {
+		dirs     []string
+		expected map[string]*RepoLog
+	}{
+		"Single Path": {
+			dirs: []string{"drivers/gpu"},
+			expected: map[string]*RepoLog{
+				"src/third_party/kernel": {Repo: "third_party/kernel", Commits: []*Commit{gpu}, HasMoreCommits: true},
+			},
+		},
+		"Multiple Paths": {
+			dirs: []string{"drivers/gpu", "Documentation"},
+			expected: map[string]*RepoLog{
+				"src/third_party/kernel": {Repo: "third_party/kernel", Commits: []*Commit{gpu, docs}, HasMoreCommits: true},
+				"src/overlays":           {Repo: "cos/overlays/board-overlays", Commits: []*Commit{docs}},
+			},
+		},
+		"No Match": {
+			dirs:     []string{"fs"},
+			expected: map[string]*RepoLog{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, FilterByPath(changes, test.dirs...)); diff != "" {
+				t.Errorf("FilterByPath returned unexpected changelog (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if len(changes["src/third_party/kernel"].Commits) != 3 {
+		t.Errorf("expected FilterByPath to leave its input unchanged")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+const (
+	gobHostSuffix    string = ".googlesource.com"
+	gobReviewSuffix  string = "-review.googlesource.com"
+	mergedCLStatus   string = "MERGED"
+	clQueryBatchSize int    = 25
+)
+
+// GerritService is the subset of the Gerrit changes API used to resolve the
+// CLs of commits. It is implemented by *gerrit.ChangesService.
+type GerritService interface {
+	QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error)
+}
+
+// reviewHost returns the Gerrit host reviewing the changes of a GoB instance,
+// ex. "cos-review.googlesource.com" for "cos.googlesource.com", or an empty
+// string if the instance is not hosted on GoB.
+func reviewHost(instanceURL string) string {
+	if !strings.HasSuffix(instanceURL, gobHostSuffix) || strings.HasSuffix(instanceURL, gobReviewSuffix) {
+		return ""
+	}
+	return strings.TrimSuffix(instanceURL, gobHostSuffix) + gobReviewSuffix
+}
+
+// clURL returns the review URL of a CL.
+func clURL(host, project string, number int) string {
+	return fmt.Sprintf("https://%s/c/%s/+/%d", host, project, number)
+}
+
+// clCommit is a commit whose CL is resolved, along with the branches of the
+// RepoLog it belongs to.
+type clCommit struct {
+	commit   *Commit
+	branches []string
+}
+
+// clQuery is a batch of Change-Ids of a single project resolved by one
+// Gerrit query.
+type clQuery struct {
+	host    string
+	project string
+	commits map[string][]*clCommit
+}
+
+// query returns the Gerrit search query matching every change of the batch.
+func (q *clQuery) query() string {
+	ids := make([]string, 0, len(q.commits))
+	for id := range q.commits {
+		ids = append(ids, "change:"+id)
+	}
+	return fmt.Sprintf("project:%s (%s)", q.project, strings.Join(ids, " OR "))
+}
+
+// matchCL selects the CL of a commit among the changes sharing its Change-Id,
+// which can be cherry-picked onto several branches. A change whose current
+// revision is the commit is preferred, then a change on the branch of the
+// commit's repository and then any merged change.
+func matchCL(target *clCommit, changes []gerrit.ChangeInfo) *gerrit.ChangeInfo {
+	var onBranch, merged *gerrit.ChangeInfo
+	for i := range changes {
+		change := &changes[i]
+		if change.CurrentRevision == target.commit.SHA {
+			return change
+		}
+		for _, branch := range target.branches {
+			if onBranch == nil && change.Branch == branch {
+				onBranch = change
+			}
+		}
+		if merged == nil && change.Status == mergedCLStatus {
+			merged = change
+		}
+	}
+	if onBranch != nil {
+		return onBranch
+	}
+	if merged != nil {
+		return merged
+	}
+	if len(changes) > 0 {
+		return &changes[0]
+	}
+	return nil
+}
+
+// run sends the query of a batch and sets the CL of each of its commits.
+// Commits are left without a CL if the query fails.
+func (q *clQuery) run(client GerritService) {
+	opts := &gerrit.QueryChangeOptions{}
+	opts.Query = []string{q.query()}
+	opts.AdditionalFields = []string{"CURRENT_REVISION"}
+	changes, _, err := client.QueryChanges(opts)
+	if err != nil {
+		log.Warnf("resolveCLs: failed to query CLs of project %s on %s: %v", q.project, q.host, err)
+		return
+	}
+	byID := make(map[string][]gerrit.ChangeInfo)
+	for _, change := range *changes {
+		if change.Project == q.project {
+			byID[change.ChangeID] = append(byID[change.ChangeID], change)
+		}
+	}
+	for id, targets := range q.commits {
+		for _, target := range targets {
+			change := matchCL(target, byID[id])
+			if change == nil {
+				log.Debugf("resolveCLs: no CL found for commit %s with Change-Id %s", target.commit.SHA, id)
+				continue
+			}
+			target.commit.CLNumber = change.Number
+			target.commit.ReviewURL = clURL(q.host, q.project, change.Number)
+		}
+	}
+}
+
+// clQueries groups the commits of changelogs with a Change-Id into batches of
+// at most clQueryBatchSize Change-Ids of the same project.
+func clQueries(changelogs ...map[string]*RepoLog) []*clQuery {
+	pending := make(map[string]*clQuery)
+	var queries []*clQuery
+	for _, changes := range changelogs {
+		for _, repoLog := range changes {
+			host := reviewHost(repoLog.InstanceURL)
+			if host == "" {
+				continue
+			}
+			var branches []string
+			for _, branch := range []string{repoLog.TargetBranch, repoLog.SourceBranch} {
+				if branch != "" {
+					branches = append(branches, strings.TrimPrefix(branch, "refs/heads/"))
+				}
+			}
+			key := host + "/" + repoLog.Repo
+			for _, commit := range repoLog.Commits {
+				id := changeID(commit)
+				if id == "" {
+					continue
+				}
+				q, ok := pending[key]
+				if !ok || (len(q.commits) == clQueryBatchSize && q.commits[id] == nil) {
+					q = &clQuery{host: host, project: repoLog.Repo, commits: make(map[string][]*clCommit)}
+					pending[key] = q
+					queries = append(queries, q)
+				}
+				q.commits[id] = append(q.commits[id], &clCommit{commit: commit, branches: branches})
+			}
+		}
+	}
+	return queries
+}
+
+// gerritClient creates the client used to query a Gerrit host.
+func gerritClient(httpClient *http.Client, host string, opts *Options) (GerritService, error) {
+	if opts != nil && opts.GerritClient != nil {
+		return opts.GerritClient(host)
+	}
+	client, err := gerrit.NewClient("https://"+host, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return client.Changes, nil
+}
+
+// resolveCLs sets the CL number and review URL of every commit of changelogs
+// hosted on GoB that has a Change-Id. Queries are batched by project and run
+// on pool. Resolving CLs is best effort: commits whose CL cannot be found
+// are left unchanged.
+func resolveCLs(ctx context.Context, httpClient *http.Client, opts *Options, pool *fetchPool, changelogs ...map[string]*RepoLog) {
+	clients := make(map[string]GerritService)
+	var wg sync.WaitGroup
+	for _, q := range clQueries(changelogs...) {
+		client, ok := clients[q.host]
+		if !ok {
+			var err error
+			if client, err = gerritClient(httpClient, q.host, opts); err != nil {
+				log.Warnf("resolveCLs: failed to create Gerrit client for %s: %v", q.host, err)
+			}
+			clients[q.host] = client
+		}
+		if client == nil {
+			continue
+		}
+		q := q
+		wg.Add(1)
+		pool.submit(func() {
+			defer wg.Done()
+			if err := pool.wait(ctx); err != nil {
+				return
+			}
+			q.run(client)
+		})
+	}
+	wg.Wait()
+}
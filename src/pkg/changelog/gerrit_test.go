@@ -0,0 +1,199 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+// fakeGerrit serves a fixed list of changes to every query and records the
+// queries it receives.
+type fakeGerrit struct {
+	mu      sync.Mutex
+	changes []gerrit.ChangeInfo
+	queries []string
+	err     error
+}
+
+func (g *fakeGerrit) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.queries = append(g.queries, opt.Query...)
+	if g.err != nil {
+		return nil, nil, g.err
+	}
+	changes := append([]gerrit.ChangeInfo(nil), g.changes...)
+	return &changes, nil, nil
+}
+
+func TestReviewHost(t *testing.T) {
+	tests := map[string]struct {
+		instanceURL string
+		expected    string
+	}{
+		"GoB":    {instanceURL: "cos.googlesource.com", expected: "cos-review.googlesource.com"},
+		"Review": {instanceURL: "cos-review.googlesource.com", expected: ""},
+		"GitHub": {instanceURL: "github.com/google", expected: ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := reviewHost(test.instanceURL); got != test.expected {
+				t.Errorf("expected review host %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestMatchCL(t *testing.T) {
+	master := gerrit.ChangeInfo{Number: 1, Branch: "master", Status: "MERGED", CurrentRevision: "m1"}
+	release := gerrit.ChangeInfo{Number: 2, Branch: "release-R93", Status: "MERGED", CurrentRevision: "r1"}
+	abandoned := gerrit.ChangeInfo{Number: 3, Branch: "release-R89", Status: "ABANDONED"}
+	tests := map[string]struct {
+		target   *clCommit
+		changes  []gerrit.ChangeInfo
+		expected int
+	}{
+		"Current Revision": {
+			target:   &clCommit{commit: &Commit{SHA: "r1"}, branches: []string{"master"}},
+			changes:  []gerrit.ChangeInfo{master, release},
+			expected: 2,
+		},
+		"Branch": {
+			target:   &clCommit{commit: &Commit{SHA: "x"}, branches: []string{"release-R93"}},
+			changes:  []gerrit.ChangeInfo{master, release},
+			expected: 2,
+		},
+		"Merged": {
+			target:   &clCommit{commit: &Commit{SHA: "x"}, branches: []string{"release-R97"}},
+			changes:  []gerrit.ChangeInfo{abandoned, release},
+			expected: 2,
+		},
+		"Any": {
+			target:   &clCommit{commit: &Commit{SHA: "x"}},
+			changes:  []gerrit.ChangeInfo{abandoned},
+			expected: 3,
+		},
+		"None": {
+			target:   &clCommit{commit: &Commit{SHA: "x"}},
+			expected: 0,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := 0
+			if change := matchCL(test.target, test.changes); change != nil {
+				got = change.Number
+			}
+			if got != test.expected {
+				t.Errorf("expected CL %d, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCLQueries(t *testing.T) {
+	var commits []*Commit
+	for i := 0; i < clQueryBatchSize+1; i++ {
+		commits = append(commits, commitWithChangeID("k", "I"+string(rune('a'+i))))
+	}
+	commits = append(commits, commitWithChangeID("n", ""))
+	changes := map[string]*RepoLog{
+		"src/third_party/kernel": {InstanceURL: cosInstance, Repo: "third_party/kernel", Commits: commits},
+		"src/third_party/go-cmp": {InstanceURL: "github.com/google", Repo: "go-cmp", Commits: []*Commit{commitWithChangeID("g", "Ig")}},
+	}
+	queries := clQueries(changes)
+	var sizes []int
+	for _, q := range queries {
+		if q.host != "cos-review.googlesource.com" || q.project != "third_party/kernel" {
+			t.Errorf("unexpected query of project %s on %s", q.project, q.host)
+		}
+		sizes = append(sizes, len(q.commits))
+	}
+	if diff := cmp.Diff([]int{clQueryBatchSize, 1}, sizes); diff != "" {
+		t.Errorf("unexpected batch sizes, diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestChangelogResolveCLs(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1", Message: "Initial kernel"},
+		{Id: "k2", Parents: []string{"k1"}, Message: "Fix GPU reset\n\nChange-Id: I2"},
+		{Id: "k3", Parents: []string{"k2"}, Message: "Bump version\n\nChange-Id: I3"},
+	}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{{Id: "o1"}}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = fakeManifest("k1", "o1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = fakeManifest("k3", "o1")
+	tests := map[string]struct {
+		gerrit   *fakeGerrit
+		expected map[string]int
+	}{
+		"Resolved": {
+			gerrit: &fakeGerrit{changes: []gerrit.ChangeInfo{
+				{Project: "third_party/kernel", ChangeID: "I2", Number: 102, CurrentRevision: "k2"},
+				{Project: "third_party/kernel", ChangeID: "I3", Number: 103, CurrentRevision: "k3"},
+				{Project: "third_party/other", ChangeID: "I3", Number: 999, CurrentRevision: "k3"},
+			}},
+			expected: map[string]int{"k3": 103, "k2": 102},
+		},
+		"Query Failure": {
+			gerrit:   &fakeGerrit{err: errors.New("status code 500")},
+			expected: map[string]int{"k3": 0, "k2": 0},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				ResolveCLs:    true,
+				GitilesClient: func(string) (utils.GitilesService, error) { return g, nil },
+				GerritClient: func(host string) (GerritService, error) {
+					if host != "cos-review.googlesource.com" {
+						t.Errorf("unexpected Gerrit host %s", host)
+					}
+					return test.gerrit, nil
+				},
+			}
+			additions, _, err := Changelog(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1, opts)
+			if err != nil {
+				t.Fatalf("Changelog failed: %v", err)
+			}
+			got := make(map[string]int)
+			for _, commit := range additions["src/third_party/kernel"].Commits {
+				got[commit.SHA] = commit.CLNumber
+				if commit.CLNumber != 0 {
+					expectedURL := clURL("cos-review.googlesource.com", "third_party/kernel", commit.CLNumber)
+					if commit.ReviewURL != expectedURL {
+						t.Errorf("expected review URL %s for commit %s, got %s", expectedURL, commit.SHA, commit.ReviewURL)
+					}
+				}
+			}
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected CL numbers, diff (-want +got):\n%s", diff)
+			}
+			if len(test.gerrit.queries) != 1 {
+				t.Errorf("expected a single batched query, got %v", test.gerrit.queries)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package changelog
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -25,18 +26,62 @@ import (
 const (
 	bugLinePrefix         string = "BUG="
 	releaseNoteLinePrefix string = "RELEASE_NOTE="
+
+	// Gerrit style footers, matched case-insensitively
+	bugFooterPrefix         string = "bug:"
+	releaseNoteFooterPrefix string = "release-note:"
 )
 
 // Commit is a simplified struct of git.Commit
 // Useful for interfaces
+//
+// The JSON field names are part of the serialized changelog format described
+// by JSONVersion and must not change.
 type Commit struct {
-	SHA           string
-	AuthorName    string
-	CommitterName string
-	Subject       string
-	Bugs          []string
-	ReleaseNote   string
-	CommitTime    string
+	SHA           string `json:"SHA"`
+	AuthorName    string `json:"AuthorName"`
+	AuthorEmail   string `json:"AuthorEmail"`
+	CommitterName string `json:"CommitterName"`
+	// First line of the commit message
+	Subject string `json:"Subject"`
+	// Bugs listed in the BUG= line or Bug: footer, ex. "b/123" or "crbug/456"
+	Bugs []string `json:"Bugs"`
+	// Links to every bug referenced by the commit message
+	BugLinks    []*Bug `json:"BugLinks"`
+	ReleaseNote string `json:"ReleaseNote"`
+	// Commit date, ex. "Mon, 2 Jan 2006"
+	CommitTime string `json:"CommitTime"`
+	// Commit time in seconds since the Unix epoch, used to order commits of
+	// different repositories. Zero if the commit has no committer.
+	CommitTimestamp int64 `json:"CommitTimestamp,omitempty"`
+	// Footers of the commit message, such as Change-Id, Reviewed-by or
+	// Cq-Depend, keyed by footer name as written in the message. A footer
+	// repeated in the message has one value per occurrence, in order.
+	Footers map[string][]string `json:"Footers,omitempty"`
+	// SHA of the commit with the same Change-Id on the other side of the
+	// changelog, if this commit was cherry-picked between the two builds
+	CherryPickOf string `json:"CherryPickOf,omitempty"`
+	// SHAs of older commits of the same changelog and repository with the
+	// same Change-Id, which were removed by Options.DeduplicateChanges
+	Duplicates []string `json:"Duplicates,omitempty"`
+	// Files changed by the commit. Only retrieved when Options.FileChanges
+	// is enabled, and never for repositories hosted on GitHub.
+	Files []*FileChange `json:"Files,omitempty"`
+	// CVE identifiers referenced by the commit message, ex. "CVE-2021-3156"
+	CVEs []string `json:"CVEs,omitempty"`
+	// Number and review URL of the Gerrit CL the commit was submitted
+	// through. Only resolved when Options.ResolveCLs is enabled, and never
+	// for repositories hosted outside of GoB.
+	CLNumber  int    `json:"CLNumber,omitempty"`
+	ReviewURL string `json:"ReviewURL,omitempty"`
+}
+
+// Bug is a reference to an issue tracker entry found in a commit message
+type Bug struct {
+	// Short name of the tracker, "b" or "crbug"
+	Tracker string `json:"Tracker"`
+	ID      string `json:"ID"`
+	URL     string `json:"URL"`
 }
 
 // All bug patterns need to be added here to recognize whether a bug entry
@@ -48,6 +93,22 @@ var bugPatternToReplacement = map[*regexp.Regexp]string{
 	regexp.MustCompile("^chrome.*:"):   "crbug/",
 }
 
+var (
+	// Matches inline bug references such as b/123 or crbug.com/456
+	inlineBugRe = regexp.MustCompile(`(?:^|[^\w/.])(b|crbug)(?:\.com)?/(\d+)\b`)
+
+	// Matches CVE identifiers such as CVE-2021-3156, in any case
+	cveRe = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+
+	// Matches a footer line such as "Reviewed-by: Jane Doe <jane@example.com>"
+	footerRe = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*):\s*(.*)$`)
+
+	bugTrackerURLs = map[string]string{
+		"b":     "https://issuetracker.google.com/issues/%s",
+		"crbug": "https://crbug.com/%s",
+	}
+)
+
 func author(commit *git.Commit) string {
 	if commit.Author != nil {
 		return commit.Author.Name
@@ -55,6 +116,13 @@ func author(commit *git.Commit) string {
 	return "None"
 }
 
+func authorEmail(commit *git.Commit) string {
+	if commit.Author != nil {
+		return commit.Author.Email
+	}
+	return ""
+}
+
 func committer(commit *git.Commit) string {
 	if commit.Committer != nil {
 		return commit.Committer.Name
@@ -73,14 +141,18 @@ func bugs(commit *git.Commit) []string {
 	for _, line := range msgSplit {
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, bugLinePrefix) {
-			bugLine = line
+			bugLine = line[len(bugLinePrefix):]
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), bugFooterPrefix) {
+			bugLine = line[len(bugFooterPrefix):]
 			break
 		}
 	}
-	if len(bugLine) <= len(bugLinePrefix) {
+	if strings.TrimSpace(bugLine) == "" {
 		return output
 	}
-	bugList := strings.Split(bugLine[len(bugLinePrefix):], ",")
+	bugList := strings.Split(bugLine, ",")
 	for _, bug := range bugList {
 		bug := strings.TrimSpace(bug)
 		for prefix, replacement := range bugPatternToReplacement {
@@ -92,6 +164,38 @@ func bugs(commit *git.Commit) []string {
 	return output
 }
 
+// bugLinks converts the bugs listed in the BUG= line or Bug: footer, and the
+// b/ and crbug references found anywhere in a commit message, into links.
+// Each bug is only returned once.
+func bugLinks(commit *git.Commit, footerBugs []string) []*Bug {
+	output := []*Bug{}
+	seen := make(map[string]bool)
+	add := func(tracker, id string) {
+		key := tracker + "/" + id
+		if id == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		output = append(output, &Bug{
+			Tracker: tracker,
+			ID:      id,
+			URL:     fmt.Sprintf(bugTrackerURLs[tracker], id),
+		})
+	}
+	for _, bug := range footerBugs {
+		parts := strings.SplitN(bug, "/", 2)
+		if len(parts) == 2 {
+			add(parts[0], parts[1])
+		}
+	}
+	for _, match := range inlineBugRe.FindAllStringSubmatch(commit.Message, -1) {
+		add(match[1], match[2])
+	}
+	return output
+}
+
+// releaseNote returns the value of the first RELEASE_NOTE= line or
+// Release-Note: footer in a commit message
 func releaseNote(commit *git.Commit) string {
 	msgSplit := strings.Split(commit.Message, "\n")
 	for _, line := range msgSplit {
@@ -99,10 +203,69 @@ func releaseNote(commit *git.Commit) string {
 		if strings.HasPrefix(line, releaseNoteLinePrefix) {
 			return line[len(releaseNoteLinePrefix):]
 		}
+		if strings.HasPrefix(strings.ToLower(line), releaseNoteFooterPrefix) {
+			return strings.TrimSpace(line[len(releaseNoteFooterPrefix):])
+		}
 	}
 	return ""
 }
 
+// footers parses the footers in the last paragraph of a commit message. The
+// subject line is never treated as a footer, and lines of the last paragraph
+// that are not footers are ignored.
+func footers(commit *git.Commit) map[string][]string {
+	paragraphs := strings.Split(strings.TrimSpace(commit.Message), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	var output map[string][]string
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		match := footerRe.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		if output == nil {
+			output = make(map[string][]string)
+		}
+		output[match[1]] = append(output[match[1]], strings.TrimSpace(match[2]))
+	}
+	return output
+}
+
+// Footer returns the values of the footer named key, which is matched
+// case-insensitively, ex. "Reviewed-by" also matches "Reviewed-By".
+func (c *Commit) Footer(key string) []string {
+	var output []string
+	for name, values := range c.Footers {
+		if strings.EqualFold(name, key) {
+			output = append(output, values...)
+		}
+	}
+	return output
+}
+
+// cves returns the CVE identifiers referenced anywhere in a commit message,
+// in upper case and in order of first appearance.
+func cves(commit *git.Commit) []string {
+	var output []string
+	seen := make(map[string]bool)
+	for _, match := range cveRe.FindAllString(commit.Message, -1) {
+		id := strings.ToUpper(match)
+		if !seen[id] {
+			seen[id] = true
+			output = append(output, id)
+		}
+	}
+	return output
+}
+
+func commitTimestamp(commit *git.Commit) int64 {
+	if commit.Committer != nil && commit.Committer.Time != nil {
+		return commit.Committer.Time.AsTime().Unix()
+	}
+	return 0
+}
+
 func commitTime(commit *git.Commit) string {
 	if commit.Committer != nil {
 		return commit.Committer.Time.AsTime().Format("Mon, 2 Jan 2006")
@@ -116,14 +279,21 @@ func parseGitCommit(commit *git.Commit) (*Commit, error) {
 	if commit == nil {
 		return nil, errors.New("parseCommit: Input should not be nil")
 	}
+	commitBugs := bugs(commit)
 	return &Commit{
-		SHA:           commit.Id,
-		AuthorName:    author(commit),
-		CommitterName: committer(commit),
-		Subject:       subject(commit),
-		Bugs:          bugs(commit),
-		ReleaseNote:   releaseNote(commit),
-		CommitTime:    commitTime(commit),
+		SHA:             commit.Id,
+		AuthorName:      author(commit),
+		AuthorEmail:     authorEmail(commit),
+		CommitterName:   committer(commit),
+		Subject:         subject(commit),
+		Bugs:            commitBugs,
+		BugLinks:        bugLinks(commit, commitBugs),
+		ReleaseNote:     releaseNote(commit),
+		CommitTime:      commitTime(commit),
+		CommitTimestamp: commitTimestamp(commit),
+		Footers:         footers(commit),
+		Files:           fileChanges(commit),
+		CVEs:            cves(commit),
 	}, nil
 }
 
@@ -143,6 +143,20 @@ RELEASE_NOTE=b/1224222`)},
 			CommitTime:     []string{timeVal},
 			ShouldError:    false,
 		},
+		"release note footer": {
+			Input: []*git.Commit{createCommitWithMessage(`kernel: Enable CONFIG_IPV6_SEG6_LWTUNNEL
+
+Release-Note: Enabled IPv6 segment routing
+Change-Id: I0b6895f7860921f6bed25090d64f8489dbeeb19e`)},
+			SHAs:           []string{id},
+			AuthorNames:    []string{authorName},
+			CommitterNames: []string{committerName},
+			Subjects:       []string{"kernel: Enable CONFIG_IPV6_SEG6_LWTUNNEL"},
+			Bugs:           [][]string{{}},
+			ReleaseNote:    []string{"Enabled IPv6 segment routing"},
+			CommitTime:     []string{timeVal},
+			ShouldError:    false,
+		},
 		"empty commit message": {
 			Input:          []*git.Commit{createCommitWithMessage("")},
 			SHAs:           []string{id},
@@ -347,3 +361,141 @@ BUG=56456651, chromium:777882, -1, b:9999999`),
 		})
 	}
 }
+
+func TestBugLinks(t *testing.T) {
+	tests := map[string]struct {
+		Message  string
+		BugLinks []*Bug
+	}{
+		"bug line": {
+			Message: "Subject\n\nBUG=b:123, chromium:456\nTEST=none",
+			BugLinks: []*Bug{
+				{Tracker: "b", ID: "123", URL: "https://issuetracker.google.com/issues/123"},
+				{Tracker: "crbug", ID: "456", URL: "https://crbug.com/456"},
+			},
+		},
+		"bug footer": {
+			Message: "Subject\n\nBug: b/789\nChange-Id: I0b6895f7860921f6bed25090d64f8489dbeeb19e",
+			BugLinks: []*Bug{
+				{Tracker: "b", ID: "789", URL: "https://issuetracker.google.com/issues/789"},
+			},
+		},
+		"inline references": {
+			Message: "Fix crash reported in crbug.com/111\n\nSee b/222 and b/222 for details.\nhttps://example.com/b/333",
+			BugLinks: []*Bug{
+				{Tracker: "crbug", ID: "111", URL: "https://crbug.com/111"},
+				{Tracker: "b", ID: "222", URL: "https://issuetracker.google.com/issues/222"},
+			},
+		},
+		"footer and inline duplicate": {
+			Message: "Fix b/123\n\nBUG=b:123",
+			BugLinks: []*Bug{
+				{Tracker: "b", ID: "123", URL: "https://issuetracker.google.com/issues/123"},
+			},
+		},
+		"no bugs": {
+			Message:  "Subject\n\nBUG=None",
+			BugLinks: []*Bug{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseGitCommitLog([]*git.Commit{createCommitWithMessage(test.Message)})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(res[0].BugLinks, test.BugLinks) {
+				t.Errorf("expected bug links %+v, got %+v", test.BugLinks, res[0].BugLinks)
+			}
+		})
+	}
+}
+
+func TestFooters(t *testing.T) {
+	tests := map[string]struct {
+		Message string
+		Footers map[string][]string
+	}{
+		"gerrit footers": {
+			Message: `kernel: Enable CONFIG_IPV6_SEG6_LWTUNNEL
+
+BUG=b/123
+
+Change-Id: I0b6895f7860921f6bed25090d64f8489dbeeb19e
+Cq-Depend: chromium:2268290, chromium:2268291
+Signed-off-by: Jane Doe <jane@example.com>
+Reviewed-by: Allen Li <ayatane@chromium.org>
+Reviewed-by: Amin Hassani <ahassani@chromium.org>`,
+			Footers: map[string][]string{
+				"Change-Id":     {"I0b6895f7860921f6bed25090d64f8489dbeeb19e"},
+				"Cq-Depend":     {"chromium:2268290, chromium:2268291"},
+				"Signed-off-by": {"Jane Doe <jane@example.com>"},
+				"Reviewed-by":   {"Allen Li <ayatane@chromium.org>", "Amin Hassani <ahassani@chromium.org>"},
+			},
+		},
+		"only last paragraph": {
+			Message: "Subject\n\nNote: not a footer\n\nChange-Id: I123\nnot a footer either",
+			Footers: map[string][]string{"Change-Id": {"I123"}},
+		},
+		"subject only": {
+			Message: "kernel: Update config",
+		},
+		"no footers": {
+			Message: "Subject\n\nBody text.",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseGitCommitLog([]*git.Commit{createCommitWithMessage(test.Message)})
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(res[0].Footers, test.Footers) {
+				t.Errorf("expected footers %v, got %v", test.Footers, res[0].Footers)
+			}
+		})
+	}
+}
+
+func TestCommitFooter(t *testing.T) {
+	commit := &Commit{Footers: map[string][]string{
+		"Reviewed-by": {"Allen Li <ayatane@chromium.org>"},
+		"Reviewed-By": {"Amin Hassani <ahassani@chromium.org>"},
+	}}
+	reviewers := commit.Footer("reviewed-by")
+	if len(reviewers) != 2 {
+		t.Errorf("expected 2 reviewers, got %v", reviewers)
+	}
+	if values := commit.Footer("Change-Id"); len(values) != 0 {
+		t.Errorf("expected no Change-Id footer, got %v", values)
+	}
+}
+
+func TestCVEs(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		expected []string
+	}{
+		"No CVE": {
+			message: "Update README",
+		},
+		"Subject": {
+			message:  "sudo: fix heap overflow (CVE-2021-3156)",
+			expected: []string{"CVE-2021-3156"},
+		},
+		"Body And Footers": {
+			message:  "runc: upgrade to v1.0.3\n\nFixes cve-2021-43784 and CVE-2022-29162.\n\nBUG=b/123\nCVE: CVE-2021-43784\nCVE: CVE-2022-29162",
+			expected: []string{"CVE-2021-43784", "CVE-2022-29162"},
+		},
+		"Malformed": {
+			message: "Fix CVE-21-3156 and CVE-2021-31",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cves(createCommitWithMessage(test.message)); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected CVEs %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+const (
+	githubHost       string = "github.com"
+	githubAPIURL     string = "https://api.github.com"
+	githubPageSize   int    = 100
+	githubAPIVersion string = "application/vnd.github.v3+json"
+)
+
+// isGitHubRemote reports whether a remote URL from a manifest file, with its
+// scheme removed, points to GitHub. ex. "github.com/" or "github.com/google"
+func isGitHubRemote(remoteURL string) bool {
+	return remoteURL == githubHost || strings.HasPrefix(remoteURL, githubHost+"/")
+}
+
+// githubClient retrieves commit logs and files from GitHub through its REST
+// API. It implements utils.GitilesService, so repositories hosted on GitHub
+// are handled like any other.
+//
+// Page tokens are the number of commits already returned. Gitiles excludes
+// ancestors through the compare API, whose commits are listed oldest first,
+// so the commits of each page are reversed to match the Gitiles order.
+type githubClient struct {
+	httpClient *http.Client
+	apiURL     string
+	// Owner prepended to project names when the remote URL includes one
+	owner string
+
+	mu sync.Mutex
+	// Number of commits between a base and head, keyed by compare path
+	compareTotals map[string]int
+}
+
+func newGitHubClient(httpClient *http.Client, remoteURL string) *githubClient {
+	return &githubClient{
+		httpClient:    httpClient,
+		apiURL:        githubAPIURL,
+		owner:         strings.Trim(strings.TrimPrefix(remoteURL, githubHost), "/"),
+		compareTotals: make(map[string]int),
+	}
+}
+
+type githubUser struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type githubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Author    githubUser `json:"author"`
+		Committer githubUser `json:"committer"`
+		Message   string     `json:"message"`
+		Tree      struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	} `json:"commit"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+func (c *githubCommit) proto() *git.Commit {
+	parents := make([]string, len(c.Parents))
+	for i, parent := range c.Parents {
+		parents[i] = parent.SHA
+	}
+	user := func(u githubUser) *git.Commit_User {
+		return &git.Commit_User{Name: u.Name, Email: u.Email, Time: timestamppb.New(u.Date)}
+	}
+	return &git.Commit{
+		Id:        c.SHA,
+		Tree:      c.Commit.Tree.SHA,
+		Parents:   parents,
+		Author:    user(c.Commit.Author),
+		Committer: user(c.Commit.Committer),
+		Message:   c.Commit.Message,
+	}
+}
+
+// httpToGRPCCode converts a GitHub response status into the gRPC code Gitiles
+// clients would return, so utils.GitilesErrCode and the retry policy treat
+// both backends alike.
+func httpToGRPCCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// project returns the "owner/repo" name of a repository.
+func (c *githubClient) project(name string) string {
+	return strings.TrimSuffix(path.Join(c.owner, name), ".git")
+}
+
+// get sends a GET request to the GitHub API and returns the response body and
+// headers.
+func (c *githubClient) get(ctx context.Context, apiPath string, params url.Values, accept string) ([]byte, http.Header, error) {
+	u := c.apiURL + apiPath
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "failed to create GitHub request: %v", err)
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, nil, status.Errorf(codes.Unavailable, "failed to send GitHub request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Unavailable, "failed to read GitHub response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, status.Errorf(httpToGRPCCode(resp.StatusCode), "unexpected HTTP %d from GitHub for %s: %s", resp.StatusCode, apiPath, body)
+	}
+	return body, resp.Header, nil
+}
+
+// getJSON sends a GET request to the GitHub API and decodes the JSON response
+// into out.
+func (c *githubClient) getJSON(ctx context.Context, apiPath string, params url.Values, out interface{}) (http.Header, error) {
+	body, header, err := c.get(ctx, apiPath, params, githubAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to parse GitHub response for %s: %v", apiPath, err)
+	}
+	return header, nil
+}
+
+func hasNextPage(header http.Header) bool {
+	return strings.Contains(header.Get("Link"), `rel="next"`)
+}
+
+func pageParams(page int, params url.Values) url.Values {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("per_page", strconv.Itoa(githubPageSize))
+	params.Set("page", strconv.Itoa(page))
+	return params
+}
+
+// listCommits returns up to size commits reachable from committish, newest
+// first, skipping the first offset commits.
+func (c *githubClient) listCommits(ctx context.Context, project, committish string, offset, size int) ([]*git.Commit, bool, error) {
+	var output []*git.Commit
+	skip := offset % githubPageSize
+	for page := offset/githubPageSize + 1; ; page++ {
+		var batch []*githubCommit
+		header, err := c.getJSON(ctx, fmt.Sprintf("/repos/%s/commits", project), pageParams(page, url.Values{"sha": {committish}}), &batch)
+		if err != nil {
+			return nil, false, err
+		}
+		if skip > len(batch) {
+			skip = len(batch)
+		}
+		for i, commit := range batch[skip:] {
+			if len(output) == size {
+				return output, skip+i < len(batch) || hasNextPage(header), nil
+			}
+			output = append(output, commit.proto())
+		}
+		skip = 0
+		if !hasNextPage(header) {
+			return output, false, nil
+		}
+		if len(output) == size {
+			return output, true, nil
+		}
+	}
+}
+
+type githubComparison struct {
+	TotalCommits int             `json:"total_commits"`
+	Commits      []*githubCommit `json:"commits"`
+}
+
+// compareTotal returns the number of commits reachable from head but not from
+// base.
+func (c *githubClient) compareTotal(ctx context.Context, comparePath string) (int, error) {
+	c.mu.Lock()
+	total, ok := c.compareTotals[comparePath]
+	c.mu.Unlock()
+	if ok {
+		return total, nil
+	}
+	comparison := &githubComparison{}
+	if _, err := c.getJSON(ctx, comparePath, url.Values{"per_page": {"1"}, "page": {"1"}}, comparison); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.compareTotals[comparePath] = comparison.TotalCommits
+	c.mu.Unlock()
+	return comparison.TotalCommits, nil
+}
+
+// compareCommits returns up to size commits reachable from head but not from
+// base, newest first, skipping the first offset commits.
+func (c *githubClient) compareCommits(ctx context.Context, project, base, head string, offset, size int) ([]*git.Commit, bool, error) {
+	comparePath := fmt.Sprintf("/repos/%s/compare/%s...%s", project, url.PathEscape(base), url.PathEscape(head))
+	total, err := c.compareTotal(ctx, comparePath)
+	if err != nil {
+		return nil, false, err
+	}
+	// The compare API lists commits oldest first, so the newest commits
+	// following offset are at the end of the chronological list.
+	end := total - offset
+	if end <= 0 {
+		return nil, false, nil
+	}
+	start := end - size
+	if start < 0 {
+		start = 0
+	}
+	firstPage := start/githubPageSize + 1
+	var chronological []*githubCommit
+	for page := firstPage; page <= (end-1)/githubPageSize+1; page++ {
+		comparison := &githubComparison{}
+		if _, err := c.getJSON(ctx, comparePath, pageParams(page, nil), comparison); err != nil {
+			return nil, false, err
+		}
+		chronological = append(chronological, comparison.Commits...)
+	}
+	first := start - (firstPage-1)*githubPageSize
+	last := end - (firstPage-1)*githubPageSize
+	if last > len(chronological) {
+		last = len(chronological)
+	}
+	if first > last {
+		first = last
+	}
+	output := make([]*git.Commit, 0, last-first)
+	for i := last - 1; i >= first; i-- {
+		output = append(output, chronological[i].proto())
+	}
+	return output, start > 0, nil
+}
+
+// Log implements utils.GitilesService.
+func (c *githubClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	offset := 0
+	if in.PageToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(in.PageToken); err != nil || offset < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid GitHub page token %q", in.PageToken)
+		}
+	}
+	size := int(in.PageSize)
+	if size <= 0 {
+		size = githubPageSize
+	}
+	project := c.project(in.Project)
+	log.Debugf("githubClient: fetching %d commits of %s from %s to %s", size, project, in.ExcludeAncestorsOf, in.Committish)
+	var commits []*git.Commit
+	var more bool
+	var err error
+	if in.ExcludeAncestorsOf == "" {
+		commits, more, err = c.listCommits(ctx, project, in.Committish, offset, size)
+	} else {
+		commits, more, err = c.compareCommits(ctx, project, in.ExcludeAncestorsOf, in.Committish, offset, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := &gitilesProto.LogResponse{Log: commits}
+	if more {
+		resp.NextPageToken = strconv.Itoa(offset + len(commits))
+	}
+	return resp, nil
+}
+
+// DownloadFile implements utils.GitilesService.
+func (c *githubClient) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	apiPath := fmt.Sprintf("/repos/%s/contents/%s", c.project(in.Project), strings.TrimPrefix(in.Path, "/"))
+	body, _, err := c.get(ctx, apiPath, url.Values{"ref": {in.Committish}}, "application/vnd.github.v3.raw")
+	if err != nil {
+		return nil, err
+	}
+	return &gitilesProto.DownloadFileResponse{Contents: string(body)}, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+const githubTestCommits = 250
+
+func githubTestSHA(i int) string {
+	return fmt.Sprintf("c%03d", i)
+}
+
+// fakeGitHub serves a repository google/go-cmp whose history is the linear
+// sequence of commits c000 to c249, c249 being the newest.
+func fakeGitHub(t *testing.T) *httptest.Server {
+	commit := func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"sha": githubTestSHA(i),
+			"commit": map[string]interface{}{
+				"author":  map[string]string{"name": "Jane Doe", "email": "jane@example.com", "date": "2020-02-01T08:15:00Z"},
+				"message": fmt.Sprintf("Commit %d\n\nChange-Id: I%d", i, i),
+			},
+		}
+	}
+	page := func(w http.ResponseWriter, r *http.Request, total int) (int, int) {
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start, end := (p-1)*perPage, p*perPage
+		if end < total {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, r.URL.Path, p+1))
+		} else {
+			end = total
+		}
+		if start > end {
+			start = end
+		}
+		return start, end
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/google/go-cmp/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha") != githubTestSHA(githubTestCommits-1) {
+			http.NotFound(w, r)
+			return
+		}
+		start, end := page(w, r, githubTestCommits)
+		var commits []interface{}
+		for i := start; i < end; i++ {
+			commits = append(commits, commit(githubTestCommits-1-i))
+		}
+		json.NewEncoder(w).Encode(commits)
+	})
+	// Compares c049 with c249, listing c050 to c249 oldest first
+	mux.HandleFunc("/repos/google/go-cmp/compare/c049...c249", func(w http.ResponseWriter, r *http.Request) {
+		const base, total = 50, githubTestCommits - 50
+		start, end := page(w, r, total)
+		var commits []interface{}
+		for i := start; i < end; i++ {
+			commits = append(commits, commit(base+i))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"total_commits": total, "commits": commits})
+	})
+	mux.HandleFunc("/repos/cos/manifest/contents/snapshot.xml", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != "refs/tags/15000.0.0" || r.Header.Get("Accept") != "application/vnd.github.v3.raw" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<manifest/>"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestGitHubClient(server *httptest.Server, remoteURL string) *githubClient {
+	client := newGitHubClient(server.Client(), remoteURL)
+	client.apiURL = server.URL
+	return client
+}
+
+func commitRange(newest, oldest int) []string {
+	var shas []string
+	for i := newest; i >= oldest; i-- {
+		shas = append(shas, githubTestSHA(i))
+	}
+	return shas
+}
+
+func TestGitHubLog(t *testing.T) {
+	server := fakeGitHub(t)
+	defer server.Close()
+	tests := map[string]struct {
+		remoteURL     string
+		req           *gitilesProto.LogRequest
+		expectedSHAs  []string
+		expectedToken string
+		expectedCode  string
+	}{
+		"First Page": {
+			remoteURL:     "github.com/",
+			req:           &gitilesProto.LogRequest{Project: "google/go-cmp.git", Committish: "c249", PageSize: 150},
+			expectedSHAs:  commitRange(249, 100),
+			expectedToken: "150",
+		},
+		"Last Page": {
+			remoteURL:    "github.com/google",
+			req:          &gitilesProto.LogRequest{Project: "go-cmp", Committish: "c249", PageToken: "150", PageSize: 150},
+			expectedSHAs: commitRange(99, 0),
+		},
+		"Exact Page End": {
+			remoteURL:     "github.com",
+			req:           &gitilesProto.LogRequest{Project: "google/go-cmp", Committish: "c249", PageSize: 100},
+			expectedSHAs:  commitRange(249, 150),
+			expectedToken: "100",
+		},
+		"Compare First Page": {
+			remoteURL:     "github.com",
+			req:           &gitilesProto.LogRequest{Project: "google/go-cmp", Committish: "c249", ExcludeAncestorsOf: "c049", PageSize: 150},
+			expectedSHAs:  commitRange(249, 100),
+			expectedToken: "150",
+		},
+		"Compare Last Page": {
+			remoteURL:    "github.com",
+			req:          &gitilesProto.LogRequest{Project: "google/go-cmp", Committish: "c249", ExcludeAncestorsOf: "c049", PageToken: "150", PageSize: 150},
+			expectedSHAs: commitRange(99, 50),
+		},
+		"Unknown Committish": {
+			remoteURL:    "github.com",
+			req:          &gitilesProto.LogRequest{Project: "google/go-cmp", Committish: "c999"},
+			expectedCode: "404",
+		},
+		"Invalid Page Token": {
+			remoteURL:    "github.com",
+			req:          &gitilesProto.LogRequest{Project: "google/go-cmp", Committish: "c249", PageToken: "abc"},
+			expectedCode: "400",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := newTestGitHubClient(server, test.remoteURL).Log(context.Background(), test.req)
+			if test.expectedCode != "" {
+				if code := utils.GitilesErrCode(err); code != test.expectedCode {
+					t.Fatalf("expected error code %s, got %v", test.expectedCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Log failed: %v", err)
+			}
+			var shas []string
+			for _, commit := range resp.Log {
+				shas = append(shas, commit.Id)
+			}
+			if diff := cmp.Diff(test.expectedSHAs, shas); diff != "" {
+				t.Errorf("Log returned unexpected commits (-want +got):\n%s", diff)
+			}
+			if resp.NextPageToken != test.expectedToken {
+				t.Errorf("expected next page token %q, got %q", test.expectedToken, resp.NextPageToken)
+			}
+		})
+	}
+}
+
+func TestGitHubCommitsPage(t *testing.T) {
+	server := fakeGitHub(t)
+	defer server.Close()
+	client := newTestGitHubClient(server, "github.com")
+	commits, nextToken, err := utils.CommitsPage(context.Background(), client, "google/go-cmp", "c249", "c049", "", -1)
+	if err != nil {
+		t.Fatalf("CommitsPage failed: %v", err)
+	}
+	if len(commits) != githubTestCommits-50 || nextToken != "" {
+		t.Errorf("expected %d commits and no next page, got %d commits and token %q", githubTestCommits-50, len(commits), nextToken)
+	}
+	parsed, err := ParseGitCommitLog(commits)
+	if err != nil {
+		t.Fatalf("ParseGitCommitLog failed: %v", err)
+	}
+	if parsed[0].AuthorEmail != "jane@example.com" || parsed[0].Footer("Change-Id")[0] != "I249" {
+		t.Errorf("unexpected parsed commit %+v", parsed[0])
+	}
+}
+
+func TestGitHubDownloadFile(t *testing.T) {
+	server := fakeGitHub(t)
+	defer server.Close()
+	client := newTestGitHubClient(server, "github.com")
+	resp, err := client.DownloadFile(context.Background(), &gitilesProto.DownloadFileRequest{Project: "cos/manifest", Committish: "refs/tags/15000.0.0", Path: "snapshot.xml"})
+	if err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if resp.Contents != "<manifest/>" {
+		t.Errorf("expected manifest contents, got %q", resp.Contents)
+	}
+}
+
+func TestIsGitHubRemote(t *testing.T) {
+	tests := map[string]bool{
+		"github.com":                true,
+		"github.com/":               true,
+		"github.com/google":         true,
+		"cos.googlesource.com":      false,
+		"github.com.example.com":    false,
+		"chromium.googlesource.com": false,
+	}
+	for remoteURL, expected := range tests {
+		if got := isGitHubRemote(remoteURL); got != expected {
+			t.Errorf("isGitHubRemote(%q): expected %v, got %v", remoteURL, expected, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// JSONVersion is the version of the serialized changelog format written by
+// MarshalJSONChangelog. It is increased whenever a field is removed or its
+// meaning changes; adding a field does not change the version.
+//
+// Version 1 has the following layout:
+//
+//	{
+//	  "version": 1,
+//	  "source": "15000.0.0",
+//	  "target": "15001.0.0",
+//	  "sourceBuild": <BuildInfo>,
+//	  "targetBuild": <BuildInfo>,
+//	  "additions": {"<repo path>": <RepoLog>, ...},
+//	  "removals": {"<repo path>": <RepoLog>, ...},
+//	  "addedRepos": [<RepoChange>, ...],
+//	  "removedRepos": [<RepoChange>, ...],
+//	  "manifestChanges": [<ManifestChange>, ...]
+//	}
+//
+// where BuildInfo, RepoLog, RepoChange, ManifestChange, Commit and Bug objects
+// use the field names of the Go types. Repository and manifest changes are
+// omitted if there are none. Build metadata is only present if the changelog was generated by
+// ChangelogWithBuildInfo.
+const JSONVersion = 1
+
+// Document is a serialized changelog between two builds
+type Document struct {
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	// Metadata of the source and target builds, if it was retrieved
+	SourceBuild *BuildInfo `json:"sourceBuild,omitempty"`
+	TargetBuild *BuildInfo `json:"targetBuild,omitempty"`
+	// Commits present in the target build but not in the source build
+	Additions map[string]*RepoLog `json:"additions"`
+	// Commits present in the source build but not in the target build
+	Removals map[string]*RepoLog `json:"removals"`
+	// Repositories present in the target build but not in the source build
+	AddedRepos []*RepoChange `json:"addedRepos,omitempty"`
+	// Repositories present in the source build but not in the target build
+	RemovedRepos []*RepoChange `json:"removedRepos,omitempty"`
+	// Settings of the manifest files that differ between the builds
+	ManifestChanges []*ManifestChange `json:"manifestChanges,omitempty"`
+}
+
+// MarshalJSONChangelog serializes the changelog returned by Changelog into the
+// versioned JSON format described by JSONVersion.
+func MarshalJSONChangelog(source, target string, additions, removals map[string]*RepoLog) ([]byte, error) {
+	return MarshalJSONDocument(&Document{
+		Source:    source,
+		Target:    target,
+		Additions: additions,
+		Removals:  removals,
+	})
+}
+
+// MarshalJSONDocument serializes a changelog returned by
+// ChangelogWithBuildInfo, or built by the caller, into the versioned JSON
+// format described by JSONVersion. The version of doc is ignored.
+func MarshalJSONDocument(doc *Document) ([]byte, error) {
+	out := *doc
+	out.Version = JSONVersion
+	if out.Additions == nil {
+		out.Additions = map[string]*RepoLog{}
+	}
+	if out.Removals == nil {
+		out.Removals = map[string]*RepoLog{}
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal changelog from %s to %s: %v", doc.Source, doc.Target, err)
+	}
+	return data, nil
+}
+
+// UnmarshalJSONChangelog parses a changelog serialized by MarshalJSONChangelog.
+// Returns an error if the data was written in an unsupported format version.
+func UnmarshalJSONChangelog(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal changelog: %v", err)
+	}
+	switch {
+	case doc.Version == 0:
+		return nil, errors.New("failed to unmarshal changelog: missing version")
+	case doc.Version > JSONVersion:
+		return nil, fmt.Errorf("failed to unmarshal changelog: unsupported version %d, expected at most %d", doc.Version, JSONVersion)
+	}
+	return doc, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONChangelogRoundTrip(t *testing.T) {
+	additions := map[string]*RepoLog{
+		"src/third_party/kernel/v5.4": {
+			Commits: []*Commit{{
+				SHA:           "3333333333333333333333333333333333333333",
+				AuthorName:    "Jane Doe",
+				AuthorEmail:   "jane@example.com",
+				CommitterName: "John Doe",
+				Subject:       "net: fix leak",
+				Bugs:          []string{"b/123"},
+				BugLinks:      []*Bug{{Tracker: "b", ID: "123", URL: "https://issuetracker.google.com/issues/123"}},
+				ReleaseNote:   "Fixed a memory leak",
+				CommitTime:    "Mon, 2 Jan 2006",
+				Footers:       map[string][]string{"Change-Id": {"I0b6895f7860921f6bed25090d64f8489dbeeb19e"}},
+			}},
+			InstanceURL:    "cos.googlesource.com",
+			Repo:           "third_party/kernel",
+			SourceSHA:      "1111111111111111111111111111111111111111",
+			TargetSHA:      "2222222222222222222222222222222222222222",
+			HasMoreCommits: true,
+		},
+	}
+	data, err := MarshalJSONChangelog("15000.0.0", "15001.0.0", additions, nil)
+	if err != nil {
+		t.Fatalf("MarshalJSONChangelog failed: %v", err)
+	}
+	doc, err := UnmarshalJSONChangelog(data)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONChangelog failed: %v", err)
+	}
+	want := &Document{
+		Version:   JSONVersion,
+		Source:    "15000.0.0",
+		Target:    "15001.0.0",
+		Additions: additions,
+		Removals:  map[string]*RepoLog{},
+	}
+	if diff := cmp.Diff(want, doc); diff != "" {
+		t.Errorf("round trip returned unexpected document (-want +got):\n%s", diff)
+	}
+}
+
+// TestJSONChangelogFieldNames guards the documented field names of the
+// serialized format against accidental renames.
+func TestJSONChangelogFieldNames(t *testing.T) {
+	additions := map[string]*RepoLog{"path": {Commits: []*Commit{{BugLinks: []*Bug{{}}}}}}
+	data, err := MarshalJSONChangelog("source", "target", additions, nil)
+	if err != nil {
+		t.Fatalf("MarshalJSONChangelog failed: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to unmarshal changelog: %v", err)
+	}
+	repoLog := doc["additions"].(map[string]interface{})["path"].(map[string]interface{})
+	commit := repoLog["Commits"].([]interface{})[0].(map[string]interface{})
+	bug := commit["BugLinks"].([]interface{})[0].(map[string]interface{})
+	tests := map[string]struct {
+		object map[string]interface{}
+		fields []string
+	}{
+		"Document": {
+			object: doc,
+			fields: []string{"version", "source", "target", "additions", "removals"},
+		},
+		"RepoLog": {
+			object: repoLog,
+			fields: []string{"Commits", "InstanceURL", "Repo", "SourceSHA", "TargetSHA", "HasMoreCommits"},
+		},
+		"Commit": {
+			object: commit,
+			fields: []string{"SHA", "AuthorName", "AuthorEmail", "CommitterName", "Subject", "Bugs", "BugLinks", "ReleaseNote", "CommitTime"},
+		},
+		"Bug": {
+			object: bug,
+			fields: []string{"Tracker", "ID", "URL"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if len(test.object) != len(test.fields) {
+				t.Errorf("expected %d fields, got %d: %v", len(test.fields), len(test.object), test.object)
+			}
+			for _, field := range test.fields {
+				if _, ok := test.object[field]; !ok {
+					t.Errorf("expected field %s, got %v", field, test.object)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONChangelogVersion(t *testing.T) {
+	tests := map[string]string{
+		"Missing Version": `{"source": "1", "target": "2"}`,
+		"Future Version":  `{"version": 99, "source": "1", "target": "2"}`,
+		"Malformed":       `{"version": `,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := UnmarshalJSONChangelog([]byte(data)); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"sort"
+
+	"github.com/beevik/etree"
+)
+
+// ManifestChange is a setting of the manifest files that differs between the
+// two builds of a changelog, ex. the default revision of every project.
+type ManifestChange struct {
+	// Element holding the setting, one of "default", "remote <name>" or
+	// "project <path>"
+	Element string `json:"Element"`
+	// Attribute of the element, ex. "revision" or "fetch". For projects, the
+	// "remote" attribute is the GoB instance hosting the repository.
+	Attribute string `json:"Attribute"`
+	// Value of the attribute in each build. Empty if the build does not set it.
+	Source string `json:"Source"`
+	Target string `json:"Target"`
+}
+
+// RepoChange is a repository recorded in the manifest file of only one of the
+// two builds of a changelog.
+type RepoChange struct {
+	// Path of the repository in the build that contains it
+	Path string `json:"Path"`
+	// GoB instance hosting the repository, ex. "cos.googlesource.com"
+	InstanceURL string `json:"InstanceURL"`
+	Repo        string `json:"Repo"`
+	// Committish the manifest file pins the repository to
+	Revision string `json:"Revision"`
+	// Branch the manifest file records for the repository, ex.
+	// "refs/heads/release-R93". Empty if the manifest does not record it.
+	Branch string `json:"Branch,omitempty"`
+}
+
+// repoChanges lists the repositories of the target build that are not in the
+// source build, and the repositories of the source build that are not in the
+// target build. Repositories that only moved to another path are in both
+// builds. Both lists are sorted by path.
+func repoChanges(sourceRepos, targetRepos map[string]*repo) (added, removed []*RepoChange) {
+	return unmatchedRepos(targetRepos, matchSourceRepos(sourceRepos, targetRepos)),
+		unmatchedRepos(sourceRepos, matchSourceRepos(targetRepos, sourceRepos))
+}
+
+// unmatchedRepos returns the repositories of repos whose path is not a key of
+// matched, sorted by path.
+func unmatchedRepos(repos, matched map[string]*repo) []*RepoChange {
+	var output []*RepoChange
+	for path, r := range repos {
+		if _, ok := matched[path]; ok {
+			continue
+		}
+		output = append(output, &RepoChange{
+			Path:        path,
+			InstanceURL: r.InstanceURL,
+			Repo:        r.Repo,
+			Revision:    r.Committish,
+			Branch:      r.Branch,
+		})
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Path < output[j].Path
+	})
+	return output
+}
+
+// settings returns the attributes of the <default> and <remote> elements of a
+// manifest, keyed by element as in ManifestChange and then by attribute.
+// Attributes of an element repeated in included files are merged, the last
+// value winning.
+func (m *manifestElements) settings() map[string]map[string]string {
+	output := make(map[string]map[string]string)
+	add := func(element string, elem *etree.Element) {
+		if output[element] == nil {
+			output[element] = make(map[string]string)
+		}
+		for _, attr := range elem.Attr {
+			output[element][attr.Key] = attr.Value
+		}
+	}
+	for _, def := range m.defaults {
+		add("default", def)
+	}
+	for _, remote := range m.remotes {
+		add("remote "+remote.SelectAttrValue("name", ""), remote)
+	}
+	return output
+}
+
+// manifestChanges compares the <default> and <remote> elements of the source
+// and target manifests, and the remote of each project present at the same
+// path in both builds. Changes are sorted by element and attribute.
+func manifestChanges(source, target *manifestElements, sourceRepos, targetRepos map[string]*repo) []*ManifestChange {
+	var output []*ManifestChange
+	sourceSettings, targetSettings := source.settings(), target.settings()
+	elements := make(map[string]bool)
+	for element := range sourceSettings {
+		elements[element] = true
+	}
+	for element := range targetSettings {
+		elements[element] = true
+	}
+	for element := range elements {
+		sourceAttrs, targetAttrs := sourceSettings[element], targetSettings[element]
+		attrs := make(map[string]bool)
+		for attr := range sourceAttrs {
+			attrs[attr] = true
+		}
+		for attr := range targetAttrs {
+			attrs[attr] = true
+		}
+		for attr := range attrs {
+			// The name of a remote is part of its element
+			if attr == "name" || sourceAttrs[attr] == targetAttrs[attr] {
+				continue
+			}
+			output = append(output, &ManifestChange{
+				Element:   element,
+				Attribute: attr,
+				Source:    sourceAttrs[attr],
+				Target:    targetAttrs[attr],
+			})
+		}
+	}
+	for path, targetRepo := range targetRepos {
+		sourceRepo, ok := sourceRepos[path]
+		if !ok || sourceRepo.InstanceURL == targetRepo.InstanceURL {
+			continue
+		}
+		output = append(output, &ManifestChange{
+			Element:   "project " + path,
+			Attribute: "remote",
+			Source:    sourceRepo.InstanceURL,
+			Target:    targetRepo.InstanceURL,
+		})
+	}
+	sort.Slice(output, func(i, j int) bool {
+		if output[i].Element != output[j].Element {
+			return output[i].Element < output[j].Element
+		}
+		return output[i].Attribute < output[j].Attribute
+	})
+	return output
+}
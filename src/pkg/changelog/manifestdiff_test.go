@@ -0,0 +1,163 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+func TestRepoChanges(t *testing.T) {
+	kernel := &repo{Repo: "third_party/kernel", Path: "src/third_party/kernel", InstanceURL: cosInstance, Committish: "k1", Branch: "refs/heads/cos-5.10"}
+	movedKernel := &repo{Repo: "third_party/kernel", Path: "src/kernel", InstanceURL: cosInstance, Committish: "k2"}
+	overlays := &repo{Repo: "cos/overlays/board-overlays", Path: "src/overlays", InstanceURL: cosInstance, Committish: "o1"}
+	dev := &repo{Repo: "cos/platform/dev", Path: "src/platform/dev", InstanceURL: cosInstance, Committish: "d1"}
+	tests := map[string]struct {
+		source          map[string]*repo
+		target          map[string]*repo
+		expectedAdded   []*RepoChange
+		expectedRemoved []*RepoChange
+	}{
+		"Unchanged": {
+			source: map[string]*repo{"src/overlays": overlays},
+			target: map[string]*repo{"src/overlays": overlays},
+		},
+		"Added And Removed": {
+			source:          map[string]*repo{"src/overlays": overlays, "src/third_party/kernel": kernel},
+			target:          map[string]*repo{"src/overlays": overlays, "src/platform/dev": dev},
+			expectedAdded:   []*RepoChange{{Path: "src/platform/dev", InstanceURL: cosInstance, Repo: "cos/platform/dev", Revision: "d1"}},
+			expectedRemoved: []*RepoChange{{Path: "src/third_party/kernel", InstanceURL: cosInstance, Repo: "third_party/kernel", Revision: "k1", Branch: "refs/heads/cos-5.10"}},
+		},
+		"Moved": {
+			source: map[string]*repo{"src/third_party/kernel": kernel},
+			target: map[string]*repo{"src/kernel": movedKernel},
+		},
+		"Sorted": {
+			source: map[string]*repo{},
+			target: map[string]*repo{"src/platform/dev": dev, "src/overlays": overlays},
+			expectedAdded: []*RepoChange{
+				{Path: "src/overlays", InstanceURL: cosInstance, Repo: "cos/overlays/board-overlays", Revision: "o1"},
+				{Path: "src/platform/dev", InstanceURL: cosInstance, Repo: "cos/platform/dev", Revision: "d1"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			added, removed := repoChanges(test.source, test.target)
+			if diff := cmp.Diff(test.expectedAdded, added); diff != "" {
+				t.Errorf("repoChanges returned unexpected added repositories (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.expectedRemoved, removed); diff != "" {
+				t.Errorf("repoChanges returned unexpected removed repositories (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChangelogRepoChanges(t *testing.T) {
+	g := fakes.NewGitiles()
+	g.Commits["third_party/kernel"] = []*git.Commit{{Id: "k1"}}
+	g.Commits["cos/overlays/board-overlays"] = []*git.Commit{{Id: "o1"}}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = fakeManifest("k1", "o1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="k1"/>
+</manifest>`
+	opts := &Options{GitilesClient: func(string) (utils.GitilesService, error) { return g, nil }}
+
+	doc, err := ChangelogWithBuildInfo(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("ChangelogWithBuildInfo failed: %v", err)
+	}
+	expected := []*RepoChange{{Path: "src/overlays", InstanceURL: cosInstance, Repo: "cos/overlays/board-overlays", Revision: "o1", Branch: "refs/heads/master"}}
+	if diff := cmp.Diff(expected, doc.RemovedRepos); diff != "" {
+		t.Errorf("unexpected removed repositories (-want +got):\n%s", diff)
+	}
+	if len(doc.AddedRepos) != 0 {
+		t.Errorf("expected no added repositories, got %v", doc.AddedRepos)
+	}
+	if len(doc.ManifestChanges) != 0 {
+		t.Errorf("expected no manifest changes, got %v", doc.ManifestChanges)
+	}
+}
+
+func TestManifestChanges(t *testing.T) {
+	tests := map[string]struct {
+		source   string
+		target   string
+		expected []*ManifestChange
+	}{
+		"Unchanged": {
+			source: fakeManifest("k1", "o1"),
+			target: fakeManifest("k2", "o2"),
+		},
+		"Default Revision": {
+			source: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/release-R93" sync-j="4"/>
+</manifest>`,
+			target: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/release-R97"/>
+</manifest>`,
+			expected: []*ManifestChange{
+				{Element: "default", Attribute: "revision", Source: "refs/heads/release-R93", Target: "refs/heads/release-R97"},
+				{Element: "default", Attribute: "sync-j", Source: "4"},
+			},
+		},
+		"Remotes": {
+			source: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <remote fetch="https://chromium.googlesource.com" name="chromium"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" remote="chromium" revision="k1"/>
+</manifest>`,
+			target: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos" review="https://cos-review.googlesource.com"/>
+  <remote fetch="https://github.com" name="github"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="third_party/kernel" path="src/third_party/kernel" revision="k2"/>
+</manifest>`,
+			expected: []*ManifestChange{
+				{Element: "project src/third_party/kernel", Attribute: "remote", Source: "chromium.googlesource.com", Target: "cos.googlesource.com"},
+				{Element: "remote chromium", Attribute: "fetch", Source: "https://chromium.googlesource.com"},
+				{Element: "remote cos", Attribute: "review", Target: "https://cos-review.googlesource.com"},
+				{Element: "remote github", Attribute: "fetch", Target: "https://github.com"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			source, err := loadManifestElements(test.source, nil)
+			if err != nil {
+				t.Fatalf("loadManifestElements failed: %v", err)
+			}
+			target, err := loadManifestElements(test.target, nil)
+			if err != nil {
+				t.Fatalf("loadManifestElements failed: %v", err)
+			}
+			changes := manifestChanges(source, target, source.repos(), target.repos())
+			if diff := cmp.Diff(test.expected, changes); diff != "" {
+				t.Errorf("manifestChanges returned unexpected changes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
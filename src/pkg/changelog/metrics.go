@@ -0,0 +1,61 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// Cache lookup kinds reported to Meter.ObserveCacheLookup
+const (
+	ManifestLookup = "manifest"
+	CommitsLookup  = "commits"
+)
+
+// Meter records operational metrics of changelog generation, so they can be
+// exported to a monitoring system such as Prometheus or OpenTelemetry. Its
+// methods are called concurrently.
+type Meter interface {
+	// ObserveChangelog is called once for every Changelog or ChangelogToHead
+	// call, with the error it returned.
+	ObserveChangelog(latency time.Duration, err utils.ChangelogError)
+	// ObserveFetch is called once the commits of a repository have been
+	// requested from its Git instance. Commits served from the cache are not
+	// fetched.
+	ObserveFetch(instanceURL, repo string, latency time.Duration, err utils.ChangelogError)
+	// ObserveCacheLookup is called for every lookup in Options.Cache, where
+	// kind is either ManifestLookup or CommitsLookup.
+	ObserveCacheLookup(kind string, hit bool)
+}
+
+// noopMeter is the Meter used when Options.Meter is not set
+type noopMeter struct{}
+
+func (noopMeter) ObserveChangelog(time.Duration, utils.ChangelogError)             {}
+func (noopMeter) ObserveFetch(string, string, time.Duration, utils.ChangelogError) {}
+func (noopMeter) ObserveCacheLookup(string, bool)                                  {}
+
+// meteredCacheGet looks up key like cacheGet and reports the lookup to meter.
+// Lookups are not reported if there is no cache.
+func meteredCacheGet(cache Cache, meter Meter, kind, key string, v interface{}) bool {
+	if cache == nil {
+		return false
+	}
+	hit := cacheGet(cache, key, v)
+	meter.ObserveCacheLookup(kind, hit)
+	return hit
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingMeter is a Meter that records a description of every observation
+type recordingMeter struct {
+	mu         sync.Mutex
+	changelogs []string
+	fetches    []string
+	lookups    []string
+}
+
+func errorCode(err utils.ChangelogError) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.HTTPCode()
+}
+
+func (m *recordingMeter) ObserveChangelog(latency time.Duration, err utils.ChangelogError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.changelogs = append(m.changelogs, errorCode(err))
+}
+
+func (m *recordingMeter) ObserveFetch(instanceURL, repo string, latency time.Duration, err utils.ChangelogError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetches = append(m.fetches, instanceURL+"/"+repo+" "+errorCode(err))
+}
+
+func (m *recordingMeter) ObserveCacheLookup(kind string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.lookups = append(m.lookups, kind+" "+result)
+}
+
+func TestAdditionsMetrics(t *testing.T) {
+	const cachedSHA = "1111111111111111111111111111111111111111"
+	server := fakeGitHub(t)
+	defer server.Close()
+	clients := map[string]utils.GitilesService{
+		"github.com": newTestGitHubClient(server, "github.com"),
+	}
+	targetRepos := map[string]*repo{
+		"src/cached": {Repo: "cos/cached", Path: "src/cached", InstanceURL: cosInstance, Committish: cachedSHA},
+		"src/go-cmp": {Repo: "google/go-cmp", Path: "src/go-cmp", InstanceURL: "github.com", Committish: githubTestSHA(githubTestCommits - 1)},
+	}
+	cache := NewMemoryCache(0)
+	cacheSet(cache, commitsCacheKey(cosInstance, "cos/cached", cachedSHA, "", 10, false), &cachedCommits{
+		Commits: []*Commit{{SHA: cachedSHA}},
+	})
+	meter := &recordingMeter{}
+	opts := &Options{Cache: cache, Meter: meter}
+	pool := newFetchPool(opts)
+	defer pool.close()
+	outputChan := make(chan additionsResult, 1)
+	additions(context.Background(), clients, map[string]*repo{}, targetRepos, 10, opts, pool, nil, outputChan)
+	if res := <-outputChan; res.Err != nil {
+		t.Fatalf("additions failed: %v", res.Err)
+	}
+	// Commits of a branch ref are never cached, so only the cached
+	// repository is looked up.
+	if diff := cmp.Diff([]string{"commits hit"}, meter.lookups); diff != "" {
+		t.Errorf("unexpected cache lookups (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"github.com/google/go-cmp ok"}, meter.fetches); diff != "" {
+		t.Errorf("unexpected fetches (-want +got):\n%s", diff)
+	}
+}
+
+func TestChangelogMetrics(t *testing.T) {
+	meter := &recordingMeter{}
+	if _, _, err := Changelog(context.Background(), nil, "15000.0.0", "15001.0.0", "cos-internal.googlesource.com", "cos/manifest-snapshots", "", 10, &Options{Meter: meter}); err == nil {
+		t.Fatal("expected Changelog to fail without an HTTP client")
+	}
+	if diff := cmp.Diff([]string{"500"}, meter.changelogs); diff != "" {
+		t.Errorf("unexpected changelog observations (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// Options holds optional settings for generating a changelog. A nil *Options
+// is equivalent to the zero value.
+type Options struct {
+	// IncludeRepos restricts the changelog to repositories matching at least
+	// one of the glob patterns. All repositories are included if empty.
+	// ex. []string{"third_party/kernel"}
+	IncludeRepos []string
+	// ExcludeRepos removes repositories matching any of the glob patterns from
+	// the changelog. Exclusions take precedence over inclusions.
+	// ex. []string{"src/overlays/*"}
+	ExcludeRepos []string
+	// RepoMapping maps the repositories named in manifest files to the
+	// repositories their commits are queried from, for instances where the
+	// manifest names are not queryable, ex. mirrors of Gerrit repositories.
+	// Keys and values are either a repository name applying to every
+	// instance, ex. "chromiumos/third_party/kernel", or a repository name
+	// prefixed by its instance, ex.
+	// "chromium.googlesource.com/chromiumos/third_party/kernel". A value with
+	// an instance also changes the instance queried. A key ending with "/"
+	// maps every repository under it to the same path under its value. Exact
+	// keys take precedence over instance-less keys and prefixes, and
+	// filters apply to the names of the manifest files.
+	RepoMapping map[string]string
+	// Cache stores manifest files and commit logs retrieved from Gitiles so
+	// later changelogs can reuse them. Nothing is cached if nil.
+	Cache Cache
+	// ManifestFileName is the path of the manifest file in the manifest
+	// repository. Defaults to utils.DefaultManifestFileName.
+	ManifestFileName string
+	// TagPrefix is prepended to a build number to form the ref of the
+	// build's manifest file. Defaults to utils.DefaultManifestTagPrefix.
+	// ex. "refs/tags/lakitu-release/"
+	TagPrefix string
+	// RequestsPerSecond limits the rate at which commit logs are requested
+	// from Gitiles, with bursts of up to RequestBurst requests. There is no
+	// limit if not positive.
+	RequestsPerSecond float64
+	RequestBurst      int
+	// MaxConcurrentRequests is the maximum number of repositories whose
+	// commit logs are requested at the same time. Defaults to 64.
+	MaxConcurrentRequests int
+	// Scheduler limits the Gitiles requests of the changelog instead of
+	// RequestsPerSecond and RequestBurst. Sharing it with other callers, ex.
+	// findbuild.BuildRequest.Scheduler, bounds their requests as a whole.
+	Scheduler *utils.Scheduler
+	// RetryPolicy describes how failed Gitiles requests of the changelog are
+	// retried. Defaults to the policy set on the context passed to Changelog
+	// by utils.WithRetryPolicy, or utils.DefaultRetryPolicy.
+	RetryPolicy *utils.RetryPolicy
+	// Pool runs the commit log requests of the changelog instead of a pool
+	// dedicated to it, to bound the requests of concurrent changelogs as a
+	// whole. RequestsPerSecond, RequestBurst, MaxConcurrentRequests and
+	// Scheduler are ignored if it is set.
+	Pool *WorkerPool
+	// BestEffort keeps generating the changelog when the commits of some
+	// repositories cannot be retrieved. Their RepoLog has no commits and its
+	// Error field describes the failure. By default the first failure aborts
+	// the whole changelog.
+	BestEffort bool
+	// ExcludeCherryPicks removes commits that were cherry-picked between the
+	// two builds from both changelogs, so they only list changes that are
+	// truly absent from the other build. Cherry-picks are always marked
+	// through Commit.CherryPickOf.
+	ExcludeCherryPicks bool
+	// DeduplicateChanges keeps a single commit for each Change-Id of a
+	// repository in each changelog, since a change can be reachable through
+	// several commits once diverged branches are merged back together. The
+	// removed commits are listed in Commit.Duplicates.
+	DeduplicateChanges bool
+	// GitHubHTTPClient is used for repositories hosted on github.com, ex. a
+	// client authenticating with a GitHub token to raise rate limits. The
+	// Gerrit client is never used for GitHub. Defaults to an unauthenticated
+	// client.
+	GitHubHTTPClient *http.Client
+	// PageSize controls how many commits are requested per Gitiles page, to
+	// trade the latency of each request against the number of requests.
+	// Fields left at zero use utils.DefaultPageSizePolicy.
+	PageSize utils.PageSizePolicy
+	// MaxCommitsPerRepo caps the number of commits retrieved for each
+	// repository, whatever the query size passed to Changelog, so a changelog
+	// spanning thousands of commits cannot exhaust memory. Truncated RepoLogs
+	// have HasMoreCommits and ContinuationCommittish set. There is no cap if
+	// not positive.
+	MaxCommitsPerRepo int
+	// Meter records the latency and errors of changelog requests and Gitiles
+	// fetches, and the hit rate of Cache. Nothing is recorded if nil.
+	Meter Meter
+	// FileChanges retrieves the files changed by each commit into
+	// Commit.Files, so changelogs can be filtered with FilterByPath. This
+	// makes Gitiles requests slower.
+	FileChanges bool
+	// ResolveCLs retrieves the Gerrit CL of each commit with a Change-Id into
+	// Commit.CLNumber and Commit.ReviewURL, so changelogs can link to code
+	// review. CLs are queried in batches for each repository, and commits
+	// whose CL cannot be found are left without one.
+	ResolveCLs bool
+	// GerritClient creates the client used to query each Gerrit host, ex.
+	// "cos-review.googlesource.com", instead of a client sending requests
+	// with the HTTP client passed to Changelog.
+	GerritClient func(host string) (GerritService, error)
+	// PublicInstances lists the GoB instances readable without credentials,
+	// ex. "cos.googlesource.com". They are queried anonymously if Changelog
+	// is passed a nil HTTP client, or once the credentials of the HTTP
+	// client are rejected or cannot be refreshed. Defaults to
+	// cos.googlesource.com, chromium.googlesource.com,
+	// android.googlesource.com and kernel.googlesource.com.
+	PublicInstances []string
+	// GitilesClient creates the client used to query each remote, ex.
+	// "cos.googlesource.com", instead of a Gitiles or GitHub client sending
+	// requests with the HTTP client passed to Changelog. It lets callers
+	// supply instrumented clients, or fakes serving fixtures in tests.
+	GitilesClient func(remoteURL string) (utils.GitilesService, error)
+	// PageTokenKey authenticates the NextPageToken of truncated RepoLogs, so
+	// ChangelogPage only follows tokens issued with the same key. Servers
+	// with several replicas must share it. Defaults to a random key generated
+	// when the process starts, so tokens do not outlive the process.
+	PageTokenKey []byte
+}
+
+func (o *Options) cache() Cache {
+	if o == nil {
+		return nil
+	}
+	return o.Cache
+}
+
+func (o *Options) meter() Meter {
+	if o == nil || o.Meter == nil {
+		return noopMeter{}
+	}
+	return o.Meter
+}
+
+func (o *Options) githubHTTPClient() *http.Client {
+	if o == nil || o.GitHubHTTPClient == nil {
+		return http.DefaultClient
+	}
+	return o.GitHubHTTPClient
+}
+
+// retryContext returns a copy of ctx carrying RetryPolicy, or ctx if it is
+// not set.
+func (o *Options) retryContext(ctx context.Context) context.Context {
+	if o == nil || o.RetryPolicy == nil {
+		return ctx
+	}
+	return utils.WithRetryPolicy(ctx, *o.RetryPolicy)
+}
+
+func (o *Options) pageTokenKey() []byte {
+	if o == nil || len(o.PageTokenKey) == 0 {
+		return defaultPageTokenKey
+	}
+	return o.PageTokenKey
+}
+
+func (o *Options) pageSizePolicy() utils.PageSizePolicy {
+	if o == nil {
+		return utils.DefaultPageSizePolicy
+	}
+	return o.PageSize
+}
+
+// querySize caps a query size passed to Changelog to MaxCommitsPerRepo.
+func (o *Options) querySize(querySize int) int {
+	if o == nil || o.MaxCommitsPerRepo <= 0 {
+		return querySize
+	}
+	if querySize == -1 || querySize > o.MaxCommitsPerRepo {
+		return o.MaxCommitsPerRepo
+	}
+	return querySize
+}
+
+func (o *Options) bestEffort() bool {
+	return o != nil && o.BestEffort
+}
+
+func (o *Options) fileChanges() bool {
+	return o != nil && o.FileChanges
+}
+
+// publicInstance reports whether a GoB instance can be queried without
+// credentials.
+func (o *Options) publicInstance(instanceURL string) bool {
+	instances := defaultPublicInstances
+	if o != nil && o.PublicInstances != nil {
+		instances = o.PublicInstances
+	}
+	for _, instance := range instances {
+		if instance == instanceURL {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Options) resolveCLs() bool {
+	return o != nil && o.ResolveCLs
+}
+
+func (o *Options) deduplicateChanges() bool {
+	return o != nil && o.DeduplicateChanges
+}
+
+func (o *Options) excludeCherryPicks() bool {
+	return o != nil && o.ExcludeCherryPicks
+}
+
+// manifestFileName returns the path of the manifest file in the manifest
+// repository.
+func (o *Options) manifestFileName() string {
+	if o == nil || o.ManifestFileName == "" {
+		return utils.DefaultManifestFileName
+	}
+	return o.ManifestFileName
+}
+
+// manifestRef returns the committish pointing to the manifest file of a
+// build. Commit SHAs of the manifest repository and full refs, ex.
+// "refs/tags/dev-weekly/2021-07-01", are used as is, so snapshots that do not
+// follow the build number tag layout can be referenced directly.
+func (o *Options) manifestRef(buildNum string) string {
+	if commitSHARe.MatchString(buildNum) || strings.HasPrefix(buildNum, "refs/") {
+		return buildNum
+	}
+	if o == nil || o.TagPrefix == "" {
+		return utils.DefaultManifestTagPrefix + buildNum
+	}
+	return o.TagPrefix + buildNum
+}
+
+// validate checks that every option holds a usable value.
+func (o *Options) validate() utils.ChangelogError {
+	if o == nil {
+		return nil
+	}
+	for from, to := range o.RepoMapping {
+		if from == "" || to == "" || strings.HasSuffix(from, "/") != strings.HasSuffix(to, "/") {
+			log.Errorf("validate: invalid repository mapping from %q to %q", from, to)
+			return utils.InvalidRepoMapping(from, to)
+		}
+	}
+	for _, patterns := range [][]string{o.IncludeRepos, o.ExcludeRepos} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				log.Errorf("validate: invalid repository filter %q: %v", pattern, err)
+				return utils.InvalidRepoFilter(pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// matchRepo reports whether a glob pattern matches either the name of a
+// repository or the path it is checked out at.
+func matchRepo(pattern string, repoData *repo) bool {
+	if ok, _ := path.Match(pattern, repoData.Repo); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, repoData.Path)
+	return ok
+}
+
+// wantRepo reports whether a repository passes the include and exclude
+// filters.
+func (o *Options) wantRepo(repoData *repo) bool {
+	if o == nil {
+		return true
+	}
+	for _, pattern := range o.ExcludeRepos {
+		if matchRepo(pattern, repoData) {
+			return false
+		}
+	}
+	if len(o.IncludeRepos) == 0 {
+		return true
+	}
+	for _, pattern := range o.IncludeRepos {
+		if matchRepo(pattern, repoData) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRepos removes the repositories that do not pass the include and
+// exclude filters from a repository mapping.
+func (o *Options) filterRepos(repos map[string]*repo) {
+	for repoPath, repoData := range repos {
+		if !o.wantRepo(repoData) {
+			log.Debugf("filterRepos: skipping repo %s at %s", repoData.Repo, repoPath)
+			delete(repos, repoPath)
+		}
+	}
+}
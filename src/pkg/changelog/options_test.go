@@ -0,0 +1,262 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testRepos() map[string]*repo {
+	return map[string]*repo{
+		"src/third_party/kernel/v5.4": {Repo: "third_party/kernel", Path: "src/third_party/kernel/v5.4"},
+		"src/overlays":                {Repo: "cos/overlays/board-overlays", Path: "src/overlays"},
+		"src/platform/dev":            {Repo: "cos/platform/dev", Path: "src/platform/dev"},
+	}
+}
+
+func TestFilterRepos(t *testing.T) {
+	tests := map[string]struct {
+		opts          *Options
+		expectedPaths []string
+	}{
+		"Nil Options": {
+			opts:          nil,
+			expectedPaths: []string{"src/overlays", "src/platform/dev", "src/third_party/kernel/v5.4"},
+		},
+		"Include By Name": {
+			opts:          &Options{IncludeRepos: []string{"third_party/kernel"}},
+			expectedPaths: []string{"src/third_party/kernel/v5.4"},
+		},
+		"Include By Path Glob": {
+			opts:          &Options{IncludeRepos: []string{"src/platform/*", "src/overlays"}},
+			expectedPaths: []string{"src/overlays", "src/platform/dev"},
+		},
+		"Exclude": {
+			opts:          &Options{ExcludeRepos: []string{"cos/overlays/*"}},
+			expectedPaths: []string{"src/platform/dev", "src/third_party/kernel/v5.4"},
+		},
+		"Exclude Takes Precedence": {
+			opts:          &Options{IncludeRepos: []string{"src/overlays", "src/platform/*"}, ExcludeRepos: []string{"src/overlays"}},
+			expectedPaths: []string{"src/platform/dev"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			repos := testRepos()
+			test.opts.filterRepos(repos)
+			var paths []string
+			for repoPath := range repos {
+				paths = append(paths, repoPath)
+			}
+			sort.Strings(paths)
+			if diff := cmp.Diff(test.expectedPaths, paths); diff != "" {
+				t.Errorf("filterRepos returned unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := map[string]struct {
+		opts         *Options
+		expectedCode string
+	}{
+		"Nil Options":     {opts: nil},
+		"Valid Patterns":  {opts: &Options{IncludeRepos: []string{"src/*"}, ExcludeRepos: []string{"third_party/?ernel"}}},
+		"Invalid Include": {opts: &Options{IncludeRepos: []string{"src/["}}, expectedCode: "400"},
+		"Invalid Exclude": {opts: &Options{ExcludeRepos: []string{"[-]"}}, expectedCode: "400"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.opts.validate()
+			switch {
+			case test.expectedCode == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case test.expectedCode != "" && err == nil:
+				t.Errorf("expected error code %s, got nil", test.expectedCode)
+			case test.expectedCode != "" && err.HTTPCode() != test.expectedCode:
+				t.Errorf("expected error code %s, got %s", test.expectedCode, err.HTTPCode())
+			}
+		})
+	}
+}
+
+func TestOptionsManifestLayout(t *testing.T) {
+	tests := map[string]struct {
+		opts             *Options
+		build            string
+		expectedRef      string
+		expectedFileName string
+	}{
+		"Nil Options": {
+			opts:             nil,
+			build:            "15000.0.0",
+			expectedRef:      "refs/tags/15000.0.0",
+			expectedFileName: "snapshot.xml",
+		},
+		"Custom Layout": {
+			opts:             &Options{TagPrefix: "refs/tags/lakitu-release/", ManifestFileName: "manifest/default.xml"},
+			build:            "15000.0.0",
+			expectedRef:      "refs/tags/lakitu-release/15000.0.0",
+			expectedFileName: "manifest/default.xml",
+		},
+		"Manifest Commit": {
+			opts:             &Options{TagPrefix: "refs/tags/lakitu-release/"},
+			build:            "0123456789abcdef0123456789abcdef01234567",
+			expectedRef:      "0123456789abcdef0123456789abcdef01234567",
+			expectedFileName: "snapshot.xml",
+		},
+		"Full Ref": {
+			opts:             &Options{TagPrefix: "refs/tags/lakitu-release/"},
+			build:            "refs/tags/dev-weekly/2021-07-01",
+			expectedRef:      "refs/tags/dev-weekly/2021-07-01",
+			expectedFileName: "snapshot.xml",
+		},
+		"Abbreviated Commit": {
+			opts:             nil,
+			build:            "0123456",
+			expectedRef:      "refs/tags/0123456",
+			expectedFileName: "snapshot.xml",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if ref := test.opts.manifestRef(test.build); ref != test.expectedRef {
+				t.Errorf("expected manifest ref %s, got %s", test.expectedRef, ref)
+			}
+			if fileName := test.opts.manifestFileName(); fileName != test.expectedFileName {
+				t.Errorf("expected manifest file name %s, got %s", test.expectedFileName, fileName)
+			}
+		})
+	}
+}
+
+func TestOptionsQuerySize(t *testing.T) {
+	tests := map[string]struct {
+		opts      *Options
+		querySize int
+		expected  int
+	}{
+		"Nil Options": {
+			opts:      nil,
+			querySize: -1,
+			expected:  -1,
+		},
+		"No Cap": {
+			opts:      &Options{},
+			querySize: 20000,
+			expected:  20000,
+		},
+		"Under Cap": {
+			opts:      &Options{MaxCommitsPerRepo: 100},
+			querySize: 10,
+			expected:  10,
+		},
+		"Over Cap": {
+			opts:      &Options{MaxCommitsPerRepo: 100},
+			querySize: 20000,
+			expected:  100,
+		},
+		"All Commits": {
+			opts:      &Options{MaxCommitsPerRepo: 100},
+			querySize: -1,
+			expected:  100,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.opts.querySize(test.querySize); got != test.expected {
+				t.Errorf("expected query size %d, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptionsPageSizePolicy(t *testing.T) {
+	tests := map[string]struct {
+		opts     *Options
+		expected utils.PageSizePolicy
+	}{
+		"Nil Options": {
+			opts:     nil,
+			expected: utils.DefaultPageSizePolicy,
+		},
+		"Custom Policy": {
+			opts:     &Options{PageSize: utils.PageSizePolicy{InitialPageSize: 50, MaxPageSize: 500}},
+			expected: utils.PageSizePolicy{InitialPageSize: 50, MaxPageSize: 500},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.opts.pageSizePolicy(); got != test.expected {
+				t.Errorf("expected page size policy %+v, got %+v", test.expected, got)
+			}
+		})
+	}
+}
+
+// unavailableGitiles fails every file download with a transient error
+type unavailableGitiles struct {
+	*fakes.Gitiles
+	downloads int
+}
+
+func (g *unavailableGitiles) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	g.downloads++
+	return nil, status.New(codes.Unavailable, "unavailable").Err()
+}
+
+func TestOptionsRetryContext(t *testing.T) {
+	ctxPolicy := utils.RetryPolicy{MaxAttempts: 2}
+	tests := map[string]struct {
+		opts              *Options
+		expectedDownloads int
+	}{
+		"Nil Options": {
+			opts:              nil,
+			expectedDownloads: 2,
+		},
+		"No Policy": {
+			opts:              &Options{},
+			expectedDownloads: 2,
+		},
+		"Options Policy": {
+			opts:              &Options{RetryPolicy: &utils.RetryPolicy{MaxAttempts: 3}},
+			expectedDownloads: 3,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &unavailableGitiles{Gitiles: fakes.NewGitiles()}
+			ctx := test.opts.retryContext(utils.WithRetryPolicy(context.Background(), ctxPolicy))
+			if _, err := utils.DownloadManifestFile(ctx, client, defaultManifestRepo, "refs/tags/1.0.0", "snapshot.xml"); err == nil {
+				t.Fatalf("expected the download to fail")
+			}
+			if client.downloads != test.expectedDownloads {
+				t.Errorf("expected %d downloads, got %d", test.expectedDownloads, client.downloads)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// defaultPageTokenKey authenticates the page tokens of changelogs whose
+// Options do not set PageTokenKey
+var defaultPageTokenKey = newPageTokenKey()
+
+func newPageTokenKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic("changelog: failed to generate page token key: " + err.Error())
+	}
+	return key
+}
+
+// pageToken identifies the position of a page in the changelog of a single
+// repository. It is handed to clients as URL safe base64 encoded JSON followed
+// by an HMAC of the JSON, which they must treat as opaque. The HMAC keeps
+// clients from forging tokens that send the credentials of ChangelogPage to
+// other hosts.
+type pageToken struct {
+	InstanceURL string `json:"i"`
+	Repo        string `json:"r"`
+	SourceSHA   string `json:"s,omitempty"`
+	TargetSHA   string `json:"t"`
+	// Gitiles token of the next page of the repository log
+	GitilesToken string `json:"p"`
+}
+
+func newPageToken(repoLog *RepoLog, gitilesToken string) *pageToken {
+	return &pageToken{
+		InstanceURL:  repoLog.InstanceURL,
+		Repo:         repoLog.Repo,
+		SourceSHA:    repoLog.SourceSHA,
+		TargetSHA:    repoLog.TargetSHA,
+		GitilesToken: gitilesToken,
+	}
+}
+
+func pageTokenMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (t *pageToken) encode(key []byte) string {
+	// Marshalling a struct of strings cannot fail
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(pageTokenMAC(key, data))
+}
+
+func decodePageToken(token string, key []byte) (*pageToken, error) {
+	separator := strings.Index(token, ".")
+	if separator < 0 {
+		return nil, errors.New("missing signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token[:separator])
+	if err != nil {
+		return nil, err
+	}
+	tokenMAC, err := base64.RawURLEncoding.DecodeString(token[separator+1:])
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(tokenMAC, pageTokenMAC(key, data)) {
+		return nil, errors.New("invalid signature")
+	}
+	t := &pageToken{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	if t.InstanceURL == "" || t.Repo == "" || t.TargetSHA == "" || t.GitilesToken == "" {
+		return nil, errors.New("missing required field")
+	}
+	return t, nil
+}
+
+// ChangelogPage retrieves the next commits of a repository whose changelog was
+// truncated, so large changelogs can be loaded lazily instead of in a single
+// response.
+//
+// token is the NextPageToken of a RepoLog returned by Changelog,
+// ChangelogToHead or a previous ChangelogPage call. At most pageSize commits
+// are retrieved, or every remaining commit if pageSize is -1. The returned
+// RepoLog has its NextPageToken set if more commits remain.
+//
+// The Git on Borg instance queried is read from token, which is only accepted
+// if it was issued with the same opts.PageTokenKey. The opts used to generate
+// the changelog should be passed, so the same clients and retry policy are used.
+func ChangelogPage(ctx context.Context, httpClient *http.Client, token string, pageSize int, opts *Options) (*RepoLog, utils.ChangelogError) {
+	t, err := decodePageToken(token, opts.pageTokenKey())
+	if err != nil {
+		log.Errorf("ChangelogPage: invalid page token %q:\n%v", token, err)
+		return nil, utils.InvalidPageToken
+	}
+	ctx = opts.retryContext(ctx)
+	client, utilErr := gitilesClient(httpClient, t.InstanceURL, opts)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	commits, nextGitilesToken, err := utils.CommitsPage(ctx, client, t.Repo, t.TargetSHA, t.SourceSHA, t.GitilesToken, pageSize)
+	if err != nil {
+		log.Errorf("ChangelogPage: error retrieving commits on repo %s from commit %s to commit %s:\n%v", t.Repo, t.SourceSHA, t.TargetSHA, err)
+		if ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		if utils.GitilesErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
+		}
+		return nil, utils.RepoLogUnavailable(t.Repo)
+	}
+	parsedCommits, err := ParseGitCommitLog(commits)
+	if err != nil {
+		log.Errorf("ChangelogPage: error parsing Gitiles commits response\n%v", err)
+		return nil, utils.RepoLogUnavailable(t.Repo)
+	}
+	repoLog := &RepoLog{
+		Commits:        parsedCommits,
+		InstanceURL:    t.InstanceURL,
+		Repo:           t.Repo,
+		SourceSHA:      t.SourceSHA,
+		TargetSHA:      t.TargetSHA,
+		HasMoreCommits: nextGitilesToken != "",
+	}
+	if nextGitilesToken != "" {
+		repoLog.NextPageToken = newPageToken(repoLog, nextGitilesToken).encode(opts.pageTokenKey())
+		repoLog.ContinuationCommittish = continuationCommittish(commits)
+	}
+	return repoLog, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	repoLog := &RepoLog{
+		InstanceURL: cosInstance,
+		Repo:        "third_party/kernel",
+		SourceSHA:   "1111111111111111111111111111111111111111",
+		TargetSHA:   "2222222222222222222222222222222222222222",
+	}
+	want := newPageToken(repoLog, "3333333333333333333333333333333333333333")
+	got, err := decodePageToken(want.encode(defaultPageTokenKey), defaultPageTokenKey)
+	if err != nil {
+		t.Fatalf("decodePageToken failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("decodePageToken returned unexpected token (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecodePageTokenInvalid(t *testing.T) {
+	signed := func(data string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(data)) + "." + base64.RawURLEncoding.EncodeToString(pageTokenMAC(defaultPageTokenKey, []byte(data)))
+	}
+	// A token for another host, signed with a key the caller chose
+	forged := newPageToken(&RepoLog{InstanceURL: "attacker.example.com", Repo: "repo", TargetSHA: "2222222222222222222222222222222222222222"}, "3")
+	tests := map[string]string{
+		"Empty":             "",
+		"Not Base64":        "not a token!",
+		"Unsigned":          base64.RawURLEncoding.EncodeToString([]byte(`{"i":"attacker.example.com","r":"repo","t":"2","p":"3"}`)),
+		"Not JSON":          signed("{"),
+		"Missing Fields":    signed(`{"i": "cos.googlesource.com"}`),
+		"Signature Not B64": base64.RawURLEncoding.EncodeToString([]byte("{}")) + ".!",
+		"Forged":            forged.encode([]byte("another key")),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodePageToken(token, defaultPageTokenKey); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+			// Invalid tokens are rejected before any request is sent
+			if _, err := ChangelogPage(context.Background(), http.DefaultClient, token, 10, nil); err != utils.InvalidPageToken {
+				t.Errorf("expected InvalidPageToken error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPageTokenKey(t *testing.T) {
+	token := newPageToken(&RepoLog{InstanceURL: cosInstance, Repo: "repo", TargetSHA: "2222222222222222222222222222222222222222"}, "3")
+	opts := &Options{PageTokenKey: []byte("shared key")}
+	if _, err := decodePageToken(token.encode(opts.pageTokenKey()), (&Options{PageTokenKey: []byte("shared key")}).pageTokenKey()); err != nil {
+		t.Errorf("expected a token signed with the same key to be accepted, got %v", err)
+	}
+	if _, err := decodePageToken(token.encode(opts.pageTokenKey()), (*Options)(nil).pageTokenKey()); err == nil {
+		t.Errorf("expected a token signed with another key to be rejected")
+	}
+}
+
+func TestAdditionsPageToken(t *testing.T) {
+	const sourceSHA, targetSHA = "1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"
+	sourceRepos := map[string]*repo{
+		"src/kernel": {Repo: "third_party/kernel", Path: "src/kernel", InstanceURL: cosInstance, Committish: sourceSHA},
+	}
+	targetRepos := map[string]*repo{
+		"src/kernel": {Repo: "third_party/kernel", Path: "src/kernel", InstanceURL: cosInstance, Committish: targetSHA},
+	}
+	cache := NewMemoryCache(0)
+	cacheSet(cache, commitsCacheKey(cosInstance, "third_party/kernel", targetSHA, sourceSHA, 1, false), &cachedCommits{
+		Commits:        []*Commit{{SHA: targetSHA}},
+		HasMoreCommits: true,
+		NextPageToken:  "3333333333333333333333333333333333333333",
+	})
+	opts := &Options{Cache: cache}
+	pool := newFetchPool(opts)
+	defer pool.close()
+	outputChan := make(chan additionsResult, 1)
+	additions(context.Background(), nil, sourceRepos, targetRepos, 1, opts, pool, nil, outputChan)
+	res := <-outputChan
+	if res.Err != nil {
+		t.Fatalf("additions failed: %v", res.Err)
+	}
+	token, err := decodePageToken(res.Additions["src/kernel"].NextPageToken, opts.pageTokenKey())
+	if err != nil {
+		t.Fatalf("decodePageToken failed: %v", err)
+	}
+	want := &pageToken{
+		InstanceURL:  cosInstance,
+		Repo:         "third_party/kernel",
+		SourceSHA:    sourceSHA,
+		TargetSHA:    targetSHA,
+		GitilesToken: "3333333333333333333333333333333333333333",
+	}
+	if diff := cmp.Diff(want, token); diff != "" {
+		t.Errorf("additions returned unexpected page token (-want +got):\n%s", diff)
+	}
+}
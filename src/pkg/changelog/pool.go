@@ -0,0 +1,139 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// defaultMaxConcurrentRequests is the number of repositories whose commits
+// are fetched at the same time unless Options.MaxConcurrentRequests is set
+const defaultMaxConcurrentRequests = 64
+
+// fetchPool runs commit requests on a fixed number of workers, and schedules
+// the Gitiles requests they send with a utils.Scheduler. A single pool is
+// shared by every additions call of a changelog so the limits apply to the
+// changelog as a whole.
+type fetchPool struct {
+	jobs      chan func()
+	scheduler *utils.Scheduler
+	wg        sync.WaitGroup
+}
+
+// newFetchPool starts the workers of a pool configured by opts. The pool must
+// be closed once every job has been submitted.
+func newFetchPool(opts *Options) *fetchPool {
+	if opts == nil {
+		return startFetchPool(0, nil)
+	}
+	scheduler := opts.Scheduler
+	if scheduler == nil {
+		scheduler = utils.NewScheduler(0, opts.RequestsPerSecond, opts.RequestBurst)
+	}
+	return startFetchPool(opts.MaxConcurrentRequests, scheduler)
+}
+
+// startFetchPool starts a pool of workers, defaulting to
+// defaultMaxConcurrentRequests if not positive. Requests are not limited
+// beyond the number of workers if scheduler is nil.
+func startFetchPool(workers int, scheduler *utils.Scheduler) *fetchPool {
+	if workers <= 0 {
+		workers = defaultMaxConcurrentRequests
+	}
+	pool := &fetchPool{
+		jobs:      make(chan func()),
+		scheduler: scheduler,
+	}
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pool.wg.Done()
+			for job := range pool.jobs {
+				job()
+			}
+		}()
+	}
+	return pool
+}
+
+// submit queues a job, blocking until a worker is free to run it.
+func (p *fetchPool) submit(job func()) {
+	p.jobs <- job
+}
+
+// wait blocks until the rate limit allows another request to be sent, or ctx
+// is done.
+func (p *fetchPool) wait(ctx context.Context) error {
+	return p.scheduler.Wait(ctx)
+}
+
+// scheduled returns a copy of ctx scheduling the Gitiles requests sent with it
+// by the pool's scheduler, including retries and the later pages of a log.
+func (p *fetchPool) scheduled(ctx context.Context) context.Context {
+	if p.scheduler == nil {
+		return ctx
+	}
+	return utils.WithScheduler(ctx, p.scheduler)
+}
+
+// close stops the workers once they finish their current jobs.
+func (p *fetchPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// acquirePool returns the pool running the requests of a single changelog,
+// and the function to call once every request has been submitted. A pool set
+// in opts is shared with other changelogs, so it is never closed.
+func acquirePool(opts *Options) (*fetchPool, func()) {
+	if opts != nil && opts.Pool != nil {
+		return opts.Pool.pool, func() {}
+	}
+	pool := newFetchPool(opts)
+	return pool, pool.close
+}
+
+// WorkerPool bounds the number of commit log requests in flight across every
+// changelog it is shared by through Options.Pool, ex. every changelog
+// generated by a server, so large manifests and concurrent changelogs cannot
+// exhaust sockets or trigger Git on Borg throttling.
+type WorkerPool struct {
+	pool *fetchPool
+}
+
+// NewWorkerPool starts a WorkerPool sending at most workers requests at the
+// same time, with a default of 64 if not positive. If requestsPerSecond is
+// positive, requests are also rate limited with bursts of up to burst
+// requests. The pool must be closed once no changelog uses it anymore.
+func NewWorkerPool(workers int, requestsPerSecond float64, burst int) *WorkerPool {
+	return NewScheduledWorkerPool(workers, utils.NewScheduler(0, requestsPerSecond, burst))
+}
+
+// NewScheduledWorkerPool starts a WorkerPool running at most workers
+// changelog requests at the same time, with a default of 64 if not positive,
+// whose Gitiles requests are limited by scheduler. Sharing scheduler with
+// other packages, ex. findbuild.BuildRequest.Scheduler, bounds their requests
+// as a whole. The pool must be closed once no changelog uses it anymore.
+func NewScheduledWorkerPool(workers int, scheduler *utils.Scheduler) *WorkerPool {
+	return &WorkerPool{pool: startFetchPool(workers, scheduler)}
+}
+
+// Close stops the workers once they finish their current requests.
+func (p *WorkerPool) Close() {
+	p.pool.close()
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchPoolConcurrency(t *testing.T) {
+	const workers, jobs = 3, 20
+	pool := newFetchPool(&Options{MaxConcurrentRequests: workers})
+	var mu sync.Mutex
+	active, maxActive, done := 0, 0, 0
+	for i := 0; i < jobs; i++ {
+		pool.submit(func() {
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			active--
+			done++
+			mu.Unlock()
+		})
+	}
+	pool.close()
+	if done != jobs {
+		t.Errorf("expected %d jobs to run, got %d", jobs, done)
+	}
+	if maxActive > workers {
+		t.Errorf("expected at most %d concurrent jobs, got %d", workers, maxActive)
+	}
+}
+
+func TestFetchPoolRateLimit(t *testing.T) {
+	tests := map[string]struct {
+		opts        *Options
+		expectedErr bool
+	}{
+		"No Limit": {
+			opts: nil,
+		},
+		"Within Burst": {
+			opts: &Options{RequestsPerSecond: 0.001, RequestBurst: 2},
+		},
+		"Exceeds Deadline": {
+			opts:        &Options{RequestsPerSecond: 0.001, RequestBurst: 1},
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pool := newFetchPool(test.opts)
+			defer pool.close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			var err error
+			for i := 0; i < 2 && err == nil; i++ {
+				err = pool.wait(ctx)
+			}
+			if (err != nil) != test.expectedErr {
+				t.Errorf("expected error: %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAcquirePool(t *testing.T) {
+	shared := NewWorkerPool(2, 0, 0)
+	defer shared.Close()
+	pool, release := acquirePool(&Options{Pool: shared})
+	if pool != shared.pool {
+		t.Errorf("expected the shared pool to be used")
+	}
+	release()
+	// The shared pool must still accept jobs once released
+	ran := make(chan struct{})
+	pool.submit(func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the shared pool to run jobs after being released")
+	}
+
+	pool, release = acquirePool(&Options{})
+	if pool == shared.pool {
+		t.Errorf("expected a dedicated pool without Options.Pool")
+	}
+	release()
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import "strings"
+
+// Release note values that authors use to mark a commit as not noteworthy
+var emptyReleaseNotes = map[string]bool{
+	"none": true,
+	"n/a":  true,
+	"na":   true,
+	"-":    true,
+}
+
+// HasReleaseNote reports whether the commit carries a noteworthy release
+// note, ignoring placeholders such as "None" or "N/A".
+func (c *Commit) HasReleaseNote() bool {
+	note := strings.ToLower(strings.TrimSpace(c.ReleaseNote))
+	return note != "" && !emptyReleaseNotes[note]
+}
+
+// ReleaseNotes returns a copy of a changelog that only contains commits with
+// a noteworthy release note. Repositories left without commits are removed.
+// The input changelog is not modified.
+func ReleaseNotes(changelog map[string]*RepoLog) map[string]*RepoLog {
+	output := make(map[string]*RepoLog)
+	for repoPath, repoLog := range changelog {
+		var commits []*Commit
+		for _, commit := range repoLog.Commits {
+			if commit.HasReleaseNote() {
+				commits = append(commits, commit)
+			}
+		}
+		if len(commits) == 0 {
+			continue
+		}
+		notes := *repoLog
+		notes.Commits = commits
+		output[repoPath] = &notes
+	}
+	return output
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHasReleaseNote(t *testing.T) {
+	tests := map[string]struct {
+		note     string
+		expected bool
+	}{
+		"Note":        {note: "Upgraded the Linux kernel to v5.4.129", expected: true},
+		"Empty":       {note: "", expected: false},
+		"Whitespace":  {note: "  ", expected: false},
+		"None":        {note: "None", expected: false},
+		"Lowercase":   {note: "none", expected: false},
+		"Not Applied": {note: "N/A", expected: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			commit := &Commit{ReleaseNote: test.note}
+			if got := commit.HasReleaseNote(); got != test.expected {
+				t.Errorf("expected HasReleaseNote %v for %q, got %v", test.expected, test.note, got)
+			}
+		})
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	noted := &Commit{SHA: "a", ReleaseNote: "Upgraded Docker to v20.10"}
+	placeholder := &Commit{SHA: "b", ReleaseNote: "None"}
+	silent := &Commit{SHA: "c"}
+	changelog := map[string]*RepoLog{
+		"src/third_party/docker": {
+			Commits:   []*Commit{silent, noted, placeholder},
+			Repo:      "third_party/docker",
+			SourceSHA: "1",
+			TargetSHA: "2",
+		},
+		"src/platform/dev": {
+			Commits: []*Commit{silent, placeholder},
+			Repo:    "cos/platform/dev",
+		},
+	}
+	want := map[string]*RepoLog{
+		"src/third_party/docker": {
+			Commits:   []*Commit{noted},
+			Repo:      "third_party/docker",
+			SourceSHA: "1",
+			TargetSHA: "2",
+		},
+	}
+	if diff := cmp.Diff(want, ReleaseNotes(changelog)); diff != "" {
+		t.Errorf("ReleaseNotes returned unexpected changelog (-want +got):\n%s", diff)
+	}
+	if len(changelog["src/third_party/docker"].Commits) != 3 {
+		t.Errorf("ReleaseNotes modified the input changelog")
+	}
+}
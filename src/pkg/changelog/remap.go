@@ -0,0 +1,80 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"strings"
+)
+
+// splitRepoRef splits a repository of Options.RepoMapping into its instance
+// and name. The first path element is an instance if it contains a dot, ex.
+// "cos.googlesource.com/cos/repo", since repository names do not.
+func splitRepoRef(ref string) (instance, name string) {
+	if i := strings.Index(ref, "/"); i > 0 && strings.Contains(ref[:i], ".") {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
+}
+
+// mappedRepo returns the target of the mapping of o.RepoMapping whose key
+// matches a repository, or an empty string if there is none.
+func (o *Options) mappedRepo(repoData *repo) string {
+	qualified := repoData.InstanceURL + "/" + repoData.Repo
+	for _, key := range []string{qualified, repoData.Repo} {
+		if to, ok := o.RepoMapping[key]; ok {
+			return to
+		}
+	}
+	// The longest matching prefix wins, and prefixes with an instance win
+	// over prefixes without one of the same length
+	bestLen, bestQualified, bestTo := -1, false, ""
+	for from, to := range o.RepoMapping {
+		if !strings.HasSuffix(from, "/") {
+			continue
+		}
+		instance, prefix := splitRepoRef(from)
+		if instance != "" && instance != repoData.InstanceURL || !strings.HasPrefix(repoData.Repo, prefix) {
+			continue
+		}
+		qualified := instance != ""
+		if len(prefix) > bestLen || len(prefix) == bestLen && qualified && !bestQualified {
+			bestLen, bestQualified, bestTo = len(prefix), qualified, to+strings.TrimPrefix(repoData.Repo, prefix)
+		}
+	}
+	return bestTo
+}
+
+// remapRepos replaces the repositories of a repository mapping according to
+// o.RepoMapping. Mapped repositories are copied, so the input entries are not
+// modified.
+func (o *Options) remapRepos(repos map[string]*repo) {
+	if o == nil || len(o.RepoMapping) == 0 {
+		return
+	}
+	for repoPath, repoData := range repos {
+		to := o.mappedRepo(repoData)
+		if to == "" {
+			continue
+		}
+		mapped := *repoData
+		instance, name := splitRepoRef(to)
+		if instance != "" {
+			mapped.InstanceURL = instance
+		}
+		mapped.Repo = name
+		log.Debugf("remapRepos: querying repo %s/%s at %s as %s/%s", repoData.InstanceURL, repoData.Repo, repoPath, mapped.InstanceURL, mapped.Repo)
+		repos[repoPath] = &mapped
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+func TestRemapRepos(t *testing.T) {
+	const chromium = "chromium.googlesource.com"
+	repos := map[string]*repo{
+		"src/third_party/kernel": {Repo: "chromiumos/third_party/kernel", Path: "src/third_party/kernel", InstanceURL: chromium},
+		"src/platform/dev":       {Repo: "chromiumos/platform/dev-util", Path: "src/platform/dev", InstanceURL: chromium},
+		"src/platform2":          {Repo: "chromiumos/platform2", Path: "src/platform2", InstanceURL: cosInstance},
+		"src/overlays":           {Repo: "cos/overlays/board-overlays", Path: "src/overlays", InstanceURL: cosInstance},
+	}
+	tests := map[string]struct {
+		mapping  map[string]string
+		expected map[string]string
+	}{
+		"Name": {
+			mapping: map[string]string{"chromiumos/third_party/kernel": "mirrors/kernel"},
+			expected: map[string]string{
+				"src/third_party/kernel": chromium + "/mirrors/kernel",
+			},
+		},
+		"Instance": {
+			mapping: map[string]string{chromium + "/chromiumos/third_party/kernel": cosInstance + "/third_party/kernel"},
+			expected: map[string]string{
+				"src/third_party/kernel": cosInstance + "/third_party/kernel",
+			},
+		},
+		"Prefix": {
+			mapping: map[string]string{"chromiumos/": "mirrors/chromiumos/"},
+			expected: map[string]string{
+				"src/third_party/kernel": chromium + "/mirrors/chromiumos/third_party/kernel",
+				"src/platform/dev":       chromium + "/mirrors/chromiumos/platform/dev-util",
+				"src/platform2":          cosInstance + "/mirrors/chromiumos/platform2",
+			},
+		},
+		"Instance Prefix": {
+			mapping: map[string]string{chromium + "/": cosInstance + "/mirrors/"},
+			expected: map[string]string{
+				"src/third_party/kernel": cosInstance + "/mirrors/chromiumos/third_party/kernel",
+				"src/platform/dev":       cosInstance + "/mirrors/chromiumos/platform/dev-util",
+			},
+		},
+		"Precedence": {
+			mapping: map[string]string{
+				"chromiumos/":                   "mirrors/",
+				"chromiumos/platform/":          "platform-mirrors/",
+				chromium + "/chromiumos/":       "chromium-mirrors/",
+				"chromiumos/third_party/kernel": "third_party/kernel",
+			},
+			expected: map[string]string{
+				"src/third_party/kernel": chromium + "/third_party/kernel",
+				"src/platform/dev":       chromium + "/platform-mirrors/dev-util",
+				"src/platform2":          cosInstance + "/mirrors/platform2",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := make(map[string]*repo)
+			for repoPath, repoData := range repos {
+				got[repoPath] = repoData
+			}
+			(&Options{RepoMapping: test.mapping}).remapRepos(got)
+			changed := make(map[string]string)
+			for repoPath, repoData := range got {
+				if repoData != repos[repoPath] {
+					changed[repoPath] = repoData.InstanceURL + "/" + repoData.Repo
+				}
+			}
+			if diff := cmp.Diff(test.expected, changed); diff != "" {
+				t.Errorf("remapRepos returned unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if repos["src/third_party/kernel"].Repo != "chromiumos/third_party/kernel" {
+		t.Errorf("expected remapRepos to leave its input entries unchanged")
+	}
+}
+
+func TestValidateRepoMapping(t *testing.T) {
+	tests := map[string]struct {
+		mapping     map[string]string
+		expectedErr bool
+	}{
+		"Valid":            {mapping: map[string]string{"chromiumos/": "mirrors/", "a": "b"}},
+		"Empty Target":     {mapping: map[string]string{"a": ""}, expectedErr: true},
+		"Prefix To Repo":   {mapping: map[string]string{"chromiumos/": "mirrors"}, expectedErr: true},
+		"Repo To Prefix":   {mapping: map[string]string{"chromiumos": "mirrors/"}, expectedErr: true},
+		"Empty Repository": {mapping: map[string]string{"": "b"}, expectedErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := (&Options{RepoMapping: test.mapping}).validate()
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected error: %v, got %v", test.expectedErr, err)
+			}
+			if err != nil && err.HTTPCode() != "400" {
+				t.Errorf("expected error code 400, got %s", err.HTTPCode())
+			}
+		})
+	}
+}
+
+func TestChangelogRepoMapping(t *testing.T) {
+	g := fakes.NewGitiles()
+	// Only the mirror of the kernel repository can be queried
+	g.Commits["mirrors/kernel"] = []*git.Commit{
+		{Id: "k1", Message: "Initial kernel"},
+		{Id: "k2", Parents: []string{"k1"}, Message: "Fix GPU reset"},
+	}
+	manifest := func(sha string) string {
+		return `<manifest>
+  <remote fetch="https://chromium.googlesource.com" name="cros"/>
+  <default remote="cros" revision="refs/heads/master"/>
+  <project name="chromiumos/third_party/kernel" path="src/third_party/kernel" revision="` + sha + `"/>
+</manifest>`
+	}
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/1.0.0", Path: "snapshot.xml"}] = manifest("k1")
+	g.Files[fakes.GitilesFile{Project: defaultManifestRepo, Committish: "refs/tags/2.0.0", Path: "snapshot.xml"}] = manifest("k2")
+	opts := &Options{
+		GitilesClient: func(string) (utils.GitilesService, error) { return g, nil },
+		RepoMapping:   map[string]string{"chromium.googlesource.com/chromiumos/third_party/kernel": cosInstance + "/mirrors/kernel"},
+	}
+	additions, _, err := Changelog(context.Background(), nil, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", 10, opts)
+	if err != nil {
+		t.Fatalf("Changelog failed: %v", err)
+	}
+	kernel := additions["src/third_party/kernel"]
+	if kernel == nil || !commitsMatch(kernel.Commits, []string{"k2"}) {
+		t.Fatalf("expected kernel addition k2, got %v", kernel)
+	}
+	if kernel.InstanceURL != cosInstance || kernel.Repo != "mirrors/kernel" {
+		t.Errorf("expected the mirror to be queried, got %s/%s", kernel.InstanceURL, kernel.Repo)
+	}
+}
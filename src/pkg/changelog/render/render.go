@@ -0,0 +1,226 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render converts changelogs generated by the changelog package into
+// Markdown or HTML documents. Commits are grouped under a heading for each
+// repository, sorted by repository path, and link to their Gitiles pages and
+// bug trackers. Commits referencing CVEs are also listed in a security fixes
+// section at the top of the document.
+package render
+
+import (
+	"fmt"
+	htmlTemplate "html/template"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	textTemplate "text/template"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+)
+
+// shortSHALen is the number of characters of a commit SHA that are displayed
+const shortSHALen = 8
+
+// cveURLFormat is the format of the URL describing a CVE
+const cveURLFormat = "https://nvd.nist.gov/vuln/detail/%s"
+
+// repoSection holds the data rendered for a single repository
+type repoSection struct {
+	Path    string
+	Repo    string
+	Commits []*commitEntry
+	// Link to the full log if the changelog was truncated, empty otherwise
+	MoreURL string
+	// Reason the commits could not be retrieved, empty otherwise
+	Error string
+}
+
+// commitEntry holds the data rendered for a single commit
+type commitEntry struct {
+	ShortSHA    string
+	URL         string
+	Subject     string
+	AuthorName  string
+	CommitTime  string
+	Bugs        []*changelog.Bug
+	ReleaseNote string
+}
+
+// securityFix is a commit fixing a CVE. A commit fixing several CVEs has a
+// securityFix for each of them.
+type securityFix struct {
+	CVE    string
+	URL    string
+	Path   string
+	Commit *commitEntry
+}
+
+type document struct {
+	Title string
+	// Sorted by CVE, then by repository path
+	SecurityFixes []*securityFix
+	Repos         []*repoSection
+}
+
+// Escapes characters that change the meaning of inline Markdown text. Text is
+// never rendered at the start of a line, so block level syntax is kept as is.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+var markdownTmpl = textTemplate.Must(textTemplate.New("markdown").Funcs(textTemplate.FuncMap{
+	"md": markdownEscaper.Replace,
+}).Parse(`{{if .Title}}# {{md .Title}}
+
+{{end}}{{if .SecurityFixes}}## Security Fixes
+
+{{range .SecurityFixes}}- [{{.CVE}}]({{.URL}}): [` + "`{{.Commit.ShortSHA}}`" + `]({{.Commit.URL}}) {{md .Commit.Subject}} ({{md .Path}})
+{{end}}
+{{end}}{{if not .Repos}}No changes.
+{{end}}{{range .Repos}}## {{md .Path}}{{if ne .Path .Repo}} ({{md .Repo}}){{end}}
+
+{{range .Commits}}- [` + "`{{.ShortSHA}}`" + `]({{.URL}}) {{md .Subject}} - {{md .AuthorName}}, {{.CommitTime}}{{range .Bugs}} [{{.Tracker}}/{{.ID}}]({{.URL}}){{end}}
+{{if .ReleaseNote}}  - Release note: {{md .ReleaseNote}}
+{{end}}{{end}}{{if .MoreURL}}- [More commits]({{.MoreURL}})
+{{end}}{{if .Error}}- Failed to retrieve commits: {{md .Error}}
+{{end}}
+{{end}}`))
+
+var htmlTmpl = htmlTemplate.Must(htmlTemplate.New("html").Parse(`{{if .Title}}<h1>{{.Title}}</h1>
+{{end}}{{if .SecurityFixes}}<h2>Security Fixes</h2>
+<ul>
+{{range .SecurityFixes}}  <li><a href="{{.URL}}">{{.CVE}}</a>: <a href="{{.Commit.URL}}"><code>{{.Commit.ShortSHA}}</code></a> {{.Commit.Subject}} ({{.Path}})</li>
+{{end}}</ul>
+{{end}}{{if not .Repos}}<p>No changes.</p>
+{{end}}{{range .Repos}}<h2>{{.Path}}{{if ne .Path .Repo}} ({{.Repo}}){{end}}</h2>
+<ul>
+{{range .Commits}}  <li><a href="{{.URL}}"><code>{{.ShortSHA}}</code></a> {{.Subject}} - {{.AuthorName}}, {{.CommitTime}}{{range .Bugs}} <a href="{{.URL}}">{{.Tracker}}/{{.ID}}</a>{{end}}{{if .ReleaseNote}}
+    <ul><li>Release note: {{.ReleaseNote}}</li></ul>{{end}}</li>
+{{end}}{{if .MoreURL}}  <li><a href="{{.MoreURL}}">More commits</a></li>
+{{end}}{{if .Error}}  <li>Failed to retrieve commits: {{.Error}}</li>
+{{end}}</ul>
+{{end}}`))
+
+// githubRepoURL returns the web URL of a repository hosted on GitHub, or an
+// empty string if the instance is not GitHub.
+func githubRepoURL(instanceURL, repo string) string {
+	if instanceURL != "github.com" && !strings.HasPrefix(instanceURL, "github.com/") {
+		return ""
+	}
+	return "https://" + path.Join(instanceURL, strings.TrimSuffix(repo, ".git"))
+}
+
+// commitURL links to the CL a commit was reviewed in if it is known, and to
+// the commit itself otherwise.
+func commitURL(instanceURL, repo string, commit *changelog.Commit) string {
+	if commit.ReviewURL != "" {
+		return commit.ReviewURL
+	}
+	sha := commit.SHA
+	if repoURL := githubRepoURL(instanceURL, repo); repoURL != "" {
+		return fmt.Sprintf("%s/commit/%s", repoURL, sha)
+	}
+	return fmt.Sprintf("https://%s/%s/+/%s", instanceURL, repo, sha)
+}
+
+func logURL(instanceURL, repo, sourceSHA, targetSHA string) string {
+	if repoURL := githubRepoURL(instanceURL, repo); repoURL != "" {
+		if sourceSHA == "" {
+			return fmt.Sprintf("%s/commits/%s", repoURL, targetSHA)
+		}
+		return fmt.Sprintf("%s/compare/%s...%s", repoURL, sourceSHA, targetSHA)
+	}
+	if sourceSHA == "" {
+		return fmt.Sprintf("https://%s/%s/+log/%s", instanceURL, repo, targetSHA)
+	}
+	return fmt.Sprintf("https://%s/%s/+log/%s..%s", instanceURL, repo, sourceSHA, targetSHA)
+}
+
+// newDocument converts a changelog into the data rendered by the templates.
+func newDocument(title string, changes map[string]*changelog.RepoLog) *document {
+	doc := &document{Title: title}
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		repoLog := changes[path]
+		section := &repoSection{Path: path, Repo: repoLog.Repo, Error: repoLog.Error}
+		for _, commit := range repoLog.Commits {
+			shortSHA := commit.SHA
+			if len(shortSHA) > shortSHALen {
+				shortSHA = shortSHA[:shortSHALen]
+			}
+			entry := &commitEntry{
+				ShortSHA:    shortSHA,
+				URL:         commitURL(repoLog.InstanceURL, repoLog.Repo, commit),
+				Subject:     commit.Subject,
+				AuthorName:  commit.AuthorName,
+				CommitTime:  commit.CommitTime,
+				Bugs:        commit.BugLinks,
+				ReleaseNote: releaseNote(commit),
+			}
+			section.Commits = append(section.Commits, entry)
+			for _, cve := range commit.CVEs {
+				doc.SecurityFixes = append(doc.SecurityFixes, &securityFix{
+					CVE:    cve,
+					URL:    fmt.Sprintf(cveURLFormat, cve),
+					Path:   path,
+					Commit: entry,
+				})
+			}
+		}
+		if repoLog.HasMoreCommits {
+			section.MoreURL = logURL(repoLog.InstanceURL, repoLog.Repo, repoLog.SourceSHA, repoLog.TargetSHA)
+		}
+		doc.Repos = append(doc.Repos, section)
+	}
+	// Fixes were added by repository path, which is kept for each CVE
+	sort.SliceStable(doc.SecurityFixes, func(i, j int) bool {
+		return doc.SecurityFixes[i].CVE < doc.SecurityFixes[j].CVE
+	})
+	return doc
+}
+
+// releaseNote returns the release note of a commit, or an empty string if it
+// does not have a noteworthy one.
+func releaseNote(commit *changelog.Commit) string {
+	if !commit.HasReleaseNote() {
+		return ""
+	}
+	return commit.ReleaseNote
+}
+
+// Markdown writes a changelog as a Markdown document to w. The document starts
+// with title as a top level heading, unless title is empty.
+func Markdown(w io.Writer, title string, changes map[string]*changelog.RepoLog) error {
+	if err := markdownTmpl.Execute(w, newDocument(title, changes)); err != nil {
+		return fmt.Errorf("failed to render changelog as Markdown: %v", err)
+	}
+	return nil
+}
+
+// HTML writes a changelog as an HTML fragment to w, so it can be embedded in
+// an existing page. The fragment starts with title as a top level heading,
+// unless title is empty. All commit data is escaped.
+func HTML(w io.Writer, title string, changes map[string]*changelog.RepoLog) error {
+	if err := htmlTmpl.Execute(w, newDocument(title, changes)); err != nil {
+		return fmt.Errorf("failed to render changelog as HTML: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testChangelog() map[string]*changelog.RepoLog {
+	return map[string]*changelog.RepoLog{
+		"src/third_party/kernel/v5.4": {
+			InstanceURL:    "cos.googlesource.com",
+			Repo:           "third_party/kernel",
+			SourceSHA:      "1111111111111111111111111111111111111111",
+			TargetSHA:      "2222222222222222222222222222222222222222",
+			HasMoreCommits: true,
+			Commits: []*changelog.Commit{{
+				SHA:         "3333333333333333333333333333333333333333",
+				Subject:     "net: fix <skb> *leak*",
+				AuthorName:  "Jane Doe",
+				CommitTime:  "Mon, 2 Jan 2006",
+				ReleaseNote: "Fixed a memory leak",
+				BugLinks: []*changelog.Bug{
+					{Tracker: "b", ID: "123", URL: "https://issuetracker.google.com/issues/123"},
+				},
+			}},
+		},
+		"src/platform/dev": {
+			InstanceURL: "cos.googlesource.com",
+			Repo:        "src/platform/dev",
+			Commits: []*changelog.Commit{{
+				SHA:         "4444444444444444444444444444444444444444",
+				Subject:     "Update README",
+				AuthorName:  "John Doe",
+				CommitTime:  "Tue, 3 Jan 2006",
+				ReleaseNote: "None",
+			}},
+		},
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := map[string]struct {
+		title    string
+		changes  map[string]*changelog.RepoLog
+		expected string
+	}{
+		"Changelog": {
+			title:   "15000.0.0 -> 15001.0.0",
+			changes: testChangelog(),
+			expected: "# 15000.0.0 -> 15001.0.0\n\n" +
+				"## src/platform/dev\n\n" +
+				"- [`44444444`](https://cos.googlesource.com/src/platform/dev/+/4444444444444444444444444444444444444444) Update README - John Doe, Tue, 3 Jan 2006\n\n" +
+				"## src/third\\_party/kernel/v5.4 (third\\_party/kernel)\n\n" +
+				"- [`33333333`](https://cos.googlesource.com/third_party/kernel/+/3333333333333333333333333333333333333333) net: fix \\<skb> \\*leak\\* - Jane Doe, Mon, 2 Jan 2006 [b/123](https://issuetracker.google.com/issues/123)\n" +
+				"  - Release note: Fixed a memory leak\n" +
+				"- [More commits](https://cos.googlesource.com/third_party/kernel/+log/1111111111111111111111111111111111111111..2222222222222222222222222222222222222222)\n\n",
+		},
+		"Empty Changelog": {
+			changes:  map[string]*changelog.RepoLog{},
+			expected: "No changes.\n",
+		},
+		"Security Fixes": {
+			changes: map[string]*changelog.RepoLog{
+				"src/third_party/sudo": {
+					InstanceURL: "cos.googlesource.com",
+					Repo:        "third_party/sudo",
+					Commits: []*changelog.Commit{{
+						SHA:        "5555555555555555555555555555555555555555",
+						Subject:    "Fix heap overflows",
+						AuthorName: "Jane Doe",
+						CommitTime: "Mon, 2 Jan 2006",
+						CVEs:       []string{"CVE-2021-3156", "CVE-2019-14287"},
+					}},
+				},
+			},
+			expected: "## Security Fixes\n\n" +
+				"- [CVE-2019-14287](https://nvd.nist.gov/vuln/detail/CVE-2019-14287): [`55555555`](https://cos.googlesource.com/third_party/sudo/+/5555555555555555555555555555555555555555) Fix heap overflows (src/third\\_party/sudo)\n" +
+				"- [CVE-2021-3156](https://nvd.nist.gov/vuln/detail/CVE-2021-3156): [`55555555`](https://cos.googlesource.com/third_party/sudo/+/5555555555555555555555555555555555555555) Fix heap overflows (src/third\\_party/sudo)\n\n" +
+				"## src/third\\_party/sudo (third\\_party/sudo)\n\n" +
+				"- [`55555555`](https://cos.googlesource.com/third_party/sudo/+/5555555555555555555555555555555555555555) Fix heap overflows - Jane Doe, Mon, 2 Jan 2006\n\n",
+		},
+		"Failed Repo": {
+			changes: map[string]*changelog.RepoLog{
+				"src/platform/dev": {
+					InstanceURL: "cos.googlesource.com",
+					Repo:        "src/platform/dev",
+					Error:       "Internal Server Error",
+				},
+			},
+			expected: "## src/platform/dev\n\n" +
+				"- Failed to retrieve commits: Internal Server Error\n\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Markdown(&buf, test.title, test.changes); err != nil {
+				t.Fatalf("Markdown failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, buf.String()); diff != "" {
+				t.Errorf("Markdown returned unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	expected := "<h1>15000.0.0 -&gt; 15001.0.0</h1>\n" +
+		"<h2>src/platform/dev</h2>\n<ul>\n" +
+		"  <li><a href=\"https://cos.googlesource.com/src/platform/dev/&#43;/4444444444444444444444444444444444444444\"><code>44444444</code></a> Update README - John Doe, Tue, 3 Jan 2006</li>\n" +
+		"</ul>\n" +
+		"<h2>src/third_party/kernel/v5.4 (third_party/kernel)</h2>\n<ul>\n" +
+		"  <li><a href=\"https://cos.googlesource.com/third_party/kernel/&#43;/3333333333333333333333333333333333333333\"><code>33333333</code></a> net: fix &lt;skb&gt; *leak* - Jane Doe, Mon, 2 Jan 2006 <a href=\"https://issuetracker.google.com/issues/123\">b/123</a>\n" +
+		"    <ul><li>Release note: Fixed a memory leak</li></ul></li>\n" +
+		"  <li><a href=\"https://cos.googlesource.com/third_party/kernel/&#43;log/1111111111111111111111111111111111111111..2222222222222222222222222222222222222222\">More commits</a></li>\n" +
+		"</ul>\n"
+	var buf bytes.Buffer
+	if err := HTML(&buf, "15000.0.0 -> 15001.0.0", testChangelog()); err != nil {
+		t.Fatalf("HTML failed: %v", err)
+	}
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("HTML returned unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestURLs(t *testing.T) {
+	const sourceSHA, targetSHA = "1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"
+	tests := map[string]struct {
+		instanceURL       string
+		repo              string
+		sourceSHA         string
+		reviewURL         string
+		expectedCommitURL string
+		expectedLogURL    string
+	}{
+		"Gitiles": {
+			instanceURL:       "cos.googlesource.com",
+			repo:              "third_party/kernel",
+			sourceSHA:         sourceSHA,
+			expectedCommitURL: "https://cos.googlesource.com/third_party/kernel/+/" + targetSHA,
+			expectedLogURL:    "https://cos.googlesource.com/third_party/kernel/+log/" + sourceSHA + ".." + targetSHA,
+		},
+		"Review URL": {
+			instanceURL:       "cos.googlesource.com",
+			repo:              "third_party/kernel",
+			sourceSHA:         sourceSHA,
+			reviewURL:         "https://cos-review.googlesource.com/c/third_party/kernel/+/1234",
+			expectedCommitURL: "https://cos-review.googlesource.com/c/third_party/kernel/+/1234",
+			expectedLogURL:    "https://cos.googlesource.com/third_party/kernel/+log/" + sourceSHA + ".." + targetSHA,
+		},
+		"GitHub": {
+			instanceURL:       "github.com/",
+			repo:              "google/go-cmp.git",
+			sourceSHA:         sourceSHA,
+			expectedCommitURL: "https://github.com/google/go-cmp/commit/" + targetSHA,
+			expectedLogURL:    "https://github.com/google/go-cmp/compare/" + sourceSHA + "..." + targetSHA,
+		},
+		"GitHub Owner Remote": {
+			instanceURL:       "github.com/google",
+			repo:              "go-cmp",
+			expectedCommitURL: "https://github.com/google/go-cmp/commit/" + targetSHA,
+			expectedLogURL:    "https://github.com/google/go-cmp/commits/" + targetSHA,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := commitURL(test.instanceURL, test.repo, &changelog.Commit{SHA: targetSHA, ReviewURL: test.reviewURL}); got != test.expectedCommitURL {
+				t.Errorf("expected commit URL %s, got %s", test.expectedCommitURL, got)
+			}
+			if got := logURL(test.instanceURL, test.repo, test.sourceSHA, targetSHA); got != test.expectedLogURL {
+				t.Errorf("expected log URL %s, got %s", test.expectedLogURL, got)
+			}
+		})
+	}
+}
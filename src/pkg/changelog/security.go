@@ -0,0 +1,42 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+// IsSecurityFix reports whether the commit message references a CVE.
+func (c *Commit) IsSecurityFix() bool {
+	return len(c.CVEs) > 0
+}
+
+// SecurityFixes returns a copy of a changelog that only contains commits
+// referencing a CVE. Repositories left without commits are removed. The input
+// changelog is not modified.
+func SecurityFixes(changelog map[string]*RepoLog) map[string]*RepoLog {
+	output := make(map[string]*RepoLog)
+	for repoPath, repoLog := range changelog {
+		var commits []*Commit
+		for _, commit := range repoLog.Commits {
+			if commit.IsSecurityFix() {
+				commits = append(commits, commit)
+			}
+		}
+		if len(commits) == 0 {
+			continue
+		}
+		fixes := *repoLog
+		fixes.Commits = commits
+		output[repoPath] = &fixes
+	}
+	return output
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSecurityFixes(t *testing.T) {
+	fix := &Commit{SHA: "a", CVEs: []string{"CVE-2021-3156"}}
+	other := &Commit{SHA: "b"}
+	changelog := map[string]*RepoLog{
+		"src/third_party/sudo": {
+			Commits:   []*Commit{other, fix},
+			Repo:      "third_party/sudo",
+			SourceSHA: "1",
+			TargetSHA: "2",
+		},
+		"src/platform/dev": {
+			Commits: []*Commit{other},
+			Repo:    "cos/platform/dev",
+		},
+	}
+	want := map[string]*RepoLog{
+		"src/third_party/sudo": {
+			Commits:   []*Commit{fix},
+			Repo:      "third_party/sudo",
+			SourceSHA: "1",
+			TargetSHA: "2",
+		},
+	}
+	if diff := cmp.Diff(want, SecurityFixes(changelog)); diff != "" {
+		t.Errorf("SecurityFixes returned unexpected changelog (-want +got):\n%s", diff)
+	}
+	if len(changelog["src/third_party/sudo"].Commits) != 2 {
+		t.Errorf("SecurityFixes modified the input changelog")
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server exposes changelog generation as a gRPC service, so release
+// automation can request changelogs without orchestrating Gitiles requests
+// itself. Responses are cached, and each caller is subject to its own quota.
+package server
+
+//go:generate protoc --go_out=:./pb --go-grpc_out=:./pb -I. proto/changelog.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog/server/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultQuerySize = 1000
+	defaultTimeout   = 5 * time.Minute
+)
+
+// Messages are written to the Logger set by utils.SetLogger
+var log = utils.Log
+
+// Config configures a Server. HTTPClient, Host and ManifestRepo are required.
+type Config struct {
+	// Authorized client with Gerrit scope used for every Gitiles request
+	HTTPClient *http.Client
+	// Default Git on Borg instance and manifest repository of requests that
+	// do not set them, ex. "cos.googlesource.com" and "cos/manifest-snapshots"
+	Host         string
+	ManifestRepo string
+	// AllowedHosts lists the instances other than Host that requests may
+	// query. HTTPClient sends its credentials to the instance of a request,
+	// so requests for any other instance are rejected.
+	AllowedHosts []string
+	// Default maximum number of commits per repository. Defaults to 1000.
+	QuerySize int
+	// Cache stores changelog responses, manifest files and commit logs. Nothing
+	// is cached if nil.
+	Cache changelog.Cache
+	// Meter records metrics of changelog generation. Nothing is recorded if
+	// nil.
+	Meter changelog.Meter
+	// Pool bounds the commit log requests of every changelog the server
+	// generates. Each changelog uses a pool of its own if nil.
+	Pool *changelog.WorkerPool
+	// CallerRequestsPerSecond limits the rate of requests of each caller, with
+	// bursts of up to CallerBurst requests. There is no quota if not positive.
+	CallerRequestsPerSecond float64
+	CallerBurst             int
+	// Caller identifies the caller of a request for quota purposes. Defaults
+	// to the IP address of the peer, so that the connections of a caller share
+	// its quota.
+	Caller func(ctx context.Context) string
+	// Timeout bounds the generation of a single changelog. Defaults to 5
+	// minutes.
+	Timeout time.Duration
+	// RetryPolicy describes how failed Gitiles requests are retried. Defaults
+	// to utils.DefaultRetryPolicy.
+	RetryPolicy *utils.RetryPolicy
+}
+
+// Server implements the ChangelogService gRPC service
+type Server struct {
+	pb.UnimplementedChangelogServiceServer
+	cfg Config
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[string]*callerLimiter
+	lastSweep time.Time
+}
+
+// callerLimiter is the quota of a caller
+type callerLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewServer creates a Server from cfg.
+func NewServer(cfg *Config) (*Server, error) {
+	if cfg == nil || cfg.HTTPClient == nil || cfg.Host == "" || cfg.ManifestRepo == "" {
+		return nil, errors.New("failed to create changelog server: HTTPClient, Host and ManifestRepo are required")
+	}
+	s := &Server{cfg: *cfg, now: time.Now, limiters: make(map[string]*callerLimiter)}
+	if s.cfg.QuerySize == 0 {
+		s.cfg.QuerySize = defaultQuerySize
+	}
+	if s.cfg.Caller == nil {
+		s.cfg.Caller = peerAddress
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+	if s.cfg.CallerBurst < 1 {
+		s.cfg.CallerBurst = 1
+	}
+	return s, nil
+}
+
+// peerAddress returns the IP address of the caller of a request, without the
+// port that changes with every connection.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// limiterIdleTimeout returns the time a caller's quota takes to refill
+// entirely. A limiter idle for that long is in the same state as a new one, so
+// it can be evicted without changing the quota of its caller.
+func (s *Server) limiterIdleTimeout() time.Duration {
+	return time.Duration(float64(s.cfg.CallerBurst) / s.cfg.CallerRequestsPerSecond * float64(time.Second))
+}
+
+// sweepLimiters evicts the limiters of idle callers, at most once per idle
+// timeout so that the cost of a sweep is spread over many requests. s.mu must
+// be held.
+func (s *Server) sweepLimiters(now time.Time) {
+	idle := s.limiterIdleTimeout()
+	if now.Sub(s.lastSweep) < idle {
+		return
+	}
+	s.lastSweep = now
+	for caller, l := range s.limiters {
+		if now.Sub(l.lastSeen) >= idle {
+			delete(s.limiters, caller)
+		}
+	}
+}
+
+// allow reports whether caller has quota left for another request.
+func (s *Server) allow(caller string) bool {
+	if s.cfg.CallerRequestsPerSecond <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweepLimiters(now)
+	l, ok := s.limiters[caller]
+	if !ok {
+		l = &callerLimiter{limiter: rate.NewLimiter(rate.Limit(s.cfg.CallerRequestsPerSecond), s.cfg.CallerBurst)}
+		s.limiters[caller] = l
+	}
+	l.lastSeen = now
+	return l.limiter.AllowN(now, 1)
+}
+
+// allowedHost reports whether requests may query host with the credentials of
+// the server.
+func (s *Server) allowedHost(host string) bool {
+	if host == s.cfg.Host {
+		return true
+	}
+	for _, allowed := range s.cfg.AllowedHosts {
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults returns a copy of req with the server defaults filled in.
+func (s *Server) withDefaults(req *pb.ChangelogRequest) *pb.ChangelogRequest {
+	out := &pb.ChangelogRequest{
+		Source:       req.GetSource(),
+		Target:       req.GetTarget(),
+		Host:         req.GetHost(),
+		ManifestRepo: req.GetManifestRepo(),
+		QuerySize:    req.GetQuerySize(),
+		IncludeRepos: req.GetIncludeRepos(),
+		ExcludeRepos: req.GetExcludeRepos(),
+	}
+	if out.Host == "" {
+		out.Host = s.cfg.Host
+	}
+	if out.ManifestRepo == "" {
+		out.ManifestRepo = s.cfg.ManifestRepo
+	}
+	if out.QuerySize == 0 {
+		out.QuerySize = int32(s.cfg.QuerySize)
+	}
+	return out
+}
+
+// responseCacheKey identifies the changelog of a request in the cache. Builds
+// are immutable once tagged, so responses never need to be invalidated.
+// Requests involving a branch are never cached.
+func responseCacheKey(req *pb.ChangelogRequest) string {
+	return fmt.Sprintf("response:%s/%s:%s..%s:%d:+%s:-%s", req.Host, req.ManifestRepo, req.Source, req.Target,
+		req.QuerySize, strings.Join(req.IncludeRepos, ","), strings.Join(req.ExcludeRepos, ","))
+}
+
+// GetChangelog implements pb.ChangelogServiceServer.
+func (s *Server) GetChangelog(ctx context.Context, req *pb.ChangelogRequest) (*pb.ChangelogResponse, error) {
+	if req.GetSource() == "" || req.GetTarget() == "" {
+		return nil, status.Error(codes.InvalidArgument, "source and target must be set")
+	}
+	caller := s.cfg.Caller(ctx)
+	if !s.allow(caller) {
+		log.Warnf("GetChangelog: caller %q exceeded its quota", caller)
+		return nil, status.Errorf(codes.ResourceExhausted, "quota exceeded for caller %q", caller)
+	}
+	req = s.withDefaults(req)
+	if !s.allowedHost(req.Host) {
+		log.Warnf("GetChangelog: caller %q requested host %q, which is not allowed", caller, req.Host)
+		return nil, status.Errorf(codes.PermissionDenied, "host %q is not allowed", req.Host)
+	}
+	cacheKey := responseCacheKey(req)
+	cacheable := s.cfg.Cache != nil && changelog.StableBuild(req.Source) && changelog.StableBuild(req.Target)
+	if cacheable {
+		if data, ok := s.cfg.Cache.Get(cacheKey); ok {
+			return &pb.ChangelogResponse{ChangelogJson: data}, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	opts := &changelog.Options{
+		IncludeRepos: req.IncludeRepos,
+		ExcludeRepos: req.ExcludeRepos,
+		Cache:        s.cfg.Cache,
+		Meter:        s.cfg.Meter,
+		Pool:         s.cfg.Pool,
+		RetryPolicy:  s.cfg.RetryPolicy,
+	}
+	additions, removals, utilErr := changelog.Changelog(ctx, s.cfg.HTTPClient, req.Source, req.Target, req.Host, req.ManifestRepo, "", int(req.QuerySize), opts)
+	if utilErr != nil {
+		return nil, status.Error(grpcCode(utilErr), utilErr.Error())
+	}
+	data, err := changelog.MarshalJSONChangelog(req.Source, req.Target, additions, removals)
+	if err != nil {
+		log.Errorf("GetChangelog: %v", err)
+		return nil, status.Error(codes.Internal, utils.InternalServerError.Error())
+	}
+	if cacheable {
+		s.cfg.Cache.Set(cacheKey, data)
+	}
+	return &pb.ChangelogResponse{ChangelogJson: data}, nil
+}
+
+// grpcCode converts the HTTP code of a ChangelogError into a gRPC status code.
+func grpcCode(err utils.ChangelogError) codes.Code {
+	switch err.HTTPCode() {
+	case "400":
+		return codes.InvalidArgument
+	case "401":
+		return codes.Unauthenticated
+	case "403":
+		return codes.PermissionDenied
+	case "404":
+		return codes.NotFound
+	case "429":
+		return codes.ResourceExhausted
+	case "504":
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
@@ -0,0 +1,268 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog/server/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testHost         = "cos.googlesource.com"
+	testManifestRepo = "cos/manifest-snapshots"
+)
+
+type callerKey struct{}
+
+func testCaller(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// newTestServer returns a server whose cache already holds the changelog
+// between 15000.0.0 and 15001.0.0, so requests for it are served offline.
+// Callers are identified by testCaller unless cfg sets Caller.
+func newTestServer(t *testing.T, cfg *Config) *Server {
+	cfg.HTTPClient = http.DefaultClient
+	cfg.Host = testHost
+	cfg.ManifestRepo = testManifestRepo
+	if cfg.Caller == nil {
+		cfg.Caller = testCaller
+	}
+	cfg.Cache = changelog.NewMemoryCache(0)
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	cached := s.withDefaults(&pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0"})
+	cfg.Cache.Set(responseCacheKey(cached), []byte("cached changelog"))
+	return s
+}
+
+func TestNewServer(t *testing.T) {
+	tests := map[string]struct {
+		cfg         *Config
+		expectedErr bool
+	}{
+		"Nil Config": {
+			cfg:         nil,
+			expectedErr: true,
+		},
+		"Missing Host": {
+			cfg:         &Config{HTTPClient: http.DefaultClient, ManifestRepo: testManifestRepo},
+			expectedErr: true,
+		},
+		"Valid Config": {
+			cfg: &Config{HTTPClient: http.DefaultClient, Host: testHost, ManifestRepo: testManifestRepo},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewServer(test.cfg); (err != nil) != test.expectedErr {
+				t.Errorf("expected error: %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestGetChangelog(t *testing.T) {
+	tests := map[string]struct {
+		req          *pb.ChangelogRequest
+		expectedCode codes.Code
+	}{
+		"Cached Response": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0"},
+			expectedCode: codes.OK,
+		},
+		"Explicit Defaults": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0", Host: testHost, ManifestRepo: testManifestRepo, QuerySize: defaultQuerySize},
+			expectedCode: codes.OK,
+		},
+		"Missing Target": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0"},
+			expectedCode: codes.InvalidArgument,
+		},
+		"Invalid Filter": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "15002.0.0", IncludeRepos: []string{"["}},
+			expectedCode: codes.InvalidArgument,
+		},
+		"Disallowed Host": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0", Host: "attacker.example.com"},
+			expectedCode: codes.PermissionDenied,
+		},
+		// Requests for an allowed host are not rejected, which is detected
+		// by the request failing validation instead
+		"Allowed Host": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "15002.0.0", Host: "chromium.googlesource.com", IncludeRepos: []string{"["}},
+			expectedCode: codes.InvalidArgument,
+		},
+		// The response cached for the branch must be ignored, which is
+		// detected by the request failing validation
+		"Uncached Branch": {
+			req:          &pb.ChangelogRequest{Source: "15000.0.0", Target: "refs/heads/main", IncludeRepos: []string{"["}},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+	cfg := &Config{AllowedHosts: []string{"chromium.googlesource.com"}}
+	s := newTestServer(t, cfg)
+	branch := s.withDefaults(&pb.ChangelogRequest{Source: "15000.0.0", Target: "refs/heads/main", IncludeRepos: []string{"["}})
+	cfg.Cache.Set(responseCacheKey(branch), []byte("cached changelog"))
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := s.GetChangelog(context.Background(), test.req)
+			if code := status.Code(err); code != test.expectedCode {
+				t.Fatalf("expected code %v, got %v", test.expectedCode, err)
+			}
+			if test.expectedCode == codes.OK && string(resp.GetChangelogJson()) != "cached changelog" {
+				t.Errorf("expected cached changelog, got %q", resp.GetChangelogJson())
+			}
+		})
+	}
+}
+
+func TestGetChangelogQuota(t *testing.T) {
+	s := newTestServer(t, &Config{CallerRequestsPerSecond: 0.001, CallerBurst: 2})
+	req := &pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0"}
+	tests := []struct {
+		caller       string
+		expectedCode codes.Code
+	}{
+		{caller: "release-bot", expectedCode: codes.OK},
+		{caller: "release-bot", expectedCode: codes.OK},
+		{caller: "release-bot", expectedCode: codes.ResourceExhausted},
+		{caller: "nightly-bot", expectedCode: codes.OK},
+	}
+	for i, test := range tests {
+		ctx := context.WithValue(context.Background(), callerKey{}, test.caller)
+		if _, err := s.GetChangelog(ctx, req); status.Code(err) != test.expectedCode {
+			t.Errorf("request %d from %s: expected code %v, got %v", i, test.caller, test.expectedCode, err)
+		}
+	}
+}
+
+func peerContext(ip string, port int) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port}})
+}
+
+func TestPeerAddress(t *testing.T) {
+	tests := map[string]struct {
+		ctx      context.Context
+		expected string
+	}{
+		"No Peer": {
+			ctx:      context.Background(),
+			expected: "",
+		},
+		"IPv4": {
+			ctx:      peerContext("192.0.2.1", 50000),
+			expected: "192.0.2.1",
+		},
+		"IPv6": {
+			ctx:      peerContext("2001:db8::1", 50000),
+			expected: "2001:db8::1",
+		},
+		"Unix Socket": {
+			ctx:      peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "/run/changelog.sock", Net: "unix"}}),
+			expected: "/run/changelog.sock",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := peerAddress(test.ctx); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestGetChangelogPeerQuota checks that new connections from a peer, which
+// come from new ports, share the quota of the peer.
+func TestGetChangelogPeerQuota(t *testing.T) {
+	s := newTestServer(t, &Config{CallerRequestsPerSecond: 0.001, CallerBurst: 2, Caller: peerAddress})
+	req := &pb.ChangelogRequest{Source: "15000.0.0", Target: "15001.0.0"}
+	tests := []struct {
+		ctx          context.Context
+		expectedCode codes.Code
+	}{
+		{ctx: peerContext("192.0.2.1", 50000), expectedCode: codes.OK},
+		{ctx: peerContext("192.0.2.1", 50001), expectedCode: codes.OK},
+		{ctx: peerContext("192.0.2.1", 50002), expectedCode: codes.ResourceExhausted},
+		{ctx: peerContext("192.0.2.2", 50000), expectedCode: codes.OK},
+	}
+	for i, test := range tests {
+		if _, err := s.GetChangelog(test.ctx, req); status.Code(err) != test.expectedCode {
+			t.Errorf("request %d: expected code %v, got %v", i, test.expectedCode, err)
+		}
+	}
+}
+
+func TestAllowEvictsIdleCallers(t *testing.T) {
+	s := newTestServer(t, &Config{CallerRequestsPerSecond: 1, CallerBurst: 2})
+	clock := fakes.NewTime(time.Unix(0, 0))
+	s.now = clock.Now
+	s.allow("release-bot")
+	s.allow("release-bot")
+	if s.allow("release-bot") {
+		t.Fatalf("expected release-bot to be out of quota")
+	}
+	clock.Sleep(time.Second)
+	s.allow("nightly-bot")
+	if got := len(s.limiters); got != 2 {
+		t.Fatalf("expected 2 limiters before the idle timeout, got %d", got)
+	}
+	// release-bot has refilled its burst, so its limiter is evicted on the
+	// next sweep while nightly-bot's, used since, is kept
+	clock.Sleep(time.Second)
+	s.allow("nightly-bot")
+	if _, ok := s.limiters["release-bot"]; ok {
+		t.Errorf("expected the limiter of idle caller release-bot to be evicted")
+	}
+	if _, ok := s.limiters["nightly-bot"]; !ok {
+		t.Errorf("expected the limiter of active caller nightly-bot to be kept")
+	}
+	if !s.allow("release-bot") || !s.allow("release-bot") {
+		t.Errorf("expected an evicted caller to get its full burst back")
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := map[string]struct {
+		err      utils.ChangelogError
+		expected codes.Code
+	}{
+		"Forbidden":       {err: utils.ForbiddenError, expected: codes.PermissionDenied},
+		"Timeout":         {err: utils.TimeoutError, expected: codes.DeadlineExceeded},
+		"Build Not Found": {err: utils.BuildNotFound("15000.0.0"), expected: codes.NotFound},
+		"Internal":        {err: utils.InternalServerError, expected: codes.Internal},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if code := grpcCode(test.err); code != test.expected {
+				t.Errorf("expected code %v, got %v", test.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Stats summarizes the commits of a changelog for contribution and churn
+// reports.
+type Stats struct {
+	TotalCommits int
+	// Number of commits keyed by author name
+	Authors map[string]int
+	// Number of commits keyed by the lowercase domain of the author email,
+	// ex. "google.com". Commits without an author email are not counted.
+	Domains map[string]int
+	// Number of commits keyed by repository path
+	Repos map[string]int
+}
+
+// Count is the number of commits attributed to a key of a Stats map
+type Count struct {
+	Key     string
+	Commits int
+}
+
+// ChangelogStats aggregates a changelog returned by Changelog into per author,
+// per email domain and per repository commit counts.
+func ChangelogStats(changes map[string]*RepoLog) *Stats {
+	stats := &Stats{
+		Authors: make(map[string]int),
+		Domains: make(map[string]int),
+		Repos:   make(map[string]int),
+	}
+	for repoPath, repoLog := range changes {
+		if len(repoLog.Commits) == 0 {
+			continue
+		}
+		stats.Repos[repoPath] += len(repoLog.Commits)
+		for _, commit := range repoLog.Commits {
+			stats.TotalCommits++
+			stats.Authors[commit.AuthorName]++
+			if domain := emailDomain(commit.AuthorEmail); domain != "" {
+				stats.Domains[domain]++
+			}
+		}
+	}
+	return stats
+}
+
+// emailDomain returns the lowercase domain of an email address, or an empty
+// string if the address has no domain.
+func emailDomain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i == -1 {
+		return ""
+	}
+	return strings.ToLower(email[i+1:])
+}
+
+// SortCounts converts one of the maps of Stats into a list sorted by
+// decreasing number of commits. Keys with the same number of commits are
+// sorted alphabetically.
+func SortCounts(counts map[string]int) []Count {
+	output := make([]Count, 0, len(counts))
+	for key, commits := range counts {
+		output = append(output, Count{Key: key, Commits: commits})
+	}
+	sort.Slice(output, func(i, j int) bool {
+		if output[i].Commits != output[j].Commits {
+			return output[i].Commits > output[j].Commits
+		}
+		return output[i].Key < output[j].Key
+	})
+	return output
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+func TestChangelogStats(t *testing.T) {
+	parsed, err := ParseGitCommitLog([]*git.Commit{createCommitWithMessage("Subject")})
+	if err != nil {
+		t.Fatalf("ParseGitCommitLog failed: %v", err)
+	}
+	tests := map[string]struct {
+		changes  map[string]*RepoLog
+		expected *Stats
+	}{
+		"Changelog": {
+			changes: map[string]*RepoLog{
+				"src/third_party/kernel": {Commits: []*Commit{
+					{AuthorName: "Jane Doe", AuthorEmail: "jane@Google.com"},
+					{AuthorName: "John Doe", AuthorEmail: "john@example.com"},
+					{AuthorName: "Jane Doe", AuthorEmail: "jane@google.com"},
+				}},
+				"src/platform/dev": {Commits: []*Commit{
+					{AuthorName: "None"},
+				}},
+				"src/overlays": {Error: "Internal Server Error"},
+				"src/scripts":  {Commits: parsed},
+			},
+			expected: &Stats{
+				TotalCommits: 5,
+				Authors:      map[string]int{"Jane Doe": 2, "John Doe": 1, "None": 1, authorName: 1},
+				Domains:      map[string]int{"google.com": 3, "example.com": 1},
+				Repos:        map[string]int{"src/third_party/kernel": 3, "src/platform/dev": 1, "src/scripts": 1},
+			},
+		},
+		"Empty Changelog": {
+			changes: map[string]*RepoLog{},
+			expected: &Stats{
+				Authors: map[string]int{},
+				Domains: map[string]int{},
+				Repos:   map[string]int{},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, ChangelogStats(test.changes)); diff != "" {
+				t.Errorf("ChangelogStats returned unexpected stats (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortCounts(t *testing.T) {
+	counts := map[string]int{"b": 2, "a": 2, "c": 5, "d": 1}
+	expected := []Count{{"c", 5}, {"a", 2}, {"b", 2}, {"d", 1}}
+	if diff := cmp.Diff(expected, SortCounts(counts)); diff != "" {
+		t.Errorf("SortCounts returned unexpected counts (-want +got):\n%s", diff)
+	}
+}
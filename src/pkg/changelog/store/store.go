@@ -0,0 +1,129 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package store persists generated changelogs in a Cloud Storage bucket, so
+// the changelog between two builds is only generated once.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+var log = utils.Log
+
+// ErrNotFound is returned by Get if no changelog is stored for a build pair.
+var ErrNotFound = errors.New("changelog not found")
+
+// Generator generates the changelog stored for a build pair, ex. a call to
+// changelog.ChangelogWithBuildInfo.
+type Generator func(ctx context.Context) (*changelog.Document, utils.ChangelogError)
+
+// Store reads and writes changelogs as JSON objects, in the format of
+// changelog.MarshalJSONChangelog, keyed by source and target build.
+//
+// Changelogs are only identified by their builds, so changelogs generated
+// from different manifest repositories or with different options, such as the
+// query size, must be kept under different prefixes.
+type Store struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// New returns a Store keeping changelogs in bucket under prefix. An empty
+// prefix stores changelogs at the root of the bucket.
+func New(client *storage.Client, bucket, prefix string) *Store {
+	return &Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+// objectName returns the name of the object storing the changelog from source
+// to target. Builds are escaped so a branch or ref name cannot add path
+// segments.
+func (s *Store) objectName(source, target string) string {
+	return path.Join(s.prefix, url.PathEscape(source), url.PathEscape(target)+".json")
+}
+
+// Get returns the changelog stored from source to target, or ErrNotFound if
+// it was never stored.
+func (s *Store) Get(ctx context.Context, source, target string) (*changelog.Document, error) {
+	name := s.objectName(source, target)
+	r, err := s.client.Bucket(s.bucket).Object(name).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gs://%s/%s: %v", s.bucket, name, err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gs://%s/%s: %v", s.bucket, name, err)
+	}
+	return changelog.UnmarshalJSONChangelog(data)
+}
+
+// Put stores a changelog under its source and target builds, replacing any
+// changelog already stored for them.
+func (s *Store) Put(ctx context.Context, doc *changelog.Document) error {
+	data, err := changelog.MarshalJSONDocument(doc)
+	if err != nil {
+		return err
+	}
+	name := s.objectName(doc.Source, doc.Target)
+	w := s.client.Bucket(s.bucket).Object(name).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write gs://%s/%s: %v", s.bucket, name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write gs://%s/%s: %v", s.bucket, name, err)
+	}
+	return nil
+}
+
+// Load returns the changelog stored from source to target, generating and
+// storing it first if it was never stored. Failing to read or write the
+// bucket is logged rather than returned, since the changelog can still be
+// generated; only errors of generate are returned.
+func (s *Store) Load(ctx context.Context, source, target string, generate Generator) (*changelog.Document, utils.ChangelogError) {
+	doc, err := s.Get(ctx, source, target)
+	if err == nil {
+		log.Debugf("Loaded changelog from %s to %s from gs://%s", source, target, s.bucket)
+		return doc, nil
+	}
+	if err != ErrNotFound {
+		log.Errorf("Load: regenerating changelog from %s to %s: %v", source, target, err)
+	}
+	doc, utilErr := generate(ctx)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	// The document is stored under the builds it was requested for
+	stored := *doc
+	stored.Source, stored.Target = source, target
+	if err := s.Put(ctx, &stored); err != nil {
+		log.Errorf("Load: failed to store changelog from %s to %s: %v", source, target, err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestObjectName(t *testing.T) {
+	tests := map[string]struct {
+		prefix   string
+		source   string
+		target   string
+		expected string
+	}{
+		"No Prefix": {
+			source:   "15000.0.0",
+			target:   "15001.0.0",
+			expected: "15000.0.0/15001.0.0.json",
+		},
+		"Prefix": {
+			prefix:   "cos.googlesource.com/n50",
+			source:   "15000.0.0",
+			target:   "15001.0.0",
+			expected: "cos.googlesource.com/n50/15000.0.0/15001.0.0.json",
+		},
+		"Ref": {
+			source:   "refs/heads/release-R89",
+			target:   "15001.0.0",
+			expected: "refs%2Fheads%2Frelease-R89/15001.0.0.json",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := New(nil, "bucket", test.prefix)
+			if got := s.objectName(test.source, test.target); got != test.expected {
+				t.Errorf("expected object name %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	ctx := context.Background()
+	s := New(gcs.Client, "bucket", "changelogs")
+	additions := map[string]*changelog.RepoLog{
+		"src/third_party/kernel": {Repo: "third_party/kernel", Commits: []*changelog.Commit{{SHA: "c1", Subject: "Fix GPU reset"}}},
+	}
+	expected := &changelog.Document{
+		Version:     changelog.JSONVersion,
+		Source:      "15000.0.0",
+		Target:      "15001.0.0",
+		SourceBuild: &changelog.BuildInfo{BuildNumber: "15000.0.0", Milestone: 93, Branch: "refs/heads/release-R93", ManifestCommitTime: time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)},
+		TargetBuild: &changelog.BuildInfo{BuildNumber: "15001.0.0", Milestone: 93, Branch: "refs/heads/release-R93", ManifestCommitTime: time.Date(2021, 7, 8, 12, 0, 0, 0, time.UTC)},
+		Additions:   additions,
+		Removals:    map[string]*changelog.RepoLog{},
+	}
+	generated := 0
+	generate := func(context.Context) (*changelog.Document, utils.ChangelogError) {
+		generated++
+		return expected, nil
+	}
+
+	if _, err := s.Get(ctx, "15000.0.0", "15001.0.0"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound before the changelog is stored, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		doc, err := s.Load(ctx, "15000.0.0", "15001.0.0", generate)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if diff := cmp.Diff(expected, doc); diff != "" {
+			t.Errorf("Load returned unexpected changelog (-want +got):\n%s", diff)
+		}
+	}
+	if generated != 1 {
+		t.Errorf("expected the changelog to be generated once, got %d", generated)
+	}
+	if _, ok := gcs.Objects["/bucket/changelogs/15000.0.0/15001.0.0.json"]; !ok {
+		t.Errorf("expected the changelog to be stored in the bucket")
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	s := New(gcs.Client, "bucket", "")
+	generate := func(context.Context) (*changelog.Document, utils.ChangelogError) {
+		return nil, utils.BuildNotFound("15001.0.0")
+	}
+	if _, err := s.Load(context.Background(), "15000.0.0", "15001.0.0", generate); err == nil {
+		t.Fatalf("expected Load to return the error of the generator")
+	}
+	if len(gcs.Objects) != 0 {
+		t.Errorf("expected failed changelogs not to be stored, got %v", gcs.Objects)
+	}
+}
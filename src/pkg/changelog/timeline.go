@@ -0,0 +1,110 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"sort"
+	"strings"
+)
+
+// TimelineEntry is a commit of a changelog together with the repository it
+// was retrieved from.
+type TimelineEntry struct {
+	// Path of the repository the commit belongs to, as keyed in the changelog
+	RepoPath    string
+	InstanceURL string
+	Repo        string
+	Commit      *Commit
+}
+
+// Timeline flattens a changelog returned by Changelog into a single list of
+// commits sorted from newest to oldest, regardless of their repository.
+// Commits with the same timestamp are ordered by repository path, and keep
+// the order of their RepoLog within a repository.
+func Timeline(changes map[string]*RepoLog) []*TimelineEntry {
+	repoPaths := make([]string, 0, len(changes))
+	for repoPath := range changes {
+		repoPaths = append(repoPaths, repoPath)
+	}
+	sort.Strings(repoPaths)
+	var output []*TimelineEntry
+	for _, repoPath := range repoPaths {
+		repoLog := changes[repoPath]
+		for _, commit := range repoLog.Commits {
+			output = append(output, &TimelineEntry{
+				RepoPath:    repoPath,
+				InstanceURL: repoLog.InstanceURL,
+				Repo:        repoLog.Repo,
+				Commit:      commit,
+			})
+		}
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].Commit.CommitTimestamp > output[j].Commit.CommitTimestamp
+	})
+	return output
+}
+
+// GroupBy selects the key used by GroupTimeline to group commits
+type GroupBy int
+
+const (
+	// GroupByAuthor groups commits by author name
+	GroupByAuthor GroupBy = iota
+	// GroupByTopic groups commits by the prefix of their subject, ex. "net"
+	// for "net: fix socket leak". Commits without a prefix have an empty topic.
+	GroupByTopic
+)
+
+// TimelineGroup is a list of timeline entries sharing the same key
+type TimelineGroup struct {
+	Key     string
+	Entries []*TimelineEntry
+}
+
+// GroupTimeline splits a timeline returned by Timeline into groups of commits
+// with the same author or topic. Groups are sorted by key, and the entries of
+// a group keep their order in the timeline.
+func GroupTimeline(timeline []*TimelineEntry, by GroupBy) []*TimelineGroup {
+	groups := make(map[string]*TimelineGroup)
+	var output []*TimelineGroup
+	for _, entry := range timeline {
+		key := entry.Commit.AuthorName
+		if by == GroupByTopic {
+			key = topic(entry.Commit.Subject)
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &TimelineGroup{Key: key}
+			groups[key] = group
+			output = append(output, group)
+		}
+		group.Entries = append(group.Entries, entry)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Key < output[j].Key
+	})
+	return output
+}
+
+// topic returns the prefix of a commit subject that precedes the first ": ",
+// or an empty string if the prefix contains whitespace.
+func topic(subject string) string {
+	i := strings.Index(subject, ": ")
+	if i <= 0 || strings.ContainsAny(subject[:i], " \t") {
+		return ""
+	}
+	return subject[:i]
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func timelineSHAs(entries []*TimelineEntry) []string {
+	var output []string
+	for _, entry := range entries {
+		output = append(output, entry.Commit.SHA)
+	}
+	return output
+}
+
+func TestCommitTimestamp(t *testing.T) {
+	commitTime := time.Date(2021, 7, 1, 12, 30, 0, 0, time.UTC)
+	parsed, err := ParseGitCommitLog([]*git.Commit{
+		{Id: "a", Committer: &git.Commit_User{Time: timestamppb.New(commitTime)}},
+		{Id: "b"},
+	})
+	if err != nil {
+		t.Fatalf("ParseGitCommitLog failed: %v", err)
+	}
+	if parsed[0].CommitTimestamp != commitTime.Unix() {
+		t.Errorf("expected timestamp %d, got %d", commitTime.Unix(), parsed[0].CommitTimestamp)
+	}
+	if parsed[1].CommitTimestamp != 0 {
+		t.Errorf("expected timestamp 0 for a commit without committer, got %d", parsed[1].CommitTimestamp)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	changes := map[string]*RepoLog{
+		"src/third_party/kernel": {
+			Repo: "third_party/kernel",
+			Commits: []*Commit{
+				{SHA: "k2", CommitTimestamp: 400},
+				{SHA: "k1", CommitTimestamp: 100},
+			},
+		},
+		"src/overlays": {
+			Repo: "cos/overlays/board-overlays",
+			Commits: []*Commit{
+				{SHA: "o2", CommitTimestamp: 300},
+				{SHA: "o1", CommitTimestamp: 100},
+			},
+		},
+		"src/platform/dev": {Repo: "cos/platform/dev"},
+	}
+	timeline := Timeline(changes)
+	if diff := cmp.Diff([]string{"k2", "o2", "o1", "k1"}, timelineSHAs(timeline)); diff != "" {
+		t.Errorf("Timeline returned unexpected order (-want +got):\n%s", diff)
+	}
+	if timeline[1].RepoPath != "src/overlays" || timeline[1].Repo != "cos/overlays/board-overlays" {
+		t.Errorf("expected o2 to belong to src/overlays, got %s (%s)", timeline[1].RepoPath, timeline[1].Repo)
+	}
+	if len(Timeline(nil)) != 0 {
+		t.Errorf("expected an empty timeline for an empty changelog")
+	}
+}
+
+func TestGroupTimeline(t *testing.T) {
+	timeline := Timeline(map[string]*RepoLog{
+		"src/third_party/kernel": {Commits: []*Commit{
+			{SHA: "k3", AuthorName: "John Doe", Subject: "net: fix socket leak", CommitTimestamp: 500},
+			{SHA: "k2", AuthorName: "Jane Doe", Subject: "Bump kernel version", CommitTimestamp: 300},
+			{SHA: "k1", AuthorName: "Jane Doe", Subject: "net: add tracing", CommitTimestamp: 100},
+		}},
+		"src/overlays": {Commits: []*Commit{
+			{SHA: "o1", AuthorName: "John Doe", Subject: "lakitu: update driver", CommitTimestamp: 400},
+		}},
+	})
+	tests := map[string]struct {
+		by       GroupBy
+		expected map[string][]string
+		keys     []string
+	}{
+		"Author": {
+			by:   GroupByAuthor,
+			keys: []string{"Jane Doe", "John Doe"},
+			expected: map[string][]string{
+				"Jane Doe": {"k2", "k1"},
+				"John Doe": {"k3", "o1"},
+			},
+		},
+		"Topic": {
+			by:   GroupByTopic,
+			keys: []string{"", "lakitu", "net"},
+			expected: map[string][]string{
+				"":       {"k2"},
+				"lakitu": {"o1"},
+				"net":    {"k3", "k1"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			groups := GroupTimeline(timeline, test.by)
+			var keys []string
+			for _, group := range groups {
+				keys = append(keys, group.Key)
+				if diff := cmp.Diff(test.expected[group.Key], timelineSHAs(group.Entries)); diff != "" {
+					t.Errorf("unexpected commits for group %q (-want +got):\n%s", group.Key, diff)
+				}
+			}
+			if diff := cmp.Diff(test.keys, keys); diff != "" {
+				t.Errorf("unexpected group keys (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	tests := map[string]string{
+		"net: fix socket leak":         "net",
+		"UPSTREAM: net: fix":           "UPSTREAM",
+		"Revert \"net: fix\"":          "",
+		"Bump version":                 "",
+		": empty prefix":               "",
+		"cos-kernel/build: add config": "cos-kernel/build",
+	}
+	for subject, expected := range tests {
+		if got := topic(subject); got != expected {
+			t.Errorf("topic(%q) = %q, expected %q", subject, got, expected)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// Gerrit is a fake implementation of the QueryChanges, ListTags and
+// ListBranches methods of the Gerrit API, serving fixtures set on its fields. It is intended to be
+// constructed with NewGerrit.
+//
+// Failures are reported with errors containing "status code <code>", which
+// utils.GerritErrCode parses.
+//
+// The struct is safe for concurrent requests as long as its fields are not
+// modified while requests are served.
+type Gerrit struct {
+	// Changes holds the changes searched by QueryChanges, in the order they
+	// are returned.
+	Changes []gerrit.ChangeInfo
+	// Tags maps each project to its tags.
+	Tags map[string][]gerrit.TagInfo
+	// Branches maps each project to its branches.
+	Branches map[string][]gerrit.BranchInfo
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// NewGerrit constructs a fake Gerrit service without any fixtures.
+func NewGerrit() *Gerrit {
+	return &Gerrit{
+		Tags:     make(map[string][]gerrit.TagInfo),
+		Branches: make(map[string][]gerrit.BranchInfo),
+	}
+}
+
+// Queries returns the search queries received by QueryChanges so far, in
+// order.
+func (g *Gerrit) Queries() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.queries...)
+}
+
+// queryTerms splits a search query into its space separated operators. The
+// value of an operator may be a double quoted phrase containing spaces, ex.
+// message:"fix gpu reset".
+func queryTerms(query string) []string {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case r == ' ' && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms
+}
+
+// matchTerm reports whether a change matches a single search operator.
+func matchTerm(change *gerrit.ChangeInfo, term string) (bool, error) {
+	parts := strings.SplitN(term, ":", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("unsupported search term %q: status code 400", term)
+	}
+	operator, value := parts[0], parts[1]
+	switch operator {
+	case "change":
+		return value == strconv.Itoa(change.Number) || value == change.ChangeID, nil
+	case "commit":
+		return value == change.CurrentRevision, nil
+	case "project":
+		return value == change.Project, nil
+	case "branch":
+		return value == change.Branch, nil
+	case "status":
+		return strings.EqualFold(value, change.Status), nil
+	case "message":
+		message := change.Subject
+		if revision, ok := change.Revisions[change.CurrentRevision]; ok && revision.Commit.Message != "" {
+			message = revision.Commit.Message
+		}
+		return strings.Contains(strings.ToLower(message), strings.ToLower(strings.Trim(value, `"`))), nil
+	}
+	return false, fmt.Errorf("unsupported search operator %q: status code 400", operator)
+}
+
+// QueryChanges implements the Gerrit change search. A query is a list of
+// space separated operators that must all match, whose values may be double
+// quoted phrases. The change, commit, project, branch, status and message
+// operators are supported, where message searches the commit message of the
+// current revision, or the subject of changes without revisions. The Limit
+// option is honored.
+func (g *Gerrit) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	g.mu.Lock()
+	g.queries = append(g.queries, opt.Query...)
+	g.mu.Unlock()
+	if len(opt.Query) != 1 {
+		return nil, nil, fmt.Errorf("expected a single query, got %d: status code 400", len(opt.Query))
+	}
+	output := []gerrit.ChangeInfo{}
+	for i := range g.Changes {
+		change := &g.Changes[i]
+		match := true
+		for _, term := range queryTerms(opt.Query[0]) {
+			ok, err := matchTerm(change, term)
+			if err != nil {
+				return nil, nil, err
+			}
+			match = match && ok
+		}
+		if match {
+			output = append(output, *change)
+		}
+		if opt.Limit > 0 && len(output) == opt.Limit {
+			break
+		}
+	}
+	return &output, nil, nil
+}
+
+// ListTags returns the tags of a project.
+func (g *Gerrit) ListTags(projectName string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, *gerrit.Response, error) {
+	tags, ok := g.Tags[projectName]
+	if !ok {
+		return nil, nil, fmt.Errorf("project %s not found: status code 404", projectName)
+	}
+	output := append([]gerrit.TagInfo(nil), tags...)
+	return &output, nil, nil
+}
+
+// ListBranches returns the branches of a project.
+func (g *Gerrit) ListBranches(projectName string, opt *gerrit.BranchOptions) (*[]gerrit.BranchInfo, *gerrit.Response, error) {
+	branches, ok := g.Branches[projectName]
+	if !ok {
+		return nil, nil, fmt.Errorf("project %s not found: status code 404", projectName)
+	}
+	output := append([]gerrit.BranchInfo(nil), branches...)
+	return &output, nil, nil
+}
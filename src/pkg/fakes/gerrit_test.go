@@ -0,0 +1,113 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"testing"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func gerritForTest() *Gerrit {
+	g := NewGerrit()
+	g.Changes = []gerrit.ChangeInfo{
+		{Number: 1, ChangeID: "I1", Project: "cos/repo", Branch: "master", Status: "MERGED", CurrentRevision: "c1", Subject: "Fix GPU reset"},
+		{Number: 2, ChangeID: "I1", Project: "cos/repo", Branch: "release-R93", Status: "MERGED", CurrentRevision: "c2", Subject: "Fix GPU reset"},
+		{Number: 3, ChangeID: "I3", Project: "cos/other", Branch: "master", Status: "NEW", CurrentRevision: "c3", Subject: "Bump version"},
+	}
+	g.Changes[2].Revisions = map[string]gerrit.RevisionInfo{
+		"c3": {Commit: gerrit.CommitInfo{Message: "Bump version\n\nPicks up the GPU driver fix.\n"}},
+	}
+	g.Tags["cos/repo"] = []gerrit.TagInfo{{Ref: "refs/tags/v1", Revision: "c1"}}
+	g.Branches["cos/repo"] = []gerrit.BranchInfo{{Ref: "refs/heads/master", Revision: "c1"}, {Ref: "refs/heads/release-R93", Revision: "c2"}}
+	return g
+}
+
+func TestGerritQueryChanges(t *testing.T) {
+	tests := map[string]struct {
+		query     string
+		limit     int
+		expected  []int
+		expectErr bool
+	}{
+		"Number":       {query: "change:3", expected: []int{3}},
+		"Change-Id":    {query: "change:I1", expected: []int{1, 2}},
+		"Limit":        {query: "change:I1", limit: 1, expected: []int{1}},
+		"Commit":       {query: "commit:c2", expected: []int{2}},
+		"All Terms":    {query: "change:I1 branch:release-R93", expected: []int{2}},
+		"Status":       {query: "status:new", expected: []int{3}},
+		"Message":      {query: "project:cos/repo message:gpu", expected: []int{1, 2}},
+		"Message Body": {query: "message:driver", expected: []int{3}},
+		"Phrase":       {query: `message:"gpu reset" status:merged`, expected: []int{1, 2}},
+		"Phrase Order": {query: `message:"reset gpu"`, expected: []int{}},
+		"No Match":     {query: "change:4", expected: []int{}},
+		"Invalid Term": {query: "I1", expectErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := gerritForTest()
+			opts := &gerrit.QueryChangeOptions{}
+			opts.Query = []string{test.query}
+			opts.Limit = test.limit
+			changes, _, err := g.QueryChanges(opts)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error %v, got %v", test.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			got := []int{}
+			for _, change := range *changes {
+				got = append(got, change.Number)
+			}
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected changes (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff([]string{test.query}, g.Queries()); diff != "" {
+				t.Errorf("unexpected recorded queries (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGerritListTags(t *testing.T) {
+	g := gerritForTest()
+	tags, _, err := g.ListTags("cos/repo", &gerrit.ProjectBaseOptions{})
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if diff := cmp.Diff([]gerrit.TagInfo{{Ref: "refs/tags/v1", Revision: "c1"}}, *tags); diff != "" {
+		t.Errorf("unexpected tags (-want +got):\n%s", diff)
+	}
+	if _, _, err := g.ListTags("cos/missing", &gerrit.ProjectBaseOptions{}); err == nil {
+		t.Error("expected an error listing the tags of a missing project")
+	}
+}
+
+func TestGerritListBranches(t *testing.T) {
+	g := gerritForTest()
+	branches, _, err := g.ListBranches("cos/repo", &gerrit.BranchOptions{})
+	if err != nil {
+		t.Fatalf("ListBranches failed: %v", err)
+	}
+	expected := []gerrit.BranchInfo{{Ref: "refs/heads/master", Revision: "c1"}, {Ref: "refs/heads/release-R93", Revision: "c2"}}
+	if diff := cmp.Diff(expected, *branches); diff != "" {
+		t.Errorf("unexpected branches (-want +got):\n%s", diff)
+	}
+	if _, _, err := g.ListBranches("cos/missing", &gerrit.BranchOptions{}); err == nil {
+		t.Error("expected an error listing the branches of a missing project")
+	}
+}
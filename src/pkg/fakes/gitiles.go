@@ -0,0 +1,189 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// Default number of commits per page, matching Gitiles
+const gitilesDefaultPageSize = 100
+
+// GitilesFile identifies a file served by the fake Gitiles service.
+type GitilesFile struct {
+	// Project is the name of the repository, ex. "cos/manifest-snapshots".
+	Project string
+	// Committish is the commit SHA or ref the file is requested at, ex.
+	// "refs/tags/15000.0.0".
+	Committish string
+	// Path is the path of the file in the repository, ex. "snapshot.xml".
+	Path string
+}
+
+// Gitiles is a fake implementation of the Log and DownloadFile methods of the
+// Gitiles API, serving fixtures set on its fields. It is intended to be
+// constructed with NewGitiles.
+//
+// Missing files, commits and refs are reported with a NotFound status, like
+// Gitiles. Commits are listed breadth first from the requested commit, which
+// is newest first for linear histories.
+//
+// The struct is safe for concurrent requests as long as its fields are not
+// modified while requests are served.
+type Gitiles struct {
+	// Files holds the contents of each file. Files requested at a ref are
+	// also looked up at the commit SHA the ref points to.
+	Files map[GitilesFile]string
+	// Commits holds the commits of each project, in any order. Ancestry is
+	// taken from the Parents field of each commit.
+	Commits map[string][]*git.Commit
+	// Refs maps each project to its refs and the commit SHA they point to,
+	// ex. "refs/heads/master".
+	Refs map[string]map[string]string
+}
+
+// NewGitiles constructs a fake Gitiles service without any fixtures.
+func NewGitiles() *Gitiles {
+	return &Gitiles{
+		Files:   make(map[GitilesFile]string),
+		Commits: make(map[string][]*git.Commit),
+		Refs:    make(map[string]map[string]string),
+	}
+}
+
+// resolve returns the commit SHA a committish refers to in project.
+func (g *Gitiles) resolve(project, committish string) (string, bool) {
+	if sha, ok := g.Refs[project][committish]; ok {
+		return sha, true
+	}
+	for _, commit := range g.Commits[project] {
+		if commit.Id == committish {
+			return committish, true
+		}
+	}
+	return "", false
+}
+
+// ancestors returns the commits reachable from sha, including itself, in
+// breadth first order.
+func ancestors(commits map[string]*git.Commit, sha string) []*git.Commit {
+	var output []*git.Commit
+	seen := map[string]bool{sha: true}
+	queue := []string{sha}
+	for len(queue) > 0 {
+		commit, ok := commits[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		output = append(output, commit)
+		for _, parent := range commit.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return output
+}
+
+// Log implements utils.GitilesService. Only the Project, Committish,
+// ExcludeAncestorsOf, PageSize, PageToken and TreeDiff fields of the request
+// are supported.
+func (g *Gitiles) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	commits := make(map[string]*git.Commit)
+	for _, commit := range g.Commits[in.Project] {
+		commits[commit.Id] = commit
+	}
+	head, ok := g.resolve(in.Project, in.Committish)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "committish %s not found in %s", in.Committish, in.Project)
+	}
+	excluded := make(map[string]bool)
+	if in.ExcludeAncestorsOf != "" {
+		ancestor, ok := g.resolve(in.Project, in.ExcludeAncestorsOf)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "committish %s not found in %s", in.ExcludeAncestorsOf, in.Project)
+		}
+		for _, commit := range ancestors(commits, ancestor) {
+			excluded[commit.Id] = true
+		}
+	}
+	var log []*git.Commit
+	for _, commit := range ancestors(commits, head) {
+		if !excluded[commit.Id] {
+			log = append(log, commit)
+		}
+	}
+
+	start := 0
+	if in.PageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(in.PageToken); err != nil || start < 0 || start > len(log) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q", in.PageToken)
+		}
+	}
+	pageSize := int(in.PageSize)
+	if pageSize <= 0 {
+		pageSize = gitilesDefaultPageSize
+	}
+	end := start + pageSize
+	// Gitiles clients never return a nil log, even if it is empty
+	resp := &gitilesProto.LogResponse{Log: []*git.Commit{}}
+	if end < len(log) {
+		resp.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(log)
+	}
+	for _, commit := range log[start:end] {
+		commit = proto.Clone(commit).(*git.Commit)
+		if !in.TreeDiff {
+			commit.TreeDiff = nil
+		}
+		resp.Log = append(resp.Log, commit)
+	}
+	return resp, nil
+}
+
+// DownloadFile implements utils.GitilesService. The requested format is
+// ignored and contents are always returned as is.
+func (g *Gitiles) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	file := GitilesFile{Project: in.Project, Committish: in.Committish, Path: in.Path}
+	contents, ok := g.Files[file]
+	if !ok {
+		if file.Committish, ok = g.resolve(in.Project, in.Committish); ok {
+			contents, ok = g.Files[file]
+		}
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "file %s not found in %s at %s", in.Path, in.Project, in.Committish)
+	}
+	return &gitilesProto.DownloadFileResponse{Contents: contents}, nil
+}
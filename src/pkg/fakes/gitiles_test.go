@@ -0,0 +1,158 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// gitilesForTest returns a fake Gitiles service with the history
+// c1 <- c2 <- c3 <- c5 and c2 <- c4 <- c5, where c5 merges c4.
+func gitilesForTest() *Gitiles {
+	g := NewGitiles()
+	g.Commits["cos/repo"] = []*git.Commit{
+		{Id: "c1"},
+		{Id: "c2", Parents: []string{"c1"}},
+		{Id: "c3", Parents: []string{"c2"}},
+		{Id: "c4", Parents: []string{"c2"}, TreeDiff: []*git.Commit_TreeDiff{{Type: git.Commit_TreeDiff_ADD, NewPath: "a.txt"}}},
+		{Id: "c5", Parents: []string{"c3", "c4"}},
+	}
+	g.Refs["cos/repo"] = map[string]string{"refs/heads/master": "c5", "refs/tags/v1": "c2"}
+	g.Files[GitilesFile{Project: "cos/repo", Committish: "c2", Path: "a.txt"}] = "a"
+	return g
+}
+
+func commitIDs(commits []*git.Commit) []string {
+	var ids []string
+	for _, commit := range commits {
+		ids = append(ids, commit.Id)
+	}
+	return ids
+}
+
+func TestGitilesLog(t *testing.T) {
+	tests := map[string]struct {
+		req               *gitilesProto.LogRequest
+		expectedIDs       []string
+		expectedNextToken string
+		expectedCode      codes.Code
+	}{
+		"Full History": {
+			req:         &gitilesProto.LogRequest{Project: "cos/repo", Committish: "refs/heads/master"},
+			expectedIDs: []string{"c5", "c3", "c4", "c2", "c1"},
+		},
+		"Exclude Ancestors": {
+			req:         &gitilesProto.LogRequest{Project: "cos/repo", Committish: "c5", ExcludeAncestorsOf: "refs/tags/v1"},
+			expectedIDs: []string{"c5", "c3", "c4"},
+		},
+		"First Page": {
+			req:               &gitilesProto.LogRequest{Project: "cos/repo", Committish: "c5", PageSize: 2},
+			expectedIDs:       []string{"c5", "c3"},
+			expectedNextToken: "2",
+		},
+		"Last Page": {
+			req:         &gitilesProto.LogRequest{Project: "cos/repo", Committish: "c5", PageSize: 2, PageToken: "4"},
+			expectedIDs: []string{"c1"},
+		},
+		"Unknown Committish": {
+			req:          &gitilesProto.LogRequest{Project: "cos/repo", Committish: "refs/heads/missing"},
+			expectedCode: codes.NotFound,
+		},
+		"Unknown Project": {
+			req:          &gitilesProto.LogRequest{Project: "cos/missing", Committish: "c5"},
+			expectedCode: codes.NotFound,
+		},
+	}
+	g := gitilesForTest()
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := g.Log(context.Background(), test.req)
+			if code := status.Code(err); code != test.expectedCode {
+				t.Fatalf("expected code %v, got %v", test.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.expectedIDs, commitIDs(resp.Log)); diff != "" {
+				t.Errorf("Log returned unexpected commits (-want +got):\n%s", diff)
+			}
+			if resp.NextPageToken != test.expectedNextToken {
+				t.Errorf("expected next page token %q, got %q", test.expectedNextToken, resp.NextPageToken)
+			}
+		})
+	}
+}
+
+func TestGitilesLogTreeDiff(t *testing.T) {
+	g := gitilesForTest()
+	for _, treeDiff := range []bool{false, true} {
+		resp, err := g.Log(context.Background(), &gitilesProto.LogRequest{Project: "cos/repo", Committish: "c4", PageSize: 1, TreeDiff: treeDiff})
+		if err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if got := len(resp.Log[0].TreeDiff) > 0; got != treeDiff {
+			t.Errorf("expected tree diff to be returned: %v, got %v", treeDiff, got)
+		}
+	}
+	if len(g.Commits["cos/repo"][3].TreeDiff) == 0 {
+		t.Errorf("expected Log to leave fixtures unchanged")
+	}
+}
+
+func TestGitilesDownloadFile(t *testing.T) {
+	tests := map[string]struct {
+		committish       string
+		path             string
+		expectedContents string
+		expectedCode     codes.Code
+	}{
+		"Commit SHA": {
+			committish:       "c2",
+			path:             "a.txt",
+			expectedContents: "a",
+		},
+		"Ref": {
+			committish:       "refs/tags/v1",
+			path:             "a.txt",
+			expectedContents: "a",
+		},
+		"Missing File": {
+			committish:   "refs/heads/master",
+			path:         "a.txt",
+			expectedCode: codes.NotFound,
+		},
+	}
+	g := gitilesForTest()
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &gitilesProto.DownloadFileRequest{Project: "cos/repo", Committish: test.committish, Path: test.path}
+			resp, err := g.DownloadFile(context.Background(), req)
+			if code := status.Code(err); code != test.expectedCode {
+				t.Fatalf("expected code %v, got %v", test.expectedCode, err)
+			}
+			if err == nil && resp.Contents != test.expectedContents {
+				t.Errorf("expected contents %q, got %q", test.expectedContents, resp.Contents)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// Maximum number of CLs retrieved when searching the CLs referencing a bug
+const maxBugCLs = 100
+
+// Bug trackers referenced by CLs
+const (
+	// buganizerTracker is the tracker of b/ bugs
+	buganizerTracker = "b"
+	// chromiumTracker is the tracker of crbug bugs
+	chromiumTracker = "chromium"
+)
+
+// bugRefRe matches a bug reference, ex. "b/123", "b:123", "crbug/123",
+// "crbug.com/123" or "chromium:123", and captures its tracker and number.
+var bugRefRe = regexp.MustCompile(`^(?:https?://)?(b|buganizer|crbug|crbug\.com|chromium)[/:](\d+)$`)
+
+// bugFooterRe matches the bug footers of a commit message, ex. "Bug: b/123"
+// or "BUG=chromium:123", and captures their comma or space separated bugs.
+var bugFooterRe = regexp.MustCompile(`(?im)^\s*(?:bug|fixed)\s*[:=]\s*(.+)$`)
+
+// bug identifies a bug in a tracker.
+type bug struct {
+	tracker string
+	number  string
+}
+
+// parseBug parses a bug reference, ex. "b/123" or "crbug/123".
+func parseBug(ref string) (bug, bool) {
+	matches := bugRefRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(ref)))
+	if matches == nil {
+		return bug{}, false
+	}
+	tracker := buganizerTracker
+	if matches[1] != "b" && matches[1] != "buganizer" {
+		tracker = chromiumTracker
+	}
+	return bug{tracker: tracker, number: matches[2]}, true
+}
+
+// referencesBug reports whether a commit message has a bug footer
+// referencing target.
+func referencesBug(message string, target bug) bool {
+	for _, matches := range bugFooterRe.FindAllStringSubmatch(message, -1) {
+		for _, ref := range strings.FieldsFunc(matches[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+			if parsed, ok := parseBug(ref); ok && parsed == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bugCLs retrieves the numbers of the merged CLs whose commit message
+// references a bug, in increasing order. Cherry-picks of a CL are listed
+// separately.
+func bugCLs(ctx context.Context, client GerritService, ref, instanceURL string) ([]string, utils.ChangelogError) {
+	target, ok := parseBug(ref)
+	if !ok {
+		log.Errorf("bugCLs: %q is not a bug reference", ref)
+		return nil, utils.BugNotFound(ref, instanceURL)
+	}
+	log.Debugf("Retrieving CLs referencing bug %s from Gerrit", ref)
+	queryOptions := &gerrit.QueryChangeOptions{}
+	queryOptions.Query = []string{fmt.Sprintf("message:%s status:merged", target.number)}
+	queryOptions.AdditionalFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT"}
+	queryOptions.Limit = maxBugCLs
+	changes, _, err := client.QueryChanges(queryOptions)
+	if err != nil {
+		log.Errorf("bugCLs: error retrieving changes referencing bug %s:\n%v", ref, err)
+		if ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		if utils.GerritErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
+		}
+		return nil, utils.InternalServerError
+	}
+	var numbers []int
+	for _, change := range *changes {
+		if change.Submitted == nil || !referencesBug(change.Revisions[change.CurrentRevision].Commit.Message, target) {
+			continue
+		}
+		numbers = append(numbers, change.Number)
+	}
+	if len(numbers) == 0 {
+		log.Errorf("bugCLs: no CL referencing bug %s found on %s", ref, instanceURL)
+		return nil, utils.BugNotFound(ref, instanceURL)
+	}
+	sort.Ints(numbers)
+	output := make([]string, len(numbers))
+	for i, number := range numbers {
+		output[i] = strconv.Itoa(number)
+	}
+	return output, nil
+}
+
+// FindBugBuilds locates the first build containing each merged CL of
+// request.GerritHost referencing a bug in a "Bug:" or "BUG=" footer, ex.
+// "b/123" or "crbug/123", to tell when the fix of the bug was released.
+// request.CL and request.CLs are ignored.
+//
+// Returns one CLBuild per CL, identified by CL number in increasing order.
+// The cherry-picks of a fix to release branches are separate CLs.
+func FindBugBuilds(ctx context.Context, request *BuildRequest, bug string) ([]*CLBuild, utils.ChangelogError) {
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
+	gerritClient, err := request.gerritClient(ctx, request.GerritHost)
+	if err != nil {
+		log.Errorf("failed to establish Gerrit client for host %s:\n%v", request.GerritHost, err)
+		return nil, utils.InternalServerError
+	}
+	cls, clErr := bugCLs(ctx, gerritClient, bug, request.GerritHost)
+	if clErr != nil {
+		return nil, clErr
+	}
+	bugRequest := *request
+	bugRequest.CLs = cls
+	return FindBuilds(ctx, &bugRequest)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReferencesBug(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		bug      string
+		expected bool
+	}{
+		"Bug Footer":         {message: "Fix\n\nBug: b/123\n", bug: "b/123", expected: true},
+		"Legacy Footer":      {message: "Fix\n\nBUG=b:123\n", bug: "b/123", expected: true},
+		"Several Bugs":       {message: "Fix\n\nBug: b/7, chromium:123\n", bug: "crbug/123", expected: true},
+		"Crbug Link":         {message: "Fix\n\nFixed: https://crbug.com/123\n", bug: "chromium:123", expected: true},
+		"Other Tracker":      {message: "Fix\n\nBug: chromium:123\n", bug: "b/123", expected: false},
+		"Longer Number":      {message: "Fix\n\nBug: b/1234\n", bug: "b/123", expected: false},
+		"Not A Footer":       {message: "Fix b/123\n", bug: "b/123", expected: false},
+		"Unsupported Format": {message: "Fix\n\nBug: b/123\n", bug: "123", expected: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, ok := parseBug(test.bug)
+			if got := ok && referencesBug(test.message, target); got != test.expected {
+				t.Errorf("expected referencesBug = %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindBugBuilds(t *testing.T) {
+	tests := map[string]struct {
+		bug           string
+		expected      map[string]string
+		expectedError string
+	}{
+		"Fix And Cherry-Pick": {
+			bug:      "b/42",
+			expected: map[string]string{"102": "3.0.0", "202": "2.1.0"},
+		},
+		"No CL": {
+			bug:           "b/43",
+			expectedError: "404",
+		},
+		"Invalid Bug": {
+			bug:           "42",
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addReleaseBranches(gr, g)
+			for i := range gr.Changes {
+				message := "Fix\n\nBug: b/42\n"
+				if gr.Changes[i].Number == 101 {
+					message = "Related to b/42\n\nBug: b/420\n"
+				}
+				gr.Changes[i].Revisions = map[string]gerrit.RevisionInfo{
+					gr.Changes[i].CurrentRevision: {Commit: gerrit.CommitInfo{Message: message}},
+				}
+			}
+			req := fakeRequest(gr, g)
+			builds, err := FindBugBuilds(context.Background(), req, test.bug)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBugBuilds failed: %v", err)
+			}
+			got := make(map[string]string)
+			for _, build := range builds {
+				if build.Err != nil {
+					t.Fatalf("CL %s not found: %v", build.CL, build.Err)
+				}
+				got[build.CL] = build.Build.BuildNum
+			}
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected builds, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
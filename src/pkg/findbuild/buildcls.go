@@ -0,0 +1,270 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/beevik/etree"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+// Maximum number of manifest commits searched for the build preceding a build
+const previousBuildSearchSize = 100
+
+// reviewedOnRe matches the Reviewed-on footer added by Gerrit to submitted
+// commits, and captures the review URL and CL number.
+var reviewedOnRe = regexp.MustCompile(`(?m)^Reviewed-on:\s*(\S+/\+/(\d+))\s*$`)
+
+// CLsResponse is the output struct for the FindCLs function
+type CLsResponse struct {
+	BuildNum string
+	// PreviousBuildNum is the build preceding BuildNum on its branch of the
+	// manifest repository.
+	PreviousBuildNum string
+	// CLs lists the CLs introduced in BuildNum, ordered by repository path,
+	// newest first within a repository.
+	CLs []*BuildCL
+}
+
+// BuildCL is a CL introduced in a build
+type BuildCL struct {
+	// CLNum and ReviewURL are read from the Reviewed-on footer of the commit,
+	// and are empty for commits that were not submitted through Gerrit.
+	CLNum     string
+	ReviewURL string
+	Project   string
+	SHA       string
+	Subject   string
+}
+
+// manifestProject is a <project> element of a manifest file.
+type manifestProject struct {
+	Name      string
+	Path      string
+	Revision  string
+	RemoteURL string
+}
+
+// manifestProjects parses the projects of a manifest file, keyed by path. An
+// empty manifest file has no projects.
+func manifestProjects(contents string) (map[string]*manifestProject, error) {
+	output := make(map[string]*manifestProject)
+	if contents == "" {
+		return output, nil
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(contents); err != nil {
+		return nil, err
+	}
+	root := doc.SelectElement("manifest")
+	if root == nil {
+		return output, nil
+	}
+	remoteMap := make(map[string]string)
+	for _, remote := range root.SelectElements("remote") {
+		remoteMap[remote.SelectAttrValue("name", "")] = strings.Replace(remote.SelectAttrValue("fetch", ""), "https://", "", 1)
+	}
+	if defaults := root.SelectElement("default"); defaults != nil {
+		remoteMap[""] = remoteMap[defaults.SelectAttrValue("remote", "")]
+	}
+	for _, project := range root.SelectElements("project") {
+		parsed := &manifestProject{
+			Name:      project.SelectAttrValue("name", ""),
+			Path:      project.SelectAttrValue("path", ""),
+			Revision:  project.SelectAttrValue("revision", ""),
+			RemoteURL: remoteMap[project.SelectAttrValue("remote", "")],
+		}
+		if parsed.Path == "" {
+			parsed.Path = parsed.Name
+		}
+		output[parsed.Path] = parsed
+	}
+	return output, nil
+}
+
+// newBuildCL returns the CL a commit was submitted through.
+func newBuildCL(project string, commit *git.Commit) *BuildCL {
+	output := &BuildCL{
+		Project: project,
+		SHA:     commit.Id,
+		Subject: strings.SplitN(commit.Message, "\n", 2)[0],
+	}
+	// Cherry-picked commits keep the footer of the original CL, so the last
+	// footer is the CL of the commit
+	if matches := reviewedOnRe.FindAllStringSubmatch(commit.Message, -1); matches != nil {
+		output.ReviewURL = matches[len(matches)-1][1]
+		output.CLNum = matches[len(matches)-1][2]
+	}
+	return output
+}
+
+// previousBuild returns the build preceding the build at manifestSHA in the
+// first-parent history of the manifest repository.
+func (s *buildSearch) previousBuild(buildNum, manifestSHA string, tags map[string]string) (string, utils.ChangelogError) {
+	buildTags := make(map[string]string)
+	for tagRef, sha := range tags {
+		buildTags[sha] = strings.TrimPrefix(tagRef, "refs/tags/")
+	}
+	commits, _, err := utils.Commits(s.ctx, s.gitilesClient, s.request.ManifestRepo, manifestSHA, "", previousBuildSearchSize)
+	if err != nil {
+		log.Errorf("previousBuild: error retrieving manifest commits before build %s:\n%v", buildNum, err)
+		if s.ctx.Err() != nil {
+			return "", utils.TimeoutError
+		}
+		return "", utils.InternalServerError
+	}
+	for _, commit := range firstParents(commits) {
+		if commit.Id == manifestSHA {
+			continue
+		}
+		if previous, ok := buildTags[commit.Id]; ok {
+			return previous, nil
+		}
+	}
+	log.Errorf("previousBuild: no build found in the %d manifest commits before build %s", previousBuildSearchSize, buildNum)
+	return "", utils.PreviousBuildNotFound(buildNum)
+}
+
+// buildProjects downloads and parses the manifest file of a build.
+func (s *buildSearch) buildProjects(buildNum string) (map[string]*manifestProject, utils.ChangelogError) {
+	contents, err := s.manifests.download(s.ctx, s.gitilesClient, s.request.ManifestRepo, buildNum)
+	if err != nil {
+		log.Errorf("buildProjects: error downloading manifest of build %s:\n%v", buildNum, err)
+		if s.ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		if utils.GitilesErrCode(err) == "404" {
+			return nil, utils.BuildNotFound(buildNum)
+		}
+		return nil, utils.InternalServerError
+	}
+	projects, err := manifestProjects(contents)
+	if err != nil {
+		log.Errorf("buildProjects: error parsing manifest of build %s:\n%v", buildNum, err)
+		return nil, utils.ManifestMalformed(buildNum)
+	}
+	return projects, nil
+}
+
+// projectCLs retrieves the CLs of a project between two revisions.
+func (s *buildSearch) projectCLs(source, target *manifestProject) ([]*BuildCL, utils.ChangelogError) {
+	client := s.gitilesClient
+	if target.RemoteURL != s.request.GitilesHost {
+		var err error
+		if client, err = s.request.gitilesClient(target.RemoteURL); err != nil {
+			log.Errorf("failed to establish Gitiles client for remote URL %s", target.RemoteURL)
+			return nil, utils.InternalServerError
+		}
+	}
+	commits, _, err := utils.Commits(s.ctx, client, target.Name, target.Revision, source.Revision, -1)
+	if err != nil {
+		log.Errorf("projectCLs: error retrieving commits of %s between %s and %s:\n%v", target.Name, source.Revision, target.Revision, err)
+		if s.ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		return nil, utils.RepoLogUnavailable(target.Name)
+	}
+	output := make([]*BuildCL, len(commits))
+	for i, commit := range commits {
+		output[i] = newBuildCL(target.Name, commit)
+	}
+	return output, nil
+}
+
+// FindCLs lists the CLs introduced in a build, relative to the previous build
+// on the same branch of the manifest repository. Only the GerritHost,
+// GitilesHost, ManifestRepo, client and Cache fields of request are used.
+//
+// Repositories added to the manifest in the build have no previous revision,
+// and their CLs are not listed.
+func FindCLs(ctx context.Context, request *BuildRequest, buildNum string) (*CLsResponse, utils.ChangelogError) {
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
+	log.Debugf("Fetching CLs introduced in build %s", buildNum)
+	start := time.Now()
+	search, clErr := newBuildSearch(ctx, request)
+	if clErr != nil {
+		return nil, clErr
+	}
+	tags, clErr := search.manifestTags()
+	if clErr != nil {
+		return nil, clErr
+	}
+	manifestSHA, ok := tags["refs/tags/"+buildNum]
+	if !ok {
+		log.Errorf("FindCLs: no tag found for build %s", buildNum)
+		return nil, utils.BuildNotFound(buildNum)
+	}
+	previous, clErr := search.previousBuild(buildNum, manifestSHA, tags)
+	if clErr != nil {
+		return nil, clErr
+	}
+	log.Debugf("Build %s is preceded by build %s", buildNum, previous)
+	sourceProjects, clErr := search.buildProjects(previous)
+	if clErr != nil {
+		return nil, clErr
+	}
+	targetProjects, clErr := search.buildProjects(buildNum)
+	if clErr != nil {
+		return nil, clErr
+	}
+	var paths []string
+	for path, target := range targetProjects {
+		source, ok := sourceProjects[path]
+		if !ok {
+			log.Debugf("FindCLs: skipping repository %s added in build %s", target.Name, buildNum)
+			continue
+		}
+		if source.Name == target.Name && source.Revision != target.Revision {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	projectCLs := make([][]*BuildCL, len(paths))
+	errs := make([]utils.ChangelogError, len(paths))
+	sem := make(chan struct{}, maxConcurrentCLs)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		i, path := i, path
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			projectCLs[i], errs[i] = search.projectCLs(sourceProjects[path], targetProjects[path])
+		}()
+	}
+	wg.Wait()
+	output := &CLsResponse{BuildNum: buildNum, PreviousBuildNum: previous}
+	for i := range paths {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		output.CLs = append(output.CLs, projectCLs[i]...)
+	}
+	log.Debugf("Retrieved %d CLs introduced in build %s in %s\n", len(output.CLs), buildNum, time.Since(start))
+	return output, nil
+}
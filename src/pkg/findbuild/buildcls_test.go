@@ -0,0 +1,122 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+func TestNewBuildCL(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		expected *BuildCL
+	}{
+		"Reviewed CL": {
+			message: "Fix build\n\nChange-Id: I101\nReviewed-on: https://cos-review.googlesource.com/c/cos/overlays/+/101\n",
+			expected: &BuildCL{
+				CLNum:     "101",
+				ReviewURL: "https://cos-review.googlesource.com/c/cos/overlays/+/101",
+				Project:   "cos/overlays",
+				SHA:       "o2",
+				Subject:   "Fix build",
+			},
+		},
+		"Cherry-Pick": {
+			message: "Fix build\n\nReviewed-on: https://cos-review.googlesource.com/c/cos/overlays/+/101\n" +
+				"(cherry picked from commit 1111)\nReviewed-on: https://cos-review.googlesource.com/c/cos/overlays/+/202\n",
+			expected: &BuildCL{
+				CLNum:     "202",
+				ReviewURL: "https://cos-review.googlesource.com/c/cos/overlays/+/202",
+				Project:   "cos/overlays",
+				SHA:       "o2",
+				Subject:   "Fix build",
+			},
+		},
+		"No Review": {
+			message:  "Merge upstream",
+			expected: &BuildCL{Project: "cos/overlays", SHA: "o2", Subject: "Merge upstream"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := newBuildCL("cos/overlays", &git.Commit{Id: "o2", Message: test.message})
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected CL, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindCLs(t *testing.T) {
+	tests := map[string]struct {
+		buildNum      string
+		expected      *CLsResponse
+		expectedError string
+	}{
+		"Single CL": {
+			buildNum: "2.0.0",
+			expected: &CLsResponse{
+				BuildNum:         "2.0.0",
+				PreviousBuildNum: "1.0.0",
+				CLs: []*BuildCL{{
+					CLNum:     "101",
+					ReviewURL: "https://cos-review.googlesource.com/c/cos/overlays/+/101",
+					Project:   "cos/overlays",
+					SHA:       "o2",
+					Subject:   "Add feature",
+				}},
+			},
+		},
+		"Unreviewed Commit": {
+			buildNum: "3.0.0",
+			expected: &CLsResponse{
+				BuildNum:         "3.0.0",
+				PreviousBuildNum: "2.0.0",
+				CLs:              []*BuildCL{{Project: "cos/overlays", SHA: overlaysHead}},
+			},
+		},
+		"First Build": {
+			buildNum:      "1.0.0",
+			expectedError: "404",
+		},
+		"Unknown Build": {
+			buildNum:      "9.0.0",
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			g.Commits["cos/overlays"][1].Message = "Add feature\n\nReviewed-on: https://cos-review.googlesource.com/c/cos/overlays/+/101\n"
+			res, err := FindCLs(context.Background(), fakeRequest(gr, g), test.buildNum)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindCLs failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default maximum age of the manifest tags read from BuildRequest.Cache
+const defaultTagsMaxAge = 10 * time.Minute
+
+// Cache stores the manifest tags and manifest files retrieved by FindBuild,
+// so repeated requests, ex. in a service deployment, do not send them again.
+// Implementations must be safe for concurrent use. Errors are not returned
+// since a failing cache only costs an extra request; implementations should
+// log them instead.
+//
+// It has the same methods as changelog.Cache, so changelog.DiskCache can be
+// used as a persistent Cache.
+type Cache interface {
+	// Get returns the value stored for key, and whether it was found.
+	Get(key string) ([]byte, bool)
+	// Set stores value for key.
+	Set(key string, value []byte)
+}
+
+// cachedTags is the cache entry for the tags of a manifest repository. Tags
+// are added with every build, so the time they were retrieved is stored to
+// expire them.
+type cachedTags struct {
+	Tags    map[string]string
+	Fetched time.Time
+}
+
+func tagsCacheKey(instanceURL, repo string) string {
+	return fmt.Sprintf("tags:%s/%s", instanceURL, repo)
+}
+
+// Manifest files at a build tag never change, so they are never expired.
+func manifestCacheKey(instanceURL, repo, buildNum string) string {
+	return fmt.Sprintf("manifest:%s/%s@%s", instanceURL, repo, buildNum)
+}
+
+// cacheGet decodes the JSON value stored for key into v. A nil cache never
+// returns a value.
+func cacheGet(cache Cache, key string, v interface{}) bool {
+	if cache == nil {
+		return false
+	}
+	data, ok := cache.Get(key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Errorf("cacheGet: ignoring malformed cache entry %s: %v", key, err)
+		return false
+	}
+	log.Debugf("Cache hit for %s", key)
+	return true
+}
+
+// cacheSet stores the JSON encoding of v for key. It does nothing if cache
+// is nil.
+func cacheSet(cache Cache, key string, v interface{}) {
+	if cache == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("cacheSet: failed to encode cache entry %s: %v", key, err)
+		return
+	}
+	cache.Set(key, data)
+}
+
+// MemoryCache is a Cache that keeps entries in memory for a limited time, in
+// front of an optional persistent Cache. Entries missing from memory are read
+// from the persistent Cache, and entries are written to both.
+type MemoryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	backend Cache
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache keeping entries in memory for
+// ttl, or for the lifetime of the process if ttl is not positive. backend
+// is the persistent Cache, or nil to only keep entries in memory.
+func NewMemoryCache(ttl time.Duration, backend Cache) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		backend: backend,
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Get implements Cache. Expired entries are removed when read.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.ttl > 0 && !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		return entry.value, true
+	}
+	if c.backend == nil {
+		return nil, false
+	}
+	value, ok := c.backend.Get(key)
+	if ok {
+		c.store(key, value)
+	}
+	return value, ok
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.store(key, value)
+	if c.backend != nil {
+		c.backend.Set(key, value)
+	}
+}
+
+func (c *MemoryCache) store(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryEntry{value: value, expires: c.now().Add(c.ttl)}
+}
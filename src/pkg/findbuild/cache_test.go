@@ -0,0 +1,149 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+func TestMemoryCache(t *testing.T) {
+	tests := map[string]struct {
+		ttl        time.Duration
+		backend    bool
+		set        bool
+		elapsed    time.Duration
+		expectedOK bool
+	}{
+		"Hit":              {ttl: time.Minute, set: true, elapsed: 30 * time.Second, expectedOK: true},
+		"Expired":          {ttl: time.Minute, set: true, elapsed: time.Minute},
+		"No TTL":           {set: true, elapsed: 24 * time.Hour, expectedOK: true},
+		"Backend":          {ttl: time.Minute, backend: true, set: true, elapsed: time.Minute, expectedOK: true},
+		"Missing":          {ttl: time.Minute, backend: true},
+		"Memory Only Miss": {ttl: time.Minute},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+			backend := NewMemoryCache(0, nil)
+			var cache *MemoryCache
+			if test.backend {
+				cache = NewMemoryCache(test.ttl, backend)
+			} else {
+				cache = NewMemoryCache(test.ttl, nil)
+			}
+			cache.now = func() time.Time { return now }
+			if test.set {
+				cache.Set("key", []byte("value"))
+			}
+			now = now.Add(test.elapsed)
+			value, ok := cache.Get("key")
+			if ok != test.expectedOK {
+				t.Fatalf("expected found %t, got %t", test.expectedOK, ok)
+			}
+			if ok && string(value) != "value" {
+				t.Errorf("expected value %q, got %q", "value", value)
+			}
+			if _, ok := backend.Get("key"); ok != (test.backend && test.set) {
+				t.Errorf("expected entry in backend %t, got %t", test.backend && test.set, ok)
+			}
+		})
+	}
+}
+
+// countingGerrit counts the tag listings sent to a Gerrit service.
+type countingGerrit struct {
+	*fakes.Gerrit
+
+	mu       sync.Mutex
+	tagLists int
+}
+
+func (g *countingGerrit) ListTags(projectName string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, *gerrit.Response, error) {
+	g.mu.Lock()
+	g.tagLists++
+	g.mu.Unlock()
+	return g.Gerrit.ListTags(projectName, opt)
+}
+
+func TestFindBuildCache(t *testing.T) {
+	tests := map[string]struct {
+		tagsMaxAge       time.Duration
+		expectedTagLists int
+	}{
+		"Cached Tags":  {expectedTagLists: 1},
+		"Expired Tags": {tagsMaxAge: -1, expectedTagLists: 2},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			countedGerrit := &countingGerrit{Gerrit: gr}
+			gitiles := &countingGitiles{GitilesService: g, downloads: make(map[string]int)}
+			cache := NewMemoryCache(time.Hour, nil)
+			for i := 0; i < 2; i++ {
+				req := fakeRequest(gr, gitiles)
+				req.GerritClient = func(string) (GerritService, error) { return countedGerrit, nil }
+				req.CL = "101"
+				req.Cache = cache
+				req.TagsMaxAge = test.tagsMaxAge
+				res, err := FindBuild(context.Background(), req)
+				if err != nil {
+					t.Fatalf("FindBuild %d failed: %v", i, err)
+				}
+				if res.BuildNum != "2.0.0" {
+					t.Errorf("FindBuild %d: expected build 2.0.0, got %s", i, res.BuildNum)
+				}
+			}
+			if countedGerrit.tagLists != test.expectedTagLists {
+				t.Errorf("expected %d tag listings, got %d", test.expectedTagLists, countedGerrit.tagLists)
+			}
+			for ref, count := range gitiles.downloads {
+				if count != 1 {
+					t.Errorf("expected manifest %s to be downloaded once, got %d", ref, count)
+				}
+			}
+		})
+	}
+}
+
+func TestFindBuildPrefetchedManifests(t *testing.T) {
+	gr, g := fakeServices()
+	manifests := make(map[string]string)
+	for file, contents := range g.Files {
+		if file.Project == externalManifestRepo {
+			manifests[strings.TrimPrefix(file.Committish, "refs/tags/")] = contents
+		}
+	}
+	gitiles := &countingGitiles{GitilesService: g, downloads: make(map[string]int)}
+	req := fakeRequest(gr, gitiles)
+	req.CL = "101"
+	req.Manifests = manifests
+	res, err := FindBuild(context.Background(), req)
+	if err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	if res.BuildNum != "2.0.0" {
+		t.Errorf("expected build 2.0.0, got %s", res.BuildNum)
+	}
+	if len(gitiles.downloads) != 0 {
+		t.Errorf("expected no manifest to be downloaded, got %v", gitiles.downloads)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Candidate is a build examined while searching the first build containing
+// a CL.
+type Candidate struct {
+	BuildNum string
+	// Revision is the revision of the CL's repository in the build. It is
+	// empty if the manifest of the build could not be read, or does not list
+	// the repository.
+	Revision string `json:",omitempty"`
+	// Checked indicates that the build was checked for the CL. Builds that
+	// are not checked are assumed to contain the CL if a previous build
+	// does.
+	Checked bool
+	// Contains indicates that the build contains the CL, if it was checked.
+	Contains bool
+}
+
+// rangeResult is the first build containing a CL found in a search window,
+// and the candidate builds examined to find it.
+type rangeResult struct {
+	BuildNum   string
+	Candidates []*Candidate
+	Notes      []string
+	Timings    Timings
+}
+
+// examinedCandidates returns the candidate builds of a search window, from
+// the earliest to the latest. buildNums is in reverse chronological order,
+// revisions maps builds to the revision of the CL's repository, and checked
+// maps the revisions checked for the CL to the result.
+func examinedCandidates(buildNums []string, revisions map[string]string, checked map[string]bool) []*Candidate {
+	output := make([]*Candidate, 0, len(buildNums))
+	for i := len(buildNums) - 1; i >= 0; i-- {
+		candidate := &Candidate{BuildNum: buildNums[i], Revision: revisions[buildNums[i]]}
+		if candidate.Revision != "" {
+			candidate.Contains, candidate.Checked = checked[candidate.Revision]
+		}
+		output = append(output, candidate)
+	}
+	return output
+}
+
+// ambiguityNotes explains why buildNum may not be the first build containing
+// a CL. The builds directly preceding it whose revision of the CL's
+// repository is unknown may contain the CL.
+func ambiguityNotes(candidates []*Candidate, buildNum string, clData *clData) []string {
+	found := -1
+	for i, candidate := range candidates {
+		if candidate.BuildNum == buildNum {
+			found = i
+			break
+		}
+	}
+	var gaps []string
+	for i := found - 1; i >= 0 && candidates[i].Revision == ""; i-- {
+		gaps = append([]string{candidates[i].BuildNum}, gaps...)
+	}
+	if len(gaps) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("the manifests of builds %s preceding build %s could not be read or do not list repository %s, so the CL may have landed in them",
+		strings.Join(gaps, ", "), buildNum, clData.Project)}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindBuildCandidates(t *testing.T) {
+	tests := map[string]struct {
+		cl                 string
+		missingRepo        string
+		expectedBuild      string
+		expectedCandidates []*Candidate
+		expectedNotes      []string
+	}{
+		"Unambiguous": {
+			cl:            "101",
+			expectedBuild: "2.0.0",
+			expectedCandidates: []*Candidate{
+				{BuildNum: "1.0.0", Revision: "o1", Checked: true},
+				{BuildNum: "2.0.0", Revision: "o2", Checked: true, Contains: true},
+				{BuildNum: "3.0.0", Revision: overlaysHead, Checked: true, Contains: true},
+			},
+		},
+		"Manifest Gap": {
+			cl:            "102",
+			missingRepo:   "2.0.0",
+			expectedBuild: "3.0.0",
+			expectedCandidates: []*Candidate{
+				{BuildNum: "2.0.0"},
+				{BuildNum: "3.0.0", Revision: overlaysHead, Checked: true, Contains: true},
+			},
+			expectedNotes: []string{"the manifests of builds 2.0.0 preceding build 3.0.0 could not be read or do not list repository cos/overlays, so the CL may have landed in them"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			if test.missingRepo != "" {
+				g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + test.missingRepo, Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+</manifest>`
+			}
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			res, err := FindBuild(context.Background(), req)
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != test.expectedBuild {
+				t.Errorf("expected build %s, got %s", test.expectedBuild, res.BuildNum)
+			}
+			if diff := cmp.Diff(test.expectedCandidates, res.Candidates); diff != "" {
+				t.Errorf("unexpected candidates, diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.expectedNotes, res.Notes); diff != "" {
+				t.Errorf("unexpected notes, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
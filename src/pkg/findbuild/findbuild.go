@@ -14,7 +14,8 @@
 
 // This package returns the build number of the first build containing
 // a specified CL. It accepts any value that identifies a unique CL,
-// such as a CL Number or Commit SHA.
+// such as a CL Number or Commit SHA, or a substring of the subject of a
+// merged CL containing spaces.
 //
 // This package uses information from Gerrit and Git on Borg to complete a
 // request. To locate the first build containing a CL, the package first
@@ -22,24 +23,54 @@
 // submission time of the user-provided CL. It then retrieves a list of
 // commits that were submitted in the manifest repository under the same
 // release branch. It narrows down the commit list to all commits that were
-// made within 5 days of the CL submission, and downloads/parses each manifest
-// file created within this time range concurrently. Each thread retrieves
+// made within a search window (5 days by default) of the CL submission, and
+// downloads/parses each manifest file created within this time range
+// concurrently. The window expands if the CL is not found. Each thread retrieves
 // the commit SHA associated with the CL's repository and branch in the
-// manifest file, and maps it to the manifest file's build number. It creates
-// a repository changelog between the first and last commit SHA in the window,
-// and traverses the changelog until it encounters the target CL. It then
-// continues traversing until it encounters a commit SHA that exists in the
-// build mapping. This is the first build containing the CL, and is returned.
+// manifest file, and maps it to the manifest file's build number. The first
+// build whose commit SHA has the CL's commit as an ancestor contains the CL,
+// and is returned. Builds are binary searched with ancestry queries, so the
+// changelog of the repository is never retrieved and merges are followed.
+// The latest and earliest builds, where the binary search starts, are
+// checked while the other manifest files are still downloaded and parsed.
+//
+// With BuildRequest.AllMilestones, the search is repeated on every release
+// branch of the manifest repository, for the cherry-pick of the CL to that
+// branch, or for the CL itself if it was submitted to master.
+// BuildRequest.Milestones restricts the search to some release branches, and
+// FindLTSBuilds uses it to report the first build on each LTS milestone.
+//
+// With BuildRequest.DetectReverts, the revert of the CL on its branch is
+// searched after its first build is found, as well as the first build
+// containing the revert.
+//
+// With BuildRequest.ReleaseChecker, the builds found are checked for a
+// public image, since manifest tags sometimes exist for builds that never
+// shipped.
+//
+// With BuildRequest.UpstreamKernel, the user-provided value is the SHA of an
+// upstream Linux kernel commit, and the CL searched is its earliest backport.
+//
+// With BuildRequest.ManifestRepos, the manifest repositories are searched in
+// order, and a CL is searched in the next repository if no build of the
+// previous one contains it.
+//
+// FindBugBuilds searches the CLs referencing a bug in their Bug footer, ex.
+// b/123456, to report when the fix of the bug was released.
+//
+// FindCLs performs the reverse lookup: it lists the CLs introduced in a build
+// by comparing its manifest file with the one of the previous build.
 
 package findbuild
 
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -50,24 +81,27 @@ import (
 	"github.com/beevik/etree"
 	_ "github.com/go-sql-driver/mysql"
 	"go.chromium.org/luci/common/proto/git"
-	"go.chromium.org/luci/common/proto/gitiles"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	gerrit "github.com/andygrunwald/go-gerrit"
-	log "github.com/sirupsen/logrus"
 	gitilesApi "go.chromium.org/luci/common/api/gitiles"
-	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 )
 
 const (
 	// Exponential search range variables
-	defaultSearchRange    = 5 // Search range in days
-	searchRangeMultiplier = 5
+	defaultSearchRange    = 5 * 24 * time.Hour
+	searchRangeMultiplier = 3
 	// Maximum time to wait for a response from a Gerrit or Gitiles request
 	requestMaxAge = 30 * time.Second
-	// Max size of changelog if no changelog source is specified
-	noSourceChangelogSize = 10000
+	// Maximum number of CLs searched at the same time by FindBuilds
+	maxConcurrentCLs = 8
+	// Maximum number of CLs listed when a CL identifier is not unique
+	maxCLCandidates = 10
+	// Maximum number of CLs retrieved when searching a CL by subject
+	maxSubjectMatches = 100
+	// Maximum number of builds listed when no build contains a CL
+	maxNearestBuilds = 3
 
 	shortSHALength = 7
 	fullSHALength  = 40
@@ -80,37 +114,23 @@ const (
 )
 
 var (
-	// clReleaseMapping is used to handle special cases where a CL's branch
-	// name does not map to a branch in the manifest repository.
-	clReleaseMapping = map[string]struct {
-		// releaseRe is the regexp used to retrieve the release branch name
-		// from a CL branch name
-		releaseRe *regexp.Regexp
-		// defaultRelease is the release branch that will be used if there is
-		// no regexp match
-		defaultRelease string
-	}{
-		"third_party/kernel": {
-			releaseRe:      regexp.MustCompile("(.*)-cos-.*"),
-			defaultRelease: "master",
-		},
-		"chromiumos/third_party/kernel": {
-			releaseRe:      regexp.MustCompile("(.*)-chromeos-.*"),
-			defaultRelease: "master",
-		},
-		"chromiumos/third_party/lakitu-kernel": {
-			releaseRe:      regexp.MustCompile("(.*)-lakitu-.*"),
-			defaultRelease: "master",
-		},
-	}
-	// crosRepoRe is used to strip chromium prefixes from the repo name.
-	crosRepoRe = regexp.MustCompile("^(?:chromeos|chrome|chromiumos|chromium)?/(.*)")
+	// Messages are written to the Logger set by utils.SetLogger
+	log = utils.Log
 )
 
 // BuildRequest is the input struct for the FindBuild function
 type BuildRequest struct {
 	// HttpClient is a authorized http.Client object with Gerrit scope.
 	HTTPClient *http.Client
+	// HostHTTPClient returns the authorized http.Client used to query a
+	// Gerrit or GoB host, ex. "cos-review.googlesource.com" or
+	// "cos.googlesource.com" (note the lack of https://), for hosts requiring
+	// different credentials than HTTPClient. HTTPClient is used for the hosts
+	// it returns a nil client for.
+	HostHTTPClient func(host string) (*http.Client, error)
+	// ClientPool shares the clients of each host between requests. If set,
+	// it is used instead of HTTPClient and HostHTTPClient.
+	ClientPool *ClientPool
 	// GerritHost is the Gerrit instance to query from.
 	// ex. "https://cos-review.googlesource.com"
 	GerritHost string
@@ -121,28 +141,506 @@ type BuildRequest struct {
 	// ManifestRepo is the repository the manifest.xml files are located in.
 	// ex. "cos/manifest-snapshots"
 	ManifestRepo string
+	// ManifestRepos lists the manifest repositories of GitilesHost searched
+	// in priority order, ex. the snapshots of release builders before those
+	// of periodic builders. A CL is only searched in a repository if no
+	// build of the previous ones contains it. Defaults to ManifestRepo.
+	ManifestRepos []string
 	// CL can be either the CL number or commit SHA of your target CL
-	// ex. 3741 or If9f774179322c413fa0fd5ebb3dd615c5b22cd6c
+	// ex. 3741 or If9f774179322c413fa0fd5ebb3dd615c5b22cd6c, or a substring
+	// of its subject containing spaces, ex. "fix gpu reset". A subject
+	// matching several merged CLs returns a utils.CLNotUniqueError.
 	CL string
+	// CLs lists the CLs searched by FindBuilds, in the same format as CL.
+	// FindBuild ignores it.
+	CLs []string
+	// Branch selects the CL on a branch, ex. "release-R97-16919.B", when
+	// the CL identifier matches several CLs, such as the cherry-picks of a
+	// Change-Id. A utils.CLNotUniqueError is returned if none of them, or
+	// several, are on Branch.
+	Branch string
+	// AllMilestones searches every release branch of ManifestRepo for the
+	// CL, its cherry-picks included, instead of only the CL's branch. The
+	// first build containing the CL on each branch is listed in
+	// BuildResponse.Milestones.
+	AllMilestones bool
+	// Milestones restricts the release branches searched with AllMilestones
+	// to those of the listed milestones, ex. the active LTS milestones. Every
+	// release branch is searched if empty.
+	Milestones []int
+	// UpstreamKernel indicates that CL, or each of CLs, is the SHA of an
+	// upstream Linux kernel commit. The CLs backporting it to the COS kernel
+	// are located by their cherry-pick footer, and the build containing the
+	// earliest backport is searched.
+	UpstreamKernel bool
+	// ReleaseChecker reports whether the build found, and the builds of
+	// BuildResponse.Milestones, were released in BuildResponse.Released, ex.
+	// with NewImageReleaseChecker. Nothing is checked if nil.
+	ReleaseChecker ReleaseChecker
+	// DetectReverts searches the revert of the CL on its branch, and the
+	// first build containing it, which is reported in BuildResponse.Revert.
+	// It also applies to the cherry-picks found with AllMilestones.
+	DetectReverts bool
+	// GitilesClient creates the client used to query a GoB instance, ex.
+	// "cos.googlesource.com", instead of a Gitiles client sending requests
+	// with HTTPClient. It lets callers supply instrumented clients, or fakes
+	// in tests.
+	GitilesClient func(remoteURL string) (utils.GitilesService, error)
+	// SearchWindow is the time after a CL's submission in which the builds
+	// that could contain it are searched first. Defaults to 5 days.
+	SearchWindow time.Duration
+	// MaxSearchWindow caps the time after a CL's submission in which builds
+	// are searched as the window expands, for CLs that are not found in the
+	// first window. The window expands until every build is searched if not
+	// positive.
+	MaxSearchWindow time.Duration
+	// GerritClient creates the client used to query a Gerrit instance, ex.
+	// "https://cos-review.googlesource.com", instead of a Gerrit client
+	// sending requests with HTTPClient.
+	GerritClient func(host string) (GerritService, error)
+	// Cache stores the manifest tags and manifest files retrieved from
+	// GitilesHost, so they are shared between requests using the same Cache.
+	// Nothing is cached if nil.
+	Cache Cache
+	// TagsMaxAge is the maximum age of the manifest tags read from Cache.
+	// Defaults to 10 minutes. Tags are always retrieved if negative.
+	TagsMaxAge time.Duration
+	// Manifests holds the contents of manifest files already downloaded from
+	// GitilesHost, ex. by a changelog service running in the same process,
+	// keyed by build number. The manifest files of these builds are read from
+	// it instead of Cache or GitilesHost. Other builds are downloaded as usual.
+	Manifests map[string]string
+	// Mapping describes how CLs map to the repositories and release
+	// branches of the manifest repository. Defaults to DefaultMappingConfig.
+	Mapping *MappingConfig
+	// Hooks receives the progress of the search. Nothing is reported if nil.
+	Hooks Hooks
+	// RetryPolicy describes how failed Gerrit and Gitiles requests are
+	// retried. Defaults to utils.DefaultRetryPolicy. Requests are not retried
+	// if its MaxAttempts is 1.
+	RetryPolicy *utils.RetryPolicy
+	// Scheduler limits the rate and concurrency of the Gerrit and Gitiles
+	// requests. Sharing it with other callers, ex. changelog.Options.Scheduler,
+	// bounds their requests as a whole. Requests are not limited if nil.
+	Scheduler *utils.Scheduler
+}
+
+// GerritService is the subset of the Gerrit API used by findbuild.
+type GerritService interface {
+	// QueryChanges returns the changes matching a search query.
+	QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error)
+	// ListTags returns the tags of a project.
+	ListTags(projectName string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, *gerrit.Response, error)
+	// ListBranches returns the branches of a project.
+	ListBranches(projectName string, opt *gerrit.BranchOptions) (*[]gerrit.BranchInfo, *gerrit.Response, error)
+}
+
+// gerritService implements GerritService with a go-gerrit client.
+type gerritService struct {
+	client *gerrit.Client
+}
+
+func (s gerritService) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	return s.client.Changes.QueryChanges(opt)
+}
+
+func (s gerritService) ListTags(projectName string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, *gerrit.Response, error) {
+	return s.client.Projects.ListTags(projectName, opt)
+}
+
+func (s gerritService) ListBranches(projectName string, opt *gerrit.BranchOptions) (*[]gerrit.BranchInfo, *gerrit.Response, error) {
+	return s.client.Projects.ListBranches(projectName, opt)
+}
+
+// httpClient returns the authorized client used to query a Gerrit or GoB
+// host.
+func (r *BuildRequest) httpClient(host string) (*http.Client, error) {
+	if r.ClientPool != nil {
+		return r.ClientPool.hostClient(host)
+	}
+	if r.HostHTTPClient != nil {
+		client, err := r.HostHTTPClient(normalizeHost(host))
+		if err != nil {
+			return nil, err
+		}
+		if client != nil {
+			return client, nil
+		}
+	}
+	return r.HTTPClient, nil
+}
+
+// HostHTTPClients returns a BuildRequest.HostHTTPClient using the client of
+// each host of clients, keyed by host without scheme, ex.
+// "cos.googlesource.com".
+func HostHTTPClients(clients map[string]*http.Client) func(host string) (*http.Client, error) {
+	return func(host string) (*http.Client, error) {
+		return clients[host], nil
+	}
+}
+
+// gitilesClient creates the client used to query the GoB instance at
+// remoteURL.
+func (r *BuildRequest) gitilesClient(remoteURL string) (utils.GitilesService, error) {
+	if r.GitilesClient != nil {
+		return r.GitilesClient(remoteURL)
+	}
+	if r.ClientPool != nil {
+		return r.ClientPool.gitilesClient(remoteURL)
+	}
+	httpClient, err := r.httpClient(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	return gitilesApi.NewRESTClient(httpClient, remoteURL, true)
+}
+
+// searchWindow returns the initial search window of the request.
+func (r *BuildRequest) searchWindow() time.Duration {
+	window := r.SearchWindow
+	if window <= 0 {
+		window = defaultSearchRange
+	}
+	if r.MaxSearchWindow > 0 && window > r.MaxSearchWindow {
+		window = r.MaxSearchWindow
+	}
+	return window
+}
+
+// manifestRepos returns the manifest repositories searched by the request,
+// in priority order.
+func (r *BuildRequest) manifestRepos() []string {
+	if len(r.ManifestRepos) > 0 {
+		return r.ManifestRepos
+	}
+	return []string{r.ManifestRepo}
+}
+
+// tagsMaxAge returns the maximum age of the manifest tags read from the
+// cache of the request.
+func (r *BuildRequest) tagsMaxAge() time.Duration {
+	if r.TagsMaxAge == 0 {
+		return defaultTagsMaxAge
+	}
+	return r.TagsMaxAge
+}
+
+// gerritClient creates the client used to query the Gerrit instance at host.
+// Its requests are sent with ctx.
+func (r *BuildRequest) gerritClient(ctx context.Context, host string) (GerritService, error) {
+	if r.GerritClient != nil {
+		client, err := r.GerritClient(host)
+		if err != nil {
+			return nil, err
+		}
+		return retryingGerrit{GerritService: client, ctx: ctx, policy: r.retryPolicy(), scheduler: r.Scheduler}, nil
+	}
+	hostClient, err := r.httpClient(host)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{}
+	if hostClient != nil {
+		*httpClient = *hostClient
+	}
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpClient.Transport = contextTransport{ctx: ctx, base: transport}
+	client, err := gerrit.NewClient(host, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return retryingGerrit{GerritService: gerritService{client}, ctx: ctx, policy: r.retryPolicy(), scheduler: r.Scheduler}, nil
+}
+
+// contextTransport sends requests with the context of a FindBuild call,
+// since the Gerrit client does not accept one.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
 }
 
 // iterCache contains information to perform an iteration of the
 // findBuildInRange search on a specific time range. It is used to pass information
 // that does not change between iterations, such as manifest tags
 type iterCache struct {
-	GitilesClient   gitilesProto.GitilesClient
+	GitilesClient   utils.GitilesService
 	ManifestCommits []*git.Commit
 	Tags            map[string]string
+	Manifests       *manifestCache
+	// Timings accumulates the time spent in the stages of every search
+	// window
+	Timings *Timings
+	// Builds lists the candidate builds of the last search window, in
+	// reverse chronological order
+	Builds []string
+}
+
+// manifestCache shares the manifest files downloaded by the searches of a
+// single FindBuild or FindBuilds call, since CLs submitted around the same
+// time have the same candidate builds. Files are also read from and written
+// to the Cache of the request, if any. Files provided by the caller are never
+// downloaded.
+type manifestCache struct {
+	mu    sync.Mutex
+	files map[string]*cachedManifest
+
+	prefetched  map[string]string
+	cache       Cache
+	instanceURL string
+}
+
+type cachedManifest struct {
+	once     sync.Once
+	contents string
+	err      error
+}
+
+func newManifestCache(prefetched map[string]string, cache Cache, instanceURL string) *manifestCache {
+	return &manifestCache{
+		files:       make(map[string]*cachedManifest),
+		prefetched:  prefetched,
+		cache:       cache,
+		instanceURL: instanceURL,
+	}
+}
+
+// download returns the contents of the manifest file of a build, downloading
+// it only if neither the caller nor another search did.
+func (c *manifestCache) download(ctx context.Context, client utils.GitilesService, manifestRepo, buildNum string) (string, error) {
+	if contents, ok := c.prefetched[buildNum]; ok {
+		log.Debugf("Using prefetched manifest of build %s", buildNum)
+		return contents, nil
+	}
+	c.mu.Lock()
+	file, ok := c.files[buildNum]
+	if !ok {
+		file = &cachedManifest{}
+		c.files[buildNum] = file
+	}
+	c.mu.Unlock()
+	file.once.Do(func() {
+		key := manifestCacheKey(c.instanceURL, manifestRepo, buildNum)
+		if cacheGet(c.cache, key, &file.contents) {
+			return
+		}
+		response, err := utils.DownloadManifest(ctx, client, manifestRepo, buildNum)
+		if err != nil {
+			file.err = err
+			return
+		}
+		file.contents = response.Contents
+		cacheSet(c.cache, key, file.contents)
+	})
+	return file.contents, file.err
+}
+
+// releaseCommits holds the commits of a release branch of the manifest
+// repository.
+type releaseCommits struct {
+	once    sync.Once
+	commits []*git.Commit
+	err     error
+}
+
+// buildSearch holds the clients and data shared by the CLs searched by a
+// single FindBuild or FindBuilds call. The manifest commits of each release
+// branch, the manifest tags and the manifest files are only retrieved once.
+type buildSearch struct {
+	ctx           context.Context
+	request       *BuildRequest
+	gitilesClient utils.GitilesService
+	gerritClient  GerritService
+	manifests     *manifestCache
+	mapping       *clMapping
+
+	mu       sync.Mutex
+	releases map[string]*releaseCommits
+
+	tagsOnce sync.Once
+	tags     map[string]string
+	tagsErr  utils.ChangelogError
+
+	branchesOnce sync.Once
+	branches     []string
+	branchesErr  utils.ChangelogError
+}
+
+func newBuildSearch(ctx context.Context, request *BuildRequest) (*buildSearch, utils.ChangelogError) {
+	if request.RetryPolicy != nil {
+		ctx = utils.WithRetryPolicy(ctx, *request.RetryPolicy)
+	}
+	if request.Scheduler != nil {
+		ctx = utils.WithScheduler(ctx, request.Scheduler)
+	}
+	gitilesClient, err := request.gitilesClient(request.GitilesHost)
+	if err != nil {
+		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
+		return nil, utils.InternalServerError
+	}
+	gerritClient, err := request.gerritClient(ctx, request.GerritHost)
+	if err != nil {
+		log.Errorf("failed to establish Gerrit client for host %s:\n%v", request.GerritHost, err)
+		return nil, utils.InternalServerError
+	}
+	mappingConfig := request.Mapping
+	if mappingConfig == nil {
+		mappingConfig = DefaultMappingConfig()
+	}
+	mapping, err := mappingConfig.compile()
+	if err != nil {
+		log.Errorf("invalid CL mapping config:\n%v", err)
+		return nil, utils.InternalServerError
+	}
+	return &buildSearch{
+		ctx:           ctx,
+		request:       request,
+		gitilesClient: gitilesClient,
+		gerritClient:  gerritClient,
+		manifests:     newManifestCache(request.Manifests, request.Cache, request.GitilesHost),
+		mapping:       mapping,
+		releases:      make(map[string]*releaseCommits),
+	}, nil
+}
+
+// firstParents returns the first-parent chain of commits listed by a Gitiles
+// log, starting from the first commit listed. The log of a history with
+// merges also lists the commits of the merged branches, whose builds are not
+// ordered with the builds of the branch, so they are left out. The chain stops
+// at the first parent missing from commits.
+func firstParents(commits []*git.Commit) []*git.Commit {
+	if len(commits) == 0 {
+		return commits
+	}
+	byID := make(map[string]*git.Commit, len(commits))
+	for _, commit := range commits {
+		byID[commit.Id] = commit
+	}
+	chain := []*git.Commit{commits[0]}
+	for commit := commits[0]; len(commit.Parents) > 0; {
+		parent, ok := byID[commit.Parents[0]]
+		if !ok {
+			break
+		}
+		chain = append(chain, parent)
+		commit = parent
+	}
+	return chain
+}
+
+// manifestCommits returns the first-parent chain of a release branch of the
+// manifest repository, newest first.
+func (s *buildSearch) manifestCommits(release string) ([]*git.Commit, error) {
+	s.mu.Lock()
+	entry, ok := s.releases[release]
+	if !ok {
+		entry = &releaseCommits{}
+		s.releases[release] = entry
+	}
+	s.mu.Unlock()
+	entry.once.Do(func() {
+		entry.commits, _, entry.err = utils.Commits(s.ctx, s.gitilesClient, s.request.ManifestRepo, "refs/heads/"+release, "", -1)
+		entry.commits = firstParents(entry.commits)
+	})
+	return entry.commits, entry.err
+}
+
+// manifestTags returns the commit SHA each tag of the manifest repository
+// points to, keyed by tag ref.
+func (s *buildSearch) manifestTags() (map[string]string, utils.ChangelogError) {
+	s.tagsOnce.Do(func() {
+		key := tagsCacheKey(s.request.GitilesHost, s.request.ManifestRepo)
+		var cached cachedTags
+		if maxAge := s.request.tagsMaxAge(); maxAge >= 0 && cacheGet(s.request.Cache, key, &cached) && time.Since(cached.Fetched) < maxAge {
+			s.tags = cached.Tags
+			return
+		}
+		// The manifest Gerrit client will be used for finding information
+		// associated with an annotated git tag.
+		gerritClient, clErr := s.manifestGerritClient()
+		if clErr != nil {
+			s.tagsErr = clErr
+			return
+		}
+		var err error
+		if s.tags, err = repoTags(gerritClient, s.request.ManifestRepo); err != nil {
+			log.Errorf("failed to retrieve tags for project %s:\n%v", s.request.ManifestRepo, err)
+			s.tagsErr = utils.InternalServerError
+			if s.ctx.Err() != nil {
+				s.tagsErr = utils.TimeoutError
+			}
+			return
+		}
+		cacheSet(s.request.Cache, key, cachedTags{Tags: s.tags, Fetched: time.Now()})
+	})
+	return s.tags, s.tagsErr
+}
+
+// manifestGerritClient creates a Gerrit client based on the manifest
+// repository's instance.
+func (s *buildSearch) manifestGerritClient() (GerritService, utils.ChangelogError) {
+	instanceURL, err := utils.CreateGerritURL(s.request.GitilesHost)
+	if err != nil {
+		log.Errorf("failed to create Gerrit URL from Gitiles Host %q: %v", s.request.GitilesHost, err)
+		return nil, utils.InternalServerError
+	}
+	gerritClient, err := s.request.gerritClient(s.ctx, instanceURL)
+	if err != nil {
+		log.Errorf("failed to establish Gerrit client for host %s:\n%v", instanceURL, err)
+		return nil, utils.InternalServerError
+	}
+	return gerritClient, nil
 }
 
 // BuildResponse is the output struct for the FindBuild function
 type BuildResponse struct {
 	BuildNum string
 	CLNum    string
+	// SearchWindow is the time after the CL's submission that was searched
+	// when the build was found.
+	SearchWindow time.Duration
+	// Release is the branch of the manifest repository the build was found
+	// on, ex. "master" or "release-R93".
+	Release string
+	// ManifestRepo is the manifest repository the build was found in.
+	ManifestRepo string
+	// Revert describes the revert of the CL on its branch, if it was
+	// searched with BuildRequest.DetectReverts. It is nil if the CL was not
+	// reverted.
+	Revert *Revert
+	// Candidates lists the builds examined in the search window BuildNum
+	// was found in, from the earliest to the latest.
+	Candidates []*Candidate
+	// Notes explain why BuildNum may not be the first build containing the
+	// CL, ex. builds whose manifest does not list the CL's repository. It is
+	// empty if the answer is not ambiguous.
+	Notes []string
+	// Timings is the time spent in each stage of the search. The Timings of
+	// Milestones only cover the search of their branch.
+	Timings Timings
+	// Released reports whether BuildNum was released, if it was checked with
+	// BuildRequest.ReleaseChecker. It is nil if it was not checked, or the
+	// check failed.
+	Released *bool
+	// Milestones lists the first build containing the CL on each branch of
+	// the manifest repository, if requested with BuildRequest.AllMilestones.
+	// Release branches are ordered by milestone, followed by the CL's own
+	// branch if it is not a release branch.
+	Milestones []*BuildResponse
 }
 
 type clData struct {
+	// ID is the identifier the CL was searched with, reported to
+	// BuildRequest.Hooks
+	ID               string
 	CLNum            string
+	ChangeID         string
 	InstanceURL      string
 	Project          string
 	Release          string
@@ -150,13 +648,20 @@ type clData struct {
 	Revision         string
 	SearchStartRange time.Time
 	SearchEndRange   time.Time
+	// UpstreamCommit is the upstream commit backported by the CL, if it was
+	// searched with BuildRequest.UpstreamKernel.
+	UpstreamCommit string
 }
 
 type repoData struct {
 	Candidates map[string]string
-	SourceSHA  string
-	TargetSHA  string
-	RemoteURL  string
+	// Revisions maps each build whose manifest was read to the revision of
+	// the CL's repository in it, which is empty if the manifest does not
+	// list the repository.
+	Revisions map[string]string
+	SourceSHA string
+	TargetSHA string
+	RemoteURL string
 }
 
 type manifestResponse struct {
@@ -167,25 +672,46 @@ type manifestResponse struct {
 	Err       error
 }
 
+// isSubject reports whether a CL identifier is a substring of the subject of
+// a CL, ex. the title of a patch in a security advisory, rather than a CL
+// number, Change-Id or commit SHA. Only subjects contain spaces.
+func isSubject(clID string) bool {
+	return strings.ContainsAny(strings.TrimSpace(clID), " \t")
+}
+
 func queryString(clID string) string {
+	if isSubject(clID) {
+		phrase := strings.Join(strings.Fields(strings.ReplaceAll(clID, `"`, " ")), " ")
+		return fmt.Sprintf(`message:"%s" status:merged`, phrase)
+	}
 	if len(clID) == fullSHALength {
 		return fmt.Sprintf("commit:%s", clID)
 	}
 	return fmt.Sprintf("change:%s", clID)
 }
 
-// queryCL retrieves the list of CLs matching a query from Gerrit
-func queryCL(client *gerrit.Client, clID, instanceURL string) (gerrit.ChangeInfo, utils.ChangelogError) {
+// queryCL retrieves the CL matching an identifier from Gerrit. An identifier
+// matching several CLs, ex. the Change-Id of a cherry-picked CL, returns a
+// utils.CLNotUniqueError listing them, unless exactly one of them is on
+// branch. A subject substring matches the merged CLs whose subject contains
+// it, ignoring case.
+func queryCL(ctx context.Context, client GerritService, clID, branch, instanceURL string) (gerrit.ChangeInfo, utils.ChangelogError) {
 	log.Debugf("Retrieving CL List from Gerrit for clID: %q", clID)
 	query := queryString(clID)
 	queryOptions := &gerrit.QueryChangeOptions{}
 	queryOptions.Query = []string{query}
 	queryOptions.AdditionalFields = []string{"CURRENT_REVISION"}
-	queryOptions.Limit = 1
+	queryOptions.Limit = maxCLCandidates
+	if isSubject(clID) {
+		queryOptions.Limit = maxSubjectMatches
+	}
 
-	clList, _, err := client.Changes.QueryChanges(queryOptions)
+	clList, _, err := client.QueryChanges(queryOptions)
 	if err != nil {
 		log.Errorf("queryCL: Error retrieving change for input %s:\n%v", clID, err)
+		if ctx.Err() != nil {
+			return gerrit.ChangeInfo{}, utils.TimeoutError
+		}
 		httpCode := utils.GerritErrCode(err)
 		if httpCode == "403" {
 			return gerrit.ChangeInfo{}, utils.ForbiddenError
@@ -194,10 +720,37 @@ func queryCL(client *gerrit.Client, clID, instanceURL string) (gerrit.ChangeInfo
 		}
 		return gerrit.ChangeInfo{}, utils.InternalServerError
 	}
+	if isSubject(clID) {
+		clList = subjectMatches(*clList, clID)
+	}
 	if len(*clList) == 0 {
 		log.Errorf("queryCL: CL with identifier %s not found", clID)
 		return gerrit.ChangeInfo{}, utils.CLNotFound(clID)
 	}
+	if len(*clList) > 1 && branch != "" {
+		if onBranch := branchMatches(*clList, branch); len(onBranch) == 1 {
+			log.Debugf("queryCL: CL identifier %s matches %d CLs, selected the CL on branch %s", clID, len(*clList), branch)
+			clList = &onBranch
+		}
+	}
+	if len(*clList) > 1 {
+		log.Errorf("queryCL: CL identifier %s matches %d CLs", clID, len(*clList))
+		matches := *clList
+		if len(matches) > maxCLCandidates {
+			matches = matches[:maxCLCandidates]
+		}
+		candidates := make([]utils.CLCandidate, len(matches))
+		for i, change := range matches {
+			candidates[i] = utils.CLCandidate{
+				Number:  change.Number,
+				Project: change.Project,
+				Branch:  change.Branch,
+				Status:  change.Status,
+				Subject: change.Subject,
+			}
+		}
+		return gerrit.ChangeInfo{}, utils.CLNotUnique(clID, candidates, instanceURL)
+	}
 	change := (*clList)[0]
 	log.Debugf("Found CL: %+v", change)
 	if change.Submitted == nil {
@@ -207,49 +760,62 @@ func queryCL(client *gerrit.Client, clID, instanceURL string) (gerrit.ChangeInfo
 	return change, nil
 }
 
-func getCLData(clID, instanceURL string, httpClient *http.Client) (*clData, utils.ChangelogError) {
-	log.Debugf("Retrieving CL data from Gerrit for changeID: %s", clID)
-	gerritClient, clientErr := gerrit.NewClient(instanceURL, httpClient)
-	if clientErr != nil {
-		log.Errorf("failed to establish Gerrit client for host %s:\n%v", instanceURL, clientErr)
-		return nil, utils.InternalServerError
+// subjectMatches returns the changes whose subject contains subject,
+// ignoring case and repeated spaces. The message search of Gerrit also
+// matches the body of commit messages.
+func subjectMatches(changes []gerrit.ChangeInfo, subject string) *[]gerrit.ChangeInfo {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
 	}
-	change, err := queryCL(gerritClient, clID, instanceURL)
-	if err != nil {
-		return nil, err
-	}
-	log.Debugf("Target CL found with SHA %s on repo %s, branch %s", change.CurrentRevision, change.Project, change.Branch)
-	// If a repository has non-conventional branch names, need to convert the
-	// repository branch name to a release branch name
-	release := change.Branch
-	if rule, ok := clReleaseMapping[change.Project]; ok {
-		if matches := rule.releaseRe.FindStringSubmatch(release); matches != nil {
-			release = matches[1]
-		} else {
-			release = rule.defaultRelease
+	subject = normalize(subject)
+	output := []gerrit.ChangeInfo{}
+	for _, change := range changes {
+		if strings.Contains(normalize(change.Subject), subject) {
+			output = append(output, change)
 		}
 	}
-	// In case the branch associated with the change is "main", branch
-	// on the manifest-snapshot will be master.
-	if change.Branch == "main" {
-		release = "master"
+	return &output
+}
+
+// branchMatches returns the changes on branch, which may be given with or
+// without the refs/heads/ prefix.
+func branchMatches(changes []gerrit.ChangeInfo, branch string) []gerrit.ChangeInfo {
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	var output []gerrit.ChangeInfo
+	for _, change := range changes {
+		if change.Branch == branch {
+			output = append(output, change)
+		}
 	}
-	// Strip chromium prefixes
-	project := change.Project
-	if matches := crosRepoRe.FindStringSubmatch(project); matches != nil {
-		project = matches[1]
+	return output
+}
+
+func getCLData(ctx context.Context, gerritClient GerritService, clID, branch, instanceURL string, mapping *clMapping) (*clData, utils.ChangelogError) {
+	log.Debugf("Retrieving CL data from Gerrit for changeID: %s", clID)
+	change, err := queryCL(ctx, gerritClient, clID, branch, instanceURL)
+	if err != nil {
+		return nil, err
 	}
+	return newCLData(change, instanceURL, mapping), nil
+}
+
+// newCLData returns the search data of a submitted change.
+func newCLData(change gerrit.ChangeInfo, instanceURL string, mapping *clMapping) *clData {
+	log.Debugf("Target CL found with SHA %s on repo %s, branch %s", change.CurrentRevision, change.Project, change.Branch)
+	release := mapping.release(instanceURL, change.Project, change.Branch)
+	project := mapping.repo(change.Project)
 	submittedTime := *change.Submitted
 	return &clData{
 		CLNum:            strconv.Itoa(change.Number),
+		ChangeID:         change.ChangeID,
 		InstanceURL:      instanceURL,
 		Project:          project,
 		Release:          release,
 		Branch:           change.Branch,
 		Revision:         change.CurrentRevision,
 		SearchStartRange: submittedTime.Time,
-		SearchEndRange:   submittedTime.Time.AddDate(0, 0, defaultSearchRange),
-	}, nil
+		SearchEndRange:   submittedTime.Time.Add(defaultSearchRange),
+	}
 }
 
 // candidateManifestCommits returns a list of commits to the manifest-snapshot
@@ -301,9 +867,9 @@ func candidateManifestCommits(manifestCommits []*git.Commit, clData *clData) ([]
 }
 
 // repoTags retrieves all tags belonging to a repository
-func repoTags(client *gerrit.Client, repo string) (map[string]string, error) {
+func repoTags(client GerritService, repo string) (map[string]string, error) {
 	log.Debugf("Retrieving tags for repository %s", repo)
-	tagInfos, _, err := client.Projects.ListTags(repo, &gerrit.ProjectBaseOptions{})
+	tagInfos, _, err := client.ListTags(repo, &gerrit.ProjectBaseOptions{})
 	if err != nil {
 		log.Errorf("error retrieving tags:\n%v", err)
 		return nil, err
@@ -350,22 +916,22 @@ func candidateBuildNums(manifestCommits []*git.Commit, tags map[string]string) (
 
 // manifestData retrieves the commit SHA and remote URL used in a particular build
 // for the same repository and branch as the target CL.
-func manifestData(client gitilesProto.GitilesClient, manifestRepo string, buildNum string, clData *clData, out chan manifestResponse, wg *sync.WaitGroup) {
+func manifestData(ctx context.Context, client utils.GitilesService, manifests *manifestCache, manifestRepo string, buildNum string, clData *clData, out chan manifestResponse, wg *sync.WaitGroup) {
 	defer wg.Done()
-	response, err := utils.DownloadManifest(client, manifestRepo, buildNum)
+	contents, err := manifests.download(ctx, client, manifestRepo, buildNum)
 	log.Debugf("Parsing manifest for build %s", buildNum)
 	if err != nil {
 		out <- manifestResponse{Err: err}
 		return
 	}
-	if response.Contents == "" {
+	if contents == "" {
 		// If an empty manifest file is encountered, an empty string SHA is
 		// inserted to instruct findBuild to retrieve a complete repo changelog
 		out <- manifestResponse{BuildNum: buildNum, SHA: ""}
 		return
 	}
 	doc := etree.NewDocument()
-	if err := doc.ReadFromString(response.Contents); err != nil {
+	if err := doc.ReadFromString(contents); err != nil {
 		out <- manifestResponse{Err: err}
 		return
 	}
@@ -384,6 +950,9 @@ func manifestData(client gitilesProto.GitilesClient, manifestRepo string, buildN
 	// Some projects do not have a "remote" attribute.
 	// If this is the case, they should use the default remoteURL.
 	output := manifestResponse{BuildNum: buildNum}
+	// The manifests of a search window are parsed concurrently, so the
+	// repository found is only recorded in clData by getRepoData
+	clProject := clData.Project
 	for _, project := range root.SelectElements("project") {
 		repo := project.SelectAttr("name").Value
 		branch := project.SelectAttrValue("upstream", "")
@@ -394,8 +963,8 @@ func manifestData(client gitilesProto.GitilesClient, manifestRepo string, buildN
 		if len(branch) > 0 {
 			branch = branch[11:]
 		}
-		if strings.Contains(repo, clData.Project) && (branch == "" || branch == clData.Branch) {
-			clData.Project = repo
+		if strings.Contains(repo, clProject) && (branch == "" || clData.Branch == "" || branch == clData.Branch) {
+			clProject = repo
 			output.SHA = project.SelectAttr("revision").Value
 			output.Repo = repo
 			output.RemoteURL = remoteMap[project.SelectAttrValue("remote", "")]
@@ -411,21 +980,25 @@ func manifestData(client gitilesProto.GitilesClient, manifestRepo string, buildN
 // getRepoData retrieves information about the repository being modified by the
 // CL. It retrieves candidate build numbers and their associated SHA, the
 // the first and last SHA in the repository changelog, and the remote URL.
-func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData *clData, buildNums []string) (*repoData, utils.ChangelogError) {
+func getRepoData(ctx context.Context, client utils.GitilesService, manifests *manifestCache, manifestRepo string, clData *clData, buildNums []string) (*repoData, utils.ChangelogError) {
 	log.Debug("Retrieving and parsing manifest file for each build")
 	buildOrder := map[string]int{}
 	for i, buildNum := range buildNums {
 		buildOrder[buildNum] = i * -1
 	}
 
-	output := repoData{Candidates: map[string]string{}}
+	output := repoData{Candidates: map[string]string{}, Revisions: map[string]string{}}
 	shaChan := make(chan manifestResponse, len(buildNums))
 	var wg sync.WaitGroup
 	wg.Add(len(buildNums))
 	for _, buildNum := range buildNums {
-		go manifestData(client, manifestRepo, buildNum, clData, shaChan, &wg)
+		go manifestData(ctx, client, manifests, manifestRepo, buildNum, clData, shaChan, &wg)
 	}
 	wg.Wait()
+	if ctx.Err() != nil {
+		log.Errorf("getRepoData: manifest downloads for CL %s interrupted: %v", clData.CLNum, ctx.Err())
+		return nil, utils.TimeoutError
+	}
 
 	sourceOrder, targetOrder := len(buildNums), len(buildNums)*-1
 	for i := 0; i < len(buildNums); i++ {
@@ -448,6 +1021,10 @@ func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData
 			output.SourceSHA = curr.SHA
 			sourceOrder = buildOrder[curr.BuildNum]
 		}
+		output.Revisions[curr.BuildNum] = curr.SHA
+		if curr.SHA == "" {
+			continue
+		}
 		if storedBuild, ok := output.Candidates[curr.SHA]; !ok || buildOrder[curr.BuildNum] < buildOrder[storedBuild] {
 			output.Candidates[curr.SHA] = curr.BuildNum
 		}
@@ -459,162 +1036,488 @@ func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData
 	return &output, nil
 }
 
-// firstBuild retrieves the earliest build containing the target CL from a map
-// of candidate builds.
-func firstBuild(changelog []*git.Commit, clData *clData, candidates map[string]string) (string, utils.ChangelogError) {
-	log.Debug("Scanning changelog for first build")
-	targetIdx := -1
-	for i, commit := range changelog {
-		if commit.Id == clData.Revision {
-			targetIdx = i
+// orderedCandidates returns the SHAs of candidates, a map of SHA to build
+// number, from the earliest build to the latest. buildNums is in reverse
+// chronological order.
+func orderedCandidates(candidates map[string]string, buildNums []string) []string {
+	buildOrder := make(map[string]int, len(buildNums))
+	for i, buildNum := range buildNums {
+		buildOrder[buildNum] = i
+	}
+	output := make([]string, 0, len(candidates))
+	for sha := range candidates {
+		output = append(output, sha)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return buildOrder[candidates[output[i]]] > buildOrder[candidates[output[j]]]
+	})
+	return output
+}
+
+// checkEndpoints checks the ancestry of the CL in the latest and earliest
+// builds of buildNums, in reverse chronological order, while the other
+// manifest files are downloaded and parsed, since the binary search of
+// firstBuild starts with them. Returns the channel receiving the results,
+// keyed by SHA, once both checks are done.
+//
+// The checks are speculative: builds whose manifest does not list the CL's
+// repository, or uses another remote than the GoB instance of the request,
+// and failed checks are skipped and left to firstBuild.
+func checkEndpoints(ctx context.Context, request *BuildRequest, cache *iterCache, clData clData, buildNums []string) <-chan map[string]bool {
+	out := make(chan map[string]bool, 1)
+	endpoints := []string{buildNums[0]}
+	if len(buildNums) > 1 {
+		endpoints = append(endpoints, buildNums[len(buildNums)-1])
+	}
+	go func() {
+		var mu sync.Mutex
+		output := make(map[string]bool)
+		var wg sync.WaitGroup
+		for _, buildNum := range endpoints {
+			buildNum := buildNum
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				manifests := make(chan manifestResponse, 1)
+				var manifestWg sync.WaitGroup
+				manifestWg.Add(1)
+				manifestData(ctx, cache.GitilesClient, cache.Manifests, request.ManifestRepo, buildNum, &clData, manifests, &manifestWg)
+				manifest := <-manifests
+				if manifest.Err != nil || manifest.SHA == "" || manifest.RemoteURL != request.GitilesHost {
+					return
+				}
+				ok, err := utils.IsAncestor(ctx, cache.GitilesClient, manifest.Repo, clData.Revision, manifest.SHA)
+				if err != nil {
+					log.Debugf("checkEndpoints: ancestry check of CL %s in build %s failed: %v", clData.CLNum, buildNum, err)
+					return
+				}
+				mu.Lock()
+				output[manifest.SHA] = ok
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		out <- output
+	}()
+	return out
+}
+
+// firstBuild retrieves the earliest candidate build containing the target CL.
+// A build contains the CL if the CL's revision is an ancestor of the
+// revision of its repository in the build, which holds across merges without
+// retrieving the changelog of the repository.
+//
+// shas lists the revisions of the candidate builds from the earliest build to
+// the latest. A build is assumed to contain the CLs of the builds preceding
+// it, so the candidates are binary searched. If hasSource is set, the first
+// candidate is the build preceding the search window, and the CL is not
+// searched in it.
+//
+// The result of each ancestry check is recorded in checked, keyed by SHA,
+// unless it is nil. The SHAs already in checked are not checked again.
+func firstBuild(ctx context.Context, client utils.GitilesService, clData *clData, candidates map[string]string, shas []string, hasSource bool, checked map[string]bool) (string, utils.ChangelogError) {
+	log.Debugf("Checking the ancestry of CL %s in %d candidate builds", clData.CLNum, len(shas))
+	contains := func(sha string) (bool, utils.ChangelogError) {
+		if ok, found := checked[sha]; found {
+			return ok, nil
 		}
+		ok, err := utils.IsAncestor(ctx, client, clData.Project, clData.Revision, sha)
+		if err != nil {
+			log.Errorf("failed to check the ancestry of CL %s: %v", clData.CLNum, err)
+			if ctx.Err() != nil {
+				return false, utils.TimeoutError
+			}
+			if utils.GitilesErrCode(err) == "404" {
+				return false, utils.CLNotUsed(clData.CLNum, clData.Project, clData.Release, clData.InstanceURL)
+			}
+			return false, utils.InternalServerError
+		}
+		if checked != nil {
+			checked[sha] = ok
+		}
+		return ok, nil
+	}
+	low, high := 0, len(shas)-1
+	if hasSource {
+		low = 1
+	}
+	if low > high {
+		return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+	}
+	found, clErr := contains(shas[high])
+	if clErr != nil {
+		return "", clErr
 	}
-	if targetIdx == -1 {
+	if !found {
 		return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
 	}
-	for i := targetIdx; i >= 0; i-- {
-		currSHA := changelog[i].Id
-		if buildNum, ok := candidates[currSHA]; ok {
-			return buildNum, nil
+	if hasSource {
+		// A CL already in the build preceding the window landed before it
+		if found, clErr = contains(shas[0]); clErr != nil {
+			return "", clErr
+		} else if found {
+			return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
 		}
 	}
-	return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+	// shas[high] always contains the CL
+	for low < high {
+		mid := (low + high) / 2
+		if found, clErr = contains(shas[mid]); clErr != nil {
+			return "", clErr
+		}
+		if found {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return candidates[shas[high]], nil
 }
 
 // findBuildInRange searches for the first build containing a given CL in
 // Git on Borg within the specified start and end time range.
 //
-// Returns the build number if found, a bool indicating if the search range
-// can be further expanded, and an error.
-func findBuildInRange(request *BuildRequest, cache *iterCache, clData *clData) (string, bool, utils.ChangelogError) {
+// Returns the build and the candidate builds examined if found, a bool
+// indicating if the search range can be further expanded, and an error.
+func findBuildInRange(ctx context.Context, request *BuildRequest, cache *iterCache, clData *clData) (*rangeResult, bool, utils.ChangelogError) {
 	log.Debugf("Searching for first build containing CL from time %v to time %v", clData.SearchStartRange, clData.SearchEndRange)
 	var err error
 	manifestCommits, canExpand, utilErr := candidateManifestCommits(cache.ManifestCommits, clData)
 	if utilErr != nil {
-		return "", canExpand, utilErr
+		return nil, canExpand, utilErr
 	}
 	buildNums, utilErr := candidateBuildNums(manifestCommits, cache.Tags)
 	if err != nil {
-		return "", canExpand, utilErr
+		return nil, canExpand, utilErr
 	}
-	repoData, utilErr := getRepoData(cache.GitilesClient, request.ManifestRepo, clData, buildNums)
+	cache.Builds = buildNums
+	// The endpoint checks are abandoned if the search window fails
+	endpointCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// clData is copied since getRepoData updates it
+	endpoints := checkEndpoints(endpointCtx, request, cache, *clData, buildNums)
+	parseStart := time.Now()
+	repoData, utilErr := getRepoData(ctx, cache.GitilesClient, cache.Manifests, request.ManifestRepo, clData, buildNums)
+	cache.Timings.ManifestParsing += time.Since(parseStart)
 	if utilErr != nil {
-		return "", canExpand, utilErr
+		return nil, canExpand, utilErr
 	}
+	request.hooks().ManifestsParsed(clData.ID, len(buildNums))
 	if repoData.TargetSHA == "" {
-		return "", canExpand, utils.CLLandingNotFound(clData.CLNum, request.GerritHost)
+		return nil, canExpand, utils.CLLandingNotFound(clData.CLNum, request.GerritHost)
 	}
 	changelogClient := cache.GitilesClient
 	if repoData.RemoteURL != request.GitilesHost {
 		log.Debugf("Different remote URL used in build, setting remote URL to %s", repoData.RemoteURL)
-		changelogClient, err = gitilesApi.NewRESTClient(request.HTTPClient, repoData.RemoteURL, true)
+		changelogClient, err = request.gitilesClient(repoData.RemoteURL)
 		if err != nil {
 			log.Errorf("failed to establish Gitiles client for remote URL %s", repoData.RemoteURL)
-			return "", false, utils.InternalServerError
+			return nil, false, utils.InternalServerError
 		}
 	}
-	querySize := -1
-	if repoData.SourceSHA == "" {
-		querySize = noSourceChangelogSize
+	shas := orderedCandidates(repoData.Candidates, buildNums)
+	changelogStart := time.Now()
+	checked := <-endpoints
+	buildNum, utilErr := firstBuild(ctx, changelogClient, clData, repoData.Candidates, shas, repoData.SourceSHA != "", checked)
+	cache.Timings.Changelog += time.Since(changelogStart)
+	if utilErr == utils.TimeoutError {
+		return nil, false, utilErr
+	}
+	if utilErr != nil {
+		return nil, canExpand, utilErr
 	}
-	changelog, _, err := utils.Commits(changelogClient, clData.Project, repoData.TargetSHA, repoData.SourceSHA, querySize)
+	candidates := examinedCandidates(buildNums, repoData.Revisions, checked)
+	return &rangeResult{
+		BuildNum:   buildNum,
+		Candidates: candidates,
+		Notes:      ambiguityNotes(candidates, buildNum, clData),
+	}, canExpand, nil
+}
+
+// manifestHistory returns the manifest commits of the CL's release branch,
+// newest first, and the commit SHA each manifest tag points to.
+func (s *buildSearch) manifestHistory(clData *clData) ([]*git.Commit, map[string]string, utils.ChangelogError) {
+	manifestCommits, err := s.manifestCommits(clData.Release)
 	if err != nil {
-		log.Errorf("failed to retrieve changelog: %v", err)
-		if utils.GitilesErrCode(err) == "404" {
-			return "", canExpand, utils.CLNotUsed(clData.CLNum, clData.Project, clData.Release, clData.InstanceURL)
+		log.Errorf("error retrieving manifest commits within CL submission range: %v", err)
+		if s.ctx.Err() != nil {
+			return nil, nil, utils.TimeoutError
+		}
+		httpCode := utils.GitilesErrCode(err)
+		if httpCode == "404" {
+			return nil, nil, utils.CLInvalidRelease(clData.CLNum, clData.Release, clData.InstanceURL)
 		}
-		return "", canExpand, utils.InternalServerError
+		return nil, nil, utils.InternalServerError
 	}
-	buildNum, utilErr := firstBuild(changelog, clData, repoData.Candidates)
-	if utilErr != nil {
-		return "", canExpand, utilErr
+	tags, clErr := s.manifestTags()
+	if clErr != nil {
+		return nil, nil, clErr
 	}
-	return buildNum, canExpand, nil
+	return manifestCommits, tags, nil
 }
 
 // findBuildExponential searches for the first build containing a CL in an
-// exponentially increasing time range.
-func findBuildExponential(gitilesClient gitiles.GitilesClient, request *BuildRequest, clData *clData) (string, utils.ChangelogError) {
+// exponentially increasing time range. The search window is multiplied by
+// searchRangeMultiplier each time the CL is not found, ex. 5, 15 then 45 days.
+//
+// Returns the build and the search window in which it was found.
+func findBuildExponential(search *buildSearch, clData *clData) (*rangeResult, time.Duration, utils.ChangelogError) {
 	log.Debug("Searching for first build in exponentially increasing time range")
-	timeRange := defaultSearchRange
+	initialWindow := search.request.searchWindow()
+	clData.SearchEndRange = clData.SearchStartRange.Add(initialWindow)
 
 	// Manifest commits and tags only need to be retrieved once and can be
 	// reused for each iteration.
-	manifestCommits, _, err := utils.Commits(gitilesClient, request.ManifestRepo, "refs/heads/"+clData.Release, "", -1)
-	if err != nil {
-		log.Errorf("error retrieving manifest commits within CL submission range: %v", err)
-		httpCode := utils.GitilesErrCode(err)
-		if httpCode == "404" {
-			return "", utils.CLInvalidRelease(clData.CLNum, clData.Release, clData.InstanceURL)
-		}
-		return "", utils.InternalServerError
+	timings := &Timings{}
+	historyStart := time.Now()
+	finish := startStage(search.request.hooks(), clData.ID, ManifestHistoryStage)
+	manifestCommits, tags, utilErr := search.manifestHistory(clData)
+	finish(utilErr)
+	timings.ManifestHistory = time.Since(historyStart)
+	if utilErr != nil {
+		return nil, 0, noBuildFound(search.request, clData, nil, utilErr)
 	}
 	if manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().After(clData.SearchEndRange) {
 		clData.SearchStartRange = manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().Add(-time.Second)
-		clData.SearchEndRange = clData.SearchStartRange.AddDate(0, 0, defaultSearchRange)
+		clData.SearchEndRange = clData.SearchStartRange.Add(initialWindow)
 		log.Debugf("CL submitted earlier than first build, set search range to starting time from %v to %v", clData.SearchStartRange, clData.SearchEndRange)
 	}
-	// Creating a Gerrit client based on manifest-snapshot repository.
-	// The client will be used for finding information associated with
-	// an annotated git tag.
-	instanceURL, err := utils.CreateGerritURL(request.GitilesHost)
-	if err != nil {
-		log.Errorf("failed to create Gerrit URL from Gitiles Host %q: %v", request.GitilesHost, err)
-		return "", utils.InternalServerError
-	}
-	gerritClient, err := gerrit.NewClient(instanceURL, request.HTTPClient)
-	if err != nil {
-		log.Errorf("failed to establish Gerrit client for host %s:\n%v", instanceURL, err)
-		return "", utils.InternalServerError
-	}
-	tagResp, err := repoTags(gerritClient, request.ManifestRepo)
-	if err != nil {
-		log.Errorf("failed to retrieve tags for project %s:\n%v", request.ManifestRepo, err)
-		return "", utils.InternalServerError
-	}
 	cache := &iterCache{
-		GitilesClient:   gitilesClient,
-		Tags:            tagResp,
+		GitilesClient:   search.gitilesClient,
+		Tags:            tags,
 		ManifestCommits: manifestCommits,
+		Manifests:       search.manifests,
+		Timings:         timings,
 	}
 
-	res, canExpand, utilErr := findBuildInRange(request, cache, clData)
+	// The window never expands past MaxSearchWindow after the start of the
+	// first window
+	start := clData.SearchStartRange
+	var maxEnd time.Time
+	if search.request.MaxSearchWindow > 0 {
+		maxEnd = start.Add(search.request.MaxSearchWindow)
+	}
+	searchRange := func() (*rangeResult, bool, utils.ChangelogError) {
+		finish := startStage(search.request.hooks(), clData.ID, SearchWindowStage)
+		res, canExpand, utilErr := findBuildInRange(search.ctx, search.request, cache, clData)
+		finish(utilErr)
+		return res, canExpand, utilErr
+	}
+	window := initialWindow
+	res, canExpand, utilErr := searchRange()
 	for utilErr != nil && utilErr.Retryable() && canExpand {
-		timeRange *= searchRangeMultiplier
-		clData.SearchStartRange = clData.SearchEndRange.AddDate(0, 0, -defaultSearchRange)
-		clData.SearchEndRange = clData.SearchEndRange.AddDate(0, 0, timeRange)
+		if !maxEnd.IsZero() && !clData.SearchEndRange.Before(maxEnd) {
+			log.Debugf("Could not locate CL before the maximum search window ending at %v", maxEnd)
+			break
+		}
+		window *= searchRangeMultiplier
+		// Builds before the end of the previous window were already searched
+		clData.SearchStartRange = clData.SearchEndRange.Add(-initialWindow)
+		clData.SearchEndRange = start.Add(window)
+		if !maxEnd.IsZero() && clData.SearchEndRange.After(maxEnd) {
+			clData.SearchEndRange = maxEnd
+		}
 		log.Debugf("Could not locate CL in current time range, retrying with range %v to %v", clData.SearchStartRange, clData.SearchEndRange)
-		res, canExpand, utilErr = findBuildInRange(request, cache, clData)
+		res, canExpand, utilErr = searchRange()
+	}
+	if utilErr != nil {
+		return nil, 0, noBuildFound(search.request, clData, cache.Builds, utilErr)
+	}
+	res.Timings = *timings
+	return res, clData.SearchEndRange.Sub(start), nil
+}
+
+// noBuildFound describes where a CL was searched in clErr if it indicates
+// that no build contains the CL, so users can tell a CL that has not landed
+// yet apart from a CL searched on the wrong branch. builds lists the
+// candidate builds of the last search window, in reverse chronological order.
+// Other errors are returned as is.
+func noBuildFound(request *BuildRequest, clData *clData, builds []string, clErr utils.ChangelogError) utils.ChangelogError {
+	utilErr, ok := clErr.(*utils.UtilChangelogError)
+	if !ok || !errors.Is(utilErr, utils.ErrNoBuildFound) {
+		return clErr
+	}
+	if len(builds) > maxNearestBuilds {
+		builds = builds[:maxNearestBuilds]
 	}
-	return res, utilErr
+	return utils.NoBuildFound(utilErr, request.ManifestRepo, clData.Release, clData.Project, clData.Branch, builds)
 }
 
-// FindBuild locates the first build that a CL was introduced to.
-func FindBuild(request *BuildRequest) (*BuildResponse, utils.ChangelogError) {
+// FindBuild locates the first build that a CL was introduced to. The Gerrit
+// and Gitiles requests of the search are sent with ctx.
+func FindBuild(ctx context.Context, request *BuildRequest) (*BuildResponse, utils.ChangelogError) {
 	log.Debugf("Fetching first build for CL: %s", request.CL)
 	start := time.Now()
 	if request == nil {
 		log.Error("expected non-nil request")
 		return nil, utils.InternalServerError
 	}
-	gitilesClient, err := gitilesApi.NewRESTClient(request.HTTPClient, request.GitilesHost, true)
-	if err != nil {
-		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
-		return nil, utils.InternalServerError
+	searches, clErr := newRepoSearches(ctx, request)
+	if clErr != nil {
+		return nil, clErr
 	}
-	clData, clErr := getCLData(request.CL, request.GerritHost, request.HTTPClient)
+	res, clErr := findInRepos(searches, request.CL)
 	if clErr != nil {
 		return nil, clErr
 	}
-	buildNum, clErr := findBuildExponential(gitilesClient, request, clData)
+	res.Timings.Total = time.Since(start)
+	return res, nil
+}
+
+// find locates the first build that a CL was introduced to.
+func (s *buildSearch) find(cl string) (*BuildResponse, utils.ChangelogError) {
+	if s.ctx.Err() != nil {
+		return nil, utils.TimeoutError
+	}
+	hooks := s.request.hooks()
+	queryStart := time.Now()
+	finish := startStage(hooks, cl, QueryCLStage)
+	var clData *clData
+	var clErr utils.ChangelogError
+	if s.request.UpstreamKernel {
+		clData, clErr = getUpstreamCLData(s.ctx, s.gerritClient, cl, s.request.GerritHost, s.mapping)
+	} else {
+		clData, clErr = getCLData(s.ctx, s.gerritClient, cl, s.request.Branch, s.request.GerritHost, s.mapping)
+	}
+	finish(clErr)
+	queryTime := time.Since(queryStart)
 	if clErr != nil {
 		return nil, clErr
 	}
-	log.Debugf("Retrieved first build for CL: %s in %s\n", request.CL, time.Since(start))
-	return &BuildResponse{
-		BuildNum: buildNum,
-		CLNum:    clData.CLNum,
-	}, nil
+	clData.ID = cl
+	target := *clData
+	res, clErr := s.findOnBranch(clData)
+	if clErr != nil {
+		return nil, clErr
+	}
+	res.Timings.QueryCL = queryTime
+	if s.request.AllMilestones {
+		finish := startStage(hooks, cl, MilestonesStage)
+		res.Milestones, clErr = s.milestones(&target, res)
+		finish(clErr)
+		if clErr != nil {
+			return nil, clErr
+		}
+	}
+	if s.request.ReleaseChecker != nil {
+		s.checkReleased(cl, res)
+	}
+	return res, nil
+}
+
+// findOnBranch locates the first build of the CL's release branch that the
+// CL was introduced to.
+func (s *buildSearch) findOnBranch(clData *clData) (*BuildResponse, utils.ChangelogError) {
+	found, window, clErr := findBuildExponential(s, clData)
+	if clErr != nil {
+		return nil, clErr
+	}
+	log.Debugf("Found first build for CL %s on %s within a %v search window", clData.CLNum, clData.Release, window)
+	output := &BuildResponse{
+		BuildNum:     found.BuildNum,
+		CLNum:        clData.CLNum,
+		SearchWindow: window,
+		Release:      clData.Release,
+		ManifestRepo: s.request.ManifestRepo,
+		Candidates:   found.Candidates,
+		Notes:        found.Notes,
+		Timings:      found.Timings,
+	}
+	if s.request.DetectReverts {
+		if output.Revert, clErr = s.revert(clData); clErr != nil {
+			return nil, clErr
+		}
+	}
+	return output, nil
+}
+
+// newRepoSearches creates a buildSearch for each manifest repository of the
+// request, in priority order.
+func newRepoSearches(ctx context.Context, request *BuildRequest) ([]*buildSearch, utils.ChangelogError) {
+	repos := request.manifestRepos()
+	output := make([]*buildSearch, len(repos))
+	for i, repo := range repos {
+		repoRequest := *request
+		repoRequest.ManifestRepo = repo
+		repoRequest.ManifestRepos = nil
+		search, clErr := newBuildSearch(ctx, &repoRequest)
+		if clErr != nil {
+			return nil, clErr
+		}
+		output[i] = search
+	}
+	return output, nil
+}
+
+// findInRepos locates the first build that a CL was introduced to in the
+// first manifest repository of searches that has a build containing it.
+func findInRepos(searches []*buildSearch, cl string) (*BuildResponse, utils.ChangelogError) {
+	var clErr utils.ChangelogError
+	for i, search := range searches {
+		var res *BuildResponse
+		res, clErr = search.find(cl)
+		if !errors.Is(clErr, utils.ErrNoBuildFound) {
+			return res, clErr
+		}
+		if i < len(searches)-1 {
+			log.Debugf("No build of manifest repository %s contains CL %s, searching %s", search.request.ManifestRepo, cl, searches[i+1].request.ManifestRepo)
+		}
+	}
+	return nil, clErr
+}
+
+// CLBuild is the result of FindBuilds for a single CL
+type CLBuild struct {
+	// CL is the CL identifier, as listed in BuildRequest.CLs
+	CL string
+	// Build is the first build containing the CL, if it was found
+	Build *BuildResponse
+	// Err is the reason why the first build containing the CL was not found
+	Err utils.ChangelogError
+}
+
+// FindBuilds locates the first build that each CL of request.CLs was
+// introduced to. The manifest tags, manifest commits and manifest files
+// needed by several CLs are only retrieved once, which makes it much faster
+// than a FindBuild call per CL.
+//
+// Returns one CLBuild per CL in the order of request.CLs. A CL that cannot be
+// found does not fail the other CLs; an error is only returned if the search
+// cannot start.
+func FindBuilds(ctx context.Context, request *BuildRequest) ([]*CLBuild, utils.ChangelogError) {
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
+	log.Debugf("Fetching first builds for %d CLs", len(request.CLs))
+	start := time.Now()
+	searches, clErr := newRepoSearches(ctx, request)
+	if clErr != nil {
+		return nil, clErr
+	}
+	output := make([]*CLBuild, len(request.CLs))
+	sem := make(chan struct{}, maxConcurrentCLs)
+	var wg sync.WaitGroup
+	for i, cl := range request.CLs {
+		i, cl := i, cl
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			clStart := time.Now()
+			res, err := findInRepos(searches, cl)
+			if err == nil {
+				res.Timings.Total = time.Since(clStart)
+			}
+			output[i] = &CLBuild{CL: cl, Build: res, Err: err}
+		}()
+	}
+	wg.Wait()
+	log.Debugf("Retrieved first builds for %d CLs in %s\n", len(request.CLs), time.Since(start))
+	return output, nil
 }
 
 type secretBundle struct {
@@ -16,13 +16,26 @@ package findbuild
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.chromium.org/luci/common/proto/git"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
@@ -196,7 +209,7 @@ func TestFindCL(t *testing.T) {
 			ManifestRepo: test.ManifestRepo,
 			CL:           test.Change,
 		}
-		res, err := FindBuild(req)
+		res, err := FindBuild(context.Background(), req)
 		if err != nil && err.HTTPCode() != "404" && test.ShouldFallback {
 			t.Fatalf("test \"%s\" failed:\nexpected not found error, got %v", name, err)
 		}
@@ -208,7 +221,7 @@ func TestFindCL(t *testing.T) {
 				ManifestRepo: test.ManifestRepo,
 				CL:           test.Change,
 			}
-			res, err = FindBuild(fallbackReq)
+			res, err = FindBuild(context.Background(), fallbackReq)
 		}
 		switch {
 		case test.ExpectedError == "" && err != nil:
@@ -218,8 +231,847 @@ func TestFindCL(t *testing.T) {
 		case test.ExpectedError != "" && err != nil && test.ExpectedError != err.HTTPCode():
 			t.Fatalf("test \"%s\" failed:\nexpected error code %s, got error code %s", name, test.ExpectedError, err.HTTPCode())
 		case test.ExpectedError == "" && res.BuildNum != test.OutputBuildNum:
-			t.Fatalf("test \"%s\" failed:\nexpected output %s, got %s", name, test.OutputBuildNum, res)
+			t.Fatalf("test \"%s\" failed:\nexpected output %s, got %s", name, test.OutputBuildNum, res.BuildNum)
 		}
 		time.Sleep(time.Second * 5)
 	}
 }
+
+// overlaysHead is the full SHA of the last cos/overlays commit of
+// fakeServices, since only full SHAs are looked up as commits.
+const overlaysHead = "3333333333333333333333333333333333333333"
+
+// fakeBaseTime is the commit time of the first manifest snapshot of
+// fakeServices.
+var fakeBaseTime = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ignoreCandidates ignores the builds examined by a search and its timings
+// when comparing BuildResponses, for tests of the build found.
+var ignoreCandidates = cmpopts.IgnoreFields(BuildResponse{}, "Candidates", "Notes", "Timings")
+
+// countingGitiles counts the manifest files downloaded from a Gitiles
+// service.
+type countingGitiles struct {
+	utils.GitilesService
+
+	mu        sync.Mutex
+	downloads map[string]int
+}
+
+func (g *countingGitiles) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	g.mu.Lock()
+	g.downloads[in.Committish]++
+	g.mu.Unlock()
+	return g.GitilesService.DownloadFile(ctx, in, opts...)
+}
+
+func fakeSnapshot(overlaysSHA string) string {
+	return `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays" path="src/overlays" revision="` + overlaysSHA + `"/>
+</manifest>`
+}
+
+func committedAt(t time.Time) *git.Commit_User {
+	return &git.Commit_User{Time: timestamppb.New(t)}
+}
+
+// fakeServices returns fake Gerrit and Gitiles services where builds 1.0.0,
+// 2.0.0 and 3.0.0 are snapshotted a day apart on the master branch, with
+// cos/overlays at o1, o2 and overlaysHead. CL 101 submitted o2 and CL 102
+// submitted overlaysHead.
+func fakeServices() (*fakes.Gerrit, *fakes.Gitiles) {
+	g := fakes.NewGitiles()
+	g.Commits[externalManifestRepo] = []*git.Commit{
+		{Id: "m1", Committer: committedAt(fakeBaseTime)},
+		{Id: "m2", Parents: []string{"m1"}, Committer: committedAt(fakeBaseTime.AddDate(0, 0, 1))},
+		{Id: "m3", Parents: []string{"m2"}, Committer: committedAt(fakeBaseTime.AddDate(0, 0, 2))},
+	}
+	g.Refs[externalManifestRepo] = map[string]string{"refs/heads/master": "m3"}
+	g.Commits["cos/overlays"] = []*git.Commit{
+		{Id: "o1"},
+		{Id: "o2", Parents: []string{"o1"}},
+		{Id: overlaysHead, Parents: []string{"o2"}},
+	}
+	gr := fakes.NewGerrit()
+	for i, sha := range []string{"o1", "o2", overlaysHead} {
+		buildNum := fmt.Sprintf("%d.0.0", i+1)
+		manifestSHA := fmt.Sprintf("m%d", i+1)
+		g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = fakeSnapshot(sha)
+		gr.Tags[externalManifestRepo] = append(gr.Tags[externalManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + buildNum, Revision: manifestSHA})
+	}
+	for _, cl := range []struct {
+		number    int
+		sha       string
+		submitted time.Time
+	}{
+		{101, "o2", fakeBaseTime.Add(12 * time.Hour)},
+		{102, overlaysHead, fakeBaseTime.Add(36 * time.Hour)},
+	} {
+		gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+			Number:          cl.number,
+			ChangeID:        fmt.Sprintf("I%d", cl.number),
+			Project:         "cos/overlays",
+			Branch:          "master",
+			Status:          "MERGED",
+			CurrentRevision: cl.sha,
+			Submitted:       &gerrit.Timestamp{Time: cl.submitted},
+		})
+	}
+	return gr, g
+}
+
+// fakeRequest returns a request served by fake services.
+func fakeRequest(gr *fakes.Gerrit, gitiles utils.GitilesService) *BuildRequest {
+	return &BuildRequest{
+		GerritHost:    externalGerritURL,
+		GitilesHost:   externalGitilesURL,
+		ManifestRepo:  externalManifestRepo,
+		GitilesClient: func(string) (utils.GitilesService, error) { return gitiles, nil },
+		GerritClient:  func(string) (GerritService, error) { return gr, nil },
+	}
+}
+
+func TestFindBuildFake(t *testing.T) {
+	tests := map[string]struct {
+		cl            string
+		expected      *BuildResponse
+		expectedError string
+	}{
+		"CL Number": {
+			cl:       "101",
+			expected: &BuildResponse{BuildNum: "2.0.0", CLNum: "101", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Commit SHA": {
+			cl:       overlaysHead,
+			expected: &BuildResponse{BuildNum: "3.0.0", CLNum: "102", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Change-Id": {
+			cl:       "I102",
+			expected: &BuildResponse{BuildNum: "3.0.0", CLNum: "102", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Not Found": {
+			cl:            "999",
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res, ignoreCandidates); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindBuildNotUnique(t *testing.T) {
+	gr, g := fakeServices()
+	addReleaseBranches(gr, g)
+	req := fakeRequest(gr, g)
+	req.CL = "I102"
+	_, err := FindBuild(context.Background(), req)
+	var notUnique *utils.CLNotUniqueError
+	if !errors.As(err, &notUnique) {
+		t.Fatalf("expected a CLNotUniqueError, got %v", err)
+	}
+	if !errors.Is(err, utils.ErrCLNotUnique) || err.HTTPCode() != "409" {
+		t.Errorf("expected ErrCLNotUnique with code 409, got %v with code %s", err, err.HTTPCode())
+	}
+	expected := []utils.CLCandidate{
+		{Number: 102, Project: "cos/overlays", Branch: "master", Status: "MERGED"},
+		{Number: 202, Project: "cos/overlays", Branch: "release-R2", Status: "MERGED"},
+	}
+	if diff := cmp.Diff(expected, notUnique.Candidates); diff != "" {
+		t.Errorf("unexpected candidates, diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindBuildNoBuildFound(t *testing.T) {
+	tests := map[string]struct {
+		branch   string
+		sha      string
+		expected *utils.NoBuildFoundError
+	}{
+		"Not Landed": {
+			branch: "master",
+			sha:    "o4",
+			expected: &utils.NoBuildFoundError{
+				ManifestRepo:  externalManifestRepo,
+				Release:       "master",
+				Repo:          "cos/overlays",
+				Branch:        "master",
+				NearestBuilds: []string{"3.0.0", "2.0.0"},
+			},
+		},
+		"Unknown Release Branch": {
+			branch: "release-R9",
+			sha:    overlaysHead,
+			expected: &utils.NoBuildFoundError{
+				ManifestRepo: externalManifestRepo,
+				Release:      "release-R9",
+				Repo:         "cos/overlays",
+				Branch:       "release-R9",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			g.Commits["cos/overlays"] = append(g.Commits["cos/overlays"], &git.Commit{Id: "o4", Parents: []string{overlaysHead}})
+			gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+				Number:          103,
+				ChangeID:        "I103",
+				Project:         "cos/overlays",
+				Branch:          test.branch,
+				Status:          "MERGED",
+				CurrentRevision: test.sha,
+				Submitted:       &gerrit.Timestamp{Time: fakeBaseTime.Add(40 * time.Hour)},
+			})
+			req := fakeRequest(gr, g)
+			req.CL = "103"
+			_, err := FindBuild(context.Background(), req)
+			var noBuild *utils.NoBuildFoundError
+			if !errors.As(err, &noBuild) {
+				t.Fatalf("expected a NoBuildFoundError, got %v", err)
+			}
+			if !errors.Is(err, utils.ErrNoBuildFound) || err.HTTPCode() != "406" {
+				t.Errorf("expected ErrNoBuildFound with code 406, got %v with code %s", err, err.HTTPCode())
+			}
+			if diff := cmp.Diff(test.expected, noBuild, cmpopts.IgnoreFields(utils.NoBuildFoundError{}, "UtilChangelogError")); diff != "" {
+				t.Errorf("unexpected error, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindBuildBranch(t *testing.T) {
+	tests := map[string]struct {
+		branch        string
+		expected      string
+		expectedCL    string
+		expectedError string
+	}{
+		"Release Branch": {
+			branch:     "release-R2",
+			expected:   "2.1.0",
+			expectedCL: "202",
+		},
+		"Ref": {
+			branch:     "refs/heads/master",
+			expected:   "3.0.0",
+			expectedCL: "102",
+		},
+		"No CL On Branch": {
+			branch:        "release-R1",
+			expectedError: "409",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addReleaseBranches(gr, g)
+			req := fakeRequest(gr, g)
+			req.CL = "I102"
+			req.Branch = test.branch
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != test.expected || res.CLNum != test.expectedCL {
+				t.Errorf("expected build %s of CL %s, got build %s of CL %s", test.expected, test.expectedCL, res.BuildNum, res.CLNum)
+			}
+		})
+	}
+}
+
+func TestFindBuildSubject(t *testing.T) {
+	tests := map[string]struct {
+		cl                 string
+		expected           string
+		expectedCandidates []int
+		expectedError      string
+	}{
+		"Unique": {
+			cl:       "gpu  reset on RESUME",
+			expected: "2.0.0",
+		},
+		"Not Unique": {
+			cl:                 "Fix GPU reset",
+			expectedCandidates: []int{101, 102},
+			expectedError:      "409",
+		},
+		"Body Only": {
+			cl:            "the driver fix",
+			expectedError: "404",
+		},
+		"Not Found": {
+			cl:            "no such patch",
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			for i, subject := range []string{"Fix GPU reset on resume", "Fix GPU reset on boot"} {
+				gr.Changes[i].Subject = subject
+				gr.Changes[i].Revisions = map[string]gerrit.RevisionInfo{
+					gr.Changes[i].CurrentRevision: {Commit: gerrit.CommitInfo{Message: subject + "\n\nPicks up the driver fix.\n"}},
+				}
+			}
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				var notUnique *utils.CLNotUniqueError
+				if errors.As(err, &notUnique) {
+					var got []int
+					for _, candidate := range notUnique.Candidates {
+						got = append(got, candidate.Number)
+					}
+					if diff := cmp.Diff(test.expectedCandidates, got); diff != "" {
+						t.Errorf("unexpected candidates, diff (-want +got):\n%s", diff)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != test.expected {
+				t.Errorf("expected build %s, got %s", test.expected, res.BuildNum)
+			}
+			if queries := gr.Queries(); len(queries) == 0 || !strings.HasPrefix(queries[0], `message:"gpu reset on RESUME"`) {
+				t.Errorf("expected a message search, got queries %v", queries)
+			}
+		})
+	}
+}
+
+// periodicManifestRepo is a second manifest repository added by
+// addPeriodicBuilds.
+const periodicManifestRepo = "cos/periodic-snapshots"
+
+// addPeriodicBuilds adds builds 2.0.1 and 2.0.2 to periodicManifestRepo,
+// snapshotted 6 and 30 hours after the first build of fakeServices with
+// cos/overlays at o1 and o2.
+func addPeriodicBuilds(gr *fakes.Gerrit, g *fakes.Gitiles) {
+	g.Commits[periodicManifestRepo] = []*git.Commit{
+		{Id: "p1", Committer: committedAt(fakeBaseTime.Add(6 * time.Hour))},
+		{Id: "p2", Parents: []string{"p1"}, Committer: committedAt(fakeBaseTime.Add(30 * time.Hour))},
+	}
+	g.Refs[periodicManifestRepo] = map[string]string{"refs/heads/master": "p2"}
+	for i, sha := range []string{"o1", "o2"} {
+		buildNum := fmt.Sprintf("2.0.%d", i+1)
+		g.Files[fakes.GitilesFile{Project: periodicManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = fakeSnapshot(sha)
+		gr.Tags[periodicManifestRepo] = append(gr.Tags[periodicManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + buildNum, Revision: fmt.Sprintf("p%d", i+1)})
+	}
+}
+
+func TestFindBuildManifestRepos(t *testing.T) {
+	tests := map[string]struct {
+		cl            string
+		repos         []string
+		expected      *BuildResponse
+		expectedError string
+	}{
+		"First Repository": {
+			cl:       "101",
+			repos:    []string{periodicManifestRepo, externalManifestRepo},
+			expected: &BuildResponse{BuildNum: "2.0.2", CLNum: "101", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: periodicManifestRepo},
+		},
+		"Priority Order": {
+			cl:       "101",
+			repos:    []string{externalManifestRepo, periodicManifestRepo},
+			expected: &BuildResponse{BuildNum: "2.0.0", CLNum: "101", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Second Repository": {
+			cl:       "102",
+			repos:    []string{periodicManifestRepo, externalManifestRepo},
+			expected: &BuildResponse{BuildNum: "3.0.0", CLNum: "102", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"No Build Found": {
+			cl:            "102",
+			repos:         []string{periodicManifestRepo},
+			expectedError: "406",
+		},
+		"CL Not Found": {
+			cl:            "999",
+			repos:         []string{periodicManifestRepo, externalManifestRepo},
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addPeriodicBuilds(gr, g)
+			req := fakeRequest(gr, g)
+			req.ManifestRepos = test.repos
+			req.CL = test.cl
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res, ignoreCandidates); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// countingLogGitiles counts the Log requests sent to a Gitiles service.
+type countingLogGitiles struct {
+	utils.GitilesService
+	mu   sync.Mutex
+	logs int
+}
+
+func (g *countingLogGitiles) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	g.mu.Lock()
+	g.logs++
+	g.mu.Unlock()
+	return g.GitilesService.Log(ctx, in, opts...)
+}
+
+func TestFirstBuild(t *testing.T) {
+	// feature branches off base, and is merged into main after main1
+	g := fakes.NewGitiles()
+	g.Commits["cos/overlays"] = []*git.Commit{
+		{Id: "base"},
+		{Id: "main1", Parents: []string{"base"}},
+		{Id: "feature", Parents: []string{"base"}},
+		{Id: "merge", Parents: []string{"main1", "feature"}},
+		{Id: "main2", Parents: []string{"merge"}},
+	}
+	candidates := map[string]string{"base": "1.0.0", "main1": "2.0.0", "merge": "3.0.0", "main2": "4.0.0"}
+	shas := []string{"base", "main1", "merge", "main2"}
+	tests := map[string]struct {
+		revision      string
+		hasSource     bool
+		expected      string
+		expectedError string
+	}{
+		"Linear History": {
+			revision:  "main1",
+			hasSource: true,
+			expected:  "2.0.0",
+		},
+		"Merged Branch": {
+			revision:  "feature",
+			hasSource: true,
+			expected:  "3.0.0",
+		},
+		"Latest Build": {
+			revision:  "main2",
+			hasSource: true,
+			expected:  "4.0.0",
+		},
+		"Without Source": {
+			revision: "base",
+			expected: "1.0.0",
+		},
+		"Landed Before Window": {
+			revision:      "base",
+			hasSource:     true,
+			expectedError: utils.CLLandingNotFound("101", externalGerritURL).Error(),
+		},
+		"Unknown Revision": {
+			revision:      "missing",
+			hasSource:     true,
+			expectedError: utils.CLNotUsed("101", "cos/overlays", "master", externalGerritURL).Error(),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &countingLogGitiles{GitilesService: g}
+			data := &clData{CLNum: "101", InstanceURL: externalGerritURL, Project: "cos/overlays", Release: "master", Revision: test.revision}
+			got, err := firstBuild(context.Background(), client, data, candidates, shas, test.hasSource, nil)
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("expected error %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("firstBuild failed: %v", err)
+			}
+			if got != test.expected {
+				t.Errorf("expected build %s, got %s", test.expected, got)
+			}
+			if client.logs > 4 {
+				t.Errorf("expected at most 4 ancestry checks, got %d", client.logs)
+			}
+		})
+	}
+}
+
+func TestFindBuildMergedManifestBranch(t *testing.T) {
+	// A side branch of the manifest repository snapshotting o2 is merged
+	// into master by m3. Its build sorts between 1.0.0 and 2.0.0 but is not
+	// a build of the master branch.
+	gr, g := fakeServices()
+	g.Commits[externalManifestRepo] = []*git.Commit{
+		{Id: "m1", Committer: committedAt(fakeBaseTime)},
+		{Id: "side", Parents: []string{"m1"}, Committer: committedAt(fakeBaseTime.Add(18 * time.Hour))},
+		{Id: "m2", Parents: []string{"m1"}, Committer: committedAt(fakeBaseTime.AddDate(0, 0, 1))},
+		{Id: "m3", Parents: []string{"m2", "side"}, Committer: committedAt(fakeBaseTime.AddDate(0, 0, 2))},
+	}
+	g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/9.0.0", Path: "snapshot.xml"}] = fakeSnapshot("o2")
+	gr.Tags[externalManifestRepo] = append(gr.Tags[externalManifestRepo], gerrit.TagInfo{Ref: "refs/tags/9.0.0", Revision: "side"})
+	req := fakeRequest(gr, g)
+	req.CL = "101"
+	res, err := FindBuild(context.Background(), req)
+	if err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	if res.BuildNum != "2.0.0" {
+		t.Errorf("expected build 2.0.0, got %s", res.BuildNum)
+	}
+}
+
+func TestFindBuildEndpointChecks(t *testing.T) {
+	tests := map[string]struct {
+		cl       string
+		expected string
+	}{
+		"Middle Build": {cl: "101", expected: "2.0.0"},
+		"Latest Build": {cl: "102", expected: "3.0.0"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			client := &countingLogGitiles{GitilesService: g}
+			req := fakeRequest(gr, client)
+			req.CL = test.cl
+			res, err := FindBuild(context.Background(), req)
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != test.expected {
+				t.Errorf("expected build %s, got %s", test.expected, res.BuildNum)
+			}
+			// The latest and earliest builds are checked while the manifests
+			// are parsed, and not checked again by the binary search. The
+			// manifest commits are also listed.
+			if client.logs > 4 {
+				t.Errorf("expected at most 3 ancestry checks and a manifest commits request, got %d requests", client.logs)
+			}
+		})
+	}
+}
+
+func TestOrderedCandidates(t *testing.T) {
+	candidates := map[string]string{"o1": "1.0.0", "o2": "2.0.0", overlaysHead: "3.0.0"}
+	got := orderedCandidates(candidates, []string{"4.0.0", "3.0.0", "2.0.0", "1.0.0"})
+	if diff := cmp.Diff([]string{"o1", "o2", overlaysHead}, got); diff != "" {
+		t.Errorf("unexpected order, diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindBuilds(t *testing.T) {
+	gr, g := fakeServices()
+	gitiles := &countingGitiles{GitilesService: g, downloads: make(map[string]int)}
+	req := fakeRequest(gr, gitiles)
+	req.CLs = []string{"101", "999", "102"}
+	res, err := FindBuilds(context.Background(), req)
+	if err != nil {
+		t.Fatalf("FindBuilds failed: %v", err)
+	}
+	if len(res) != len(req.CLs) {
+		t.Fatalf("expected %d results, got %d", len(req.CLs), len(res))
+	}
+	for i, expected := range []string{"2.0.0", "", "3.0.0"} {
+		got := res[i]
+		if got.CL != req.CLs[i] {
+			t.Errorf("expected result %d to be for CL %s, got %s", i, req.CLs[i], got.CL)
+		}
+		if expected == "" {
+			if got.Err == nil || got.Err.HTTPCode() != "404" {
+				t.Errorf("expected CL %s not to be found, got %+v", got.CL, got)
+			}
+			continue
+		}
+		if got.Err != nil || got.Build.BuildNum != expected {
+			t.Errorf("expected CL %s in build %s, got %+v", got.CL, expected, got)
+		}
+	}
+	for ref, count := range gitiles.downloads {
+		if count != 1 {
+			t.Errorf("expected manifest %s to be downloaded once, got %d downloads", ref, count)
+		}
+	}
+}
+
+// cancellingGitiles cancels a context when a manifest file is downloaded.
+type cancellingGitiles struct {
+	utils.GitilesService
+	cancel context.CancelFunc
+}
+
+func (g *cancellingGitiles) DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	g.cancel()
+	return g.GitilesService.DownloadFile(ctx, in, opts...)
+}
+
+func TestFindBuildContext(t *testing.T) {
+	tests := map[string]struct {
+		cancelBefore bool
+	}{
+		"Cancelled Before Search": {cancelBefore: true},
+		"Cancelled During Search": {},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			gr, g := fakeServices()
+			req := fakeRequest(gr, &cancellingGitiles{GitilesService: g, cancel: cancel})
+			req.CL = "101"
+			req.CLs = []string{"101", "102"}
+			if test.cancelBefore {
+				cancel()
+			}
+			if _, err := FindBuild(ctx, req); err != utils.TimeoutError {
+				t.Errorf("FindBuild: expected timeout error, got %v", err)
+			}
+			res, err := FindBuilds(ctx, req)
+			if err != nil {
+				t.Fatalf("FindBuilds failed: %v", err)
+			}
+			for _, got := range res {
+				if got.Err != utils.TimeoutError {
+					t.Errorf("FindBuilds: expected timeout error for CL %s, got %+v", got.CL, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGerritClientContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ")]}'\n[]")
+	}))
+	defer server.Close()
+	req := &BuildRequest{HTTPClient: server.Client()}
+
+	client, err := req.gerritClient(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("gerritClient failed: %v", err)
+	}
+	if _, _, err := client.QueryChanges(&gerrit.QueryChangeOptions{}); err != nil {
+		t.Errorf("QueryChanges failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client, err = req.gerritClient(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("gerritClient failed: %v", err)
+	}
+	if _, _, err := client.QueryChanges(&gerrit.QueryChangeOptions{}); err == nil {
+		t.Error("expected QueryChanges to fail with a cancelled context")
+	}
+}
+
+// tokenTransport authorizes requests with a bearer token.
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+func TestHostHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer host-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/+log/") {
+			fmt.Fprint(w, ")]}'\n{\"log\": []}")
+			return
+		}
+		fmt.Fprint(w, ")]}'\n[]")
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	hostClient := &http.Client{Transport: tokenTransport{token: "host-token", base: server.Client().Transport}}
+	tests := map[string]struct {
+		hostClients map[string]*http.Client
+		expectErr   bool
+	}{
+		"Host Client": {
+			hostClients: map[string]*http.Client{host: hostClient},
+		},
+		"Other Host": {
+			hostClients: map[string]*http.Client{"cos.googlesource.com": hostClient},
+			expectErr:   true,
+		},
+		"Default Client": {
+			expectErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &BuildRequest{HTTPClient: server.Client(), RetryPolicy: &utils.RetryPolicy{MaxAttempts: 1}}
+			if test.hostClients != nil {
+				req.HostHTTPClient = HostHTTPClients(test.hostClients)
+			}
+			gerritClient, err := req.gerritClient(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("gerritClient failed: %v", err)
+			}
+			if _, _, err := gerritClient.QueryChanges(&gerrit.QueryChangeOptions{}); (err != nil) != test.expectErr {
+				t.Errorf("QueryChanges: expected error %t, got %v", test.expectErr, err)
+			}
+			gitilesClient, err := req.gitilesClient(host)
+			if err != nil {
+				t.Fatalf("gitilesClient failed: %v", err)
+			}
+			if _, _, err := utils.Commits(context.Background(), gitilesClient, "cos/overlays", "master", "", 1); (err != nil) != test.expectErr {
+				t.Errorf("Commits: expected error %t, got %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+// addLateBuilds adds builds 4.0.0 and 5.0.0 to fakeServices six and eight
+// days after the first build, and build 6.0.0 after twenty days. Only build
+// 6.0.0 contains CL 103, which is submitted shortly after build 3.0.0.
+func addLateBuilds(gr *fakes.Gerrit, g *fakes.Gitiles) {
+	g.Commits["cos/overlays"] = append(g.Commits["cos/overlays"], &git.Commit{Id: "o4", Parents: []string{overlaysHead}})
+	parent := "m3"
+	for i, build := range []struct {
+		days        int
+		overlaysSHA string
+	}{{6, overlaysHead}, {8, overlaysHead}, {20, "o4"}} {
+		buildNum := fmt.Sprintf("%d.0.0", i+4)
+		manifestSHA := fmt.Sprintf("m%d", i+4)
+		g.Commits[externalManifestRepo] = append(g.Commits[externalManifestRepo],
+			&git.Commit{Id: manifestSHA, Parents: []string{parent}, Committer: committedAt(fakeBaseTime.AddDate(0, 0, build.days))})
+		g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = fakeSnapshot(build.overlaysSHA)
+		gr.Tags[externalManifestRepo] = append(gr.Tags[externalManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + buildNum, Revision: manifestSHA})
+		parent = manifestSHA
+	}
+	g.Refs[externalManifestRepo]["refs/heads/master"] = parent
+	gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+		Number:          103,
+		Project:         "cos/overlays",
+		Branch:          "master",
+		Status:          "MERGED",
+		CurrentRevision: "o4",
+		Submitted:       &gerrit.Timestamp{Time: fakeBaseTime.Add(49 * time.Hour)},
+	})
+}
+
+func TestFindBuildSearchWindow(t *testing.T) {
+	tests := map[string]struct {
+		window         time.Duration
+		maxWindow      time.Duration
+		expected       string
+		expectedWindow time.Duration
+		expectedError  string
+	}{
+		"Default Window Expands": {
+			expected:       "6.0.0",
+			expectedWindow: 15 * 24 * time.Hour,
+		},
+		"Wide Window": {
+			window:         10 * 24 * time.Hour,
+			expected:       "6.0.0",
+			expectedWindow: 10 * 24 * time.Hour,
+		},
+		"Capped Window": {
+			maxWindow:     3 * 24 * time.Hour,
+			expectedError: "406",
+		},
+		"Cap Above Build": {
+			maxWindow:      9 * 24 * time.Hour,
+			expected:       "6.0.0",
+			expectedWindow: 9 * 24 * time.Hour,
+		},
+		"Small Window Expands": {
+			window:         24 * time.Hour,
+			expected:       "6.0.0",
+			expectedWindow: 9 * 24 * time.Hour,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addLateBuilds(gr, g)
+			req := fakeRequest(gr, g)
+			req.CL = "103"
+			req.SearchWindow = test.window
+			req.MaxSearchWindow = test.maxWindow
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != test.expected {
+				t.Errorf("expected build %s, got %s", test.expected, res.BuildNum)
+			}
+			if res.SearchWindow != test.expectedWindow {
+				t.Errorf("expected search window %v, got %v", test.expectedWindow, res.SearchWindow)
+			}
+		})
+	}
+}
+
+func TestSearchWindow(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := map[string]struct {
+		req      *BuildRequest
+		expected time.Duration
+	}{
+		"Default":        {req: &BuildRequest{}, expected: 5 * day},
+		"Custom":         {req: &BuildRequest{SearchWindow: 2 * day}, expected: 2 * day},
+		"Capped":         {req: &BuildRequest{SearchWindow: 20 * day, MaxSearchWindow: 10 * day}, expected: 10 * day},
+		"Capped Default": {req: &BuildRequest{MaxSearchWindow: day}, expected: day},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.req.searchWindow(); got != test.expected {
+				t.Errorf("expected search window %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// Stages of the search of a CL reported to Hooks
+const (
+	// QueryCLStage retrieves the CL from Gerrit
+	QueryCLStage = "query_cl"
+	// ManifestHistoryStage retrieves the manifest commits of the CL's release
+	// branch and the manifest tags
+	ManifestHistoryStage = "manifest_history"
+	// SearchWindowStage searches the builds of a search window. It is
+	// repeated for every window searched, and for every release branch with
+	// BuildRequest.AllMilestones.
+	SearchWindowStage = "search_window"
+	// MilestonesStage searches the release branches with
+	// BuildRequest.AllMilestones
+	MilestonesStage = "milestones"
+	// RevertStage retrieves the revert of a CL with
+	// BuildRequest.DetectReverts. The search of the build containing the
+	// revert is reported as a SearchWindowStage.
+	RevertStage = "revert"
+	// ReleaseCheckStage checks whether the builds found were released with
+	// BuildRequest.ReleaseChecker
+	ReleaseCheckStage = "release_check"
+)
+
+// Timings is the time spent in each stage of the search of a CL, reported
+// in BuildResponse so callers can surface why a search was slow. The stages
+// repeated for every search window are summed.
+type Timings struct {
+	// QueryCL is the time spent retrieving the CL from Gerrit.
+	QueryCL time.Duration
+	// ManifestHistory is the time spent listing the manifest commits of the
+	// CL's release branch and the manifest tags.
+	ManifestHistory time.Duration
+	// ManifestParsing is the time spent retrieving and parsing the manifest
+	// files of the candidate builds.
+	ManifestParsing time.Duration
+	// Changelog is the time spent walking the changelog of the CL's
+	// repository to find the first candidate build containing it.
+	Changelog time.Duration
+	// Total is the time spent searching the CL, including the stages
+	// above and the search of the other release branches with
+	// BuildRequest.AllMilestones.
+	Total time.Duration
+}
+
+// Hooks receives the progress of FindBuild and FindBuilds searches, so a
+// serving frontend can display the progress of long searches and record
+// their latency breakdown. CLs are identified as given in BuildRequest.CL or
+// BuildRequest.CLs. Its methods are called concurrently.
+type Hooks interface {
+	// StageStarted is called when a stage of the search of a CL starts.
+	StageStarted(cl, stage string)
+	// StageFinished is called when a stage of the search of a CL finishes,
+	// with its duration and the error it failed with, if any.
+	StageFinished(cl, stage string, elapsed time.Duration, err utils.ChangelogError)
+	// ManifestsParsed is called after the manifest files of the builds of a
+	// search window are parsed, with the number of files.
+	ManifestsParsed(cl string, count int)
+}
+
+// noopHooks is the Hooks used when BuildRequest.Hooks is not set
+type noopHooks struct{}
+
+func (noopHooks) StageStarted(string, string)                                       {}
+func (noopHooks) StageFinished(string, string, time.Duration, utils.ChangelogError) {}
+func (noopHooks) ManifestsParsed(string, int)                                       {}
+
+// hooks returns the Hooks of the request.
+func (r *BuildRequest) hooks() Hooks {
+	if r.Hooks == nil {
+		return noopHooks{}
+	}
+	return r.Hooks
+}
+
+// startStage reports the start of a stage of the search of a CL to hooks,
+// and returns the function reporting its end.
+func startStage(hooks Hooks, cl, stage string) func(err utils.ChangelogError) {
+	hooks.StageStarted(cl, stage)
+	start := time.Now()
+	return func(err utils.ChangelogError) {
+		hooks.StageFinished(cl, stage, time.Since(start), err)
+	}
+}
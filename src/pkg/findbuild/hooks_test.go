@@ -0,0 +1,147 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingHooks records the events reported to Hooks as strings.
+type recordingHooks struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHooks) record(format string, args ...interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+}
+
+func (h *recordingHooks) StageStarted(cl, stage string) {
+	h.record("%s: start %s", cl, stage)
+}
+
+func (h *recordingHooks) StageFinished(cl, stage string, elapsed time.Duration, err utils.ChangelogError) {
+	if elapsed < 0 {
+		h.record("%s: negative duration for %s", cl, stage)
+	}
+	if err != nil {
+		h.record("%s: fail %s with %s", cl, stage, err.HTTPCode())
+		return
+	}
+	h.record("%s: finish %s", cl, stage)
+}
+
+func (h *recordingHooks) ManifestsParsed(cl string, count int) {
+	h.record("%s: parsed %d manifests", cl, count)
+}
+
+func TestFindBuildHooks(t *testing.T) {
+	tests := map[string]struct {
+		cl       string
+		expected []string
+	}{
+		"Found": {
+			cl: "I101",
+			expected: []string{
+				"I101: start query_cl",
+				"I101: finish query_cl",
+				"I101: start manifest_history",
+				"I101: finish manifest_history",
+				"I101: start search_window",
+				"I101: parsed 3 manifests",
+				"I101: finish search_window",
+			},
+		},
+		"Not Found": {
+			cl: "999",
+			expected: []string{
+				"999: start query_cl",
+				"999: fail query_cl with 404",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			hooks := &recordingHooks{}
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			req.Hooks = hooks
+			FindBuild(context.Background(), req)
+			if diff := cmp.Diff(test.expected, hooks.events); diff != "" {
+				t.Errorf("unexpected events, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindBuildHooksMilestones(t *testing.T) {
+	gr, g := fakeServices()
+	addReleaseBranches(gr, g)
+	hooks := &recordingHooks{}
+	req := fakeRequest(gr, g)
+	req.CL = "102"
+	req.AllMilestones = true
+	req.Hooks = hooks
+	if _, err := FindBuild(context.Background(), req); err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, event := range hooks.events {
+		counts[event]++
+	}
+	// The master branch and both release branches are searched, and
+	// release-R1 does not contain the CL
+	expected := map[string]int{
+		"102: start query_cl":              1,
+		"102: finish query_cl":             1,
+		"102: start manifest_history":      3,
+		"102: finish manifest_history":     3,
+		"102: start search_window":         3,
+		"102: finish search_window":        2,
+		"102: fail search_window with 406": 1,
+		"102: start milestones":            1,
+		"102: finish milestones":           1,
+	}
+	for event, count := range expected {
+		if counts[event] != count {
+			t.Errorf("expected event %q %d times, got %d in %v", event, count, counts[event], hooks.events)
+		}
+	}
+}
+
+func TestFindBuildTimings(t *testing.T) {
+	gr, g := fakeServices()
+	req := fakeRequest(gr, g)
+	req.CL = "101"
+	res, err := FindBuild(context.Background(), req)
+	if err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	timings := res.Timings
+	stages := timings.QueryCL + timings.ManifestHistory + timings.ManifestParsing + timings.Changelog
+	if timings.Total <= 0 || stages > timings.Total {
+		t.Errorf("expected the stages to take at most the total time, got %+v", timings)
+	}
+}
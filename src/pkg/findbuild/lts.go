@@ -0,0 +1,104 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// LTSBuild is the first build containing a CL, or its cherry-pick, on the
+// release branch of an LTS milestone.
+type LTSBuild struct {
+	// Milestone is the LTS milestone, ex. 93
+	Milestone int
+	// Release is the release branch of the milestone, ex. "release-R93"
+	Release string
+	// Landed indicates that a build of Release contains the CL
+	Landed bool
+	// BuildNum is the first build of Release containing the CL, if it landed
+	BuildNum string `json:",omitempty"`
+	// CLNum is the number of the CL found in BuildNum, which differs from
+	// the searched CL if it is a cherry-pick
+	CLNum string `json:",omitempty"`
+}
+
+// LTSReport lists the first build containing a CL on each LTS milestone.
+type LTSReport struct {
+	// CL is the CL identifier, as listed in BuildRequest.CLs
+	CL string
+	// Builds lists one LTSBuild per milestone, in the requested order. It is
+	// empty if Err is set.
+	Builds []*LTSBuild
+	// Err is the reason why the CL could not be searched on the milestones
+	Err utils.ChangelogError
+}
+
+// LTSBuilds converts the result of a search with BuildRequest.AllMilestones
+// to one LTSBuild per milestone of milestones. Milestones absent from
+// build.Milestones are reported as not landed.
+func LTSBuilds(build *BuildResponse, milestones []int) []*LTSBuild {
+	found := make(map[string]*BuildResponse)
+	for _, res := range build.Milestones {
+		found[res.Release] = res
+	}
+	output := make([]*LTSBuild, len(milestones))
+	for i, milestone := range milestones {
+		ltsBuild := &LTSBuild{Milestone: milestone, Release: fmt.Sprintf("release-R%d", milestone)}
+		if res, ok := found[ltsBuild.Release]; ok {
+			ltsBuild.Landed = true
+			ltsBuild.BuildNum = res.BuildNum
+			ltsBuild.CLNum = res.CLNum
+		}
+		output[i] = ltsBuild
+	}
+	return output
+}
+
+// FindLTSBuilds reports the first build containing each CL of request.CLs on
+// the release branch of each milestone of milestones, ex. the active LTS
+// milestones, or that the CL has not landed on it yet. Only the release
+// branches of milestones are searched.
+//
+// Returns one LTSReport per CL in the order of request.CLs. A CL that is not
+// found on its own branch is reported with an error, since its cherry-picks
+// are not searched.
+func FindLTSBuilds(ctx context.Context, request *BuildRequest, milestones []int) ([]*LTSReport, utils.ChangelogError) {
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
+	if len(milestones) == 0 {
+		log.Error("FindLTSBuilds: expected at least one milestone")
+		return nil, utils.InternalServerError
+	}
+	ltsRequest := *request
+	ltsRequest.AllMilestones = true
+	ltsRequest.Milestones = milestones
+	builds, clErr := FindBuilds(ctx, &ltsRequest)
+	if clErr != nil {
+		return nil, clErr
+	}
+	output := make([]*LTSReport, len(builds))
+	for i, build := range builds {
+		output[i] = &LTSReport{CL: build.CL, Err: build.Err}
+		if build.Err == nil {
+			output[i].Builds = LTSBuilds(build.Build, milestones)
+		}
+	}
+	return output, nil
+}
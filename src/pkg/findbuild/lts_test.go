@@ -0,0 +1,83 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindLTSBuilds(t *testing.T) {
+	gr, g := fakeServices()
+	addReleaseBranches(gr, g)
+	req := fakeRequest(gr, g)
+	req.CLs = []string{"101", "102", "999"}
+	got, err := FindLTSBuilds(context.Background(), req, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("FindLTSBuilds failed: %v", err)
+	}
+	notLanded := func(milestone int, release string) *LTSBuild {
+		return &LTSBuild{Milestone: milestone, Release: release}
+	}
+	expected := []*LTSReport{
+		{
+			CL: "101",
+			Builds: []*LTSBuild{
+				notLanded(1, "release-R1"),
+				{Milestone: 2, Release: "release-R2", Landed: true, BuildNum: "2.0.0", CLNum: "101"},
+				notLanded(3, "release-R3"),
+			},
+		},
+		{
+			CL: "102",
+			Builds: []*LTSBuild{
+				notLanded(1, "release-R1"),
+				{Milestone: 2, Release: "release-R2", Landed: true, BuildNum: "2.1.0", CLNum: "202"},
+				notLanded(3, "release-R3"),
+			},
+		},
+	}
+	if len(got) != 3 || got[2].Err == nil || got[2].Err.HTTPCode() != "404" || got[2].Builds != nil {
+		t.Fatalf("expected a 404 error for CL 999, got %+v", got)
+	}
+	if diff := cmp.Diff(expected, got[:2]); diff != "" {
+		t.Errorf("unexpected reports, diff (-want +got):\n%s", diff)
+	}
+	if _, err := FindLTSBuilds(context.Background(), req, nil); err == nil {
+		t.Error("expected an error without milestones")
+	}
+}
+
+func TestFindBuildMilestonesFilter(t *testing.T) {
+	gr, g := fakeServices()
+	addReleaseBranches(gr, g)
+	req := fakeRequest(gr, g)
+	req.CL = "102"
+	req.AllMilestones = true
+	req.Milestones = []int{1}
+	res, err := FindBuild(context.Background(), req)
+	if err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	var releases []string
+	for _, milestone := range res.Milestones {
+		releases = append(releases, milestone.Release)
+	}
+	if diff := cmp.Diff([]string{"master"}, releases); diff != "" {
+		t.Errorf("unexpected milestones, diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// defaultMappingJSON is the JSON encoding of DefaultMappingConfig
+//
+//go:embed default_mapping.json
+var defaultMappingJSON []byte
+
+// MappingConfig describes how the CLs of a Gerrit instance map to the
+// repositories and release branches of the manifest repository.
+type MappingConfig struct {
+	// ReleaseRules handle the projects whose branch names do not match a
+	// branch of the manifest repository.
+	ReleaseRules []ReleaseRule
+	// RepoPrefixes are stripped from the project of a CL to get its
+	// repository name in the manifest files, ex. "chromiumos/". Only the
+	// longest matching prefix is stripped.
+	RepoPrefixes []string
+	// ReplaceDefaults indicates that a config loaded by LoadMappingConfig
+	// replaces DefaultMappingConfig instead of extending it.
+	ReplaceDefaults bool `json:",omitempty"`
+}
+
+// ReleaseRule maps the branches of a Gerrit project to release branches of
+// the manifest repository.
+type ReleaseRule struct {
+	// Host restricts the rule to the CLs of a Gerrit instance, ex.
+	// "https://chromium-review.googlesource.com". Rules with a Host take
+	// precedence over rules without one, which apply to every instance.
+	Host string `json:",omitempty"`
+	// Project is the Gerrit project the rule applies to, ex.
+	// "third_party/kernel".
+	Project string
+	// BranchPattern is the regexp matching the branch of a CL, ex.
+	// "(.*)-cos-.*". Its first submatch is the release branch.
+	BranchPattern string
+	// DefaultRelease is the release branch of the CLs whose branch does not
+	// match BranchPattern.
+	DefaultRelease string
+}
+
+// DefaultMappingConfig returns the mapping of the COS and ChromiumOS kernel
+// branches, and of the repositories of ChromiumOS projects. It is read from
+// the embedded default_mapping.json file.
+func DefaultMappingConfig() *MappingConfig {
+	config, err := parseMappingConfig(defaultMappingJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default mapping config: %v", err))
+	}
+	return config
+}
+
+// parseMappingConfig decodes and validates the JSON encoding of a
+// MappingConfig.
+func parseMappingConfig(data []byte) (*MappingConfig, error) {
+	config := &MappingConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if _, err := config.compile(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadMappingConfig reads a MappingConfig from a JSON file. Unless the file
+// sets ReplaceDefaults, its rules extend DefaultMappingConfig, and take
+// precedence over the default rules of the same projects.
+func LoadMappingConfig(path string) (*MappingConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadMappingConfig: error reading %s: %v", path, err)
+	}
+	config, err := parseMappingConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("LoadMappingConfig: invalid config %s: %v", path, err)
+	}
+	if config.ReplaceDefaults {
+		return config, nil
+	}
+	return config.extend(DefaultMappingConfig()), nil
+}
+
+// extend returns the config combining the rules of c and base. The release
+// rules of c are listed first, so they are preferred over the rules of base
+// for the same project and host.
+func (c *MappingConfig) extend(base *MappingConfig) *MappingConfig {
+	output := &MappingConfig{}
+	output.ReleaseRules = append(output.ReleaseRules, c.ReleaseRules...)
+	output.ReleaseRules = append(output.ReleaseRules, base.ReleaseRules...)
+	seen := make(map[string]bool)
+	for _, prefix := range append(append([]string{}, base.RepoPrefixes...), c.RepoPrefixes...) {
+		if !seen[prefix] {
+			seen[prefix] = true
+			output.RepoPrefixes = append(output.RepoPrefixes, prefix)
+		}
+	}
+	return output
+}
+
+// clMapping is a compiled MappingConfig.
+type clMapping struct {
+	rules    []releaseRule
+	prefixes []string
+}
+
+type releaseRule struct {
+	host           string
+	project        string
+	releaseRe      *regexp.Regexp
+	defaultRelease string
+}
+
+// normalizeHost strips the scheme and trailing slash of a Gerrit instance
+// URL, so that rules match with or without them.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/")
+}
+
+// compile validates c and compiles its branch patterns.
+func (c *MappingConfig) compile() (*clMapping, error) {
+	output := &clMapping{prefixes: c.RepoPrefixes}
+	for _, rule := range c.ReleaseRules {
+		if rule.Project == "" {
+			return nil, fmt.Errorf("release rule for branch pattern %q has no project", rule.BranchPattern)
+		}
+		releaseRe, err := regexp.Compile(rule.BranchPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch pattern for project %s: %v", rule.Project, err)
+		}
+		if releaseRe.NumSubexp() < 1 {
+			return nil, fmt.Errorf("branch pattern %q for project %s has no submatch for the release branch", rule.BranchPattern, rule.Project)
+		}
+		output.rules = append(output.rules, releaseRule{
+			host:           normalizeHost(rule.Host),
+			project:        rule.Project,
+			releaseRe:      releaseRe,
+			defaultRelease: rule.DefaultRelease,
+		})
+	}
+	return output, nil
+}
+
+// rule returns the release rule of a project of a Gerrit instance, or nil if
+// there is none.
+func (m *clMapping) rule(instanceURL, project string) *releaseRule {
+	host := normalizeHost(instanceURL)
+	var output *releaseRule
+	for i, rule := range m.rules {
+		if rule.project != project {
+			continue
+		}
+		if rule.host == host {
+			return &m.rules[i]
+		}
+		if rule.host == "" && output == nil {
+			output = &m.rules[i]
+		}
+	}
+	return output
+}
+
+// release returns the release branch of the manifest repository a CL of a
+// Gerrit instance was submitted to.
+func (m *clMapping) release(instanceURL, project, branch string) string {
+	// In case the branch associated with the change is "main", branch
+	// on the manifest-snapshot will be master.
+	if branch == "main" {
+		return "master"
+	}
+	// If a repository has non-conventional branch names, need to convert the
+	// repository branch name to a release branch name
+	rule := m.rule(instanceURL, project)
+	if rule == nil {
+		return branch
+	}
+	if matches := rule.releaseRe.FindStringSubmatch(branch); matches != nil {
+		return matches[1]
+	}
+	return rule.defaultRelease
+}
+
+// repo returns the name of a Gerrit project in the manifest files.
+func (m *clMapping) repo(project string) string {
+	longest := ""
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(project, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	return strings.TrimPrefix(project, longest)
+}
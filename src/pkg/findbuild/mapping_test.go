@@ -0,0 +1,205 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMappingRelease(t *testing.T) {
+	config := DefaultMappingConfig()
+	config.ReleaseRules = append(config.ReleaseRules,
+		ReleaseRule{Host: "chromium-review.googlesource.com", Project: "third_party/kernel", BranchPattern: "chromeos-(.*)", DefaultRelease: "main"},
+	)
+	mapping, err := config.compile()
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	tests := map[string]struct {
+		instanceURL string
+		project     string
+		branch      string
+		expected    string
+	}{
+		"Conventional Branch": {
+			instanceURL: externalGerritURL,
+			project:     "cos/overlays",
+			branch:      "release-R93",
+			expected:    "release-R93",
+		},
+		"Main Branch": {
+			instanceURL: externalGerritURL,
+			project:     "cos/overlays",
+			branch:      "main",
+			expected:    "master",
+		},
+		"COS Kernel": {
+			instanceURL: externalGerritURL,
+			project:     "third_party/kernel",
+			branch:      "release-R93-cos-5.10",
+			expected:    "release-R93",
+		},
+		"COS Kernel Default": {
+			instanceURL: externalGerritURL,
+			project:     "third_party/kernel",
+			branch:      "cos-5.10",
+			expected:    "master",
+		},
+		"ChromiumOS Kernel": {
+			instanceURL: externalFallbackGerritURL,
+			project:     "chromiumos/third_party/kernel",
+			branch:      "release-R93-chromeos-5.10",
+			expected:    "release-R93",
+		},
+		"Host Rule": {
+			instanceURL: externalFallbackGerritURL,
+			project:     "third_party/kernel",
+			branch:      "chromeos-release-R93",
+			expected:    "release-R93",
+		},
+		"Host Rule Default": {
+			instanceURL: externalFallbackGerritURL + "/",
+			project:     "third_party/kernel",
+			branch:      "release-R93-cos-5.10",
+			expected:    "main",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := mapping.release(test.instanceURL, test.project, test.branch); got != test.expected {
+				t.Errorf("expected release %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestMappingRepo(t *testing.T) {
+	mapping, err := (&MappingConfig{RepoPrefixes: []string{"chromiumos/", "chromiumos/third_party/"}}).compile()
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	tests := map[string]struct {
+		project  string
+		expected string
+	}{
+		"No Prefix":      {project: "cos/overlays", expected: "cos/overlays"},
+		"Prefix":         {project: "chromiumos/overlays/board-overlays", expected: "overlays/board-overlays"},
+		"Longest Prefix": {project: "chromiumos/third_party/kernel", expected: "kernel"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := mapping.repo(test.project); got != test.expected {
+				t.Errorf("expected repository %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadMappingConfig(t *testing.T) {
+	tests := map[string]struct {
+		contents    string
+		expected    *MappingConfig
+		expectedErr bool
+	}{
+		"Valid": {
+			contents: `{"ReleaseRules": [{"Host": "https://chromium-review.googlesource.com", "Project": "chromiumos/third_party/kernel",` +
+				` "BranchPattern": "(.*)-chromeos-.*", "DefaultRelease": "master"}], "RepoPrefixes": ["chromiumos/"],` +
+				` "ReplaceDefaults": true}`,
+			expected: &MappingConfig{
+				ReleaseRules: []ReleaseRule{{
+					Host:           externalFallbackGerritURL,
+					Project:        "chromiumos/third_party/kernel",
+					BranchPattern:  "(.*)-chromeos-.*",
+					DefaultRelease: "master",
+				}},
+				RepoPrefixes:    []string{"chromiumos/"},
+				ReplaceDefaults: true,
+			},
+		},
+		"Extends Defaults": {
+			contents: `{"ReleaseRules": [{"Project": "third_party/kernel", "BranchPattern": "(.*)-custom-.*", "DefaultRelease": "release-R1"}],` +
+				` "RepoPrefixes": ["chromiumos/", "custom/"]}`,
+			expected: &MappingConfig{
+				ReleaseRules: append([]ReleaseRule{{
+					Project:        "third_party/kernel",
+					BranchPattern:  "(.*)-custom-.*",
+					DefaultRelease: "release-R1",
+				}}, DefaultMappingConfig().ReleaseRules...),
+				RepoPrefixes: append(DefaultMappingConfig().RepoPrefixes, "custom/"),
+			},
+		},
+		"Malformed": {
+			contents:    `{"ReleaseRules": [`,
+			expectedErr: true,
+		},
+		"Invalid Pattern": {
+			contents:    `{"ReleaseRules": [{"Project": "third_party/kernel", "BranchPattern": "(.*"}]}`,
+			expectedErr: true,
+		},
+		"No Submatch": {
+			contents:    `{"ReleaseRules": [{"Project": "third_party/kernel", "BranchPattern": ".*-cos-.*"}]}`,
+			expectedErr: true,
+		},
+		"No Project": {
+			contents:    `{"ReleaseRules": [{"BranchPattern": "(.*)-cos-.*"}]}`,
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mapping.json")
+			if err := ioutil.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			got, err := LoadMappingConfig(path)
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got config %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMappingConfig failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, got); diff != "" {
+				t.Errorf("unexpected config, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if _, err := LoadMappingConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error loading a missing config")
+	}
+}
+
+func TestDefaultMappingConfig(t *testing.T) {
+	config := DefaultMappingConfig()
+	if len(config.ReleaseRules) == 0 || len(config.RepoPrefixes) == 0 {
+		t.Fatalf("default mapping config has no rules: %+v", config)
+	}
+	mapping, err := config.compile()
+	if err != nil {
+		t.Fatalf("default mapping config is invalid: %v", err)
+	}
+	if got := mapping.release(externalGerritURL, "third_party/kernel", "release-R2-cos-5.4"); got != "release-R2" {
+		t.Errorf("release of a COS kernel branch: expected release-R2, got %s", got)
+	}
+	if got := mapping.repo("chromiumos/overlays/board-overlays"); got != "overlays/board-overlays" {
+		t.Errorf("repo of a ChromiumOS project: expected overlays/board-overlays, got %s", got)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// releaseBranchRe matches the release branches of the manifest repository
+// and captures their name and milestone, ex. "refs/heads/release-R93".
+var releaseBranchRe = regexp.MustCompile(`^refs/heads/(release-R(\d+))$`)
+
+// manifestBranches returns the release branches of the manifest repository,
+// ordered by milestone.
+func (s *buildSearch) manifestBranches() ([]string, utils.ChangelogError) {
+	s.branchesOnce.Do(func() {
+		gerritClient, clErr := s.manifestGerritClient()
+		if clErr != nil {
+			s.branchesErr = clErr
+			return
+		}
+		branchInfos, _, err := gerritClient.ListBranches(s.request.ManifestRepo, &gerrit.BranchOptions{})
+		if err != nil {
+			log.Errorf("failed to retrieve branches for project %s:\n%v", s.request.ManifestRepo, err)
+			s.branchesErr = utils.InternalServerError
+			if s.ctx.Err() != nil {
+				s.branchesErr = utils.TimeoutError
+			}
+			return
+		}
+		milestones := make(map[string]int)
+		for _, branchInfo := range *branchInfos {
+			matches := releaseBranchRe.FindStringSubmatch(branchInfo.Ref)
+			if matches == nil {
+				continue
+			}
+			milestone, _ := strconv.Atoi(matches[2])
+			milestones[matches[1]] = milestone
+			s.branches = append(s.branches, matches[1])
+		}
+		sort.Slice(s.branches, func(i, j int) bool {
+			return milestones[s.branches[i]] < milestones[s.branches[j]]
+		})
+	})
+	return s.branches, s.branchesErr
+}
+
+// branchMilestone returns the milestone of a release branch of the manifest
+// repository, ex. 93 for "release-R93".
+func branchMilestone(branch string) (int, bool) {
+	matches := releaseBranchRe.FindStringSubmatch("refs/heads/" + branch)
+	if matches == nil {
+		return 0, false
+	}
+	milestone, err := strconv.Atoi(matches[2])
+	return milestone, err == nil
+}
+
+// searchesBranch reports whether a release branch is searched with
+// BuildRequest.AllMilestones.
+func (r *BuildRequest) searchesBranch(branch string) bool {
+	if len(r.Milestones) == 0 {
+		return true
+	}
+	milestone, ok := branchMilestone(branch)
+	if !ok {
+		return false
+	}
+	for _, m := range r.Milestones {
+		if m == milestone {
+			return true
+		}
+	}
+	return false
+}
+
+// cherryPicks returns the search data of the merged changes sharing the
+// Change-Id of the target CL in its repository, keyed by release branch.
+// The target CL is included, as well as the other backports of its upstream
+// commit if it was searched with BuildRequest.UpstreamKernel.
+func (s *buildSearch) cherryPicks(target *clData) (map[string]*clData, utils.ChangelogError) {
+	output := map[string]*clData{target.Release: target}
+	if target.UpstreamCommit != "" {
+		backports, clErr := upstreamBackports(s.ctx, s.gerritClient, target.UpstreamCommit, target.InstanceURL)
+		if clErr != nil {
+			return nil, clErr
+		}
+		for _, change := range backports {
+			data := newCLData(change, target.InstanceURL, s.mapping)
+			if _, ok := output[data.Release]; !ok {
+				data.UpstreamCommit = target.UpstreamCommit
+				output[data.Release] = data
+			}
+		}
+	}
+	if target.ChangeID == "" {
+		return output, nil
+	}
+	queryOptions := &gerrit.QueryChangeOptions{}
+	queryOptions.Query = []string{fmt.Sprintf("change:%s status:merged", target.ChangeID)}
+	queryOptions.AdditionalFields = []string{"CURRENT_REVISION"}
+	changes, _, err := s.gerritClient.QueryChanges(queryOptions)
+	if err != nil {
+		log.Errorf("cherryPicks: error retrieving changes with Change-Id %s:\n%v", target.ChangeID, err)
+		if s.ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		return nil, utils.InternalServerError
+	}
+	for _, change := range *changes {
+		if change.Submitted == nil {
+			continue
+		}
+		data := newCLData(change, target.InstanceURL, s.mapping)
+		if _, ok := output[data.Release]; ok || data.Project != target.Project {
+			continue
+		}
+		log.Debugf("Found cherry-pick %s of CL %s on %s", data.CLNum, target.CLNum, data.Release)
+		output[data.Release] = data
+	}
+	return output, nil
+}
+
+// milestones locates the first build containing the target CL on each
+// release branch of the manifest repository. A branch is searched for a
+// cherry-pick of the CL, or for the CL itself if it was submitted to master
+// before the branch was created. Branches that do not contain the CL, or whose
+// milestone is not in BuildRequest.Milestones, are omitted.
+//
+// found is the first build containing the CL on its own branch.
+func (s *buildSearch) milestones(target *clData, found *BuildResponse) ([]*BuildResponse, utils.ChangelogError) {
+	branches, clErr := s.manifestBranches()
+	if clErr != nil {
+		return nil, clErr
+	}
+	picks, clErr := s.cherryPicks(target)
+	if clErr != nil {
+		return nil, clErr
+	}
+	own := *found
+	output := make([]*BuildResponse, len(branches))
+	errs := make([]utils.ChangelogError, len(branches))
+	sem := make(chan struct{}, maxConcurrentCLs)
+	var wg sync.WaitGroup
+	for i, branch := range branches {
+		if !s.request.searchesBranch(branch) {
+			continue
+		}
+		if branch == target.Release {
+			output[i] = &own
+			continue
+		}
+		data, ok := picks[branch]
+		if !ok {
+			if target.Release != "master" {
+				continue
+			}
+			// Release branches created after the CL was submitted contain it.
+			// The branch of the CL's repository is unknown.
+			derived := *target
+			derived.Release = branch
+			derived.Branch = ""
+			data = &derived
+		} else {
+			copied := *data
+			data = &copied
+			data.ID = target.ID
+		}
+		i, branch := i, branch
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res, clErr := s.findOnBranch(data)
+			if clErr != nil {
+				log.Debugf("CL %s not found on %s: %v", target.CLNum, branch, clErr)
+				errs[i] = clErr
+				return
+			}
+			output[i] = res
+		}()
+	}
+	wg.Wait()
+	var milestones []*BuildResponse
+	for i := range branches {
+		if errs[i] == utils.TimeoutError {
+			return nil, errs[i]
+		}
+		if output[i] != nil {
+			milestones = append(milestones, output[i])
+		}
+	}
+	if !releaseBranchRe.MatchString("refs/heads/" + target.Release) {
+		milestones = append(milestones, &own)
+	}
+	return milestones, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+// addReleaseBranches adds release branches to fakeServices. release-R1 is
+// created from build 1.0.0 before CL 101 is submitted, and has build 1.1.0.
+// release-R2 is created from build 2.0.0, and has build 2.1.0 containing
+// CL 202, a cherry-pick of CL 102.
+func addReleaseBranches(gr *fakes.Gerrit, g *fakes.Gitiles) {
+	g.Commits[externalManifestRepo] = append(g.Commits[externalManifestRepo],
+		&git.Commit{Id: "r1", Parents: []string{"m1"}, Committer: committedAt(fakeBaseTime.Add(20 * time.Hour))},
+		&git.Commit{Id: "r2", Parents: []string{"m2"}, Committer: committedAt(fakeBaseTime.Add(40 * time.Hour))})
+	g.Refs[externalManifestRepo]["refs/heads/release-R1"] = "r1"
+	g.Refs[externalManifestRepo]["refs/heads/release-R2"] = "r2"
+	g.Commits["cos/overlays"] = append(g.Commits["cos/overlays"], &git.Commit{Id: "o2p", Parents: []string{"o2"}})
+	for _, build := range []struct {
+		buildNum, manifestSHA, overlaysSHA string
+	}{{"1.1.0", "r1", "o1"}, {"2.1.0", "r2", "o2p"}} {
+		g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + build.buildNum, Path: "snapshot.xml"}] = fakeSnapshot(build.overlaysSHA)
+		gr.Tags[externalManifestRepo] = append(gr.Tags[externalManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + build.buildNum, Revision: build.manifestSHA})
+	}
+	gr.Branches[externalManifestRepo] = []gerrit.BranchInfo{
+		{Ref: "refs/heads/master", Revision: "m3"},
+		{Ref: "refs/heads/release-R2", Revision: "r2"},
+		{Ref: "refs/heads/release-R1", Revision: "r1"},
+		{Ref: "refs/heads/stabilize-1.2.B", Revision: "m2"},
+	}
+	gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+		Number:          202,
+		ChangeID:        "I102",
+		Project:         "cos/overlays",
+		Branch:          "release-R2",
+		Status:          "MERGED",
+		CurrentRevision: "o2p",
+		Submitted:       &gerrit.Timestamp{Time: fakeBaseTime.Add(38 * time.Hour)},
+	})
+}
+
+func TestFindBuildAllMilestones(t *testing.T) {
+	window := 5 * 24 * time.Hour
+	tests := map[string]struct {
+		cl       string
+		expected []*BuildResponse
+	}{
+		"Branch Created After CL": {
+			cl: "101",
+			expected: []*BuildResponse{
+				{BuildNum: "2.0.0", CLNum: "101", SearchWindow: window, Release: "release-R2", ManifestRepo: externalManifestRepo},
+				{BuildNum: "2.0.0", CLNum: "101", SearchWindow: window, Release: "master", ManifestRepo: externalManifestRepo},
+			},
+		},
+		"Cherry-Pick": {
+			cl: "102",
+			expected: []*BuildResponse{
+				{BuildNum: "2.1.0", CLNum: "202", SearchWindow: window, Release: "release-R2", ManifestRepo: externalManifestRepo},
+				{BuildNum: "3.0.0", CLNum: "102", SearchWindow: window, Release: "master", ManifestRepo: externalManifestRepo},
+			},
+		},
+		"Release Branch CL": {
+			cl: "202",
+			expected: []*BuildResponse{
+				{BuildNum: "2.1.0", CLNum: "202", SearchWindow: window, Release: "release-R2", ManifestRepo: externalManifestRepo},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addReleaseBranches(gr, g)
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			req.AllMilestones = true
+			res, err := FindBuild(context.Background(), req)
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res.Milestones, ignoreCandidates); diff != "" {
+				t.Errorf("unexpected milestones, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManifestBranches(t *testing.T) {
+	gr, g := fakeServices()
+	gr.Branches[externalManifestRepo] = []gerrit.BranchInfo{
+		{Ref: "refs/heads/release-R10"},
+		{Ref: "refs/heads/master"},
+		{Ref: "refs/heads/release-R9"},
+		{Ref: "refs/heads/release-R93-old"},
+	}
+	search, clErr := newBuildSearch(context.Background(), fakeRequest(gr, g))
+	if clErr != nil {
+		t.Fatalf("newBuildSearch failed: %v", clErr)
+	}
+	branches, clErr := search.manifestBranches()
+	if clErr != nil {
+		t.Fatalf("manifestBranches failed: %v", clErr)
+	}
+	if diff := cmp.Diff([]string{"release-R9", "release-R10"}, branches); diff != "" {
+		t.Errorf("unexpected branches, diff (-want +got):\n%s", diff)
+	}
+}
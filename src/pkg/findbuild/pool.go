@@ -0,0 +1,99 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"net/http"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gitilesApi "go.chromium.org/luci/common/api/gitiles"
+)
+
+// ClientPool shares the clients of each Gerrit and GoB host between the
+// FindBuild and FindBuilds calls of a long running process, ex. a server
+// handling many concurrent lookups, instead of creating them for every call.
+// The authorized http.Client of each host is only resolved once, so its
+// connections are reused between calls.
+//
+// Gitiles clients are shared as is. The Gerrit client does not accept a
+// context, so a Gerrit client sending its requests with the context of the
+// call is created around the shared http.Client of its host by every call.
+//
+// A ClientPool is safe for concurrent use.
+type ClientPool struct {
+	httpClient     *http.Client
+	hostHTTPClient func(host string) (*http.Client, error)
+
+	mu      sync.Mutex
+	hosts   map[string]*http.Client
+	gitiles map[string]utils.GitilesService
+}
+
+// NewClientPool creates a ClientPool sending the requests to every host
+// with httpClient, or with the client returned by hostHTTPClient for the
+// host if it is not nil, as BuildRequest.HTTPClient and
+// BuildRequest.HostHTTPClient.
+func NewClientPool(httpClient *http.Client, hostHTTPClient func(host string) (*http.Client, error)) *ClientPool {
+	return &ClientPool{
+		httpClient:     httpClient,
+		hostHTTPClient: hostHTTPClient,
+		hosts:          make(map[string]*http.Client),
+		gitiles:        make(map[string]utils.GitilesService),
+	}
+}
+
+// hostClientLocked returns the authorized client of a host. p.mu must be
+// held.
+func (p *ClientPool) hostClientLocked(host string) (*http.Client, error) {
+	host = normalizeHost(host)
+	if client, ok := p.hosts[host]; ok {
+		return client, nil
+	}
+	request := &BuildRequest{HTTPClient: p.httpClient, HostHTTPClient: p.hostHTTPClient}
+	client, err := request.httpClient(host)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Created pooled HTTP client for host %s", host)
+	p.hosts[host] = client
+	return client, nil
+}
+
+// hostClient returns the authorized client of a Gerrit or GoB host.
+func (p *ClientPool) hostClient(host string) (*http.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hostClientLocked(host)
+}
+
+// gitilesClient returns the client of the GoB instance at remoteURL.
+func (p *ClientPool) gitilesClient(remoteURL string) (utils.GitilesService, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.gitiles[remoteURL]; ok {
+		return client, nil
+	}
+	httpClient, err := p.hostClientLocked(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := gitilesApi.NewRESTClient(httpClient, remoteURL, true)
+	if err != nil {
+		return nil, err
+	}
+	p.gitiles[remoteURL] = client
+	return client, nil
+}
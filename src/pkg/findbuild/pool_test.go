@@ -0,0 +1,104 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+func TestClientPool(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer host-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/+log/") {
+			fmt.Fprint(w, ")]}'\n{\"log\": []}")
+			return
+		}
+		fmt.Fprint(w, ")]}'\n[]")
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	var mu sync.Mutex
+	resolved := make(map[string]int)
+	pool := NewClientPool(server.Client(), func(h string) (*http.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolved[h]++
+		return &http.Client{Transport: tokenTransport{token: "host-token", base: server.Client().Transport}}, nil
+	})
+
+	var gitilesClients []utils.GitilesService
+	var wg sync.WaitGroup
+	var clientsMu sync.Mutex
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &BuildRequest{ClientPool: pool, RetryPolicy: &utils.RetryPolicy{MaxAttempts: 1}}
+			gerritClient, err := req.gerritClient(context.Background(), server.URL)
+			if err != nil {
+				t.Errorf("gerritClient failed: %v", err)
+				return
+			}
+			if _, _, err := gerritClient.QueryChanges(&gerrit.QueryChangeOptions{}); err != nil {
+				t.Errorf("QueryChanges failed: %v", err)
+			}
+			gitilesClient, err := req.gitilesClient(host)
+			if err != nil {
+				t.Errorf("gitilesClient failed: %v", err)
+				return
+			}
+			if _, _, err := utils.Commits(context.Background(), gitilesClient, "cos/overlays", "master", "", 1); err != nil {
+				t.Errorf("Commits failed: %v", err)
+			}
+			clientsMu.Lock()
+			gitilesClients = append(gitilesClients, gitilesClient)
+			clientsMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if resolved[host] != 1 || len(resolved) != 1 {
+		t.Errorf("expected the client of host %s to be resolved once, got %v", host, resolved)
+	}
+	for _, client := range gitilesClients {
+		if client != gitilesClients[0] {
+			t.Error("expected every request to share the same Gitiles client")
+		}
+	}
+
+	// The context of each call still applies to the requests of a pooled
+	// Gerrit client.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := &BuildRequest{ClientPool: pool, RetryPolicy: &utils.RetryPolicy{MaxAttempts: 1}}
+	gerritClient, err := req.gerritClient(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("gerritClient failed: %v", err)
+	}
+	if _, _, err := gerritClient.QueryChanges(&gerrit.QueryChangeOptions{}); err == nil {
+		t.Error("expected QueryChanges to fail with a cancelled context")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// PublicImageProject is the GCP project the public COS images are released
+// in.
+const PublicImageProject = "cos-cloud"
+
+// ReleaseChecker reports whether a build was released. Manifest tags
+// sometimes exist for builds that never shipped.
+type ReleaseChecker interface {
+	// Released reports whether a build, ex. "16919.29.16", was released.
+	Released(ctx context.Context, buildNum string) (bool, error)
+}
+
+// imageReleaseChecker implements ReleaseChecker by looking up the images of
+// a GCP project.
+type imageReleaseChecker struct {
+	svc     *compute.Service
+	project string
+}
+
+// NewImageReleaseChecker returns a ReleaseChecker reporting that a build was
+// released if an image of project, ex. PublicImageProject, was built from
+// it. The images are named after their milestone and build number, ex.
+// "cos-97-16919-29-16" or "cos-beta-101-17162-40-5". Deprecated images count
+// as released.
+func NewImageReleaseChecker(svc *compute.Service, project string) ReleaseChecker {
+	return &imageReleaseChecker{svc: svc, project: project}
+}
+
+func (c *imageReleaseChecker) Released(ctx context.Context, buildNum string) (bool, error) {
+	suffix := "-" + strings.ReplaceAll(buildNum, ".", "-")
+	filter := fmt.Sprintf(`name eq "cos-.*%s"`, suffix)
+	found := false
+	err := c.svc.Images.List(c.project).Filter(filter).Pages(ctx, func(imageList *compute.ImageList) error {
+		for _, image := range imageList.Items {
+			if strings.HasPrefix(image.Name, "cos-") && strings.HasSuffix(image.Name, suffix) {
+				found = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list the images of project %s: %v", c.project, err)
+	}
+	return found, nil
+}
+
+// checkReleased reports whether the build of res and of its Milestones were
+// released in their Released field. The check failing does not fail the
+// search, and leaves Released unset.
+func (s *buildSearch) checkReleased(cl string, res *BuildResponse) {
+	finish := startStage(s.request.hooks(), cl, ReleaseCheckStage)
+	defer finish(nil)
+	checked := make(map[string]*bool)
+	for _, build := range append([]*BuildResponse{res}, res.Milestones...) {
+		if released, ok := checked[build.BuildNum]; ok {
+			build.Released = released
+			continue
+		}
+		released, err := s.request.ReleaseChecker.Released(s.ctx, build.BuildNum)
+		if err != nil {
+			log.Errorf("checkReleased: failed to check whether build %s of CL %s was released: %v", build.BuildNum, cl, err)
+			continue
+		}
+		build.Released = &released
+		checked[build.BuildNum] = build.Released
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestFindBuildReleased(t *testing.T) {
+	released, notReleased := true, false
+	tests := map[string]struct {
+		images      []string
+		unavailable bool
+		expected    *bool
+	}{
+		"Released": {
+			images:   []string{"cos-1-1-0-0", "cos-beta-1-2-0-0"},
+			expected: &released,
+		},
+		"Not Released": {
+			images:   []string{"cos-1-1-0-0", "cos-1-12-0-0", "cos-1-3-0-0"},
+			expected: &notReleased,
+		},
+		"Check Failed": {
+			unavailable: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gce, svc := fakes.GCEForTest(t, PublicImageProject)
+			defer gce.Close()
+			for _, image := range test.images {
+				gce.Images.Items = append(gce.Images.Items, &compute.Image{Name: image})
+			}
+			if test.unavailable {
+				gce.Close()
+			}
+			gr, g := fakeServices()
+			req := fakeRequest(gr, g)
+			req.CL = "101"
+			req.ReleaseChecker = NewImageReleaseChecker(svc, PublicImageProject)
+			res, err := FindBuild(context.Background(), req)
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if res.BuildNum != "2.0.0" {
+				t.Errorf("expected build 2.0.0, got %s", res.BuildNum)
+			}
+			switch {
+			case test.expected == nil && res.Released != nil:
+				t.Errorf("expected the release to be unchecked, got %t", *res.Released)
+			case test.expected != nil && res.Released == nil:
+				t.Errorf("expected released = %t, got unchecked", *test.expected)
+			case test.expected != nil && *res.Released != *test.expected:
+				t.Errorf("expected released = %t, got %t", *test.expected, *res.Released)
+			}
+		})
+	}
+}
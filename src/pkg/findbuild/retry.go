@@ -0,0 +1,75 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// retryPolicy returns the policy used to retry the Gerrit requests of the
+// request.
+func (r *BuildRequest) retryPolicy() utils.RetryPolicy {
+	if r.RetryPolicy != nil {
+		return *r.RetryPolicy
+	}
+	return utils.DefaultRetryPolicy()
+}
+
+// retryingGerrit retries the failed requests of a GerritService that may
+// succeed if sent again, scheduling every attempt with scheduler. The Gerrit
+// client does not accept a context, so the RequestTimeout of the policy is not
+// applied to its requests.
+type retryingGerrit struct {
+	GerritService
+	ctx       context.Context
+	policy    utils.RetryPolicy
+	scheduler *utils.Scheduler
+}
+
+func (g retryingGerrit) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	var changes *[]gerrit.ChangeInfo
+	var resp *gerrit.Response
+	err := g.scheduler.Do(g.ctx, g.policy, utils.GerritRetryable, func(context.Context) error {
+		var err error
+		changes, resp, err = g.GerritService.QueryChanges(opt)
+		return err
+	})
+	return changes, resp, err
+}
+
+func (g retryingGerrit) ListTags(projectName string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, *gerrit.Response, error) {
+	var tags *[]gerrit.TagInfo
+	var resp *gerrit.Response
+	err := g.scheduler.Do(g.ctx, g.policy, utils.GerritRetryable, func(context.Context) error {
+		var err error
+		tags, resp, err = g.GerritService.ListTags(projectName, opt)
+		return err
+	})
+	return tags, resp, err
+}
+
+func (g retryingGerrit) ListBranches(projectName string, opt *gerrit.BranchOptions) (*[]gerrit.BranchInfo, *gerrit.Response, error) {
+	var branches *[]gerrit.BranchInfo
+	var resp *gerrit.Response
+	err := g.scheduler.Do(g.ctx, g.policy, utils.GerritRetryable, func(context.Context) error {
+		var err error
+		branches, resp, err = g.GerritService.ListBranches(projectName, opt)
+		return err
+	})
+	return branches, resp, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// flakyGerrit fails the first failures change queries with err.
+type flakyGerrit struct {
+	*fakes.Gerrit
+	err      error
+	failures int
+
+	mu      sync.Mutex
+	queries int
+}
+
+func (g *flakyGerrit) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	g.mu.Lock()
+	g.queries++
+	failed := g.queries <= g.failures
+	g.mu.Unlock()
+	if failed {
+		return nil, nil, g.err
+	}
+	return g.Gerrit.QueryChanges(opt)
+}
+
+func TestFindBuildRetry(t *testing.T) {
+	unavailable := errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 503")
+	forbidden := errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 403")
+	tests := map[string]struct {
+		err             error
+		failures        int
+		policy          *utils.RetryPolicy
+		expectedQueries int
+		expectedError   bool
+	}{
+		"Transient Failure": {
+			err:             unavailable,
+			failures:        2,
+			policy:          &utils.RetryPolicy{MaxAttempts: 3},
+			expectedQueries: 3,
+		},
+		"Attempts Exhausted": {
+			err:             unavailable,
+			failures:        3,
+			policy:          &utils.RetryPolicy{MaxAttempts: 3},
+			expectedQueries: 3,
+			expectedError:   true,
+		},
+		"Permanent Failure": {
+			err:             forbidden,
+			failures:        1,
+			policy:          &utils.RetryPolicy{MaxAttempts: 3},
+			expectedQueries: 1,
+			expectedError:   true,
+		},
+		"Retries Disabled": {
+			err:             unavailable,
+			failures:        1,
+			policy:          &utils.RetryPolicy{MaxAttempts: 1},
+			expectedQueries: 1,
+			expectedError:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			flaky := &flakyGerrit{Gerrit: gr, err: test.err, failures: test.failures}
+			req := fakeRequest(gr, g)
+			req.GerritClient = func(string) (GerritService, error) { return flaky, nil }
+			req.RetryPolicy = test.policy
+			req.CL = "101"
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected an error, got build %s", res.BuildNum)
+				}
+			} else if err != nil || res.BuildNum != "2.0.0" {
+				t.Errorf("expected build 2.0.0, got %v with error %v", res, err)
+			}
+			if flaky.queries != test.expectedQueries {
+				t.Errorf("expected %d change queries, got %d", test.expectedQueries, flaky.queries)
+			}
+		})
+	}
+}
+
+func TestFindBuildScheduler(t *testing.T) {
+	tests := map[string]struct {
+		scheduler     *utils.Scheduler
+		expectedError bool
+	}{
+		"Concurrency Capped": {
+			scheduler: utils.NewScheduler(1, 0, 0),
+		},
+		"Quota Exhausted": {
+			scheduler:     utils.NewScheduler(0, 0.001, 1),
+			expectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			req := fakeRequest(gr, g)
+			req.Scheduler = test.scheduler
+			req.CL = "101"
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			res, err := FindBuild(ctx, req)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected an error, got build %s", res.BuildNum)
+				}
+			} else if err != nil || res.BuildNum != "2.0.0" {
+				t.Errorf("expected build 2.0.0, got %v with error %v", res, err)
+			}
+		})
+	}
+}
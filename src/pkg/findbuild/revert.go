@@ -0,0 +1,108 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// Revert describes the CL reverting the CL of a BuildResponse.
+type Revert struct {
+	// CLNum is the number of the CL reverting the CL
+	CLNum string
+	// BuildNum is the first build containing the revert. It is empty if no
+	// build contains the revert yet.
+	BuildNum string `json:",omitempty"`
+}
+
+// revertsCommit reports whether a commit message is the one of a revert of
+// the commit sha, as written by git revert and the Gerrit revert button.
+func revertsCommit(message, sha string) bool {
+	return strings.Contains(message, "This reverts commit "+sha)
+}
+
+// findRevertChange retrieves the earliest merged CL reverting a CL on the
+// same branch of its repository, or nil if it was not reverted.
+func (s *buildSearch) findRevertChange(clData *clData) (*gerrit.ChangeInfo, utils.ChangelogError) {
+	log.Debugf("Retrieving reverts of CL %s from Gerrit", clData.CLNum)
+	queryOptions := &gerrit.QueryChangeOptions{}
+	queryOptions.Query = []string{fmt.Sprintf("message:%s status:merged", clData.Revision)}
+	queryOptions.AdditionalFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT"}
+	changes, _, err := s.gerritClient.QueryChanges(queryOptions)
+	if err != nil {
+		log.Errorf("findRevertChange: error retrieving changes referencing %s:\n%v", clData.Revision, err)
+		if s.ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		if utils.GerritErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
+		}
+		return nil, utils.InternalServerError
+	}
+	var output *gerrit.ChangeInfo
+	for i, change := range *changes {
+		if change.Submitted == nil || change.Submitted.Time.Before(clData.SearchStartRange) {
+			continue
+		}
+		if change.Branch != clData.Branch || s.mapping.repo(change.Project) != clData.Project {
+			continue
+		}
+		if !revertsCommit(change.Revisions[change.CurrentRevision].Commit.Message, clData.Revision) {
+			continue
+		}
+		if output == nil || change.Submitted.Time.Before(output.Submitted.Time) {
+			output = &(*changes)[i]
+		}
+	}
+	return output, nil
+}
+
+// revert locates the first build containing the revert of a CL on the CL's
+// release branch. Returns nil if the CL was not reverted.
+//
+// The CLs of release branches created after the CL was submitted have no
+// branch, and are not checked.
+func (s *buildSearch) revert(clData *clData) (*Revert, utils.ChangelogError) {
+	if clData.Branch == "" {
+		return nil, nil
+	}
+	finish := startStage(s.request.hooks(), clData.ID, RevertStage)
+	change, clErr := s.findRevertChange(clData)
+	if clErr != nil || change == nil {
+		finish(clErr)
+		return nil, clErr
+	}
+	revertData := newCLData(*change, clData.InstanceURL, s.mapping)
+	revertData.ID = clData.ID
+	revertData.Release = clData.Release
+	finish(nil)
+	output := &Revert{CLNum: revertData.CLNum}
+	found, _, clErr := findBuildExponential(s, revertData)
+	if errors.Is(clErr, utils.ErrNoBuildFound) {
+		log.Debugf("CL %s is reverted by CL %s, which no build of %s contains yet", clData.CLNum, revertData.CLNum, clData.Release)
+		return output, nil
+	}
+	if clErr != nil {
+		return nil, clErr
+	}
+	log.Debugf("CL %s is reverted by CL %s in build %s", clData.CLNum, revertData.CLNum, found.BuildNum)
+	output.BuildNum = found.BuildNum
+	return output, nil
+}
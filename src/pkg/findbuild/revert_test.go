@@ -0,0 +1,133 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+)
+
+// addReverts makes CL 102 of fakeServices revert CL 101, and adds CL 103,
+// reverting CL 102 after the last build.
+func addReverts(gr *fakes.Gerrit) {
+	for i := range gr.Changes {
+		if gr.Changes[i].Number == 102 {
+			gr.Changes[i].Revisions = map[string]gerrit.RevisionInfo{
+				overlaysHead: {Commit: gerrit.CommitInfo{Message: "Revert \"CL 101\"\n\nThis reverts commit o2.\n"}},
+			}
+		}
+	}
+	gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+		Number:          103,
+		ChangeID:        "I103",
+		Project:         "cos/overlays",
+		Branch:          "master",
+		Status:          "MERGED",
+		CurrentRevision: "o4",
+		Revisions: map[string]gerrit.RevisionInfo{
+			"o4": {Commit: gerrit.CommitInfo{Message: "Revert \"CL 102\"\n\nThis reverts commit " + overlaysHead + ".\n"}},
+		},
+		Submitted: &gerrit.Timestamp{Time: fakeBaseTime.AddDate(0, 0, 3)},
+	})
+}
+
+func TestFindBuildRevert(t *testing.T) {
+	tests := map[string]struct {
+		cl            string
+		reverts       bool
+		detectReverts bool
+		expected      *Revert
+	}{
+		"Reverted": {
+			cl:            "101",
+			reverts:       true,
+			detectReverts: true,
+			expected:      &Revert{CLNum: "102", BuildNum: "3.0.0"},
+		},
+		"Revert Not In Build": {
+			cl:            "102",
+			reverts:       true,
+			detectReverts: true,
+			expected:      &Revert{CLNum: "103"},
+		},
+		"Not Reverted": {
+			cl:            "101",
+			detectReverts: true,
+		},
+		"Not Requested": {
+			cl:      "101",
+			reverts: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			if test.reverts {
+				addReverts(gr)
+			}
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			req.DetectReverts = test.detectReverts
+			res, err := FindBuild(context.Background(), req)
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res.Revert); diff != "" {
+				t.Errorf("unexpected revert, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRevertsCommit(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		expected bool
+	}{
+		"Revert":       {message: "Revert \"x\"\n\nThis reverts commit " + overlaysHead + ".\n", expected: true},
+		"Other Commit": {message: "Revert \"x\"\n\nThis reverts commit 4444444444444444444444444444444444444444.\n"},
+		"Mention":      {message: "Fix the bug of commit " + overlaysHead + ".\n"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := revertsCommit(test.message, overlaysHead); got != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindBuildRevertHooks(t *testing.T) {
+	gr, g := fakeServices()
+	addReverts(gr)
+	req := fakeRequest(gr, g)
+	req.CL = "101"
+	req.DetectReverts = true
+	hooks := &recordingHooks{}
+	req.Hooks = hooks
+	if _, err := FindBuild(context.Background(), req); err != nil {
+		t.Fatalf("FindBuild failed: %v", err)
+	}
+	for _, event := range hooks.events {
+		if event == "101: finish "+RevertStage {
+			return
+		}
+	}
+	t.Errorf("expected the %s stage to be reported, got events %v", RevertStage, hooks.events)
+}
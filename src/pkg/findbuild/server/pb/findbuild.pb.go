@@ -0,0 +1,399 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.17.3
+// source: proto/findbuild.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FindBuildRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// CL number, commit SHA or Change-Id of the CL.
+	// Ex: 3280 or 18d4ce48c1dc2f530120f85973fec348367f78a0
+	Cl string `protobuf:"bytes,1,opt,name=cl,proto3" json:"cl,omitempty"`
+	// Gerrit instance hosting the CL, with the https:// prefix. Uses the
+	// server default, and its fallback instance, if empty.
+	// Ex: https://cos-review.googlesource.com
+	GerritHost string `protobuf:"bytes,2,opt,name=gerrit_host,json=gerritHost,proto3" json:"gerrit_host,omitempty"`
+	// Git on Borg instance hosting the manifest repository. Uses the server
+	// default if empty. Ex: cos.googlesource.com
+	GitilesHost string `protobuf:"bytes,3,opt,name=gitiles_host,json=gitilesHost,proto3" json:"gitiles_host,omitempty"`
+	// Repository containing the manifest files. Uses the server default if
+	// empty. Ex: cos/manifest-snapshots
+	ManifestRepo string `protobuf:"bytes,4,opt,name=manifest_repo,json=manifestRepo,proto3" json:"manifest_repo,omitempty"`
+	// Also find the first build containing the CL, or its cherry-pick, on
+	// every release branch of the manifest repository.
+	AllMilestones bool `protobuf:"varint,5,opt,name=all_milestones,json=allMilestones,proto3" json:"all_milestones,omitempty"`
+	// Treat cl as the SHA of an upstream Linux kernel commit, and find the
+	// first build containing its backport.
+	UpstreamKernel bool `protobuf:"varint,6,opt,name=upstream_kernel,json=upstreamKernel,proto3" json:"upstream_kernel,omitempty"`
+}
+
+func (x *FindBuildRequest) Reset() {
+	*x = FindBuildRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_findbuild_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindBuildRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindBuildRequest) ProtoMessage() {}
+
+func (x *FindBuildRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_findbuild_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindBuildRequest.ProtoReflect.Descriptor instead.
+func (*FindBuildRequest) Descriptor() ([]byte, []int) {
+	return file_proto_findbuild_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FindBuildRequest) GetCl() string {
+	if x != nil {
+		return x.Cl
+	}
+	return ""
+}
+
+func (x *FindBuildRequest) GetGerritHost() string {
+	if x != nil {
+		return x.GerritHost
+	}
+	return ""
+}
+
+func (x *FindBuildRequest) GetGitilesHost() string {
+	if x != nil {
+		return x.GitilesHost
+	}
+	return ""
+}
+
+func (x *FindBuildRequest) GetManifestRepo() string {
+	if x != nil {
+		return x.ManifestRepo
+	}
+	return ""
+}
+
+func (x *FindBuildRequest) GetAllMilestones() bool {
+	if x != nil {
+		return x.AllMilestones
+	}
+	return false
+}
+
+func (x *FindBuildRequest) GetUpstreamKernel() bool {
+	if x != nil {
+		return x.UpstreamKernel
+	}
+	return false
+}
+
+type Milestone struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Branch of the manifest repository. Ex: release-R93
+	Release string `protobuf:"bytes,1,opt,name=release,proto3" json:"release,omitempty"`
+	// First build on the branch containing the CL.
+	BuildNum string `protobuf:"bytes,2,opt,name=build_num,json=buildNum,proto3" json:"build_num,omitempty"`
+	// Number of the CL, or of its cherry-pick, found in build_num.
+	ClNum string `protobuf:"bytes,3,opt,name=cl_num,json=clNum,proto3" json:"cl_num,omitempty"`
+}
+
+func (x *Milestone) Reset() {
+	*x = Milestone{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_findbuild_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Milestone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Milestone) ProtoMessage() {}
+
+func (x *Milestone) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_findbuild_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Milestone.ProtoReflect.Descriptor instead.
+func (*Milestone) Descriptor() ([]byte, []int) {
+	return file_proto_findbuild_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Milestone) GetRelease() string {
+	if x != nil {
+		return x.Release
+	}
+	return ""
+}
+
+func (x *Milestone) GetBuildNum() string {
+	if x != nil {
+		return x.BuildNum
+	}
+	return ""
+}
+
+func (x *Milestone) GetClNum() string {
+	if x != nil {
+		return x.ClNum
+	}
+	return ""
+}
+
+type FindBuildResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// First build containing the CL.
+	BuildNum string `protobuf:"bytes,1,opt,name=build_num,json=buildNum,proto3" json:"build_num,omitempty"`
+	// Number of the CL found in build_num.
+	ClNum string `protobuf:"bytes,2,opt,name=cl_num,json=clNum,proto3" json:"cl_num,omitempty"`
+	// Branch of the manifest repository build_num is on. Ex: master
+	Release string `protobuf:"bytes,3,opt,name=release,proto3" json:"release,omitempty"`
+	// First build containing the CL on each branch, if all_milestones is set.
+	Milestones []*Milestone `protobuf:"bytes,4,rep,name=milestones,proto3" json:"milestones,omitempty"`
+}
+
+func (x *FindBuildResponse) Reset() {
+	*x = FindBuildResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_findbuild_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindBuildResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindBuildResponse) ProtoMessage() {}
+
+func (x *FindBuildResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_findbuild_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindBuildResponse.ProtoReflect.Descriptor instead.
+func (*FindBuildResponse) Descriptor() ([]byte, []int) {
+	return file_proto_findbuild_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FindBuildResponse) GetBuildNum() string {
+	if x != nil {
+		return x.BuildNum
+	}
+	return ""
+}
+
+func (x *FindBuildResponse) GetClNum() string {
+	if x != nil {
+		return x.ClNum
+	}
+	return ""
+}
+
+func (x *FindBuildResponse) GetRelease() string {
+	if x != nil {
+		return x.Release
+	}
+	return ""
+}
+
+func (x *FindBuildResponse) GetMilestones() []*Milestone {
+	if x != nil {
+		return x.Milestones
+	}
+	return nil
+}
+
+var File_proto_findbuild_proto protoreflect.FileDescriptor
+
+var file_proto_findbuild_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x69, 0x6e, 0x64, 0x62, 0x75, 0x69, 0x6c,
+	0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0d, 0x63, 0x6f, 0x73, 0x5f, 0x66, 0x69, 0x6e,
+	0x64, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x22, 0xdb, 0x01, 0x0a, 0x10, 0x46, 0x69, 0x6e, 0x64, 0x42,
+	0x75, 0x69, 0x6c, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x63,
+	0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x63, 0x6c, 0x12, 0x1f, 0x0a, 0x0b, 0x67,
+	0x65, 0x72, 0x72, 0x69, 0x74, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x67, 0x65, 0x72, 0x72, 0x69, 0x74, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x0c,
+	0x67, 0x69, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x67, 0x69, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x48, 0x6f, 0x73, 0x74, 0x12,
+	0x23, 0x0a, 0x0d, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x5f, 0x72, 0x65, 0x70, 0x6f,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74,
+	0x52, 0x65, 0x70, 0x6f, 0x12, 0x25, 0x0a, 0x0e, 0x61, 0x6c, 0x6c, 0x5f, 0x6d, 0x69, 0x6c, 0x65,
+	0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x61, 0x6c,
+	0x6c, 0x4d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x75,
+	0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4b, 0x65,
+	0x72, 0x6e, 0x65, 0x6c, 0x22, 0x59, 0x0a, 0x09, 0x4d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x62,
+	0x75, 0x69, 0x6c, 0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x62, 0x75, 0x69, 0x6c, 0x64, 0x4e, 0x75, 0x6d, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x6c, 0x5f, 0x6e,
+	0x75, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x6c, 0x4e, 0x75, 0x6d, 0x22,
+	0x9b, 0x01, 0x0a, 0x11, 0x46, 0x69, 0x6e, 0x64, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x6e,
+	0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x4e,
+	0x75, 0x6d, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x63, 0x6c, 0x4e, 0x75, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c,
+	0x65, 0x61, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65,
+	0x61, 0x73, 0x65, 0x12, 0x38, 0x0a, 0x0a, 0x6d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e, 0x65,
+	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x66, 0x69,
+	0x6e, 0x64, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x2e, 0x4d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e,
+	0x65, 0x52, 0x0a, 0x6d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x32, 0x62, 0x0a,
+	0x10, 0x46, 0x69, 0x6e, 0x64, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x4e, 0x0a, 0x09, 0x46, 0x69, 0x6e, 0x64, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x12, 0x1f,
+	0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x66, 0x69, 0x6e, 0x64, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x2e, 0x46,
+	0x69, 0x6e, 0x64, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x20, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x66, 0x69, 0x6e, 0x64, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x2e,
+	0x46, 0x69, 0x6e, 0x64, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_proto_findbuild_proto_rawDescOnce sync.Once
+	file_proto_findbuild_proto_rawDescData = file_proto_findbuild_proto_rawDesc
+)
+
+func file_proto_findbuild_proto_rawDescGZIP() []byte {
+	file_proto_findbuild_proto_rawDescOnce.Do(func() {
+		file_proto_findbuild_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_findbuild_proto_rawDescData)
+	})
+	return file_proto_findbuild_proto_rawDescData
+}
+
+var file_proto_findbuild_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_proto_findbuild_proto_goTypes = []interface{}{
+	(*FindBuildRequest)(nil),  // 0: cos_findbuild.FindBuildRequest
+	(*Milestone)(nil),         // 1: cos_findbuild.Milestone
+	(*FindBuildResponse)(nil), // 2: cos_findbuild.FindBuildResponse
+}
+var file_proto_findbuild_proto_depIdxs = []int32{
+	1, // 0: cos_findbuild.FindBuildResponse.milestones:type_name -> cos_findbuild.Milestone
+	0, // 1: cos_findbuild.FindBuildService.FindBuild:input_type -> cos_findbuild.FindBuildRequest
+	2, // 2: cos_findbuild.FindBuildService.FindBuild:output_type -> cos_findbuild.FindBuildResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_proto_findbuild_proto_init() }
+func file_proto_findbuild_proto_init() {
+	if File_proto_findbuild_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_findbuild_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FindBuildRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_findbuild_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Milestone); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_findbuild_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FindBuildResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_findbuild_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_findbuild_proto_goTypes,
+		DependencyIndexes: file_proto_findbuild_proto_depIdxs,
+		MessageInfos:      file_proto_findbuild_proto_msgTypes,
+	}.Build()
+	File_proto_findbuild_proto = out.File
+	file_proto_findbuild_proto_rawDesc = nil
+	file_proto_findbuild_proto_goTypes = nil
+	file_proto_findbuild_proto_depIdxs = nil
+}
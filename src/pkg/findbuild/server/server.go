@@ -0,0 +1,374 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server exposes findbuild as a gRPC service and an HTTP endpoint,
+// so dashboards can annotate CLs with the first COS build containing them.
+// Callers are authenticated, responses are cached, and each caller is
+// subject to its own quota.
+package server
+
+//go:generate protoc --go_out=:./pb --go-grpc_out=:./pb -I. proto/findbuild.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild/server/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultTimeout = 5 * time.Minute
+
+// Messages are written to the Logger set by utils.SetLogger
+var log = utils.Log
+
+// Config configures a Server. HTTPClient, GerritHost, GitilesHost and
+// ManifestRepo are required.
+type Config struct {
+	// Authorized client with Gerrit scope used for every Gerrit and Gitiles
+	// request
+	HTTPClient *http.Client
+	// HostHTTPClient returns the authorized client used for the requests to
+	// a Gerrit or Git on Borg host requiring different credentials than
+	// HTTPClient, as in findbuild.BuildRequest. HTTPClient is used for every
+	// host if nil.
+	HostHTTPClient func(host string) (*http.Client, error)
+	// Default Gerrit instance of requests that do not set one, ex.
+	// "https://cos-review.googlesource.com"
+	GerritHost string
+	// Gerrit instance searched for CLs not found on GerritHost, ex.
+	// "https://chromium-review.googlesource.com". It is not used for requests
+	// setting their Gerrit instance, and there is no fallback if empty.
+	FallbackGerritHost string
+	// Default Git on Borg instance and manifest repository of requests that
+	// do not set them, ex. "cos.googlesource.com" and "cos/manifest-snapshots"
+	GitilesHost  string
+	ManifestRepo string
+	// Cache stores responses, manifest tags and manifest files. Nothing is
+	// cached if nil.
+	Cache findbuild.Cache
+	// GerritClient and GitilesClient create the clients used to query Gerrit
+	// and Git on Borg instances instead of clients sending requests with
+	// HTTPClient, as in findbuild.BuildRequest.
+	GerritClient  func(host string) (findbuild.GerritService, error)
+	GitilesClient func(remoteURL string) (utils.GitilesService, error)
+	// Mapping describes how the CLs of every Gerrit instance map to the
+	// repositories and release branches of the manifest repositories.
+	// Defaults to findbuild.DefaultMappingConfig.
+	Mapping *findbuild.MappingConfig
+	// Hooks receives the progress of the searches of every request, ex. to
+	// record their latency breakdown. Nothing is reported if nil.
+	Hooks findbuild.Hooks
+	// CallerRequestsPerSecond limits the rate of requests of each caller, with
+	// bursts of up to CallerBurst requests. There is no quota if not positive.
+	CallerRequestsPerSecond float64
+	CallerBurst             int
+	// Authenticate identifies the caller of a request, and returns an error
+	// if the request must be rejected. HTTP requests carry their
+	// Authorization header as "authorization" metadata. Defaults to
+	// identifying callers by the address of the peer, without authentication.
+	Authenticate func(ctx context.Context) (string, error)
+	// Timeout bounds the search of a single CL. Defaults to 5 minutes.
+	Timeout time.Duration
+}
+
+// Server implements the FindBuildService gRPC service, and serves the same
+// requests over HTTP.
+type Server struct {
+	pb.UnimplementedFindBuildServiceServer
+	cfg Config
+	// clients shares the clients of each Gerrit and Git on Borg host
+	// between requests
+	clients *findbuild.ClientPool
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewServer creates a Server from cfg.
+func NewServer(cfg *Config) (*Server, error) {
+	if cfg == nil || cfg.HTTPClient == nil || cfg.GerritHost == "" || cfg.GitilesHost == "" || cfg.ManifestRepo == "" {
+		return nil, errors.New("failed to create findbuild server: HTTPClient, GerritHost, GitilesHost and ManifestRepo are required")
+	}
+	s := &Server{
+		cfg:      *cfg,
+		clients:  findbuild.NewClientPool(cfg.HTTPClient, cfg.HostHTTPClient),
+		limiters: make(map[string]*rate.Limiter),
+	}
+	if s.cfg.Authenticate == nil {
+		s.cfg.Authenticate = peerAddress
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+	if s.cfg.CallerBurst < 1 {
+		s.cfg.CallerBurst = 1
+	}
+	return s, nil
+}
+
+// peerAddress returns the network address of the caller of a request.
+func peerAddress(ctx context.Context) (string, error) {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String(), nil
+	}
+	return "", nil
+}
+
+// TokenAuthenticator returns a Config.Authenticate function accepting the
+// requests whose "authorization" metadata is "Bearer <token>" for a token of
+// tokens, and identifying their caller as the name the token is mapped to.
+func TokenAuthenticator(tokens map[string]string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			token := strings.TrimPrefix(value, "Bearer ")
+			if token == value {
+				continue
+			}
+			if caller, ok := tokens[token]; ok {
+				return caller, nil
+			}
+		}
+		return "", errors.New("missing or invalid bearer token")
+	}
+}
+
+// allow reports whether caller has quota left for another request.
+func (s *Server) allow(caller string) bool {
+	if s.cfg.CallerRequestsPerSecond <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limiter, ok := s.limiters[caller]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(s.cfg.CallerRequestsPerSecond), s.cfg.CallerBurst)
+		s.limiters[caller] = limiter
+	}
+	return limiter.Allow()
+}
+
+// withDefaults returns a copy of req with the server defaults filled in, and
+// the fallback Gerrit instance of the request.
+func (s *Server) withDefaults(req *pb.FindBuildRequest) (*pb.FindBuildRequest, string) {
+	out := &pb.FindBuildRequest{
+		Cl:             req.GetCl(),
+		GerritHost:     req.GetGerritHost(),
+		GitilesHost:    req.GetGitilesHost(),
+		ManifestRepo:   req.GetManifestRepo(),
+		AllMilestones:  req.GetAllMilestones(),
+		UpstreamKernel: req.GetUpstreamKernel(),
+	}
+	fallback := ""
+	if out.GerritHost == "" {
+		out.GerritHost = s.cfg.GerritHost
+		fallback = s.cfg.FallbackGerritHost
+	}
+	if out.GitilesHost == "" {
+		out.GitilesHost = s.cfg.GitilesHost
+	}
+	if out.ManifestRepo == "" {
+		out.ManifestRepo = s.cfg.ManifestRepo
+	}
+	return out, fallback
+}
+
+// responseCacheKey identifies the response to a request in the cache. The
+// first build containing a CL never changes once it is found, so responses
+// never need to be invalidated. Requests for all milestones are never
+// cached, since later cherry-picks add milestones.
+func responseCacheKey(req *pb.FindBuildRequest, fallback string) string {
+	return fmt.Sprintf("response:%s|%s:%s/%s:%s:upstream=%t", req.GerritHost, fallback, req.GitilesHost, req.ManifestRepo,
+		req.Cl, req.UpstreamKernel)
+}
+
+// FindBuild implements pb.FindBuildServiceServer.
+func (s *Server) FindBuild(ctx context.Context, req *pb.FindBuildRequest) (*pb.FindBuildResponse, error) {
+	caller, err := s.cfg.Authenticate(ctx)
+	if err != nil {
+		log.Warnf("FindBuild: rejected unauthenticated request: %v", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if req.GetCl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "cl must be set")
+	}
+	if !s.allow(caller) {
+		log.Warnf("FindBuild: caller %q exceeded its quota", caller)
+		return nil, status.Errorf(codes.ResourceExhausted, "quota exceeded for caller %q", caller)
+	}
+	req, fallback := s.withDefaults(req)
+	cacheKey := responseCacheKey(req, fallback)
+	cacheable := s.cfg.Cache != nil && !req.AllMilestones
+	if cacheable {
+		if data, ok := s.cfg.Cache.Get(cacheKey); ok {
+			resp := &pb.FindBuildResponse{}
+			err := proto.Unmarshal(data, resp)
+			if err == nil {
+				return resp, nil
+			}
+			log.Errorf("FindBuild: ignoring malformed cache entry %s: %v", cacheKey, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	buildReq := &findbuild.BuildRequest{
+		ClientPool:     s.clients,
+		GerritHost:     req.GerritHost,
+		GitilesHost:    req.GitilesHost,
+		ManifestRepo:   req.ManifestRepo,
+		CL:             req.Cl,
+		AllMilestones:  req.AllMilestones,
+		UpstreamKernel: req.UpstreamKernel,
+		GerritClient:   s.cfg.GerritClient,
+		GitilesClient:  s.cfg.GitilesClient,
+		Cache:          s.cfg.Cache,
+		Mapping:        s.cfg.Mapping,
+		Hooks:          s.cfg.Hooks,
+	}
+	res, clErr := findbuild.FindBuild(ctx, buildReq)
+	if clErr != nil && clErr.HTTPCode() == "404" && fallback != "" {
+		log.Debugf("FindBuild: CL %s not found on %s, using fallback %s", req.Cl, req.GerritHost, fallback)
+		buildReq.GerritHost = fallback
+		res, clErr = findbuild.FindBuild(ctx, buildReq)
+	}
+	if clErr != nil {
+		return nil, status.Error(grpcCode(clErr), clErr.Error())
+	}
+	resp := &pb.FindBuildResponse{
+		BuildNum: res.BuildNum,
+		ClNum:    res.CLNum,
+		Release:  res.Release,
+	}
+	for _, milestone := range res.Milestones {
+		resp.Milestones = append(resp.Milestones, &pb.Milestone{
+			Release:  milestone.Release,
+			BuildNum: milestone.BuildNum,
+			ClNum:    milestone.CLNum,
+		})
+	}
+	if cacheable {
+		data, err := proto.Marshal(resp)
+		if err != nil {
+			log.Errorf("FindBuild: failed to encode cache entry %s: %v", cacheKey, err)
+		} else {
+			s.cfg.Cache.Set(cacheKey, data)
+		}
+	}
+	return resp, nil
+}
+
+// httpAddr is the net.Addr of the caller of an HTTP request.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "tcp" }
+func (a httpAddr) String() string  { return string(a) }
+
+// ServeHTTP serves FindBuild requests over HTTP. The fields of the request
+// are read from the query parameters of the same name, ex.
+// "/findbuild?cl=3280&all_milestones=true", and the response is written as
+// JSON.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	req := &pb.FindBuildRequest{
+		Cl:           query.Get("cl"),
+		GerritHost:   query.Get("gerrit_host"),
+		GitilesHost:  query.Get("gitiles_host"),
+		ManifestRepo: query.Get("manifest_repo"),
+	}
+	for name, dest := range map[string]*bool{"all_milestones": &req.AllMilestones, "upstream_kernel": &req.UpstreamKernel} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid value %q for %s", value, name), http.StatusBadRequest)
+				return
+			}
+			*dest = parsed
+		}
+	}
+	ctx := peer.NewContext(r.Context(), &peer.Peer{Addr: httpAddr(r.RemoteAddr)})
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", auth))
+	}
+	resp, err := s.FindBuild(ctx, req)
+	if err != nil {
+		st := status.Convert(err)
+		http.Error(w, st.Message(), httpCode(st.Code()))
+		return
+	}
+	data, err := protojson.Marshal(resp)
+	if err != nil {
+		log.Errorf("ServeHTTP: failed to encode response: %v", err)
+		http.Error(w, utils.InternalServerError.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// grpcCode converts the HTTP code of a ChangelogError into a gRPC status code.
+func grpcCode(err utils.ChangelogError) codes.Code {
+	switch err.HTTPCode() {
+	case "400", "409":
+		return codes.InvalidArgument
+	case "401":
+		return codes.Unauthenticated
+	case "403":
+		return codes.PermissionDenied
+	case "404":
+		return codes.NotFound
+	case "429":
+		return codes.ResourceExhausted
+	case "504":
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// httpCode converts a gRPC status code into an HTTP status code.
+func httpCode(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
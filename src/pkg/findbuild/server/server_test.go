@@ -0,0 +1,377 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild/server/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	testGerritHost   = "https://cos-review.googlesource.com"
+	testFallbackHost = "https://chromium-review.googlesource.com"
+	testGitilesHost  = "cos.googlesource.com"
+	testManifestRepo = "cos/manifest-snapshots"
+)
+
+type callerKey struct{}
+
+func testCaller(ctx context.Context) (string, error) {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller, nil
+}
+
+// newTestServer returns a server backed by fake services with builds 1.0.0
+// and 2.0.0. CL 7 is on the default Gerrit instance and CL 8 on the fallback
+// instance, and both are included from build 2.0.0. It also returns a
+// counter of the Gerrit clients created by the server.
+func newTestServer(t *testing.T, cfg *Config) (*Server, *int) {
+	baseTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := fakes.NewGitiles()
+	g.Commits[testManifestRepo] = []*git.Commit{
+		{Id: "m1", Committer: &git.Commit_User{Time: timestamppb.New(baseTime)}},
+		{Id: "m2", Parents: []string{"m1"}, Committer: &git.Commit_User{Time: timestamppb.New(baseTime.AddDate(0, 0, 1))}},
+	}
+	g.Refs[testManifestRepo] = map[string]string{"refs/heads/master": "m2"}
+	g.Commits["cos/overlays"] = []*git.Commit{{Id: "o1"}, {Id: "o2", Parents: []string{"o1"}}}
+	primary, fallback := fakes.NewGerrit(), fakes.NewGerrit()
+	for i, sha := range []string{"o1", "o2"} {
+		buildNum := fmt.Sprintf("%d.0.0", i+1)
+		g.Files[fakes.GitilesFile{Project: testManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays" path="src/overlays" revision="` + sha + `"/>
+</manifest>`
+		primary.Tags[testManifestRepo] = append(primary.Tags[testManifestRepo], gerrit.TagInfo{Ref: "refs/tags/" + buildNum, Revision: fmt.Sprintf("m%d", i+1)})
+	}
+	primary.Branches[testManifestRepo] = []gerrit.BranchInfo{{Ref: "refs/heads/master", Revision: "m2"}}
+	for i, instance := range []*fakes.Gerrit{primary, fallback} {
+		instance.Changes = []gerrit.ChangeInfo{{
+			Number:          7 + i,
+			Project:         "cos/overlays",
+			Branch:          "master",
+			Status:          "MERGED",
+			CurrentRevision: "o2",
+			Submitted:       &gerrit.Timestamp{Time: baseTime.Add(12 * time.Hour)},
+		}}
+	}
+	var mu sync.Mutex
+	clients := 0
+	cfg.HTTPClient = http.DefaultClient
+	cfg.GerritHost = testGerritHost
+	cfg.GitilesHost = testGitilesHost
+	cfg.ManifestRepo = testManifestRepo
+	cfg.GitilesClient = func(string) (utils.GitilesService, error) { return g, nil }
+	cfg.GerritClient = func(host string) (findbuild.GerritService, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		clients++
+		if host == testFallbackHost {
+			return fallback, nil
+		}
+		return primary, nil
+	}
+	if cfg.Authenticate == nil {
+		cfg.Authenticate = testCaller
+	}
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return s, &clients
+}
+
+func TestNewServer(t *testing.T) {
+	tests := map[string]struct {
+		cfg         *Config
+		expectedErr bool
+	}{
+		"Nil Config": {
+			cfg:         nil,
+			expectedErr: true,
+		},
+		"Missing Gerrit Host": {
+			cfg:         &Config{HTTPClient: http.DefaultClient, GitilesHost: testGitilesHost, ManifestRepo: testManifestRepo},
+			expectedErr: true,
+		},
+		"Valid Config": {
+			cfg: &Config{HTTPClient: http.DefaultClient, GerritHost: testGerritHost, GitilesHost: testGitilesHost, ManifestRepo: testManifestRepo},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewServer(test.cfg); (err != nil) != test.expectedErr {
+				t.Errorf("expected error: %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestFindBuild(t *testing.T) {
+	tests := map[string]struct {
+		req          *pb.FindBuildRequest
+		expected     *pb.FindBuildResponse
+		expectedCode codes.Code
+	}{
+		"Default Gerrit": {
+			req:          &pb.FindBuildRequest{Cl: "7"},
+			expected:     &pb.FindBuildResponse{BuildNum: "2.0.0", ClNum: "7", Release: "master"},
+			expectedCode: codes.OK,
+		},
+		"Fallback Gerrit": {
+			req:          &pb.FindBuildRequest{Cl: "8"},
+			expected:     &pb.FindBuildResponse{BuildNum: "2.0.0", ClNum: "8", Release: "master"},
+			expectedCode: codes.OK,
+		},
+		"Explicit Gerrit Without Fallback": {
+			req:          &pb.FindBuildRequest{Cl: "8", GerritHost: testGerritHost},
+			expectedCode: codes.NotFound,
+		},
+		"Not Found": {
+			req:          &pb.FindBuildRequest{Cl: "9"},
+			expectedCode: codes.NotFound,
+		},
+		"Missing CL": {
+			req:          &pb.FindBuildRequest{},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+	s, _ := newTestServer(t, &Config{FallbackGerritHost: testFallbackHost})
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := s.FindBuild(context.Background(), test.req)
+			if code := status.Code(err); code != test.expectedCode {
+				t.Fatalf("expected code %v, got %v", test.expectedCode, err)
+			}
+			if diff := cmp.Diff(test.expected, resp, protocmp.Transform()); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindBuildMapping(t *testing.T) {
+	mapping := &findbuild.MappingConfig{
+		ReleaseRules: []findbuild.ReleaseRule{{Host: testFallbackHost, Project: "cos/overlays", BranchPattern: "(.*)-cos", DefaultRelease: "release-R1"}},
+	}
+	s, _ := newTestServer(t, &Config{FallbackGerritHost: testFallbackHost, Mapping: mapping})
+	resp, err := s.FindBuild(context.Background(), &pb.FindBuildRequest{Cl: "7"})
+	if err != nil {
+		t.Fatalf("FindBuild failed for CL on the default instance: %v", err)
+	}
+	if resp.Release != "master" {
+		t.Errorf("expected release master for CL on the default instance, got %s", resp.Release)
+	}
+	// The master branch of CL 8 maps to release-R1 on the fallback instance,
+	// which is not a branch of the manifest repository
+	if _, err := s.FindBuild(context.Background(), &pb.FindBuildRequest{Cl: "8"}); status.Code(err) == codes.OK {
+		t.Error("expected FindBuild to fail for CL mapped to a missing release branch")
+	}
+}
+
+func TestFindBuildCache(t *testing.T) {
+	tests := map[string]struct {
+		req          *pb.FindBuildRequest
+		expectCached bool
+	}{
+		"Cached Response": {
+			req:          &pb.FindBuildRequest{Cl: "7"},
+			expectCached: true,
+		},
+		"Uncached Milestones": {
+			req:          &pb.FindBuildRequest{Cl: "7", AllMilestones: true},
+			expectCached: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, clients := newTestServer(t, &Config{Cache: findbuild.NewMemoryCache(0, nil)})
+			// counts holds the number of Gerrit clients created after each request
+			var counts []int
+			for i := 0; i < 2; i++ {
+				resp, err := s.FindBuild(context.Background(), test.req)
+				if err != nil {
+					t.Fatalf("FindBuild %d failed: %v", i, err)
+				}
+				if resp.GetBuildNum() != "2.0.0" {
+					t.Errorf("FindBuild %d: expected build 2.0.0, got %s", i, resp.GetBuildNum())
+				}
+				counts = append(counts, *clients)
+			}
+			if cached := counts[1] == counts[0]; cached != test.expectCached {
+				t.Errorf("expected cached response: %t, got Gerrit clients %v", test.expectCached, counts)
+			}
+		})
+	}
+}
+
+func TestFindBuildAuthentication(t *testing.T) {
+	tests := map[string]struct {
+		authorization string
+		expectedCode  codes.Code
+	}{
+		"Valid Token": {
+			authorization: "Bearer secret",
+			expectedCode:  codes.OK,
+		},
+		"Invalid Token": {
+			authorization: "Bearer guess",
+			expectedCode:  codes.Unauthenticated,
+		},
+		"Missing Bearer": {
+			authorization: "secret",
+			expectedCode:  codes.Unauthenticated,
+		},
+		"Missing Token": {
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+	s, _ := newTestServer(t, &Config{Authenticate: TokenAuthenticator(map[string]string{"secret": "dashboard"})})
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.authorization))
+			}
+			if _, err := s.FindBuild(ctx, &pb.FindBuildRequest{Cl: "7"}); status.Code(err) != test.expectedCode {
+				t.Errorf("expected code %v, got %v", test.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestFindBuildQuota(t *testing.T) {
+	s, _ := newTestServer(t, &Config{CallerRequestsPerSecond: 0.001, CallerBurst: 2})
+	req := &pb.FindBuildRequest{Cl: "7"}
+	tests := []struct {
+		caller       string
+		expectedCode codes.Code
+	}{
+		{caller: "dashboard", expectedCode: codes.OK},
+		{caller: "dashboard", expectedCode: codes.OK},
+		{caller: "dashboard", expectedCode: codes.ResourceExhausted},
+		{caller: "release-bot", expectedCode: codes.OK},
+	}
+	for i, test := range tests {
+		ctx := context.WithValue(context.Background(), callerKey{}, test.caller)
+		if _, err := s.FindBuild(ctx, req); status.Code(err) != test.expectedCode {
+			t.Errorf("request %d from %s: expected code %v, got %v", i, test.caller, test.expectedCode, err)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		target        string
+		authorization string
+		expectedCode  int
+		expected      *pb.FindBuildResponse
+	}{
+		"Found": {
+			method:        http.MethodGet,
+			target:        "/findbuild?cl=7",
+			authorization: "Bearer secret",
+			expectedCode:  http.StatusOK,
+			expected:      &pb.FindBuildResponse{BuildNum: "2.0.0", ClNum: "7", Release: "master"},
+		},
+		"Not Found": {
+			method:        http.MethodGet,
+			target:        "/findbuild?cl=9",
+			authorization: "Bearer secret",
+			expectedCode:  http.StatusNotFound,
+		},
+		"Invalid Flag": {
+			method:        http.MethodGet,
+			target:        "/findbuild?cl=7&all_milestones=maybe",
+			authorization: "Bearer secret",
+			expectedCode:  http.StatusBadRequest,
+		},
+		"Unauthenticated": {
+			method:       http.MethodGet,
+			target:       "/findbuild?cl=7",
+			expectedCode: http.StatusUnauthorized,
+		},
+		"Wrong Method": {
+			method:        http.MethodPost,
+			target:        "/findbuild?cl=7",
+			authorization: "Bearer secret",
+			expectedCode:  http.StatusMethodNotAllowed,
+		},
+	}
+	s, _ := newTestServer(t, &Config{Authenticate: TokenAuthenticator(map[string]string{"secret": "dashboard"})})
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != test.expectedCode {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedCode, w.Code, w.Body.String())
+			}
+			if test.expected == nil {
+				return
+			}
+			got := &pb.FindBuildResponse{}
+			if err := protojson.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+			}
+			if diff := cmp.Diff(test.expected, got, protocmp.Transform()); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPCode(t *testing.T) {
+	tests := map[string]struct {
+		err      utils.ChangelogError
+		expected int
+	}{
+		"Forbidden":     {err: utils.ForbiddenError, expected: http.StatusForbidden},
+		"Timeout":       {err: utils.TimeoutError, expected: http.StatusGatewayTimeout},
+		"CL Not Found":  {err: utils.CLNotFound("3280"), expected: http.StatusNotFound},
+		"CL Not Unique": {err: utils.CLNotUnique("I3280", nil, testGerritHost), expected: http.StatusBadRequest},
+		"Internal":      {err: utils.InternalServerError, expected: http.StatusInternalServerError},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if code := httpCode(grpcCode(test.err)); code != test.expected {
+				t.Errorf("expected status %d, got %d", test.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// upstreamFooterRe matches the footers that kernel backports use to reference
+// the upstream commit they were picked from, ex.
+// "(cherry picked from commit <SHA>)", "commit <SHA> upstream." and
+// "[ Upstream commit <SHA> ]". The commit SHA is captured.
+var upstreamFooterRe = regexp.MustCompile(`(?im)(?:cherry picked from commit|upstream commit|^commit)\s+([0-9a-f]{40})\b`)
+
+// backportsUpstream reports whether a commit message has a footer
+// referencing the upstream commit sha.
+func backportsUpstream(message, sha string) bool {
+	for _, matches := range upstreamFooterRe.FindAllStringSubmatch(message, -1) {
+		if matches[1] == sha {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamBackports retrieves the merged CLs backporting an upstream commit,
+// ex. to the COS kernel, ordered by submission time.
+func upstreamBackports(ctx context.Context, client GerritService, sha, instanceURL string) ([]gerrit.ChangeInfo, utils.ChangelogError) {
+	log.Debugf("Retrieving backports of upstream commit %s from Gerrit", sha)
+	sha = strings.ToLower(sha)
+	if len(sha) != fullSHALength {
+		log.Errorf("upstreamBackports: %q is not a full commit SHA", sha)
+		return nil, utils.CLNotFound(sha)
+	}
+	queryOptions := &gerrit.QueryChangeOptions{}
+	queryOptions.Query = []string{fmt.Sprintf("message:%s status:merged", sha)}
+	queryOptions.AdditionalFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT"}
+	changes, _, err := client.QueryChanges(queryOptions)
+	if err != nil {
+		log.Errorf("upstreamBackports: error retrieving changes referencing %s:\n%v", sha, err)
+		if ctx.Err() != nil {
+			return nil, utils.TimeoutError
+		}
+		if utils.GerritErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
+		}
+		return nil, utils.InternalServerError
+	}
+	var output []gerrit.ChangeInfo
+	for _, change := range *changes {
+		if change.Submitted == nil || !backportsUpstream(change.Revisions[change.CurrentRevision].Commit.Message, sha) {
+			continue
+		}
+		output = append(output, change)
+	}
+	if len(output) == 0 {
+		log.Errorf("upstreamBackports: no backport of upstream commit %s found on %s", sha, instanceURL)
+		return nil, utils.CLNotFound(sha)
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].Submitted.Time.Before(output[j].Submitted.Time)
+	})
+	return output, nil
+}
+
+// getUpstreamCLData retrieves the search data of the earliest CL backporting
+// an upstream commit.
+func getUpstreamCLData(ctx context.Context, gerritClient GerritService, sha, instanceURL string, mapping *clMapping) (*clData, utils.ChangelogError) {
+	backports, clErr := upstreamBackports(ctx, gerritClient, sha, instanceURL)
+	if clErr != nil {
+		return nil, clErr
+	}
+	data := newCLData(backports[0], instanceURL, mapping)
+	data.UpstreamCommit = strings.ToLower(sha)
+	log.Debugf("Upstream commit %s backported by CL %s", sha, data.CLNum)
+	return data, nil
+}
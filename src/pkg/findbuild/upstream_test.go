@@ -0,0 +1,140 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"github.com/google/go-cmp/cmp"
+	"go.chromium.org/luci/common/proto/git"
+)
+
+var upstreamSHA = strings.Repeat("a", fullSHALength)
+
+// addKernel adds the third_party/kernel repository to the builds of
+// fakeServices. CL 301 backports upstreamSHA to the kernel, and is included
+// from build 2.0.0. CL 302 mentions upstreamSHA without backporting it.
+func addKernel(gr *fakes.Gerrit, g *fakes.Gitiles) {
+	g.Commits["third_party/kernel"] = []*git.Commit{
+		{Id: "k1"},
+		{Id: "k2", Parents: []string{"k1"}},
+	}
+	for i, sha := range []string{"o1", "o2", overlaysHead} {
+		kernelSHA := "k2"
+		if i == 0 {
+			kernelSHA = "k1"
+		}
+		buildNum := fmt.Sprintf("%d.0.0", i+1)
+		g.Files[fakes.GitilesFile{Project: externalManifestRepo, Committish: "refs/tags/" + buildNum, Path: "snapshot.xml"}] = strings.Replace(fakeSnapshot(sha),
+			"</manifest>", `  <project name="third_party/kernel" path="src/kernel" revision="`+kernelSHA+`"/>
+</manifest>`, 1)
+	}
+	for _, cl := range []struct {
+		number    int
+		project   string
+		branch    string
+		sha       string
+		message   string
+		submitted time.Time
+	}{
+		{301, "third_party/kernel", "cos-5.10", "k2", "net: fix overflow\n\ncommit " + upstreamSHA + " upstream.\n", fakeBaseTime.Add(14 * time.Hour)},
+		{302, "cos/overlays", "master", "o2", "Update kernel\n\nIncludes " + upstreamSHA + ".\n", fakeBaseTime.Add(time.Hour)},
+	} {
+		gr.Changes = append(gr.Changes, gerrit.ChangeInfo{
+			Number:          cl.number,
+			ChangeID:        fmt.Sprintf("I%d", cl.number),
+			Project:         cl.project,
+			Branch:          cl.branch,
+			Status:          "MERGED",
+			CurrentRevision: cl.sha,
+			Revisions:       map[string]gerrit.RevisionInfo{cl.sha: {Commit: gerrit.CommitInfo{Message: cl.message}}},
+			Submitted:       &gerrit.Timestamp{Time: cl.submitted},
+		})
+	}
+}
+
+func TestBackportsUpstream(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		expected bool
+	}{
+		"Cherry-Pick":     {message: "Fix\n\n(cherry picked from commit " + upstreamSHA + ")\n", expected: true},
+		"Stable Backport": {message: "Fix\n\ncommit " + upstreamSHA + " upstream.\n", expected: true},
+		"Upstream Commit": {message: "Fix\n\n[ Upstream commit " + upstreamSHA + " ]\n", expected: true},
+		"Mention":         {message: "Fix\n\nSimilar to " + upstreamSHA + ".\n"},
+		"Other Commit":    {message: "Fix\n\n(cherry picked from commit " + strings.Repeat("b", fullSHALength) + ")\n"},
+		"No Message":      {},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := backportsUpstream(test.message, upstreamSHA); got != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindBuildUpstreamKernel(t *testing.T) {
+	tests := map[string]struct {
+		cl            string
+		expected      *BuildResponse
+		expectedError string
+	}{
+		"Backported": {
+			cl:       upstreamSHA,
+			expected: &BuildResponse{BuildNum: "2.0.0", CLNum: "301", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Upper Case": {
+			cl:       strings.ToUpper(upstreamSHA),
+			expected: &BuildResponse{BuildNum: "2.0.0", CLNum: "301", SearchWindow: 5 * 24 * time.Hour, Release: "master", ManifestRepo: externalManifestRepo},
+		},
+		"Not Backported": {
+			cl:            strings.Repeat("b", fullSHALength),
+			expectedError: "404",
+		},
+		"Short SHA": {
+			cl:            upstreamSHA[:12],
+			expectedError: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gr, g := fakeServices()
+			addKernel(gr, g)
+			req := fakeRequest(gr, g)
+			req.CL = test.cl
+			req.UpstreamKernel = true
+			res, err := FindBuild(context.Background(), req)
+			if test.expectedError != "" {
+				if err == nil || err.HTTPCode() != test.expectedError {
+					t.Fatalf("expected error code %s, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBuild failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, res, ignoreCandidates); diff != "" {
+				t.Errorf("unexpected response, diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package imageanalyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/gcemetadata"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/toolbox"
+)
+
+// Analyzer finds one kind of difference of two mounted images, or the same
+// information for a single image. The built-in analyzers find the binary,
+// package, GCE metadata and toolbox differences. Downstream consumers add their
+// own (Ex: company specific hardening checks) with Register.
+type Analyzer interface {
+	// Name identifies the analyzer. The name of a registered analyzer is also
+	// its difference category, Ex: in the "junit" output and "-expected-diffs".
+	Name() string
+	// Analyze stores the difference of the images into imageDiff. A registered
+	// analyzer appends an output.CustomDiff named after the analyzer. image2 has
+	// no TempDir if a single image is analyzed.
+	Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error
+}
+
+var (
+	analyzersMu sync.Mutex
+	// Analyzers run by every analysis, in order
+	analyzers = []Analyzer{binaryAnalyzer{}, packageAnalyzer{}, gceMetadataAnalyzer{}, toolboxAnalyzer{}}
+)
+
+// Register adds an analyzer that runs after the built-in and previously
+// registered analyzers in every analysis. It is meant to be called from an
+// init function, and panics if the name is already used by an analyzer or a
+// difference category. Registered analyzers are not checkpointed, so they run
+// again on the mounted images when an analysis is resumed.
+func Register(analyzer Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	name := analyzer.Name()
+	if name == "" {
+		panic("imageanalyzer: Register called with an unnamed analyzer")
+	}
+	for _, registered := range analyzers {
+		if registered.Name() == name {
+			panic(fmt.Sprintf("imageanalyzer: analyzer name %v is already used", name))
+		}
+	}
+	// The category is added while analyzersMu is held, so that a name is never
+	// half registered by concurrent calls
+	if err := input.AddDiffCategory(name); err != nil {
+		panic(fmt.Sprintf("imageanalyzer: analyzer name %v is already used: %v", name, err))
+	}
+	analyzers = append(analyzers, analyzer)
+}
+
+// registeredAnalyzers returns a copy of the analyzers run by every analysis
+func registeredAnalyzers() []Analyzer {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	return append([]Analyzer{}, analyzers...)
+}
+
+// checkpointedAnalyzer is implemented by the built-in analyzers, which
+// checkpoint everything they read from the images under a single step. A
+// resumed analysis neither runs them again nor mounts the images for them.
+type checkpointedAnalyzer interface {
+	Analyzer
+	checkpointStep() string
+}
+
+// binaryAnalyzer finds the binary differences selected by the "-binary" flag
+type binaryAnalyzer struct{}
+
+func (binaryAnalyzer) Name() string { return "Binary" }
+
+func (binaryAnalyzer) checkpointStep() string { return "Analyzer:Binary" }
+
+func (a binaryAnalyzer) Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error {
+	var binaryDiff *binary.Differences
+	if err := flagInfo.Checkpoint.Run(a.checkpointStep(), &binaryDiff, func() (err error) {
+		binaryDiff, err = binary.Diff(ctx, image1, image2, flagInfo)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to get Binary Difference: %v", err)
+	}
+	imageDiff.BinaryDiff = binaryDiff
+	return nil
+}
+
+// packageAnalyzer finds the package differences
+type packageAnalyzer struct{}
+
+func (packageAnalyzer) Name() string { return "Package" }
+
+func (packageAnalyzer) checkpointStep() string { return "Analyzer:Package" }
+
+func (a packageAnalyzer) Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error {
+	packageLists := &struct{ Image1, Image2 []packagediff.Package }{}
+	if err := flagInfo.Checkpoint.Run(a.checkpointStep(), packageLists, func() (err error) {
+		if packageLists.Image1, err = packagediff.GetPackageInfo(image1, flagInfo); err != nil {
+			return fmt.Errorf("failed to get package info from image %v: %v", flagInfo.Image1, err)
+		}
+		if packageLists.Image2, err = packagediff.GetPackageInfo(image2, flagInfo); err != nil {
+			return fmt.Errorf("failed to get package info from image %v: %v", flagInfo.Image2, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	packageDiff, err := packagediff.Diff(packageLists.Image1, packageLists.Image2, flagInfo)
+	if err != nil {
+		return fmt.Errorf("failed to get package difference: %v", err)
+	}
+	imageDiff.PackageDiff = packageDiff
+	return nil
+}
+
+// gceMetadataAnalyzer finds the GCE metadata differences of -cos-cloud images
+type gceMetadataAnalyzer struct{}
+
+func (gceMetadataAnalyzer) Name() string { return "GCE-metadata" }
+
+func (gceMetadataAnalyzer) checkpointStep() string { return "Analyzer:GCE-metadata" }
+
+func (a gceMetadataAnalyzer) Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error {
+	gceMetadata := &struct{ Image1, Image2 *gcemetadata.Metadata }{}
+	if err := flagInfo.Checkpoint.Run(a.checkpointStep(), gceMetadata, func() (err error) {
+		if gceMetadata.Image1, err = gcemetadata.GetMetadata(ctx, image1); err != nil {
+			return fmt.Errorf("failed to get GCE metadata from image %v: %v", flagInfo.Image1, err)
+		}
+		if gceMetadata.Image2, err = gcemetadata.GetMetadata(ctx, image2); err != nil {
+			return fmt.Errorf("failed to get GCE metadata from image %v: %v", flagInfo.Image2, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	imageDiff.GCEMetadataDiff = gcemetadata.Diff(gceMetadata.Image1, gceMetadata.Image2)
+	return nil
+}
+
+// toolboxAnalyzer finds the toolbox and debug utility differences with -toolbox
+type toolboxAnalyzer struct{}
+
+func (toolboxAnalyzer) Name() string { return "Toolbox" }
+
+func (toolboxAnalyzer) checkpointStep() string { return "Analyzer:Toolbox" }
+
+func (a toolboxAnalyzer) Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error {
+	// The step is checkpointed even without -toolbox, so that it never needs the images
+	toolboxInfo := &struct{ Image1, Image2 *toolbox.Info }{}
+	if err := flagInfo.Checkpoint.Run(a.checkpointStep(), toolboxInfo, func() (err error) {
+		if !flagInfo.ToolboxSelected {
+			return nil
+		}
+		if toolboxInfo.Image1, err = toolbox.GetInfo(image1); err != nil {
+			return fmt.Errorf("failed to get toolbox info from image %v: %v", flagInfo.Image1, err)
+		}
+		if toolboxInfo.Image2, err = toolbox.GetInfo(image2); err != nil {
+			return fmt.Errorf("failed to get toolbox info from image %v: %v", flagInfo.Image2, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !flagInfo.ToolboxSelected {
+		return nil
+	}
+	imageDiff.ToolboxDiff = toolbox.Diff(toolboxInfo.Image1, toolboxInfo.Image2)
+	return nil
+}
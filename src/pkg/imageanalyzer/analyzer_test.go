@@ -0,0 +1,115 @@
+package imageanalyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// fakeAnalyzer reports a fixed custom difference
+type fakeAnalyzer struct {
+	name string
+}
+
+func (a fakeAnalyzer) Name() string { return a.name }
+
+func (a fakeAnalyzer) Analyze(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, imageDiff *output.ImageDiff) error {
+	imageDiff.CustomDiffs = append(imageDiff.CustomDiffs, &output.CustomDiff{Name: a.name, Diff: "sshd PermitRootLogin changed"})
+	return nil
+}
+
+// registers reports whether Register accepts an analyzer
+func registers(analyzer Analyzer) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	Register(analyzer)
+	return true
+}
+
+// test Register function
+func TestRegister(t *testing.T) {
+	savedAnalyzers := analyzers
+	defer func() {
+		analyzers = savedAnalyzers
+	}()
+	// Categories are never unregistered, so every run of the test registers a new name
+	name := fmt.Sprintf("Hardening%d", len(input.DiffCategories()))
+
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{name: name, want: true},
+		{name: name, want: false},
+		{name: "Binary", want: false},
+		{name: "Rootfs", want: false},
+		{name: "", want: false},
+	} {
+		if got := registers(fakeAnalyzer{name: tc.name}); got != tc.want {
+			t.Errorf("Register(%q) accepted: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	registered := registeredAnalyzers()
+	if last := registered[len(registered)-1]; last.Name() != name {
+		t.Errorf("last registered analyzer is %v, want %v", last.Name(), name)
+	}
+	if !utilities.InArray(name, input.DiffCategories()) {
+		t.Errorf("%v is not a difference category: %v", name, input.DiffCategories())
+	}
+	imageDiff := &output.ImageDiff{}
+	if err := registered[len(registered)-1].Analyze(context.Background(), &input.ImageInfo{}, &input.ImageInfo{}, &input.FlagInfo{}, imageDiff); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(imageDiff.CustomDiffs) != 1 || imageDiff.CustomDiffs[0].Name != name {
+		t.Errorf("got custom differences %v, want one %v difference", imageDiff.CustomDiffs, name)
+	}
+}
+
+// test Register function called concurrently with the same name
+func TestRegisterConcurrent(t *testing.T) {
+	savedAnalyzers := analyzers
+	defer func() {
+		analyzers = savedAnalyzers
+	}()
+	name := fmt.Sprintf("Concurrent%d", len(input.DiffCategories()))
+
+	const calls = 8
+	accepted := make(chan bool, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			accepted <- registers(fakeAnalyzer{name: name})
+		}()
+	}
+	wg.Wait()
+	close(accepted)
+	count := 0
+	for ok := range accepted {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Register of %v accepted %d times, want 1", name, count)
+	}
+	categories := 0
+	for _, category := range input.DiffCategories() {
+		if category == name {
+			categories++
+		}
+	}
+	if categories != 1 {
+		t.Errorf("%v is %d difference categories, want 1", name, categories)
+	}
+}
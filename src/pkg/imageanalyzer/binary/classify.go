@@ -0,0 +1,72 @@
+package binary
+
+import (
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+)
+
+// classifyDirectoryDiff counts each line of a directory difference into the
+// bucket of the path it refers to. A compressed directory counts once.
+// Input:
+//   (classify.Summary) summary - Counts of the differences of each bucket
+//   (string) category - Difference category of the lines (Ex: Rootfs)
+//   (string) diff - Directory difference, one entry per line
+//   ([][2]string) dirs - Pairs of directories the paths of the lines are relative to
+func classifyDirectoryDiff(summary classify.Summary, category, diff string, dirs ...[2]string) {
+	if diff == "" {
+		return
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		bucket := classify.Userspace
+		for _, pair := range dirs {
+			if path, ok := diffLinePath(line, pair[0], pair[1]); ok {
+				bucket = classify.Path(path)
+				break
+			}
+		}
+		summary.Add(bucket, category, 1)
+	}
+}
+
+// Classify counts the binary differences into the buckets of summary. Version
+// and BuildID identify the images and are not counted.
+// Input:
+//   (classify.Summary) summary - Counts of the differences of each bucket
+//   (string) rootfs1, rootfs2 - Paths to the mounted Rootfs partitions
+//   (string) stateful1, stateful2 - Paths to the mounted stateful partitions
+func (d *Differences) Classify(summary classify.Summary, rootfs1, rootfs2, stateful1, stateful2 string) {
+	if d == nil {
+		return
+	}
+	rootfs, stateful := [2]string{rootfs1, rootfs2}, [2]string{stateful1, stateful2}
+	classifyDirectoryDiff(summary, "Rootfs", d.Rootfs, rootfs)
+	classifyDirectoryDiff(summary, "Stateful-partition", d.Stateful, stateful)
+	classifyDirectoryDiff(summary, "Permissions", d.Permissions, rootfs, stateful)
+	for _, osConfigDiff := range d.OSConfigs {
+		if osConfigDiff != "" {
+			summary.Add(classify.Config, "OS-config", 1)
+		}
+	}
+	for _, configDiff := range d.StatefulConfigs {
+		if configDiff != "" {
+			summary.Add(classify.Config, "Stateful-partition", 1)
+		}
+	}
+	if d.DockerState != "" {
+		summary.Add(classify.Userspace, "Stateful-partition", len(strings.Split(d.DockerState, "\n")))
+	}
+	if d.PartitionStructure != "" {
+		summary.Add(classify.Firmware, "Partition-structure", 1)
+	}
+	if d.KernelConfigs != "" {
+		summary.Add(classify.Kernel, "Kernel-configs", 1)
+	}
+	summary.Add(classify.Kernel, "Kernel-command-line", len(d.KernelCommandLine))
+	if d.SysctlSettings != "" {
+		summary.Add(classify.Config, "Sysctl-settings", 1)
+	}
+}
@@ -0,0 +1,339 @@
+package binary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pureDiffHeader starts each per-file section of "diff -r --no-dereference" output
+const pureDiffHeader = "diff -r --no-dereference "
+
+// configFormat is a known config file format that can be compared structurally
+type configFormat struct {
+	// parse turns the contents of a config file into a list of canonical entries
+	parse func(content string) ([]string, error)
+	// ordered is true if the order of entries is meaningful (Ex: PAM stacks)
+	ordered bool
+}
+
+var (
+	sshConfigFormat = configFormat{parse: parseSSHConfig}
+	pamFormat       = configFormat{parse: parsePAMConfig, ordered: true}
+	nsswitchFormat  = configFormat{parse: parseNsswitchConfig}
+	jsonFormat      = configFormat{parse: parseJSONConfig}
+	tomlFormat      = configFormat{parse: parseTOMLConfig}
+)
+
+// findConfigFormat returns the format of a config file based on its path
+// Input:
+//   (string) path - Path to the config file
+// Output:
+//   (configFormat) format - The format of the config file
+//   (bool) ok - Flag to indicate the format is known
+func findConfigFormat(path string) (configFormat, bool) {
+	name := filepath.Base(path)
+	switch {
+	case name == "sshd_config" || name == "ssh_config":
+		return sshConfigFormat, true
+	case filepath.Base(filepath.Dir(path)) == "pam.d":
+		return pamFormat, true
+	case name == "nsswitch.conf":
+		return nsswitchFormat, true
+	case filepath.Ext(name) == ".json":
+		return jsonFormat, true
+	case filepath.Ext(name) == ".toml":
+		return tomlFormat, true
+	}
+	return configFormat{}, false
+}
+
+// stripComment removes the comment starting at the first "#" of a line that
+// is not inside a quoted value
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote == '"':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// configLines returns the lines of a config file with comments, blank lines,
+// and repeated whitespace removed
+func configLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(stripComment(line)); len(fields) > 0 {
+			lines = append(lines, strings.Join(fields, " "))
+		}
+	}
+	return lines
+}
+
+// sshMultiValueKeywords are the ssh config keywords that may be repeated, with
+// every occurrence taking effect
+var sshMultiValueKeywords = map[string]bool{
+	"acceptenv":       true,
+	"certificatefile": true,
+	"dynamicforward":  true,
+	"hostcertificate": true,
+	"hostkey":         true,
+	"identityfile":    true,
+	"listenaddress":   true,
+	"localforward":    true,
+	"port":            true,
+	"remoteforward":   true,
+	"sendenv":         true,
+	"setenv":          true,
+	"subsystem":       true,
+}
+
+// parseSSHConfig parses sshd_config and ssh_config files into their effective
+// entries. Keywords are case insensitive, and the first value obtained for a
+// keyword is used, so later occurrences of a keyword are dropped. Entries
+// following a "Match" or "Host" keyword are scoped to it, and since the first
+// matching block sets a keyword, blocks are numbered in order.
+func parseSSHConfig(content string) ([]string, error) {
+	var entries []string
+	scope := ""
+	blocks := 0
+	seen := make(map[string]bool)
+	for _, line := range configLines(content) {
+		keyword, value := line, ""
+		if startOfValue := strings.IndexAny(line, " ="); startOfValue >= 0 {
+			keyword, value = line[:startOfValue], strings.TrimSpace(strings.TrimLeft(line[startOfValue:], " ="))
+		}
+		keyword = strings.ToLower(keyword)
+		if keyword == "match" || keyword == "host" {
+			blocks++
+			scope = fmt.Sprintf("%s[%d] %s: ", keyword, blocks, value)
+			continue
+		}
+		if !sshMultiValueKeywords[keyword] {
+			if seen[scope+keyword] {
+				continue
+			}
+			seen[scope+keyword] = true
+		}
+		entries = append(entries, scope+keyword+" "+value)
+	}
+	return entries, nil
+}
+
+// parsePAMConfig parses files under /etc/pam.d. The order of the entries in a
+// PAM stack is meaningful, so only comments and whitespace are normalized.
+func parsePAMConfig(content string) ([]string, error) {
+	return configLines(content), nil
+}
+
+// parseNsswitchConfig parses nsswitch.conf. The order of databases is not
+// meaningful, but the order of sources for each database is.
+func parseNsswitchConfig(content string) ([]string, error) {
+	var entries []string
+	for _, line := range configLines(content) {
+		startOfSources := strings.Index(line, ":")
+		if startOfSources < 0 {
+			return nil, fmt.Errorf("invalid nsswitch.conf line %q", line)
+		}
+		entries = append(entries, strings.TrimSpace(line[:startOfSources])+": "+strings.TrimSpace(line[startOfSources+1:]))
+	}
+	return entries, nil
+}
+
+// flattenJSON appends one "path = value" entry for every leaf value of a
+// decoded json object
+func flattenJSON(path string, value interface{}, entries []string) []string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			entries = flattenJSON(path+"."+key, elem, entries)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			entries = flattenJSON(fmt.Sprintf("%s[%d]", path, i), elem, entries)
+		}
+	default:
+		valueBytes, _ := json.Marshal(v)
+		entries = append(entries, path+" = "+string(valueBytes))
+	}
+	return entries
+}
+
+// parseJSONConfig parses json files into one entry per leaf value
+func parseJSONConfig(content string) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse json: %v", err)
+	}
+	return flattenJSON("", value, nil), nil
+}
+
+// parseTOMLConfig parses toml files into one "table.key = value" entry per
+// key. Values are compared textually with whitespace normalized. The tables
+// of an array of tables are numbered in order, ex. "plugins[0].key = value".
+func parseTOMLConfig(content string) ([]string, error) {
+	var entries []string
+	table := ""
+	arrayTables := make(map[string]int)
+	for _, line := range configLines(content) {
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "[["), "]]"))
+			table = fmt.Sprintf("%s[%d]", name, arrayTables[name])
+			arrayTables[name]++
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+		startOfValue := strings.Index(line, "=")
+		if startOfValue < 0 {
+			return nil, fmt.Errorf("invalid toml line %q", line)
+		}
+		key := strings.TrimSpace(line[:startOfValue])
+		if table != "" {
+			key = table + "." + key
+		}
+		entries = append(entries, key+" = "+strings.TrimSpace(line[startOfValue+1:]))
+	}
+	return entries, nil
+}
+
+// entryDiff compares two lists of entries as sets
+// Input:
+//   ([]string) entries1 - Entries of image1
+//   ([]string) entries2 - Entries of image2
+// Output:
+//   (string) diff - Sorted entries only in entries1 ("< ") and only in entries2 ("> ")
+func entryDiff(entries1, entries2 []string) string {
+	set1, set2 := make(map[string]bool), make(map[string]bool)
+	for _, entry := range entries1 {
+		set1[entry] = true
+	}
+	for _, entry := range entries2 {
+		set2[entry] = true
+	}
+	var removed, added []string
+	for entry := range set1 {
+		if !set2[entry] {
+			removed = append(removed, "< "+entry)
+		}
+	}
+	for entry := range set2 {
+		if !set1[entry] {
+			added = append(added, "> "+entry)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return strings.Join(append(removed, added...), "\n")
+}
+
+// structuralConfigDiff compares two config files of a known format entry by entry
+// Input:
+//   (string) file1 - Path to the config file in image1
+//   (string) file2 - Path to the config file in image2
+// Output:
+//   (string) diff - Entries only in file1 ("< ") and only in file2 ("> ")
+//   (bool) ok - Flag to indicate the files were compared structurally.
+//               If false, the textual difference should be used instead
+func structuralConfigDiff(file1, file2 string) (string, bool) {
+	format, ok := findConfigFormat(file2)
+	if !ok {
+		return "", false
+	}
+	// Fall back to the textual difference on any read or parse failure so no
+	// difference is ever dropped silently.
+	content1, err := ioutil.ReadFile(file1)
+	if err != nil {
+		return "", false
+	}
+	content2, err := ioutil.ReadFile(file2)
+	if err != nil {
+		return "", false
+	}
+	entries1, err := format.parse(string(content1))
+	if err != nil {
+		return "", false
+	}
+	entries2, err := format.parse(string(content2))
+	if err != nil {
+		return "", false
+	}
+
+	if format.ordered {
+		if strings.Join(entries1, "\n") == strings.Join(entries2, "\n") {
+			return "", true
+		}
+		return "", false
+	}
+	return entryDiff(entries1, entries2), true
+}
+
+// semanticConfigDiff replaces the textual difference of config files with a
+// known format by their structural difference, so that reordered but
+// equivalent files are not reported as changed
+// Input:
+//   (string) rawDiff - Output of pureDiff on entry1 and entry2
+//   (string) entry1 - Path to the /etc entry in image1
+//   (string) entry2 - Path to the /etc entry in image2
+//   (bool) isDir - Flag to indicate the /etc entry is a directory
+// Output:
+//   (string) diff - The difference with known config formats compared structurally
+func semanticConfigDiff(rawDiff, entry1, entry2 string, isDir bool) string {
+	if rawDiff == "" {
+		return rawDiff
+	}
+	if !isDir {
+		if diff, ok := structuralConfigDiff(entry1, entry2); ok {
+			return diff
+		}
+		return rawDiff
+	}
+
+	// Split the output of "diff -r" into one section per file and compare
+	// each section's files structurally
+	var sections [][]string
+	for _, line := range strings.Split(rawDiff, "\n") {
+		if len(sections) == 0 || strings.HasPrefix(line, pureDiffHeader) || strings.HasPrefix(line, "Only in ") ||
+			strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "File ") || strings.HasPrefix(line, "Symbolic links ") {
+			sections = append(sections, []string{})
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+
+	var output []string
+	entry2Prefix := " " + filepath.Clean(entry2)
+	for _, section := range sections {
+		if header := section[0]; strings.HasPrefix(header, pureDiffHeader) {
+			files := strings.TrimPrefix(header, pureDiffHeader)
+			if startOfFile2 := strings.Index(files, entry2Prefix); startOfFile2 >= 0 {
+				if diff, ok := structuralConfigDiff(files[:startOfFile2], files[startOfFile2+1:]); ok {
+					if diff != "" {
+						output = append(output, header, diff)
+					}
+					continue
+				}
+			}
+		}
+		output = append(output, section...)
+	}
+	return strings.Join(output, "\n")
+}
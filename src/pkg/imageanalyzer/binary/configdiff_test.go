@@ -0,0 +1,132 @@
+package binary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigs creates a file with the same name and the given contents under
+// two temporary directories and returns their paths
+func writeConfigs(t *testing.T, name, content1, content2 string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	file1, file2 := filepath.Join(dir, "image1", name), filepath.Join(dir, "image2", name)
+	for file, content := range map[string]string{file1: content1, file2: content2} {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file1, file2
+}
+
+// test structuralConfigDiff function
+func TestStructuralConfigDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content1 string
+		content2 string
+		want     string
+		wantOk   bool
+	}{
+		{name: "ssh/sshd_config",
+			content1: "PermitRootLogin no\n# comment\nPasswordAuthentication no\n",
+			content2: "passwordauthentication   no\nPermitRootLogin no\n",
+			want:     "", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "PermitRootLogin no\nMatch User foo\n  X11Forwarding yes\n",
+			content2: "PermitRootLogin no\nMatch User foo\n  X11Forwarding no\n",
+			want:     "< match[1] User foo: x11forwarding yes\n> match[1] User foo: x11forwarding no", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "PermitRootLogin no\nPermitRootLogin yes\n",
+			content2: "PermitRootLogin yes\nPermitRootLogin no\n",
+			want:     "< permitrootlogin no\n> permitrootlogin yes", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "PermitRootLogin no\n",
+			content2: "PermitRootLogin yes\nPermitRootLogin no\n",
+			want:     "< permitrootlogin no\n> permitrootlogin yes", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "PermitRootLogin no\nPort 22\n",
+			content2: "PermitRootLogin no\nPort 22\nPort 2222\nPermitRootLogin yes\n",
+			want:     "> port 2222", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "Match User foo\n  X11Forwarding yes\nMatch User bar\n  X11Forwarding no\n",
+			content2: "Match User bar\n  X11Forwarding no\nMatch User foo\n  X11Forwarding yes\n",
+			want: "< match[1] User foo: x11forwarding yes\n< match[2] User bar: x11forwarding no\n" +
+				"> match[1] User bar: x11forwarding no\n> match[2] User foo: x11forwarding yes", wantOk: true},
+		{name: "ssh/sshd_config",
+			content1: "Banner \"#1\"\n",
+			content2: "Banner \"#2\"\n",
+			want:     "< banner \"#1\"\n> banner \"#2\"", wantOk: true},
+		{name: "nsswitch.conf",
+			content1: "passwd: files\nhosts: files dns\n",
+			content2: "hosts:   files dns\npasswd: files\n",
+			want:     "", wantOk: true},
+		{name: "nsswitch.conf",
+			content1: "hosts: files dns\n",
+			content2: "hosts: dns files\n",
+			want:     "< hosts: files dns\n> hosts: dns files", wantOk: true},
+		{name: "pam.d/sshd",
+			content1: "auth required pam_unix.so\n#comment\naccount required pam_unix.so\n",
+			content2: "auth   required pam_unix.so\naccount required pam_unix.so\n",
+			want:     "", wantOk: true},
+		{name: "pam.d/sshd",
+			content1: "auth required pam_unix.so\naccount required pam_unix.so\n",
+			content2: "account required pam_unix.so\nauth required pam_unix.so\n",
+			want:     "", wantOk: false},
+		{name: "docker/daemon.json",
+			content1: `{"log-driver": "json-file", "storage-driver": "overlay2"}`,
+			content2: `{"storage-driver":"overlay2","log-driver":"local"}`,
+			want:     "< .log-driver = \"json-file\"\n> .log-driver = \"local\"", wantOk: true},
+		{name: "containerd/config.toml",
+			content1: "[plugins.cri]\n  sandbox_image = \"pause\"\n  enable_selinux = false\n",
+			content2: "[plugins.cri]\nenable_selinux = false\nsandbox_image = \"pause\"\n",
+			want:     "", wantOk: true},
+		{name: "containerd/config.toml",
+			content1: "[plugins.cri]\n  sandbox_image = \"pause#1\" # image\n",
+			content2: "[plugins.cri]\n  sandbox_image = \"pause#2\"\n",
+			want:     "< plugins.cri.sandbox_image = \"pause#1\"\n> plugins.cri.sandbox_image = \"pause#2\"", wantOk: true},
+		{name: "containerd/config.toml",
+			content1: "[[mirrors]]\n  endpoint = \"a\"\n[[mirrors]]\n  endpoint = \"b\"\n",
+			content2: "[[mirrors]]\n  endpoint = \"a\"\n",
+			want:     "< mirrors[1].endpoint = \"b\"", wantOk: true},
+		{name: "docker/broken.json",
+			content1: `{`,
+			content2: `{}`,
+			want:     "", wantOk: false},
+		{name: "hostname",
+			content1: "a\n",
+			content2: "b\n",
+			want:     "", wantOk: false},
+	} {
+		file1, file2 := writeConfigs(t, tc.name, tc.content1, tc.content2)
+		got, ok := structuralConfigDiff(file1, file2)
+		if got != tc.want || ok != tc.wantOk {
+			t.Fatalf("structuralConfigDiff(%v) expected:\n%v, %v\ngot:\n%v, %v", tc.name, tc.want, tc.wantOk, got, ok)
+		}
+	}
+}
+
+// test semanticConfigDiff function
+func TestSemanticConfigDiff(t *testing.T) {
+	file1, file2 := writeConfigs(t, "ssh/sshd_config", "PermitRootLogin no\nUsePAM yes\n", "UsePAM yes\nPermitRootLogin no\n")
+	dir1, dir2 := filepath.Dir(file1), filepath.Dir(file2)
+	rawDiff := "diff -r --no-dereference " + file1 + " " + file2 + `
+1d0
+< PermitRootLogin no
+2a2
+> PermitRootLogin no
+Only in ` + dir1 + `: moduli`
+
+	want := "Only in " + dir1 + ": moduli"
+	if got := semanticConfigDiff(rawDiff, dir1, dir2, true); got != want {
+		t.Fatalf("semanticConfigDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+	if got := semanticConfigDiff("1d0\n< PermitRootLogin no", file1, file2, false); got != "" {
+		t.Fatalf("semanticConfigDiff expected empty difference, got:\n%v", got)
+	}
+}
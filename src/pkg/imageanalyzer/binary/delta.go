@@ -0,0 +1,174 @@
+package binary
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// DeltaSize stores the size of a binary delta between two versions of a file
+type DeltaSize struct {
+	Path       string // Path of the file relative to the root of the partition
+	DeltaBytes int64  // Size of the patch that turns image1's file into image2's
+	FileBytes  int64  // Size of image2's file
+}
+
+// differingFiles finds the pairs of regular files reported as different by
+// the output of "diff -rq"
+// Input:
+//   (string) diff - Output of the "diff -rq" command
+// Output:
+//   ([][2]string) files - Pairs of file paths from directory 1 and directory 2
+func differingFiles(diff string) [][2]string {
+	var files [][2]string
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "Files ") || !strings.HasSuffix(line, " differ") {
+			continue
+		}
+		paths := strings.TrimSuffix(strings.TrimPrefix(line, "Files "), " differ")
+		pair := strings.SplitN(paths, " and ", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		files = append(files, [2]string{pair[0], pair[1]})
+	}
+	return files
+}
+
+// fileStat gets the type and size of a file without following symbolic links.
+// The file is stat'ed through sudo since the mounted partitions hold files
+// only readable by root
+// Input:
+//   (context.Context) ctx - Context used to cancel the stat command
+//   (string) path - Path to the file
+// Output:
+//   (bool) regular - Flag to indicate the file is a regular file
+//   (int64) size - Size of the file in bytes
+func fileStat(ctx context.Context, path string) (bool, int64, error) {
+	out, err := exec.CommandContext(ctx, "sudo", "stat", "-c", "%s %F", path).Output()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to call stat on %v: %v", path, err)
+	}
+	return parseStat(string(out))
+}
+
+// parseStat parses the output of the "stat -c '%s %F'" command
+// Input:
+//   (string) out - Output of the stat command
+// Output:
+//   (bool) regular - Flag to indicate the file is a regular file
+//   (int64) size - Size of the file in bytes
+func parseStat(out string) (bool, int64, error) {
+	fields := strings.SplitN(strings.TrimSpace(out), " ", 2)
+	if len(fields) != 2 {
+		return false, 0, fmt.Errorf("unexpected stat output %q", out)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to parse size in stat output %q: %v", out, err)
+	}
+	regular := fields[1] == "regular file" || fields[1] == "regular empty file"
+	return regular, size, nil
+}
+
+// fileDeltaSize computes the size of the patch produced by the delta tool
+// for the pair of files
+// Input:
+//   (context.Context) ctx - Context used to cancel the delta tool
+//   (string) tool - Name of the delta tool ("bsdiff" or "xdelta3")
+//   (string) file1 - Path to the file in image1
+//   (string) file2 - Path to the file in image2
+// Output:
+//   (int64) size - Size of the patch in bytes
+func fileDeltaSize(ctx context.Context, tool, file1, file2 string) (int64, error) {
+	patchFile, err := ioutil.TempFile("", "delta")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary patch file: %v", err)
+	}
+	patchFile.Close()
+	defer os.Remove(patchFile.Name())
+
+	var cmd *exec.Cmd
+	switch tool {
+	case "bsdiff":
+		cmd = exec.CommandContext(ctx, "sudo", "bsdiff", file1, file2, patchFile.Name())
+	case "xdelta3":
+		cmd = exec.CommandContext(ctx, "sudo", "xdelta3", "-e", "-f", "-s", file1, file2, patchFile.Name())
+	default:
+		return 0, fmt.Errorf("unsupported delta tool %q", tool)
+	}
+	if _, err := cmd.Output(); err != nil {
+		return 0, fmt.Errorf("failed to call %v on %v and %v: %v", tool, file1, file2, err)
+	}
+	info, err := os.Stat(patchFile.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get info on patch file %v: %v", patchFile.Name(), err)
+	}
+	return info.Size(), nil
+}
+
+// deltaSizes computes the binary delta size of every regular file reported
+// as different in the "diff -rq" output of two directories
+// Input:
+//   (context.Context) ctx - Context used to cancel the delta tool
+//   (string) dir1 - Path to directory 1
+//   (string) dir2 - Path to directory 2
+//   (string) diff - Output of the "diff -rq" command on dir1 and dir2
+//   (string) tool - Name of the delta tool ("bsdiff" or "xdelta3")
+//   (int) threads - Maximum number of delta tool processes run at the same time
+// Output:
+//   ([]DeltaSize) sizes - Delta sizes sorted by path
+func deltaSizes(ctx context.Context, dir1, dir2, diff, tool string, threads int) ([]DeltaSize, error) {
+	pairs := differingFiles(diff)
+	pairSizes := make([]*DeltaSize, len(pairs))
+	errs := utilities.ParallelFor(threads, len(pairs), func(i int) error {
+		regular, fileBytes, err := fileStat(ctx, pairs[i][1])
+		if err != nil {
+			return fmt.Errorf("failed to get info on file %v: %v", pairs[i][1], err)
+		}
+		if !regular {
+			return nil
+		}
+		deltaBytes, err := fileDeltaSize(ctx, tool, pairs[i][0], pairs[i][1])
+		if err != nil {
+			return fmt.Errorf("failed to get delta size of %v: %v", pairs[i][1], err)
+		}
+		relPath, err := filepath.Rel(dir2, pairs[i][1])
+		if err != nil {
+			return fmt.Errorf("failed to get path of %v relative to %v: %v", pairs[i][1], dir2, err)
+		}
+		pairSizes[i] = &DeltaSize{Path: "/" + relPath, DeltaBytes: deltaBytes, FileBytes: fileBytes}
+		return nil
+	})
+	var sizes []DeltaSize
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		if pairSizes[i] != nil {
+			sizes = append(sizes, *pairSizes[i])
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].Path < sizes[j].Path })
+	return sizes, nil
+}
+
+// formatDeltaSizes returns a formated string of the binary delta sizes
+func formatDeltaSizes(sizes []DeltaSize) string {
+	if len(sizes) == 0 {
+		return ""
+	}
+	output := "Delta sizes (patch bytes / file bytes):\n"
+	for _, size := range sizes {
+		output += size.Path + ": " + strconv.FormatInt(size.DeltaBytes, 10) + " / " + strconv.FormatInt(size.FileBytes, 10) + "\n"
+	}
+	return output
+}
@@ -0,0 +1,75 @@
+package binary
+
+import (
+	"testing"
+)
+
+// test differingFiles function
+func TestDifferingFiles(t *testing.T) {
+	testDiff := `Files ../testdata/image1/rootfs/lib64/python.txt and ../testdata/image2/rootfs/lib64/python.txt differ
+Symbolic links ../testdata/image1/rootfs/lib/link and ../testdata/image2/rootfs/lib/link differ
+Only in ../testdata/image1/rootfs/usr/lib: usr-lib-image1
+Files ../testdata/image1/rootfs/proc/security/configs and ../testdata/image2/rootfs/proc/security/configs differ`
+
+	for _, tc := range []struct {
+		diff string
+		want [][2]string
+	}{
+		{diff: testDiff,
+			want: [][2]string{
+				{"../testdata/image1/rootfs/lib64/python.txt", "../testdata/image2/rootfs/lib64/python.txt"},
+				{"../testdata/image1/rootfs/proc/security/configs", "../testdata/image2/rootfs/proc/security/configs"}}},
+		{diff: "", want: nil},
+	} {
+		got := differingFiles(tc.diff)
+		if len(got) != len(tc.want) {
+			t.Fatalf("differingFiles expected:\n%v\ngot:\n%v", tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("differingFiles expected:\n%v\ngot:\n%v", tc.want, got)
+			}
+		}
+	}
+}
+
+// test formatDeltaSizes function
+func TestFormatDeltaSizes(t *testing.T) {
+	for _, tc := range []struct {
+		sizes []DeltaSize
+		want  string
+	}{
+		{sizes: []DeltaSize{{Path: "/bin/bash", DeltaBytes: 120, FileBytes: 1024}, {Path: "/lib64/libc.so", DeltaBytes: 5, FileBytes: 2048}},
+			want: "Delta sizes (patch bytes / file bytes):\n/bin/bash: 120 / 1024\n/lib64/libc.so: 5 / 2048\n"},
+		{sizes: nil, want: ""},
+	} {
+		if got := formatDeltaSizes(tc.sizes); got != tc.want {
+			t.Fatalf("formatDeltaSizes expected:\n%v\ngot:\n%v", tc.want, got)
+		}
+	}
+}
+
+// test parseStat function
+func TestParseStat(t *testing.T) {
+	for _, tc := range []struct {
+		out         string
+		wantRegular bool
+		wantSize    int64
+		wantErr     bool
+	}{
+		{out: "1024 regular file\n", wantRegular: true, wantSize: 1024},
+		{out: "0 regular empty file\n", wantRegular: true, wantSize: 0},
+		{out: "7 symbolic link\n", wantRegular: false, wantSize: 7},
+		{out: "4096 directory\n", wantRegular: false, wantSize: 4096},
+		{out: "size regular file\n", wantErr: true},
+		{out: "", wantErr: true},
+	} {
+		regular, size, err := parseStat(tc.out)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("parseStat(%q) expected error: %v, got: %v", tc.out, tc.wantErr, err)
+		}
+		if regular != tc.wantRegular || size != tc.wantSize {
+			t.Fatalf("parseStat(%q) expected: %v %v, got: %v %v", tc.out, tc.wantRegular, tc.wantSize, regular, size)
+		}
+	}
+}
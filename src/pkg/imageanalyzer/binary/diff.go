@@ -1,6 +1,7 @@
 package binary
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,8 +9,8 @@ import (
 	"sort"
 	"strings"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 // Global variables
@@ -28,12 +29,16 @@ type Differences struct {
 	Version            []string
 	BuildID            []string
 	Rootfs             string
+	RootfsDeltaSizes   []DeltaSize
 	OSConfigs          map[string]string
 	Stateful           string
+	StatefulConfigs    map[string]string
+	DockerState        string
 	PartitionStructure string
 	KernelConfigs      string
 	KernelCommandLine  map[string]string
 	SysctlSettings     string
+	Permissions        string
 }
 
 // versionDiff calculates the Version difference of two images
@@ -51,58 +56,118 @@ func (d *Differences) buildDiff(image1, image2 *input.ImageInfo) {
 }
 
 // rootfsDiff calculates the Root FS difference of two images
-func (d *Differences) rootfsDiff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
-	rootfsDiff, err := directoryDiff(image1.RootfsPartition3, image2.RootfsPartition3, "rootfs", flagInfo.Verbose, flagInfo.CompressRootfsSlice)
+func (d *Differences) rootfsDiff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	rawRootfsDiff, err := contentDirectoryDiff(ctx, image1.RootfsPartition3, image2.RootfsPartition3, "rootfs", flagInfo.MetadataMode)
+	if err != nil {
+		return fmt.Errorf("fail to diff Rootfs partitions %v and %v: %v", image1.RootfsPartition3, image2.RootfsPartition3, err)
+	}
+	if !flagInfo.ShowVersionChurn {
+		rawRootfsDiff = newVersionChurn(image1, image2).suppressDirectoryDiff(rawRootfsDiff, image1.RootfsPartition3, image2.RootfsPartition3)
+	}
+	rootfsDiff, err := compressDirectoryDiff(image1.RootfsPartition3, image2.RootfsPartition3, "rootfs", rawRootfsDiff, flagInfo.Verbose, flagInfo.CompressRootfsSlice)
 	if err != nil {
 		return fmt.Errorf("fail to diff Rootfs partitions %v and %v: %v", image1.RootfsPartition3, image2.RootfsPartition3, err)
 	}
 	d.Rootfs = rootfsDiff
+
+	if flagInfo.DeltaTool != "" {
+		sizes, err := deltaSizes(ctx, image1.RootfsPartition3, image2.RootfsPartition3, rawRootfsDiff, flagInfo.DeltaTool, flagInfo.Threads)
+		if err != nil {
+			return fmt.Errorf("failed to get delta sizes of Rootfs partitions %v and %v: %v", image1.RootfsPartition3, image2.RootfsPartition3, err)
+		}
+		d.RootfsDeltaSizes = sizes
+	}
 	return nil
 }
 
 // osConfigDiff calculates the OsConfig difference of two images
-func (d *Differences) osConfigDiff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+func (d *Differences) osConfigDiff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
 	mapOfEtcEntries, err := findOSConfigs(image1, image2) // Get map of /etc entries for both images
 	if err != nil {
 		return fmt.Errorf("failed to find OS Configs: %v", err)
 	}
-	output := make(map[string]string)
-	for etcEntryName, img := range mapOfEtcEntries {
+	var churn *versionChurn
+	if !flagInfo.ShowVersionChurn {
+		churn = newVersionChurn(image1, image2)
+	}
+	var etcEntryNames []string
+	for etcEntryName := range mapOfEtcEntries {
 		etcEntryPath := filepath.Join(etc, etcEntryName) + "/"
 		if flagInfo.Verbose || !utilities.InArray(etcEntryPath, flagInfo.CompressRootfsSlice) { // Only diff if Verbose or etcEntry is not in CompressRootfs.txt
-			currentImage := img
-			if img != "" { // Unique /etc entry in Image 1 or Image2
-				output[etcEntryPath] += "Only in " + img + "/rootfs/etc: " + etcEntryName
-			} else { // Shared /etc entry in Image 1 and Image 2
-				osConfigDiff, err := pureDiff(filepath.Join(image1.RootfsPartition3, etcEntryPath), filepath.Join(image2.RootfsPartition3, etcEntryPath))
-				if err != nil {
-					return fmt.Errorf("fail to take \"diff -r --no-dereference\" on %v: %v", etcEntryPath, err)
-				}
-				currentImage = image1.TempDir
-				output[etcEntryPath] = osConfigDiff
-			}
+			etcEntryNames = append(etcEntryNames, etcEntryName)
+		}
+	}
+	entryDiffs := make([]string, len(etcEntryNames))
+	errs := utilities.ParallelFor(flagInfo.Threads, len(etcEntryNames), func(i int) error {
+		step := "OS-config:" + filepath.Join(etc, etcEntryNames[i]) + "/"
+		return flagInfo.Checkpoint.Run(step, &entryDiffs[i], func() error {
+			entryDiff, err := osConfigEntryDiff(ctx, etcEntryNames[i], mapOfEtcEntries[etcEntryNames[i]], image1, image2, flagInfo, churn)
+			entryDiffs[i] = entryDiff
+			return err
+		})
+	})
+	output := make(map[string]string)
+	for i, etcEntryName := range etcEntryNames {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		output[filepath.Join(etc, etcEntryName)+"/"] = entryDiffs[i]
+	}
+	d.OSConfigs = output
+	return nil
+}
 
-			fullPath := filepath.Join(currentImage, "/rootfs/", etcEntryPath)
-			entryFile, err := os.Stat(fullPath)
+// osConfigEntryDiff calculates the difference of a single /etc entry of two images
+// Input:
+//   (context.Context) ctx - Context used to cancel the diff command
+//   (string) etcEntryName - Name of the entry under /etc
+//   (string) img - Name of the image the entry is unique to, empty if shared
+//   (*ImageInfo) image1, image2 - Structs that store binary info for both images
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+//   (*versionChurn) churn - Suppresses version churn, nil to show it
+// Output:
+//   (string) osConfigDiff - The formated difference of the entry
+func osConfigEntryDiff(ctx context.Context, etcEntryName, img string, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo, churn *versionChurn) (string, error) {
+	etcEntryPath := filepath.Join(etc, etcEntryName) + "/"
+	currentImage, osConfigDiff := img, ""
+	if img != "" { // Unique /etc entry in Image 1 or Image2
+		osConfigDiff = "Only in " + img + "/rootfs/etc: " + etcEntryName
+	} else { // Shared /etc entry in Image 1 and Image 2
+		etcEntry1, etcEntry2 := filepath.Join(image1.RootfsPartition3, etcEntryPath), filepath.Join(image2.RootfsPartition3, etcEntryPath)
+		var err error
+		osConfigDiff, err = pureDiff(ctx, etcEntry1, etcEntry2)
+		if err != nil {
+			return "", fmt.Errorf("fail to take \"diff -r --no-dereference\" on %v: %v", etcEntryPath, err)
+		}
+		osConfigDiff = churn.suppressPureDiff(osConfigDiff, etcEntry1, etcEntry2)
+		if flagInfo.SemanticConfigs && osConfigDiff != "" {
+			entryFile, err := os.Stat(etcEntry1)
 			if err != nil {
-				return fmt.Errorf("failed to get info on file %v: %v", fullPath, err)
-			}
-			if output[etcEntryPath] != "" {
-				if entryFile.IsDir() {
-					output[etcEntryPath] = "Configs for directory " + etcEntryPath + "\n" + output[etcEntryPath]
-				} else {
-					output[etcEntryPath] = "Configs for file " + etcEntryPath + "\n" + output[etcEntryPath]
-				}
+				return "", fmt.Errorf("failed to get info on file %v: %v", etcEntry1, err)
 			}
+			osConfigDiff = semanticConfigDiff(osConfigDiff, etcEntry1, etcEntry2, entryFile.IsDir())
 		}
+		currentImage = image1.TempDir
 	}
-	d.OSConfigs = output
-	return nil
+
+	fullPath := filepath.Join(currentImage, "/rootfs/", etcEntryPath)
+	entryFile, err := os.Stat(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get info on file %v: %v", fullPath, err)
+	}
+	if osConfigDiff != "" {
+		if entryFile.IsDir() {
+			osConfigDiff = "Configs for directory " + etcEntryPath + "\n" + osConfigDiff
+		} else {
+			osConfigDiff = "Configs for file " + etcEntryPath + "\n" + osConfigDiff
+		}
+	}
+	return osConfigDiff, nil
 }
 
 // statefulDiff calculates the stateful partition difference of two images
-func (d *Differences) statefulDiff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
-	statefulDiff, err := directoryDiff(image1.StatePartition1, image2.StatePartition1, "stateful", flagInfo.Verbose, flagInfo.CompressStatefulSlice)
+func (d *Differences) statefulDiff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	statefulDiff, err := directoryDiff(ctx, image1.StatePartition1, image2.StatePartition1, "stateful", flagInfo.MetadataMode, flagInfo.Verbose, flagInfo.CompressStatefulSlice)
 	if err != nil {
 		return fmt.Errorf("failed to diff stateful partitions %v and %v: %v", image1.StatePartition1, image2.StatePartition1, err)
 	}
@@ -110,10 +175,36 @@ func (d *Differences) statefulDiff(image1, image2 *input.ImageInfo, flagInfo *in
 	return nil
 }
 
+// permissionsDiff calculates the permission and ownership difference of the
+// Rootfs (if Rootfs or OS-config is selected) and stateful partitions of two images
+func (d *Differences) permissionsDiff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	var diffs []string
+	if utilities.InArray("Rootfs", flagInfo.BinaryTypesSelected) || utilities.InArray("OS-config", flagInfo.BinaryTypesSelected) {
+		rootfsDiff, err := permissionsDiff(ctx, image1.RootfsPartition3, image2.RootfsPartition3)
+		if err != nil {
+			return fmt.Errorf("failed to diff Rootfs permissions: %v", err)
+		}
+		if rootfsDiff != "" {
+			diffs = append(diffs, rootfsDiff)
+		}
+	}
+	if utilities.InArray("Stateful-partition", flagInfo.BinaryTypesSelected) {
+		statefulDiff, err := permissionsDiff(ctx, image1.StatePartition1, image2.StatePartition1)
+		if err != nil {
+			return fmt.Errorf("failed to diff stateful partition permissions: %v", err)
+		}
+		if statefulDiff != "" {
+			diffs = append(diffs, statefulDiff)
+		}
+	}
+	d.Permissions = strings.Join(diffs, "\n")
+	return nil
+}
+
 // partitionStructureDiff calculates the Version difference of two images
-func (d *Differences) partitionStructureDiff(image1, image2 *input.ImageInfo) error {
+func (d *Differences) partitionStructureDiff(ctx context.Context, image1, image2 *input.ImageInfo) error {
 	if image2.TempDir != "" {
-		partitionStructureDiff, err := pureDiff(image1.PartitionFile, image2.PartitionFile)
+		partitionStructureDiff, err := pureDiff(ctx, image1.PartitionFile, image2.PartitionFile)
 		if err != nil {
 			return fmt.Errorf("fail to compare both image's \"partitions.txt\" file: %v", err)
 		}
@@ -129,9 +220,9 @@ func (d *Differences) partitionStructureDiff(image1, image2 *input.ImageInfo) er
 }
 
 // kernelConfigsDiff calculates the kernel configs difference of two images
-func (d *Differences) kernelConfigsDiff(image1, image2 *input.ImageInfo) error {
+func (d *Differences) kernelConfigsDiff(ctx context.Context, image1, image2 *input.ImageInfo) error {
 	if image2.TempDir != "" {
-		kernelConfigsDiff, err := pureDiff(image1.KernelConfigsFile, image2.KernelConfigsFile)
+		kernelConfigsDiff, err := pureDiff(ctx, image1.KernelConfigsFile, image2.KernelConfigsFile)
 		if err != nil {
 			return fmt.Errorf("fail to compare the two image's kernel configs files: %v", err)
 		}
@@ -181,9 +272,9 @@ func (d *Differences) kernelCommandLineDiff(image1, image2 *input.ImageInfo) err
 }
 
 // sysctlSettingsDiff calculates the sysctl Settings difference of two images
-func (d *Differences) sysctlSettingsDiff(image1, image2 *input.ImageInfo) error {
+func (d *Differences) sysctlSettingsDiff(ctx context.Context, image1, image2 *input.ImageInfo) error {
 	if image2.TempDir != "" {
-		sysctlSettingsDiff, err := pureDiff(image1.SysctlSettingsFile, image2.SysctlSettingsFile)
+		sysctlSettingsDiff, err := pureDiff(ctx, image1.SysctlSettingsFile, image2.SysctlSettingsFile)
 		if err != nil {
 			return fmt.Errorf("fail to compare the two image's sysctl settings files: %v", err)
 		}
@@ -223,6 +314,9 @@ func (d *Differences) FormatBuildIDDiff() string {
 // FormatRootfsDiff returns a formated string of the rootfs difference
 func (d *Differences) FormatRootfsDiff() string {
 	if d.Rootfs != "" {
+		if len(d.RootfsDeltaSizes) > 0 {
+			return "----------RootFS----------\n" + d.Rootfs + "\n\n" + formatDeltaSizes(d.RootfsDeltaSizes) + "\n"
+		}
 		return "----------RootFS----------\n" + d.Rootfs + "\n\n"
 	}
 	return ""
@@ -230,10 +324,11 @@ func (d *Differences) FormatRootfsDiff() string {
 
 // FormatStatefulDiff returns a formated string of the stateful partition difference
 func (d *Differences) FormatStatefulDiff() string {
+	statefulDiff := ""
 	if d.Stateful != "" {
-		return "----------Stateful Partition----------\n" + d.Stateful + "\n\n"
+		statefulDiff = "----------Stateful Partition----------\n" + d.Stateful + "\n\n"
 	}
-	return ""
+	return statefulDiff + d.FormatStatefulAnalysisDiff()
 }
 
 // FormatOSConfigDiff returns a formated string of the OS Config difference
@@ -301,12 +396,13 @@ func (d *Differences) FormatSysctlSettingsDiff() string {
 // Diff is a tool that finds all binary differences of two COS images
 // (COS version, rootfs, kernel command line, stateful partition, ...)
 // Input:
+//   (context.Context) ctx - Context used to cancel the diff commands
 //   (*ImageInfo) image1 - A struct that will store binary info for image1
 //   (*ImageInfo) image2 - A struct that will store binary info for image2
 //   (*FlagInfo) flagInfo - A struct that holds input preference from the user
 // Output:
 //   (*Differences) BinaryDiff - A struct that will store the binary differences
-func Diff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) (*Differences, error) {
+func Diff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) (*Differences, error) {
 	BinaryDiff := &Differences{}
 
 	if utilities.InArray("Version", flagInfo.BinaryTypesSelected) {
@@ -317,12 +413,12 @@ func Diff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) (*Differenc
 	}
 
 	if utilities.InArray("Partition-structure", flagInfo.BinaryTypesSelected) {
-		if err := BinaryDiff.partitionStructureDiff(image1, image2); err != nil {
+		if err := BinaryDiff.partitionStructureDiff(ctx, image1, image2); err != nil {
 			return BinaryDiff, fmt.Errorf("Failed to get Partition-structure difference: %v", err)
 		}
 	}
 	if utilities.InArray("Kernel-configs", flagInfo.BinaryTypesSelected) {
-		if err := BinaryDiff.kernelConfigsDiff(image1, image2); err != nil {
+		if err := BinaryDiff.kernelConfigsDiff(ctx, image1, image2); err != nil {
 			return BinaryDiff, fmt.Errorf("failed to get Kernel-configs difference: %v", err)
 		}
 	}
@@ -332,27 +428,55 @@ func Diff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) (*Differenc
 		}
 	}
 	if utilities.InArray("Sysctl-settings", flagInfo.BinaryTypesSelected) {
-		if err := BinaryDiff.sysctlSettingsDiff(image1, image2); err != nil {
+		if err := BinaryDiff.sysctlSettingsDiff(ctx, image1, image2); err != nil {
 			return BinaryDiff, fmt.Errorf("failed to get Sysctl-settings difference: %v", err)
 		}
 	}
 
+	// The directory walks below are checkpointed to resume an interrupted analysis
+	state := flagInfo.Checkpoint
 	if image2.TempDir != "" {
 		if utilities.InArray("Rootfs", flagInfo.BinaryTypesSelected) {
-			if err := BinaryDiff.rootfsDiff(image1, image2, flagInfo); err != nil {
+			rootfs := &struct {
+				Rootfs     *string
+				DeltaSizes *[]DeltaSize
+			}{&BinaryDiff.Rootfs, &BinaryDiff.RootfsDeltaSizes}
+			if err := state.Run("Rootfs", rootfs, func() error {
+				return BinaryDiff.rootfsDiff(ctx, image1, image2, flagInfo)
+			}); err != nil {
 				return BinaryDiff, fmt.Errorf("Failed to get Roofs difference: %v", err)
 			}
 		}
 		if utilities.InArray("OS-config", flagInfo.BinaryTypesSelected) {
-			if err := BinaryDiff.osConfigDiff(image1, image2, flagInfo); err != nil {
+			if err := BinaryDiff.osConfigDiff(ctx, image1, image2, flagInfo); err != nil {
 				return BinaryDiff, fmt.Errorf("Failed to get OS-config difference: %v", err)
 			}
 		}
 		if utilities.InArray("Stateful-partition", flagInfo.BinaryTypesSelected) {
-			if err := BinaryDiff.statefulDiff(image1, image2, flagInfo); err != nil {
+			if err := state.Run("Stateful-partition", &BinaryDiff.Stateful, func() error {
+				return BinaryDiff.statefulDiff(ctx, image1, image2, flagInfo)
+			}); err != nil {
 				return BinaryDiff, fmt.Errorf("Failed to get Stateful-partition difference: %v", err)
 			}
+			if flagInfo.StatefulAnalysis {
+				if err := state.Run("Stateful-configs", &BinaryDiff.StatefulConfigs, func() error {
+					return BinaryDiff.statefulConfigsDiff(ctx, image1, image2, flagInfo)
+				}); err != nil {
+					return BinaryDiff, fmt.Errorf("failed to get persisted config difference: %v", err)
+				}
+				if err := BinaryDiff.dockerStateDiff(image1, image2); err != nil {
+					return BinaryDiff, fmt.Errorf("failed to get docker state difference: %v", err)
+				}
+			}
+		}
+		if flagInfo.MetadataMode == metadataReport {
+			if err := state.Run("Permissions", &BinaryDiff.Permissions, func() error {
+				return BinaryDiff.permissionsDiff(ctx, image1, image2, flagInfo)
+			}); err != nil {
+				return BinaryDiff, fmt.Errorf("failed to get permissions difference: %v", err)
+			}
 		}
+		BinaryDiff.filterPaths(image1.RootfsPartition3, image2.RootfsPartition3, image1.StatePartition1, image2.StatePartition1, flagInfo.FilterRegexp, flagInfo.ExcludeRegexp)
 	}
 	return BinaryDiff, nil
 }
@@ -1,10 +1,11 @@
 package binary
 
 import (
+	"context"
 	"testing"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 // test Diff function
@@ -298,7 +299,7 @@ net.ipv4.tcp_slow_start_after_idle = 0`
 			FlagInfo: &input.FlagInfo{BinaryTypesSelected: []string{"Sysctl-settings"}},
 			want:     &Differences{SysctlSettings: testSysctlSettingsDiff}},
 	} {
-		got, _ := Diff(tc.Image1, tc.Image2, tc.FlagInfo)
+		got, _ := Diff(context.Background(), tc.Image1, tc.Image2, tc.FlagInfo)
 
 		if !utilities.EqualArrays(tc.want.Version, got.Version) {
 			t.Fatalf("Diff expected version %v, got: %v", tc.want.Version, got.Version)
@@ -0,0 +1,105 @@
+package binary
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pathKept determines whether a path passes the "-filter" and "-exclude" regexes
+// Input:
+//   (string) path - Path relative to the root of the partition (Ex: /etc/ssh/)
+//   (*regexp.Regexp) filter - Only paths matching filter are kept. Nil keeps all paths
+//   (*regexp.Regexp) exclude - Paths matching exclude are dropped. Nil drops no paths
+// Output:
+//   (bool) kept - True if the path should stay in the output
+func pathKept(path string, filter, exclude *regexp.Regexp) bool {
+	if filter != nil && !filter.MatchString(path) {
+		return false
+	}
+	if exclude != nil && exclude.MatchString(path) {
+		return false
+	}
+	return true
+}
+
+// diffLinePath finds the path, relative to the root of the directories, that
+// a single line of "diff -rq" output (or its compressed form) refers to
+// Input:
+//   (string) line - A single line of output from the "diff -rq" command
+//   (string) dir1 - Path to directory 1
+//   (string) dir2 - Path to directory 2
+// Output:
+//   (string) path - Path relative to the root of dir1 and dir2
+//   (bool) ok - Flag to indicate a path has been found
+func diffLinePath(line, dir1, dir2 string) (string, bool) {
+	for _, dir := range []string{filepath.Clean(dir1), filepath.Clean(dir2)} {
+		start := strings.Index(line, dir)
+		if start < 0 {
+			continue
+		}
+		rest := line[start+len(dir):]
+		if end := strings.IndexAny(rest, " :"); end >= 0 {
+			if strings.HasPrefix(rest[end:], ": ") { // "Only in [dir]: [name]" case
+				return filepath.Join("/", rest[:end], rest[end+2:]), true
+			}
+			rest = rest[:end]
+		}
+		if strings.HasPrefix(line, "Files in ") || strings.HasPrefix(line, "Unique files in ") { // Compressed directory case
+			return filepath.Join("/", rest) + "/", true
+		}
+		return filepath.Join("/", rest), true
+	}
+	return "", false
+}
+
+// filterDirectoryDiff removes the lines of a directory difference whose path
+// does not pass the "-filter" and "-exclude" regexes
+func filterDirectoryDiff(diff, dir1, dir2 string, filter, exclude *regexp.Regexp) string {
+	if diff == "" {
+		return diff
+	}
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if path, ok := diffLinePath(line, dir1, dir2); ok && !pathKept(path, filter, exclude) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// filterPaths removes all path based differences that do not pass the
+// "-filter" and "-exclude" regexes
+// Input:
+//   (string) rootfs1, rootfs2 - Paths to the mounted Rootfs partitions
+//   (string) stateful1, stateful2 - Paths to the mounted stateful partitions
+//   (*regexp.Regexp) filter - Only paths matching filter are kept. Nil keeps all paths
+//   (*regexp.Regexp) exclude - Paths matching exclude are dropped. Nil drops no paths
+func (d *Differences) filterPaths(rootfs1, rootfs2, stateful1, stateful2 string, filter, exclude *regexp.Regexp) {
+	if filter == nil && exclude == nil {
+		return
+	}
+	d.Rootfs = filterDirectoryDiff(d.Rootfs, rootfs1, rootfs2, filter, exclude)
+	d.Stateful = filterDirectoryDiff(d.Stateful, stateful1, stateful2, filter, exclude)
+	d.Permissions = filterDirectoryDiff(filterDirectoryDiff(d.Permissions, rootfs1, rootfs2, filter, exclude), stateful1, stateful2, filter, exclude)
+
+	var deltaSizes []DeltaSize
+	for _, size := range d.RootfsDeltaSizes {
+		if pathKept(size.Path, filter, exclude) {
+			deltaSizes = append(deltaSizes, size)
+		}
+	}
+	d.RootfsDeltaSizes = deltaSizes
+
+	for etcEntryPath := range d.OSConfigs {
+		if !pathKept(etcEntryPath, filter, exclude) {
+			delete(d.OSConfigs, etcEntryPath)
+		}
+	}
+	for configDir := range d.StatefulConfigs {
+		if !pathKept(configDir, filter, exclude) {
+			delete(d.StatefulConfigs, configDir)
+		}
+	}
+}
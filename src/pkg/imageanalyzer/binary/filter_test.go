@@ -0,0 +1,67 @@
+package binary
+
+import (
+	"regexp"
+	"testing"
+)
+
+// test diffLinePath function
+func TestDiffLinePath(t *testing.T) {
+	dir1, dir2 := "../testdata/image1/rootfs/", "../testdata/image2/rootfs/"
+	for _, tc := range []struct {
+		line   string
+		want   string
+		wantOk bool
+	}{
+		{line: "Files ../testdata/image1/rootfs/lib64/python.txt and ../testdata/image2/rootfs/lib64/python.txt differ", want: "/lib64/python.txt", wantOk: true},
+		{line: "Only in ../testdata/image2/rootfs/usr/lib: usr-lib-image2", want: "/usr/lib/usr-lib-image2", wantOk: true},
+		{line: "Files in ../testdata/image1/rootfs/proc and ../testdata/image2/rootfs/proc differ", want: "/proc/", wantOk: true},
+		{line: "Unique files in ../testdata/image2/rootfs/usr/lib", want: "/usr/lib/", wantOk: true},
+		{line: "", want: "", wantOk: false},
+	} {
+		got, ok := diffLinePath(tc.line, dir1, dir2)
+		if got != tc.want || ok != tc.wantOk {
+			t.Fatalf("diffLinePath(%v) expected: %v, %v, got: %v, %v", tc.line, tc.want, tc.wantOk, got, ok)
+		}
+	}
+}
+
+// test filterPaths function
+func TestFilterPaths(t *testing.T) {
+	testRootfsDiff := `Files ../testdata/image1/rootfs/lib64/python.txt and ../testdata/image2/rootfs/lib64/python.txt differ
+Files in ../testdata/image1/rootfs/proc and ../testdata/image2/rootfs/proc differ
+Unique files in ../testdata/image1/rootfs/usr/lib
+Unique files in ../testdata/image2/rootfs/usr/lib`
+
+	for _, tc := range []struct {
+		filter        *regexp.Regexp
+		exclude       *regexp.Regexp
+		wantRootfs    string
+		wantOSConfigs []string
+	}{
+		{filter: nil, exclude: nil, wantRootfs: testRootfsDiff, wantOSConfigs: []string{"/etc/docker/", "/etc/sysctl.d/"}},
+		{filter: regexp.MustCompile(`^/usr/`), exclude: nil,
+			wantRootfs:    "Unique files in ../testdata/image1/rootfs/usr/lib\nUnique files in ../testdata/image2/rootfs/usr/lib",
+			wantOSConfigs: []string{}},
+		{filter: regexp.MustCompile(`^/(etc|proc)/`), exclude: regexp.MustCompile(`docker`),
+			wantRootfs:    "Files in ../testdata/image1/rootfs/proc and ../testdata/image2/rootfs/proc differ",
+			wantOSConfigs: []string{"/etc/sysctl.d/"}},
+	} {
+		d := &Differences{
+			Rootfs:    testRootfsDiff,
+			OSConfigs: map[string]string{"/etc/docker/": "docker diff", "/etc/sysctl.d/": "sysctl diff"},
+		}
+		d.filterPaths("../testdata/image1/rootfs/", "../testdata/image2/rootfs/", "", "", tc.filter, tc.exclude)
+		if d.Rootfs != tc.wantRootfs {
+			t.Fatalf("filterPaths Rootfs expected:\n%v\ngot:\n%v", tc.wantRootfs, d.Rootfs)
+		}
+		if len(d.OSConfigs) != len(tc.wantOSConfigs) {
+			t.Fatalf("filterPaths OSConfigs expected: %v, got: %v", tc.wantOSConfigs, d.OSConfigs)
+		}
+		for _, etcEntryPath := range tc.wantOSConfigs {
+			if _, ok := d.OSConfigs[etcEntryPath]; !ok {
+				t.Fatalf("filterPaths OSConfigs expected: %v, got: %v", tc.wantOSConfigs, d.OSConfigs)
+			}
+		}
+	}
+}
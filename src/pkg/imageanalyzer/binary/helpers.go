@@ -1,6 +1,7 @@
 package binary
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,8 +10,8 @@ import (
 	"regexp"
 	"strings"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 // findOSConfigs creates a map of all /etc entries in both images
@@ -180,25 +181,41 @@ func compressString(dir1, dir2, root, input string, patterns []string) (string,
 //   (string) dir1 - Path to directory 1
 //   (string) dir2 - Path to directory 2
 //   (string) root - Name of the root for directories 1 and 2
+//   (string) metadataMode - "ignore" to compare file content hashes only, else "diff -rq" is used
 //   ([]string) compressedDirs - List of directories to compress by
 //   (bool) verbose - Flag that determines whether to show full or compressed difference
 // Output:
 //   (string) diff - The file difference output of the "diff" command
-func directoryDiff(dir1, dir2, root string, verbose bool, compressedDirs []string) (string, error) {
+func directoryDiff(ctx context.Context, dir1, dir2, root, metadataMode string, verbose bool, compressedDirs []string) (string, error) {
+	diffStr, err := contentDirectoryDiff(ctx, dir1, dir2, root, metadataMode)
+	if err != nil {
+		return "", err
+	}
+	return compressDirectoryDiff(dir1, dir2, root, diffStr, verbose, compressedDirs)
+}
+
+// rawDirectoryDiff returns the uncompressed output of "diff -rq" between two directories
+func rawDirectoryDiff(ctx context.Context, dir1, dir2, root string) (string, error) {
 	var cmd *exec.Cmd
 	if root == "rootfs" { // Only exclude "/etc" for Rootfs difference
-		cmd = exec.Command("sudo", "diff", "--no-dereference", "-rq", "-x", "etc", dir1, dir2)
+		cmd = exec.CommandContext(ctx, "sudo", "diff", "--no-dereference", "-rq", "-x", "etc", dir1, dir2)
 	} else {
-		cmd = exec.Command("sudo", "diff", "--no-dereference", "-rq", dir1, dir2)
+		cmd = exec.CommandContext(ctx, "sudo", "diff", "--no-dereference", "-rq", dir1, dir2)
 	}
 	diff, err := cmd.Output()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
 	if exitError, ok := err.(*exec.ExitError); ok {
 		if exitError.ExitCode() == 2 {
 			return "", fmt.Errorf("failed to call 'diff' command on directories %v and %v: %v", dir1, dir2, err)
 		}
 	}
+	return strings.TrimSuffix(string(diff), "\n"), nil
+}
 
-	diffStr := strings.TrimSuffix(string(diff), "\n")
+// compressDirectoryDiff compresses the output of rawDirectoryDiff unless verbose is true
+func compressDirectoryDiff(dir1, dir2, root, diffStr string, verbose bool, compressedDirs []string) (string, error) {
 	if verbose {
 		return diffStr, nil
 	}
@@ -210,8 +227,11 @@ func directoryDiff(dir1, dir2, root string, verbose bool, compressedDirs []strin
 }
 
 // pureDiff returns the output of a normal diff between two files or directories
-func pureDiff(input1, input2 string) (string, error) {
-	diff, err := exec.Command("sudo", "diff", "-r", "--no-dereference", input1, input2).Output()
+func pureDiff(ctx context.Context, input1, input2 string) (string, error) {
+	diff, err := exec.CommandContext(ctx, "sudo", "diff", "-r", "--no-dereference", input1, input2).Output()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
 	if exitError, ok := err.(*exec.ExitError); ok {
 		if exitError.ExitCode() == 2 {
 			return "", fmt.Errorf("failed to call 'diff' on %v and %v: %v", input1, input2, err)
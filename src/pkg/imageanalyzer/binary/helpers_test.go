@@ -1,6 +1,7 @@
 package binary
 
 import (
+	"context"
 	"testing"
 )
 
@@ -27,7 +28,7 @@ Unique files in ../testdata/image2/rootfs/usr/lib`
 		{dir1: "../testdata/image1/rootfs/", dir2: "../testdata/image2/rootfs/", root: "rootfs", verbose: true, compressedDirs: []string{"/proc/", "/usr/lib/"}, want: testVerboseOutput},
 		{dir1: "../testdata/image1/rootfs/", dir2: "../testdata/image2/rootfs/", root: "rootfs", verbose: false, compressedDirs: []string{"/proc/", "/usr/lib/"}, want: testBriefOutput},
 	} {
-		got, _ := directoryDiff(tc.dir1, tc.dir2, tc.root, tc.verbose, tc.compressedDirs)
+		got, _ := directoryDiff(context.Background(), tc.dir1, tc.dir2, tc.root, "", tc.verbose, tc.compressedDirs)
 		if got != tc.want {
 			t.Fatalf("directoryDiff expected:\n%v\ngot:\n%v", tc.want, got)
 		}
@@ -53,7 +54,7 @@ func TestPureDiff(t *testing.T) {
 		{input1: "../testdata/image1/rootfs/proc/security/configs", input2: "../testdata/image2/rootfs/proc/security/configs", want: testOutput2},
 		{input1: "../testdata/image1/rootfs/proc/security/lib-image1", input2: "../testdata/image2/rootfs/proc/security/lib-image2", want: ""},
 	} {
-		got, _ := pureDiff(tc.input1, tc.input2)
+		got, _ := pureDiff(context.Background(), tc.input1, tc.input2)
 		if got != tc.want {
 			t.Fatalf("PureDiff expected:\n%v\ngot:\n%v", tc.want, got)
 		}
@@ -2,6 +2,7 @@ package binary
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -9,8 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 const cosGCSBucket = "cos-tools"
@@ -24,12 +25,12 @@ const startOfHashingKCL = "dm="
 const pathToSysctlSettings = "/etc/sysctl.d/00-sysctl.conf" // Located in partition 3 Root-A
 
 // getPartitionStructure returns the partition structure of .raw file
-func getPartitionStructure(image *input.ImageInfo) error {
+func getPartitionStructure(ctx context.Context, image *input.ImageInfo) error {
 	if image.TempDir == "" {
 		return nil
 	}
 
-	out, err := exec.Command("sudo", "sgdisk", "-p", image.DiskFile).Output()
+	out, err := exec.CommandContext(ctx, "sudo", "sgdisk", "-p", image.DiskFile).Output()
 	if err != nil {
 		return fmt.Errorf("failed to call sgdisk -p %v: %v", image.DiskFile, err)
 	}
@@ -44,14 +45,14 @@ func getPartitionStructure(image *input.ImageInfo) error {
 
 // getKernelConfigs downloads the kernel configs for a build from GCS and stores
 // it into the image's temporary directory
-func getKernelConfigs(image *input.ImageInfo) error {
+func getKernelConfigs(ctx context.Context, image *input.ImageInfo) error {
 	gcsObject := filepath.Join(image.BuildID, kernelHeaderGCSObject)
-	tarFile, err := utilities.GcsDowndload(cosGCSBucket, gcsObject, image.TempDir, kernelHeaderGCSObject, false)
+	tarFile, err := utilities.GcsDowndload(ctx, cosGCSBucket, gcsObject, image.TempDir, kernelHeaderGCSObject, false)
 	if err != nil {
 		return fmt.Errorf("failed to download GCS object %v from bucket %v: %v", gcsObject, cosGCSBucket, err)
 	}
 
-	_, err = exec.Command("tar", "-xf", tarFile, "-C", image.TempDir).Output()
+	_, err = exec.CommandContext(ctx, "tar", "-xf", tarFile, "-C", image.TempDir).Output()
 	if err != nil {
 		return fmt.Errorf("failed to unzip %v into %v: %v", tarFile, image.TempDir, err)
 	}
@@ -101,7 +102,7 @@ func getSysctlSettings(image *input.ImageInfo) error {
 }
 
 // GetBinaryInfo finds relevant binary information for the COS image
-func GetBinaryInfo(image *input.ImageInfo, flagInfo *input.FlagInfo) error {
+func GetBinaryInfo(ctx context.Context, image *input.ImageInfo, flagInfo *input.FlagInfo) error {
 	if image.TempDir == "" {
 		return nil
 	}
@@ -121,13 +122,13 @@ func GetBinaryInfo(image *input.ImageInfo, flagInfo *input.FlagInfo) error {
 	}
 
 	if utilities.InArray("Partition-structure", flagInfo.BinaryTypesSelected) { // Get partition structure from "sgdisk -p"
-		if err := getPartitionStructure(image); err != nil {
+		if err := getPartitionStructure(ctx, image); err != nil {
 			return fmt.Errorf("failed to get partition structure for image %v: %v", image.TempDir, err)
 		}
 	}
 
 	if utilities.InArray("Kernel-configs", flagInfo.BinaryTypesSelected) { // Get kernel configs from gs://cos-tools/BuildID/kernel-headers.tgz
-		if err := getKernelConfigs(image); err != nil {
+		if err := getKernelConfigs(ctx, image); err != nil {
 			return fmt.Errorf("failed to get kernel configs for image %v: %v", image.TempDir, err)
 		}
 	}
@@ -1,9 +1,10 @@
 package binary
 
 import (
+	"context"
 	"testing"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
 )
 
 // test GetBinaryInf function
@@ -29,7 +30,7 @@ func TestGetBinaryInfo(t *testing.T) {
 			flagInfo: &input.FlagInfo{BinaryTypesSelected: []string{"Kernel-command-line"}},
 			want:     &input.ImageInfo{TempDir: "../testdata/image1", EFIPartition12: "../testdata/image1/efi/", KernelCommandLine: kclImage1}},
 	} {
-		GetBinaryInfo(tc.image, tc.flagInfo)
+		GetBinaryInfo(context.Background(), tc.image, tc.flagInfo)
 
 		if tc.want.Version != tc.image.Version {
 			t.Fatalf("GetBinaryInfo expected: %v, got: %v", tc.want.Version, tc.image.Version)
@@ -0,0 +1,477 @@
+package binary
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Modes of the "-metadata" flag
+const (
+	metadataIgnore = "ignore" // Compare file content hashes only
+	metadataReport = "report" // Report permission and ownership changes as their own category
+)
+
+// hashBatchSize is the maximum number of files hashed by a single sha256sum command
+const hashBatchSize = 256
+
+// treeEntry is an entry of a directory tree listed by listTree
+type treeEntry struct {
+	mode   os.FileMode // Type and permission bits of the entry
+	uid    uint32
+	gid    uint32
+	size   int64
+	target string // Target of a symbolic link, empty for other types
+}
+
+// findTypes maps the "%y" file types printed by "find" to os.FileMode type bits
+var findTypes = map[string]os.FileMode{
+	"f": 0,
+	"d": os.ModeDir,
+	"l": os.ModeSymlink,
+	"p": os.ModeNamedPipe,
+	"s": os.ModeSocket,
+	"c": os.ModeDevice | os.ModeCharDevice,
+	"b": os.ModeDevice,
+}
+
+// findFormat is the "-printf" format of the entries listed by listTree.
+// Fields are NUL terminated since file names may hold any other character.
+const findFormat = "%P\\0%y\\0%m\\0%U\\0%G\\0%s\\0%l\\0"
+
+// findFields is the number of fields printed per entry with findFormat
+const findFields = 7
+
+// parseFindOutput parses the entries printed by "find -printf" with findFormat
+// Input:
+//   (string) out - Output of the find command
+// Output:
+//   (map[string]*treeEntry) tree - Entries by path relative to the listed directory
+func parseFindOutput(out string) (map[string]*treeEntry, error) {
+	fields := strings.Split(out, "\x00")
+	// The output ends with a NUL, which leaves an empty last field
+	if len(fields)%findFields != 1 || fields[len(fields)-1] != "" {
+		return nil, fmt.Errorf("unexpected number of fields %d in find output", len(fields)-1)
+	}
+	tree := make(map[string]*treeEntry)
+	for i := 0; i+findFields < len(fields); i += findFields {
+		path, fileType, perm, uid, gid, size, target := fields[i], fields[i+1], fields[i+2], fields[i+3], fields[i+4], fields[i+5], fields[i+6]
+		typeBits, ok := findTypes[fileType]
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %q of %v in find output", fileType, path)
+		}
+		permBits, err := strconv.ParseUint(perm, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mode %q of %v: %v", perm, path, err)
+		}
+		mode := typeBits | os.FileMode(permBits&0777)
+		if permBits&04000 != 0 {
+			mode |= os.ModeSetuid
+		}
+		if permBits&02000 != 0 {
+			mode |= os.ModeSetgid
+		}
+		if permBits&01000 != 0 {
+			mode |= os.ModeSticky
+		}
+		entry := &treeEntry{mode: mode, target: target}
+		uidValue, err := strconv.ParseUint(uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse uid %q of %v: %v", uid, path, err)
+		}
+		gidValue, err := strconv.ParseUint(gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gid %q of %v: %v", gid, path, err)
+		}
+		entry.uid, entry.gid = uint32(uidValue), uint32(gidValue)
+		if entry.size, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse size %q of %v: %v", size, path, err)
+		}
+		tree[path] = entry
+	}
+	return tree, nil
+}
+
+// listTree lists the entries of a directory tree, without following symbolic
+// links. The tree is listed through sudo since the mounted partitions hold
+// files and directories only readable by root.
+// Input:
+//   (context.Context) ctx - Context used to cancel the find command
+//   (string) dir - Path to the directory
+//   (string) exclude - Base name of the entries to skip at any depth, empty to skip none
+// Output:
+//   (map[string]*treeEntry) tree - Entries by path relative to dir
+func listTree(ctx context.Context, dir, exclude string) (map[string]*treeEntry, error) {
+	args := []string{"find", dir, "-mindepth", "1"}
+	if exclude != "" {
+		args = append(args, "-name", exclude, "-prune", "-o")
+	}
+	args = append(args, "-printf", findFormat)
+	out, err := exec.CommandContext(ctx, "sudo", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contents of directory %v: %v", dir, err)
+	}
+	tree, err := parseFindOutput(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contents of directory %v: %v", dir, err)
+	}
+	return tree, nil
+}
+
+// lessPath orders relative paths the way a depth first walk visiting the
+// entries of each directory in lexical order does
+func lessPath(path1, path2 string) bool {
+	names1, names2 := strings.Split(path1, "/"), strings.Split(path2, "/")
+	for i := 0; i < len(names1) && i < len(names2); i++ {
+		if names1[i] != names2[i] {
+			return names1[i] < names2[i]
+		}
+	}
+	return len(names1) < len(names2)
+}
+
+// treePair holds the listed entries of two directory trees
+type treePair struct {
+	dir1, dir2   string
+	tree1, tree2 map[string]*treeEntry
+}
+
+// listTreePair lists the entries of two directory trees
+func listTreePair(ctx context.Context, dir1, dir2, exclude string) (*treePair, error) {
+	tree1, err := listTree(ctx, dir1, exclude)
+	if err != nil {
+		return nil, err
+	}
+	tree2, err := listTree(ctx, dir2, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &treePair{dir1: dir1, dir2: dir2, tree1: tree1, tree2: tree2}, nil
+}
+
+// walk visits the entries of two directory trees side by side in lexical
+// order, the same order "diff -r" reports in. visit is called for every entry
+// of either tree, with a nil entry for the tree the entry is missing from.
+// Only directories present in both trees are descended into.
+func (p *treePair) walk(visit func(path1, path2 string, entry1, entry2 *treeEntry)) {
+	paths := make([]string, 0, len(p.tree1)+len(p.tree2))
+	for path := range p.tree1 {
+		paths = append(paths, path)
+	}
+	for path := range p.tree2 {
+		if _, ok := p.tree1[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return lessPath(paths[i], paths[j]) })
+
+	sharedDirs := map[string]bool{".": true}
+	for _, path := range paths {
+		if !sharedDirs[filepath.Dir(path)] {
+			continue
+		}
+		entry1, entry2 := p.tree1[path], p.tree2[path]
+		visit(filepath.Join(p.dir1, path), filepath.Join(p.dir2, path), entry1, entry2)
+		if entry1 != nil && entry2 != nil && entry1.mode.IsDir() && entry2.mode.IsDir() {
+			sharedDirs[path] = true
+		}
+	}
+}
+
+// fileType describes the type of a file the way "diff" does
+func fileType(entry *treeEntry) string {
+	mode := entry.mode
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character special file"
+	case mode&os.ModeDevice != 0:
+		return "block special file"
+	case entry.size == 0:
+		return "regular empty file"
+	}
+	return "regular file"
+}
+
+// fileHashes returns the SHA-256 digests of the files' content. The files are
+// read through sudo since the mounted partitions hold files only readable by root.
+// Input:
+//   (context.Context) ctx - Context used to cancel the sha256sum commands
+//   ([]string) paths - Paths to the files
+// Output:
+//   (map[string]string) hashes - Hex encoded digests by path
+func fileHashes(ctx context.Context, paths []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for start := 0; start < len(paths); start += hashBatchSize {
+		end := start + hashBatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		batch := paths[start:end]
+		// "-z" ends each line with a NUL and disables the escaping of file names
+		out, err := exec.CommandContext(ctx, "sudo", append([]string{"sha256sum", "-z", "--"}, batch...)...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash files: %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+		if len(lines) != len(batch) {
+			return nil, fmt.Errorf("unexpected number of hashes %d for %d files", len(lines), len(batch))
+		}
+		// sha256sum prints the digests in the order of its arguments
+		for i, line := range lines {
+			fields := strings.SplitN(line, " ", 2)
+			hashes[batch[i]] = fields[0]
+		}
+	}
+	return hashes, nil
+}
+
+// hashDirectoryDiff is the metadata-insensitive counterpart of rawDirectoryDiff.
+// Files are compared by content hash and symbolic links by target only, so
+// mtime, ownership, mode and xattr differences are never reported. The output
+// uses the "diff -rq" line format so it can be compressed and filtered the same way.
+// Input:
+//   (context.Context) ctx - Context used to cancel the walk
+//   (string) dir1 - Path to directory 1
+//   (string) dir2 - Path to directory 2
+//   (string) root - Name of the root for directories 1 and 2
+// Output:
+//   (string) diff - The file difference in "diff -rq" format
+func hashDirectoryDiff(ctx context.Context, dir1, dir2, root string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	exclude := ""
+	if root == "rootfs" { // Only exclude "/etc" for Rootfs difference
+		exclude = "etc"
+	}
+	pair, err := listTreePair(ctx, dir1, dir2, exclude)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare content of directories %v and %v: %v", dir1, dir2, err)
+	}
+
+	// Only regular files of the same size need their content hashed
+	var toHash []string
+	pair.walk(func(path1, path2 string, entry1, entry2 *treeEntry) {
+		if entry1 != nil && entry2 != nil && entry1.mode.IsRegular() && entry2.mode.IsRegular() && entry1.size == entry2.size {
+			toHash = append(toHash, path1, path2)
+		}
+	})
+	hashes, err := fileHashes(ctx, toHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare content of directories %v and %v: %v", dir1, dir2, err)
+	}
+
+	var lines []string
+	pair.walk(func(path1, path2 string, entry1, entry2 *treeEntry) {
+		switch {
+		case entry2 == nil:
+			lines = append(lines, "Only in "+filepath.Dir(path1)+": "+filepath.Base(path1))
+		case entry1 == nil:
+			lines = append(lines, "Only in "+filepath.Dir(path2)+": "+filepath.Base(path2))
+		case entry1.mode.Type() != entry2.mode.Type():
+			lines = append(lines, "File "+path1+" is a "+fileType(entry1)+" while file "+path2+" is a "+fileType(entry2))
+		case entry1.mode&os.ModeSymlink != 0:
+			if entry1.target != entry2.target {
+				lines = append(lines, "Symbolic links "+path1+" and "+path2+" differ")
+			}
+		case entry1.mode.IsRegular():
+			if entry1.size != entry2.size || hashes[path1] != hashes[path2] {
+				lines = append(lines, "Files "+path1+" and "+path2+" differ")
+			}
+		}
+	})
+	return strings.Join(lines, "\n"), nil
+}
+
+// unquoteGetfattr reverses the octal escaping ("\\012") of the file names
+// printed by "getfattr"
+func unquoteGetfattr(name string) string {
+	var unquoted strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if value, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				unquoted.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		unquoted.WriteByte(name[i])
+	}
+	return unquoted.String()
+}
+
+// parseGetfattr parses the output of "getfattr -d -e hex"
+// Input:
+//   (string) dir - Path to the directory the attributes were dumped from
+//   (string) out - Output of the getfattr command
+// Output:
+//   (map[string]map[string]string) attrs - {path relative to dir: {name: hex value}}
+func parseGetfattr(dir, out string) (map[string]map[string]string, error) {
+	attrs := make(map[string]map[string]string)
+	var fileAttrs map[string]string
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case line == "":
+			fileAttrs = nil
+		case strings.HasPrefix(line, "# file: "):
+			path, err := filepath.Rel(dir, unquoteGetfattr(strings.TrimPrefix(line, "# file: ")))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get path of %v relative to %v: %v", line, dir, err)
+			}
+			fileAttrs = make(map[string]string)
+			attrs[path] = fileAttrs
+		case fileAttrs == nil:
+			return nil, fmt.Errorf("unexpected getfattr line %q outside of a file", line)
+		default:
+			name, value := line, ""
+			if startOfValue := strings.Index(line, "="); startOfValue >= 0 {
+				name, value = line[:startOfValue], line[startOfValue+1:]
+			}
+			fileAttrs[unquoteGetfattr(name)] = value
+		}
+	}
+	return attrs, nil
+}
+
+// listXattrs returns the extended attributes of every entry of a directory
+// tree, without following symbolic links. The attributes are read through
+// sudo so that root-only entries and the "security" and "trusted" namespaces
+// are included.
+// Input:
+//   (context.Context) ctx - Context used to cancel the getfattr command
+//   (string) dir - Path to the directory
+// Output:
+//   (map[string]map[string]string) attrs - {path relative to dir: {name: hex value}}, only
+//                                          holding the entries with extended attributes
+func listXattrs(ctx context.Context, dir string) (map[string]map[string]string, error) {
+	out, err := exec.CommandContext(ctx, "sudo", "getfattr", "--absolute-names", "-R", "-P", "-h", "-d", "-m", "-", "-e", "hex", dir).Output()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || !onlyXattrNotSupported(string(exitErr.Stderr)) {
+			return nil, fmt.Errorf("failed to list xattrs of directory %v: %v", dir, err)
+		}
+	}
+	attrs, err := parseGetfattr(dir, string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list xattrs of directory %v: %v", dir, err)
+	}
+	return attrs, nil
+}
+
+// onlyXattrNotSupported returns true if every error printed by getfattr is
+// about a file system without extended attributes support, in which case the
+// files have no extended attributes
+func onlyXattrNotSupported(stderr string) bool {
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if !strings.HasSuffix(line, "Operation not supported") {
+			return false
+		}
+	}
+	return true
+}
+
+// changedXattrs returns the sorted names of the extended attributes that were
+// added, removed or changed between two files
+func changedXattrs(attrs1, attrs2 map[string]string) []string {
+	var changed []string
+	for name, value1 := range attrs1 {
+		if value2, ok := attrs2[name]; !ok || value1 != value2 {
+			changed = append(changed, name)
+		}
+	}
+	for name := range attrs2 {
+		if _, ok := attrs1[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// permissionsChange describes the permission, ownership and xattr changes of
+// an entry present in both images, empty if there are none
+func permissionsChange(path1, path2 string, entry1, entry2 *treeEntry, attrs1, attrs2 map[string]string) string {
+	var changes []string
+	if entry1.mode != entry2.mode {
+		changes = append(changes, "mode "+entry1.mode.String()+" -> "+entry2.mode.String())
+	}
+	if entry1.uid != entry2.uid || entry1.gid != entry2.gid {
+		changes = append(changes, fmt.Sprintf("owner %d:%d -> %d:%d", entry1.uid, entry1.gid, entry2.uid, entry2.gid))
+	}
+	if attrs := changedXattrs(attrs1, attrs2); len(attrs) > 0 {
+		changes = append(changes, "xattrs "+strings.Join(attrs, ","))
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return "Permissions of " + path1 + " and " + path2 + " differ: " + strings.Join(changes, ", ")
+}
+
+// permissionsDiff reports the permission (mode), ownership (uid and gid) and
+// extended attribute changes of the entries present in both directories.
+// Entries whose type differs are left to the content difference.
+// Input:
+//   (context.Context) ctx - Context used to cancel the walk
+//   (string) dir1 - Path to directory 1
+//   (string) dir2 - Path to directory 2
+// Output:
+//   (string) diff - One line per changed entry
+func permissionsDiff(ctx context.Context, dir1, dir2 string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	pair, err := listTreePair(ctx, dir1, dir2, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to compare permissions of directories %v and %v: %v", dir1, dir2, err)
+	}
+	xattrs1, err := listXattrs(ctx, dir1)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare permissions of directories %v and %v: %v", dir1, dir2, err)
+	}
+	xattrs2, err := listXattrs(ctx, dir2)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare permissions of directories %v and %v: %v", dir1, dir2, err)
+	}
+
+	var lines []string
+	pair.walk(func(path1, path2 string, entry1, entry2 *treeEntry) {
+		if entry1 == nil || entry2 == nil || entry1.mode.Type() != entry2.mode.Type() {
+			return
+		}
+		relPath, _ := filepath.Rel(dir1, path1)
+		if change := permissionsChange(path1, path2, entry1, entry2, xattrs1[relPath], xattrs2[relPath]); change != "" {
+			lines = append(lines, change)
+		}
+	})
+	return strings.Join(lines, "\n"), nil
+}
+
+// contentDirectoryDiff returns the uncompressed file difference of two
+// directories, by content hash if the metadata mode is "ignore" and by "diff -rq" otherwise
+func contentDirectoryDiff(ctx context.Context, dir1, dir2, root, metadataMode string) (string, error) {
+	if metadataMode == metadataIgnore {
+		return hashDirectoryDiff(ctx, dir1, dir2, root)
+	}
+	return rawDirectoryDiff(ctx, dir1, dir2, root)
+}
+
+// FormatPermissionsDiff returns a formated string of the permission and ownership difference
+func (d *Differences) FormatPermissionsDiff() string {
+	if d.Permissions != "" {
+		return "----------Permissions----------\n" + d.Permissions + "\n\n"
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package binary
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// test hashDirectoryDiff function
+func TestHashDirectoryDiff(t *testing.T) {
+	dir1 := writeStatefulFiles(t, map[string]string{
+		"bin/same":      "same content",
+		"bin/changed":   "content 1",
+		"bin/only1":     "only in dir1",
+		"etc/skipped":   "etc is excluded from the Rootfs difference",
+		"lib/dir/file":  "directory in dir1",
+		"usr/empty":     "",
+		"usr/touched":   "touched",
+		"usr/sub/same2": "same content",
+	})
+	dir2 := writeStatefulFiles(t, map[string]string{
+		"bin/same":      "same content",
+		"bin/changed":   "content 2",
+		"bin/only2":     "only in dir2",
+		"etc/skipped":   "changed",
+		"lib/dir":       "file in dir2",
+		"usr/empty":     "",
+		"usr/touched":   "touched",
+		"usr/sub/same2": "same content",
+	})
+	if err := os.Symlink("target1", filepath.Join(dir1, "usr", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target2", filepath.Join(dir2, "usr", "link")); err != nil {
+		t.Fatal(err)
+	}
+	// Metadata only differences are never reported
+	if err := os.Chmod(filepath.Join(dir2, "usr", "touched"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir2, "usr", "touched"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Files " + dir1 + "/bin/changed and " + dir2 + "/bin/changed differ\n" +
+		"Only in " + dir1 + "/bin: only1\n" +
+		"Only in " + dir2 + "/bin: only2\n" +
+		"File " + dir1 + "/lib/dir is a directory while file " + dir2 + "/lib/dir is a regular file\n" +
+		"Symbolic links " + dir1 + "/usr/link and " + dir2 + "/usr/link differ"
+	got, err := hashDirectoryDiff(context.Background(), dir1, dir2, "rootfs")
+	if err != nil {
+		t.Fatalf("hashDirectoryDiff failed: %v", err)
+	}
+	if got != want {
+		t.Fatalf("hashDirectoryDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hashDirectoryDiff(ctx, dir1, dir2, "rootfs"); err == nil {
+		t.Fatalf("hashDirectoryDiff expected an error for a cancelled context")
+	}
+}
+
+// test permissionsDiff function
+func TestPermissionsDiff(t *testing.T) {
+	files := map[string]string{"usr/bin/sudo": "binary", "etc/shadow": "secret", "etc/only1": ""}
+	dir1 := writeStatefulFiles(t, files)
+	delete(files, "etc/only1")
+	dir2 := writeStatefulFiles(t, files)
+	if err := os.Chmod(filepath.Join(dir2, "usr", "bin", "sudo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Permissions of " + dir1 + "/usr/bin/sudo and " + dir2 + "/usr/bin/sudo differ: mode -rw------- -> -rwxr-xr-x"
+	got, err := permissionsDiff(context.Background(), dir1, dir2)
+	if err != nil {
+		t.Fatalf("permissionsDiff failed: %v", err)
+	}
+	if got != want {
+		t.Fatalf("permissionsDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+	if path, ok := diffLinePath(got, dir1, dir2); !ok || path != "/usr/bin/sudo" {
+		t.Fatalf("diffLinePath expected /usr/bin/sudo, got: %v", path)
+	}
+}
+
+// test parseFindOutput function
+func TestParseFindOutput(t *testing.T) {
+	out := "bin\x00d\x00755\x000\x000\x004096\x00\x00" +
+		"bin/su\x00f\x004755\x000\x000\x0012\x00\x00" +
+		"tmp\x00d\x001777\x000\x000\x004096\x00\x00" +
+		"usr/new\nline\x00l\x00777\x001000\x00100\x003\x00../bin\x00"
+	want := map[string]*treeEntry{
+		"bin":           {mode: os.ModeDir | 0755, size: 4096},
+		"bin/su":        {mode: os.ModeSetuid | 0755, size: 12},
+		"tmp":           {mode: os.ModeDir | os.ModeSticky | 0777, size: 4096},
+		"usr/new\nline": {mode: os.ModeSymlink | 0777, uid: 1000, gid: 100, size: 3, target: "../bin"},
+	}
+	got, err := parseFindOutput(out)
+	if err != nil {
+		t.Fatalf("parseFindOutput failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(treeEntry{})); diff != "" {
+		t.Fatalf("parseFindOutput expected:\n%v\ngot:\n%v\ndiff:\n%v", want, got, diff)
+	}
+
+	for _, out := range []string{"bin\x00d\x00755\x00", "bin\x00x\x00755\x000\x000\x004096\x00\x00", "bin\x00d\x00rwx\x000\x000\x004096\x00\x00"} {
+		if _, err := parseFindOutput(out); err == nil {
+			t.Fatalf("parseFindOutput expected an error for %q", out)
+		}
+	}
+}
+
+// test parseGetfattr function
+func TestParseGetfattr(t *testing.T) {
+	out := "# file: /mnt/rootfs/usr/bin/ping\n" +
+		"security.capability=0x0100000200200000\n" +
+		"security.selinux=0x73797374656d5f75\n" +
+		"\n" +
+		"# file: /mnt/rootfs/etc/new\\012line\n" +
+		"user.empty\n" +
+		"\n"
+	want := map[string]map[string]string{
+		"usr/bin/ping":  {"security.capability": "0x0100000200200000", "security.selinux": "0x73797374656d5f75"},
+		"etc/new\nline": {"user.empty": ""},
+	}
+	got, err := parseGetfattr("/mnt/rootfs", out)
+	if err != nil {
+		t.Fatalf("parseGetfattr failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("parseGetfattr expected:\n%v\ngot:\n%v\ndiff:\n%v", want, got, diff)
+	}
+
+	if _, err := parseGetfattr("/mnt/rootfs", "user.orphan=0x00\n"); err == nil {
+		t.Fatalf("parseGetfattr expected an error for an attribute outside of a file")
+	}
+}
+
+// test treePair.walk function
+func TestTreePairWalk(t *testing.T) {
+	dir := &treeEntry{mode: os.ModeDir | 0755}
+	file := &treeEntry{mode: 0644}
+	pair := &treePair{
+		dir1: "/dir1",
+		dir2: "/dir2",
+		tree1: map[string]*treeEntry{
+			"a":       dir,
+			"a/b":     file,
+			"a-c":     file,
+			"only1":   dir,
+			"only1/x": file,
+			"type":    dir,
+			"type/y":  file,
+		},
+		tree2: map[string]*treeEntry{
+			"a":     dir,
+			"a/b":   file,
+			"a-c":   file,
+			"only2": file,
+			"type":  file,
+		},
+	}
+	// "a/b" is visited before "a-c" even though '-' sorts before '/', and
+	// directories missing from or of another type in one tree are not descended into
+	want := []string{"a: both", "a/b: both", "a-c: both", "only1: dir1", "only2: dir2", "type: both"}
+	var got []string
+	pair.walk(func(path1, path2 string, entry1, entry2 *treeEntry) {
+		relPath, _ := filepath.Rel("/dir1", path1)
+		switch {
+		case entry2 == nil:
+			got = append(got, relPath+": dir1")
+		case entry1 == nil:
+			got = append(got, relPath+": dir2")
+		default:
+			got = append(got, relPath+": both")
+		}
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("walk expected:\n%v\ngot:\n%v\ndiff:\n%v", want, got, diff)
+	}
+}
@@ -0,0 +1,197 @@
+package binary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+// persistedConfigDirs are the stateful partition directories holding configs
+// that persist across reboots of a node. /var is mounted from /var_overlay and
+// /home from /home on the stateful partition.
+var persistedConfigDirs = []string{"/etc/", "/home/kubernetes/", "/var_overlay/lib/cloud/", "/var_overlay/lib/kubelet/"}
+
+// dockerRoot is the docker data root (/var/lib/docker) on the stateful partition
+const dockerRoot = "var_overlay/lib/docker"
+
+// dockerRepositories is the file mapping image references to image IDs under dockerRoot
+const dockerRepositories = "image/overlay2/repositories.json"
+
+// dockerContainers is the directory holding one directory per container under dockerRoot
+const dockerContainers = "containers"
+
+// dockerContainerConfig is the file describing a container under its directory
+const dockerContainerConfig = "config.v2.json"
+
+// pathExists returns true if the path exists, false if it does not
+func pathExists(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get info on file %v: %v", path, err)
+	}
+	return true, nil
+}
+
+// statefulConfigsDiff calculates the persisted config difference of two
+// images' stateful partitions. Directories that exist on neither image are skipped.
+func (d *Differences) statefulConfigsDiff(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	output := make(map[string]string)
+	for _, configDir := range persistedConfigDirs {
+		dir1, dir2 := filepath.Join(image1.StatePartition1, configDir), filepath.Join(image2.StatePartition1, configDir)
+		exists1, err := pathExists(dir1)
+		if err != nil {
+			return err
+		}
+		exists2, err := pathExists(dir2)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case exists1 && exists2:
+			configDiff, err := pureDiff(ctx, dir1, dir2)
+			if err != nil {
+				return fmt.Errorf("fail to take \"diff -r --no-dereference\" on %v: %v", configDir, err)
+			}
+			if flagInfo.SemanticConfigs {
+				configDiff = semanticConfigDiff(configDiff, dir1, dir2, true)
+			}
+			if configDiff != "" {
+				output[configDir] = "Persisted configs for directory " + configDir + "\n" + configDiff
+			}
+		case exists1:
+			output[configDir] = "Only in " + image1.TempDir + "/stateful: " + configDir
+		case exists2:
+			output[configDir] = "Only in " + image2.TempDir + "/stateful: " + configDir
+		}
+	}
+	d.StatefulConfigs = output
+	return nil
+}
+
+// dockerImages lists the docker images stored on a stateful partition
+// Input:
+//   (string) stateful - Path to the mounted stateful partition
+// Output:
+//   ([]string) images - Sorted "image [reference] [image ID]" entries
+func dockerImages(stateful string) ([]string, error) {
+	repositoriesFile := filepath.Join(stateful, dockerRoot, dockerRepositories)
+	content, err := ioutil.ReadFile(repositoriesFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %v: %v", repositoriesFile, err)
+	}
+	var repositories struct {
+		Repositories map[string]map[string]string
+	}
+	if err := json.Unmarshal(content, &repositories); err != nil {
+		return nil, fmt.Errorf("failed to parse file %v: %v", repositoriesFile, err)
+	}
+	var images []string
+	for _, references := range repositories.Repositories {
+		for reference, imageID := range references {
+			images = append(images, "image "+reference+" "+imageID)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// dockerContainerList lists the docker containers stored on a stateful partition
+// Input:
+//   (string) stateful - Path to the mounted stateful partition
+// Output:
+//   ([]string) containers - Sorted "container [name] [image]" entries
+func dockerContainerList(stateful string) ([]string, error) {
+	containersDir := filepath.Join(stateful, dockerRoot, dockerContainers)
+	containerDirs, err := ioutil.ReadDir(containersDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read contents of directory %v: %v", containersDir, err)
+	}
+	var containers []string
+	for _, containerDir := range containerDirs {
+		configFile := filepath.Join(containersDir, containerDir.Name(), dockerContainerConfig)
+		content, err := ioutil.ReadFile(configFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %v: %v", configFile, err)
+		}
+		var config struct {
+			Name   string
+			Config struct {
+				Image string
+			}
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse file %v: %v", configFile, err)
+		}
+		containers = append(containers, "container "+strings.TrimPrefix(config.Name, "/")+" "+config.Config.Image)
+	}
+	sort.Strings(containers)
+	return containers, nil
+}
+
+// dockerState lists the docker images and containers stored on a stateful partition
+func dockerState(stateful string) ([]string, error) {
+	images, err := dockerImages(stateful)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list docker images: %v", err)
+	}
+	containers, err := dockerContainerList(stateful)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list docker containers: %v", err)
+	}
+	return append(images, containers...), nil
+}
+
+// dockerStateDiff calculates the docker image and container difference of two
+// images' stateful partitions
+func (d *Differences) dockerStateDiff(image1, image2 *input.ImageInfo) error {
+	state1, err := dockerState(image1.StatePartition1)
+	if err != nil {
+		return fmt.Errorf("failed to get docker state of image %v: %v", image1.TempDir, err)
+	}
+	state2, err := dockerState(image2.StatePartition1)
+	if err != nil {
+		return fmt.Errorf("failed to get docker state of image %v: %v", image2.TempDir, err)
+	}
+	d.DockerState = entryDiff(state1, state2)
+	return nil
+}
+
+// FormatStatefulAnalysisDiff returns a formated string of the persisted config
+// and docker state differences
+func (d *Differences) FormatStatefulAnalysisDiff() string {
+	statefulAnalysis := ""
+	if len(d.StatefulConfigs) > 0 {
+		statefulAnalysis += "----------Persisted Configurations----------\n"
+		keys := make([]string, 0)
+		for k := range d.StatefulConfigs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			statefulAnalysis += d.StatefulConfigs[k] + "\n\n"
+		}
+	}
+	if d.DockerState != "" {
+		statefulAnalysis += "----------Docker State----------\n" + d.DockerState + "\n\n"
+	}
+	return statefulAnalysis
+}
@@ -0,0 +1,95 @@
+package binary
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeStatefulFiles creates the given files, keyed by path relative to the
+// stateful partition, under a temporary directory and returns its path
+func writeStatefulFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	stateful := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(stateful, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stateful
+}
+
+// test dockerStateDiff function
+func TestDockerStateDiff(t *testing.T) {
+	stateful1 := writeStatefulFiles(t, map[string]string{
+		"var_overlay/lib/docker/image/overlay2/repositories.json": `{"Repositories": {"gcr.io/cos-cloud/toolbox": {"gcr.io/cos-cloud/toolbox:v20200603": "sha256:aaa"}}}`,
+		"var_overlay/lib/docker/containers/1a2b/config.v2.json":   `{"Name": "/toolbox", "Config": {"Image": "gcr.io/cos-cloud/toolbox:v20200603"}}`,
+		"var_overlay/lib/docker/containers/3c4d/hostconfig.json":  `{}`,
+		"var_overlay/lib/docker/containers/5e6f/config.v2.json":   `{"Name": "/pause", "Config": {"Image": "k8s.gcr.io/pause:3.2"}}`,
+	})
+	stateful2 := writeStatefulFiles(t, map[string]string{
+		"var_overlay/lib/docker/image/overlay2/repositories.json": `{"Repositories": {"gcr.io/cos-cloud/toolbox": {"gcr.io/cos-cloud/toolbox:v20200714": "sha256:bbb"}}}`,
+		"var_overlay/lib/docker/containers/5e6f/config.v2.json":   `{"Name": "/pause", "Config": {"Image": "k8s.gcr.io/pause:3.2"}}`,
+	})
+	image1 := &input.ImageInfo{TempDir: "cos-77-12371.273.0", StatePartition1: stateful1}
+	image2 := &input.ImageInfo{TempDir: "cos-81-12871.119.0", StatePartition1: stateful2}
+
+	d := &Differences{}
+	if err := d.dockerStateDiff(image1, image2); err != nil {
+		t.Fatalf("dockerStateDiff expected no error, got: %v", err)
+	}
+	want := "< container toolbox gcr.io/cos-cloud/toolbox:v20200603\n" +
+		"< image gcr.io/cos-cloud/toolbox:v20200603 sha256:aaa\n" +
+		"> image gcr.io/cos-cloud/toolbox:v20200714 sha256:bbb"
+	if d.DockerState != want {
+		t.Fatalf("dockerStateDiff expected:\n%v\ngot:\n%v", want, d.DockerState)
+	}
+
+	// A stateful partition without docker state has no images or containers
+	image2.StatePartition1 = t.TempDir()
+	if err := d.dockerStateDiff(image2, image2); err != nil || d.DockerState != "" {
+		t.Fatalf("dockerStateDiff expected empty difference and no error, got: %v, %v", d.DockerState, err)
+	}
+
+	broken := writeStatefulFiles(t, map[string]string{"var_overlay/lib/docker/image/overlay2/repositories.json": `{`})
+	image2.StatePartition1 = broken
+	if err := d.dockerStateDiff(image1, image2); err == nil {
+		t.Fatalf("dockerStateDiff expected error for invalid repositories.json but none returned")
+	}
+}
+
+// test statefulConfigsDiff function for directories only on one image
+func TestStatefulConfigsDiff(t *testing.T) {
+	stateful1 := writeStatefulFiles(t, map[string]string{"home/kubernetes/kubelet-config.yaml": "a"})
+	stateful2 := writeStatefulFiles(t, map[string]string{"var_overlay/lib/cloud/instance": "b"})
+	image1 := &input.ImageInfo{TempDir: "cos-77-12371.273.0", StatePartition1: stateful1}
+	image2 := &input.ImageInfo{TempDir: "cos-81-12871.119.0", StatePartition1: stateful2}
+
+	d := &Differences{}
+	if err := d.statefulConfigsDiff(context.Background(), image1, image2, &input.FlagInfo{}); err != nil {
+		t.Fatalf("statefulConfigsDiff expected no error, got: %v", err)
+	}
+	want := map[string]string{
+		"/home/kubernetes/":       "Only in cos-77-12371.273.0/stateful: /home/kubernetes/",
+		"/var_overlay/lib/cloud/": "Only in cos-81-12871.119.0/stateful: /var_overlay/lib/cloud/",
+	}
+	if !cmp.Equal(d.StatefulConfigs, want) {
+		t.Fatalf("statefulConfigsDiff expected:\n%v\ngot:\n%v", want, d.StatefulConfigs)
+	}
+
+	wantFormat := "----------Persisted Configurations----------\n" +
+		"Only in cos-77-12371.273.0/stateful: /home/kubernetes/\n\n" +
+		"Only in cos-81-12871.119.0/stateful: /var_overlay/lib/cloud/\n\n"
+	if got := d.FormatStatefulAnalysisDiff(); got != wantFormat {
+		t.Fatalf("FormatStatefulAnalysisDiff expected:\n%v\ngot:\n%v", wantFormat, got)
+	}
+}
@@ -0,0 +1,206 @@
+package binary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+// maxChurnFileSize is the largest file whose content is normalized. Larger
+// files are always reported as different.
+const maxChurnFileSize = 4 << 20
+
+// pureDiffHunkHeader matches the header of a hunk of normal "diff" output (Ex: 3c3,4)
+var pureDiffHunkHeader = regexp.MustCompile(`^\d+(,\d+)?[acd]\d+(,\d+)?$`)
+
+// versionChurn recognizes differences that only come from the version and
+// build number embedded in files (os-release, motd, ...) and paths
+type versionChurn struct {
+	buildIDs *regexp.Regexp // Matches the build IDs of both images (Ex: 12371.273.0 or 12371-273-0)
+	versions *regexp.Regexp // Matches the milestones of both images after a known prefix (Ex: cos-77, R77)
+}
+
+// newVersionChurn creates a versionChurn for two images, nil if the images
+// share the same version and build ID or they are unknown
+func newVersionChurn(image1, image2 *input.ImageInfo) *versionChurn {
+	if image1.BuildID == "" || image2.BuildID == "" || image1.BuildID == image2.BuildID {
+		return nil
+	}
+	var buildIDs []string
+	for _, buildID := range []string{image1.BuildID, image2.BuildID} {
+		for _, sep := range []string{".", "-", "_"} {
+			buildIDs = append(buildIDs, regexp.QuoteMeta(strings.ReplaceAll(buildID, ".", sep)))
+		}
+	}
+	churn := &versionChurn{buildIDs: regexp.MustCompile(`\b(` + strings.Join(buildIDs, "|") + `)\b`)}
+	if image1.Version != "" && image2.Version != "" {
+		churn.versions = regexp.MustCompile(`(cos-|\bR|\bm|VERSION=|VERSION_ID=|milestone[ :=]+)(` + regexp.QuoteMeta(image1.Version) + `|` + regexp.QuoteMeta(image2.Version) + `)\b`)
+	}
+	return churn
+}
+
+// normalize replaces the version strings of both images by placeholders
+func (v *versionChurn) normalize(s string) string {
+	s = v.buildIDs.ReplaceAllString(s, "<BUILD_ID>")
+	if v.versions != nil {
+		s = v.versions.ReplaceAllString(s, "${1}<VERSION>")
+	}
+	return s
+}
+
+// sameContent returns true if two regular files are identical once their
+// version strings are normalized
+func (v *versionChurn) sameContent(file1, file2 string) bool {
+	for _, file := range []string{file1, file2} {
+		info, err := os.Lstat(file)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxChurnFileSize {
+			return false
+		}
+	}
+	content1, err := ioutil.ReadFile(file1)
+	if err != nil {
+		return false
+	}
+	content2, err := ioutil.ReadFile(file2)
+	if err != nil {
+		return false
+	}
+	return v.normalize(string(content1)) == v.normalize(string(content2))
+}
+
+// suppressOnlyInPairs removes pairs of "Only in [dir]: [name]" lines, one in
+// each directory, that refer to the same relative path once version strings
+// are normalized (Ex: /usr/share/cos-77-12371.273.0 and /usr/share/cos-81-12871.119.0)
+func (v *versionChurn) suppressOnlyInPairs(lines []string, dir1, dir2 string) []string {
+	dir1, dir2 = filepath.Clean(dir1), filepath.Clean(dir2)
+	type onlyIn struct {
+		line1, line2 []int // Indexes of the lines only in dir1 and only in dir2
+	}
+	pairs := make(map[string]*onlyIn)
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "Only in ") {
+			continue
+		}
+		path := strings.TrimPrefix(line, "Only in ")
+		startOfName := strings.Index(path, ": ")
+		if startOfName < 0 {
+			continue
+		}
+		dir, name := path[:startOfName], path[startOfName+2:]
+		var rel string
+		inDir1 := false
+		switch {
+		case dir == dir1 || strings.HasPrefix(dir, dir1+"/"):
+			rel, inDir1 = strings.TrimPrefix(dir, dir1), true
+		case dir == dir2 || strings.HasPrefix(dir, dir2+"/"):
+			rel = strings.TrimPrefix(dir, dir2)
+		default:
+			continue
+		}
+		key := v.normalize(rel + "/" + name)
+		if pairs[key] == nil {
+			pairs[key] = &onlyIn{}
+		}
+		if inDir1 {
+			pairs[key].line1 = append(pairs[key].line1, i)
+		} else {
+			pairs[key].line2 = append(pairs[key].line2, i)
+		}
+	}
+
+	suppressed := make(map[int]bool)
+	for _, pair := range pairs {
+		if len(pair.line1) == 1 && len(pair.line2) == 1 {
+			suppressed[pair.line1[0]], suppressed[pair.line2[0]] = true, true
+		}
+	}
+	var kept []string
+	for i, line := range lines {
+		if !suppressed[i] {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// suppressDirectoryDiff removes the lines of "diff -rq" output that only come
+// from version strings: files whose contents are equal once normalized, and
+// versioned paths that only exist in one image each
+// Input:
+//   (string) diff - Output of the "diff -rq" command on dir1 and dir2
+//   (string) dir1 - Path to directory 1
+//   (string) dir2 - Path to directory 2
+// Output:
+//   (string) diff - The difference without version churn
+func (v *versionChurn) suppressDirectoryDiff(diff, dir1, dir2 string) string {
+	if v == nil || diff == "" {
+		return diff
+	}
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if files := differingFiles(line); len(files) == 1 && v.sameContent(files[0][0], files[0][1]) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(v.suppressOnlyInPairs(lines, dir1, dir2), "\n")
+}
+
+// suppressPureDiff removes the hunks of "diff -r" output whose removed and
+// added lines are equal once version strings are normalized, and the file
+// headers left without any hunk
+// Input:
+//   (string) diff - Output of pureDiff on entry1 and entry2
+//   (string) entry1 - Path to the file or directory in image1
+//   (string) entry2 - Path to the file or directory in image2
+// Output:
+//   (string) diff - The difference without version churn
+func (v *versionChurn) suppressPureDiff(diff, entry1, entry2 string) string {
+	if v == nil || diff == "" {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	var kept []string
+	header := -1 // Index in kept of the last "diff -r" header, -1 if it has hunks left
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if !pureDiffHunkHeader.MatchString(line) {
+			if header >= 0 { // The previous header has no hunks left
+				kept = kept[:header]
+			}
+			header = -1
+			if strings.HasPrefix(line, pureDiffHeader) {
+				header = len(kept)
+			}
+			kept = append(kept, line)
+			i++
+			continue
+		}
+
+		end := i + 1
+		var removed, added []string
+		for ; end < len(lines); end++ {
+			hunkLine := lines[end]
+			if strings.HasPrefix(hunkLine, "< ") {
+				removed = append(removed, v.normalize(hunkLine[2:]))
+			} else if strings.HasPrefix(hunkLine, "> ") {
+				added = append(added, v.normalize(hunkLine[2:]))
+			} else if hunkLine != "---" && !strings.HasPrefix(hunkLine, `\ `) {
+				break
+			}
+		}
+		if len(removed) == 0 || strings.Join(removed, "\n") != strings.Join(added, "\n") {
+			kept = append(kept, lines[i:end]...)
+			header = -1
+		}
+		i = end
+	}
+	if header >= 0 {
+		kept = kept[:header]
+	}
+	return strings.Join(v.suppressOnlyInPairs(kept, entry1, entry2), "\n")
+}
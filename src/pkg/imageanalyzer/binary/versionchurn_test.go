@@ -0,0 +1,102 @@
+package binary
+
+import (
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+var (
+	churnImage1 = &input.ImageInfo{Version: "81", BuildID: "12871.119.0"}
+	churnImage2 = &input.ImageInfo{Version: "77", BuildID: "12371.273.0"}
+)
+
+// test newVersionChurn and normalize functions
+func TestVersionChurnNormalize(t *testing.T) {
+	if churn := newVersionChurn(churnImage1, churnImage1); churn != nil {
+		t.Fatalf("newVersionChurn expected nil for images with the same build ID, got: %v", churn)
+	}
+	churn := newVersionChurn(churnImage1, churnImage2)
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{input: "BUILD_ID=12871.119.0", want: "BUILD_ID=<BUILD_ID>"},
+		{input: "/usr/share/cos-77-12371-273-0/", want: "/usr/share/cos-<VERSION>-<BUILD_ID>/"},
+		{input: "VERSION=81", want: "VERSION=<VERSION>"},
+		{input: "R77-12371.273.0", want: "R<VERSION>-<BUILD_ID>"},
+		{input: "listen 81", want: "listen 81"},
+		{input: "112871.119.01", want: "112871.119.01"},
+	} {
+		if got := churn.normalize(tc.input); got != tc.want {
+			t.Fatalf("normalize(%v) expected: %v, got: %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+// test suppressPureDiff function
+func TestSuppressPureDiff(t *testing.T) {
+	churn := newVersionChurn(churnImage1, churnImage2)
+	osReleaseDiff := `1c1
+< BUILD_ID=12871.119.0
+---
+> BUILD_ID=12371.273.0
+3c3
+< KERNEL_COMMIT_ID=fa84f12c6d738af9486e69a006a57df923f9476a
+---
+> KERNEL_COMMIT_ID=5d4ffd91281840f7a118143d77fbefb02e87943c
+8c8
+< VERSION=81
+---
+> VERSION=77`
+	want := `3c3
+< KERNEL_COMMIT_ID=fa84f12c6d738af9486e69a006a57df923f9476a
+---
+> KERNEL_COMMIT_ID=5d4ffd91281840f7a118143d77fbefb02e87943c`
+	if got := churn.suppressPureDiff(osReleaseDiff, "image1/rootfs/etc/os-release", "image2/rootfs/etc/os-release"); got != want {
+		t.Fatalf("suppressPureDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+
+	dirDiff := `diff -r --no-dereference image1/rootfs/etc/motd image2/rootfs/etc/motd
+1c1
+< Welcome to cos-81 (R81-12871.119.0)
+---
+> Welcome to cos-77 (R77-12371.273.0)
+Only in image1/rootfs/etc/cos: release-12871.119.0
+Only in image2/rootfs/etc/cos: release-12371.273.0
+Only in image2/rootfs/etc/cos: new-file
+diff -r --no-dereference image1/rootfs/etc/cos/a image2/rootfs/etc/cos/a
+2a3
+> added line`
+	want = `Only in image2/rootfs/etc/cos: new-file
+diff -r --no-dereference image1/rootfs/etc/cos/a image2/rootfs/etc/cos/a
+2a3
+> added line`
+	if got := churn.suppressPureDiff(dirDiff, "image1/rootfs/etc", "image2/rootfs/etc"); got != want {
+		t.Fatalf("suppressPureDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+
+	var noChurn *versionChurn
+	if got := noChurn.suppressPureDiff(osReleaseDiff, "a", "b"); got != osReleaseDiff {
+		t.Fatalf("suppressPureDiff on nil versionChurn expected unchanged difference, got:\n%v", got)
+	}
+}
+
+// test suppressDirectoryDiff function
+func TestSuppressDirectoryDiff(t *testing.T) {
+	churn := newVersionChurn(churnImage1, churnImage2)
+	motd1, motd2 := writeConfigs(t, "motd", "Welcome to cos-81 build 12871.119.0\n", "Welcome to cos-77 build 12371.273.0\n")
+	lsb1, lsb2 := writeConfigs(t, "lsb-release", "CHROMEOS_RELEASE_BUILDER_PATH=lakitu-release/R81-12871.119.0\nA=1\n", "CHROMEOS_RELEASE_BUILDER_PATH=lakitu-release/R77-12371.273.0\nA=2\n")
+	dir1, dir2 := filepath.Dir(motd1), filepath.Dir(motd2)
+	diff := "Files " + motd1 + " and " + motd2 + " differ\n" +
+		"Files " + lsb1 + " and " + lsb2 + " differ\n" +
+		"Only in " + dir1 + ": cos-81-12871-119-0\n" +
+		"Only in " + dir2 + ": cos-77-12371-273-0\n" +
+		"Only in " + dir2 + ": extra"
+	want := "Files " + lsb1 + " and " + lsb2 + " differ\n" +
+		"Only in " + dir2 + ": extra"
+	if got := churn.suppressDirectoryDiff(diff, dir1, dir2); got != want {
+		t.Fatalf("suppressDirectoryDiff expected:\n%v\ngot:\n%v", want, got)
+	}
+}
@@ -0,0 +1,196 @@
+// Package checkpoint persists the results of the completed steps of an
+// analysis to a state file, so that an interrupted analysis of the same images
+// with the same options resumes instead of walking both images from scratch.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+)
+
+const stateFileMode = 0600
+
+// stateFile is the JSON content of a state file
+type stateFile struct {
+	Key   string                     `json:"key"`   // Identifies the images and options of the analysis
+	Steps map[string]json.RawMessage `json:"steps"` // Result of each completed step
+}
+
+// State is the progress of an analysis. A nil State disables checkpointing.
+type State struct {
+	path string
+	mu   sync.Mutex
+	file stateFile
+}
+
+// Key returns the key of an analysis described by v, which must marshal to JSON
+// Input:
+//   (interface{}) v - The images and options that change the results of the analysis
+// Output:
+//   (string) key - Hex encoded SHA-256 digest of the JSON encoding of v
+func Key(v interface{}) (string, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal checkpoint key: %v", err)
+	}
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// Load reads the state of an analysis from the state file at path. A missing
+// state file, or one written by an analysis with another key, starts an empty state.
+// Input:
+//   (string) path - Path to the state file
+//   (string) key - Key of the analysis, see Key
+// Output:
+//   (*State) state - The completed steps of the analysis
+func Load(path, key string) (*State, error) {
+	state := &State{path: path, file: stateFile{Key: key, Steps: map[string]json.RawMessage{}}}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %v: %v", path, err)
+	}
+	var file stateFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %v: %v", path, err)
+	}
+	if file.Key != key || file.Steps == nil {
+		log.Printf("state file %v was written for other images or options, starting from scratch\n", path)
+		return state, nil
+	}
+	state.file = file
+	return state, nil
+}
+
+// Completed returns the number of completed steps
+func (s *State) Completed() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.file.Steps)
+}
+
+// Done returns true if step completed in an earlier run
+func (s *State) Done(step string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.file.Steps[step]
+	return ok
+}
+
+// Reset drops every completed step, Ex: once the fetched images turn out to
+// differ from the ones the steps were completed for
+func (s *State) Reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Steps = map[string]json.RawMessage{}
+}
+
+// Put checkpoints the result of step to the state file, replacing the result
+// of an earlier run. Unlike Run, it is for steps that run again on a resumed analysis.
+// Input:
+//   (string) step - Unique name of the step
+//   (interface{}) result - The result of the step, which must marshal to JSON
+// Output: nil on success, else error
+func (s *State) Put(step string, result interface{}) error {
+	if s == nil {
+		return nil
+	}
+	return s.save(step, result)
+}
+
+// Get loads the result of step into result if the step completed in an earlier run
+// Input:
+//   (string) step - Unique name of the step
+//   (interface{}) result - Pointer to the result of the step
+// Output:
+//   (bool) done - True if the step completed and its result was loaded
+func (s *State) Get(step string, result interface{}) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	saved, ok := s.file.Steps[step]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(saved, result); err != nil {
+		return false, fmt.Errorf("failed to load step %v from state file %v: %v", step, s.path, err)
+	}
+	return true, nil
+}
+
+// Run loads the result of step into result if the step completed in an earlier
+// run. Else it runs fn, which stores its result into result, and checkpoints the
+// result to the state file. Run is safe for concurrent use with distinct steps.
+// Input:
+//   (string) step - Unique name of the step (Ex: "Rootfs" or "OS-config:/etc/ssh/")
+//   (interface{}) result - Pointer to the result of the step, which must marshal to JSON
+//   (func() error) fn - Computes the step
+// Output: nil on success, else error
+func (s *State) Run(step string, result interface{}, fn func() error) error {
+	if s == nil {
+		return fn()
+	}
+	done, err := s.Get(step, result)
+	if err != nil || done {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.save(step, result)
+}
+
+// save adds the result of step to the state file. The file is replaced with a
+// rename so that an interruption never leaves a truncated state file.
+func (s *State) save(step string, result interface{}) error {
+	content, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result of step %v: %v", step, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Steps[step] = content
+	fileContent, err := json.Marshal(s.file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state file %v: %v", s.path, err)
+	}
+	tempPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, fileContent, stateFileMode); err != nil {
+		return fmt.Errorf("failed to write state file %v: %v", tempPath, err)
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file %v: %v", s.path, err)
+	}
+	return nil
+}
+
+// Remove deletes the state file once the analysis completed
+func (s *State) Remove() error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file %v: %v", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// test Run function across a resumed analysis
+func TestRunResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	key, err := Key([]string{"image1", "image2"})
+	if err != nil {
+		t.Fatalf("Key failed: %v", err)
+	}
+	state, err := Load(path, key)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var rootfs string
+	if err := state.Run("Rootfs", &rootfs, func() error { rootfs = "Only in a"; return nil }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var osConfig string
+	if err := state.Run("OS-config", &osConfig, func() error { return errors.New("interrupted") }); err == nil {
+		t.Fatalf("Run did not return the step error")
+	}
+
+	resumed, err := Load(path, key)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if resumed.Completed() != 1 {
+		t.Fatalf("got %d completed steps, want 1", resumed.Completed())
+	}
+	var gotRootfs string
+	if err := resumed.Run("Rootfs", &gotRootfs, func() error { t.Errorf("completed step ran again"); return nil }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotRootfs != "Only in a" {
+		t.Errorf("got Rootfs %q, want %q", gotRootfs, "Only in a")
+	}
+	ran := false
+	if err := resumed.Run("OS-config", &osConfig, func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("failed step did not run again: %v", err)
+	}
+
+	if err := resumed.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file was not removed: %v", err)
+	}
+}
+
+// test Load function with a state file of another analysis
+func TestLoadOtherKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := Load(path, "key1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var result string
+	if err := state.Run("Rootfs", &result, func() error { return nil }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	other, err := Load(path, "key2")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if other.Completed() != 0 {
+		t.Errorf("got %d completed steps for another key, want 0", other.Completed())
+	}
+}
+
+// test that a nil State always runs the steps
+func TestNilState(t *testing.T) {
+	var state *State
+	ran := false
+	if err := state.Run("Rootfs", nil, func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("nil state did not run the step: %v", err)
+	}
+	if err := state.Remove(); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+}
+
+// test Put, Done and Reset functions across a resumed analysis
+func TestPutReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := Load(path, "key")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := state.Put("Verifications", "first"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := state.Put("Verifications", "second"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	resumed, err := Load(path, "key")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !resumed.Done("Verifications") || resumed.Done("Rootfs") {
+		t.Fatalf("got Done %v for Verifications and %v for Rootfs, want true and false", resumed.Done("Verifications"), resumed.Done("Rootfs"))
+	}
+	var verifications string
+	if err := resumed.Run("Verifications", &verifications, func() error { t.Errorf("completed step ran again"); return nil }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if verifications != "second" {
+		t.Errorf("got Verifications %q, want %q", verifications, "second")
+	}
+	resumed.Reset()
+	if resumed.Completed() != 0 {
+		t.Errorf("got %d completed steps after Reset, want 0", resumed.Completed())
+	}
+}
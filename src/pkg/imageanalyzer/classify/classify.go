@@ -0,0 +1,124 @@
+// Package classify sorts image differences into kernel, userspace, firmware
+// and config buckets, so that the team owning each bucket can review its part
+// of an upgrade.
+package classify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Buckets of a difference
+const (
+	Kernel    = "Kernel"
+	Userspace = "Userspace"
+	Firmware  = "Firmware"
+	Config    = "Config"
+)
+
+// Buckets lists all buckets in output order
+var Buckets = []string{Kernel, Userspace, Firmware, Config}
+
+// pathPrefixes maps the directories whose entries belong to a bucket other than
+// Userspace to their bucket. Firmware is checked before Kernel so that
+// /lib/firmware/ is not mistaken for a kernel path.
+var pathPrefixes = []struct {
+	prefix string
+	bucket string
+}{
+	{"/lib/firmware/", Firmware},
+	{"/usr/lib/firmware/", Firmware},
+	{"/boot/efi/", Firmware},
+	{"/efi/", Firmware},
+	{"/lib/modules/", Kernel},
+	{"/usr/lib/modules/", Kernel},
+	{"/boot/", Kernel},
+	{"/usr/src/", Kernel},
+	{"/etc/", Config},
+	{"/usr/lib/sysctl.d/", Config},
+	{"/usr/lib/modprobe.d/", Config},
+}
+
+// Path returns the bucket of a file path relative to the root of a partition
+// Input:
+//   (string) path - Path of the file or directory (Ex: /lib/modules/ or /etc/ssh/sshd_config)
+// Output:
+//   (string) bucket - Bucket of the path, Userspace by default
+func Path(path string) string {
+	for _, p := range pathPrefixes {
+		if strings.HasPrefix(path+"/", p.prefix) {
+			return p.bucket
+		}
+	}
+	return Userspace
+}
+
+// Package returns the bucket of a package
+// Input:
+//   (string) category - Portage category of the package (Ex: sys-kernel), empty
+//   if unknown, in which case a "kernel" package name (Ex: lakitu-kernel-5_10)
+//   is a kernel package
+//   (string) name - Name of the package (Ex: linux-firmware)
+// Output:
+//   (string) bucket - Bucket of the package, Userspace by default
+func Package(category, name string) string {
+	if category == "sys-firmware" || category == "sys-boot" || strings.Contains(name, "firmware") {
+		return Firmware
+	}
+	if category == "sys-kernel" || (category == "" && strings.Contains(name, "kernel")) {
+		return Kernel
+	}
+	return Userspace
+}
+
+// Summary counts the differences of each bucket by difference category
+// (Ex: Summary[Kernel]["Rootfs"] is the number of Rootfs differences under
+// /lib/modules/ and other kernel paths)
+type Summary map[string]map[string]int
+
+// Add counts differences of a category into a bucket
+func (s Summary) Add(bucket, category string, count int) {
+	if count <= 0 {
+		return
+	}
+	if s[bucket] == nil {
+		s[bucket] = make(map[string]int)
+	}
+	s[bucket][category] += count
+}
+
+// Total returns the number of differences in a bucket
+func (s Summary) Total(bucket string) int {
+	total := 0
+	for _, count := range s[bucket] {
+		total += count
+	}
+	return total
+}
+
+// Format returns one summary line per bucket, with the number of differences
+// of each category in the bucket
+func (s Summary) Format() string {
+	summary := ""
+	for _, bucket := range Buckets {
+		summary += fmt.Sprintf("%s: %d", bucket, s.Total(bucket))
+		categories := make([]string, 0, len(s[bucket]))
+		for category := range s[bucket] {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for i, category := range categories {
+			separator := ", "
+			if i == 0 {
+				separator = " ("
+			}
+			summary += fmt.Sprintf("%s%s: %d", separator, category, s[bucket][category])
+		}
+		if len(categories) > 0 {
+			summary += ")"
+		}
+		summary += "\n"
+	}
+	return summary
+}
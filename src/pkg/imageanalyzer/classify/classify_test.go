@@ -0,0 +1,59 @@
+package classify
+
+import "testing"
+
+// test Path function
+func TestPath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{path: "/lib/modules/", want: Kernel},
+		{path: "/lib/modules/5.10.90/kernel/net/ipv4/tcp_bbr.ko", want: Kernel},
+		{path: "/boot/vmlinuz", want: Kernel},
+		{path: "/lib/firmware/i915/skl_dmc_ver1_27.bin", want: Firmware},
+		{path: "/boot/efi/boot/grub.cfg", want: Firmware},
+		{path: "/etc/ssh/sshd_config", want: Config},
+		{path: "/etc", want: Config},
+		{path: "/usr/bin/docker", want: Userspace},
+		{path: "/lib64/libc.so.6", want: Userspace},
+		{path: "/bootstrap", want: Userspace},
+	} {
+		if got := Path(tc.path); got != tc.want {
+			t.Errorf("Path(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+// test Package function
+func TestPackage(t *testing.T) {
+	for _, tc := range []struct {
+		category string
+		name     string
+		want     string
+	}{
+		{category: "sys-kernel", name: "lakitu-kernel-5_10", want: Kernel},
+		{category: "sys-kernel", name: "linux-firmware", want: Firmware},
+		{category: "sys-boot", name: "grub", want: Firmware},
+		{category: "app-emulation", name: "docker", want: Userspace},
+		{category: "", name: "lakitu-kernel-4_19", want: Kernel},
+		{category: "dev-libs", name: "libkernelcapi", want: Userspace},
+	} {
+		if got := Package(tc.category, tc.name); got != tc.want {
+			t.Errorf("Package(%q, %q) = %v, want %v", tc.category, tc.name, got, tc.want)
+		}
+	}
+}
+
+// test Summary Format function
+func TestSummaryFormat(t *testing.T) {
+	summary := Summary{}
+	summary.Add(Kernel, "Rootfs", 2)
+	summary.Add(Kernel, "Kernel-configs", 1)
+	summary.Add(Config, "OS-config", 3)
+	summary.Add(Firmware, "Package", 0)
+	want := "Kernel: 3 (Kernel-configs: 1, Rootfs: 2)\nUserspace: 0\nFirmware: 0\nConfig: 3 (OS-config: 3)\n"
+	if got := summary.Format(); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
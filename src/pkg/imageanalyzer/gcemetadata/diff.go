@@ -0,0 +1,92 @@
+package gcemetadata
+
+import (
+	"sort"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// Differences stores the cloud metadata differences of two GCE images.
+// Each field holds [image1 value, image2 value] and is only set if the values
+// differ. If only one image is passed in, each field holds [image1 value, ""].
+type Differences struct {
+	Labels            map[string][]string // {label: [image1 value, image2 value]}, "" if the label is unset
+	Licenses          []string
+	GuestOSFeatures   []string
+	DeprecationState  []string
+	CreationTimestamp []string
+	SingleImage       bool `json:"-"` // Set if only one image is passed in
+}
+
+// valuePair returns [value1, value2] if the values differ, else nil
+func valuePair(value1, value2 string) []string {
+	if value1 == value2 {
+		return nil
+	}
+	return []string{value1, value2}
+}
+
+// Diff finds the cloud metadata differences of two GCE images
+// Input:
+//   (*Metadata) metadata1 - The cloud metadata of image1
+//   (*Metadata) metadata2 - The cloud metadata of image2, nil if only one image is passed in
+// Output:
+//   (*Differences) metadataDiff - The cloud metadata differences, nil if image1 is not a GCE image
+func Diff(metadata1, metadata2 *Metadata) *Differences {
+	if metadata1 == nil {
+		return nil
+	}
+	singleImage := metadata2 == nil
+	if singleImage {
+		metadata2 = &Metadata{}
+	}
+	metadataDiff := &Differences{
+		SingleImage:       singleImage,
+		Licenses:          valuePair(strings.Join(metadata1.Licenses, ", "), strings.Join(metadata2.Licenses, ", ")),
+		GuestOSFeatures:   valuePair(strings.Join(metadata1.GuestOSFeatures, ", "), strings.Join(metadata2.GuestOSFeatures, ", ")),
+		DeprecationState:  valuePair(metadata1.DeprecationState, metadata2.DeprecationState),
+		CreationTimestamp: valuePair(metadata1.CreationTimestamp, metadata2.CreationTimestamp),
+	}
+	labels := make(map[string][]string)
+	for key, value1 := range metadata1.Labels {
+		if pair := valuePair(value1, metadata2.Labels[key]); pair != nil {
+			labels[key] = pair
+		}
+	}
+	for key, value2 := range metadata2.Labels {
+		if _, ok := metadata1.Labels[key]; !ok {
+			labels[key] = []string{"", value2}
+		}
+	}
+	if len(labels) > 0 {
+		metadataDiff.Labels = labels
+	}
+	return metadataDiff
+}
+
+// formatPair returns a formated string of a single metadata difference
+func (d *Differences) formatPair(name string, pair []string) string {
+	return utilities.FormatPair(name, pair, d.SingleImage)
+}
+
+// FormatGCEMetadataDiff returns a formated string of the cloud metadata difference
+func (d *Differences) FormatGCEMetadataDiff() string {
+	if d == nil {
+		return ""
+	}
+	metadataDiff := d.formatPair("Creation timestamp", d.CreationTimestamp) +
+		d.formatPair("Deprecation state", d.DeprecationState) +
+		d.formatPair("Licenses", d.Licenses) +
+		d.formatPair("Guest OS features", d.GuestOSFeatures)
+
+	keys := make([]string, 0, len(d.Labels))
+	for k := range d.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		metadataDiff += d.formatPair("Label "+k, d.Labels[k])
+	}
+	return metadataDiff
+}
@@ -0,0 +1,105 @@
+package gcemetadata
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/compute/v1"
+)
+
+// test newMetadata function
+func TestNewMetadata(t *testing.T) {
+	image := &compute.Image{
+		Name:              "cos-81-12871-119-0",
+		Labels:            map[string]string{"milestone": "81"},
+		Licenses:          []string{"https://www.googleapis.com/compute/v1/projects/cos-cloud/global/licenses/cos-pcid", "https://www.googleapis.com/compute/v1/projects/cos-cloud/global/licenses/cos"},
+		GuestOsFeatures:   []*compute.GuestOsFeature{{Type: "VIRTIO_SCSI_MULTIQUEUE"}, {Type: "UEFI_COMPATIBLE"}},
+		CreationTimestamp: "2020-06-29T10:00:00.000-07:00",
+	}
+	want := &Metadata{
+		Name:              "cos-81-12871-119-0",
+		Labels:            map[string]string{"milestone": "81"},
+		Licenses:          []string{"cos", "cos-pcid"},
+		GuestOSFeatures:   []string{"UEFI_COMPATIBLE", "VIRTIO_SCSI_MULTIQUEUE"},
+		DeprecationState:  "ACTIVE",
+		CreationTimestamp: "2020-06-29T10:00:00.000-07:00",
+	}
+	if got := newMetadata(image); !cmp.Equal(got, want) {
+		t.Fatalf("newMetadata expected:\n%v\ngot:\n%v", want, got)
+	}
+
+	image.Deprecated = &compute.DeprecationStatus{State: "DEPRECATED"}
+	if got := newMetadata(image).DeprecationState; got != "DEPRECATED" {
+		t.Fatalf("newMetadata expected deprecation state DEPRECATED, got: %v", got)
+	}
+}
+
+// test Diff and FormatGCEMetadataDiff functions
+func TestDiff(t *testing.T) {
+	metadata1 := &Metadata{
+		Labels:            map[string]string{"milestone": "77", "lts": "true"},
+		Licenses:          []string{"cos", "cos-pcid"},
+		GuestOSFeatures:   []string{"UEFI_COMPATIBLE"},
+		DeprecationState:  "DEPRECATED",
+		CreationTimestamp: "2020-06-01T10:00:00.000-07:00",
+	}
+	metadata2 := &Metadata{
+		Labels:            map[string]string{"milestone": "81", "channel": "stable"},
+		Licenses:          []string{"cos", "cos-pcid"},
+		GuestOSFeatures:   []string{"UEFI_COMPATIBLE", "VIRTIO_SCSI_MULTIQUEUE"},
+		DeprecationState:  "ACTIVE",
+		CreationTimestamp: "2020-06-29T10:00:00.000-07:00",
+	}
+
+	for _, tc := range []struct {
+		metadata1  *Metadata
+		metadata2  *Metadata
+		want       *Differences
+		wantFormat string
+	}{
+		{metadata1: nil, metadata2: metadata2, want: nil, wantFormat: ""},
+		{metadata1: metadata1, metadata2: metadata2,
+			want: &Differences{
+				Labels:            map[string][]string{"milestone": {"77", "81"}, "lts": {"true", ""}, "channel": {"", "stable"}},
+				GuestOSFeatures:   []string{"UEFI_COMPATIBLE", "UEFI_COMPATIBLE, VIRTIO_SCSI_MULTIQUEUE"},
+				DeprecationState:  []string{"DEPRECATED", "ACTIVE"},
+				CreationTimestamp: []string{"2020-06-01T10:00:00.000-07:00", "2020-06-29T10:00:00.000-07:00"},
+			},
+			wantFormat: "Creation timestamp:\n< 2020-06-01T10:00:00.000-07:00\n> 2020-06-29T10:00:00.000-07:00\n" +
+				"Deprecation state:\n< DEPRECATED\n> ACTIVE\n" +
+				"Guest OS features:\n< UEFI_COMPATIBLE\n> UEFI_COMPATIBLE, VIRTIO_SCSI_MULTIQUEUE\n" +
+				"Label channel:\n< \n> stable\n" +
+				"Label lts:\n< true\n> \n" +
+				"Label milestone:\n< 77\n> 81\n"},
+		{metadata1: &Metadata{Licenses: []string{"cos"}, Labels: map[string]string{"lts": "true"}}, metadata2: &Metadata{},
+			want: &Differences{
+				Labels:   map[string][]string{"lts": {"true", ""}},
+				Licenses: []string{"cos", ""},
+			},
+			wantFormat: "Licenses:\n< cos\n> \n" +
+				"Label lts:\n< true\n> \n"},
+		{metadata1: metadata2, metadata2: nil,
+			want: &Differences{
+				Labels:            map[string][]string{"milestone": {"81", ""}, "channel": {"stable", ""}},
+				Licenses:          []string{"cos, cos-pcid", ""},
+				GuestOSFeatures:   []string{"UEFI_COMPATIBLE, VIRTIO_SCSI_MULTIQUEUE", ""},
+				DeprecationState:  []string{"ACTIVE", ""},
+				CreationTimestamp: []string{"2020-06-29T10:00:00.000-07:00", ""},
+				SingleImage:       true,
+			},
+			wantFormat: "Creation timestamp: 2020-06-29T10:00:00.000-07:00\n" +
+				"Deprecation state: ACTIVE\n" +
+				"Licenses: cos, cos-pcid\n" +
+				"Guest OS features: UEFI_COMPATIBLE, VIRTIO_SCSI_MULTIQUEUE\n" +
+				"Label channel: stable\n" +
+				"Label milestone: 81\n"},
+	} {
+		got := Diff(tc.metadata1, tc.metadata2)
+		if !cmp.Equal(got, tc.want) {
+			t.Fatalf("Diff expected:\n%v\ngot:\n%v", tc.want, got)
+		}
+		if gotFormat := got.FormatGCEMetadataDiff(); gotFormat != tc.wantFormat {
+			t.Fatalf("FormatGCEMetadataDiff expected:\n%v\ngot:\n%v", tc.wantFormat, gotFormat)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package gcemetadata
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"google.golang.org/api/compute/v1"
+)
+
+const cosCloudProject = "cos-cloud"
+const contextTimeOut = time.Second * 50
+
+// Metadata stores the cloud metadata of a GCE image
+type Metadata struct {
+	Name              string
+	Labels            map[string]string
+	Licenses          []string // Names of the licenses (Ex: cos-pcid), sorted
+	GuestOSFeatures   []string // Types of the guest OS features (Ex: UEFI_COMPATIBLE), sorted
+	DeprecationState  string   // "ACTIVE" if the image is not deprecated
+	CreationTimestamp string
+}
+
+// newMetadata extracts the relevant metadata from a compute API image
+func newMetadata(image *compute.Image) *Metadata {
+	metadata := &Metadata{
+		Name:              image.Name,
+		Labels:            image.Labels,
+		DeprecationState:  "ACTIVE",
+		CreationTimestamp: image.CreationTimestamp,
+	}
+	for _, license := range image.Licenses {
+		metadata.Licenses = append(metadata.Licenses, path.Base(license))
+	}
+	sort.Strings(metadata.Licenses)
+	for _, feature := range image.GuestOsFeatures {
+		metadata.GuestOSFeatures = append(metadata.GuestOSFeatures, feature.Type)
+	}
+	sort.Strings(metadata.GuestOSFeatures)
+	if image.Deprecated != nil && image.Deprecated.State != "" {
+		metadata.DeprecationState = image.Deprecated.State
+	}
+	return metadata
+}
+
+// GetMetadata fetches the cloud metadata of an image that was given as a GCE
+// image (-cos-cloud input) by calling the compute API. ADC is used for authorization.
+// Input:
+//   (context.Context) ctx - Context used to cancel the request
+//   (*ImageInfo) image - A struct that stores relevent info for the image
+// Output:
+//   (*Metadata) metadata - The cloud metadata of the image, nil if the image is not a GCE image
+func GetMetadata(ctx context.Context, image *input.ImageInfo) (*Metadata, error) {
+	if image.GCEImage == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, contextTimeOut)
+	defer cancel()
+	svc, err := compute.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new compute client: %v", err)
+	}
+	gceImage, err := svc.Images.Get(cosCloudProject, image.GCEImage).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCE image %v from project %v: %v", image.GCEImage, cosCloudProject, err)
+	}
+	return newMetadata(gceImage), nil
+}
@@ -0,0 +1,496 @@
+// Package imageanalyzer finds all meaningful differences of two COS images
+// (binary, package, toolbox, GCE metadata and provenance differences), or the
+// same information for a single image. It backs the cos_image_analyzer
+// command and can be embedded by other tools and services.
+//
+// The root permission is needed because the images are loop device mounted
+// into the local filesystem to calculate their difference.
+package imageanalyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/checkpoint"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/provenance"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// Sources of an ImageSpec
+const (
+	// Local is the path to a disk.raw file on the local file system
+	Local = "local"
+	// GCS is the "gs://<bucket>/<object_path>.tar.gz" path of an exported image
+	GCS = "gcs"
+	// CosCloud is the name of a cos-cloud image (Ex: cos-81-12871-119-0) or a milestone (Ex: 81)
+	CosCloud = "cos-cloud"
+)
+
+// ImageSpec identifies an image to analyze
+type ImageSpec struct {
+	Source string // Local, GCS or CosCloud
+	Path   string // Path or name of the image, interpreted based on Source
+}
+
+// Options holds the analysis preferences. The zero value analyzes every binary
+// difference type with the default compressed directories.
+type Options struct {
+	// Binary difference types to analyze (see input.BinaryDiffTypes). Nil analyzes all of them.
+	BinaryTypes []string
+	// Compare the package lists of the images
+	Package bool
+	// Compare the toolbox image and debug utility versions of the images
+	Toolbox bool
+	// Count the differences into kernel, userspace, firmware and config buckets.
+	// Requires two images.
+	Classify bool
+	// Compare the persisted configs and docker state of the stateful partitions.
+	// Implies the "Stateful-partition" binary type.
+	StatefulAnalysis bool
+	// Tool used to size the delta of differing Rootfs files ("bsdiff" or "xdelta3"), empty to disable
+	DeltaTool string
+	// "ignore" compares files by content hash only, "report" also reports permission changes
+	MetadataMode string
+	// Show the full, uncompressed Rootfs, OS-config and Stateful-partition differences
+	Verbose bool
+	// Compare known OS-config formats entry by entry instead of textually
+	SemanticConfigs bool
+	// Show differences that only come from the version and build number embedded in files
+	ShowVersionChurn bool
+	// Directories compressed in non-verbose output. Nil uses input.DefaultCompressRootfs
+	// and input.DefaultCompressStateful respectively.
+	CompressRootfs   []string
+	CompressStateful []string
+	// Only paths matching Filter are kept and paths matching Exclude are dropped. Nil keeps all paths
+	Filter  *regexp.Regexp
+	Exclude *regexp.Regexp
+	// Project used to export CosCloud images
+	ProjectID string
+	// Verify the provenance of the images before they are analyzed. Checksums is a
+	// local or "gs://" sha256sum manifest and PublicKey a local PEM public key.
+	Verify    bool
+	Checksums string
+	PublicKey string
+	// Maximum number of concurrent workers. Values below 1 use the number of CPUs.
+	Threads int
+	// State file the Rootfs, OS-config, Stateful-partition and permission
+	// differences and the result of each analyzer are checkpointed to, so that an
+	// interrupted analysis of the same images with the same options resumes from
+	// them, without fetching the images if every analyzer completed. Empty
+	// disables checkpointing.
+	StateFile string
+}
+
+// Diff stores the differences of two images, or the info of a single image
+type Diff struct {
+	*output.ImageDiff
+	// Analyzed images, with their names (TempDir), versions and build IDs.
+	// Image2 is empty if only one image is analyzed.
+	Image1 *input.ImageInfo
+	Image2 *input.ImageInfo
+}
+
+// flagInfo converts the image specs and options into the FlagInfo used by the analysis
+// Input:
+//   (ImageSpec) image1 - The first image
+//   (ImageSpec) image2 - The second image, zero value to analyze a single image
+// Output:
+//   (*FlagInfo) flagInfo - A struct that holds the analysis preferences
+func (options Options) flagInfo(image1, image2 ImageSpec) (*input.FlagInfo, error) {
+	if image1.Path == "" {
+		return nil, errors.New("Error: the first image must have a path")
+	}
+	if image2.Path != "" && image2.Source != image1.Source {
+		return nil, errors.New("Error: both images must have the same source")
+	}
+	if image2.Path != "" && image2.Path == image1.Path {
+		return nil, errors.New("Error: Identical image passed in. To analyze single image, pass in one image")
+	}
+	flagInfo := &input.FlagInfo{
+		Image1:           image1.Path,
+		Image2:           image2.Path,
+		LocalPtr:         image1.Source == Local,
+		GcsPtr:           image1.Source == GCS,
+		CosCloudPtr:      image1.Source == CosCloud,
+		ProjectIDPtr:     options.ProjectID,
+		Verify:           options.Verify || options.Checksums != "" || options.PublicKey != "",
+		ChecksumsPtr:     options.Checksums,
+		PublicKeyPtr:     options.PublicKey,
+		DeltaTool:        options.DeltaTool,
+		StatefulAnalysis: options.StatefulAnalysis,
+		PackageSelected:  options.Package,
+		ToolboxSelected:  options.Toolbox,
+		ClassifySelected: options.Classify,
+		Verbose:          options.Verbose,
+		SemanticConfigs:  options.SemanticConfigs,
+		FilterRegexp:     options.Filter,
+		ExcludeRegexp:    options.Exclude,
+		Threads:          options.Threads,
+		ShowVersionChurn: options.ShowVersionChurn,
+		MetadataMode:     options.MetadataMode,
+		StateFile:        options.StateFile,
+		OutputSelected:   "terminal",
+	}
+	if !flagInfo.LocalPtr && !flagInfo.GcsPtr && !flagInfo.CosCloudPtr {
+		return nil, fmt.Errorf("Error: invalid image source %q", image1.Source)
+	}
+
+	flagInfo.BinaryTypesSelected = input.BinaryDiffTypes
+	if options.BinaryTypes != nil {
+		flagInfo.BinaryTypesSelected = nil
+		for _, binaryType := range options.BinaryTypes {
+			if !utilities.InArray(binaryType, input.BinaryDiffTypes) {
+				return nil, fmt.Errorf("Error: invalid binary difference type %q", binaryType)
+			}
+			flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, binaryType)
+		}
+	}
+	if flagInfo.StatefulAnalysis && !utilities.InArray("Stateful-partition", flagInfo.BinaryTypesSelected) {
+		flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, "Stateful-partition")
+	}
+	if flagInfo.ClassifySelected && flagInfo.Image2 == "" {
+		return nil, errors.New("Error: classification requires two images")
+	}
+	if flagInfo.DeltaTool != "" && !utilities.InArray(flagInfo.DeltaTool, input.DeltaTools) {
+		return nil, errors.New("Error: delta tool must be either \"bsdiff\" or \"xdelta3\"")
+	}
+	if flagInfo.MetadataMode != "" && !utilities.InArray(flagInfo.MetadataMode, input.MetadataModes) {
+		return nil, errors.New("Error: metadata mode must be either \"ignore\" or \"report\"")
+	}
+	if flagInfo.Threads < 1 {
+		flagInfo.Threads = runtime.NumCPU()
+	}
+	flagInfo.CompressRootfsSlice = input.DefaultCompressRootfs
+	if options.CompressRootfs != nil {
+		flagInfo.CompressRootfsSlice = options.CompressRootfs
+	}
+	flagInfo.CompressStatefulSlice = input.DefaultCompressStateful
+	if options.CompressStateful != nil {
+		flagInfo.CompressStatefulSlice = options.CompressStateful
+	}
+	return flagInfo, nil
+}
+
+// mountImages mounts both images on up to flagInfo.Threads goroutines, each into
+// its own temporary directory. Both mounts always run to completion, even if one
+// of them fails, so that Cleanup sees the final state of each image.
+func mountImages(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	images := []*input.ImageInfo{image1, image2}
+	names := []string{"first image " + flagInfo.Image1, "second image " + flagInfo.Image2}
+	mountErrs := utilities.ParallelFor(flagInfo.Threads, len(images), func(i int) error {
+		return images[i].MountImage(ctx, flagInfo.BinaryTypesSelected)
+	})
+
+	var errs []string
+	for i, err := range mountErrs {
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to mount %v: %v", names[i], err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Steps of the analysis checkpointed besides the analyzers
+const (
+	imagesStep        = "Images"
+	verificationsStep = "Verifications"
+)
+
+// loadCheckpoint loads the state file of the analysis into flagInfo.Checkpoint.
+// The state is keyed by the images as passed in and the options that change the
+// checkpointed differences, so that it is consulted before the images are fetched.
+func loadCheckpoint(flagInfo *input.FlagInfo) error {
+	if flagInfo.StateFile == "" {
+		return nil
+	}
+	var filter, exclude string
+	if flagInfo.FilterRegexp != nil {
+		filter = flagInfo.FilterRegexp.String()
+	}
+	if flagInfo.ExcludeRegexp != nil {
+		exclude = flagInfo.ExcludeRegexp.String()
+	}
+	key, err := checkpoint.Key(struct {
+		Images           []string
+		Local            bool
+		GCS              bool
+		CosCloud         bool
+		BinaryTypes      []string
+		DeltaTool        string
+		StatefulAnalysis bool
+		Package          bool
+		Toolbox          bool
+		Verbose          bool
+		SemanticConfigs  bool
+		ShowVersionChurn bool
+		MetadataMode     string
+		CompressRootfs   []string
+		CompressStateful []string
+		Filter           string
+		Exclude          string
+		Checksums        string
+		PublicKey        string
+	}{
+		Images:           []string{flagInfo.Image1, flagInfo.Image2},
+		Local:            flagInfo.LocalPtr,
+		GCS:              flagInfo.GcsPtr,
+		CosCloud:         flagInfo.CosCloudPtr,
+		BinaryTypes:      flagInfo.BinaryTypesSelected,
+		DeltaTool:        flagInfo.DeltaTool,
+		StatefulAnalysis: flagInfo.StatefulAnalysis,
+		Package:          flagInfo.PackageSelected,
+		Toolbox:          flagInfo.ToolboxSelected,
+		Verbose:          flagInfo.Verbose,
+		SemanticConfigs:  flagInfo.SemanticConfigs,
+		ShowVersionChurn: flagInfo.ShowVersionChurn,
+		MetadataMode:     flagInfo.MetadataMode,
+		CompressRootfs:   flagInfo.CompressRootfsSlice,
+		CompressStateful: flagInfo.CompressStatefulSlice,
+		Filter:           filter,
+		Exclude:          exclude,
+		Checksums:        flagInfo.ChecksumsPtr,
+		PublicKey:        flagInfo.PublicKeyPtr,
+	})
+	if err != nil {
+		return err
+	}
+	state, err := checkpoint.Load(flagInfo.StateFile, key)
+	if err != nil {
+		return err
+	}
+	if completed := state.Completed(); completed > 0 {
+		log.Printf("resuming analysis from %d completed steps in state file %v\n", completed, flagInfo.StateFile)
+	}
+	flagInfo.Checkpoint = state
+	return nil
+}
+
+// checkpointedImages is the info of the images checkpointed once they are
+// renamed. It holds what the differences are formated with, but none of the
+// mounts, which do not outlive the analysis.
+type checkpointedImages struct {
+	Image1, Image2 *input.ImageInfo
+}
+
+// checkpointedInfo returns the part of the info of an image that is checkpointed
+func checkpointedInfo(image *input.ImageInfo) *input.ImageInfo {
+	return &input.ImageInfo{
+		TempDir:          image.TempDir,
+		ImageSource:      image.ImageSource,
+		GCEImage:         image.GCEImage,
+		Version:          image.Version,
+		BuildID:          image.BuildID,
+		StatePartition1:  image.StatePartition1,
+		RootfsPartition3: image.RootfsPartition3,
+		EFIPartition12:   image.EFIPartition12,
+	}
+}
+
+// checkpointImages checkpoints the info of the renamed images and their
+// verifications. The completed steps are dropped if they were checkpointed for
+// other images, Ex: once a cos-cloud milestone resolves to a newer image.
+func checkpointImages(image1, image2 *input.ImageInfo, verifications []*provenance.Verification, flagInfo *input.FlagInfo) error {
+	state := flagInfo.Checkpoint
+	images := &checkpointedImages{Image1: checkpointedInfo(image1), Image2: checkpointedInfo(image2)}
+	saved := &checkpointedImages{}
+	done, err := state.Get(imagesStep, saved)
+	if err != nil {
+		return err
+	}
+	if done && (saved.Image1.TempDir != images.Image1.TempDir || saved.Image2.TempDir != images.Image2.TempDir) {
+		log.Printf("state file %v was written for images %v and %v, starting from scratch\n", flagInfo.StateFile, saved.Image1.TempDir, saved.Image2.TempDir)
+		state.Reset()
+	}
+	if err := state.Put(imagesStep, images); err != nil {
+		return err
+	}
+	return state.Put(verificationsStep, verifications)
+}
+
+// resumeAnalysis returns the differences of an analysis whose every analyzer
+// completed in an earlier run, without fetching or mounting the images. It
+// returns nil if any analyzer still has to run, or checkpointing is disabled.
+// Input:
+//   (context.Context) ctx - Context used to cancel the analysis
+//   ([]Analyzer) analyzers - The analyzers run by the analysis
+//   (*FlagInfo) flagInfo - A struct that holds the analysis preferences
+// Output:
+//   (*Diff) diff - The checkpointed differences of the images, nil if the images are needed
+func resumeAnalysis(ctx context.Context, analyzers []Analyzer, flagInfo *input.FlagInfo) (*Diff, error) {
+	state := flagInfo.Checkpoint
+	if !state.Done(imagesStep) || !state.Done(verificationsStep) {
+		return nil, nil
+	}
+	for _, analyzer := range analyzers {
+		checkpointed, ok := analyzer.(checkpointedAnalyzer)
+		if !ok || !state.Done(checkpointed.checkpointStep()) {
+			return nil, nil
+		}
+	}
+	log.Printf("every analyzer completed in state file %v, skipping fetching and mounting the images\n", flagInfo.StateFile)
+	images := &checkpointedImages{}
+	if _, err := state.Get(imagesStep, images); err != nil {
+		return nil, err
+	}
+	var verifications []*provenance.Verification
+	if _, err := state.Get(verificationsStep, &verifications); err != nil {
+		return nil, err
+	}
+	// The analyzers load their checkpointed results instead of reading the images
+	imageDiff, err := runAnalyzers(ctx, images.Image1, images.Image2, analyzers, flagInfo)
+	if err != nil {
+		return nil, err
+	}
+	imageDiff.Verifications = verifications
+	return &Diff{ImageDiff: imageDiff, Image1: images.Image1, Image2: images.Image2}, nil
+}
+
+// runAnalyzers runs the analyzers on two images, the second of which has no
+// TempDir if a single image is analyzed
+// Input:
+//   (context.Context) ctx - Context used to cancel the analysis
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+//   ([]Analyzer) analyzers - The analyzers to run, in order
+//   (*FlagInfo) flagInfo - A struct that holds the analysis preferences
+// Output:
+//   (*ImageDiff) imageDiff - All differences of the two images
+func runAnalyzers(ctx context.Context, image1, image2 *input.ImageInfo, analyzers []Analyzer, flagInfo *input.FlagInfo) (*output.ImageDiff, error) {
+	imageDiff := &output.ImageDiff{}
+	for _, analyzer := range analyzers {
+		if err := analyzer.Analyze(ctx, image1, image2, flagInfo, imageDiff); err != nil {
+			return nil, fmt.Errorf("failed to run the %v analyzer: %v", analyzer.Name(), err)
+		}
+	}
+	if flagInfo.ClassifySelected {
+		imageDiff.Classification = imageDiff.Classify(image1, image2)
+	}
+	return imageDiff, nil
+}
+
+// diffImages finds all differences of two mounted images with the registered analyzers
+// Input:
+//   (context.Context) ctx - Context used to cancel the analysis
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+//   ([]*Verification) verifications - The verifications of the images
+//   ([]Analyzer) analyzers - The analyzers to run, in order
+//   (*FlagInfo) flagInfo - A struct that holds the analysis preferences
+// Output:
+//   (*ImageDiff) imageDiff - All differences of the two images
+func diffImages(ctx context.Context, image1, image2 *input.ImageInfo, verifications []*provenance.Verification, analyzers []Analyzer, flagInfo *input.FlagInfo) (*output.ImageDiff, error) {
+	binaryInfoErrs := utilities.ParallelFor(flagInfo.Threads, 2, func(i int) error {
+		return binary.GetBinaryInfo(ctx, []*input.ImageInfo{image1, image2}[i], flagInfo)
+	})
+	if err := binaryInfoErrs[0]; err != nil {
+		return nil, fmt.Errorf("failed to get GetBinaryInfo from image %v: %v", flagInfo.Image1, err)
+	}
+	if err := binaryInfoErrs[1]; err != nil {
+		return nil, fmt.Errorf("failed to GetBinaryInfo from image %v: %v", flagInfo.Image2, err)
+	}
+	if err := image1.Rename(flagInfo); err != nil {
+		return nil, fmt.Errorf("failed to rename image %v: %v", flagInfo.Image1, err)
+	}
+	if err := image2.Rename(flagInfo); err != nil {
+		return nil, fmt.Errorf("failed to rename image %v: %v", flagInfo.Image2, err)
+	}
+	if err := checkpointImages(image1, image2, verifications, flagInfo); err != nil {
+		return nil, err
+	}
+	imageDiff, err := runAnalyzers(ctx, image1, image2, analyzers, flagInfo)
+	if err != nil {
+		return nil, err
+	}
+	imageDiff.Verifications = verifications
+	return imageDiff, nil
+}
+
+// AnalyzeWithFlags gets, verifies, mounts, diffs and cleans up the images
+// described by a FlagInfo. Cleanup always runs to completion, even after ctx
+// is cancelled or times out. The state file, if any, is removed once the
+// images are diffed. If every analyzer completed in the state file, the
+// checkpointed differences are returned without fetching the images.
+// Input:
+//   (context.Context) ctx - Context used to cancel the analysis
+//   (*FlagInfo) flagInfo - A struct that holds the analysis preferences
+// Output:
+//   (*Diff) diff - The differences of the images
+func AnalyzeWithFlags(ctx context.Context, flagInfo *input.FlagInfo) (diff *Diff, err error) {
+	if err := loadCheckpoint(flagInfo); err != nil {
+		return nil, err
+	}
+	analyzers := registeredAnalyzers()
+	resumed, err := resumeAnalysis(ctx, analyzers, flagInfo)
+	if err != nil {
+		return nil, err
+	}
+	if resumed != nil {
+		if err := flagInfo.Checkpoint.Remove(); err != nil {
+			return nil, err
+		}
+		return resumed, nil
+	}
+
+	var image1, image2 *input.ImageInfo
+	defer func() {
+		var errs []string
+		if cleanupErr := image1.Cleanup(); cleanupErr != nil {
+			errs = append(errs, fmt.Sprintf("failed to clean up image %v: %v", flagInfo.Image1, cleanupErr))
+		}
+		if cleanupErr := image2.Cleanup(); cleanupErr != nil {
+			errs = append(errs, fmt.Sprintf("failed to clean up image %v: %v", flagInfo.Image2, cleanupErr))
+		}
+		if len(errs) > 0 && err == nil {
+			diff, err = nil, errors.New(strings.Join(errs, "; "))
+		}
+	}()
+	image1, image2, err = input.GetImages(ctx, flagInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images: %v", err)
+	}
+	// The fetched images are always verified, even if a previous run verified them
+	verifications, err := provenance.Verify(ctx, image1, image2, flagInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify images: %v", err)
+	}
+	if err := mountImages(ctx, image1, image2, flagInfo); err != nil {
+		return nil, err
+	}
+	imageDiff, err := diffImages(ctx, image1, image2, verifications, analyzers, flagInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := flagInfo.Checkpoint.Remove(); err != nil {
+		return nil, err
+	}
+	return &Diff{ImageDiff: imageDiff, Image1: image1, Image2: image2}, nil
+}
+
+// Analyze finds all differences of two images, or the info of a single image.
+// The images are downloaded or exported as needed into temporary directories
+// under the working directory, and removed before Analyze returns.
+// Input:
+//   (context.Context) ctx - Context used to cancel the analysis
+//   (ImageSpec) image1 - The first image
+//   (ImageSpec) image2 - The second image, zero value to analyze a single image
+//   (Options) options - The analysis preferences
+// Output:
+//   (*Diff) diff - The differences of the images
+func Analyze(ctx context.Context, image1, image2 ImageSpec, options Options) (*Diff, error) {
+	flagInfo, err := options.flagInfo(image1, image2)
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeWithFlags(ctx, flagInfo)
+}
@@ -0,0 +1,184 @@
+package imageanalyzer
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/provenance"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// test Options.flagInfo function
+func TestOptionsFlagInfo(t *testing.T) {
+	filter := regexp.MustCompile("^/etc/")
+	for _, tc := range []struct {
+		name    string
+		image1  ImageSpec
+		image2  ImageSpec
+		options Options
+		want    *input.FlagInfo
+		wantErr bool
+	}{
+		{
+			name:   "Defaults",
+			image1: ImageSpec{Source: Local, Path: "image1/disk.raw"},
+			image2: ImageSpec{Source: Local, Path: "image2/disk.raw"},
+			want: &input.FlagInfo{
+				Image1:                "image1/disk.raw",
+				Image2:                "image2/disk.raw",
+				LocalPtr:              true,
+				BinaryTypesSelected:   input.BinaryDiffTypes,
+				CompressRootfsSlice:   input.DefaultCompressRootfs,
+				CompressStatefulSlice: input.DefaultCompressStateful,
+				Threads:               runtime.NumCPU(),
+				OutputSelected:        "terminal",
+			},
+		},
+		{
+			name:   "SingleCosCloudImage",
+			image1: ImageSpec{Source: CosCloud, Path: "81"},
+			options: Options{
+				BinaryTypes:      []string{"Rootfs"},
+				StatefulAnalysis: true,
+				Package:          true,
+				PublicKey:        "key.pem",
+				ProjectID:        "my-project",
+				CompressRootfs:   []string{"/usr/"},
+				Filter:           filter,
+				MetadataMode:     "report",
+				Threads:          2,
+			},
+			want: &input.FlagInfo{
+				Image1:                "81",
+				CosCloudPtr:           true,
+				ProjectIDPtr:          "my-project",
+				Verify:                true,
+				PublicKeyPtr:          "key.pem",
+				BinaryTypesSelected:   []string{"Rootfs", "Stateful-partition"},
+				StatefulAnalysis:      true,
+				PackageSelected:       true,
+				CompressRootfsSlice:   []string{"/usr/"},
+				CompressStatefulSlice: input.DefaultCompressStateful,
+				FilterRegexp:          filter,
+				MetadataMode:          "report",
+				Threads:               2,
+				OutputSelected:        "terminal",
+			},
+		},
+		{name: "NoPath", image1: ImageSpec{Source: Local}, wantErr: true},
+		{name: "InvalidSource", image1: ImageSpec{Source: "s3", Path: "image"}, wantErr: true},
+		{name: "MixedSources", image1: ImageSpec{Source: Local, Path: "disk.raw"}, image2: ImageSpec{Source: GCS, Path: "gs://bucket/image.tar.gz"}, wantErr: true},
+		{name: "IdenticalImages", image1: ImageSpec{Source: GCS, Path: "gs://bucket/image.tar.gz"}, image2: ImageSpec{Source: GCS, Path: "gs://bucket/image.tar.gz"}, wantErr: true},
+		{name: "InvalidBinaryType", image1: ImageSpec{Source: Local, Path: "disk.raw"}, options: Options{BinaryTypes: []string{"Kernel"}}, wantErr: true},
+		{name: "InvalidDeltaTool", image1: ImageSpec{Source: Local, Path: "disk.raw"}, options: Options{DeltaTool: "rsync"}, wantErr: true},
+		{name: "InvalidMetadataMode", image1: ImageSpec{Source: Local, Path: "disk.raw"}, options: Options{MetadataMode: "strict"}, wantErr: true},
+		{name: "ClassifySingleImage", image1: ImageSpec{Source: Local, Path: "disk.raw"}, options: Options{Classify: true}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.options.flagInfo(tc.image1, tc.image2)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error but none returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("flagInfo failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(regexp.Regexp{})); diff != "" {
+				t.Fatalf("flagInfo returned unexpected FlagInfo (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// loadFlagInfo returns the FlagInfo of an analysis checkpointed to the state file at statePath
+func loadFlagInfo(t *testing.T, statePath string) *input.FlagInfo {
+	flagInfo := &input.FlagInfo{
+		Image1:              "81",
+		Image2:              "85",
+		CosCloudPtr:         true,
+		BinaryTypesSelected: []string{"Version", "Rootfs"},
+		StateFile:           statePath,
+	}
+	if err := loadCheckpoint(flagInfo); err != nil {
+		t.Fatalf("loadCheckpoint failed: %v", err)
+	}
+	return flagInfo
+}
+
+// test resumeAnalysis function
+func TestResumeAnalysis(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	builtins := []Analyzer{binaryAnalyzer{}, packageAnalyzer{}, gceMetadataAnalyzer{}, toolboxAnalyzer{}}
+	image1 := &input.ImageInfo{TempDir: "cos-81-12871-119-0", Version: "81", BuildID: "12871.119.0", LoopDevice3: "/dev/loop3"}
+	image2 := &input.ImageInfo{TempDir: "cos-85-13310-1041-9", Version: "85", BuildID: "13310.1041.9", LoopDevice3: "/dev/loop4"}
+	verifications := []*provenance.Verification{{Image: "81", SHA256: "abc"}}
+	binaryDiff := &binary.Differences{Version: []string{"81", "85"}, Rootfs: "Files differ"}
+
+	// The Binary, Package and GCE-metadata analyzers complete before the analysis is interrupted
+	flagInfo := loadFlagInfo(t, statePath)
+	if diff, err := resumeAnalysis(context.Background(), builtins, flagInfo); err != nil || diff != nil {
+		t.Fatalf("resumeAnalysis of a new analysis expected no differences, got: %v, %v", diff, err)
+	}
+	if err := checkpointImages(image1, image2, verifications, flagInfo); err != nil {
+		t.Fatalf("checkpointImages failed: %v", err)
+	}
+	if err := flagInfo.Checkpoint.Put(binaryAnalyzer{}.checkpointStep(), binaryDiff); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for _, analyzer := range builtins[1:3] {
+		if err := analyzer.Analyze(context.Background(), image1, image2, flagInfo, &output.ImageDiff{}); err != nil {
+			t.Fatalf("Analyze of %v failed: %v", analyzer.Name(), err)
+		}
+	}
+
+	// The Toolbox analyzer still needs the images
+	flagInfo = loadFlagInfo(t, statePath)
+	if diff, err := resumeAnalysis(context.Background(), builtins, flagInfo); err != nil || diff != nil {
+		t.Fatalf("resumeAnalysis with an interrupted analyzer expected no differences, got: %v, %v", diff, err)
+	}
+	if err := builtins[3].Analyze(context.Background(), image1, image2, flagInfo, &output.ImageDiff{}); err != nil {
+		t.Fatalf("Analyze of Toolbox failed: %v", err)
+	}
+
+	// Every analyzer completed, so the images are neither fetched nor mounted
+	flagInfo = loadFlagInfo(t, statePath)
+	diff, err := resumeAnalysis(context.Background(), builtins, flagInfo)
+	if err != nil {
+		t.Fatalf("resumeAnalysis failed: %v", err)
+	}
+	if diff == nil {
+		t.Fatalf("resumeAnalysis expected the checkpointed differences, got none")
+	}
+	if got, want := diff.Image1, checkpointedInfo(image1); !cmp.Equal(got, want) {
+		t.Errorf("resumeAnalysis expected image1:\n%+v\ngot:\n%+v", want, got)
+	}
+	if diff.Image1.LoopDevice3 != "" {
+		t.Errorf("resumeAnalysis expected an image without mounts, got loop device %v", diff.Image1.LoopDevice3)
+	}
+	if !cmp.Equal(diff.BinaryDiff, binaryDiff) || !cmp.Equal(diff.Verifications, verifications) {
+		t.Errorf("resumeAnalysis expected:\n%+v %+v\ngot:\n%+v %+v", binaryDiff, verifications, diff.BinaryDiff, diff.Verifications)
+	}
+
+	// A registered analyzer is never checkpointed
+	if diff, err := resumeAnalysis(context.Background(), append(builtins, fakeAnalyzer{name: "Hardening"}), flagInfo); err != nil || diff != nil {
+		t.Fatalf("resumeAnalysis with a registered analyzer expected no differences, got: %v, %v", diff, err)
+	}
+
+	// The steps are dropped once the images turn out to be others
+	image2.TempDir = "cos-85-13310-1100-0"
+	if err := checkpointImages(image1, image2, verifications, flagInfo); err != nil {
+		t.Fatalf("checkpointImages failed: %v", err)
+	}
+	if completed := flagInfo.Checkpoint.Completed(); completed != 2 {
+		t.Errorf("checkpointImages of other images expected 2 completed steps, got %d", completed)
+	}
+}
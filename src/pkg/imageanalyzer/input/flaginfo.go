@@ -0,0 +1,131 @@
+package input
+
+import (
+	"regexp"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/checkpoint"
+)
+
+// FlagInfo holds input preference from the user
+type FlagInfo struct {
+	// Args
+	Image1 string
+	Image2 string
+
+	// Input Types
+	LocalPtr    bool
+	GcsPtr      bool
+	CosCloudPtr bool
+
+	// Authentication
+	ProjectIDPtr string
+
+	// Binary
+	BinaryDiffPtr       string
+	BinaryTypesSelected []string
+	// Tool used to size the delta of differing Rootfs files ("bsdiff" or "xdelta3").
+	// Empty (default) disables delta sizing.
+	DeltaTool string
+	// If true, the stateful partition is mounted and its persisted configs and
+	// docker state (images and containers) are compared as well. Default false.
+	StatefulAnalysis bool
+	// If true, the provenance of each image is verified before diffing. Set by
+	// "-verify" or implied by ChecksumsPtr or PublicKeyPtr.
+	Verify bool
+	// Local or "gs://" path to a "sha256sum" format manifest listing image checksums
+	ChecksumsPtr string
+	// Local path to a PEM encoded public key used to verify "[image].sig" signatures
+	PublicKeyPtr string
+	// Package
+	PackageSelected bool
+	// If true, the default toolbox image and the debug utility versions shipped
+	// on each image are compared. Default false.
+	ToolboxSelected bool
+	// If true, every difference is counted into the kernel, userspace, firmware
+	// or config bucket and a summary per bucket is shown. Default false.
+	ClassifySelected bool
+	// Commit
+	CommitSelected bool
+	// Release Notes
+	ReleaseNotesSelected bool
+
+	// Verbosity of output
+	// If true, full Rootfs, Os-Config, and Stateful Partition output is shown.
+	// Else false (default), Rootfs and Stateful Partition directories listed on files
+	// 	pointed to by CompressRootfsFile and CompressStatefulFile respectively are compressed.
+	// 	For OS-configs difference, all /etc entries that are listed in CompressRootfsFile are ignored.
+	Verbose bool
+
+	// If true (default), OS-config differences of known config formats (sshd_config,
+	// PAM files, nsswitch.conf, json and toml files) are compared structurally so that
+	// reordered but equivalent files are not reported as changed.
+	SemanticConfigs bool
+
+	// File used to compress directories in the output from Rootfs difference and
+	// for ignore entries under /etc for OS-Config difference
+	// (either user provided or default CompressRootfs.txt)
+	CompressRootfsFile string
+	// Slice of CompressRootfsFile
+	CompressRootfsSlice []string
+
+	// File used to compress directories in the output from Stateful-partition difference
+	// (either user provided or default CompressStateful.txt)
+	CompressStatefulFile string
+	// Slice of CompressRootfsFile
+	CompressStatefulSlice []string
+
+	// Regexes applied to the paths of Rootfs, Stateful-partition, and OS-config
+	// differences after they are computed. Only paths matching FilterPtr are kept
+	// and paths matching ExcludePtr are dropped.
+	FilterPtr     string
+	ExcludePtr    string
+	FilterRegexp  *regexp.Regexp
+	ExcludeRegexp *regexp.Regexp
+
+	// Maximum duration of the whole analysis (download, mount and diff). Zero
+	// (default) means no timeout. The images are cleaned up when it expires.
+	Timeout time.Duration
+
+	// Maximum number of concurrent workers of the download, extraction, mount and
+	// diff phases. Defaults to the number of CPUs.
+	Threads int
+
+	// State file the completed steps of the analysis are checkpointed to, so that
+	// an interrupted analysis resumes from them. Empty (default) disables
+	// checkpointing. Checkpoint is the loaded state, nil if disabled.
+	StateFile  string
+	Checkpoint *checkpoint.State
+
+	// If true, the analysis runs on a GCE VM and its output is streamed back, for
+	// machines that can not loop mount images. Set by "-remote" or implied by
+	// RemoteVM. RemoteVM is an existing stopped VM, empty to provision a transient
+	// VM of type RemoteMachineType in RemoteZone.
+	Remote            bool
+	RemoteVM          string
+	RemoteZone        string
+	RemoteMachineType string
+	// "gs://" path of the linux/amd64 build of this tool that is run on the VM
+	RemoteBinary string
+	// Flags and image arguments passed to the tool on the VM
+	RemoteArgs []string
+
+	// If true, differences that only come from the version and build number
+	// embedded in files and paths are shown. Else false (default), they are suppressed.
+	ShowVersionChurn bool
+
+	// "ignore" compares Rootfs and Stateful-partition files by content hash only.
+	// "report" also reports permission, ownership and xattr changes as their own
+	// category. Empty (default) uses the "diff -rq" content difference.
+	MetadataMode string
+
+	// Output
+	OutputSelected string
+	// BigQuery table ("project.dataset.table") the differences are exported to.
+	// Empty (default) disables the export.
+	BigQueryTablePtr string
+	// Difference categories reported as passing test cases of the "junit" output
+	// (Ex: "Version,BuildID"), and their slice
+	ExpectedDiffsPtr string
+	ExpectedDiffs    []string
+}
@@ -2,6 +2,7 @@ package input
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,9 +12,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gce"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+	"google.golang.org/api/compute/v1"
 )
 
 const gcsObjFormat = ".tar.gz"
@@ -23,11 +29,14 @@ const imageFormat = "vmdk"
 const name = "gcr.io/compute-image-tools/gce_vm_image_export:release"
 const pathToKernelConfigs = "usr/src/linux-headers-4.19.112+/.config"
 const pathToSysctlSettings = "/etc/sysctl.d/00-sysctl.conf" // Located in partition 3 Root-A
+const resolveTimeOut = time.Second * 50
 
 // ImageInfo stores all relevant information on a COS image
 type ImageInfo struct {
 	// Input Overhead
 	TempDir          string // Temporary directory holding the mounted image and disk file
+	ImageSource      string // The image as passed in by the user (local path or "gs://bucket/object" path)
+	ImageFile        string // Path to the file the image was read from (downloaded .tar.gz or local disk.raw)
 	DiskFile         string // Path to the DOS/MBR disk partition file
 	StatePartition1  string // Path to mounted directory of partition #1, stateful partition
 	RootfsPartition3 string // Path to mounted directory of partition #3, Rootfs-A
@@ -35,6 +44,7 @@ type ImageInfo struct {
 	LoopDevice1      string // Active loop device for mounted image
 	LoopDevice3      string // Active loop device for mounted image
 	LoopDevice12     string // Active loop device for mounted image
+	GCEImage         string // Name of the resolved cos-cloud GCE image, empty if the image is not a GCE image
 
 	// Binary info
 	Version            string // Major cos version
@@ -56,6 +66,7 @@ func (image *ImageInfo) Rename(flagInfo *FlagInfo) error {
 
 		if !flagInfo.LocalPtr {
 			image.DiskFile = filepath.Join(fullImageName, "disk.raw")
+			image.ImageFile = filepath.Join(fullImageName, filepath.Base(image.ImageFile))
 		}
 		if image.StatePartition1 != "" {
 			image.StatePartition1 = filepath.Join(fullImageName, "stateful")
@@ -76,9 +87,10 @@ func (image *ImageInfo) Rename(flagInfo *FlagInfo) error {
 // MountImage is an ImagInfo method that mounts partitions 1,3 and 12 of
 // the image into the temporary directory
 // Input:
+//   (context.Context) ctx - Context used to cancel the mount
 //   (string) arr - List of binary types selected from the user
 // Output: nil on success, else error
-func (image *ImageInfo) MountImage(arr []string) error {
+func (image *ImageInfo) MountImage(ctx context.Context, arr []string) error {
 	if image.TempDir == "" {
 		return nil
 	}
@@ -89,7 +101,7 @@ func (image *ImageInfo) MountImage(arr []string) error {
 		}
 		image.StatePartition1 = stateful
 
-		loopDevice1, err := utilities.MountDisk(image.DiskFile, image.StatePartition1, "1")
+		loopDevice1, err := utilities.MountDisk(ctx, image.DiskFile, image.StatePartition1, "1")
 		if err != nil {
 			return fmt.Errorf("Failed to mount %v's partition #1 onto %v: %v", image.DiskFile, image.StatePartition1, err)
 		}
@@ -103,7 +115,7 @@ func (image *ImageInfo) MountImage(arr []string) error {
 		}
 		image.RootfsPartition3 = rootfs
 
-		loopDevice3, err := utilities.MountDisk(image.DiskFile, image.RootfsPartition3, "3")
+		loopDevice3, err := utilities.MountDisk(ctx, image.DiskFile, image.RootfsPartition3, "3")
 		if err != nil {
 			return fmt.Errorf("Failed to mount %v's partition #3 onto %v: %v", image.DiskFile, image.RootfsPartition3, err)
 		}
@@ -117,7 +129,7 @@ func (image *ImageInfo) MountImage(arr []string) error {
 		}
 		image.EFIPartition12 = efi
 
-		loopDevice12, err := utilities.MountDisk(image.DiskFile, image.EFIPartition12, "12")
+		loopDevice12, err := utilities.MountDisk(ctx, image.DiskFile, image.EFIPartition12, "12")
 		if err != nil {
 			return fmt.Errorf("Failed to mount %v's partition #12 onto %v: %v", image.DiskFile, image.EFIPartition12, err)
 		}
@@ -130,9 +142,10 @@ func (image *ImageInfo) MountImage(arr []string) error {
 // download a COS image from a GCS bucket, unzips it, and mounts relevant
 // partitions. ADC is used for authorization
 // Input:
+//   (context.Context) ctx - Context used to cancel the download
 //	 (string) gcsPath - GCS "bucket/object" path for stored COS Image (.tar.gz file)
 // Output: nil on success, else error
-func (image *ImageInfo) GetGcsImage(gcsPath string) error {
+func (image *ImageInfo) GetGcsImage(ctx context.Context, gcsPath string) error {
 	if gcsPath == "" {
 		return nil
 	}
@@ -151,18 +164,21 @@ func (image *ImageInfo) GetGcsImage(gcsPath string) error {
 		return errors.New("Error: Argument " + gcsPath + " is not a valid gcs path \"gs://<bucket>/<object_path>.tar.gz\"")
 	}
 
+	image.ImageSource = "gs://" + gcsBucket + "/" + gcsObject
+
 	tempDir, err := ioutil.TempDir(".", "tempDir") // Removed at end
 	if err != nil {
 		return fmt.Errorf("failed to create temporary directory: %v", err)
 	}
 	image.TempDir = tempDir
 
-	tarFile, err := utilities.GcsDowndload(gcsBucket, gcsObject, image.TempDir, filepath.Base(gcsObject), true)
+	tarFile, err := utilities.GcsDowndload(ctx, gcsBucket, gcsObject, image.TempDir, filepath.Base(gcsObject), true)
 	if err != nil {
 		return fmt.Errorf("failed to download GCS object %v from bucket %v: %v", gcsObject, gcsBucket, err)
 	}
+	image.ImageFile = tarFile
 
-	_, err = exec.Command("tar", "-xzf", tarFile, "-C", image.TempDir).Output()
+	_, err = exec.CommandContext(ctx, "tar", "-xzf", tarFile, "-C", image.TempDir).Output()
 	if err != nil {
 		return fmt.Errorf("failed to unzip %v into %v: %v", tarFile, image.TempDir, err)
 	}
@@ -180,6 +196,8 @@ func (image *ImageInfo) GetLocalImage(localPath string) error {
 		return nil
 	}
 	image.DiskFile = localPath
+	image.ImageSource = localPath
+	image.ImageFile = localPath
 
 	tempDir, err := ioutil.TempDir(".", "tempDir") // Removed at end
 	if err != nil {
@@ -206,11 +224,12 @@ type gcePayload struct {
 // gceExport calls the cloud build REST api that exports a public compute
 // image to a specific GCS bucket.
 // Input:
+//   (context.Context) ctx - Context used to cancel the request
 //   (string) projectID - project ID of the cloud project holding the image
 //   (string) bucket - name of the GCS bucket holding the COS Image
 //   (string) image - name of the source image to be exported
 // Output: nil on success, else error
-func gceExport(projectID, bucket, image string) error {
+func gceExport(ctx context.Context, projectID, bucket, image string) error {
 	// API Variables
 	gceURL := "https://cloudbuild.googleapis.com/v1/projects/" + projectID + "/builds"
 	destURI := "gs://" + bucket + "/" + image + "." + imageFormat
@@ -231,7 +250,12 @@ func gceExport(projectID, bucket, image string) error {
 	}
 	log.Println(string(requestBody))
 
-	resp, err := http.Post(gceURL, "application/json", bytes.NewBuffer(requestBody))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, gceURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create POST request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return fmt.Errorf("failed to make POST request: %v", err)
 	}
@@ -246,14 +270,66 @@ func gceExport(projectID, bucket, image string) error {
 	return nil
 }
 
+// milestoneShorthand matches a milestone given instead of a full image name (Ex: 97 or cos-97)
+var milestoneShorthand = regexp.MustCompile(`^(?:cos-)?(\d+)$`)
+
+// parseMilestoneShorthand returns the milestone of an image name given as
+// milestone shorthand (Ex: 97 or cos-97)
+// Input:
+//   (string) cosImage - Name of a public COS image or a milestone shorthand
+// Output:
+//   (int) milestone - The milestone of the shorthand
+//   (bool) ok - Flag to indicate cosImage is a milestone shorthand
+func parseMilestoneShorthand(cosImage string) (int, bool) {
+	match := milestoneShorthand.FindStringSubmatch(cosImage)
+	if match == nil {
+		return 0, false
+	}
+	milestone, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return milestone, true
+}
+
+// resolveCosImage resolves a milestone shorthand (Ex: 97 or cos-97) to the
+// latest image released in the milestone by calling the compute API.
+// Full image names are returned unchanged. ADC is used for authorization.
+// Input:
+//   (context.Context) ctx - Context used to cancel the request
+//   (string) cosImage - Name of a public COS image or a milestone shorthand
+// Output:
+//   (string) imageName - Name of the public COS image
+func resolveCosImage(ctx context.Context, cosImage string) (string, error) {
+	milestone, ok := parseMilestoneShorthand(cosImage)
+	if !ok {
+		return cosImage, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeOut)
+	defer cancel()
+	svc, err := compute.NewService(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create new compute client: %v", err)
+	}
+	imageName, err := gce.ResolveMilestone(ctx, svc, milestone)
+	if err != nil {
+		return "", fmt.Errorf("failed to find latest image in milestone %d: %v", milestone, err)
+	}
+	log.Printf("Resolved %v to image %v", cosImage, imageName)
+	return imageName, nil
+}
+
 // GetCosImage calls the cloud build api to export a public COS image to a
 // a GCS bucket and then calls GetGcsImage() to download that image from GCS.
+// The image may be given as a milestone shorthand (Ex: 97 or cos-97), in which
+// case the latest image released in the milestone is used.
 // ADC is used for authorization.
 // Input:
+//   (context.Context) ctx - Context used to cancel the export and download
 //   (*ImageInfo) image - A struct that holds the relevent
 //	 CosCloudPath "bucket/image" and projectID for the stored COS Image
 // Output: nil on success, else error
-func (image *ImageInfo) GetCosImage(cosCloudPath, projectID string) error {
+func (image *ImageInfo) GetCosImage(ctx context.Context, cosCloudPath, projectID string) error {
 	if cosCloudPath == "" {
 		return nil
 	}
@@ -263,19 +339,25 @@ func (image *ImageInfo) GetCosImage(cosCloudPath, projectID string) error {
 		return errors.New("Error: Argument " + cosCloudPath + " is not a valid cos-cloud path (\"/\" separators)")
 	}
 	gcsBucket := cosArray[0]
-	publicCosImage := cosArray[1]
-	if err := gceExport(projectID, gcsBucket, publicCosImage); err != nil {
+	publicCosImage, err := resolveCosImage(ctx, cosArray[1])
+	if err != nil {
+		return fmt.Errorf("failed to resolve cos image %v: %v", cosArray[1], err)
+	}
+	image.GCEImage = publicCosImage
+	if err := gceExport(ctx, projectID, gcsBucket, publicCosImage); err != nil {
 		return fmt.Errorf("failed to export %v cos image to GCS bucket %v: %v", publicCosImage, gcsBucket, err)
 	}
 
 	gcsPath := filepath.Join(gcsBucket, publicCosImage, gcsObjFormat)
-	if err := image.GetGcsImage(gcsPath); err != nil {
+	if err := image.GetGcsImage(ctx, gcsPath); err != nil {
 		return fmt.Errorf("failed to download image stored on GCS for %v: %v", gcsPath, err)
 	}
 	return nil
 }
 
-// Cleanup is a ImageInfo method that removes a mounted directory & loop device
+// Cleanup is a ImageInfo method that removes a mounted directory & loop device.
+// Every partition is unmounted even if a previous one fails, so that a partially
+// mounted image is cleaned up as much as possible.
 // Input:
 //   (*ImageInfo) image - A struct that holds the relevent info to clean up
 // Output: nil on success, else error
@@ -283,21 +365,31 @@ func (image *ImageInfo) Cleanup() error {
 	if image.TempDir == "" {
 		return nil
 	}
+	var errs []string
 	if image.LoopDevice1 != "" {
 		if err := utilities.Unmount(image.StatePartition1, image.LoopDevice1); err != nil {
-			return fmt.Errorf("failed to unmount mount directory %v and/or loop device %v: %v", image.StatePartition1, image.LoopDevice1, err)
+			errs = append(errs, fmt.Sprintf("failed to unmount mount directory %v and/or loop device %v: %v", image.StatePartition1, image.LoopDevice1, err))
+		} else {
+			image.LoopDevice1 = ""
 		}
 	}
 	if image.LoopDevice3 != "" {
 		if err := utilities.Unmount(image.RootfsPartition3, image.LoopDevice3); err != nil {
-			return fmt.Errorf("failed to unmount mount directory %v and/or loop device %v: %v", image.RootfsPartition3, image.LoopDevice3, err)
+			errs = append(errs, fmt.Sprintf("failed to unmount mount directory %v and/or loop device %v: %v", image.RootfsPartition3, image.LoopDevice3, err))
+		} else {
+			image.LoopDevice3 = ""
 		}
 	}
 	if image.LoopDevice12 != "" {
 		if err := utilities.Unmount(image.EFIPartition12, image.LoopDevice12); err != nil {
-			return fmt.Errorf("failed to unmount mount directory %v and/or loop device %v: %v", image.EFIPartition12, image.LoopDevice12, err)
+			errs = append(errs, fmt.Sprintf("failed to unmount mount directory %v and/or loop device %v: %v", image.EFIPartition12, image.LoopDevice12, err))
+		} else {
+			image.LoopDevice12 = ""
 		}
 	}
+	if len(errs) > 0 { // Never delete a directory that may still have a partition mounted in it
+		return errors.New(strings.Join(errs, "; "))
+	}
 
 	if err := os.RemoveAll(image.TempDir); err != nil {
 		return fmt.Errorf("failed to delete directory %v: %v", image.TempDir, err)
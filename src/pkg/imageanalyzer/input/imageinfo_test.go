@@ -0,0 +1,25 @@
+package input
+
+import (
+	"testing"
+)
+
+// test parseMilestoneShorthand function
+func TestParseMilestoneShorthand(t *testing.T) {
+	for _, tc := range []struct {
+		input         string
+		wantMilestone int
+		wantOk        bool
+	}{
+		{input: "97", wantMilestone: 97, wantOk: true},
+		{input: "cos-97", wantMilestone: 97, wantOk: true},
+		{input: "cos-97-16919-103-10", wantOk: false},
+		{input: "cos-stable", wantOk: false},
+		{input: "", wantOk: false},
+	} {
+		gotMilestone, gotOk := parseMilestoneShorthand(tc.input)
+		if gotMilestone != tc.wantMilestone || gotOk != tc.wantOk {
+			t.Fatalf("parseMilestoneShorthand(%v) expected: %v, %v, got: %v, %v", tc.input, tc.wantMilestone, tc.wantOk, gotMilestone, gotOk)
+		}
+	}
+}
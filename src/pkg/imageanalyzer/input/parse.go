@@ -0,0 +1,572 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// BinaryDiffTypes is a list of all valid binary differnce types
+var BinaryDiffTypes = []string{"Version", "BuildID", "Rootfs", "Kernel-command-line", "Stateful-partition", "Partition-structure", "Sysctl-settings", "OS-config", "Kernel-configs"}
+
+// OutputFormats is a list of all valid formats for the "-output" flag
+var OutputFormats = []string{"terminal", "json", "proto", "textproto", "junit"}
+
+var (
+	diffCategoriesMu sync.Mutex
+	// List of all difference categories, each a test case of the "junit" output
+	diffCategories = append(append([]string{}, BinaryDiffTypes...), "Permissions", "Package", "Toolbox", "GCE-metadata")
+)
+
+// DiffCategories returns a copy of the list of all difference categories,
+// including the ones added by registered analyzers
+func DiffCategories() []string {
+	diffCategoriesMu.Lock()
+	defer diffCategoriesMu.Unlock()
+	return append([]string{}, diffCategories...)
+}
+
+// AddDiffCategory adds the difference category of a registered analyzer
+// Input:
+//   (string) name - Name of the category, which must not be empty
+// Output: nil on success, else error if the category already exists
+func AddDiffCategory(name string) error {
+	diffCategoriesMu.Lock()
+	defer diffCategoriesMu.Unlock()
+	if name == "" {
+		return errors.New("difference category must have a name")
+	}
+	if utilities.InArray(name, diffCategories) {
+		return fmt.Errorf("difference category %v already exists", name)
+	}
+	diffCategories = append(diffCategories, name)
+	return nil
+}
+
+// DeltaTools is a list of all valid tools for the "-delta-size" flag
+var DeltaTools = []string{"bsdiff", "xdelta3"}
+
+// MetadataModes is a list of all valid modes for the "-metadata" flag
+var MetadataModes = []string{"ignore", "report"}
+
+// DefaultCompressRootfs lists the Rootfs entires that are compressed unless overridden by the "compress-rootfs" flag
+var DefaultCompressRootfs = []string{"/bin/", "/lib/modules/", "/lib64/", "/usr/libexec/", "/usr/bin/", "/usr/sbin/", "/usr/lib64/", "/usr/share/zoneinfo/", "/usr/share/git/", "/usr/lib/", "/sbin/", "/etc/ssh/", "/etc/os-release/", "/etc/package_list/"}
+
+// DefaultCompressStateful lists the Stateful entires that are compressed unless overridden by the "compress-stateful" flag
+var DefaultCompressStateful = []string{"/var_overlay/db/"}
+
+// Custom usage function. See -h flag
+func printUsage() {
+	usageTemplate := `NAME
+	cos_image_analyzer - finds all meaningful differences of two COS Images (binary and package differences).
+		If only one image is passed in, its binary info and package info will be returned.
+
+SYNOPSIS
+	%s [-local] FILE-1 [FILE-2] (default true)
+		FILE - the local file path to the DOS/MBR boot sector file of your image (Ex: disk.raw)
+		Ex: %s image-cos-77-12371-273-0/disk.raw image-cos-81-12871-119-0/disk.raw
+
+	%s -local -binary=Sysctl-settings,OS-config -package=false image-cos-77-12371-273-0/disk.raw
+
+	%s -gcs GCS-PATH-1 [GCS-PATH-2]
+		GCS-PATH - the GCS "gs://bucket/object" path for the COS Image ("object" is type .tar.gz)
+		Ex: %s -gcs gs://my-bucket/cos-images/cos-77-12371-273-0.tar.gz gs://my-bucket/cos-images/cos-81-12871-119-0.tar.gz
+
+
+DESCRIPTION
+	Input Flags:
+	-local (default true, flag is optional)
+		input is one or two DOS/MBR disk file on the local filesystem. If the images are downloaded from
+		Google Cloud as a tarball, decompress the tarball first then pass the disk.raw file to the program.
+	-gcs
+		input is one or two objects stored on Google Cloud Storage of type (.tar.gz). This flag temporarily downloads,
+		unzips, and loop device mounts the images into this tool's directory.
+		To download images from Google Cloud Storage, you need to pass a service account credential to the program.
+		Folllow https://cloud.google.com/docs/authentication/production#create_service_account to create a service account and
+		download the service account key. Then point environment variable GOOGLE_APPLICATION_CREDENTIALS to the key file then
+		run the program.
+	-cos-cloud
+		input is one or two public COS images, each given as "GCS-BUCKET/IMAGE". The image is exported to the GCS bucket
+		with Cloud Build and then downloaded like a -gcs input. Requires the -projectID flag. IMAGE is either a full image
+		name (Ex: cos-81-12871-119-0) or a milestone shorthand (Ex: 97 or cos-97) that is resolved to the latest image
+		released in that milestone with the compute API. The GCE metadata of the images (labels, licenses, guest OS
+		features, deprecation state, and creation timestamp) is also fetched and diffed as its own report section.
+		Ex: -cos-cloud -projectID=my-project my-bucket/cos-77 my-bucket/97
+	-projectID (string)
+		project ID of the Google Cloud project used to export -cos-cloud images.
+
+	Verification Flags:
+	-verify
+		before diffing, verify the provenance of each image and include the verification status per image in the
+		output. The SHA-256 digest of the image file (the downloaded .tar.gz for -gcs and -cos-cloud inputs, disk.raw
+		for local inputs) is reported and checked against -checksums and -public-key if given. Any failed check
+		stops the analysis. (default false)
+	-checksums (string)
+		local or "gs://" path to a checksum manifest in "sha256sum" format ("[hex digest]  [file name]" per line).
+		Each image must be listed by its full path or file name. Implies -verify.
+	-public-key (string)
+		local path to a PEM encoded ECDSA or RSA public key. Each image must have a detached signature over its
+		SHA-256 digest stored next to it as "[image].sig", raw or base64 encoded (Ex: from "cosign sign-blob").
+		Implies -verify.
+
+	Difference Flags:
+	-binary (string)
+		specify which type of binary difference to show. Types "Version", "BuildID", "Kernel-command-line",
+		"Partition-structure", "Sysctl-settings", and "Kernel-configs" are supported for one and two image. "Rootfs",
+		"Stateful-partition", and "OS-config" are only supported for two images. To list multiple types separate by
+		comma. To NOT list any binary difference, set flag to "false". (default all types)
+	-delta-size (string)
+		for files in the Rootfs difference that differ, compute the size of a binary patch between the two versions
+		using the given tool to quantify how much changed. Only "bsdiff" or "xdelta3" is supported, and the tool must
+		be installed on the local machine. (default disabled)
+	-stateful-analysis
+		for exported node disks, also compare what is persisted on the stateful partition: config directories
+		(/etc/, /home/kubernetes/, /var_overlay/lib/cloud/ and /var_overlay/lib/kubelet/) and docker state (images
+		and containers under /var_overlay/lib/docker/). Implies "Stateful-partition" and is only supported for two
+		images. (default false)
+	-package
+		specify whether to show package difference. Shows addition/removal of packages and package version updates.
+		To NOT list any package difference, set flag to false. (default false)
+	-toolbox
+		include flag to show the default toolbox container image (TOOLBOX_DOCKER_IMAGE and TOOLBOX_DOCKER_TAG of
+		/usr/bin/toolbox, overridden by /etc/default/toolbox) and the versions of the debug utilities shipped on the
+		image (strace, tcpdump, lsof, ethtool, iproute2, cri-tools, curl, bind-tools, busybox and sosreport).
+		Requires the Rootfs to be mounted by one of the -binary types other than "Stateful-partition",
+		"Partition-structure" and "Kernel-command-line". (default false)
+	-classify
+		include flag to count every difference into the kernel (Kernel-configs, Kernel-command-line, kernel packages,
+		and /lib/modules/ and /boot/ paths), firmware (Partition-structure, firmware and boot loader packages, and
+		/lib/firmware/ and /boot/efi/ paths), config (OS-config, Sysctl-settings, GCE metadata, and /etc/ paths) or
+		userspace (everything else) bucket, and show a per-bucket summary, so each owning team knows what to review.
+		A compressed directory counts as one difference. Only supported for two images. (default false)
+
+	Attribute Flags
+	-verbose
+		include flag to increase verbosity of Rootfs, Stateful-partition, and OS-config differences. See -compress-rootfs and
+		-compress-stateful flags descriptions for the directories that are compressed by default.
+	-semantic-configs
+		for OS-config differences, parse known config formats (sshd_config, ssh_config, PAM files under /etc/pam.d,
+		nsswitch.conf, .json and .toml files) and compare them entry by entry, so reordered but equivalent files are
+		not reported as changed. To show the raw textual difference, set flag to false. (default true)
+	-show-version-churn
+		include flag to show Rootfs and OS-config differences that only come from the version and build number
+		embedded in files and paths (Ex: /etc/os-release, /etc/motd, or a path containing 12371.273.0). By default
+		files that are equal once both images' versions are normalized, and versioned paths that only differ by
+		version, are not shown.
+	-metadata (string)
+		how file metadata is treated in Rootfs and Stateful-partition differences. "ignore" compares only the content
+		hashes of files (and the targets of symbolic links), so mtime, uid, gid, mode and xattr differences are never
+		reported. "report" additionally lists permission (mode), ownership (uid and gid) and xattr changes of entries
+		present in both images as their own "Permissions" category. Only supported for two images. (default content
+		difference of "diff -rq")
+	-compress-rootfs (string)
+		to customize which directories are compressed in a non-verbose Rootfs and OS-config difference output, provide a local
+		file path to a .txt file. Format of the file must be one root file path per line with an ending back slash and no commas.
+		By default the directory(s) that are compressed during a diff are /bin/, /lib/modules/, /lib64/, /usr/libexec/, /usr/bin/,
+		/usr/sbin/, /usr/lib64/, /usr/share/zoneinfo/, /usr/share/git/, /usr/lib/, /sbin/, /etc/ssh/, /etc/os-release/ and
+		/etc/package_list/.
+	-compress-stateful (string)
+		to customize which directories are compressed in a non-verbose Stateful-partition difference output, provide a local
+		file path to a .txt file. Format of file must be one root file path per line with no commas. By default the directory(s)
+		that are compressed during a diff are /var_overlay/db/.
+
+	Filter Flags:
+	-filter (string)
+		regular expression applied to the paths of Rootfs, Stateful-partition, and OS-config differences after the
+		difference is computed. Only differences whose path (Ex: /etc/ssh/sshd_config) matches are shown.
+		Ex: -filter='^/etc/'
+	-exclude (string)
+		regular expression applied to the paths of Rootfs, Stateful-partition, and OS-config differences after the
+		difference is computed. Differences whose path matches are not shown. Applied after -filter.
+
+	Execution Flags:
+	-timeout (duration)
+		maximum duration of the whole analysis, including downloading, mounting and diffing the images
+		(Ex: 30m, 1h30m). When it expires, or on an interrupt (Ctrl-C), running commands are cancelled and the
+		images are unmounted and removed before exiting. (default no timeout)
+	-threads (int)
+		maximum number of concurrent workers used to download and extract, mount, and diff the images (Ex: both
+		images are downloaded at the same time, and differing Rootfs files are delta sized and /etc entries are
+		diffed in parallel). Lower it on small machines to bound memory and disk IO usage. (default number of CPUs)
+	-state-file (string)
+		local path to a JSON file the Rootfs, Stateful-partition and permission differences, each OS-config /etc
+		entry difference and the result of each difference category are checkpointed to as they complete. When an
+		interrupted or failed analysis is run again with the same flags and images, the checkpointed differences
+		are reused instead of walking both images again. If every category completed, the images are not fetched
+		or mounted again, and a -cos-cloud milestone keeps the image resolved by the interrupted analysis. A state
+		file written for other images or flags is ignored, and the file is removed once the analysis completes.
+		(default disabled)
+
+	Remote Execution Flags:
+	-remote
+		run the analysis on a GCE VM and stream its output back, for machines that can not loop device mount images
+		(Ex: macOS or Windows). A transient Debian VM is created in -projectID with the "cos-image-analyzer" label
+		and deleted afterwards. The VM downloads -remote-binary and runs it with all the other flags and arguments,
+		so the images must be -gcs or -cos-cloud images and local files (-compress-rootfs, -compress-stateful,
+		-public-key, -state-file, and a local -checksums) are not supported. The default compute service account of
+		the project must be able to read the images. (default false)
+	-remote-binary (string)
+		"gs://" path of a linux/amd64 build of this tool that is run on the VM. Required by -remote.
+	-remote-vm (string)
+		name of an existing, stopped VM in -remote-zone to run on instead of a transient VM. Its startup script is
+		replaced during the analysis, then the VM is stopped and its metadata restored. Implies -remote.
+	-remote-zone (string)
+		zone of the VM. (default "us-central1-a")
+	-remote-machine-type (string)
+		machine type of the transient VM. (default "e2-standard-4")
+
+	Output Flags:
+	-output (string)
+		Specify format of output. "terminal" stdout, "json" object, binary "proto" or "textproto" encoded ImageDiff
+		message (see src/pkg/imageanalyzer/output/proto/imagediff.proto), and "junit" XML report are supported. (default "terminal")
+		In the "junit" report, each selected difference category is a test case that fails if the category differs,
+		so CI pipelines (Ex: Jenkins or Prow) can show and gate on the result. Only supported for two images.
+	-expected-diffs (string)
+		for the "junit" output, comma separated difference categories whose differences are expected and reported as
+		passing test cases. Categories are the "-binary" types, "Permissions", "Package", "Toolbox", and
+		"GCE-metadata". (default "Version,BuildID")
+	-bigquery-table (string)
+		in addition to the "-output" format, export the differences as one row into the given BigQuery table
+		"project.dataset.table". The table is created with the tool's schema if it does not exist. ADC is used for
+		authorization. (default disabled)
+
+OUTPUT
+	Based on the "-output" flag. Either "terminal" stdout or machine readable "json", "proto", "textproto" or "junit" format.
+
+NOTE
+	The root permission is needed for this program because it needs to mount images into your local filesystem to calculate difference.
+	With -remote, the root permission is only needed on the VM.
+`
+	cmd := filepath.Base(os.Args[0])
+	usage := fmt.Sprintf(usageTemplate, cmd, cmd, cmd, cmd, cmd)
+	fmt.Printf("%s", usage)
+}
+
+// FlagErrorChecking validates command-line flags stored in the FlagInfo struct
+// Input:
+//   (*FlagInfo) flagInfo - A struct that stores all flag input
+// Output: nil on success, else error
+func FlagErrorChecking(flagInfo *FlagInfo) error {
+	// Error Checking
+	if (flagInfo.LocalPtr && flagInfo.GcsPtr) || (flagInfo.LocalPtr && flagInfo.CosCloudPtr) || (flagInfo.CosCloudPtr && flagInfo.GcsPtr) {
+		return errors.New("Error: Only one input flag is allowed. Multiple appeared")
+	}
+
+	if !(flagInfo.GcsPtr) && !(flagInfo.CosCloudPtr) {
+		flagInfo.LocalPtr = true
+	}
+
+	if flagInfo.BinaryDiffPtr == "" {
+		flagInfo.BinaryTypesSelected = BinaryDiffTypes
+	} else {
+		binaryTypesSelected := strings.Split(flagInfo.BinaryDiffPtr, ",")
+		for _, elem := range binaryTypesSelected {
+			if utilities.InArray(elem, BinaryDiffTypes) {
+				flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, elem)
+			} else if elem != "false" {
+				return errors.New("Error: Invalid option for \"-binary\" flag")
+			}
+		}
+	}
+	if flagInfo.StatefulAnalysis && !utilities.InArray("Stateful-partition", flagInfo.BinaryTypesSelected) {
+		flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, "Stateful-partition")
+	}
+	if flagInfo.ChecksumsPtr != "" || flagInfo.PublicKeyPtr != "" {
+		flagInfo.Verify = true
+	}
+	if flagInfo.PublicKeyPtr != "" {
+		if _, err := os.Stat(flagInfo.PublicKeyPtr); err != nil {
+			return errors.New("Error: " + flagInfo.PublicKeyPtr + " file does not exist")
+		}
+	}
+	if flagInfo.DeltaTool != "" && !utilities.InArray(flagInfo.DeltaTool, DeltaTools) {
+		return errors.New("Error: \"-delta-size\" flag must be either \"bsdiff\" or \"xdelta3\"")
+	}
+	if flagInfo.MetadataMode != "" && !utilities.InArray(flagInfo.MetadataMode, MetadataModes) {
+		return errors.New("Error: \"-metadata\" flag must be either \"ignore\" or \"report\"")
+	}
+	if flagInfo.CompressRootfsFile != "" {
+		if res := utilities.FileExists(flagInfo.CompressRootfsFile, "txt"); res == -1 {
+			return errors.New("Error: " + flagInfo.CompressRootfsFile + " file does not exist")
+		} else if res == 0 {
+			return errors.New("Error: " + flagInfo.CompressRootfsFile + " is not a \".txt\" file")
+		}
+	}
+	if flagInfo.CompressStatefulFile != "" {
+		if res := utilities.FileExists(flagInfo.CompressStatefulFile, "txt"); res == -1 {
+			return errors.New("Error: " + flagInfo.CompressStatefulFile + " file does not exist")
+		} else if res == 0 {
+			return errors.New("Error: " + flagInfo.CompressStatefulFile + " is not a \".txt\" file")
+		}
+	}
+
+	if flagInfo.FilterPtr != "" {
+		filterRegexp, err := regexp.Compile(flagInfo.FilterPtr)
+		if err != nil {
+			return fmt.Errorf("Error: \"-filter\" flag is not a valid regular expression: %v", err)
+		}
+		flagInfo.FilterRegexp = filterRegexp
+	}
+	if flagInfo.ExcludePtr != "" {
+		excludeRegexp, err := regexp.Compile(flagInfo.ExcludePtr)
+		if err != nil {
+			return fmt.Errorf("Error: \"-exclude\" flag is not a valid regular expression: %v", err)
+		}
+		flagInfo.ExcludeRegexp = excludeRegexp
+	}
+
+	if flagInfo.Threads < 1 {
+		return errors.New("Error: \"-threads\" flag must be at least 1")
+	}
+
+	if flagInfo.RemoteVM != "" {
+		flagInfo.Remote = true
+	}
+	if flagInfo.Remote {
+		if !strings.HasPrefix(flagInfo.RemoteBinary, "gs://") {
+			return errors.New("Error: \"-remote\" flag requires a \"gs://\" path to a linux build of this tool in the \"-remote-binary\" flag")
+		}
+		if flagInfo.ProjectIDPtr == "" {
+			return errors.New("Error: \"-remote\" flag requires the \"-projectID\" flag")
+		}
+		if flagInfo.LocalPtr {
+			return errors.New("Error: \"-remote\" flag requires \"-gcs\" or \"-cos-cloud\" images")
+		}
+		if flagInfo.CompressRootfsFile != "" || flagInfo.CompressStatefulFile != "" || flagInfo.PublicKeyPtr != "" || flagInfo.StateFile != "" ||
+			(flagInfo.ChecksumsPtr != "" && !strings.HasPrefix(flagInfo.ChecksumsPtr, "gs://")) {
+			return errors.New("Error: local files can not be used with the \"-remote\" flag")
+		}
+	}
+
+	if flagInfo.Timeout < 0 {
+		return errors.New("Error: \"-timeout\" flag must not be negative")
+	}
+
+	if !utilities.InArray(flagInfo.OutputSelected, OutputFormats) {
+		return errors.New("Error: \"-output\" flag must be ethier \"terminal\", \"json\", \"proto\", \"textproto\" or \"junit\"")
+	}
+	flagInfo.ExpectedDiffs = nil
+	if flagInfo.ExpectedDiffsPtr != "" {
+		for _, elem := range strings.Split(flagInfo.ExpectedDiffsPtr, ",") {
+			if !utilities.InArray(elem, DiffCategories()) {
+				return errors.New("Error: Invalid option " + elem + " for \"-expected-diffs\" flag")
+			}
+			flagInfo.ExpectedDiffs = append(flagInfo.ExpectedDiffs, elem)
+		}
+	}
+
+	if len(flag.Args()) < 1 || len(flag.Args()) > 2 {
+		return errors.New("Error: Input must be one or two arguments")
+	}
+
+	flagInfo.Image1 = flag.Arg(0)
+	if len(flag.Args()) == 2 {
+		if flag.Arg(0) == flag.Arg(1) {
+			return errors.New("Error: Identical image passed in. To analyze single image, pass in one argument")
+		}
+		flagInfo.Image2 = flag.Arg(1)
+	}
+	if flagInfo.OutputSelected == "junit" && flagInfo.Image2 == "" {
+		return errors.New("Error: \"junit\" output requires two images")
+	}
+	if flagInfo.ClassifySelected && flagInfo.Image2 == "" {
+		return errors.New("Error: \"-classify\" flag requires two images")
+	}
+
+	return nil
+}
+
+// ParseFlags reads and validates the flags from the command-line
+// Input: None (Command-line flags and args)
+// Output:
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+func ParseFlags() (*FlagInfo, error) {
+	flagInfo := &FlagInfo{}
+
+	flag.Usage = printUsage
+	flag.BoolVar(&flagInfo.LocalPtr, "local", false, "See printUsage for description")
+	flag.BoolVar(&flagInfo.GcsPtr, "gcs", false, "")
+	flag.BoolVar(&flagInfo.CosCloudPtr, "cos-cloud", false, "")
+
+	flag.StringVar(&flagInfo.ProjectIDPtr, "projectID", "", "")
+
+	flag.BoolVar(&flagInfo.Verify, "verify", false, "")
+	flag.StringVar(&flagInfo.ChecksumsPtr, "checksums", "", "")
+	flag.StringVar(&flagInfo.PublicKeyPtr, "public-key", "", "")
+
+	flag.StringVar(&flagInfo.BinaryDiffPtr, "binary", "", "")
+	flag.StringVar(&flagInfo.DeltaTool, "delta-size", "", "")
+	flag.BoolVar(&flagInfo.StatefulAnalysis, "stateful-analysis", false, "")
+	flag.BoolVar(&flagInfo.PackageSelected, "package", false, "")
+	flag.BoolVar(&flagInfo.ToolboxSelected, "toolbox", false, "")
+	flag.BoolVar(&flagInfo.ClassifySelected, "classify", false, "")
+	flag.BoolVar(&flagInfo.CommitSelected, "commit", true, "")
+	flag.BoolVar(&flagInfo.ReleaseNotesSelected, "release-notes", true, "")
+
+	flag.BoolVar(&flagInfo.Verbose, "verbose", false, "")
+	flag.BoolVar(&flagInfo.SemanticConfigs, "semantic-configs", true, "")
+	flag.BoolVar(&flagInfo.ShowVersionChurn, "show-version-churn", false, "")
+	flag.StringVar(&flagInfo.MetadataMode, "metadata", "", "")
+	flag.StringVar(&flagInfo.CompressRootfsFile, "compress-rootfs", "", "")
+	flag.StringVar(&flagInfo.CompressStatefulFile, "compress-stateful", "", "")
+
+	flag.StringVar(&flagInfo.FilterPtr, "filter", "", "")
+	flag.StringVar(&flagInfo.ExcludePtr, "exclude", "", "")
+
+	flag.DurationVar(&flagInfo.Timeout, "timeout", 0, "")
+	flag.IntVar(&flagInfo.Threads, "threads", runtime.NumCPU(), "")
+	flag.StringVar(&flagInfo.StateFile, "state-file", "", "")
+
+	flag.BoolVar(&flagInfo.Remote, "remote", false, "")
+	flag.StringVar(&flagInfo.RemoteVM, "remote-vm", "", "")
+	flag.StringVar(&flagInfo.RemoteZone, "remote-zone", "us-central1-a", "")
+	flag.StringVar(&flagInfo.RemoteMachineType, "remote-machine-type", "e2-standard-4", "")
+	flag.StringVar(&flagInfo.RemoteBinary, "remote-binary", "", "")
+
+	flag.StringVar(&flagInfo.OutputSelected, "output", "terminal", "")
+	flag.StringVar(&flagInfo.ExpectedDiffsPtr, "expected-diffs", "Version,BuildID", "")
+	flag.StringVar(&flagInfo.BigQueryTablePtr, "bigquery-table", "", "")
+	flag.Parse()
+
+	if err := FlagErrorChecking(flagInfo); err != nil {
+		printUsage()
+		return &FlagInfo{}, err
+	}
+
+	if flagInfo.Remote { // The files below are read on the VM
+		flagInfo.RemoteArgs = remoteArgs()
+		return flagInfo, nil
+	}
+
+	if flagInfo.CompressRootfsFile != "" { // Get CompressRootfsslice
+		compressRootsBytes, err := ioutil.ReadFile(flagInfo.CompressRootfsFile)
+		if err != nil {
+			return &FlagInfo{}, fmt.Errorf("failed to read compress-rootfs file %v: %v", flagInfo.CompressRootfsFile, err)
+		}
+		flagInfo.CompressRootfsSlice = strings.Split(string(compressRootsBytes), "\n")
+	} else {
+		flagInfo.CompressRootfsSlice = DefaultCompressRootfs
+	}
+
+	if flagInfo.CompressStatefulFile != "" { // Get CompressStatefulFileSlice
+		compressedStatefulBytes, err := ioutil.ReadFile(flagInfo.CompressStatefulFile)
+		if err != nil {
+			return &FlagInfo{}, fmt.Errorf("failed to read compress-stateful file %v: %v", flagInfo.CompressStatefulFile, err)
+		}
+		flagInfo.CompressStatefulSlice = strings.Split(string(compressedStatefulBytes), "\n")
+	} else {
+		flagInfo.CompressStatefulSlice = DefaultCompressStateful
+	}
+	return flagInfo, nil
+}
+
+// remoteArgs returns the flags set on the command-line, except the "-remote"
+// flags, followed by the image arguments, to run the same analysis on a VM
+// Input: None (Command-line flags and args)
+// Output:
+//   ([]string) args - The arguments of the tool on the VM
+func remoteArgs() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "remote") {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return append(args, flag.Args()...)
+}
+
+// validateLocalImages ensures the two images are one or two unique boot files
+// Input:
+//   (string) localPath1 - Local path to the first disk.raw file
+//   (string) localPath2 - Local path to the second disk.raw file
+// Output: nil on success, else error
+func validateLocalImages(localPath1, localPath2 string) error {
+	if localPath2 == "" {
+		if res := utilities.FileExists(localPath1, "raw"); res == -1 {
+			return errors.New("Error: " + localPath1 + " file does not exist")
+		} else if res == 0 {
+			return errors.New("Error: " + localPath1 + " is not a \".raw\" file")
+		}
+		return nil
+	}
+
+	if res := utilities.FileExists(localPath2, "raw"); res == -1 {
+		return errors.New("Error: " + localPath2 + " file does not exist")
+	} else if res == 0 {
+		return errors.New("Error: " + localPath2 + " is not a \".raw\" file")
+	}
+
+	info1, _ := os.Stat(localPath1)
+	info2, _ := os.Stat(localPath2)
+	if os.SameFile(info1, info2) {
+		return errors.New("Error: Identical image passed in. To analyze single image, pass in one argument")
+	}
+	return nil
+}
+
+// GetImages reads in all the flags and handles the input based on its type.
+// Input:
+//   (context.Context) ctx - Context used to cancel downloads
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+// Output:
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+func GetImages(ctx context.Context, flagInfo *FlagInfo) (*ImageInfo, *ImageInfo, error) {
+	image1, image2 := &ImageInfo{}, &ImageInfo{}
+
+	// Input Selection
+	if flagInfo.GcsPtr {
+		gcsPath1, gcsPath2 := flagInfo.Image1, flagInfo.Image2
+
+		errs := utilities.ParallelFor(flagInfo.Threads, 2, func(i int) error {
+			return []*ImageInfo{image1, image2}[i].GetGcsImage(ctx, []string{gcsPath1, gcsPath2}[i])
+		})
+		if errs[0] != nil {
+			return image1, image2, fmt.Errorf("failed to download image stored on GCS for %s: %v", gcsPath1, errs[0])
+		}
+		if errs[1] != nil {
+			return image1, image2, fmt.Errorf("failed to download image stored on GCS for %s: %v", gcsPath2, errs[1])
+		}
+		return image1, image2, nil
+	} else if flagInfo.CosCloudPtr {
+		if flagInfo.ProjectIDPtr == "" {
+			return image1, image2, errors.New("Error: COS-cloud input requires the \"projectID\" flag to be set")
+		}
+		cosCloudPath1, cosCloudPath2 := flagInfo.Image1, flagInfo.Image2
+
+		errs := utilities.ParallelFor(flagInfo.Threads, 2, func(i int) error {
+			return []*ImageInfo{image1, image2}[i].GetCosImage(ctx, []string{cosCloudPath1, cosCloudPath2}[i], flagInfo.ProjectIDPtr)
+		})
+		if errs[0] != nil {
+			return image1, image2, fmt.Errorf("failed to get cos image for %s: %v", cosCloudPath1, errs[0])
+		}
+		if errs[1] != nil {
+			return image1, image2, fmt.Errorf("failed to get cos image for %s: %v", cosCloudPath2, errs[1])
+		}
+		return image1, image2, nil
+	} else if flagInfo.LocalPtr {
+		localPath1, localPath2 := flagInfo.Image1, flagInfo.Image2
+
+		if err := validateLocalImages(localPath1, localPath2); err != nil {
+			return image1, image2, fmt.Errorf("failed to validate local images: %v", err)
+		}
+		if err := image1.GetLocalImage(localPath1); err != nil {
+			return image1, image2, fmt.Errorf("failed to get local image for %s: %v", localPath1, err)
+		}
+		if err := image2.GetLocalImage(localPath2); err != nil {
+			return image1, image2, fmt.Errorf("failed to get local image for %s: %v", localPath2, err)
+		}
+		return image1, image2, nil
+	}
+	return image1, image2, errors.New("Error: At least one flag needs to be true")
+}
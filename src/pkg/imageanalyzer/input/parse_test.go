@@ -3,7 +3,7 @@ package input
 import (
 	"testing"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 // test FlagErrorChecking function
@@ -31,6 +31,15 @@ func TestFlagErrorChecking(t *testing.T) {
 		{input: &FlagInfo{Image1: "arg0", Image2: "", LocalPtr: true, GcsPtr: false, CosCloudPtr: false, OutputSelected: "notJsonOrTerminal"},
 			want:    &FlagInfo{},
 			wantErr: true},
+		{input: &FlagInfo{Image1: "arg0", Image2: "", LocalPtr: true, OutputSelected: "terminal", BinaryTypesSelected: []string{"BuildID"}, Threads: 0},
+			want:    &FlagInfo{},
+			wantErr: true},
+		{input: &FlagInfo{Image1: "gs://b/a.tar.gz", Image2: "", GcsPtr: true, OutputSelected: "terminal", Threads: 1, Remote: true, ProjectIDPtr: "p"},
+			want:    &FlagInfo{},
+			wantErr: true},
+		{input: &FlagInfo{Image1: "arg0", Image2: "", OutputSelected: "terminal", Threads: 1, RemoteVM: "vm", RemoteBinary: "gs://b/cos_image_analyzer", ProjectIDPtr: "p"},
+			want:    &FlagInfo{},
+			wantErr: true},
 		{input: &FlagInfo{Image1: "arg0", Image2: "", LocalPtr: false, GcsPtr: false, CosCloudPtr: false, OutputSelected: "notJsonOrTerminal", BinaryTypesSelected: []string{"BuildID"}},
 			want:    &FlagInfo{Image1: "arg0", Image2: "", LocalPtr: true, GcsPtr: false, CosCloudPtr: false, OutputSelected: "notJsonOrTerminal", BinaryTypesSelected: []string{"BuildID"}},
 			wantErr: false},
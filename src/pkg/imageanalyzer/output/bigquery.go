@@ -0,0 +1,283 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+const bigQueryTimeOut = time.Second * 50
+
+// bigQueryTableRegexp matches "project.dataset.table" or "project:dataset.table"
+var bigQueryTableRegexp = regexp.MustCompile(`^([a-z0-9-:.]+)[.:]([A-Za-z0-9_]+)\.([A-Za-z0-9_$-]+)$`)
+
+// BigQueryTable identifies the BigQuery table the image differences are exported to
+type BigQueryTable struct {
+	ProjectID string
+	DatasetID string
+	TableID   string
+}
+
+// ParseBigQueryTable parses a "project.dataset.table" or "project:dataset.table" string
+func ParseBigQueryTable(table string) (*BigQueryTable, error) {
+	match := bigQueryTableRegexp.FindStringSubmatch(table)
+	if match == nil {
+		return nil, errors.New("Error: " + table + " is not a valid BigQuery table \"project.dataset.table\"")
+	}
+	return &BigQueryTable{ProjectID: match[1], DatasetID: match[2], TableID: match[3]}, nil
+}
+
+// BigQuerySchema returns the schema of the table the image differences are
+// exported to. There is one row per analyzer run.
+func BigQuerySchema() *bigquery.TableSchema {
+	pathDiffFields := []*bigquery.TableFieldSchema{
+		{Name: "path", Type: "STRING"},
+		{Name: "diff", Type: "STRING"},
+	}
+	return &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+		{Name: "analyzed_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+		{Name: "image1", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "image2", Type: "STRING"},
+		{Name: "version1", Type: "STRING"},
+		{Name: "version2", Type: "STRING"},
+		{Name: "build_id1", Type: "STRING"},
+		{Name: "build_id2", Type: "STRING"},
+		{Name: "rootfs", Type: "STRING"},
+		{Name: "rootfs_delta_sizes", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+			{Name: "path", Type: "STRING"},
+			{Name: "delta_bytes", Type: "INTEGER"},
+			{Name: "file_bytes", Type: "INTEGER"},
+		}},
+		{Name: "os_configs", Type: "RECORD", Mode: "REPEATED", Fields: pathDiffFields},
+		{Name: "stateful", Type: "STRING"},
+		{Name: "stateful_configs", Type: "RECORD", Mode: "REPEATED", Fields: pathDiffFields},
+		{Name: "docker_state", Type: "STRING"},
+		{Name: "partition_structure", Type: "STRING"},
+		{Name: "kernel_configs", Type: "STRING"},
+		{Name: "kernel_command_line", Type: "RECORD", Mode: "REPEATED", Fields: pathDiffFields},
+		{Name: "sysctl_settings", Type: "STRING"},
+		{Name: "permissions", Type: "STRING"},
+		{Name: "package_diff", Type: "STRING"},
+		{Name: "gce_metadata", Type: "STRING"},
+		{Name: "toolbox", Type: "STRING"},
+		{Name: "custom_diffs", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+			{Name: "name", Type: "STRING"},
+			{Name: "diff", Type: "STRING"},
+		}},
+		{Name: "classification", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+			{Name: "bucket", Type: "STRING"},
+			{Name: "category", Type: "STRING"},
+			{Name: "count", Type: "INTEGER"},
+		}},
+		{Name: "verifications", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+			{Name: "image", Type: "STRING"},
+			{Name: "sha256", Type: "STRING"},
+			{Name: "checksum_status", Type: "STRING"},
+			{Name: "signature_status", Type: "STRING"},
+		}},
+	}}
+}
+
+// sortedPathDiffs converts a map of path to difference into repeated records sorted by path
+func sortedPathDiffs(diffs map[string]string) []map[string]string {
+	paths := make([]string, 0, len(diffs))
+	for path := range diffs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	records := make([]map[string]string, 0, len(paths))
+	for _, path := range paths {
+		if diffs[path] != "" {
+			records = append(records, map[string]string{"path": path, "diff": diffs[path]})
+		}
+	}
+	return records
+}
+
+// bigQueryRow converts the image differences into a row matching BigQuerySchema
+func (imageDiff *ImageDiff) bigQueryRow(image1, image2 *input.ImageInfo, analyzedAt time.Time) map[string]bigquery.JsonValue {
+	row := map[string]bigquery.JsonValue{
+		"analyzed_at": analyzedAt.UTC().Format(time.RFC3339),
+		"image1":      image1.TempDir,
+		"image2":      image2.TempDir,
+		"version1":    image1.Version,
+		"version2":    image2.Version,
+		"build_id1":   image1.BuildID,
+		"build_id2":   image2.BuildID,
+	}
+	if d := imageDiff.BinaryDiff; d != nil {
+		var deltaSizes []map[string]interface{}
+		for _, size := range d.RootfsDeltaSizes {
+			deltaSizes = append(deltaSizes, map[string]interface{}{"path": size.Path, "delta_bytes": size.DeltaBytes, "file_bytes": size.FileBytes})
+		}
+		row["rootfs"] = d.Rootfs
+		row["rootfs_delta_sizes"] = deltaSizes
+		row["os_configs"] = sortedPathDiffs(d.OSConfigs)
+		row["stateful"] = d.Stateful
+		row["stateful_configs"] = sortedPathDiffs(d.StatefulConfigs)
+		row["docker_state"] = d.DockerState
+		row["partition_structure"] = d.PartitionStructure
+		row["kernel_configs"] = d.KernelConfigs
+		row["kernel_command_line"] = sortedPathDiffs(d.KernelCommandLine)
+		row["sysctl_settings"] = d.SysctlSettings
+		row["permissions"] = d.Permissions
+	}
+	if imageDiff.PackageDiff != nil {
+		row["package_diff"] = imageDiff.PackageDiff.FormatPackageListDiff(image1.TempDir, image2.TempDir)
+	}
+	var verifications []map[string]string
+	for _, v := range imageDiff.Verifications {
+		verifications = append(verifications, map[string]string{"image": v.Image, "sha256": v.SHA256, "checksum_status": v.ChecksumStatus, "signature_status": v.SignatureStatus})
+	}
+	row["verifications"] = verifications
+	if imageDiff.GCEMetadataDiff != nil {
+		row["gce_metadata"] = imageDiff.GCEMetadataDiff.FormatGCEMetadataDiff()
+	}
+	if imageDiff.ToolboxDiff != nil {
+		row["toolbox"] = imageDiff.ToolboxDiff.FormatToolboxDiff()
+	}
+	var customDiffs []map[string]string
+	for _, customDiff := range imageDiff.CustomDiffs {
+		customDiffs = append(customDiffs, map[string]string{"name": customDiff.Name, "diff": customDiff.Diff})
+	}
+	row["custom_diffs"] = customDiffs
+	var classification []map[string]interface{}
+	for _, bucket := range classify.Buckets {
+		categories := make([]string, 0, len(imageDiff.Classification[bucket]))
+		for category := range imageDiff.Classification[bucket] {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			classification = append(classification, map[string]interface{}{"bucket": bucket, "category": category, "count": imageDiff.Classification[bucket][category]})
+		}
+	}
+	row["classification"] = classification
+	return row
+}
+
+// mergeSchema adds the fields of want missing from existing to existing,
+// including the missing subfields of existing RECORD fields. Fields are only
+// ever added, since BigQuery does not allow removing or changing columns.
+// Input:
+//   (*bigquery.TableSchema) existing - The schema of the existing table
+//   (*bigquery.TableSchema) want - The schema the rows are written with
+// Output:
+//   (*bigquery.TableSchema) merged - The existing schema with the missing fields added
+//   (bool) changed - Flag to indicate fields were added
+func mergeSchema(existing, want *bigquery.TableSchema) (*bigquery.TableSchema, bool) {
+	if existing == nil {
+		existing = &bigquery.TableSchema{}
+	}
+	fields, changed := mergeFields(existing.Fields, want.Fields)
+	return &bigquery.TableSchema{Fields: fields}, changed
+}
+
+// mergeFields adds the fields of want missing from existing to existing
+func mergeFields(existing, want []*bigquery.TableFieldSchema) ([]*bigquery.TableFieldSchema, bool) {
+	merged := make([]*bigquery.TableFieldSchema, 0, len(existing))
+	byName := make(map[string]*bigquery.TableFieldSchema)
+	changed := false
+	for _, field := range existing {
+		fieldCopy := *field
+		merged = append(merged, &fieldCopy)
+		byName[field.Name] = &fieldCopy
+	}
+	for _, field := range want {
+		existingField, ok := byName[field.Name]
+		if !ok {
+			newField := *field
+			// Existing rows have no value for a new column
+			if newField.Mode == "REQUIRED" {
+				newField.Mode = "NULLABLE"
+			}
+			merged = append(merged, &newField)
+			changed = true
+			continue
+		}
+		if existingField.Type == "RECORD" && field.Type == "RECORD" {
+			subfields, subfieldsChanged := mergeFields(existingField.Fields, field.Fields)
+			existingField.Fields = subfields
+			changed = changed || subfieldsChanged
+		}
+	}
+	return merged, changed
+}
+
+// createBigQueryTable creates the table with BigQuerySchema if it does not
+// exist yet, else adds the columns of BigQuerySchema the table is missing, so
+// that tables created by earlier versions accept the rows
+func createBigQueryTable(ctx context.Context, service *bigquery.Service, table *BigQueryTable) error {
+	existing, err := service.Tables.Get(table.ProjectID, table.DatasetID, table.TableID).Context(ctx).Do()
+	if err == nil {
+		schema, changed := mergeSchema(existing.Schema, BigQuerySchema())
+		if !changed {
+			return nil
+		}
+		patch := &bigquery.Table{Schema: schema}
+		if _, err := service.Tables.Patch(table.ProjectID, table.DatasetID, table.TableID, patch).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to add missing columns to BigQuery table %v.%v.%v: %v", table.ProjectID, table.DatasetID, table.TableID, err)
+		}
+		log.Print("Added missing columns to BigQuery table ", table.ProjectID, ".", table.DatasetID, ".", table.TableID)
+		return nil
+	}
+	if apiErr, ok := err.(*googleapi.Error); !ok || apiErr.Code != http.StatusNotFound {
+		return fmt.Errorf("failed to get BigQuery table %v.%v.%v: %v", table.ProjectID, table.DatasetID, table.TableID, err)
+	}
+	newTable := &bigquery.Table{
+		TableReference: &bigquery.TableReference{ProjectId: table.ProjectID, DatasetId: table.DatasetID, TableId: table.TableID},
+		Schema:         BigQuerySchema(),
+		Description:    "COS Image Analyzer differences, one row per comparison",
+	}
+	if _, err := service.Tables.Insert(table.ProjectID, table.DatasetID, newTable).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to create BigQuery table %v.%v.%v: %v", table.ProjectID, table.DatasetID, table.TableID, err)
+	}
+	log.Print("Created BigQuery table ", table.ProjectID, ".", table.DatasetID, ".", table.TableID)
+	return nil
+}
+
+// ExportToBigQuery is a ImageDiff method that streams the image differences
+// as a single row into a BigQuery table, creating the table or adding its
+// missing columns if needed.
+// ADC is used for authorization.
+// Input:
+//   (context.Context) ctx - Context used to cancel the export
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+//   (*BigQueryTable) table - The destination BigQuery table
+// Output: nil on success, else error
+func (imageDiff *ImageDiff) ExportToBigQuery(ctx context.Context, image1, image2 *input.ImageInfo, table *BigQueryTable) error {
+	ctx, cancel := context.WithTimeout(ctx, bigQueryTimeOut)
+	defer cancel()
+	service, err := bigquery.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create new BigQuery client: %v", err)
+	}
+	if err := createBigQueryTable(ctx, service, table); err != nil {
+		return err
+	}
+
+	request := &bigquery.TableDataInsertAllRequest{
+		Rows: []*bigquery.TableDataInsertAllRequestRows{{Json: imageDiff.bigQueryRow(image1, image2, time.Now())}},
+	}
+	resp, err := service.Tabledata.InsertAll(table.ProjectID, table.DatasetID, table.TableID, request).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to insert row into BigQuery table %v.%v.%v: %v", table.ProjectID, table.DatasetID, table.TableID, err)
+	}
+	for _, insertErr := range resp.InsertErrors {
+		for _, errProto := range insertErr.Errors {
+			return fmt.Errorf("failed to insert row into BigQuery table %v.%v.%v: %v: %v", table.ProjectID, table.DatasetID, table.TableID, errProto.Reason, errProto.Message)
+		}
+	}
+	return nil
+}
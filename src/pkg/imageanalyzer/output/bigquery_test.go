@@ -0,0 +1,136 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/bigquery/v2"
+)
+
+// test ParseBigQueryTable function
+func TestParseBigQueryTable(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		want    BigQueryTable
+		wantErr bool
+	}{
+		{input: "my-project.cos_diffs.image_diff", want: BigQueryTable{ProjectID: "my-project", DatasetID: "cos_diffs", TableID: "image_diff"}},
+		{input: "my-project:cos_diffs.image_diff", want: BigQueryTable{ProjectID: "my-project", DatasetID: "cos_diffs", TableID: "image_diff"}},
+		{input: "example.com:my-project.cos_diffs.image_diff", want: BigQueryTable{ProjectID: "example.com:my-project", DatasetID: "cos_diffs", TableID: "image_diff"}},
+		{input: "cos_diffs.image_diff", wantErr: true},
+		{input: "", wantErr: true},
+	} {
+		got, err := ParseBigQueryTable(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("ParseBigQueryTable(%v) expected error but none returned", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseBigQueryTable(%v) expected no error, got: %v", tc.input, err)
+		}
+		if *got != tc.want {
+			t.Fatalf("ParseBigQueryTable(%v) expected: %v, got: %v", tc.input, tc.want, *got)
+		}
+	}
+}
+
+// test bigQueryRow function
+func TestBigQueryRow(t *testing.T) {
+	imageDiff := &ImageDiff{
+		BinaryDiff: &binary.Differences{
+			Rootfs:    "Unique files in cos-81-12871.119.0/rootfs/usr/lib",
+			OSConfigs: map[string]string{"/etc/ssh/": "ssh diff", "/etc/docker/": "docker diff", "/etc/hosts/": ""},
+		},
+	}
+	image1 := &input.ImageInfo{TempDir: "cos-77-12371.273.0", Version: "77", BuildID: "12371.273.0"}
+	image2 := &input.ImageInfo{TempDir: "cos-81-12871.119.0", Version: "81", BuildID: "12871.119.0"}
+	row := imageDiff.bigQueryRow(image1, image2, time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC))
+
+	if got := row["analyzed_at"]; got != "2020-07-01T10:00:00Z" {
+		t.Fatalf("bigQueryRow analyzed_at expected: 2020-07-01T10:00:00Z, got: %v", got)
+	}
+	if got := row["build_id2"]; got != "12871.119.0" {
+		t.Fatalf("bigQueryRow build_id2 expected: 12871.119.0, got: %v", got)
+	}
+	osConfigs := row["os_configs"].([]map[string]string)
+	if len(osConfigs) != 2 || osConfigs[0]["path"] != "/etc/docker/" || osConfigs[1]["path"] != "/etc/ssh/" {
+		t.Fatalf("bigQueryRow os_configs expected /etc/docker/ and /etc/ssh/, got: %v", osConfigs)
+	}
+	if _, ok := row["package_diff"]; ok {
+		t.Fatalf("bigQueryRow expected no package_diff, got: %v", row["package_diff"])
+	}
+}
+
+// test mergeSchema function against the schema of a table created by an
+// earlier version
+func TestMergeSchema(t *testing.T) {
+	pathDiffFields := []*bigquery.TableFieldSchema{
+		{Name: "path", Type: "STRING"},
+		{Name: "diff", Type: "STRING"},
+	}
+	existing := &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+		{Name: "analyzed_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+		{Name: "image1", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "image2", Type: "STRING"},
+		{Name: "version1", Type: "STRING"},
+		{Name: "version2", Type: "STRING"},
+		{Name: "build_id1", Type: "STRING"},
+		{Name: "build_id2", Type: "STRING"},
+		{Name: "rootfs", Type: "STRING"},
+		{Name: "os_configs", Type: "RECORD", Mode: "REPEATED", Fields: pathDiffFields},
+		{Name: "stateful", Type: "STRING"},
+		{Name: "partition_structure", Type: "STRING"},
+		{Name: "kernel_configs", Type: "STRING"},
+		{Name: "kernel_command_line", Type: "RECORD", Mode: "REPEATED", Fields: pathDiffFields},
+		{Name: "sysctl_settings", Type: "STRING"},
+		{Name: "package_diff", Type: "STRING"},
+		{Name: "verifications", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+			{Name: "image", Type: "STRING"},
+			{Name: "sha256", Type: "STRING"},
+		}},
+		{Name: "removed_column", Type: "STRING"},
+	}}
+
+	merged, changed := mergeSchema(existing, BigQuerySchema())
+	if !changed {
+		t.Fatalf("mergeSchema expected missing columns to be added")
+	}
+	// Every column written by bigQueryRow must be in the merged schema
+	mergedFields := make(map[string]*bigquery.TableFieldSchema)
+	for _, field := range merged.Fields {
+		mergedFields[field.Name] = field
+	}
+	row := (&ImageDiff{BinaryDiff: &binary.Differences{}}).bigQueryRow(&input.ImageInfo{}, &input.ImageInfo{}, time.Now())
+	for column := range row {
+		if _, ok := mergedFields[column]; !ok {
+			t.Fatalf("mergeSchema result is missing column %v", column)
+		}
+	}
+	for i, field := range existing.Fields {
+		if merged.Fields[i].Name != field.Name {
+			t.Fatalf("mergeSchema expected existing column %v at position %v, got: %v", field.Name, i, merged.Fields[i].Name)
+		}
+	}
+	if _, ok := mergedFields["removed_column"]; !ok {
+		t.Fatalf("mergeSchema expected existing columns to be kept")
+	}
+	var verificationFields []string
+	for _, field := range mergedFields["verifications"].Fields {
+		verificationFields = append(verificationFields, field.Name)
+	}
+	if want := []string{"image", "sha256", "checksum_status", "signature_status"}; !cmp.Equal(verificationFields, want) {
+		t.Fatalf("mergeSchema verifications fields expected: %v, got: %v", want, verificationFields)
+	}
+	if len(existing.Fields[len(existing.Fields)-2].Fields) != 2 {
+		t.Fatalf("mergeSchema expected the existing schema to be left unchanged")
+	}
+
+	if _, changed := mergeSchema(merged, BigQuerySchema()); changed {
+		t.Fatalf("mergeSchema expected no change on an up to date schema")
+	}
+}
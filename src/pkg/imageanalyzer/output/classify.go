@@ -0,0 +1,40 @@
+package output
+
+import (
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+// Classify is a ImageDiff method that counts every difference into the kernel,
+// userspace, firmware or config bucket, so that each owning team can review its
+// part of an upgrade. Differences of registered analyzers are userspace differences.
+// Input:
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+// Output:
+//   (classify.Summary) summary - Number of differences of each bucket by category
+func (imageDiff *ImageDiff) Classify(image1, image2 *input.ImageInfo) classify.Summary {
+	summary := classify.Summary{}
+	imageDiff.BinaryDiff.Classify(summary, image1.RootfsPartition3, image2.RootfsPartition3, image1.StatePartition1, image2.StatePartition1)
+	imageDiff.PackageDiff.Classify(summary)
+	if d := imageDiff.ToolboxDiff; d != nil {
+		if d.ToolboxImage != nil {
+			summary.Add(classify.Userspace, "Toolbox", 1)
+		}
+		summary.Add(classify.Userspace, "Toolbox", len(d.DebugUtilities))
+	}
+	if d := imageDiff.GCEMetadataDiff; d != nil {
+		summary.Add(classify.Config, "GCE-metadata", len(d.Labels))
+		for _, pair := range [][]string{d.Licenses, d.GuestOSFeatures, d.DeprecationState, d.CreationTimestamp} {
+			if pair != nil {
+				summary.Add(classify.Config, "GCE-metadata", 1)
+			}
+		}
+	}
+	for _, customDiff := range imageDiff.CustomDiffs {
+		if customDiff.Diff != "" {
+			summary.Add(classify.Userspace, customDiff.Name, 1)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/toolbox"
+	"github.com/google/go-cmp/cmp"
+)
+
+// test Classify function
+func TestClassify(t *testing.T) {
+	image1 := &input.ImageInfo{RootfsPartition3: "cos-77-12371-273-0/rootfs", StatePartition1: "cos-77-12371-273-0/stateful"}
+	image2 := &input.ImageInfo{RootfsPartition3: "cos-81-12871-119-0/rootfs", StatePartition1: "cos-81-12871-119-0/stateful"}
+	imageDiff := &ImageDiff{
+		BinaryDiff: &binary.Differences{
+			Version: []string{"77", "81"},
+			Rootfs: "Files in cos-77-12371-273-0/rootfs/lib/modules and cos-81-12871-119-0/rootfs/lib/modules differ\n" +
+				"Only in cos-81-12871-119-0/rootfs/lib/firmware: i915\n" +
+				"Files cos-77-12371-273-0/rootfs/usr/bin/docker and cos-81-12871-119-0/rootfs/usr/bin/docker differ",
+			OSConfigs:         map[string]string{"/etc/ssh/": "ssh diff", "/etc/os-release/": ""},
+			KernelConfigs:     "< CONFIG_BPF=y",
+			KernelCommandLine: map[string]string{"cros_efi": "d\n< cros_efi"},
+			Permissions:       "Permissions of cos-77-12371-273-0/stateful/etc/x and cos-81-12871-119-0/stateful/etc/x differ: mode -rw-r--r-- -> -rw-------",
+		},
+		ToolboxDiff: &toolbox.Differences{DebugUtilities: map[string][]string{"strace": {"5.3-r1", "5.10-r1"}}},
+		CustomDiffs: []*CustomDiff{{Name: "Hardening", Diff: "sshd PermitRootLogin changed"}, {Name: "Empty"}},
+	}
+	want := classify.Summary{
+		classify.Kernel:    {"Rootfs": 1, "Kernel-configs": 1, "Kernel-command-line": 1},
+		classify.Firmware:  {"Rootfs": 1},
+		classify.Userspace: {"Rootfs": 1, "Toolbox": 1, "Hardening": 1},
+		classify.Config:    {"OS-config": 1, "Permissions": 1},
+	}
+	if diff := cmp.Diff(want, imageDiff.Classify(image1, image2)); diff != "" {
+		t.Errorf("Classify returned unexpected summary (-want +got):\n%v", diff)
+	}
+}
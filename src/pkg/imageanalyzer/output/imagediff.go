@@ -0,0 +1,150 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/gcemetadata"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/provenance"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/toolbox"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// ImageDiff stores all of the differences between the two images
+type ImageDiff struct {
+	BinaryDiff      *binary.Differences
+	PackageDiff     *packagediff.Differences
+	GCEMetadataDiff *gcemetadata.Differences
+	ToolboxDiff     *toolbox.Differences
+	Verifications   []*provenance.Verification
+	CustomDiffs     []*CustomDiff
+	Classification  classify.Summary
+}
+
+// CustomDiff is the difference found by an analyzer registered by a downstream
+// consumer of the imageanalyzer library
+type CustomDiff struct {
+	Name string // Name of the analyzer, which is also its difference category
+	Diff string // Formated difference, or info of a single image. Empty if none.
+}
+
+// binaryFormatFunctions maps each binary difference type to its format function
+func (imageDiff *ImageDiff) binaryFormatFunctions() map[string]func() string {
+	return map[string]func() string{
+		"Version":             imageDiff.BinaryDiff.FormatVersionDiff,
+		"BuildID":             imageDiff.BinaryDiff.FormatBuildIDDiff,
+		"Rootfs":              imageDiff.BinaryDiff.FormatRootfsDiff,
+		"Stateful-partition":  imageDiff.BinaryDiff.FormatStatefulDiff,
+		"OS-config":           imageDiff.BinaryDiff.FormatOSConfigDiff,
+		"Partition-structure": imageDiff.BinaryDiff.FormatPartitionStructureDiff,
+		"Kernel-configs":      imageDiff.BinaryDiff.FormatKernelConfigsDiff,
+		"Kernel-command-line": imageDiff.BinaryDiff.FormatKernelCommandLineDiff,
+		"Sysctl-settings":     imageDiff.BinaryDiff.FormatSysctlSettingsDiff,
+	}
+}
+
+// Formater is a ImageDiff function that outputs the image differences based on the "-output" flag.
+// Either to the terminal (default), to a stored json object, to an encoded ImageDiff proto message, or to a JUnit XML report
+// Input:
+//   (string) image1 - Temp directory name of image1
+//   (string) image2 - Temp directory name of image2
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+// Output:
+//   ([]string) diffstrings/jsonObjectStr - Based on "-output" flag, either formated string
+//   for the terminal, a string json object, a binary or text encoded proto message, or a JUnit XML report
+func (imageDiff *ImageDiff) Formater(image1, image2 string, flagInfo *input.FlagInfo) (string, error) {
+	if flagInfo.OutputSelected == "terminal" {
+		binaryStrings := ""
+		binaryFunctions := imageDiff.binaryFormatFunctions()
+		for _, diff := range input.BinaryDiffTypes {
+			if utilities.InArray(diff, flagInfo.BinaryTypesSelected) {
+				binaryStrings += binaryFunctions[diff]()
+			}
+		}
+
+		binaryStrings += imageDiff.BinaryDiff.FormatPermissionsDiff()
+
+		if len(binaryStrings) > 0 {
+			if flagInfo.Image2 == "" {
+				binaryStrings = "================= Binary Info =================\nImage: " + image1 + "\n" + binaryStrings
+			} else {
+				binaryStrings = "================= Binary Differences =================\nImages: " + image1 + " and " + image2 + "\n" + binaryStrings
+			}
+		}
+
+		packageStrings := imageDiff.PackageDiff.FormatPackageListDiff(image1, image2)
+		if len(packageStrings) > 0 {
+			if flagInfo.Image2 == "" {
+				packageStrings = "================= Package List =================\nImage: " + image1 + "\n" + packageStrings
+			} else {
+				packageStrings = "================= Package Differences =================\nImages: " + image1 + " and " + image2 + "\n" + packageStrings
+			}
+		}
+
+		gceMetadataStrings := imageDiff.GCEMetadataDiff.FormatGCEMetadataDiff()
+		if len(gceMetadataStrings) > 0 {
+			if flagInfo.Image2 == "" {
+				gceMetadataStrings = "================= GCE Image Metadata =================\nImage: " + image1 + "\n" + gceMetadataStrings
+			} else {
+				gceMetadataStrings = "================= GCE Image Metadata Differences =================\nImages: " + image1 + " and " + image2 + "\n" + gceMetadataStrings
+			}
+		}
+
+		toolboxStrings := imageDiff.ToolboxDiff.FormatToolboxDiff()
+		if len(toolboxStrings) > 0 {
+			if flagInfo.Image2 == "" {
+				toolboxStrings = "================= Toolbox and Debug Utilities =================\nImage: " + image1 + "\n" + toolboxStrings
+			} else {
+				toolboxStrings = "================= Toolbox and Debug Utilities Differences =================\nImages: " + image1 + " and " + image2 + "\n" + toolboxStrings
+			}
+		}
+
+		verificationStrings := provenance.FormatVerifications(imageDiff.Verifications)
+		if len(verificationStrings) > 0 {
+			verificationStrings = "================= Image Verification =================\n" + verificationStrings
+		}
+
+		customStrings := ""
+		for _, customDiff := range imageDiff.CustomDiffs {
+			if customDiff.Diff == "" {
+				continue
+			}
+			if flagInfo.Image2 == "" {
+				customStrings += "================= " + customDiff.Name + " =================\nImage: " + image1 + "\n"
+			} else {
+				customStrings += "================= " + customDiff.Name + " Differences =================\nImages: " + image1 + " and " + image2 + "\n"
+			}
+			customStrings += strings.TrimRight(customDiff.Diff, "\n") + "\n\n"
+		}
+
+		classificationStrings := ""
+		if imageDiff.Classification != nil {
+			classificationStrings = "================= Change Classification =================\nImages: " + image1 + " and " + image2 + "\n" + imageDiff.Classification.Format() + "\n"
+		}
+
+		diffStrings := verificationStrings + classificationStrings + binaryStrings + packageStrings + toolboxStrings + gceMetadataStrings + customStrings
+		return diffStrings, nil
+	}
+	if flagInfo.OutputSelected == "proto" || flagInfo.OutputSelected == "textproto" {
+		return imageDiff.formatProto(image1, image2, flagInfo.OutputSelected)
+	}
+	if flagInfo.OutputSelected == "junit" {
+		return imageDiff.formatJUnit(image1, image2, flagInfo)
+	}
+	jsonObjectBytes, err := json.Marshal(imageDiff)
+	if err != nil {
+		return "", fmt.Errorf("failed to json marshal the image difference struct: %v", err)
+	}
+	jsonObjectStr := string(jsonObjectBytes[:])
+	return jsonObjectStr, nil
+}
+
+// Print is a ImageDiff method that prints out all image differences
+func (imageDiff *ImageDiff) Print(differences string) {
+	fmt.Print(differences)
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/provenance"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds one test case per difference category
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single difference category, failed if it has an unexpected difference
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitFailure holds the difference of a failed test case
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// diffCategory is the formated difference of one category
+type diffCategory struct {
+	name string
+	diff string
+}
+
+// diffCategories returns the formated difference of every category selected by the flags
+// Input:
+//   (string) image1 - Temp directory name of image1
+//   (string) image2 - Temp directory name of image2
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+// Output:
+//   ([]diffCategory) categories - Categories in the order of input.DiffCategories()
+func (imageDiff *ImageDiff) diffCategories(image1, image2 string, flagInfo *input.FlagInfo) []diffCategory {
+	var categories []diffCategory
+	binaryFunctions := imageDiff.binaryFormatFunctions()
+	for _, diff := range input.BinaryDiffTypes {
+		if utilities.InArray(diff, flagInfo.BinaryTypesSelected) {
+			categories = append(categories, diffCategory{name: diff, diff: binaryFunctions[diff]()})
+		}
+	}
+	if flagInfo.MetadataMode == "report" {
+		categories = append(categories, diffCategory{name: "Permissions", diff: imageDiff.BinaryDiff.FormatPermissionsDiff()})
+	}
+	if flagInfo.PackageSelected {
+		categories = append(categories, diffCategory{name: "Package", diff: imageDiff.PackageDiff.FormatPackageListDiff(image1, image2)})
+	}
+	if flagInfo.ToolboxSelected {
+		categories = append(categories, diffCategory{name: "Toolbox", diff: imageDiff.ToolboxDiff.FormatToolboxDiff()})
+	}
+	if flagInfo.CosCloudPtr {
+		categories = append(categories, diffCategory{name: "GCE-metadata", diff: imageDiff.GCEMetadataDiff.FormatGCEMetadataDiff()})
+	}
+	for _, customDiff := range imageDiff.CustomDiffs {
+		categories = append(categories, diffCategory{name: customDiff.Name, diff: customDiff.Diff})
+	}
+	return categories
+}
+
+// formatJUnit returns the image differences as a JUnit XML report. Every
+// selected difference category is a test case that fails if the category has
+// a difference, unless it is listed in the "-expected-diffs" flag.
+// Verified images add a passing "Verification" test case.
+func (imageDiff *ImageDiff) formatJUnit(image1, image2 string, flagInfo *input.FlagInfo) (string, error) {
+	suite := junitTestSuite{Name: image1 + " vs " + image2}
+	for _, category := range imageDiff.diffCategories(image1, image2, flagInfo) {
+		testCase := junitTestCase{Name: category.name, ClassName: "cos_image_analyzer"}
+		if category.diff != "" {
+			if utilities.InArray(category.name, flagInfo.ExpectedDiffs) {
+				testCase.SystemOut = category.diff
+			} else {
+				testCase.Failure = &junitFailure{
+					Message: "unexpected " + category.name + " difference",
+					Type:    "difference",
+					Text:    category.diff,
+				}
+				suite.Failures++
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if len(imageDiff.Verifications) > 0 {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      "Verification",
+			ClassName: "cos_image_analyzer",
+			SystemOut: provenance.FormatVerifications(imageDiff.Verifications),
+		})
+	}
+	suite.Tests = len(suite.TestCases)
+
+	report := junitTestSuites{Name: "cos_image_analyzer", Tests: suite.Tests, Failures: suite.Failures, Suites: []junitTestSuite{suite}}
+	reportBytes, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to xml marshal the JUnit report: %v", err)
+	}
+	return xml.Header + string(reportBytes) + "\n", nil
+}
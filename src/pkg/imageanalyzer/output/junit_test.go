@@ -0,0 +1,71 @@
+package output
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/provenance"
+	"github.com/google/go-cmp/cmp"
+)
+
+// test formatJUnit function
+func TestFormatJUnit(t *testing.T) {
+	imageDiff := &ImageDiff{
+		BinaryDiff: &binary.Differences{
+			Version:   []string{"77", "81"},
+			BuildID:   []string{"12371.273.0", "12871.119.0"},
+			OSConfigs: map[string]string{"/etc/ssh/": "ssh diff"},
+		},
+		PackageDiff:   &packagediff.Differences{},
+		Verifications: []*provenance.Verification{{Image: "image1", SHA256: "abc", ChecksumStatus: provenance.StatusVerified, SignatureStatus: provenance.StatusUnavailable}},
+		CustomDiffs:   []*CustomDiff{{Name: "Hardening", Diff: "sshd PermitRootLogin changed"}},
+	}
+	flagInfo := &input.FlagInfo{
+		BinaryTypesSelected: []string{"Version", "BuildID", "Rootfs", "OS-config"},
+		PackageSelected:     true,
+		ExpectedDiffs:       []string{"Version", "BuildID"},
+	}
+
+	report, err := imageDiff.formatJUnit("cos-77-12371.273.0", "cos-81-12871.119.0", flagInfo)
+	if err != nil {
+		t.Fatalf("formatJUnit failed: %v", err)
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal([]byte(report), &got); err != nil {
+		t.Fatalf("failed to unmarshal JUnit report:\n%v\nerror: %v", report, err)
+	}
+	want := junitTestSuites{
+		XMLName:  xml.Name{Local: "testsuites"},
+		Name:     "cos_image_analyzer",
+		Tests:    7,
+		Failures: 2,
+		Suites: []junitTestSuite{{
+			Name:     "cos-77-12371.273.0 vs cos-81-12871.119.0",
+			Tests:    7,
+			Failures: 2,
+			TestCases: []junitTestCase{
+				{Name: "Version", ClassName: "cos_image_analyzer", SystemOut: imageDiff.BinaryDiff.FormatVersionDiff()},
+				{Name: "BuildID", ClassName: "cos_image_analyzer", SystemOut: imageDiff.BinaryDiff.FormatBuildIDDiff()},
+				{Name: "Rootfs", ClassName: "cos_image_analyzer"},
+				{Name: "OS-config", ClassName: "cos_image_analyzer", Failure: &junitFailure{
+					Message: "unexpected OS-config difference",
+					Type:    "difference",
+					Text:    imageDiff.BinaryDiff.FormatOSConfigDiff(),
+				}},
+				{Name: "Package", ClassName: "cos_image_analyzer"},
+				{Name: "Hardening", ClassName: "cos_image_analyzer", Failure: &junitFailure{
+					Message: "unexpected Hardening difference",
+					Type:    "difference",
+					Text:    "sshd PermitRootLogin changed",
+				}},
+				{Name: "Verification", ClassName: "cos_image_analyzer", SystemOut: provenance.FormatVerifications(imageDiff.Verifications)},
+			},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("formatJUnit returned unexpected report (-want +got):\n%v", diff)
+	}
+}
@@ -0,0 +1,1480 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.17.3
+// source: proto/imagediff.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PackageChange_Type int32
+
+const (
+	PackageChange_TYPE_UNSPECIFIED PackageChange_Type = 0
+	// The package is only installed on image1.
+	PackageChange_ONLY_IN_IMAGE1 PackageChange_Type = 1
+	// The package is only installed on image2.
+	PackageChange_ONLY_IN_IMAGE2 PackageChange_Type = 2
+	// The package is installed on both images with different attributes.
+	PackageChange_CHANGED PackageChange_Type = 3
+)
+
+// Enum value maps for PackageChange_Type.
+var (
+	PackageChange_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "ONLY_IN_IMAGE1",
+		2: "ONLY_IN_IMAGE2",
+		3: "CHANGED",
+	}
+	PackageChange_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"ONLY_IN_IMAGE1":   1,
+		"ONLY_IN_IMAGE2":   2,
+		"CHANGED":          3,
+	}
+)
+
+func (x PackageChange_Type) Enum() *PackageChange_Type {
+	p := new(PackageChange_Type)
+	*p = x
+	return p
+}
+
+func (x PackageChange_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PackageChange_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_imagediff_proto_enumTypes[0].Descriptor()
+}
+
+func (PackageChange_Type) Type() protoreflect.EnumType {
+	return &file_proto_imagediff_proto_enumTypes[0]
+}
+
+func (x PackageChange_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PackageChange_Type.Descriptor instead.
+func (PackageChange_Type) EnumDescriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{5, 0}
+}
+
+// ImageDiff stores all of the differences between two COS images.
+// If only one image is analyzed, image2 is empty and the differences hold the
+// binary info and package list of image1.
+type ImageDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the first image. Ex: cos-77-12371.273.0
+	Image1 string `protobuf:"bytes,1,opt,name=image1,proto3" json:"image1,omitempty"`
+	// Name of the second image. Ex: cos-81-12871.119.0
+	Image2      string       `protobuf:"bytes,2,opt,name=image2,proto3" json:"image2,omitempty"`
+	BinaryDiff  *BinaryDiff  `protobuf:"bytes,3,opt,name=binary_diff,json=binaryDiff,proto3" json:"binary_diff,omitempty"`
+	PackageDiff *PackageDiff `protobuf:"bytes,4,opt,name=package_diff,json=packageDiff,proto3" json:"package_diff,omitempty"`
+	// Cloud metadata differences, only set if the images are GCE images.
+	GceMetadataDiff *GCEMetadataDiff `protobuf:"bytes,5,opt,name=gce_metadata_diff,json=gceMetadataDiff,proto3" json:"gce_metadata_diff,omitempty"`
+	// Provenance verification results, one per image, only set with -verify.
+	Verifications []*ImageVerification `protobuf:"bytes,6,rep,name=verifications,proto3" json:"verifications,omitempty"`
+	// Toolbox and debug utility differences, only set with -toolbox.
+	ToolboxDiff *ToolboxDiff `protobuf:"bytes,7,opt,name=toolbox_diff,json=toolboxDiff,proto3" json:"toolbox_diff,omitempty"`
+	// Differences found by analyzers registered by downstream consumers of the
+	// imageanalyzer library, in registration order.
+	CustomDiffs []*CustomDiff `protobuf:"bytes,8,rep,name=custom_diffs,json=customDiffs,proto3" json:"custom_diffs,omitempty"`
+	// Number of differences of each bucket, only set with -classify.
+	Classification []*ClassificationBucket `protobuf:"bytes,9,rep,name=classification,proto3" json:"classification,omitempty"`
+}
+
+func (x *ImageDiff) Reset() {
+	*x = ImageDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImageDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageDiff) ProtoMessage() {}
+
+func (x *ImageDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageDiff.ProtoReflect.Descriptor instead.
+func (*ImageDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ImageDiff) GetImage1() string {
+	if x != nil {
+		return x.Image1
+	}
+	return ""
+}
+
+func (x *ImageDiff) GetImage2() string {
+	if x != nil {
+		return x.Image2
+	}
+	return ""
+}
+
+func (x *ImageDiff) GetBinaryDiff() *BinaryDiff {
+	if x != nil {
+		return x.BinaryDiff
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetPackageDiff() *PackageDiff {
+	if x != nil {
+		return x.PackageDiff
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetGceMetadataDiff() *GCEMetadataDiff {
+	if x != nil {
+		return x.GceMetadataDiff
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetVerifications() []*ImageVerification {
+	if x != nil {
+		return x.Verifications
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetToolboxDiff() *ToolboxDiff {
+	if x != nil {
+		return x.ToolboxDiff
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetCustomDiffs() []*CustomDiff {
+	if x != nil {
+		return x.CustomDiffs
+	}
+	return nil
+}
+
+func (x *ImageDiff) GetClassification() []*ClassificationBucket {
+	if x != nil {
+		return x.Classification
+	}
+	return nil
+}
+
+// ImageVerification stores the provenance verification result of one image.
+type ImageVerification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The image as passed in. Ex: gs://my-bucket/cos-77-12371-273-0.tar.gz
+	Image string `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	// Hex encoded SHA-256 digest of the image file.
+	Sha256 string `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// "verified", or "unavailable" if no checksum manifest was given.
+	ChecksumStatus string `protobuf:"bytes,3,opt,name=checksum_status,json=checksumStatus,proto3" json:"checksum_status,omitempty"`
+	// "verified", or "unavailable" if no public key was given.
+	SignatureStatus string `protobuf:"bytes,4,opt,name=signature_status,json=signatureStatus,proto3" json:"signature_status,omitempty"`
+}
+
+func (x *ImageVerification) Reset() {
+	*x = ImageVerification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImageVerification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageVerification) ProtoMessage() {}
+
+func (x *ImageVerification) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageVerification.ProtoReflect.Descriptor instead.
+func (*ImageVerification) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ImageVerification) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *ImageVerification) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *ImageVerification) GetChecksumStatus() string {
+	if x != nil {
+		return x.ChecksumStatus
+	}
+	return ""
+}
+
+func (x *ImageVerification) GetSignatureStatus() string {
+	if x != nil {
+		return x.SignatureStatus
+	}
+	return ""
+}
+
+// BinaryDiff stores all binary differences of two images.
+type BinaryDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Versions of image1 and image2, only set if they differ.
+	Version []string `protobuf:"bytes,1,rep,name=version,proto3" json:"version,omitempty"`
+	// Build IDs of image1 and image2, only set if they differ.
+	BuildId []string `protobuf:"bytes,2,rep,name=build_id,json=buildId,proto3" json:"build_id,omitempty"`
+	// Output of the Rootfs "diff -rq", compressed unless -verbose is set.
+	Rootfs string `protobuf:"bytes,3,opt,name=rootfs,proto3" json:"rootfs,omitempty"`
+	// Binary delta sizes of differing Rootfs files, only set with -delta-size.
+	RootfsDeltaSizes []*DeltaSize `protobuf:"bytes,4,rep,name=rootfs_delta_sizes,json=rootfsDeltaSizes,proto3" json:"rootfs_delta_sizes,omitempty"`
+	// OS-config differences keyed by /etc entry path. Ex: /etc/ssh/
+	OsConfigs map[string]string `protobuf:"bytes,5,rep,name=os_configs,json=osConfigs,proto3" json:"os_configs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Output of the stateful partition "diff -rq".
+	Stateful           string `protobuf:"bytes,6,opt,name=stateful,proto3" json:"stateful,omitempty"`
+	PartitionStructure string `protobuf:"bytes,7,opt,name=partition_structure,json=partitionStructure,proto3" json:"partition_structure,omitempty"`
+	KernelConfigs      string `protobuf:"bytes,8,opt,name=kernel_configs,json=kernelConfigs,proto3" json:"kernel_configs,omitempty"`
+	// Kernel command line differences keyed by parameter.
+	KernelCommandLine map[string]string `protobuf:"bytes,9,rep,name=kernel_command_line,json=kernelCommandLine,proto3" json:"kernel_command_line,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	SysctlSettings    string            `protobuf:"bytes,10,opt,name=sysctl_settings,json=sysctlSettings,proto3" json:"sysctl_settings,omitempty"`
+	// Persisted config differences of the stateful partitions keyed by
+	// directory, only set with -stateful-analysis. Ex: /home/kubernetes/
+	StatefulConfigs map[string]string `protobuf:"bytes,11,rep,name=stateful_configs,json=statefulConfigs,proto3" json:"stateful_configs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Docker image and container differences of the stateful partitions, only
+	// set with -stateful-analysis.
+	DockerState string `protobuf:"bytes,12,opt,name=docker_state,json=dockerState,proto3" json:"docker_state,omitempty"`
+	// Permission, ownership and xattr changes of entries present in both
+	// images, only set with -metadata=report.
+	Permissions string `protobuf:"bytes,13,opt,name=permissions,proto3" json:"permissions,omitempty"`
+}
+
+func (x *BinaryDiff) Reset() {
+	*x = BinaryDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BinaryDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinaryDiff) ProtoMessage() {}
+
+func (x *BinaryDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinaryDiff.ProtoReflect.Descriptor instead.
+func (*BinaryDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BinaryDiff) GetVersion() []string {
+	if x != nil {
+		return x.Version
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetBuildId() []string {
+	if x != nil {
+		return x.BuildId
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetRootfs() string {
+	if x != nil {
+		return x.Rootfs
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetRootfsDeltaSizes() []*DeltaSize {
+	if x != nil {
+		return x.RootfsDeltaSizes
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetOsConfigs() map[string]string {
+	if x != nil {
+		return x.OsConfigs
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetStateful() string {
+	if x != nil {
+		return x.Stateful
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetPartitionStructure() string {
+	if x != nil {
+		return x.PartitionStructure
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetKernelConfigs() string {
+	if x != nil {
+		return x.KernelConfigs
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetKernelCommandLine() map[string]string {
+	if x != nil {
+		return x.KernelCommandLine
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetSysctlSettings() string {
+	if x != nil {
+		return x.SysctlSettings
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetStatefulConfigs() map[string]string {
+	if x != nil {
+		return x.StatefulConfigs
+	}
+	return nil
+}
+
+func (x *BinaryDiff) GetDockerState() string {
+	if x != nil {
+		return x.DockerState
+	}
+	return ""
+}
+
+func (x *BinaryDiff) GetPermissions() string {
+	if x != nil {
+		return x.Permissions
+	}
+	return ""
+}
+
+// DeltaSize stores the size of a binary delta between two versions of a file.
+type DeltaSize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Path of the file relative to the root of the partition.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Size of the patch that turns image1's file into image2's.
+	DeltaBytes int64 `protobuf:"varint,2,opt,name=delta_bytes,json=deltaBytes,proto3" json:"delta_bytes,omitempty"`
+	// Size of image2's file.
+	FileBytes int64 `protobuf:"varint,3,opt,name=file_bytes,json=fileBytes,proto3" json:"file_bytes,omitempty"`
+}
+
+func (x *DeltaSize) Reset() {
+	*x = DeltaSize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeltaSize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeltaSize) ProtoMessage() {}
+
+func (x *DeltaSize) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeltaSize.ProtoReflect.Descriptor instead.
+func (*DeltaSize) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeltaSize) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DeltaSize) GetDeltaBytes() int64 {
+	if x != nil {
+		return x.DeltaBytes
+	}
+	return 0
+}
+
+func (x *DeltaSize) GetFileBytes() int64 {
+	if x != nil {
+		return x.FileBytes
+	}
+	return 0
+}
+
+// Package is a single package installed on an image.
+type Package struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Category string `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Name     string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Version  string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Revision string `protobuf:"bytes,4,opt,name=revision,proto3" json:"revision,omitempty"`
+}
+
+func (x *Package) Reset() {
+	*x = Package{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Package) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Package) ProtoMessage() {}
+
+func (x *Package) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Package.ProtoReflect.Descriptor instead.
+func (*Package) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Package) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Package) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Package) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Package) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+// PackageChange is a single package difference between two images.
+type PackageChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type PackageChange_Type `protobuf:"varint,1,opt,name=type,proto3,enum=cos_image_analyzer.PackageChange_Type" json:"type,omitempty"`
+	// The package on image1. For CHANGED packages only the name and the
+	// attributes that differ are set.
+	Image1 *Package `protobuf:"bytes,2,opt,name=image1,proto3" json:"image1,omitempty"`
+	// The package on image2. For CHANGED packages only the name and the
+	// attributes that differ are set.
+	Image2 *Package `protobuf:"bytes,3,opt,name=image2,proto3" json:"image2,omitempty"`
+}
+
+func (x *PackageChange) Reset() {
+	*x = PackageChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PackageChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PackageChange) ProtoMessage() {}
+
+func (x *PackageChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PackageChange.ProtoReflect.Descriptor instead.
+func (*PackageChange) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PackageChange) GetType() PackageChange_Type {
+	if x != nil {
+		return x.Type
+	}
+	return PackageChange_TYPE_UNSPECIFIED
+}
+
+func (x *PackageChange) GetImage1() *Package {
+	if x != nil {
+		return x.Image1
+	}
+	return nil
+}
+
+func (x *PackageChange) GetImage2() *Package {
+	if x != nil {
+		return x.Image2
+	}
+	return nil
+}
+
+// PackageDiff stores the package differences of two images.
+type PackageDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Package differences, set if two images are analyzed.
+	Changes []*PackageChange `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	// Full package list of image1, set if only one image is analyzed.
+	PackageList []*Package `protobuf:"bytes,2,rep,name=package_list,json=packageList,proto3" json:"package_list,omitempty"`
+}
+
+func (x *PackageDiff) Reset() {
+	*x = PackageDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PackageDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PackageDiff) ProtoMessage() {}
+
+func (x *PackageDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PackageDiff.ProtoReflect.Descriptor instead.
+func (*PackageDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PackageDiff) GetChanges() []*PackageChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *PackageDiff) GetPackageList() []*Package {
+	if x != nil {
+		return x.PackageList
+	}
+	return nil
+}
+
+// ValuePair holds a value of image1 and image2.
+type ValuePair struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Image1 string `protobuf:"bytes,1,opt,name=image1,proto3" json:"image1,omitempty"`
+	Image2 string `protobuf:"bytes,2,opt,name=image2,proto3" json:"image2,omitempty"`
+}
+
+func (x *ValuePair) Reset() {
+	*x = ValuePair{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ValuePair) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValuePair) ProtoMessage() {}
+
+func (x *ValuePair) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValuePair.ProtoReflect.Descriptor instead.
+func (*ValuePair) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ValuePair) GetImage1() string {
+	if x != nil {
+		return x.Image1
+	}
+	return ""
+}
+
+func (x *ValuePair) GetImage2() string {
+	if x != nil {
+		return x.Image2
+	}
+	return ""
+}
+
+// GCEMetadataDiff stores the cloud metadata differences of two GCE images.
+// Each field is only set if the values differ.
+type GCEMetadataDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Label differences keyed by label. An unset label is empty.
+	Labels map[string]*ValuePair `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Comma separated license names. Ex: cos-pcid
+	Licenses *ValuePair `protobuf:"bytes,2,opt,name=licenses,proto3" json:"licenses,omitempty"`
+	// Comma separated guest OS feature types. Ex: UEFI_COMPATIBLE
+	GuestOsFeatures *ValuePair `protobuf:"bytes,3,opt,name=guest_os_features,json=guestOsFeatures,proto3" json:"guest_os_features,omitempty"`
+	// Deprecation state, ACTIVE if the image is not deprecated.
+	DeprecationState  *ValuePair `protobuf:"bytes,4,opt,name=deprecation_state,json=deprecationState,proto3" json:"deprecation_state,omitempty"`
+	CreationTimestamp *ValuePair `protobuf:"bytes,5,opt,name=creation_timestamp,json=creationTimestamp,proto3" json:"creation_timestamp,omitempty"`
+}
+
+func (x *GCEMetadataDiff) Reset() {
+	*x = GCEMetadataDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GCEMetadataDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GCEMetadataDiff) ProtoMessage() {}
+
+func (x *GCEMetadataDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GCEMetadataDiff.ProtoReflect.Descriptor instead.
+func (*GCEMetadataDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GCEMetadataDiff) GetLabels() map[string]*ValuePair {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *GCEMetadataDiff) GetLicenses() *ValuePair {
+	if x != nil {
+		return x.Licenses
+	}
+	return nil
+}
+
+func (x *GCEMetadataDiff) GetGuestOsFeatures() *ValuePair {
+	if x != nil {
+		return x.GuestOsFeatures
+	}
+	return nil
+}
+
+func (x *GCEMetadataDiff) GetDeprecationState() *ValuePair {
+	if x != nil {
+		return x.DeprecationState
+	}
+	return nil
+}
+
+func (x *GCEMetadataDiff) GetCreationTimestamp() *ValuePair {
+	if x != nil {
+		return x.CreationTimestamp
+	}
+	return nil
+}
+
+// ToolboxDiff stores the toolbox and debug utility differences of two images.
+// Each field is only set if the values differ.
+type ToolboxDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Default toolbox container image. Ex: gcr.io/cos-cloud/toolbox:v20200603
+	ToolboxImage *ValuePair `protobuf:"bytes,1,opt,name=toolbox_image,json=toolboxImage,proto3" json:"toolbox_image,omitempty"`
+	// Debug utility version differences keyed by package name. A package that
+	// is not shipped is empty. Ex: strace: 5.3-r1
+	DebugUtilities map[string]*ValuePair `protobuf:"bytes,2,rep,name=debug_utilities,json=debugUtilities,proto3" json:"debug_utilities,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *ToolboxDiff) Reset() {
+	*x = ToolboxDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ToolboxDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolboxDiff) ProtoMessage() {}
+
+func (x *ToolboxDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolboxDiff.ProtoReflect.Descriptor instead.
+func (*ToolboxDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ToolboxDiff) GetToolboxImage() *ValuePair {
+	if x != nil {
+		return x.ToolboxImage
+	}
+	return nil
+}
+
+func (x *ToolboxDiff) GetDebugUtilities() map[string]*ValuePair {
+	if x != nil {
+		return x.DebugUtilities
+	}
+	return nil
+}
+
+// CustomDiff stores the difference found by a registered analyzer.
+type CustomDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the analyzer, which is also its difference category.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Formated difference, or info of a single image. Empty if none.
+	Diff string `protobuf:"bytes,2,opt,name=diff,proto3" json:"diff,omitempty"`
+}
+
+func (x *CustomDiff) Reset() {
+	*x = CustomDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CustomDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomDiff) ProtoMessage() {}
+
+func (x *CustomDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomDiff.ProtoReflect.Descriptor instead.
+func (*CustomDiff) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CustomDiff) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CustomDiff) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+// ClassificationBucket counts the differences of one bucket.
+type ClassificationBucket struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Kernel, Userspace, Firmware or Config.
+	Bucket string `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	// Number of differences in the bucket.
+	Total int64 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	// Number of differences in the bucket by difference category. Ex: Rootfs: 3
+	Categories map[string]int64 `protobuf:"bytes,3,rep,name=categories,proto3" json:"categories,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+}
+
+func (x *ClassificationBucket) Reset() {
+	*x = ClassificationBucket{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_imagediff_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClassificationBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassificationBucket) ProtoMessage() {}
+
+func (x *ClassificationBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_imagediff_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassificationBucket.ProtoReflect.Descriptor instead.
+func (*ClassificationBucket) Descriptor() ([]byte, []int) {
+	return file_proto_imagediff_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ClassificationBucket) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *ClassificationBucket) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ClassificationBucket) GetCategories() map[string]int64 {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+var File_proto_imagediff_proto protoreflect.FileDescriptor
+
+var file_proto_imagediff_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x64, 0x69, 0x66,
+	0x66, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x12, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x22, 0xb7, 0x04, 0x0a, 0x09,
+	0x49, 0x6d, 0x61, 0x67, 0x65, 0x44, 0x69, 0x66, 0x66, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x31, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65,
+	0x31, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x32, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x32, 0x12, 0x3f, 0x0a, 0x0b, 0x62, 0x69, 0x6e,
+	0x61, 0x72, 0x79, 0x5f, 0x64, 0x69, 0x66, 0x66, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e,
+	0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79,
+	0x7a, 0x65, 0x72, 0x2e, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x44, 0x69, 0x66, 0x66, 0x52, 0x0a,
+	0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x44, 0x69, 0x66, 0x66, 0x12, 0x42, 0x0a, 0x0c, 0x70, 0x61,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x64, 0x69, 0x66, 0x66, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1f, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61,
+	0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x44, 0x69, 0x66,
+	0x66, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x44, 0x69, 0x66, 0x66, 0x12, 0x4f,
+	0x0a, 0x11, 0x67, 0x63, 0x65, 0x5f, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x5f, 0x64,
+	0x69, 0x66, 0x66, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x63, 0x6f, 0x73, 0x5f,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x47,
+	0x43, 0x45, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x44, 0x69, 0x66, 0x66, 0x52, 0x0f,
+	0x67, 0x63, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x44, 0x69, 0x66, 0x66, 0x12,
+	0x4b, 0x0a, 0x0d, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x49, 0x6d, 0x61, 0x67,
+	0x65, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x42, 0x0a, 0x0c,
+	0x74, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x5f, 0x64, 0x69, 0x66, 0x66, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61,
+	0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x54, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x44,
+	0x69, 0x66, 0x66, 0x52, 0x0b, 0x74, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x44, 0x69, 0x66, 0x66,
+	0x12, 0x41, 0x0a, 0x0c, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x5f, 0x64, 0x69, 0x66, 0x66, 0x73,
+	0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x43, 0x75, 0x73, 0x74,
+	0x6f, 0x6d, 0x44, 0x69, 0x66, 0x66, 0x52, 0x0b, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x44, 0x69,
+	0x66, 0x66, 0x73, 0x12, 0x50, 0x0a, 0x0e, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x63, 0x6f,
+	0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72,
+	0x2e, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42,
+	0x75, 0x63, 0x6b, 0x65, 0x74, 0x52, 0x0e, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x95, 0x01, 0x0a, 0x11, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x56,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0xe5, 0x06,
+	0x0a, 0x0a, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x44, 0x69, 0x66, 0x66, 0x12, 0x18, 0x0a, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x49,
+	0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x12, 0x4b, 0x0a, 0x12, 0x72, 0x6f, 0x6f,
+	0x74, 0x66, 0x73, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x73, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x44, 0x65, 0x6c, 0x74, 0x61,
+	0x53, 0x69, 0x7a, 0x65, 0x52, 0x10, 0x72, 0x6f, 0x6f, 0x74, 0x66, 0x73, 0x44, 0x65, 0x6c, 0x74,
+	0x61, 0x53, 0x69, 0x7a, 0x65, 0x73, 0x12, 0x4c, 0x0a, 0x0a, 0x6f, 0x73, 0x5f, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x63, 0x6f, 0x73,
+	0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e,
+	0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x44, 0x69, 0x66, 0x66, 0x2e, 0x4f, 0x73, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x6f, 0x73, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x65, 0x66, 0x75, 0x6c,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x65, 0x66, 0x75, 0x6c,
+	0x12, 0x2f, 0x0a, 0x13, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74,
+	0x72, 0x75, 0x63, 0x74, 0x75, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x70,
+	0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x75, 0x72,
+	0x65, 0x12, 0x25, 0x0a, 0x0e, 0x6b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x5f, 0x63, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x6b, 0x65, 0x72, 0x6e, 0x65,
+	0x6c, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x73, 0x12, 0x65, 0x0a, 0x13, 0x6b, 0x65, 0x72, 0x6e,
+	0x65, 0x6c, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x18,
+	0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x35, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x42, 0x69, 0x6e, 0x61, 0x72,
+	0x79, 0x44, 0x69, 0x66, 0x66, 0x2e, 0x4b, 0x65, 0x72, 0x6e, 0x65, 0x6c, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x11, 0x6b, 0x65,
+	0x72, 0x6e, 0x65, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65, 0x12,
+	0x27, 0x0a, 0x0f, 0x73, 0x79, 0x73, 0x63, 0x74, 0x6c, 0x5f, 0x73, 0x65, 0x74, 0x74, 0x69, 0x6e,
+	0x67, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x73, 0x79, 0x73, 0x63, 0x74, 0x6c,
+	0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x5e, 0x0a, 0x10, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x66, 0x75, 0x6c, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x73, 0x18, 0x0b, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x33, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61,
+	0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x44, 0x69,
+	0x66, 0x66, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x66, 0x75, 0x6c, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x66, 0x75,
+	0x6c, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x6f, 0x63, 0x6b,
+	0x65, 0x72, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x64, 0x6f, 0x63, 0x6b, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x70,
+	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x1a, 0x3c, 0x0a,
+	0x0e, 0x4f, 0x73, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x44, 0x0a, 0x16, 0x4b,
+	0x65, 0x72, 0x6e, 0x65, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x1a, 0x42, 0x0a, 0x14, 0x53, 0x74, 0x61, 0x74, 0x65, 0x66, 0x75, 0x6c, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x5f, 0x0a, 0x09, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x53, 0x69,
+	0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1f, 0x0a, 0x0b, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x5f,
+	0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x64, 0x65, 0x6c,
+	0x74, 0x61, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x5f,
+	0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x66, 0x69, 0x6c,
+	0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x6f, 0x0a, 0x07, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x72,
+	0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72,
+	0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x88, 0x02, 0x0a, 0x0d, 0x50, 0x61, 0x63, 0x6b,
+	0x61, 0x67, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x3a, 0x0a, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x26, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x50, 0x61, 0x63,
+	0x6b, 0x61, 0x67, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x33, 0x0a, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x31, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61,
+	0x67, 0x65, 0x52, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x31, 0x12, 0x33, 0x0a, 0x06, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x32, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x6f, 0x73,
+	0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e,
+	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x52, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x32, 0x22,
+	0x51, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x54, 0x59, 0x50, 0x45, 0x5f,
+	0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x12, 0x0a,
+	0x0e, 0x4f, 0x4e, 0x4c, 0x59, 0x5f, 0x49, 0x4e, 0x5f, 0x49, 0x4d, 0x41, 0x47, 0x45, 0x31, 0x10,
+	0x01, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x4e, 0x4c, 0x59, 0x5f, 0x49, 0x4e, 0x5f, 0x49, 0x4d, 0x41,
+	0x47, 0x45, 0x32, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x48, 0x41, 0x4e, 0x47, 0x45, 0x44,
+	0x10, 0x03, 0x22, 0x8a, 0x01, 0x0a, 0x0b, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x44, 0x69,
+	0x66, 0x66, 0x12, 0x3b, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f,
+	0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
+	0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12,
+	0x3e, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x6c, 0x69, 0x73, 0x74, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61,
+	0x67, 0x65, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x22,
+	0x3b, 0x0a, 0x09, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x12, 0x16, 0x0a, 0x06,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x31, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x31, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x32, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x32, 0x22, 0xd4, 0x03, 0x0a,
+	0x0f, 0x47, 0x43, 0x45, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x44, 0x69, 0x66, 0x66,
+	0x12, 0x47, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x2f, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61,
+	0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x47, 0x43, 0x45, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x44, 0x69, 0x66, 0x66, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x39, 0x0a, 0x08, 0x6c, 0x69, 0x63,
+	0x65, 0x6e, 0x73, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f,
+	0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72,
+	0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x08, 0x6c, 0x69, 0x63, 0x65,
+	0x6e, 0x73, 0x65, 0x73, 0x12, 0x49, 0x0a, 0x11, 0x67, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x6f, 0x73,
+	0x5f, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1d, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c,
+	0x79, 0x7a, 0x65, 0x72, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x0f,
+	0x67, 0x75, 0x65, 0x73, 0x74, 0x4f, 0x73, 0x46, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x12,
+	0x4a, 0x0a, 0x11, 0x64, 0x65, 0x70, 0x72, 0x65, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x73,
+	0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x10, 0x64, 0x65, 0x70, 0x72, 0x65,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x4c, 0x0a, 0x12, 0x63,
+	0x72, 0x65, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x11, 0x63, 0x72, 0x65, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x1a, 0x58, 0x0a, 0x0b, 0x4c, 0x61, 0x62,
+	0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x33, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x73, 0x5f,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0x91, 0x02, 0x0a, 0x0b, 0x54, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x44,
+	0x69, 0x66, 0x66, 0x12, 0x42, 0x0a, 0x0d, 0x74, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x5f, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x73,
+	0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x0c, 0x74, 0x6f, 0x6f, 0x6c, 0x62,
+	0x6f, 0x78, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x12, 0x5c, 0x0a, 0x0f, 0x64, 0x65, 0x62, 0x75, 0x67,
+	0x5f, 0x75, 0x74, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x33, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61,
+	0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x54, 0x6f, 0x6f, 0x6c, 0x62, 0x6f, 0x78, 0x44, 0x69, 0x66,
+	0x66, 0x2e, 0x44, 0x65, 0x62, 0x75, 0x67, 0x55, 0x74, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0e, 0x64, 0x65, 0x62, 0x75, 0x67, 0x55, 0x74, 0x69, 0x6c,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x1a, 0x60, 0x0a, 0x13, 0x44, 0x65, 0x62, 0x75, 0x67, 0x55, 0x74,
+	0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x33,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e,
+	0x63, 0x6f, 0x73, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a,
+	0x65, 0x72, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x50, 0x61, 0x69, 0x72, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x34, 0x0a, 0x0a, 0x43, 0x75, 0x73, 0x74, 0x6f,
+	0x6d, 0x44, 0x69, 0x66, 0x66, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x69, 0x66,
+	0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x69, 0x66, 0x66, 0x22, 0xdd, 0x01,
+	0x0a, 0x14, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x42, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x74,
+	0x6f, 0x74, 0x61, 0x6c, 0x12, 0x58, 0x0a, 0x0a, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69,
+	0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x38, 0x2e, 0x63, 0x6f, 0x73, 0x5f, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x2e, 0x43, 0x6c,
+	0x61, 0x73, 0x73, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x75, 0x63, 0x6b,
+	0x65, 0x74, 0x2e, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x0a, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x1a, 0x3d,
+	0x0a, 0x0f, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x26, 0x0a,
+	0x1c, 0x63, 0x6f, 0x6d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x73, 0x2e,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x72, 0x50, 0x01, 0x5a,
+	0x04, 0x2e, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_imagediff_proto_rawDescOnce sync.Once
+	file_proto_imagediff_proto_rawDescData = file_proto_imagediff_proto_rawDesc
+)
+
+func file_proto_imagediff_proto_rawDescGZIP() []byte {
+	file_proto_imagediff_proto_rawDescOnce.Do(func() {
+		file_proto_imagediff_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_imagediff_proto_rawDescData)
+	})
+	return file_proto_imagediff_proto_rawDescData
+}
+
+var file_proto_imagediff_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_imagediff_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_proto_imagediff_proto_goTypes = []interface{}{
+	(PackageChange_Type)(0),      // 0: cos_image_analyzer.PackageChange.Type
+	(*ImageDiff)(nil),            // 1: cos_image_analyzer.ImageDiff
+	(*ImageVerification)(nil),    // 2: cos_image_analyzer.ImageVerification
+	(*BinaryDiff)(nil),           // 3: cos_image_analyzer.BinaryDiff
+	(*DeltaSize)(nil),            // 4: cos_image_analyzer.DeltaSize
+	(*Package)(nil),              // 5: cos_image_analyzer.Package
+	(*PackageChange)(nil),        // 6: cos_image_analyzer.PackageChange
+	(*PackageDiff)(nil),          // 7: cos_image_analyzer.PackageDiff
+	(*ValuePair)(nil),            // 8: cos_image_analyzer.ValuePair
+	(*GCEMetadataDiff)(nil),      // 9: cos_image_analyzer.GCEMetadataDiff
+	(*ToolboxDiff)(nil),          // 10: cos_image_analyzer.ToolboxDiff
+	(*CustomDiff)(nil),           // 11: cos_image_analyzer.CustomDiff
+	(*ClassificationBucket)(nil), // 12: cos_image_analyzer.ClassificationBucket
+	nil,                          // 13: cos_image_analyzer.BinaryDiff.OsConfigsEntry
+	nil,                          // 14: cos_image_analyzer.BinaryDiff.KernelCommandLineEntry
+	nil,                          // 15: cos_image_analyzer.BinaryDiff.StatefulConfigsEntry
+	nil,                          // 16: cos_image_analyzer.GCEMetadataDiff.LabelsEntry
+	nil,                          // 17: cos_image_analyzer.ToolboxDiff.DebugUtilitiesEntry
+	nil,                          // 18: cos_image_analyzer.ClassificationBucket.CategoriesEntry
+}
+var file_proto_imagediff_proto_depIdxs = []int32{
+	3,  // 0: cos_image_analyzer.ImageDiff.binary_diff:type_name -> cos_image_analyzer.BinaryDiff
+	7,  // 1: cos_image_analyzer.ImageDiff.package_diff:type_name -> cos_image_analyzer.PackageDiff
+	9,  // 2: cos_image_analyzer.ImageDiff.gce_metadata_diff:type_name -> cos_image_analyzer.GCEMetadataDiff
+	2,  // 3: cos_image_analyzer.ImageDiff.verifications:type_name -> cos_image_analyzer.ImageVerification
+	10, // 4: cos_image_analyzer.ImageDiff.toolbox_diff:type_name -> cos_image_analyzer.ToolboxDiff
+	11, // 5: cos_image_analyzer.ImageDiff.custom_diffs:type_name -> cos_image_analyzer.CustomDiff
+	12, // 6: cos_image_analyzer.ImageDiff.classification:type_name -> cos_image_analyzer.ClassificationBucket
+	4,  // 7: cos_image_analyzer.BinaryDiff.rootfs_delta_sizes:type_name -> cos_image_analyzer.DeltaSize
+	13, // 8: cos_image_analyzer.BinaryDiff.os_configs:type_name -> cos_image_analyzer.BinaryDiff.OsConfigsEntry
+	14, // 9: cos_image_analyzer.BinaryDiff.kernel_command_line:type_name -> cos_image_analyzer.BinaryDiff.KernelCommandLineEntry
+	15, // 10: cos_image_analyzer.BinaryDiff.stateful_configs:type_name -> cos_image_analyzer.BinaryDiff.StatefulConfigsEntry
+	0,  // 11: cos_image_analyzer.PackageChange.type:type_name -> cos_image_analyzer.PackageChange.Type
+	5,  // 12: cos_image_analyzer.PackageChange.image1:type_name -> cos_image_analyzer.Package
+	5,  // 13: cos_image_analyzer.PackageChange.image2:type_name -> cos_image_analyzer.Package
+	6,  // 14: cos_image_analyzer.PackageDiff.changes:type_name -> cos_image_analyzer.PackageChange
+	5,  // 15: cos_image_analyzer.PackageDiff.package_list:type_name -> cos_image_analyzer.Package
+	16, // 16: cos_image_analyzer.GCEMetadataDiff.labels:type_name -> cos_image_analyzer.GCEMetadataDiff.LabelsEntry
+	8,  // 17: cos_image_analyzer.GCEMetadataDiff.licenses:type_name -> cos_image_analyzer.ValuePair
+	8,  // 18: cos_image_analyzer.GCEMetadataDiff.guest_os_features:type_name -> cos_image_analyzer.ValuePair
+	8,  // 19: cos_image_analyzer.GCEMetadataDiff.deprecation_state:type_name -> cos_image_analyzer.ValuePair
+	8,  // 20: cos_image_analyzer.GCEMetadataDiff.creation_timestamp:type_name -> cos_image_analyzer.ValuePair
+	8,  // 21: cos_image_analyzer.ToolboxDiff.toolbox_image:type_name -> cos_image_analyzer.ValuePair
+	17, // 22: cos_image_analyzer.ToolboxDiff.debug_utilities:type_name -> cos_image_analyzer.ToolboxDiff.DebugUtilitiesEntry
+	18, // 23: cos_image_analyzer.ClassificationBucket.categories:type_name -> cos_image_analyzer.ClassificationBucket.CategoriesEntry
+	8,  // 24: cos_image_analyzer.GCEMetadataDiff.LabelsEntry.value:type_name -> cos_image_analyzer.ValuePair
+	8,  // 25: cos_image_analyzer.ToolboxDiff.DebugUtilitiesEntry.value:type_name -> cos_image_analyzer.ValuePair
+	26, // [26:26] is the sub-list for method output_type
+	26, // [26:26] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
+}
+
+func init() { file_proto_imagediff_proto_init() }
+func file_proto_imagediff_proto_init() {
+	if File_proto_imagediff_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_imagediff_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImageDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImageVerification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BinaryDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeltaSize); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Package); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PackageChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PackageDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ValuePair); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GCEMetadataDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ToolboxDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CustomDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_imagediff_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClassificationBucket); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_imagediff_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_imagediff_proto_goTypes,
+		DependencyIndexes: file_proto_imagediff_proto_depIdxs,
+		EnumInfos:         file_proto_imagediff_proto_enumTypes,
+		MessageInfos:      file_proto_imagediff_proto_msgTypes,
+	}.Build()
+	File_proto_imagediff_proto = out.File
+	file_proto_imagediff_proto_rawDesc = nil
+	file_proto_imagediff_proto_goTypes = nil
+	file_proto_imagediff_proto_depIdxs = nil
+}
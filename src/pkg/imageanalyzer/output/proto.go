@@ -0,0 +1,145 @@
+package output
+
+//go:generate protoc --go_out=:./pb -I. proto/imagediff.proto
+
+import (
+	"fmt"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// packageProto converts a package into its proto message
+func packageProto(p *packagediff.Package) *pb.Package {
+	if p == nil {
+		return nil
+	}
+	return &pb.Package{Category: p.Category, Name: p.Name, Version: p.Version, Revision: p.Revision}
+}
+
+// packageChangeTypes maps the package difference types to their proto enum
+var packageChangeTypes = map[string]pb.PackageChange_Type{
+	"image1": pb.PackageChange_ONLY_IN_IMAGE1,
+	"image2": pb.PackageChange_ONLY_IN_IMAGE2,
+	"shared": pb.PackageChange_CHANGED,
+}
+
+// valuePairProto converts an [image1 value, image2 value] pair into its proto message
+func valuePairProto(pair []string) *pb.ValuePair {
+	if len(pair) != 2 {
+		return nil
+	}
+	return &pb.ValuePair{Image1: pair[0], Image2: pair[1]}
+}
+
+// Proto is a ImageDiff method that converts the image differences into an
+// ImageDiff proto message
+// Input:
+//   (string) image1 - Temp directory name of image1
+//   (string) image2 - Temp directory name of image2
+// Output:
+//   (*pb.ImageDiff) imageDiffProto - The image differences as a proto message
+func (imageDiff *ImageDiff) Proto(image1, image2 string) *pb.ImageDiff {
+	imageDiffProto := &pb.ImageDiff{Image1: image1, Image2: image2}
+	if d := imageDiff.BinaryDiff; d != nil {
+		binaryDiff := &pb.BinaryDiff{
+			Version:            d.Version,
+			BuildId:            d.BuildID,
+			Rootfs:             d.Rootfs,
+			OsConfigs:          d.OSConfigs,
+			Stateful:           d.Stateful,
+			StatefulConfigs:    d.StatefulConfigs,
+			DockerState:        d.DockerState,
+			PartitionStructure: d.PartitionStructure,
+			KernelConfigs:      d.KernelConfigs,
+			KernelCommandLine:  d.KernelCommandLine,
+			SysctlSettings:     d.SysctlSettings,
+			Permissions:        d.Permissions,
+		}
+		for _, size := range d.RootfsDeltaSizes {
+			binaryDiff.RootfsDeltaSizes = append(binaryDiff.RootfsDeltaSizes, &pb.DeltaSize{Path: size.Path, DeltaBytes: size.DeltaBytes, FileBytes: size.FileBytes})
+		}
+		imageDiffProto.BinaryDiff = binaryDiff
+	}
+	if d := imageDiff.PackageDiff; d != nil {
+		packageDiff := &pb.PackageDiff{}
+		for _, pd := range d.PackageDiff {
+			typeOfDiff, package1, package2 := pd.Change()
+			packageDiff.Changes = append(packageDiff.Changes, &pb.PackageChange{
+				Type:   packageChangeTypes[typeOfDiff],
+				Image1: packageProto(package1),
+				Image2: packageProto(package2),
+			})
+		}
+		for i := range d.PackageList {
+			packageDiff.PackageList = append(packageDiff.PackageList, packageProto(&d.PackageList[i]))
+		}
+		imageDiffProto.PackageDiff = packageDiff
+	}
+	if d := imageDiff.GCEMetadataDiff; d != nil {
+		gceMetadataDiff := &pb.GCEMetadataDiff{
+			Licenses:          valuePairProto(d.Licenses),
+			GuestOsFeatures:   valuePairProto(d.GuestOSFeatures),
+			DeprecationState:  valuePairProto(d.DeprecationState),
+			CreationTimestamp: valuePairProto(d.CreationTimestamp),
+		}
+		if len(d.Labels) > 0 {
+			gceMetadataDiff.Labels = make(map[string]*pb.ValuePair)
+			for label, pair := range d.Labels {
+				gceMetadataDiff.Labels[label] = valuePairProto(pair)
+			}
+		}
+		imageDiffProto.GceMetadataDiff = gceMetadataDiff
+	}
+	if d := imageDiff.ToolboxDiff; d != nil {
+		toolboxDiff := &pb.ToolboxDiff{ToolboxImage: valuePairProto(d.ToolboxImage)}
+		if len(d.DebugUtilities) > 0 {
+			toolboxDiff.DebugUtilities = make(map[string]*pb.ValuePair)
+			for name, pair := range d.DebugUtilities {
+				toolboxDiff.DebugUtilities[name] = valuePairProto(pair)
+			}
+		}
+		imageDiffProto.ToolboxDiff = toolboxDiff
+	}
+	for _, v := range imageDiff.Verifications {
+		imageDiffProto.Verifications = append(imageDiffProto.Verifications, &pb.ImageVerification{
+			Image:           v.Image,
+			Sha256:          v.SHA256,
+			ChecksumStatus:  v.ChecksumStatus,
+			SignatureStatus: v.SignatureStatus,
+		})
+	}
+	if imageDiff.Classification != nil {
+		for _, bucket := range classify.Buckets {
+			bucketProto := &pb.ClassificationBucket{Bucket: bucket, Total: int64(imageDiff.Classification.Total(bucket))}
+			if counts := imageDiff.Classification[bucket]; len(counts) > 0 {
+				bucketProto.Categories = make(map[string]int64)
+				for category, count := range counts {
+					bucketProto.Categories[category] = int64(count)
+				}
+			}
+			imageDiffProto.Classification = append(imageDiffProto.Classification, bucketProto)
+		}
+	}
+	for _, customDiff := range imageDiff.CustomDiffs {
+		imageDiffProto.CustomDiffs = append(imageDiffProto.CustomDiffs, &pb.CustomDiff{Name: customDiff.Name, Diff: customDiff.Diff})
+	}
+	return imageDiffProto
+}
+
+// formatProto returns the image differences as a binary ("proto") or text
+// ("textproto") encoded ImageDiff proto message
+func (imageDiff *ImageDiff) formatProto(image1, image2, format string) (string, error) {
+	imageDiffProto := imageDiff.Proto(image1, image2)
+	if format == "textproto" {
+		return prototext.MarshalOptions{Multiline: true}.Format(imageDiffProto), nil
+	}
+	protoBytes, err := proto.Marshal(imageDiffProto)
+	if err != nil {
+		return "", fmt.Errorf("failed to proto marshal the image difference struct: %v", err)
+	}
+	return string(protoBytes), nil
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/binary"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/output/pb"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// test formatProto function
+func TestFormatProto(t *testing.T) {
+	imageDiff := &ImageDiff{
+		BinaryDiff: &binary.Differences{
+			Version:          []string{"77", "81"},
+			OSConfigs:        map[string]string{"/etc/ssh/": "ssh diff"},
+			RootfsDeltaSizes: []binary.DeltaSize{{Path: "/bin/bash", DeltaBytes: 120, FileBytes: 1024}},
+		},
+		PackageDiff: &packagediff.Differences{
+			PackageList: []packagediff.Package{{Category: "sys-apps", Name: "findutils", Version: "4.9.10", Revision: "1"}},
+		},
+	}
+	want := &pb.ImageDiff{
+		Image1: "cos-77-12371.273.0",
+		Image2: "cos-81-12871.119.0",
+		BinaryDiff: &pb.BinaryDiff{
+			Version:          []string{"77", "81"},
+			OsConfigs:        map[string]string{"/etc/ssh/": "ssh diff"},
+			RootfsDeltaSizes: []*pb.DeltaSize{{Path: "/bin/bash", DeltaBytes: 120, FileBytes: 1024}},
+		},
+		PackageDiff: &pb.PackageDiff{
+			PackageList: []*pb.Package{{Category: "sys-apps", Name: "findutils", Version: "4.9.10", Revision: "1"}},
+		},
+	}
+
+	protoStr, err := imageDiff.formatProto("cos-77-12371.273.0", "cos-81-12871.119.0", "proto")
+	if err != nil {
+		t.Fatalf("formatProto expected no error, got: %v", err)
+	}
+	got := &pb.ImageDiff{}
+	if err := proto.Unmarshal([]byte(protoStr), got); err != nil {
+		t.Fatalf("failed to unmarshal formatProto output: %v", err)
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("formatProto returned unexpected diff (-want +got):\n%s", diff)
+	}
+
+	textStr, err := imageDiff.formatProto("cos-77-12371.273.0", "cos-81-12871.119.0", "textproto")
+	if err != nil {
+		t.Fatalf("formatProto expected no error, got: %v", err)
+	}
+	if textStr == "" || textStr == protoStr {
+		t.Fatalf("formatProto expected textproto output, got: %q", textStr)
+	}
+}
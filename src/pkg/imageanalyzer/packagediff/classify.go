@@ -0,0 +1,20 @@
+package packagediff
+
+import "cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/classify"
+
+// Classify counts each package difference into the bucket of its package. The
+// category of a shared package is only known if it changed.
+// Input:
+//   (classify.Summary) summary - Counts of the differences of each bucket
+func (d *Differences) Classify(summary classify.Summary) {
+	if d == nil {
+		return
+	}
+	for _, pd := range d.PackageDiff {
+		_, package1, package2 := pd.Change()
+		if package1 == nil {
+			package1 = package2
+		}
+		summary.Add(classify.Package(package1.Category, package1.Name), "Package", 1)
+	}
+}
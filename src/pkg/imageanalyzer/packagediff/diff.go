@@ -3,7 +3,7 @@ package packagediff
 import (
 	"fmt"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
 )
 
 // PkgDiff is used to hold package difference between the two images
@@ -21,6 +21,31 @@ type Differences struct {
 	PackageList []Package // If only one image is passed in, return full package list
 }
 
+// Change returns the type of the package difference ("image1" or "image2" if the
+// package is unique to image1 or image2, "shared" if it is shared in both images)
+// and the package of each image. The package of an image is nil if the package
+// is not installed on it. For shared packages only the name and the fields that
+// differ are set.
+func (pd PkgDiff) Change() (string, *Package, *Package) {
+	switch pd.typeOFDiff {
+	case "image1":
+		return pd.typeOFDiff, &Package{Category: pd.category[0], Name: pd.name[0], Version: pd.version[0], Revision: pd.revision[0]}, nil
+	case "image2":
+		return pd.typeOFDiff, nil, &Package{Category: pd.category[0], Name: pd.name[0], Version: pd.version[0], Revision: pd.revision[0]}
+	}
+	package1, package2 := &Package{Name: pd.name[0]}, &Package{Name: pd.name[1]}
+	if len(pd.category) == 2 {
+		package1.Category, package2.Category = pd.category[0], pd.category[1]
+	}
+	if len(pd.version) == 2 {
+		package1.Version, package2.Version = pd.version[0], pd.version[1]
+	}
+	if len(pd.revision) == 2 {
+		package1.Revision, package2.Revision = pd.revision[0], pd.revision[1]
+	}
+	return pd.typeOFDiff, package1, package2
+}
+
 // searchPackageList determines whether a package name appears in a package list
 func searchPackageList(packageName string, packageList []Package) (Package, bool) {
 	for _, p := range packageList {
@@ -3,8 +3,8 @@ package packagediff
 import (
 	"testing"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
 )
 
 // test searchPackageList function
@@ -128,3 +128,34 @@ func TestDiff(t *testing.T) {
 		}
 	}
 }
+
+// test Change function
+func TestChange(t *testing.T) {
+	for _, tc := range []struct {
+		pkgDiff      PkgDiff
+		wantType     string
+		wantPackage1 *Package
+		wantPackage2 *Package
+	}{
+		{pkgDiff: PkgDiff{category: []string{"sys-apps"}, name: []string{"findutils"}, version: []string{"4.9.10"}, revision: []string{"1"}, typeOFDiff: "image1"},
+			wantType:     "image1",
+			wantPackage1: &Package{Category: "sys-apps", Name: "findutils", Version: "4.9.10", Revision: "1"}},
+		{pkgDiff: PkgDiff{category: []string{"dev-util"}, name: []string{"strace"}, version: []string{"5.5"}, revision: []string{"2"}, typeOFDiff: "image2"},
+			wantType:     "image2",
+			wantPackage2: &Package{Category: "dev-util", Name: "strace", Version: "5.5", Revision: "2"}},
+		{pkgDiff: PkgDiff{name: []string{"lakitu-kernel-4_19", "lakitu-kernel-4_19"}, version: []string{"4.20.127", "4.19.127"}, typeOFDiff: "shared"},
+			wantType:     "shared",
+			wantPackage1: &Package{Name: "lakitu-kernel-4_19", Version: "4.20.127"},
+			wantPackage2: &Package{Name: "lakitu-kernel-4_19", Version: "4.19.127"}},
+	} {
+		gotType, gotPackage1, gotPackage2 := tc.pkgDiff.Change()
+		if gotType != tc.wantType {
+			t.Fatalf("Change type expected: %v, got: %v", tc.wantType, gotType)
+		}
+		for _, pair := range [][2]*Package{{tc.wantPackage1, gotPackage1}, {tc.wantPackage2, gotPackage2}} {
+			if (pair[0] == nil) != (pair[1] == nil) || (pair[0] != nil && *pair[0] != *pair[1]) {
+				t.Fatalf("Change package expected: %v, got: %v", pair[0], pair[1])
+			}
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
 )
 
 const pathToPackageList = "/etc/package_list"
@@ -26,8 +26,8 @@ type InstalledPackages struct {
 
 // ****** NOTE ******
 // This function is a temporary implementation. Switch this out with the awaited cos-tools library function.
-// getInstalledPackages returns the package list for an image by parsing its /etc/package_list json file
-func getInstalledPackages(rootfs string) ([]Package, error) {
+// GetInstalledPackages returns the package list for an image by parsing its /etc/package_list json file
+func GetInstalledPackages(rootfs string) ([]Package, error) {
 	fullPath := filepath.Join(rootfs, pathToPackageList)
 	packageListBytes, err := ioutil.ReadFile(fullPath)
 	if err != nil {
@@ -49,7 +49,7 @@ func GetPackageInfo(image *input.ImageInfo, flagInfo *input.FlagInfo) ([]Package
 	}
 
 	if flagInfo.PackageSelected { // Get package list from /etc/package_list
-		packageList, err := getInstalledPackages(image.RootfsPartition3)
+		packageList, err := GetInstalledPackages(image.RootfsPartition3)
 		if err != nil {
 			return []Package{}, fmt.Errorf("failed to get package list from image %v: %v", image.TempDir, err)
 		}
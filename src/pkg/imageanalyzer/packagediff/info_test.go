@@ -3,7 +3,7 @@ package packagediff
 import (
 	"testing"
 
-	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
 )
 
 // test GetPackageInfo function
@@ -0,0 +1,258 @@
+package provenance
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// Verification statuses
+const (
+	// StatusVerified means the check passed
+	StatusVerified = "verified"
+	// StatusUnavailable means there was nothing to check against
+	StatusUnavailable = "unavailable"
+)
+
+// signatureSuffix is appended to the image source to find its detached signature
+const signatureSuffix = ".sig"
+
+// Verification stores the provenance verification result of a single image
+type Verification struct {
+	Image           string // The image as passed in by the user
+	SHA256          string // Hex encoded SHA-256 digest of the image file (.tar.gz or disk.raw)
+	ChecksumStatus  string // Result of checking SHA256 against the "-checksums" manifest
+	SignatureStatus string // Result of checking the detached signature with the "-public-key" key
+}
+
+// fetchFile returns a local path to a file given as a local path or a GCS
+// "gs://bucket/object" path. GCS objects are downloaded into destDir.
+func fetchFile(ctx context.Context, path, destDir string) (string, error) {
+	if !strings.HasPrefix(path, "gs://") {
+		return path, nil
+	}
+	gcsPath := strings.TrimPrefix(path, "gs://")
+	startOfObject := strings.Index(gcsPath, "/")
+	if startOfObject <= 0 || startOfObject == len(gcsPath)-1 {
+		return "", errors.New("Error: " + path + " is not a valid gcs path \"gs://<bucket>/<object_path>\"")
+	}
+	bucket, object := gcsPath[:startOfObject], gcsPath[startOfObject+1:]
+	localFile, err := utilities.GcsDowndload(ctx, bucket, object, destDir, filepath.Base(object), true)
+	if err != nil {
+		return "", fmt.Errorf("failed to download GCS object %v from bucket %v: %v", object, bucket, err)
+	}
+	return localFile, nil
+}
+
+// fileSHA256 returns the SHA-256 digest of a file
+func fileSHA256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %v: %v", path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read file %v: %v", path, err)
+	}
+	return hash.Sum(nil), nil
+}
+
+// parseChecksums parses a checksum manifest in "sha256sum" format
+// Input:
+//   (string) manifest - Contents of the manifest, one "[hex digest] [file name]" per line
+// Output:
+//   (map[string]string) checksums - Map of file name to lowercase hex digest
+func parseChecksums(manifest string) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(manifest))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum manifest line %q", line)
+		}
+		if _, err := hex.DecodeString(fields[0]); err != nil || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid SHA-256 digest in checksum manifest line %q", line)
+		}
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums, scanner.Err()
+}
+
+// verifyChecksum checks the digest of an image against the checksum manifest
+// Input:
+//   (map[string]string) checksums - Map of file name to hex digest, nil if no manifest is given
+//   (string) source - The image as passed in by the user
+//   ([]byte) digest - SHA-256 digest of the image file
+// Output:
+//   (string) status - The checksum verification status, error on mismatch
+func verifyChecksum(checksums map[string]string, source string, digest []byte) (string, error) {
+	if checksums == nil {
+		return StatusUnavailable, nil
+	}
+	want, ok := checksums[source]
+	if !ok {
+		if want, ok = checksums[filepath.Base(source)]; !ok {
+			return "", errors.New("Error: " + source + " is not listed in the checksum manifest")
+		}
+	}
+	if got := hex.EncodeToString(digest); got != want {
+		return "", fmt.Errorf("Error: SHA-256 of %v is %v, checksum manifest lists %v", source, got, want)
+	}
+	return StatusVerified, nil
+}
+
+// parsePublicKey parses a PEM encoded PKIX ECDSA or RSA public key
+func parsePublicKey(keyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("Error: public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("Error: unsupported public key type %T, only ECDSA and RSA keys are supported", key)
+}
+
+// verifySignature checks a detached signature over the SHA-256 digest of an
+// image, as created by "cosign sign-blob" or "openssl dgst -sha256 -sign"
+// Input:
+//   (crypto.PublicKey) key - ECDSA or RSA public key, nil if no key is given
+//   ([]byte) signature - Raw or base64 encoded signature
+//   ([]byte) digest - SHA-256 digest of the image file
+// Output: nil if the signature is valid, else error
+func verifySignature(key crypto.PublicKey, signature, digest []byte) error {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, signature) {
+			return errors.New("Error: invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, signature); err != nil {
+			return fmt.Errorf("Error: invalid RSA signature: %v", err)
+		}
+	default:
+		return fmt.Errorf("Error: unsupported public key type %T", key)
+	}
+	return nil
+}
+
+// verifyImage verifies the provenance of a single image
+func verifyImage(ctx context.Context, image *input.ImageInfo, checksums map[string]string, key crypto.PublicKey) (*Verification, error) {
+	digest, err := fileSHA256(image.ImageFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute SHA-256 of %v: %v", image.ImageFile, err)
+	}
+	verification := &Verification{Image: image.ImageSource, SHA256: hex.EncodeToString(digest), SignatureStatus: StatusUnavailable}
+	if verification.ChecksumStatus, err = verifyChecksum(checksums, image.ImageSource, digest); err != nil {
+		return nil, err
+	}
+	if key != nil {
+		signatureFile, err := fetchFile(ctx, image.ImageSource+signatureSuffix, image.TempDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get signature of %v: %v", image.ImageSource, err)
+		}
+		signature, err := ioutil.ReadFile(signatureFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signature file %v: %v", signatureFile, err)
+		}
+		if err := verifySignature(key, signature, digest); err != nil {
+			return nil, fmt.Errorf("failed to verify signature of %v: %v", image.ImageSource, err)
+		}
+		verification.SignatureStatus = StatusVerified
+	}
+	return verification, nil
+}
+
+// Verify checks the provenance of one or two images before they are diffed.
+// The SHA-256 digest of each image file (.tar.gz for GCS inputs, disk.raw for
+// local inputs) is checked against the "-checksums" manifest and, if a
+// "-public-key" is given, against the detached signature stored next to the
+// image as "[image].sig". Any failed check is an error.
+// Input:
+//   (context.Context) ctx - Context used to cancel downloads
+//   (*ImageInfo) image1 - A struct that stores relevent info for image1
+//   (*ImageInfo) image2 - A struct that stores relevent info for image2
+//   (*FlagInfo) flagInfo - A struct that holds input preference from the user
+// Output:
+//   ([]*Verification) verifications - The verification result of each image
+func Verify(ctx context.Context, image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) ([]*Verification, error) {
+	if !flagInfo.Verify {
+		return nil, nil
+	}
+	var checksums map[string]string
+	if flagInfo.ChecksumsPtr != "" {
+		manifestFile, err := fetchFile(ctx, flagInfo.ChecksumsPtr, image1.TempDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get checksum manifest %v: %v", flagInfo.ChecksumsPtr, err)
+		}
+		manifest, err := ioutil.ReadFile(manifestFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checksum manifest %v: %v", manifestFile, err)
+		}
+		if checksums, err = parseChecksums(string(manifest)); err != nil {
+			return nil, fmt.Errorf("failed to parse checksum manifest %v: %v", flagInfo.ChecksumsPtr, err)
+		}
+	}
+	var key crypto.PublicKey
+	if flagInfo.PublicKeyPtr != "" {
+		keyPEM, err := ioutil.ReadFile(flagInfo.PublicKeyPtr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %v: %v", flagInfo.PublicKeyPtr, err)
+		}
+		if key, err = parsePublicKey(keyPEM); err != nil {
+			return nil, err
+		}
+	}
+
+	var verifications []*Verification
+	for _, image := range []*input.ImageInfo{image1, image2} {
+		if image.TempDir == "" {
+			continue
+		}
+		verification, err := verifyImage(ctx, image, checksums, key)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications, nil
+}
+
+// FormatVerifications returns a formated string of the verification results
+func FormatVerifications(verifications []*Verification) string {
+	verificationStrings := ""
+	for _, v := range verifications {
+		verificationStrings += v.Image + "\n  SHA-256: " + v.SHA256 + "\n  Checksum: " + v.ChecksumStatus + "\n  Signature: " + v.SignatureStatus + "\n"
+	}
+	return verificationStrings
+}
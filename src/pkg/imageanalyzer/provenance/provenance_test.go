@@ -0,0 +1,153 @@
+package provenance
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+// test parseChecksums and verifyChecksum functions
+func TestVerifyChecksum(t *testing.T) {
+	digest := sha256.Sum256([]byte("disk"))
+	hexDigest := hex.EncodeToString(digest[:])
+	otherDigest := sha256.Sum256([]byte("other"))
+	manifest := "# COS images\n" + hexDigest + "  cos-77-12371-273-0.tar.gz\n" + hex.EncodeToString(otherDigest[:]) + " *cos-81-12871-119-0.tar.gz\n"
+	checksums, err := parseChecksums(manifest)
+	if err != nil {
+		t.Fatalf("parseChecksums expected no error, got: %v", err)
+	}
+	if _, err := parseChecksums("abc cos-77-12371-273-0.tar.gz"); err == nil {
+		t.Fatalf("parseChecksums expected error for invalid digest but none returned")
+	}
+
+	for _, tc := range []struct {
+		checksums map[string]string
+		source    string
+		want      string
+		wantErr   bool
+	}{
+		{checksums: nil, source: "gs://bucket/cos-77-12371-273-0.tar.gz", want: StatusUnavailable},
+		{checksums: checksums, source: "gs://bucket/cos-77-12371-273-0.tar.gz", want: StatusVerified},
+		{checksums: checksums, source: "gs://bucket/cos-81-12871-119-0.tar.gz", wantErr: true},
+		{checksums: checksums, source: "gs://bucket/cos-85-13310-1041-9.tar.gz", wantErr: true},
+	} {
+		got, err := verifyChecksum(tc.checksums, tc.source, digest[:])
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("verifyChecksum(%v) expected error but none returned", tc.source)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("verifyChecksum(%v) expected: %v, got: %v, %v", tc.source, tc.want, got, err)
+		}
+	}
+}
+
+// test verifySignature function with ECDSA and RSA keys
+func TestVerifySignature(t *testing.T) {
+	digest := sha256.Sum256([]byte("disk"))
+	otherDigest := sha256.Sum256([]byte("other"))
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		key       crypto.PublicKey
+		signature []byte
+		digest    []byte
+		wantErr   bool
+	}{
+		{name: "ecdsa raw", key: &ecdsaKey.PublicKey, signature: ecdsaSignature, digest: digest[:]},
+		{name: "ecdsa base64", key: &ecdsaKey.PublicKey, signature: []byte(base64.StdEncoding.EncodeToString(ecdsaSignature) + "\n"), digest: digest[:]},
+		{name: "ecdsa wrong digest", key: &ecdsaKey.PublicKey, signature: ecdsaSignature, digest: otherDigest[:], wantErr: true},
+		{name: "rsa raw", key: &rsaKey.PublicKey, signature: rsaSignature, digest: digest[:]},
+		{name: "rsa wrong key", key: &rsaKey.PublicKey, signature: ecdsaSignature, digest: digest[:], wantErr: true},
+	} {
+		err := verifySignature(tc.key, tc.signature, tc.digest)
+		if tc.wantErr != (err != nil) {
+			t.Fatalf("verifySignature(%v) expected error: %v, got: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+// test Verify function on a local image with a checksum manifest and signature
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	diskFile := filepath.Join(dir, "disk.raw")
+	if err := ioutil.WriteFile(diskFile, []byte("disk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("disk"))
+	manifestFile := filepath.Join(dir, "SHA256SUMS")
+	if err := ioutil.WriteFile(manifestFile, []byte(hex.EncodeToString(digest[:])+"  disk.raw\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "cosign.pub")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(diskFile+signatureSuffix, []byte(base64.StdEncoding.EncodeToString(signature)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	image1 := &input.ImageInfo{TempDir: dir, ImageSource: diskFile, ImageFile: diskFile}
+	image2 := &input.ImageInfo{}
+	flagInfo := &input.FlagInfo{Verify: true, ChecksumsPtr: manifestFile, PublicKeyPtr: keyFile}
+	verifications, err := Verify(context.Background(), image1, image2, flagInfo)
+	if err != nil {
+		t.Fatalf("Verify expected no error, got: %v", err)
+	}
+	want := Verification{Image: diskFile, SHA256: hex.EncodeToString(digest[:]), ChecksumStatus: StatusVerified, SignatureStatus: StatusVerified}
+	if len(verifications) != 1 || *verifications[0] != want {
+		t.Fatalf("Verify expected: [%v], got: %v", want, verifications)
+	}
+
+	if err := ioutil.WriteFile(diskFile, []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(context.Background(), image1, image2, flagInfo); err == nil {
+		t.Fatalf("Verify expected error for a tampered image but none returned")
+	}
+}
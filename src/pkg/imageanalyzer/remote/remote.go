@@ -0,0 +1,298 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	// Serial port the analyzer output is written to on the VM (/dev/ttyS1)
+	serialPort = 2
+
+	// Line written to the serial port after the analyzer exits
+	exitCodeMarker = "COS_IMAGE_ANALYZER_EXIT_CODE="
+
+	// Interval between two reads of the serial port
+	pollInterval = 5 * time.Second
+
+	// Maximum duration of the cleanup of the VM, which runs after ctx is cancelled
+	cleanupTimeout = 10 * time.Minute
+
+	// Boot image and disk size of the transient VM. The disk holds both downloaded images.
+	sourceImage    = "projects/debian-cloud/global/images/family/debian-11"
+	bootDiskSizeGb = 100
+
+	// Label of the transient VMs, so leftover VMs can be found and deleted
+	instanceLabel = "cos-image-analyzer"
+
+	startupScriptKey = "startup-script"
+)
+
+// Config holds the settings of a remote analysis
+type Config struct {
+	ProjectID   string
+	Zone        string
+	MachineType string
+	// Name of an existing, stopped VM to run on. Empty to provision a transient VM.
+	VM string
+	// "gs://" path of a linux/amd64 cos_image_analyzer build to run on the VM
+	Binary string
+	// Flags and image arguments passed to the analyzer on the VM
+	Args []string
+}
+
+// shellQuote quotes a string for a bash command line
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// startupScript returns the script that downloads and runs the analyzer on the
+// VM, writing its output and then its exit code to the serial port
+func startupScript(binary string, args []string) string {
+	quotedArgs := make([]string, len(args))
+	for i, arg := range args {
+		quotedArgs[i] = shellQuote(arg)
+	}
+	return `#!/bin/bash
+exec >/dev/ttyS1 2>&1
+cd "$(mktemp -d)"
+if ! command -v sgdisk >/dev/null; then
+  apt-get -qq update >/dev/null && apt-get -qq install -y gdisk bsdiff xdelta3 >/dev/null
+fi
+gsutil -q cp ` + shellQuote(binary) + ` ./cos_image_analyzer && chmod +x ./cos_image_analyzer && ./cos_image_analyzer ` + strings.Join(quotedArgs, " ") + `
+echo "` + exitCodeMarker + `$?"
+`
+}
+
+// serialStream copies the analyzer output read from the serial port until
+// the exit code marker is found
+type serialStream struct {
+	w        io.Writer
+	partial  string // Incomplete last line of the output read so far
+	exitCode int
+	done     bool
+}
+
+// write copies the complete lines of contents to the writer
+func (s *serialStream) write(contents string) error {
+	lines := strings.Split(s.partial+contents, "\n")
+	s.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if s.done {
+			return nil
+		}
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, exitCodeMarker) {
+			exitCode, err := strconv.Atoi(strings.TrimPrefix(line, exitCodeMarker))
+			if err != nil {
+				return fmt.Errorf("failed to parse exit code line %q: %v", line, err)
+			}
+			s.exitCode, s.done = exitCode, true
+			continue
+		}
+		if _, err := fmt.Fprintln(s.w, line); err != nil {
+			return fmt.Errorf("failed to write analyzer output: %v", err)
+		}
+	}
+	return nil
+}
+
+// instanceSpec returns the transient VM that runs the startup script
+func instanceSpec(config *Config, name, script string) *compute.Instance {
+	return &compute.Instance{
+		Name:        name,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", config.Zone, config.MachineType),
+		Labels:      map[string]string{instanceLabel: "true"},
+		Disks: []*compute.AttachedDisk{{
+			Boot:       true,
+			AutoDelete: true,
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				SourceImage: sourceImage,
+				DiskSizeGb:  bootDiskSizeGb,
+			},
+		}},
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network:       "global/networks/default",
+			AccessConfigs: []*compute.AccessConfig{{Name: "External NAT", Type: "ONE_TO_ONE_NAT"}},
+		}},
+		ServiceAccounts: []*compute.ServiceAccount{{
+			Email:  "default",
+			Scopes: []string{compute.CloudPlatformScope},
+		}},
+		Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: startupScriptKey, Value: &script}}},
+	}
+}
+
+// withStartupScript returns a copy of the metadata items with the startup
+// script replaced, or added if there is none
+func withStartupScript(items []*compute.MetadataItems, script string) []*compute.MetadataItems {
+	newItems := []*compute.MetadataItems{{Key: startupScriptKey, Value: &script}}
+	for _, item := range items {
+		if item.Key != startupScriptKey {
+			newItems = append(newItems, item)
+		}
+	}
+	return newItems
+}
+
+// waitForOperation waits until a zonal operation is done
+func waitForOperation(ctx context.Context, svc *compute.Service, config *Config, op *compute.Operation) error {
+	for op.Status != "DONE" {
+		var err error
+		op, err = svc.ZoneOperations.Wait(config.ProjectID, config.Zone, op.Name).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to wait for operation %v: %v", op.Name, err)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("operation %v failed: %v", op.Name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+// createInstance provisions the transient VM
+func createInstance(ctx context.Context, svc *compute.Service, config *Config, name, script string) error {
+	op, err := svc.Instances.Insert(config.ProjectID, config.Zone, instanceSpec(config, name, script)).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create VM %v: %v", name, err)
+	}
+	return waitForOperation(ctx, svc, config, op)
+}
+
+// deleteInstance deletes the transient VM
+func deleteInstance(ctx context.Context, svc *compute.Service, config *Config, name string) error {
+	op, err := svc.Instances.Delete(config.ProjectID, config.Zone, name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to delete VM %v: %v", name, err)
+	}
+	return waitForOperation(ctx, svc, config, op)
+}
+
+// setMetadata sets the metadata items of a VM
+func setMetadata(ctx context.Context, svc *compute.Service, config *Config, items []*compute.MetadataItems) error {
+	instance, err := svc.Instances.Get(config.ProjectID, config.Zone, config.VM).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to get VM %v: %v", config.VM, err)
+	}
+	metadata := &compute.Metadata{Items: items}
+	if instance.Metadata != nil {
+		metadata.Fingerprint = instance.Metadata.Fingerprint
+	}
+	op, err := svc.Instances.SetMetadata(config.ProjectID, config.Zone, config.VM, metadata).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to set metadata of VM %v: %v", config.VM, err)
+	}
+	return waitForOperation(ctx, svc, config, op)
+}
+
+// startInstance starts the provided VM with the startup script
+// Output:
+//   (func(context.Context) error) restore - Stops the VM and restores its metadata
+func startInstance(ctx context.Context, svc *compute.Service, config *Config, script string) (func(context.Context) error, error) {
+	instance, err := svc.Instances.Get(config.ProjectID, config.Zone, config.VM).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM %v: %v", config.VM, err)
+	}
+	if instance.Status != "TERMINATED" {
+		return nil, fmt.Errorf("VM %v must be stopped, its status is %v", config.VM, instance.Status)
+	}
+	var oldItems []*compute.MetadataItems
+	if instance.Metadata != nil {
+		oldItems = instance.Metadata.Items
+	}
+	restore := func(ctx context.Context) error {
+		op, err := svc.Instances.Stop(config.ProjectID, config.Zone, config.VM).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to stop VM %v: %v", config.VM, err)
+		}
+		if err := waitForOperation(ctx, svc, config, op); err != nil {
+			return err
+		}
+		return setMetadata(ctx, svc, config, oldItems)
+	}
+
+	if err := setMetadata(ctx, svc, config, withStartupScript(oldItems, script)); err != nil {
+		return nil, err
+	}
+	op, err := svc.Instances.Start(config.ProjectID, config.Zone, config.VM).Context(ctx).Do()
+	if err != nil {
+		return restore, fmt.Errorf("failed to start VM %v: %v", config.VM, err)
+	}
+	return restore, waitForOperation(ctx, svc, config, op)
+}
+
+// streamOutput copies the analyzer output from the serial port of the VM to w
+// until the analyzer exits
+// Output:
+//   (int) exitCode - Exit code of the analyzer on the VM
+func streamOutput(ctx context.Context, svc *compute.Service, config *Config, name string, w io.Writer) (int, error) {
+	stream := &serialStream{w: w}
+	var next int64
+	for {
+		output, err := svc.Instances.GetSerialPortOutput(config.ProjectID, config.Zone, name).Port(serialPort).Start(next).Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read serial port of VM %v: %v", name, err)
+		}
+		if output.Start > next {
+			fmt.Fprintf(w, "[%d bytes of analyzer output lost]\n", output.Start-next)
+		}
+		if err := stream.write(output.Contents); err != nil {
+			return 0, err
+		}
+		if stream.done {
+			return stream.exitCode, nil
+		}
+		next = output.Next
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Run runs the analyzer on a transient or provided GCE VM and streams its
+// output to w. A transient VM is deleted and a provided VM is stopped with its
+// metadata restored before Run returns, even after ctx is cancelled.
+// Input:
+//   (context.Context) ctx - Context used to cancel the remote analysis
+//   (*Config) config - The settings of the remote analysis
+//   (io.Writer) w - Writer the analyzer output is copied to
+// Output:
+//   (int) exitCode - Exit code of the analyzer on the VM
+func Run(ctx context.Context, config *Config, w io.Writer) (exitCode int, err error) {
+	svc, err := compute.NewService(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create compute service: %v", err)
+	}
+	script := startupScript(config.Binary, config.Args)
+	name := config.VM
+	var cleanup func(context.Context) error
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", instanceLabel, time.Now().Unix())
+		cleanup = func(ctx context.Context) error { return deleteInstance(ctx, svc, config, name) }
+		err = createInstance(ctx, svc, config, name, script)
+	} else {
+		cleanup, err = startInstance(ctx, svc, config, script)
+	}
+	if cleanup != nil {
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+			defer cancel()
+			if cleanupErr := cleanup(cleanupCtx); cleanupErr != nil {
+				log.Printf("failed to clean up VM %v: %v", name, cleanupErr)
+			}
+		}()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return streamOutput(ctx, svc, config, name, w)
+}
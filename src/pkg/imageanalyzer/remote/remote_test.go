@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// test shellQuote function
+func TestShellQuote(t *testing.T) {
+	for _, arg := range []string{"", "plain", "with space", "it's", `"$HOME"`, "a\nb"} {
+		out, err := exec.Command("bash", "-c", "printf %s "+shellQuote(arg)).Output()
+		if err != nil {
+			t.Fatalf("bash failed for %q: %v", arg, err)
+		}
+		if string(out) != arg {
+			t.Errorf("shellQuote(%q) expanded to %q", arg, out)
+		}
+	}
+}
+
+// test startupScript function
+func TestStartupScript(t *testing.T) {
+	script := startupScript("gs://bucket/cos_image_analyzer", []string{"-gcs", "-binary=Version,OS-config", "gs://b/a image.tar.gz", "gs://b/b.tar.gz"})
+	for _, want := range []string{
+		"gsutil -q cp 'gs://bucket/cos_image_analyzer' ./cos_image_analyzer",
+		"./cos_image_analyzer '-gcs' '-binary=Version,OS-config' 'gs://b/a image.tar.gz' 'gs://b/b.tar.gz'",
+		`echo "` + exitCodeMarker + `$?"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("startupScript does not contain %q:\n%s", want, script)
+		}
+	}
+}
+
+// test serialStream write function
+func TestSerialStreamWrite(t *testing.T) {
+	tests := []struct {
+		name         string
+		chunks       []string
+		wantOutput   string
+		wantDone     bool
+		wantExitCode int
+		wantErr      bool
+	}{
+		{
+			name:       "Incomplete",
+			chunks:     []string{"line 1\r\nli", "ne 2\r\nline"},
+			wantOutput: "line 1\nline 2\n",
+		},
+		{
+			name:         "Done",
+			chunks:       []string{"diff\r\n" + exitCodeMarker, "1\r\nafter\r\n"},
+			wantOutput:   "diff\n",
+			wantDone:     true,
+			wantExitCode: 1,
+		},
+		{
+			name:    "InvalidExitCode",
+			chunks:  []string{exitCodeMarker + "x\n"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		var output bytes.Buffer
+		stream := &serialStream{w: &output}
+		var err error
+		for _, chunk := range test.chunks {
+			if err = stream.write(chunk); err != nil {
+				break
+			}
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: write error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if output.String() != test.wantOutput || stream.done != test.wantDone || stream.exitCode != test.wantExitCode {
+			t.Errorf("%v: got output %q, done %v, exit code %d, want %q, %v, %d", test.name, output.String(), stream.done, stream.exitCode, test.wantOutput, test.wantDone, test.wantExitCode)
+		}
+	}
+}
+
+// test instanceSpec function
+func TestInstanceSpec(t *testing.T) {
+	config := &Config{ProjectID: "p", Zone: "us-central1-a", MachineType: "e2-standard-4"}
+	instance := instanceSpec(config, "vm", "script")
+	if instance.MachineType != "zones/us-central1-a/machineTypes/e2-standard-4" {
+		t.Errorf("got machine type %v", instance.MachineType)
+	}
+	if instance.Labels[instanceLabel] != "true" {
+		t.Errorf("got labels %v, want label %v", instance.Labels, instanceLabel)
+	}
+	if len(instance.Disks) != 1 || !instance.Disks[0].AutoDelete {
+		t.Errorf("boot disk is not auto deleted")
+	}
+	items := instance.Metadata.Items
+	if len(items) != 1 || items[0].Key != startupScriptKey || *items[0].Value != "script" {
+		t.Errorf("startup script is not set in metadata")
+	}
+}
+
+// test withStartupScript function
+func TestWithStartupScript(t *testing.T) {
+	oldScript, value := "old", "value"
+	items := []*compute.MetadataItems{{Key: startupScriptKey, Value: &oldScript}, {Key: "other", Value: &value}}
+	got := withStartupScript(items, "new")
+	if len(got) != 2 || got[0].Key != startupScriptKey || *got[0].Value != "new" || got[1].Key != "other" {
+		t.Errorf("withStartupScript returned unexpected items")
+	}
+	if *items[0].Value != "old" {
+		t.Errorf("withStartupScript modified the original items")
+	}
+}
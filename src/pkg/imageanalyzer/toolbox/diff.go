@@ -0,0 +1,73 @@
+package toolbox
+
+import (
+	"sort"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/utilities"
+)
+
+// Differences stores the toolbox and debug utility differences of two images.
+// Each field holds [image1 value, image2 value] and is only set if the values
+// differ. If only one image is passed in, each field holds [image1 value, ""].
+type Differences struct {
+	ToolboxImage   []string
+	DebugUtilities map[string][]string // {package name: [image1 version, image2 version]}, "" if not shipped
+	SingleImage    bool                `json:"-"` // Set if only one image is passed in
+}
+
+// Diff finds the toolbox and debug utility differences of two images
+// Input:
+//   (*Info) info1 - The toolbox info of image1
+//   (*Info) info2 - The toolbox info of image2, nil if only one image is passed in
+// Output:
+//   (*Differences) toolboxDiff - The toolbox differences, nil if image1 has no toolbox info
+func Diff(info1, info2 *Info) *Differences {
+	if info1 == nil {
+		return nil
+	}
+	singleImage := info2 == nil
+	if singleImage {
+		info2 = &Info{}
+	}
+	toolboxDiff := &Differences{SingleImage: singleImage}
+	if info1.ToolboxImage != info2.ToolboxImage {
+		toolboxDiff.ToolboxImage = []string{info1.ToolboxImage, info2.ToolboxImage}
+	}
+	utilities := make(map[string][]string)
+	for name, version1 := range info1.DebugUtilities {
+		if version2 := info2.DebugUtilities[name]; version1 != version2 {
+			utilities[name] = []string{version1, version2}
+		}
+	}
+	for name, version2 := range info2.DebugUtilities {
+		if _, ok := info1.DebugUtilities[name]; !ok {
+			utilities[name] = []string{"", version2}
+		}
+	}
+	if len(utilities) > 0 {
+		toolboxDiff.DebugUtilities = utilities
+	}
+	return toolboxDiff
+}
+
+// formatPair returns a formated string of a single toolbox difference
+func (d *Differences) formatPair(name string, pair []string) string {
+	return utilities.FormatPair(name, pair, d.SingleImage)
+}
+
+// FormatToolboxDiff returns a formated string of the toolbox difference
+func (d *Differences) FormatToolboxDiff() string {
+	if d == nil {
+		return ""
+	}
+	toolboxDiff := d.formatPair("Toolbox image", d.ToolboxImage)
+	names := make([]string, 0, len(d.DebugUtilities))
+	for name := range d.DebugUtilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		toolboxDiff += d.formatPair(name, d.DebugUtilities[name])
+	}
+	return toolboxDiff
+}
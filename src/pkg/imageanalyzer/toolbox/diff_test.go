@@ -0,0 +1,131 @@
+package toolbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+)
+
+// writeRootfsFiles writes files into a fake Rootfs directory
+func writeRootfsFiles(t *testing.T, files map[string]string) string {
+	rootfs := t.TempDir()
+	for path, content := range files {
+		fullPath := filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory for %v: %v", fullPath, err)
+		}
+		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", fullPath, err)
+		}
+	}
+	return rootfs
+}
+
+const packageList = `{"InstalledPackages": [
+	{"Category": "dev-util", "Name": "strace", "Version": "5.3", "Revision": "1"},
+	{"Category": "net-analyzer", "Name": "tcpdump", "Version": "4.9.3", "Revision": "2"},
+	{"Category": "app-shells", "Name": "bash", "Version": "4.3_p48", "Revision": "4"}
+]}`
+
+// test GetInfo function
+func TestGetInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  *Info
+	}{
+		{
+			name: "ToolboxScript",
+			files: map[string]string{
+				"/usr/bin/toolbox":  "#!/bin/bash\nTOOLBOX_DOCKER_IMAGE=\"${TOOLBOX_DOCKER_IMAGE:-gcr.io/cos-cloud/toolbox}\"\nTOOLBOX_DOCKER_TAG=\"${TOOLBOX_DOCKER_TAG:-v20200603}\" # default tag\n",
+				"/etc/package_list": packageList,
+			},
+			want: &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200603", DebugUtilities: map[string]string{"strace": "5.3-r1", "tcpdump": "4.9.3-r2"}},
+		},
+		{
+			name: "DefaultOverride",
+			files: map[string]string{
+				"/usr/bin/toolbox":     "TOOLBOX_DOCKER_IMAGE=gcr.io/cos-cloud/toolbox\nTOOLBOX_DOCKER_TAG=v20200603\n",
+				"/etc/default/toolbox": "export TOOLBOX_DOCKER_TAG='v20200714'\n",
+				"/etc/package_list":    packageList,
+			},
+			want: &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200714", DebugUtilities: map[string]string{"strace": "5.3-r1", "tcpdump": "4.9.3-r2"}},
+		},
+		{
+			name:  "NoToolbox",
+			files: map[string]string{"/etc/package_list": `{"InstalledPackages": []}`},
+			want:  &Info{DebugUtilities: map[string]string{}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			image := &input.ImageInfo{RootfsPartition3: writeRootfsFiles(t, tc.files)}
+			got, err := GetInfo(image)
+			if err != nil {
+				t.Fatalf("GetInfo failed: %v", err)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Fatalf("GetInfo expected:\n%v\ngot:\n%v", tc.want, got)
+			}
+		})
+	}
+
+	if got, err := GetInfo(&input.ImageInfo{}); got != nil || err != nil {
+		t.Fatalf("GetInfo of an unmounted image expected nil, got: %v, %v", got, err)
+	}
+}
+
+// test Diff and FormatToolboxDiff functions
+func TestDiff(t *testing.T) {
+	info1 := &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200603", DebugUtilities: map[string]string{"strace": "5.3-r1", "tcpdump": "4.9.3-r2", "lsof": "4.93-r1"}}
+	info2 := &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200714", DebugUtilities: map[string]string{"strace": "5.3-r1", "tcpdump": "4.9.3-r3", "ethtool": "5.4-r1"}}
+
+	for _, tc := range []struct {
+		info1      *Info
+		info2      *Info
+		want       *Differences
+		wantFormat string
+	}{
+		{info1: nil, info2: info2, want: nil, wantFormat: ""},
+		{info1: info1, info2: info1, want: &Differences{}, wantFormat: ""},
+		{
+			info1: info1,
+			info2: info2,
+			want: &Differences{
+				ToolboxImage:   []string{"gcr.io/cos-cloud/toolbox:v20200603", "gcr.io/cos-cloud/toolbox:v20200714"},
+				DebugUtilities: map[string][]string{"tcpdump": {"4.9.3-r2", "4.9.3-r3"}, "lsof": {"4.93-r1", ""}, "ethtool": {"", "5.4-r1"}},
+			},
+			wantFormat: "Toolbox image:\n< gcr.io/cos-cloud/toolbox:v20200603\n> gcr.io/cos-cloud/toolbox:v20200714\nethtool:\n< \n> 5.4-r1\nlsof:\n< 4.93-r1\n> \ntcpdump:\n< 4.9.3-r2\n> 4.9.3-r3\n",
+		},
+		{
+			info1: &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200603", DebugUtilities: map[string]string{"strace": "5.3-r1"}},
+			info2: &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200603", DebugUtilities: map[string]string{}},
+			want: &Differences{
+				DebugUtilities: map[string][]string{"strace": {"5.3-r1", ""}},
+			},
+			wantFormat: "strace:\n< 5.3-r1\n> \n",
+		},
+		{
+			info1: &Info{ToolboxImage: "gcr.io/cos-cloud/toolbox:v20200603", DebugUtilities: map[string]string{"strace": "5.3-r1"}},
+			info2: nil,
+			want: &Differences{
+				ToolboxImage:   []string{"gcr.io/cos-cloud/toolbox:v20200603", ""},
+				DebugUtilities: map[string][]string{"strace": {"5.3-r1", ""}},
+				SingleImage:    true,
+			},
+			wantFormat: "Toolbox image: gcr.io/cos-cloud/toolbox:v20200603\nstrace: 5.3-r1\n",
+		},
+	} {
+		got := Diff(tc.info1, tc.info2)
+		if !cmp.Equal(got, tc.want) {
+			t.Fatalf("Diff expected:\n%v\ngot:\n%v", tc.want, got)
+		}
+		if gotFormat := got.FormatToolboxDiff(); gotFormat != tc.wantFormat {
+			t.Fatalf("FormatToolboxDiff expected:\n%q\ngot:\n%q", tc.wantFormat, gotFormat)
+		}
+	}
+}
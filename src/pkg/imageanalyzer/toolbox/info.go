@@ -0,0 +1,114 @@
+package toolbox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/input"
+	"cos.googlesource.com/cos/tools.git/src/pkg/imageanalyzer/packagediff"
+)
+
+// Toolbox script and its system wide overrides. Later files override earlier ones.
+var toolboxConfigFiles = []string{"/usr/bin/toolbox", "/etc/default/toolbox"}
+
+// debugUtilities are the packages shipped on the image for on-node debugging
+var debugUtilities = []string{"bind-tools", "busybox", "cri-tools", "curl", "ethtool", "iproute2", "lsof", "sosreport", "strace", "tcpdump"}
+
+// toolboxAssignment matches "TOOLBOX_DOCKER_IMAGE=value" lines of the toolbox script
+var toolboxAssignment = regexp.MustCompile(`^\s*(?:export\s+)?(TOOLBOX_DOCKER_IMAGE|TOOLBOX_DOCKER_TAG)=(.*)$`)
+
+// shellDefault matches a "${VAR:-default}" shell expansion
+var shellDefault = regexp.MustCompile(`^\$\{\w+:?-(.*)\}$`)
+
+// Info stores the toolbox and debug utilities shipped on an image
+type Info struct {
+	ToolboxImage   string            // Default toolbox container image (Ex: gcr.io/cos-cloud/toolbox:v20200603)
+	DebugUtilities map[string]string // {package name: version-revision}
+}
+
+// shellValue returns the value of a shell assignment with quotes and a
+// "${VAR:-default}" expansion removed
+func shellValue(value string) string {
+	if startOfComment := strings.Index(value, " #"); startOfComment >= 0 {
+		value = value[:startOfComment]
+	}
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	if match := shellDefault.FindStringSubmatch(value); match != nil {
+		value = strings.Trim(match[1], `"'`)
+	}
+	return value
+}
+
+// toolboxImage finds the default toolbox container image of an image
+// Input:
+//   (string) rootfs - Path to the mounted Rootfs partition
+// Output:
+//   (string) toolboxImage - "image:tag" of the toolbox container, empty if not found
+func toolboxImage(rootfs string) (string, error) {
+	values := make(map[string]string)
+	for _, configFile := range toolboxConfigFiles {
+		fullPath := filepath.Join(rootfs, configFile)
+		file, err := os.Open(fullPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to open file %v: %v", fullPath, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if match := toolboxAssignment.FindStringSubmatch(scanner.Text()); match != nil {
+				if value := shellValue(match[2]); value != "" && !strings.Contains(value, "$") {
+					values[match[1]] = value
+				}
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to scan file %v: %v", fullPath, err)
+		}
+	}
+	image, tag := values["TOOLBOX_DOCKER_IMAGE"], values["TOOLBOX_DOCKER_TAG"]
+	if image == "" || tag == "" {
+		return image, nil
+	}
+	return image + ":" + tag, nil
+}
+
+// debugUtilityVersions finds the versions of the debug utilities in a package list
+func debugUtilityVersions(packages []packagediff.Package) map[string]string {
+	versions := make(map[string]string)
+	for _, p := range packages {
+		for _, utility := range debugUtilities {
+			if p.Name == utility {
+				versions[p.Name] = p.Version + "-r" + p.Revision
+			}
+		}
+	}
+	return versions
+}
+
+// GetInfo finds the toolbox image and debug utility versions shipped on an image
+// Input:
+//   (*ImageInfo) image - A struct that stores relevent info for the image
+// Output:
+//   (*Info) info - The toolbox info of the image, nil if the Rootfs is not mounted
+func GetInfo(image *input.ImageInfo) (*Info, error) {
+	if image.RootfsPartition3 == "" {
+		return nil, nil
+	}
+	toolboxImage, err := toolboxImage(image.RootfsPartition3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find toolbox image: %v", err)
+	}
+	packages, err := packagediff.GetInstalledPackages(image.RootfsPartition3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package list: %v", err)
+	}
+	return &Info{ToolboxImage: toolboxImage, DebugUtilities: debugUtilityVersions(packages)}, nil
+}
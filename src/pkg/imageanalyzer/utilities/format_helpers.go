@@ -0,0 +1,19 @@
+package utilities
+
+// FormatPair returns a formated string of a single difference of two images
+// Input:
+//   (string) name - Name of the difference
+//   ([]string) pair - [image1 value, image2 value], "" if the value is unset
+//   (bool) singleImage - Flag to indicate only one image is passed in, in
+//                        which case only the image1 value is printed
+// Output:
+//   (string) output - The formated difference, empty if pair is not set
+func FormatPair(name string, pair []string, singleImage bool) string {
+	if len(pair) != 2 {
+		return ""
+	}
+	if singleImage {
+		return name + ": " + pair[0] + "\n"
+	}
+	return name + ":\n< " + pair[0] + "\n> " + pair[1] + "\n"
+}
@@ -0,0 +1,24 @@
+package utilities
+
+import (
+	"testing"
+)
+
+// test FormatPair function
+func TestFormatPair(t *testing.T) {
+	for _, tc := range []struct {
+		pair        []string
+		singleImage bool
+		want        string
+	}{
+		{pair: []string{"77", "81"}, singleImage: false, want: "milestone:\n< 77\n> 81\n"},
+		{pair: []string{"true", ""}, singleImage: false, want: "milestone:\n< true\n> \n"},
+		{pair: []string{"", "stable"}, singleImage: false, want: "milestone:\n< \n> stable\n"},
+		{pair: []string{"81", ""}, singleImage: true, want: "milestone: 81\n"},
+		{pair: nil, singleImage: false, want: ""},
+	} {
+		if got := FormatPair("milestone", tc.pair, tc.singleImage); got != tc.want {
+			t.Fatalf("FormatPair(%v, %v) expected:\n%v\ngot:\n%v", tc.pair, tc.singleImage, tc.want, got)
+		}
+	}
+}
@@ -20,6 +20,7 @@ const base10 = 10
 // GcsDowndload calls the GCS client api to download a specified object from
 // a GCS bucket.
 // Input:
+//   (context.Context) ctx - Context used to cancel the download
 //   (string) bucket - Name of the GCS bucket
 //   (string) object - Name of the GCS object
 //   (string) destDir - Destination for downloaded GCS object
@@ -29,12 +30,11 @@ const base10 = 10
 //                         Otherwise, ADC will be used for authorization.
 // Output:
 //   (string) downloadedFile - Path to downloaded GCS object
-func GcsDowndload(bucket, object, destDir, name string, authenticate bool) (string, error) {
+func GcsDowndload(ctx context.Context, bucket, object, destDir, name string, authenticate bool) (string, error) {
 	// Call API to download GCS object into tempDir
 	var client *storage.Client
 	var err error
 
-	ctx := context.Background()
 	if authenticate {
 		client, err = storage.NewClient(ctx)
 	} else {
@@ -2,6 +2,7 @@ package utilities
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,10 +10,14 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const sectorSize = 512
 
+// losetupMutex serializes loop device allocation across concurrent MountDisk calls
+var losetupMutex sync.Mutex
+
 // InArray determines if a string appears in a string array
 func InArray(val string, arr []string) bool {
 	for _, elem := range arr {
@@ -82,8 +87,8 @@ func SliceToMapStr(input []string) map[string]string {
 
 // getPartitionStart finds the start partition offset of the disk
 // Input:
-//   (string) diskFile - Name of DOS/MBR file (ex: disk.raw)
 //   (string) partition - The partition number you are pulling the offset from
+//   (string) diskRaw - Name of DOS/MBR file (ex: disk.raw)
 // Output:
 //   (int) start - The start of the partition on the disk
 func getPartitionStart(partition, diskRaw string) (int, error) {
@@ -119,26 +124,38 @@ func getPartitionStart(partition, diskRaw string) (int, error) {
 
 // MountDisk finds a free loop device and mounts a DOS/MBR disk file
 // Input:
+//   (context.Context) ctx - Context used to cancel the mount
 //   (string) diskFile - Name of DOS/MBR file (ex: disk.raw)
 //   (string) mountDir - Mount Destination
 //   (string) partition - The partition number you are pulling the offset from
 // Output:
 //   (string) loopDevice - Name of the loop device used to mount
-func MountDisk(diskFile, mountDir, partition string) (string, error) {
+func MountDisk(ctx context.Context, diskFile, mountDir, partition string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	startOfPartition, err := getPartitionStart(partition, diskFile)
 	if err != nil {
 		return "", fmt.Errorf("failed to get start of partition #%v: %v", partition, err)
 	}
 	offset := strconv.Itoa(sectorSize * startOfPartition)
 
+	// Finding a free loop device and attaching to it is not atomic, so two
+	// images mounted at the same time could race for the same device. losetup
+	// is not cancelled midway so the attached loop device is always known.
+	losetupMutex.Lock()
 	out, err := exec.Command("sudo", "losetup", "--show", "-fP", diskFile).Output()
+	losetupMutex.Unlock()
 	if err != nil {
 		return "", fmt.Errorf("failed to create new loop device for %v: %v", diskFile, err)
 	}
 
 	loopDevice := string(out[:len(out)-1])
-	_, err = exec.Command("sudo", "mount", "-o", "ro,loop,offset="+offset, loopDevice, mountDir).Output()
+	_, err = exec.CommandContext(ctx, "sudo", "mount", "-o", "ro,loop,offset="+offset, loopDevice, mountDir).Output()
 	if err != nil {
+		if _, detachErr := exec.Command("sudo", "losetup", "-d", loopDevice).Output(); detachErr != nil {
+			return "", fmt.Errorf("failed to mount loop device %v at %v: %v, and failed to delete it: %v", loopDevice, mountDir, err, detachErr)
+		}
 		return "", fmt.Errorf("failed to mount loop device %v at %v: %v", loopDevice, mountDir, err)
 	}
 	return loopDevice, nil
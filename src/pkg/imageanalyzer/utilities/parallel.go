@@ -0,0 +1,36 @@
+package utilities
+
+import "sync"
+
+// ParallelFor calls fn for every index in [0, n) on a pool of at most threads
+// goroutines. Every call runs to completion, even if other calls fail, so the
+// caller sees the final state of each item.
+// Input:
+//   (int) threads - Maximum number of concurrent calls, values below 1 run serially
+//   (int) n - Number of items
+//   (func(int) error) fn - Called once with the index of each item
+// Output:
+//   ([]error) errs - The error returned by fn for each index, nil on success
+func ParallelFor(threads, n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	if threads < 1 {
+		threads = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < threads && worker < n; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,60 @@
+package utilities
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// test ParallelFor function
+func TestParallelFor(t *testing.T) {
+	for _, tc := range []struct {
+		threads int
+		n       int
+	}{
+		{threads: 0, n: 3},
+		{threads: 1, n: 5},
+		{threads: 2, n: 5},
+		{threads: 8, n: 3},
+		{threads: 4, n: 0},
+	} {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		calls := make([]int, tc.n)
+		errs := ParallelFor(tc.threads, tc.n, func(i int) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			calls[i]++
+			mu.Unlock()
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			if i%2 == 1 {
+				return errors.New("odd")
+			}
+			return nil
+		})
+		wantMax := tc.threads
+		if wantMax < 1 {
+			wantMax = 1
+		}
+		if maxRunning > wantMax {
+			t.Fatalf("ParallelFor with %v threads ran %v calls concurrently", tc.threads, maxRunning)
+		}
+		if len(errs) != tc.n {
+			t.Fatalf("ParallelFor expected %v errors, got: %v", tc.n, len(errs))
+		}
+		for i := range calls {
+			if calls[i] != 1 {
+				t.Fatalf("ParallelFor expected index %v to be called once, got: %v", i, calls[i])
+			}
+			if (errs[i] != nil) != (i%2 == 1) {
+				t.Fatalf("ParallelFor returned unexpected error for index %v: %v", i, errs[i])
+			}
+		}
+	}
+}
@@ -45,6 +45,21 @@ func LoadModule(moduleName, modulePath string) error {
 	return nil
 }
 
+// UnloadModule unloads a given kernel module from kernel, if it is loaded.
+func UnloadModule(moduleName string) error {
+	loaded, err := isModuleLoaded(moduleName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unload module %s", moduleName)
+	}
+	if !loaded {
+		return nil
+	}
+	if err := execCommand("rmmod", moduleName).Run(); err != nil {
+		return errors.Wrapf(err, "failed to run command `rmmod %s`", moduleName)
+	}
+	return nil
+}
+
 // UpdateHostLdCache updates the ld cache on host.
 func UpdateHostLdCache(hostRootDir, moduleLibDir string) error {
 	log.Info("Updating host's ld cache")
@@ -66,6 +81,32 @@ func UpdateHostLdCache(hostRootDir, moduleLibDir string) error {
 	return nil
 }
 
+// RevertHostLdCache removes a directory added by UpdateHostLdCache from the ld
+// cache on host.
+func RevertHostLdCache(hostRootDir, moduleLibDir string) error {
+	log.Info("Reverting host's ld cache")
+	ldPath := filepath.Join(hostRootDir, "/etc/ld.so.conf")
+	content, err := ioutil.ReadFile(ldPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", ldPath)
+	}
+	var lines []string
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if strings.TrimSpace(line) != moduleLibDir {
+			lines = append(lines, line)
+		}
+	}
+	if err := ioutil.WriteFile(ldPath, []byte(strings.Join(lines, "")), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", ldPath)
+	}
+
+	if err := execCommand("ldconfig", "-r", hostRootDir).Run(); err != nil {
+		return errors.Wrapf(err, "failed to run `ldconfig -r %s`", hostRootDir)
+	}
+
+	return nil
+}
+
 // LoadPublicKey loads the given public key to system keyring.
 func LoadPublicKey(keyName, keyPath, keyring string) error {
 	log.Infof("Loading %s to keyring %s", keyName, keyring)
@@ -150,6 +191,53 @@ func AppendSignature(outfilePath, modulefilePath, sigfilePath string) error {
 	return nil
 }
 
+// LoadedModules returns the names of the kernel modules loaded to kernel.
+func LoadedModules() ([]string, error) {
+	out, err := execCommand("lsmod").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run command `lsmod`")
+	}
+
+	var moduleNames []string
+	// The first line is the header of the table.
+	for i, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if i > 0 && len(fields) > 0 {
+			moduleNames = append(moduleNames, fields[0])
+		}
+	}
+	return moduleNames, nil
+}
+
+// ModuleVermagic returns the version magic of a kernel module, which starts
+// with the release of the kernel it was built for, ex. "5.15.133+ SMP
+// mod_unload modversions".
+func ModuleVermagic(modulePath string) (string, error) {
+	moduleBytes, err := ioutil.ReadFile(modulePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", modulePath)
+	}
+	key := []byte("\x00vermagic=")
+	start := bytes.Index(moduleBytes, key)
+	if start < 0 {
+		return "", errors.Errorf("failed to find vermagic in %s", modulePath)
+	}
+	value := moduleBytes[start+len(key):]
+	if end := bytes.IndexByte(value, 0); end >= 0 {
+		value = value[:end]
+	}
+	return string(value), nil
+}
+
+// IsModuleSigned reports whether a signature is appended to a kernel module.
+func IsModuleSigned(modulePath string) (bool, error) {
+	moduleBytes, err := ioutil.ReadFile(modulePath)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read %s", modulePath)
+	}
+	return bytes.HasSuffix(moduleBytes, []byte(magicNumber)), nil
+}
+
 func isModuleLoaded(moduleName string) (bool, error) {
 	out, err := execCommand("lsmod").Output()
 	if err != nil {
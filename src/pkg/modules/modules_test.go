@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -130,3 +131,87 @@ func TestAppendSignature(t *testing.T) {
 			expectedBytes, signedModuleBytes, diff)
 	}
 }
+
+func TestRevertHostLdCache(t *testing.T) {
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	hostRootDir, err := ioutil.TempDir("", "root")
+	if err != nil {
+		t.Fatalf("RevertHostLdCache: failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(hostRootDir)
+	if err := os.MkdirAll(filepath.Join(hostRootDir, "etc"), 0755); err != nil {
+		t.Fatalf("RevertHostLdCache: failed to create etc dir: %v", err)
+	}
+	ldPath := filepath.Join(hostRootDir, "etc", "ld.so.conf")
+	content := "include /etc/ld.so.conf.d/*.conf\n/var/lib/nvidia/lib64\n/opt/lib\n/var/lib/nvidia/lib64\n"
+	if err := ioutil.WriteFile(ldPath, []byte(content), 0644); err != nil {
+		t.Fatalf("RevertHostLdCache: failed to write %s: %v", ldPath, err)
+	}
+
+	if err := RevertHostLdCache(hostRootDir, "/var/lib/nvidia/lib64"); err != nil {
+		t.Fatalf("RevertHostLdCache: failed to run with error: %v", err)
+	}
+	got, err := ioutil.ReadFile(ldPath)
+	if err != nil {
+		t.Fatalf("RevertHostLdCache: failed to read %s: %v", ldPath, err)
+	}
+	want := "include /etc/ld.so.conf.d/*.conf\n/opt/lib\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("RevertHostLdCache: ld.so.conf doesn't match,\nwant: %q\ngot: %q\ndiff: %v", want, got, diff)
+	}
+}
+
+func TestLoadedModules(t *testing.T) {
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	mockCmdStdout = "Module\tSize\tUsed by\nnvidia_uvm\t1384448\t0\nnvidia\t56705024\t1 nvidia_uvm\n"
+	defer func() { mockCmdStdout = "" }()
+	out, err := LoadedModules()
+	if err != nil {
+		t.Fatalf("LoadedModules: failed to run with error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"nvidia_uvm", "nvidia"}, out); diff != "" {
+		t.Errorf("LoadedModules: unexpected modules, diff: %v", diff)
+	}
+}
+
+func TestModuleInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modules")
+	if err != nil {
+		t.Fatalf("ModuleInfo: failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	modulePath := filepath.Join(dir, "nvidia.ko")
+	signedModulePath := filepath.Join(dir, "nvidia-signed.ko")
+	sigPath := filepath.Join(dir, "nvidia.ko.sig")
+	module := "\x7fELF\x00license=NVIDIA\x00vermagic=5.15.133+ SMP mod_unload modversions \x00name=nvidia\x00"
+	if err := ioutil.WriteFile(modulePath, []byte(module), 0644); err != nil {
+		t.Fatalf("ModuleInfo: failed to write %s: %v", modulePath, err)
+	}
+	if err := ioutil.WriteFile(sigPath, []byte("signature"), 0644); err != nil {
+		t.Fatalf("ModuleInfo: failed to write %s: %v", sigPath, err)
+	}
+	if err := AppendSignature(signedModulePath, modulePath, sigPath); err != nil {
+		t.Fatalf("ModuleInfo: failed to sign module: %v", err)
+	}
+
+	vermagic, err := ModuleVermagic(signedModulePath)
+	if err != nil {
+		t.Fatalf("ModuleVermagic: failed to run with error: %v", err)
+	}
+	if want := "5.15.133+ SMP mod_unload modversions "; vermagic != want {
+		t.Errorf("ModuleVermagic: want: %q, got: %q", want, vermagic)
+	}
+	for path, want := range map[string]bool{modulePath: false, signedModulePath: true} {
+		signed, err := IsModuleSigned(path)
+		if err != nil {
+			t.Fatalf("IsModuleSigned: failed to run with error: %v", err)
+		}
+		if signed != want {
+			t.Errorf("IsModuleSigned(%s): want: %v, got: %v", path, want, signed)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"go.chromium.org/luci/common/api/gerrit"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// GerritScope is the OAuth scope needed to query Gerrit and Gitiles.
+const GerritScope = gerrit.OAuthScope
+
+// The changelog and findbuild packages accept any *http.Client that attaches
+// credentials to its requests, ex. one created by oauth2.NewClient. The
+// constructors below create such clients without an interactive login, so
+// they can be used by server deployments.
+
+// DefaultCredentialsHTTPClient returns an HTTP client authenticating with
+// Application Default Credentials, ex. the service account of a GCE instance
+// or App Engine app, or the credentials created by
+// `gcloud auth application-default login`.
+func DefaultCredentialsHTTPClient(ctx context.Context) (*http.Client, error) {
+	creds, err := google.FindDefaultCredentials(ctx, GerritScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find application default credentials: %v", err)
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+// ServiceAccountHTTPClient returns an HTTP client authenticating as the
+// service account of a JSON key.
+func ServiceAccountHTTPClient(ctx context.Context, jsonKey []byte) (*http.Client, error) {
+	if len(jsonKey) == 0 {
+		return nil, errors.New("failed to parse service account key: key is empty")
+	}
+	creds, err := google.CredentialsFromJSON(ctx, jsonKey, GerritScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account key: %v", err)
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+// ServiceAccountFileHTTPClient returns an HTTP client authenticating as the
+// service account of the JSON key stored at path.
+func ServiceAccountFileHTTPClient(ctx context.Context, path string) (*http.Client, error) {
+	jsonKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key %s: %v", path, err)
+	}
+	return ServiceAccountHTTPClient(ctx, jsonKey)
+}
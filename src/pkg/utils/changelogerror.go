@@ -22,16 +22,42 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"html"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// Sentinel errors identifying the cause of a ChangelogError. They can be
+// matched with errors.Is.
+var (
+	// ErrBuildNotFound indicates that the manifest file of a build does not exist
+	ErrBuildNotFound = errors.New("build not found")
+
+	// ErrManifestMalformed indicates that the manifest file of a build could
+	// not be parsed
+	ErrManifestMalformed = errors.New("manifest malformed")
+
+	// ErrRepoLogUnavailable indicates that the commit log of a repository
+	// could not be retrieved or parsed
+	ErrRepoLogUnavailable = errors.New("repository log unavailable")
+
+	// ErrCLNotUnique indicates that a CL identifier, ex. a Change-Id, matches
+	// several CLs
+	ErrCLNotUnique = errors.New("CL not unique")
+
+	// ErrNoBuildFound indicates that no build of the manifest repository
+	// searched by findbuild contains a CL
+	ErrNoBuildFound = errors.New("no build found")
+)
+
 var (
 	grpcCodeToHTTP = map[string]string{
 		codes.Unknown.String():            "500",
+		codes.DeadlineExceeded.String():   "504",
 		codes.InvalidArgument.String():    "400",
 		codes.NotFound.String():           "404",
 		codes.PermissionDenied.String():   "403",
@@ -59,6 +85,23 @@ var (
 		err:      "An unexpected error occurred while retrieving the requested information.",
 	}
 
+	// TimeoutError is a ChangelogError object indicating the request was
+	// cancelled or did not complete before its deadline
+	TimeoutError = &UtilChangelogError{
+		httpCode: "504",
+		header:   "Gateway Timeout",
+		err:      "The request took too long to complete. Please try again later, or narrow the requested range.",
+	}
+
+	// InvalidPageToken is a ChangelogError object indicating a continuation
+	// token was not issued by a previous changelog request
+	InvalidPageToken = &UtilChangelogError{
+		httpCode: "400",
+		header:   "Invalid Page Token",
+		err:      "The page token is malformed. Please request the changelog again to receive a new token.",
+	}
+
+	gitiles401ErrMsg = "unexpected HTTP 401 from Gitiles"
 	gitiles403ErrMsg = "unexpected HTTP 403 from Gitiles"
 	gerritErrCodeRe  = regexp.MustCompile("status code\\s*(\\d+)")
 )
@@ -79,6 +122,7 @@ type UtilChangelogError struct {
 	err       string
 	htmlErr   string
 	retryable bool
+	kind      error
 }
 
 // HTTPCode retrieves the HTTP error code associated with the error
@@ -112,6 +156,11 @@ func (e *UtilChangelogError) Retryable() bool {
 	return e.retryable
 }
 
+// Unwrap returns the sentinel error identifying the cause of the error, if any
+func (e *UtilChangelogError) Unwrap() error {
+	return e.kind
+}
+
 func unwrapError(err error) error {
 	innerErr := err
 	for errors.Unwrap(innerErr) != nil {
@@ -129,6 +178,7 @@ func BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuild
 	return &UtilChangelogError{
 		httpCode: "404",
 		header:   "Build Not Found",
+		kind:     ErrBuildNotFound,
 		err: strings.Join([]string{
 			"The builds associated with input",
 			source,
@@ -160,6 +210,7 @@ func BuildNotFound(buildNumber string) *UtilChangelogError {
 	return &UtilChangelogError{
 		httpCode: "404",
 		header:   "Build Not Found",
+		kind:     ErrBuildNotFound,
 		err: strings.Join([]string{
 			"The build associated with input",
 			buildNumber,
@@ -180,10 +231,63 @@ func BuildNotFound(buildNumber string) *UtilChangelogError {
 	}
 }
 
+// ManifestMalformed returns a ChangelogError object for changelog indicating
+// that the manifest file of a build could not be parsed
+func ManifestMalformed(buildNumber string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "500",
+		header:   "Malformed Manifest",
+		err:      fmt.Sprintf("The manifest file of build %s could not be read. Please try again later, or select a different build.", buildNumber),
+		kind:     ErrManifestMalformed,
+	}
+}
+
+// RepoLogUnavailable returns a ChangelogError object for changelog indicating
+// that the commit log of a repository could not be retrieved
+func RepoLogUnavailable(repo string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "500",
+		header:   "Repository Log Unavailable",
+		err:      fmt.Sprintf("The commit history of repository %s could not be retrieved. Please try again later.", repo),
+		kind:     ErrRepoLogUnavailable,
+	}
+}
+
+// InvalidRepoFilter returns a ChangelogError object for changelog indicating
+// that a repository filter is not a valid glob pattern
+func InvalidRepoFilter(pattern string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "400",
+		header:   "Invalid Repository Filter",
+		err:      fmt.Sprintf("The repository filter %q is not a valid glob pattern. Please enter a pattern such as third_party/kernel or src/overlays/*.", pattern),
+	}
+}
+
+// InvalidRepoMapping returns a ChangelogError object for changelog indicating
+// that a repository mapping cannot be applied
+func InvalidRepoMapping(from, to string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "400",
+		header:   "Invalid Repository Mapping",
+		err:      fmt.Sprintf("The repository mapping from %q to %q is invalid. Both repositories must be set, and a prefix ending with / must map to another prefix.", from, to),
+	}
+}
+
 func clLink(clID, instanceURL string) string {
 	return fmt.Sprintf("<a href=\"%s/c/%s\" target=\"_blank\">CL %s</a>", instanceURL, clID, clID)
 }
 
+// PreviousBuildNotFound returns a ChangelogError object for findbuild
+// indicating that no build precedes the desired build on its branch
+func PreviousBuildNotFound(buildNumber string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "404",
+		header:   "Previous Build Not Found",
+		kind:     ErrBuildNotFound,
+		err:      fmt.Sprintf("No build preceding build %s was found on its branch, so the CLs it introduced cannot be determined.", buildNumber),
+	}
+}
+
 // CLNotFound returns a ChangelogError object for findbuild indicating the provided
 // CL could not be found
 func CLNotFound(clID string) *UtilChangelogError {
@@ -202,6 +306,7 @@ func CLLandingNotFound(clID, instanceURL string) *UtilChangelogError {
 	return &UtilChangelogError{
 		httpCode:  "406",
 		header:    "No Build Found",
+		kind:      ErrNoBuildFound,
 		err:       fmt.Sprintf(errStrFmt, "CL "+clID),
 		htmlErr:   fmt.Sprintf(errStrFmt, link),
 		retryable: true,
@@ -217,11 +322,24 @@ func CLNotUsed(clID, repo, branch, instanceURL string) *UtilChangelogError {
 	return &UtilChangelogError{
 		httpCode: "406",
 		header:   "CL Not Used",
+		kind:     ErrNoBuildFound,
 		err:      fmt.Sprintf(errStrFmt, "CL "+clID, repo, branch),
 		htmlErr:  fmt.Sprintf(errStrFmt, link, repo, branch),
 	}
 }
 
+// BugNotFound returns a ChangelogError object for findbuild indicating that
+// no submitted CL references the provided bug
+func BugNotFound(bug, instanceURL string) *UtilChangelogError {
+	errStrFmt := "No submitted CL on %s references the bug: %s. Please enter a bug as b/123456 or crbug/123456, referenced in the Bug footer of the CLs fixing it."
+	return &UtilChangelogError{
+		httpCode: "404",
+		header:   "Bug Not Found",
+		err:      fmt.Sprintf(errStrFmt, instanceURL, bug),
+		htmlErr:  fmt.Sprintf(errStrFmt, instanceURL, html.EscapeString(bug)),
+	}
+}
+
 // CLTooRecent returns a ChangelogError object for findbuild indicating the provided
 // CL could not be found
 func CLTooRecent(clID, instanceURL string) *UtilChangelogError {
@@ -230,6 +348,7 @@ func CLTooRecent(clID, instanceURL string) *UtilChangelogError {
 	return &UtilChangelogError{
 		httpCode: "406",
 		header:   "CL Too Recent",
+		kind:     ErrNoBuildFound,
 		err:      fmt.Sprintf(errStrFmt, "CL "+clID),
 		htmlErr:  fmt.Sprintf(errStrFmt, link),
 	}
@@ -248,6 +367,46 @@ func CLNotSubmitted(clID, instanceURL string) *UtilChangelogError {
 	}
 }
 
+// CLCandidate describes one of the CLs matching a non-unique CL identifier
+type CLCandidate struct {
+	Number  int
+	Project string
+	Branch  string
+	Status  string
+	Subject string
+}
+
+// CLNotUniqueError is the ChangelogError returned by findbuild when a CL
+// identifier matches several CLs. Candidates lets callers prompt the user to
+// pick one of them by CL number.
+type CLNotUniqueError struct {
+	*UtilChangelogError
+	Candidates []CLCandidate
+}
+
+// CLNotUnique returns a ChangelogError object for findbuild indicating that
+// the provided CL identifier matches every CL of candidates
+func CLNotUnique(clID string, candidates []CLCandidate, instanceURL string) *CLNotUniqueError {
+	errStrFmt := "The identifier %s matches %d CLs. Please enter the CL-number of one of them: %s."
+	var descriptions, htmlDescriptions []string
+	for _, candidate := range candidates {
+		number := strconv.Itoa(candidate.Number)
+		details := fmt.Sprintf("(%s, %s branch, %s): %s", candidate.Project, candidate.Branch, candidate.Status, candidate.Subject)
+		descriptions = append(descriptions, fmt.Sprintf("CL %s %s", number, details))
+		htmlDescriptions = append(htmlDescriptions, fmt.Sprintf("%s %s", clLink(number, instanceURL), html.EscapeString(details)))
+	}
+	return &CLNotUniqueError{
+		UtilChangelogError: &UtilChangelogError{
+			httpCode: "409",
+			header:   "CL Not Unique",
+			kind:     ErrCLNotUnique,
+			err:      fmt.Sprintf(errStrFmt, clID, len(candidates), strings.Join(descriptions, "; ")),
+			htmlErr:  fmt.Sprintf(errStrFmt, html.EscapeString(clID), len(candidates), strings.Join(htmlDescriptions, "; ")),
+		},
+		Candidates: candidates,
+	}
+}
+
 // CLInvalidRelease returns a ChangelogError object for findbuild indicating
 // that the branch a CL was submitted in was not recognized as a release branch
 func CLInvalidRelease(clID, release, instanceURL string) *UtilChangelogError {
@@ -256,11 +415,62 @@ func CLInvalidRelease(clID, release, instanceURL string) *UtilChangelogError {
 	return &UtilChangelogError{
 		httpCode: "406",
 		header:   "Invalid Release Branch",
+		kind:     ErrNoBuildFound,
 		err:      fmt.Sprintf(errStrFmt, "CL "+clID, release),
 		htmlErr:  fmt.Sprintf(errStrFmt, link, release),
 	}
 }
 
+// NoBuildFoundError is the ChangelogError returned by findbuild when no build
+// contains a CL. It describes where the CL was searched, so users can tell a
+// CL that has not landed yet apart from a CL searched on the wrong branch.
+type NoBuildFoundError struct {
+	*UtilChangelogError
+	// ManifestRepo and Release are the manifest repository and the branch
+	// of it that was searched, ex. "cos/manifest-snapshots" and "release-R93"
+	ManifestRepo string
+	Release      string
+	// Repo and Branch are the repository and branch of the CL looked up in
+	// the manifest files. Branch is empty if any branch was accepted.
+	Repo   string
+	Branch string
+	// NearestBuilds lists the latest builds searched, newest first. It is
+	// empty if no build was searched.
+	NearestBuilds []string
+}
+
+// NoBuildFound adds to err, a ChangelogError for findbuild indicating that no
+// build contains a CL such as CLLandingNotFound or CLNotUsed, the manifest
+// repository and release branch searched, the repository and branch of the
+// CL, and the builds nearest to its landing.
+func NoBuildFound(err *UtilChangelogError, manifestRepo, release, repo, branch string, nearestBuilds []string) *NoBuildFoundError {
+	hint := fmt.Sprintf(" The %s branch of %s was searched for the %s repository", release, manifestRepo, repo)
+	if branch != "" {
+		hint += fmt.Sprintf(" on the %s branch", branch)
+	}
+	if len(nearestBuilds) > 0 {
+		hint += fmt.Sprintf("; the latest builds searched were %s", strings.Join(nearestBuilds, ", "))
+	}
+	hint += "."
+	withHint := func(msg string) string {
+		if !strings.HasSuffix(msg, ".") {
+			msg += "."
+		}
+		return msg + hint
+	}
+	output := *err
+	output.err = withHint(err.Error())
+	output.htmlErr = withHint(err.HTMLError())
+	return &NoBuildFoundError{
+		UtilChangelogError: &output,
+		ManifestRepo:       manifestRepo,
+		Release:            release,
+		Repo:               repo,
+		Branch:             branch,
+		NearestBuilds:      nearestBuilds,
+	}
+}
+
 // GitilesErrCode parses a Gitiles error message and returns an HTTP error code
 // associated with the error. Returns 500 if no error code is found.
 func GitilesErrCode(err error) string {
@@ -270,8 +480,11 @@ func GitilesErrCode(err error) string {
 		return "500"
 	}
 	code, text := rpcStatus.Code(), rpcStatus.Message()
-	// RPC status code misclassifies 403 error as 500 error for Gitiles requests
-	if code == codes.Internal && text == gitiles403ErrMsg {
+	// RPC status code misclassifies 401 and 403 errors as 500 errors for
+	// Gitiles requests
+	if code == codes.Internal && text == gitiles401ErrMsg {
+		code = codes.Unauthenticated
+	} else if code == codes.Internal && text == gitiles403ErrMsg {
 		code = codes.PermissionDenied
 	}
 	if httpCode, ok := grpcCodeToHTTP[code.String()]; ok {
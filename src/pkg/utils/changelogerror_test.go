@@ -73,10 +73,18 @@ func TestGitilesErrCode(t *testing.T) {
 			inputErr:     status.New(codes.NotFound, "not found").Err(),
 			expectedCode: "404",
 		},
+		"Deadline Exceeded": {
+			inputErr:     status.New(codes.DeadlineExceeded, "context deadline exceeded").Err(),
+			expectedCode: "504",
+		},
 		"403 Code Edge Case": {
 			inputErr:     status.New(codes.Internal, "unexpected HTTP 403 from Gitiles").Err(),
 			expectedCode: "403",
 		},
+		"401 Code Edge Case": {
+			inputErr:     status.New(codes.Internal, "unexpected HTTP 401 from Gitiles").Err(),
+			expectedCode: "401",
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -165,6 +173,85 @@ func TestBuildNotFound(t *testing.T) {
 	}
 }
 
+func TestSentinelErrors(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		expectedKind error
+		expectedCode string
+	}{
+		"Build Not Found": {
+			err:          BuildNotFound("15000.0.0"),
+			expectedKind: ErrBuildNotFound,
+			expectedCode: "404",
+		},
+		"Both Builds Not Found": {
+			err:          BothBuildsNotFound("crosland", "15000.0.0", "15001.0.0", "15000.0.0", "15001.0.0"),
+			expectedKind: ErrBuildNotFound,
+			expectedCode: "404",
+		},
+		"Manifest Malformed": {
+			err:          ManifestMalformed("15000.0.0"),
+			expectedKind: ErrManifestMalformed,
+			expectedCode: "500",
+		},
+		"Repository Log Unavailable": {
+			err:          RepoLogUnavailable("third_party/kernel"),
+			expectedKind: ErrRepoLogUnavailable,
+			expectedCode: "500",
+		},
+		"Previous Build Not Found": {
+			err:          PreviousBuildNotFound("15000.0.0"),
+			expectedKind: ErrBuildNotFound,
+			expectedCode: "404",
+		},
+		"CL Not Unique": {
+			err:          CLNotUnique("I102", []CLCandidate{{Number: 102}, {Number: 202}}, testInstanceURL),
+			expectedKind: ErrCLNotUnique,
+			expectedCode: "409",
+		},
+		"CL Landing Not Found": {
+			err:          CLLandingNotFound("3206", testInstanceURL),
+			expectedKind: ErrNoBuildFound,
+			expectedCode: "406",
+		},
+		"CL Not Used": {
+			err:          CLNotUsed("3206", "cos/overlays", "master", testInstanceURL),
+			expectedKind: ErrNoBuildFound,
+			expectedCode: "406",
+		},
+		"CL Not Submitted": {
+			err:          CLNotSubmitted("3206", testInstanceURL),
+			expectedCode: "406",
+		},
+		"Wrapped": {
+			err:          fmt.Errorf("changelog: %w", ManifestMalformed("15000.0.0")),
+			expectedKind: ErrManifestMalformed,
+			expectedCode: "500",
+		},
+		"Untyped": {
+			err:          InternalServerError,
+			expectedCode: "500",
+		},
+	}
+	sentinels := []error{ErrBuildNotFound, ErrManifestMalformed, ErrRepoLogUnavailable, ErrCLNotUnique, ErrNoBuildFound}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(test.err, sentinel), sentinel == test.expectedKind; got != want {
+					t.Errorf("errors.Is(%v, %v) = %t, expected %t", test.err, sentinel, got, want)
+				}
+			}
+			var changelogErr ChangelogError
+			if !errors.As(test.err, &changelogErr) {
+				t.Fatalf("expected a ChangelogError, got %T", test.err)
+			}
+			if changelogErr.HTTPCode() != test.expectedCode {
+				t.Errorf("expected HTTP code %s, got %s", test.expectedCode, changelogErr.HTTPCode())
+			}
+		})
+	}
+}
+
 func TestCLNotFound(t *testing.T) {
 	clID := "1540"
 	expectedCode := "404"
@@ -268,6 +355,35 @@ func TestCLNotSubmitted(t *testing.T) {
 	}
 }
 
+func TestCLNotUnique(t *testing.T) {
+	clID := "I102"
+	candidates := []CLCandidate{
+		{Number: 102, Project: "cos/overlays", Branch: "master", Status: "MERGED", Subject: "Fix <b>"},
+		{Number: 202, Project: "cos/overlays", Branch: "release-R2", Status: "NEW", Subject: "Fix"},
+	}
+	expectedCode := "409"
+	expectedErrHeader := "CL Not Unique"
+	expectedErrStr := "The identifier I102 matches 2 CLs. Please enter the CL-number of one of them: " +
+		"CL 102 (cos/overlays, master branch, MERGED): Fix <b>; CL 202 (cos/overlays, release-R2 branch, NEW): Fix."
+	expectedHTMLErrStr := fmt.Sprintf("The identifier I102 matches 2 CLs. Please enter the CL-number of one of them: "+
+		"%s (cos/overlays, master branch, MERGED): Fix &lt;b&gt;; %s (cos/overlays, release-R2 branch, NEW): Fix.",
+		testCLLink("102", testInstanceURL), testCLLink("202", testInstanceURL))
+	err := CLNotUnique(clID, candidates, testInstanceURL)
+	if err.HTTPCode() != expectedCode {
+		t.Errorf("expected HTTP code %s, got %s", expectedCode, err.HTTPCode())
+	} else if err.Header() != expectedErrHeader {
+		t.Errorf("expected error header \"%s\", got %s", expectedErrHeader, err.Header())
+	} else if err.Error() != expectedErrStr {
+		t.Errorf("expected error string %s, got %s", expectedErrStr, err.Error())
+	} else if err.HTMLError() != expectedHTMLErrStr {
+		t.Errorf("expected html error string %s, got %s", expectedHTMLErrStr, err.HTMLError())
+	} else if err.Retryable() {
+		t.Errorf("expected retryable = false, got true")
+	} else if len(err.Candidates) != len(candidates) {
+		t.Errorf("expected %d candidates, got %d", len(candidates), len(err.Candidates))
+	}
+}
+
 func TestCLInvalidRelease(t *testing.T) {
 	clID := "1540"
 	release := "master"
@@ -289,3 +405,61 @@ func TestCLInvalidRelease(t *testing.T) {
 		t.Errorf("expected retryable = false, got true")
 	}
 }
+
+func TestNoBuildFound(t *testing.T) {
+	clID := "1540"
+	link := testCLLink(clID, testInstanceURL)
+	tests := map[string]struct {
+		err                *UtilChangelogError
+		branch             string
+		nearestBuilds      []string
+		expectedErrStr     string
+		expectedHTMLErrStr string
+	}{
+		"Landing Not Found": {
+			err:                CLLandingNotFound(clID, testInstanceURL),
+			branch:             "master",
+			nearestBuilds:      []string{"16108.0.0", "16107.0.0"},
+			expectedErrStr:     "No build was found containing CL 1540. The master branch of cos/manifest-snapshots was searched for the cos/overlays repository on the master branch; the latest builds searched were 16108.0.0, 16107.0.0.",
+			expectedHTMLErrStr: fmt.Sprintf("No build was found containing %s. The master branch of cos/manifest-snapshots was searched for the cos/overlays repository on the master branch; the latest builds searched were 16108.0.0, 16107.0.0.", link),
+		},
+		"Invalid Release": {
+			err:                CLInvalidRelease(clID, "master", testInstanceURL),
+			expectedErrStr:     "CL 1540 maps to release master, which is not a valid release. The master branch of cos/manifest-snapshots was searched for the cos/overlays repository.",
+			expectedHTMLErrStr: fmt.Sprintf("%s maps to release master, which is not a valid release. The master branch of cos/manifest-snapshots was searched for the cos/overlays repository.", link),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := NoBuildFound(test.err, "cos/manifest-snapshots", "master", "cos/overlays", test.branch, test.nearestBuilds)
+			if err.HTTPCode() != test.err.HTTPCode() {
+				t.Errorf("expected HTTP code %s, got %s", test.err.HTTPCode(), err.HTTPCode())
+			} else if !errors.Is(err, ErrNoBuildFound) {
+				t.Errorf("expected ErrNoBuildFound, got %v", err)
+			} else if err.Error() != test.expectedErrStr {
+				t.Errorf("expected error string %s, got %s", test.expectedErrStr, err.Error())
+			} else if err.HTMLError() != test.expectedHTMLErrStr {
+				t.Errorf("expected html error string %s, got %s", test.expectedHTMLErrStr, err.HTMLError())
+			} else if err.Retryable() != test.err.Retryable() {
+				t.Errorf("expected retryable = %t, got %t", test.err.Retryable(), err.Retryable())
+			}
+		})
+	}
+}
+
+func TestBugNotFound(t *testing.T) {
+	expectedCode := "404"
+	expectedErrHeader := "Bug Not Found"
+	expectedErrStr := "No submitted CL on cos-review.googlesource.com references the bug: b/<1>. Please enter a bug as b/123456 or crbug/123456, referenced in the Bug footer of the CLs fixing it."
+	expectedHTMLErrStr := "No submitted CL on cos-review.googlesource.com references the bug: b/&lt;1&gt;. Please enter a bug as b/123456 or crbug/123456, referenced in the Bug footer of the CLs fixing it."
+	err := BugNotFound("b/<1>", testInstanceURL)
+	if err.HTTPCode() != expectedCode {
+		t.Errorf("expected HTTP code %s, got %s", expectedCode, err.HTTPCode())
+	} else if err.Header() != expectedErrHeader {
+		t.Errorf("expected error header \"%s\", got %s", expectedErrHeader, err.Header())
+	} else if err.Error() != expectedErrStr {
+		t.Errorf("expected error string %s, got %s", expectedErrStr, err.Error())
+	} else if err.HTMLError() != expectedHTMLErrStr {
+		t.Errorf("expected html error string %s, got %s", expectedHTMLErrStr, err.HTMLError())
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// GitilesService is the subset of the Gitiles API used by the changelog and
+// findbuild packages. gitilesProto.GitilesClient implements it, so clients
+// created by go.chromium.org/luci/common/api/gitiles can be used directly,
+// while tests and callers can supply fakes or instrumented clients.
+type GitilesService interface {
+	// Log returns the commits reachable from a committish, newest first.
+	Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error)
+	// DownloadFile returns the contents of a file at a committish.
+	DownloadFile(ctx context.Context, in *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error)
+}
+
+var _ GitilesService = (gitilesProto.GitilesClient)(nil)
@@ -27,27 +27,57 @@ import (
 
 	"go.chromium.org/luci/common/proto/git"
 
-	log "github.com/sirupsen/logrus"
 	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 )
 
 const (
-	manifestFileName string = "snapshot.xml"
+	// DefaultManifestFileName is the name of the manifest file in a
+	// manifest-snapshots repository
+	DefaultManifestFileName string = "snapshot.xml"
+	// DefaultManifestTagPrefix is prepended to a build number to form the tag
+	// pointing to the build's manifest file
+	DefaultManifestTagPrefix string = "refs/tags/"
+)
 
-	// These constants are used for exponential increase in Gitiles request size.
-	defaultPageSize          = 100
-	pageSizeGrowthMultiplier = 5
-	maxPageSize              = 10000
+// PageSizePolicy describes how many commits are requested per Gitiles page
+// when retrieving a commit Log. The first page holds InitialPageSize commits,
+// and every following page GrowthMultiplier times more, up to MaxPageSize.
+// Larger pages take fewer requests but each of them is slower to serve.
+// Fields that are not positive use the value of DefaultPageSizePolicy.
+type PageSizePolicy struct {
+	InitialPageSize  int
+	GrowthMultiplier int
+	MaxPageSize      int
+}
 
-	// Maximum time to wait for a response from a Gitiles request
-	requestMaxAge = 2 * time.Minute
-)
+// DefaultPageSizePolicy is the page size policy used by Commits and
+// CommitsPage.
+var DefaultPageSizePolicy = PageSizePolicy{
+	InitialPageSize:  100,
+	GrowthMultiplier: 5,
+	MaxPageSize:      10000,
+}
+
+// withDefaults returns a copy of p where fields that are not positive are
+// replaced by the value of DefaultPageSizePolicy.
+func (p PageSizePolicy) withDefaults() PageSizePolicy {
+	if p.InitialPageSize <= 0 {
+		p.InitialPageSize = DefaultPageSizePolicy.InitialPageSize
+	}
+	if p.GrowthMultiplier <= 0 {
+		p.GrowthMultiplier = DefaultPageSizePolicy.GrowthMultiplier
+	}
+	if p.MaxPageSize <= 0 {
+		p.MaxPageSize = DefaultPageSizePolicy.MaxPageSize
+	}
+	return p
+}
 
 // limitPageSize will restrict a request page size to min of pageSize (which grows exponentially)
 // or remaining request size
-func limitPageSize(pageSize, querySize int, noLimit bool) int {
-	if pageSize > maxPageSize {
-		pageSize = maxPageSize
+func (p PageSizePolicy) limitPageSize(pageSize, querySize int, noLimit bool) int {
+	if pageSize > p.MaxPageSize {
+		pageSize = p.MaxPageSize
 	}
 	if noLimit || pageSize <= querySize {
 		return pageSize
@@ -56,22 +86,35 @@ func limitPageSize(pageSize, querySize int, noLimit bool) int {
 }
 
 // DownloadManifest retrieves a manifest file from Git on Borg for a specific
-// build number
-func DownloadManifest(client gitilesProto.GitilesClient, manifestRepo, buildNum string) (*gitilesProto.DownloadFileResponse, error) {
-	log.Debugf("Downloading manifest file for build %s", buildNum)
+// build number. The request is aborted when ctx is cancelled or its deadline
+// passes, and transient failures are retried according to the policy set on
+// ctx by WithRetryPolicy, or DefaultRetryPolicy. Requests are limited by the
+// Scheduler set on ctx by WithScheduler, if any.
+func DownloadManifest(ctx context.Context, client GitilesService, manifestRepo, buildNum string) (*gitilesProto.DownloadFileResponse, error) {
+	return DownloadManifestFile(ctx, client, manifestRepo, DefaultManifestTagPrefix+buildNum, DefaultManifestFileName)
+}
+
+// DownloadManifestFile retrieves the manifest file at fileName from Git on Borg
+// at a specific committish, for repositories that do not follow the default
+// manifest-snapshots layout.
+func DownloadManifestFile(ctx context.Context, client GitilesService, manifestRepo, committish, fileName string) (*gitilesProto.DownloadFileResponse, error) {
+	Log.Debugf("Downloading manifest file %s at %s", fileName, committish)
 	request := gitilesProto.DownloadFileRequest{
 		Project:    manifestRepo,
-		Committish: "refs/tags/" + buildNum,
-		Path:       manifestFileName,
+		Committish: committish,
+		Path:       fileName,
 		Format:     1,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), requestMaxAge)
-	defer cancel()
-	response, err := client.DownloadFile(ctx, &request)
+	var response *gitilesProto.DownloadFileResponse
+	err := doGitiles(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = client.DownloadFile(ctx, &request)
+		return err
+	})
 	return response, err
 }
 
-func nextCommits(client gitilesProto.GitilesClient, repo string, committish string, ancestor string, nextToken string, pageSize int) (*gitilesProto.LogResponse, error) {
+func nextCommits(ctx context.Context, client GitilesService, repo string, committish string, ancestor string, nextToken string, pageSize int) (*gitilesProto.LogResponse, error) {
 	request := gitilesProto.LogRequest{
 		Project:            repo,
 		Committish:         committish,
@@ -79,52 +122,86 @@ func nextCommits(client gitilesProto.GitilesClient, repo string, committish stri
 		PageToken:          nextToken,
 		PageSize:           int32(pageSize),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), requestMaxAge)
-	defer cancel()
-	return client.Log(ctx, &request)
+	var response *gitilesProto.LogResponse
+	err := doGitiles(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = client.Log(ctx, &request)
+		return err
+	})
+	return response, err
 }
 
 // Commits retrieves querySize commits that occur between a committish and an ancestor
 // for a given repository. Returns a list of commits and a bool that is set to true
 // if there are more than querySize commits between the two provided committishs.
-func Commits(client gitilesProto.GitilesClient, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, bool, error) {
-	log.Debugf("Fetching changelog for repo: %s from: %s to: %s\n", repo, ancestor, committish)
+// Paging stops as soon as ctx is cancelled or its deadline passes. Transient
+// failures are retried according to the policy set on ctx by WithRetryPolicy,
+// or DefaultRetryPolicy. Requests are limited by the Scheduler set on ctx by
+// WithScheduler, if any.
+func Commits(ctx context.Context, client GitilesService, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, bool, error) {
+	commits, nextToken, err := CommitsPage(ctx, client, repo, committish, ancestor, "", querySize)
+	return commits, nextToken != "", err
+}
+
+// IsAncestor reports whether ancestor is reachable from committish in the
+// history of repo, which is the case if both are the same commit. Merges are
+// followed, and at most one commit is retrieved.
+func IsAncestor(ctx context.Context, client GitilesService, repo, ancestor, committish string) (bool, error) {
+	commits, _, err := Commits(ctx, client, repo, ancestor, committish, 1)
+	if err != nil {
+		return false, fmt.Errorf("isAncestor: error checking whether %s is an ancestor of %s in repo %s:\n%w", ancestor, committish, repo, err)
+	}
+	return len(commits) == 0, nil
+}
+
+// CommitsPage behaves like Commits, but starts from the page identified by
+// pageToken instead of the first page. Returns the token of the page following
+// the retrieved commits, or an empty string if there are no more commits.
+func CommitsPage(ctx context.Context, client GitilesService, repo string, committish string, ancestor string, pageToken string, querySize int) ([]*git.Commit, string, error) {
+	return DefaultPageSizePolicy.CommitsPage(ctx, client, repo, committish, ancestor, pageToken, querySize)
+}
+
+// CommitsPage behaves like the CommitsPage function, but requests pages sized
+// according to p.
+func (p PageSizePolicy) CommitsPage(ctx context.Context, client GitilesService, repo string, committish string, ancestor string, pageToken string, querySize int) ([]*git.Commit, string, error) {
+	p = p.withDefaults()
+	Log.Debugf("Fetching changelog for repo: %s from: %s to: %s\n", repo, ancestor, committish)
 	if querySize < -1 {
-		return nil, false, fmt.Errorf("commits: %d is not a valid querySize. Please specify a positive querySize, or -1 for all commits", querySize)
+		return nil, "", fmt.Errorf("commits: %d is not a valid querySize. Please specify a positive querySize, or -1 for all commits", querySize)
 	}
 	start := time.Now()
 
 	noLimit := querySize == -1
-	pageSize := limitPageSize(defaultPageSize, querySize, noLimit)
+	pageSize := p.limitPageSize(p.InitialPageSize, querySize, noLimit)
 	querySize -= pageSize
-	response, err := nextCommits(client, repo, committish, ancestor, "", pageSize)
+	response, err := nextCommits(ctx, client, repo, committish, ancestor, pageToken, pageSize)
 	if err != nil {
-		return nil, false, fmt.Errorf("commits: Error retrieving commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
+		return nil, "", fmt.Errorf("commits: Error retrieving commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
 	}
 
-	// No nextPageToken means there were less than <defaultPageSize> commits total.
+	// No nextPageToken means there were less than <InitialPageSize> commits total.
 	// We can immediately return.
 	if response.NextPageToken == "" {
-		log.Debugf("Retrieved %d commits from %s in %s\n", len(response.Log), repo, time.Since(start))
-		return response.Log, false, nil
+		Log.Debugf("Retrieved %d commits from %s in %s\n", len(response.Log), repo, time.Since(start))
+		return response.Log, "", nil
 	}
 	// Retrieve remaining commits using exponential increase in pageSize.
 	allCommits := response.Log
 	for (noLimit || querySize > 0) && response.NextPageToken != "" {
-		if pageSize < maxPageSize {
-			pageSize *= pageSizeGrowthMultiplier
+		if pageSize < p.MaxPageSize {
+			pageSize *= p.GrowthMultiplier
 		}
-		pageSize = limitPageSize(pageSize, querySize, noLimit)
-		log.Debugf("More commits remaining, expanding page size to %d commits", pageSize)
+		pageSize = p.limitPageSize(pageSize, querySize, noLimit)
+		Log.Debugf("More commits remaining, expanding page size to %d commits", pageSize)
 		querySize -= pageSize
-		response, err = nextCommits(client, repo, committish, ancestor, response.NextPageToken, pageSize)
+		response, err = nextCommits(ctx, client, repo, committish, ancestor, response.NextPageToken, pageSize)
 		if err != nil {
-			return nil, false, fmt.Errorf("commits: Error retrieving next page commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
+			return nil, "", fmt.Errorf("commits: Error retrieving next page commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
 		}
 		allCommits = append(allCommits, response.Log...)
 	}
-	log.Debugf("Retrieved %d commits from %s in %s\n", len(allCommits), repo, time.Since(start))
-	return allCommits, response.NextPageToken != "", nil
+	Log.Debugf("Retrieved %d commits from %s in %s\n", len(allCommits), repo, time.Since(start))
+	return allCommits, response.NextPageToken, nil
 }
 
 // CreateGerritURL creates a Gerrit URL from a given
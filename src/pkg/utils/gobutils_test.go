@@ -23,14 +23,22 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
 	"go.chromium.org/luci/common/api/gerrit"
 	"go.chromium.org/luci/common/api/gitiles"
+	"go.chromium.org/luci/common/proto/git"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 )
 
 const (
@@ -128,7 +136,7 @@ func TestDownloadManifest(t *testing.T) {
 	gobClient, _ := gitiles.NewRESTClient(httpClient, cosGoBURL, false)
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			resp, err := DownloadManifest(gobClient, test.ManifestRepo, test.BuildNum)
+			resp, err := DownloadManifest(context.Background(), gobClient, test.ManifestRepo, test.BuildNum)
 			if (err != nil) != test.ShouldError {
 				ShouldError := "no error"
 				if test.ShouldError {
@@ -188,7 +196,7 @@ func TestCommits(t *testing.T) {
 	gobClient, _ := gitiles.NewRESTClient(httpClient, cosGoBURL, false)
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			commits, moreCommits, err := Commits(gobClient, test.Repo, test.SHA, test.AncestorSHA, test.QuerySize)
+			commits, moreCommits, err := Commits(context.Background(), gobClient, test.Repo, test.SHA, test.AncestorSHA, test.QuerySize)
 			if (err != nil) != test.ShouldError {
 				ShouldError := "no error"
 				if test.ShouldError {
@@ -243,3 +251,131 @@ func TestCreateGerritURL(t *testing.T) {
 		})
 	}
 }
+
+// fakeLogClient serves a log of totalCommits commits and records the page
+// size of every request.
+type fakeLogClient struct {
+	GitilesService
+	totalCommits int
+	pageSizes    []int
+}
+
+func (c *fakeLogClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	c.pageSizes = append(c.pageSizes, int(in.PageSize))
+	offset, _ := strconv.Atoi(in.PageToken)
+	resp := &gitilesProto.LogResponse{}
+	for i := offset; i < offset+int(in.PageSize) && i < c.totalCommits; i++ {
+		resp.Log = append(resp.Log, &git.Commit{Id: strconv.Itoa(i)})
+	}
+	if next := offset + len(resp.Log); next < c.totalCommits {
+		resp.NextPageToken = strconv.Itoa(next)
+	}
+	return resp, nil
+}
+
+func TestPageSizePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy            PageSizePolicy
+		querySize         int
+		expectedPageSizes []int
+	}{
+		"Default Policy": {
+			policy:            PageSizePolicy{},
+			querySize:         -1,
+			expectedPageSizes: []int{100, 500, 2500},
+		},
+		"Custom Policy": {
+			policy:            PageSizePolicy{InitialPageSize: 10, GrowthMultiplier: 2, MaxPageSize: 1000},
+			querySize:         -1,
+			expectedPageSizes: []int{10, 20, 40, 80, 160, 320, 640, 1000, 1000},
+		},
+		"Limited Query": {
+			policy:            PageSizePolicy{InitialPageSize: 10, GrowthMultiplier: 3},
+			querySize:         50,
+			expectedPageSizes: []int{10, 30, 10},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeLogClient{totalCommits: 3000}
+			commits, _, err := test.policy.CommitsPage(context.Background(), client, "repo", "HEAD", "", "", test.querySize)
+			if err != nil {
+				t.Fatalf("CommitsPage failed: %v", err)
+			}
+			if diff := cmp.Diff(test.expectedPageSizes, client.pageSizes); diff != "" {
+				t.Errorf("CommitsPage requested unexpected page sizes (-want +got):\n%s", diff)
+			}
+			expectedCommits := test.querySize
+			if expectedCommits == -1 {
+				expectedCommits = client.totalCommits
+			}
+			if len(commits) != expectedCommits {
+				t.Errorf("expected %d commits, got %d", expectedCommits, len(commits))
+			}
+		})
+	}
+}
+
+// ancestryLogClient serves logs of a history where each commit has the
+// listed ancestors, and records the page size of every request.
+type ancestryLogClient struct {
+	GitilesService
+	ancestors map[string][]string
+	pageSizes []int
+}
+
+func (c *ancestryLogClient) Log(ctx context.Context, in *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	c.pageSizes = append(c.pageSizes, int(in.PageSize))
+	if _, ok := c.ancestors[in.Committish]; !ok {
+		return nil, status.Errorf(codes.NotFound, "committish %s not found", in.Committish)
+	}
+	resp := &gitilesProto.LogResponse{Log: []*git.Commit{}}
+	if in.Committish == in.ExcludeAncestorsOf {
+		return resp, nil
+	}
+	for _, ancestor := range c.ancestors[in.ExcludeAncestorsOf] {
+		if ancestor == in.Committish {
+			return resp, nil
+		}
+	}
+	resp.Log = append(resp.Log, &git.Commit{Id: in.Committish})
+	return resp, nil
+}
+
+func TestIsAncestor(t *testing.T) {
+	// merge has parents main and feature, which both descend from base
+	client := &ancestryLogClient{ancestors: map[string][]string{
+		"base":    nil,
+		"main":    {"base"},
+		"feature": {"base"},
+		"merge":   {"main", "feature", "base"},
+	}}
+	tests := map[string]struct {
+		ancestor    string
+		committish  string
+		expected    bool
+		expectedErr bool
+	}{
+		"Same Commit":    {ancestor: "main", committish: "main", expected: true},
+		"Ancestor":       {ancestor: "base", committish: "main", expected: true},
+		"Merged Branch":  {ancestor: "feature", committish: "merge", expected: true},
+		"Sibling Branch": {ancestor: "feature", committish: "main", expected: false},
+		"Descendant":     {ancestor: "merge", committish: "base", expected: false},
+		"Unknown Commit": {ancestor: "missing", committish: "main", expectedErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client.pageSizes = nil
+			got, err := IsAncestor(context.Background(), client, "repo", test.ancestor, test.committish)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected error %t, got %v", test.expectedErr, err)
+			}
+			if got != test.expected {
+				t.Errorf("expected IsAncestor to return %t, got %t", test.expected, got)
+			}
+			if diff := cmp.Diff([]int{1}, client.pageSizes); diff != "" {
+				t.Errorf("unexpected page sizes requested (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sync/atomic"
+
+	"github.com/golang/glog"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the logging interface used by the changelog and findbuild
+// packages. *logrus.Logger and *logrus.Entry implement it, and GlogLogger
+// adapts glog to it.
+type Logger interface {
+	Debug(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Info(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+var (
+	_ Logger = (*logrus.Logger)(nil)
+	_ Logger = (*logrus.Entry)(nil)
+	_ Logger = GlogLogger{}
+)
+
+// loggerBox wraps the current Logger so it can be stored in an atomic.Value,
+// which requires every stored value to have the same concrete type.
+type loggerBox struct {
+	Logger
+}
+
+var currentLogger atomic.Value
+
+func init() {
+	currentLogger.Store(loggerBox{logrus.StandardLogger()})
+}
+
+// SetLogger replaces the Logger that the changelog, findbuild and utils
+// packages write to. It defaults to the standard logrus logger. SetLogger can
+// be called at any time, and applies to messages logged after it returns.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = logrus.StandardLogger()
+	}
+	currentLogger.Store(loggerBox{l})
+}
+
+func current() Logger {
+	return currentLogger.Load().(loggerBox).Logger
+}
+
+// forwardingLogger sends every message to the Logger set by SetLogger at the
+// time it is logged.
+type forwardingLogger struct{}
+
+func (forwardingLogger) Debug(args ...interface{})                 { current().Debug(args...) }
+func (forwardingLogger) Debugf(format string, args ...interface{}) { current().Debugf(format, args...) }
+func (forwardingLogger) Info(args ...interface{})                  { current().Info(args...) }
+func (forwardingLogger) Infof(format string, args ...interface{})  { current().Infof(format, args...) }
+func (forwardingLogger) Warnf(format string, args ...interface{})  { current().Warnf(format, args...) }
+func (forwardingLogger) Error(args ...interface{})                 { current().Error(args...) }
+func (forwardingLogger) Errorf(format string, args ...interface{}) { current().Errorf(format, args...) }
+func (forwardingLogger) Fatalf(format string, args ...interface{}) { current().Fatalf(format, args...) }
+
+// Log is the Logger of the library packages. It forwards every message to the
+// Logger set by SetLogger, so packages can keep it in a variable.
+var Log Logger = forwardingLogger{}
+
+// GlogLogger is a Logger writing to glog. Debug messages are logged at
+// verbosity DebugLevel, so they are only written when glog runs with -v set
+// to at least DebugLevel.
+type GlogLogger struct {
+	DebugLevel glog.Level
+}
+
+func (l GlogLogger) Debug(args ...interface{}) {
+	glog.V(l.DebugLevel).Info(args...)
+}
+
+func (l GlogLogger) Debugf(format string, args ...interface{}) {
+	glog.V(l.DebugLevel).Infof(format, args...)
+}
+
+func (GlogLogger) Info(args ...interface{}) {
+	glog.Info(args...)
+}
+
+func (GlogLogger) Infof(format string, args ...interface{}) {
+	glog.Infof(format, args...)
+}
+
+func (GlogLogger) Warnf(format string, args ...interface{}) {
+	glog.Warningf(format, args...)
+}
+
+func (GlogLogger) Error(args ...interface{}) {
+	glog.Error(args...)
+}
+
+func (GlogLogger) Errorf(format string, args ...interface{}) {
+	glog.Errorf(format, args...)
+}
+
+func (GlogLogger) Fatalf(format string, args ...interface{}) {
+	glog.Fatalf(format, args...)
+}
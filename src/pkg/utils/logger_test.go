@@ -0,0 +1,51 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.InfoLevel)
+
+	// Log is kept in a variable by library packages, so it must follow
+	// loggers set afterwards.
+	packageLog := Log
+	SetLogger(logger)
+	packageLog.Debugf("hidden %d", 1)
+	packageLog.Errorf("shown %d", 2)
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown 2") {
+		t.Errorf("expected only the error to be logged, got %q", out)
+	}
+
+	buf.Reset()
+	SetLogger(nil)
+	packageLog.Errorf("default")
+	if buf.Len() != 0 {
+		t.Errorf("expected SetLogger(nil) to restore the default logger, got %q", buf.String())
+	}
+	if current() != logrus.StandardLogger() {
+		t.Errorf("expected the standard logrus logger, got %v", current())
+	}
+}
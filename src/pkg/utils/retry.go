@@ -0,0 +1,152 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how failed Gitiles and Gerrit requests are retried.
+type RetryPolicy struct {
+	// Maximum number of attempts made for a single request, including the
+	// first one. Values below 1 are treated as 1.
+	MaxAttempts int
+	// Delay before the first retry.
+	InitialBackoff time.Duration
+	// Upper bound on the delay between two attempts.
+	MaxBackoff time.Duration
+	// Factor the delay grows by after every failed attempt.
+	Multiplier float64
+	// Maximum time to wait for a response to a single attempt.
+	RequestTimeout time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy of the Gitiles requests sent
+// with a context that carries no policy set by WithRetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		RequestTimeout: 2 * time.Minute,
+	}
+}
+
+// HTTP codes of Gitiles and Gerrit errors that are considered transient
+var retryableHTTPCodes = map[string]bool{
+	"429": true,
+	"500": true,
+	"502": true,
+	"503": true,
+	"504": true,
+}
+
+// backoff returns the jittered delay before the given retry. The delay is
+// picked uniformly between half and all of the exponential backoff for the
+// retry, capped at MaxBackoff.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 0; i < retry; i++ {
+		delay *= p.Multiplier
+		if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(delay/2 + rand.Float64()*delay/2)
+}
+
+// GitilesRetryable indicates whether a failed Gitiles request may succeed if
+// it is sent again.
+func GitilesRetryable(err error) bool {
+	return retryableHTTPCodes[GitilesErrCode(err)]
+}
+
+// GerritRetryable indicates whether a failed Gerrit request may succeed if it
+// is sent again. Errors without an HTTP status code, such as connection
+// failures, are considered transient.
+func GerritRetryable(err error) bool {
+	return retryableHTTPCodes[GerritErrCode(err)]
+}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a copy of ctx carrying p, which replaces
+// DefaultRetryPolicy for the Gitiles requests sent with the returned context.
+func WithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// gitilesRetryPolicy returns the retry policy of the Gitiles requests sent
+// with ctx.
+func gitilesRetryPolicy(ctx context.Context) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	return DefaultRetryPolicy()
+}
+
+// do calls fn until it succeeds, returns an error that is not retryable,
+// runs out of attempts or ctx is done. Every call to fn receives a context
+// bounded by RequestTimeout.
+func (p RetryPolicy) do(ctx context.Context, fn func(context.Context) error) error {
+	return p.Retry(ctx, GitilesRetryable, fn)
+}
+
+// Retry calls fn until it succeeds, returns an error that retryable
+// classifies as permanent, runs out of attempts or ctx is done. Every call to
+// fn receives a context bounded by RequestTimeout. The last error is returned.
+func (p RetryPolicy) Retry(ctx context.Context, retryable func(error) bool, fn func(context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay := p.backoff(attempt - 1)
+			Log.Debugf("Retrying request in %s (attempt %d of %d): %v", delay, attempt+1, attempts, err)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+		err = p.attempt(ctx, fn)
+		if err == nil || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (p RetryPolicy) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if p.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.RequestTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	tests := map[string]struct {
+		retry int
+		max   time.Duration
+	}{
+		"First Retry":  {retry: 0, max: 100 * time.Millisecond},
+		"Third Retry":  {retry: 2, max: 400 * time.Millisecond},
+		"Capped Retry": {retry: 10, max: time.Second},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				delay := policy.backoff(test.retry)
+				if delay < test.max/2 || delay > test.max {
+					t.Fatalf("expected backoff between %s and %s, got %s", test.max/2, test.max, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	unavailable := status.New(codes.Unavailable, "unavailable").Err()
+	notFound := status.New(codes.NotFound, "not found").Err()
+	tests := map[string]struct {
+		errs         []error
+		expectedErr  error
+		expectedRuns int
+	}{
+		"Success": {
+			errs:         []error{nil},
+			expectedRuns: 1,
+		},
+		"Transient Failure": {
+			errs:         []error{unavailable, unavailable, nil},
+			expectedRuns: 3,
+		},
+		"Attempts Exhausted": {
+			errs:         []error{unavailable, unavailable, unavailable, nil},
+			expectedErr:  unavailable,
+			expectedRuns: 3,
+		},
+		"Permanent Failure": {
+			errs:         []error{notFound, nil},
+			expectedErr:  notFound,
+			expectedRuns: 1,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			runs := 0
+			err := policy.do(context.Background(), func(context.Context) error {
+				runs++
+				return test.errs[runs-1]
+			})
+			if err != test.expectedErr {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+			if runs != test.expectedRuns {
+				t.Errorf("expected %d attempts, got %d", test.expectedRuns, runs)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCancelled(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, Multiplier: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	unavailable := status.New(codes.Unavailable, "unavailable").Err()
+	runs := 0
+	err := policy.do(ctx, func(context.Context) error {
+		runs++
+		cancel()
+		return unavailable
+	})
+	if err != unavailable {
+		t.Errorf("expected error %v, got %v", unavailable, err)
+	}
+	if runs != 1 {
+		t.Errorf("expected 1 attempt, got %d", runs)
+	}
+}
+
+func TestRetryPolicyRequestTimeout(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 1, RequestTimeout: time.Minute}
+	err := policy.do(context.Background(), func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected attempt context to have a deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestGerritRetryable(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"Unavailable": {
+			err:      errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 503"),
+			expected: true,
+		},
+		"Rate Limited": {
+			err:      errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 429"),
+			expected: true,
+		},
+		"Not Found": {
+			err:      errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 404"),
+			expected: false,
+		},
+		"Connection Failure": {
+			err:      errors.New("connection reset by peer"),
+			expected: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := GerritRetryable(test.err); got != test.expected {
+				t.Errorf("expected GerritRetryable to return %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	transient := errors.New("transient")
+	permanent := errors.New("permanent")
+	retryable := func(err error) bool { return err == transient }
+	tests := map[string]struct {
+		errs         []error
+		expectedErr  error
+		expectedRuns int
+	}{
+		"Transient Failure": {
+			errs:         []error{transient, nil},
+			expectedRuns: 2,
+		},
+		"Permanent Failure": {
+			errs:         []error{transient, permanent, nil},
+			expectedErr:  permanent,
+			expectedRuns: 2,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			runs := 0
+			err := policy.Retry(context.Background(), retryable, func(context.Context) error {
+				runs++
+				return test.errs[runs-1]
+			})
+			if err != test.expectedErr {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+			if runs != test.expectedRuns {
+				t.Errorf("expected %d attempts, got %d", test.expectedRuns, runs)
+			}
+		})
+	}
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 1}
+	if got := gitilesRetryPolicy(context.Background()); got != DefaultRetryPolicy() {
+		t.Errorf("expected default policy %+v, got %+v", DefaultRetryPolicy(), got)
+	}
+	if got := gitilesRetryPolicy(WithRetryPolicy(context.Background(), policy)); got != policy {
+		t.Errorf("expected policy %+v, got %+v", policy, got)
+	}
+}
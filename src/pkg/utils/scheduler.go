@@ -0,0 +1,128 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrNotScheduled is returned when a request cannot be sent before the
+// deadline of its context because of the limits of its Scheduler.
+var ErrNotScheduled = errors.New("request not scheduled before its deadline")
+
+// Scheduler bounds the rate and the concurrency of the Gitiles and Gerrit
+// requests of every caller it is shared by, ex. the findbuild and changelog
+// packages running in the same service, so they draw on a single quota
+// instead of each enforcing their own limits. A nil Scheduler applies no
+// limit.
+//
+// A Scheduler is safe for concurrent use.
+type Scheduler struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+// NewScheduler creates a Scheduler sending at most maxConcurrent requests at
+// the same time, without limit if not positive. If requestsPerSecond is
+// positive, requests are also rate limited with bursts of up to burst
+// requests.
+func NewScheduler(maxConcurrent int, requestsPerSecond float64, burst int) *Scheduler {
+	limit := rate.Inf
+	if requestsPerSecond > 0 {
+		limit = rate.Limit(requestsPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	s := &Scheduler{limiter: rate.NewLimiter(limit, burst)}
+	if maxConcurrent > 0 {
+		s.slots = make(chan struct{}, maxConcurrent)
+	}
+	return s
+}
+
+// Wait blocks until the rate limit allows another request to be sent.
+// Returns an error wrapping ErrNotScheduled if ctx is done first, or would be
+// before the request is allowed.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotScheduled, err)
+	}
+	return nil
+}
+
+// acquire blocks until fewer than the maximum number of concurrent requests
+// are in flight, and returns the function releasing the slot taken.
+func (s *Scheduler) acquire(ctx context.Context) (func(), error) {
+	if s == nil || s.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %v", ErrNotScheduled, ctx.Err())
+	}
+}
+
+// Do sends a request with fn, retrying it according to policy. Every attempt
+// waits for the rate limit and for a free concurrency slot, which is only
+// held while the attempt is in flight, so backoffs do not block the requests
+// of other callers.
+func (s *Scheduler) Do(ctx context.Context, policy RetryPolicy, retryable func(error) bool, fn func(context.Context) error) error {
+	if s == nil {
+		return policy.Retry(ctx, retryable, fn)
+	}
+	return policy.Retry(ctx, retryable, func(ctx context.Context) error {
+		release, err := s.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if err := s.Wait(ctx); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+type schedulerKey struct{}
+
+// WithScheduler returns a copy of ctx carrying s, which schedules the
+// Gitiles requests sent with the returned context by DownloadManifest,
+// DownloadManifestFile and Commits.
+func WithScheduler(ctx context.Context, s *Scheduler) context.Context {
+	return context.WithValue(ctx, schedulerKey{}, s)
+}
+
+// gitilesScheduler returns the scheduler of the Gitiles requests sent with
+// ctx, or nil if there is none.
+func gitilesScheduler(ctx context.Context) *Scheduler {
+	s, _ := ctx.Value(schedulerKey{}).(*Scheduler)
+	return s
+}
+
+// doGitiles sends a Gitiles request with fn, scheduled and retried according
+// to the scheduler and the retry policy of ctx.
+func doGitiles(ctx context.Context, fn func(context.Context) error) error {
+	return gitilesScheduler(ctx).Do(ctx, gitilesRetryPolicy(ctx), GitilesRetryable, fn)
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerConcurrency(t *testing.T) {
+	const maxConcurrent, requests = 3, 20
+	scheduler := NewScheduler(maxConcurrent, 0, 0)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	active, maxActive := 0, 0
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := scheduler.Do(context.Background(), RetryPolicy{}, GitilesRetryable, func(context.Context) error {
+				mu.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxActive > maxConcurrent {
+		t.Errorf("expected at most %d concurrent requests, got %d", maxConcurrent, maxActive)
+	}
+}
+
+func TestSchedulerRateLimit(t *testing.T) {
+	tests := map[string]struct {
+		scheduler   *Scheduler
+		expectedErr bool
+	}{
+		"Nil Scheduler": {
+			scheduler: nil,
+		},
+		"Within Burst": {
+			scheduler: NewScheduler(0, 0.001, 2),
+		},
+		"Exceeds Deadline": {
+			scheduler:   NewScheduler(0, 0.001, 1),
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ctx = WithScheduler(ctx, test.scheduler)
+			runs := 0
+			var err error
+			for i := 0; i < 2 && err == nil; i++ {
+				err = doGitiles(ctx, func(context.Context) error {
+					runs++
+					return nil
+				})
+			}
+			if (err != nil) != test.expectedErr {
+				t.Errorf("expected error: %v, got %v", test.expectedErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrNotScheduled) {
+				t.Errorf("expected error to wrap ErrNotScheduled, got %v", err)
+			}
+			if test.expectedErr && runs != 1 {
+				t.Errorf("expected the second request not to be sent, got %d requests", runs)
+			}
+		})
+	}
+}